@@ -0,0 +1,72 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/qos"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// ApplyPortQosAction applies the qos attribute of the port to its br-int
+// veth, and records it in local db for detach and the reconciler.
+type ApplyPortQosAction struct {
+}
+
+func (this *ApplyPortQosAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***ApplyPortQosAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "ApplyPortQosAction")
+		}
+		AppendActionName(&err, "ApplyPortQosAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+	if portObj.EagerAttr.Qos == nil {
+		klog.Infof("***ApplyPortQosAction:Exec end, no qos***")
+		return nil
+	}
+
+	portQos := qos.NewQos(knitterInfo.mgrPort.ID, knitterInfo.vethPair.VethNameOfBridge, portObj.EagerAttr.Qos)
+	err = qos.ApplyQos(portQos)
+	if err != nil {
+		klog.Errorf("ApplyPortQosAction:Exec:qos.ApplyQos err: %v", err)
+		qos.RemoveQos(portQos)
+		return err
+	}
+	err = qos.SaveQos(cni.GetGlobalContext().DB, portQos)
+	if err != nil {
+		klog.Errorf("ApplyPortQosAction:Exec:qos.SaveQos err: %v", err)
+		qos.RemoveQos(portQos)
+		return err
+	}
+	klog.Infof("***ApplyPortQosAction:Exec end***")
+	return nil
+}
+
+func (this *ApplyPortQosAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***ApplyPortQosAction:RollBack begin***")
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	db := cni.GetGlobalContext().DB
+	portQos, err := qos.GetQos(db, knitterInfo.mgrPort.ID)
+	if err != nil {
+		klog.Infof("***ApplyPortQosAction:RollBack end, no qos***")
+		return
+	}
+	qos.RemoveQos(portQos)
+	qos.DeleteQos(db, portQos.PortID)
+	klog.Infof("***ApplyPortQosAction:RollBack end***")
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/bond"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/bridge-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/role/pod-role"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type AttachBondToPodAction struct {
+}
+
+func (this *AttachBondToPodAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***AttachBondToPodAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "AttachBondToPodAction")
+		}
+		AppendActionName(&err, "AttachBondToPodAction")
+	}()
+	bridegeObj := bridgeobj.GetBridgeObjSingleton()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+	if portObj.LazyAttr.NetAttr.ID == bridegeObj.BrintRole.GetDefaultGwNetworkID() {
+		knitterInfo.mgrPort.IsDefaultGateway = true
+		knitterInfo.mgrPort.GatewayIP = bridegeObj.BrintRole.GetDefaultGwIP()
+	}
+
+	b := knitterInfo.bond
+	nic, err := knitterInfo.podObj.PortRole.AttachBond(knitterInfo.KnitterObj.CniParam,
+		knitterInfo.mgrPort, &b.BondConf, knitterInfo.bondSlaveLinks, b.GetBusInfos())
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:Exec:transInfo.podObj.PortRole.AttachBond err: %v", err)
+		// the slaves may have been moved into pod already
+		bind.DetachBondFromPod(knitterInfo.KnitterObj.CniParam.Netns, &b.BondConf)
+		return err
+	}
+	err = bond.SaveBond(cni.GetGlobalContext().DB, b)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:Exec:bond.SaveBond err: %v", err)
+		return err
+	}
+	err = bridegeObj.BrintRole.IncRefCount(knitterInfo.mgrPort.NetworkID,
+		knitterInfo.podObj.PodNs, knitterInfo.podObj.PodName)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:Exec:bridegeObj.BrintRole.IncRefCount error: %v", err)
+		return errobj.ErrIncRefcountFailed
+	}
+
+	knitterInfo.Chan <- 1
+	knitterInfo.ChanFlag = false
+
+	portObj.LazyAttr.BusInfos = nic.BusInfos
+	portObj.LazyAttr.BondInfo = cni.BondInfo{BondType: b.Mode, BondMaster: b.Name}
+	for _, slave := range b.Slaves {
+		portObj.LazyAttr.BondInfo.BondPair = append(portObj.LazyAttr.BondInfo.BondPair, slave.Name)
+	}
+	err = knitterInfo.podObj.PortRole.StoreToDB(cni.GetGlobalContext().DB, knitterInfo.mgrPort,
+		portObj, nic.BusInfo)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:Exec:transInfo.podObj.PortRole.StoreToDB err: %v", err)
+		return errobj.ErrStorePod2DBFailed
+	}
+
+	err = knitterInfo.podObj.PortRole.StoreToDB(cni.GetGlobalContext().RemoteDB, knitterInfo.mgrPort,
+		portObj, nic.BusInfo)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:Exec:transInfo.podObj.PortRole.StoreToDB(remote) err: %v", err)
+		return errobj.ErrStorePod2etcdFailed
+	}
+
+	knitterInfo.Nics = append(knitterInfo.Nics, *nic)
+	klog.Infof("***AttachBondToPodAction:Exec end***")
+	return nil
+}
+
+func (this *AttachBondToPodAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***AttachBondToPodAction:RollBack begin***")
+	bridgeObj := bridgeobj.GetBridgeObjSingleton()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+
+	portObj.LazyAttr.ID = knitterInfo.mgrPort.ID
+	knitterObj := knitterInfo.KnitterObj
+	portObj.LazyAttr.TenantID = knitterObj.CniParam.TenantID
+	portObj.EagerAttr.PodNs = knitterObj.CniParam.PodNs
+	portObj.EagerAttr.PodName = knitterObj.CniParam.PodName
+
+	err := bind.DetachBondFromPod(knitterObj.CniParam.Netns, &knitterInfo.bond.BondConf)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:bind.DetachBondFromPod return err:%v", err)
+	}
+	err = podrole.DeleteFromDB(cni.GetGlobalContext().DB, portObj)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:podrole.DeleteFromDB return err:%v", err)
+	}
+	err = podrole.DeleteFromDB(cni.GetGlobalContext().RemoteDB, portObj)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:podrole.DeleteFromDB(remote) return err:%v", err)
+	}
+	bond.DeleteBond(cni.GetGlobalContext().DB, knitterInfo.bond.PortID)
+
+	err = bridgeObj.BrintRole.DecRefCount(portObj.LazyAttr.NetAttr.ID, portObj.EagerAttr.PodNs, portObj.EagerAttr.PodName)
+	if err != nil {
+		klog.Errorf("AttachBondToPodAction:bridgeObj.BrintRole.DecRefCount networkId: %v, err: %v", portObj.LazyAttr.NetAttr.ID, err)
+	}
+	klog.Infof("***AttachBondToPodAction:RollBack end***")
+}
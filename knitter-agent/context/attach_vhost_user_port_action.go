@@ -0,0 +1,77 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/domain/vhostuser"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type AttachVhostUserPortAction struct {
+}
+
+func (this *AttachVhostUserPortAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***AttachVhostUserPortAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "AttachVhostUserPortAction")
+		}
+		AppendActionName(&err, "AttachVhostUserPortAction")
+	}()
+	agtCtx := cni.GetGlobalContext()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+	provider := portObj.LazyAttr.NetAttr.Provider
+	bridge, err := ovs.GetOvsBrg(provider.PhysicalNetwork, agtCtx.PaasNwConfPath)
+	if err != nil {
+		klog.Errorf("AttachVhostUserPortAction:Exec:ovs.GetOvsBrg physnet[%s] err: %v", provider.PhysicalNetwork, err)
+		return err
+	}
+
+	vlanID := ""
+	if provider.NetworkType == "vlan" {
+		vlanID = provider.SegmentationID
+	}
+	vhostUser := vhostuser.NewVhostUser(knitterInfo.mgrPort.ID, knitterInfo.KnitterObj.CniParam.ContainerID,
+		knitterInfo.podObj.PodID, knitterInfo.mgrPort.Name, bridge)
+	err = vhostuser.AddVhostUserPort(vhostUser, vlanID, knitterInfo.mgrPort.MACAddress)
+	if err != nil {
+		klog.Errorf("AttachVhostUserPortAction:Exec:vhostuser.AddVhostUserPort err: %v", err)
+		return err
+	}
+	knitterInfo.vhostUser = vhostUser
+
+	err = vhostuser.SaveVhostUser(agtCtx.DB, vhostUser)
+	if err != nil {
+		klog.Errorf("AttachVhostUserPortAction:Exec:vhostuser.SaveVhostUser err: %v", err)
+		vhostuser.DelVhostUserPort(vhostUser)
+		return err
+	}
+	klog.Infof("***AttachVhostUserPortAction:Exec end***")
+	return nil
+}
+
+func (this *AttachVhostUserPortAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***AttachVhostUserPortAction:RollBack begin***")
+	vhostUser := transInfo.AppInfo.(*KnitterInfo).vhostUser
+	err := vhostuser.DelVhostUserPort(vhostUser)
+	if err != nil {
+		klog.Errorf("AttachVhostUserPortAction:RollBack:vhostuser.DelVhostUserPort err: %v", err)
+	}
+	vhostuser.DeleteVhostUser(cni.GetGlobalContext().DB, vhostUser.PortID)
+	klog.Infof("***AttachVhostUserPortAction:RollBack end***")
+}
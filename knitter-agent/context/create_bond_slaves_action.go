@@ -0,0 +1,130 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"strconv"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/bond"
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/bridge-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/os-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/util/implement"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type CreateBondSlavesAction struct {
+}
+
+func (this *CreateBondSlavesAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***CreateBondSlavesAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "CreateBondSlavesAction")
+		}
+		AppendActionName(&err, "CreateBondSlavesAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+	cniParam := knitterInfo.KnitterObj.CniParam
+	portBond := portObj.EagerAttr.Bond
+	knitterInfo.bond = bond.NewBond(knitterInfo.mgrPort.ID, cniParam.ContainerID, knitterInfo.mgrPort.Name, portBond)
+
+	if len(portBond.Physnets) > 0 {
+		vlan := 0
+		provider := portObj.LazyAttr.NetAttr.Provider
+		if provider.NetworkType == "vlan" {
+			vlan, _ = strconv.Atoi(provider.SegmentationID)
+		}
+		knitterInfo.bondSlaveLinks, err = knitterInfo.bond.AllocVfSlaves(cniParam.PodNs, cniParam.PodName,
+			portBond.Physnets, knitterInfo.mgrPort.MACAddress, vlan)
+		if err != nil {
+			klog.Errorf("CreateBondSlavesAction.Exec: bond.AllocVfSlaves error: %v", err)
+			return err
+		}
+		klog.Infof("***CreateBondSlavesAction:Exec end***")
+		return nil
+	}
+
+	knitterInfo.bondSlaveLinks, err = createBondVethSlaves(knitterInfo.bond, portObj.LazyAttr.VlanID)
+	if err != nil {
+		klog.Errorf("CreateBondSlavesAction.Exec: createBondVethSlaves error: %v", err)
+		releaseBondVethSlaves(knitterInfo.bond)
+		return err
+	}
+	klog.Infof("***CreateBondSlavesAction:Exec end***")
+	return nil
+}
+
+func (this *CreateBondSlavesAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***CreateBondSlavesAction:RollBack begin***")
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	if knitterInfo.bond.IsVfBond() {
+		knitterInfo.bond.ReleaseVfSlaves()
+	} else {
+		releaseBondVethSlaves(knitterInfo.bond)
+	}
+	klog.Infof("***CreateBondSlavesAction:RollBack end***")
+}
+
+// createBondVethSlaves attaches two veth pairs to br-int with the local vlan
+// of the network, the pod sides of them are returned to be enslaved.
+func createBondVethSlaves(b *bond.Bond, vlanID string) ([]*bind.Link, error) {
+	osObj := osobj.GetOsObjSingleton()
+	bridgeObj := bridgeobj.GetBridgeObjSingleton()
+	links := make([]*bind.Link, 0)
+	for i := 0; i < constvalue.BondPairNum; i++ {
+		vethPair, err := osObj.VethPairRole.Create()
+		if err != nil {
+			klog.Errorf("createBondVethSlaves: osObj.VethPairRole.Create error: %v", err)
+			return nil, err
+		}
+		b.AddVethSlave(vethPair.VethNameOfBridge)
+		err = bridgeObj.BrintRole.InsertPortTable(bond.GetSlaveKeyOfPortTable(b.PortID, i), vethPair.VethNameOfBridge)
+		if err != nil {
+			klog.Errorf("createBondVethSlaves: bridgeObj.BrintRole.InsertPortTable err: %v", err)
+		}
+		err = bridgeObj.BrintRole.AttachPort(vethPair.VethNameOfBridge, vlanID)
+		if err != nil {
+			klog.Errorf("createBondVethSlaves: bridgeObj.BrintRole.AttachPort err: %v", err)
+			return nil, err
+		}
+		link, err := ovs.GetLinkByName(vethPair.VethNameOfPod)
+		if err != nil {
+			klog.Errorf("createBondVethSlaves: ovs.GetLinkByName(%s) err: %v", vethPair.VethNameOfPod, err)
+			return nil, err
+		}
+		links = append(links, &bind.Link{Link: link, Netlink: &implement.NetLink{}})
+	}
+	return links, nil
+}
+
+func releaseBondVethSlaves(b *bond.Bond) {
+	osObj := osobj.GetOsObjSingleton()
+	bridgeObj := bridgeobj.GetBridgeObjSingleton()
+	for i, slave := range b.Slaves {
+		err := bridgeObj.BrintRole.DetachPort(slave.HostName)
+		if err != nil {
+			klog.Errorf("releaseBondVethSlaves: bridgeObj.BrintRole.DetachPort vethName:%s err: %v", slave.HostName, err)
+		}
+		err = bridgeObj.BrintRole.DelPortTable(bond.GetSlaveKeyOfPortTable(b.PortID, i))
+		if err != nil {
+			klog.Errorf("releaseBondVethSlaves: bridgeObj.BrintRole.DelPortTable err: %v", err)
+		}
+		osObj.VethPairRole.Destroy(slave.HostName)
+	}
+}
@@ -16,6 +16,7 @@ package context
 import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/db-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/physical-resource-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/vhostuser"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/ZTE/Knitter/pkg/trans-dsl"
 )
@@ -39,6 +40,9 @@ func (this *DelPodAction) Exec(transInfo *transdsl.TransInfo) (err error) {
 	}
 
 	dbObj.PodRole.Delete(knitterObj.CniParam)
+	if knitterAgtInfo.podObj != nil {
+		vhostuser.RemoveSocketDir(knitterAgtInfo.podObj.PodID)
+	}
 
 	(&physicalresourceobj.NouthInterfaceObj{ContainerID: knitterObj.CniParam.ContainerID}).DeleteContainer()
 	klog.Infof("***DelPodAction:Exec end***")
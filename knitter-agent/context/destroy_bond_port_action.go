@@ -0,0 +1,64 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/bond"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/bridge-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/role/pod-role"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type DestroyBondPortAction struct {
+}
+
+func (this *DestroyBondPortAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***DestroyBondPortAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "DestroyBondPortAction")
+		}
+		AppendActionName(&err, "DestroyBondPortAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.portObj
+
+	bridgeObj := bridgeobj.GetBridgeObjSingleton()
+	networkID := portObj.LazyAttr.NetAttr.ID
+	err = bridgeObj.BrintRole.DecRefCount(networkID, portObj.EagerAttr.PodNs, portObj.EagerAttr.PodName)
+	if err != nil {
+		klog.Errorf("DestroyBondPortAction:bridgeObj.BrintRole.DecRefCount networkId: %v, err: %v", networkID, err)
+	}
+
+	err = podrole.DeleteFromDB(cni.GetGlobalContext().DB, portObj)
+	if err != nil {
+		klog.Errorf("DestroyBondPortAction:Exec:podrole.DeleteFromDB err: %v", err)
+	}
+
+	err = podrole.DeleteFromDB(cni.GetGlobalContext().RemoteDB, portObj)
+	if err != nil {
+		klog.Errorf("DestroyBondPortAction:Exec:podrole.DeleteFromDB(remote) err: %v", err)
+	}
+
+	bond.DeleteBond(cni.GetGlobalContext().DB, portObj.LazyAttr.ID)
+	klog.Infof("***DestroyBondPortAction:Exec end***")
+	return nil
+}
+
+func (this *DestroyBondPortAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***DestroyBondPortAction:RollBack begin***")
+	klog.Infof("***DestroyBondPortAction:RollBack end***")
+}
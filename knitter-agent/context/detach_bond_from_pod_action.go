@@ -0,0 +1,74 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/bond"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-agent-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type DetachBondFromPodAction struct {
+}
+
+func (this *DetachBondFromPodAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***DetachBondFromPodAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "DetachBondFromPodAction")
+		}
+		AppendActionName(&err, "DetachBondFromPodAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	knitterAgtObj := knitteragtobj.GetKnitterAgtObjSingleton()
+	portObj, err := knitterAgtObj.PortObjRole.Create(knitterInfo.ports[transInfo.RepeatIdx].Value)
+	if err != nil {
+		return err
+	}
+	knitterInfo.portObj = portObj
+
+	b, err := bond.GetBond(cni.GetGlobalContext().DB, portObj.LazyAttr.ID)
+	if err != nil {
+		klog.Errorf("DetachBondFromPodAction: bond.GetBond port[%s] error: %v", portObj.LazyAttr.ID, err)
+		return err
+	}
+	knitterInfo.bond = b
+
+	// the VFs go back to host by themselves when the netns of pod is gone
+	if new(IsNetNsExist).Ok(transInfo) {
+		err = bind.DetachBondFromPod(knitterInfo.KnitterObj.CniParam.Netns, &b.BondConf)
+		if err != nil {
+			klog.Errorf("DetachBondFromPodAction: bind.DetachBondFromPod bond[%s] error: %v", b.Name, err)
+		}
+	}
+
+	if b.IsVfBond() {
+		err = b.ReleaseVfSlaves()
+		if err != nil {
+			klog.Errorf("DetachBondFromPodAction: release vfs of port[%s] error: %v", b.PortID, err)
+		}
+	} else {
+		releaseBondVethSlaves(b)
+	}
+	klog.Infof("***DetachBondFromPodAction:Exec end***")
+	return nil
+}
+
+func (this *DetachBondFromPodAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***DetachBondFromPodAction:RollBack begin***")
+	klog.Infof("***DetachBondFromPodAction:RollBack end***")
+}
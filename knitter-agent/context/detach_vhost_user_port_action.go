@@ -0,0 +1,71 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-agent-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/role/pod-role"
+	"github.com/ZTE/Knitter/knitter-agent/domain/vhostuser"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type DetachVhostUserPortAction struct {
+}
+
+func (this *DetachVhostUserPortAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***DetachVhostUserPortAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "DetachVhostUserPortAction")
+		}
+		AppendActionName(&err, "DetachVhostUserPortAction")
+	}()
+	agtCtx := cni.GetGlobalContext()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	knitterAgtObj := knitteragtobj.GetKnitterAgtObjSingleton()
+	portObj, err := knitterAgtObj.PortObjRole.Create(knitterInfo.ports[transInfo.RepeatIdx].Value)
+	if err != nil {
+		return err
+	}
+	knitterInfo.portObj = portObj
+
+	vhostUser, err := vhostuser.GetVhostUser(agtCtx.DB, portObj.LazyAttr.ID)
+	if err != nil {
+		klog.Errorf("DetachVhostUserPortAction: vhostuser.GetVhostUser port[%s] error: %v", portObj.LazyAttr.ID, err)
+		return err
+	}
+	err = vhostuser.DelVhostUserPort(vhostUser)
+	if err != nil {
+		klog.Errorf("DetachVhostUserPortAction: vhostuser.DelVhostUserPort port[%s] error: %v", vhostUser.IfName, err)
+	}
+
+	err = podrole.DeleteFromDB(agtCtx.DB, portObj)
+	if err != nil {
+		klog.Errorf("DetachVhostUserPortAction:Exec:podrole.DeleteFromDB err: %v", err)
+	}
+	err = podrole.DeleteFromDB(agtCtx.RemoteDB, portObj)
+	if err != nil {
+		klog.Errorf("DetachVhostUserPortAction:Exec:podrole.DeleteFromDB(remote) err: %v", err)
+	}
+	vhostuser.DeleteVhostUser(agtCtx.DB, portObj.LazyAttr.ID)
+	klog.Infof("***DetachVhostUserPortAction:Exec end***")
+	return nil
+}
+
+func (this *DetachVhostUserPortAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***DetachVhostUserPortAction:RollBack begin***")
+	klog.Infof("***DetachVhostUserPortAction:RollBack end***")
+}
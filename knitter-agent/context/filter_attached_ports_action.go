@@ -0,0 +1,79 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"path"
+
+	"github.com/coreos/etcd/client"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/db-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// FilterAttachedPortsAction keeps the ports of the pod not attached yet, it
+// is used by hot-plug after the pod is got from knitter-monitor.
+type FilterAttachedPortsAction struct {
+}
+
+func (this *FilterAttachedPortsAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***FilterAttachedPortsAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "FilterAttachedPortsAction")
+		}
+		AppendActionName(&err, "FilterAttachedPortsAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	cniParam := knitterInfo.KnitterObj.CniParam
+
+	dbObj := dbobj.GetDbObjSingleton()
+	ports, err := dbObj.PodRole.GetAllPorts(cniParam.TenantID, cniParam.PodNs, cniParam.PodName, cniParam.DB)
+	if err != nil && !errobj.IsKeyNotFoundError(err) {
+		klog.Errorf("FilterAttachedPortsAction: dbObj.PodRole.GetAllPorts err: %v", err)
+		return err
+	}
+	attachedPortIDs := getPortIDsOfNodes(ports)
+
+	portObjs := make([]*portobj.PortObj, 0)
+	for _, portObj := range knitterInfo.podObj.PortObjs {
+		if attachedPortIDs[portObj.LazyAttr.ID] {
+			continue
+		}
+		klog.Infof("FilterAttachedPortsAction: port[id: %v, name: %v] need to attach",
+			portObj.LazyAttr.ID, portObj.EagerAttr.PortName)
+		portObjs = append(portObjs, portObj)
+	}
+	knitterInfo.podObj.PortObjs = portObjs
+	transInfo.Times = len(portObjs)
+	klog.Infof("***FilterAttachedPortsAction:Exec end***")
+	return nil
+}
+
+func (this *FilterAttachedPortsAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***FilterAttachedPortsAction:RollBack begin***")
+	klog.Infof("***FilterAttachedPortsAction:RollBack end***")
+}
+
+// the key of port in pod is ended with port id
+func getPortIDsOfNodes(nodes []*client.Node) map[string]bool {
+	portIDs := make(map[string]bool)
+	for _, node := range nodes {
+		portIDs[path.Base(node.Key)] = true
+	}
+	return portIDs
+}
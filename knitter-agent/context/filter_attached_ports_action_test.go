@@ -0,0 +1,70 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/coreos/etcd/client"
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/pod-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/role/db-role"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+func newHotPlugTransInfo(portIDs ...string) *transdsl.TransInfo {
+	cniParam := &cni.CniParam{TenantID: "admin", PodNs: "admin", PodName: "pod1"}
+	podObj := &podobj.PodObj{}
+	for _, portID := range portIDs {
+		podObj.PortObjs = append(podObj.PortObjs, &portobj.PortObj{LazyAttr: portobj.PortLazyAttr{ID: portID}})
+	}
+	knitterInfo := &KnitterInfo{KnitterObj: &knitterobj.KnitterObj{CniParam: cniParam}, podObj: podObj}
+	return &transdsl.TransInfo{AppInfo: knitterInfo}
+}
+
+func patchAttachedPorts(portIDs ...string) {
+	monkey.PatchInstanceMethod(reflect.TypeOf(dbrole.PodRole{}), "GetAllPorts",
+		func(_ dbrole.PodRole, tenantID, podNs, podName string, db dbaccessor.DbAccessor) ([]*client.Node, error) {
+			nodes := make([]*client.Node, 0)
+			for _, portID := range portIDs {
+				key := dbaccessor.GetKeyOfInterfaceInPod(tenantID, portID, podNs, podName)
+				nodes = append(nodes, &client.Node{Key: key, Value: key})
+			}
+			return nodes, nil
+		})
+}
+
+func TestFilterAttachedPortsAction(t *testing.T) {
+	patchAttachedPorts("port-1", "port-2")
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestFilterAttachedPortsAction\n", t, func() {
+		transInfo := newHotPlugTransInfo("port-1", "port-3")
+		action := FilterAttachedPortsAction{}
+		err := action.Exec(transInfo)
+		convey.So(err, convey.ShouldBeNil)
+		portObjs := transInfo.AppInfo.(*KnitterInfo).podObj.PortObjs
+		convey.So(len(portObjs), convey.ShouldEqual, 1)
+		convey.So(portObjs[0].LazyAttr.ID, convey.ShouldEqual, "port-3")
+		convey.So(transInfo.Times, convey.ShouldEqual, 1)
+		convey.So((&IsPortsToAttach{}).Ok(transInfo), convey.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"path"
+
+	"github.com/coreos/etcd/client"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/db-obj"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// FilterDetachedPortsAction keeps the attached ports not in the pod got from
+// knitter-monitor any more, it is used by hot-unplug.
+type FilterDetachedPortsAction struct {
+}
+
+func (this *FilterDetachedPortsAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***FilterDetachedPortsAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "FilterDetachedPortsAction")
+		}
+		AppendActionName(&err, "FilterDetachedPortsAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	cniParam := knitterInfo.KnitterObj.CniParam
+
+	dbObj := dbobj.GetDbObjSingleton()
+	attachedPorts, err := dbObj.PodRole.GetAllPorts(cniParam.TenantID, cniParam.PodNs, cniParam.PodName, cniParam.DB)
+	if err != nil && !errobj.IsKeyNotFoundError(err) {
+		klog.Errorf("FilterDetachedPortsAction: dbObj.PodRole.GetAllPorts err: %v", err)
+		return err
+	}
+
+	portIDs := make(map[string]bool)
+	for _, portObj := range knitterInfo.podObj.PortObjs {
+		portIDs[portObj.LazyAttr.ID] = true
+	}
+
+	ports := make([]*client.Node, 0)
+	for _, port := range attachedPorts {
+		if portIDs[path.Base(port.Key)] {
+			continue
+		}
+		klog.Infof("FilterDetachedPortsAction: port[%v] need to detach", port.Key)
+		ports = append(ports, port)
+	}
+	knitterInfo.ports = ports
+	transInfo.Times = len(ports)
+	klog.Infof("***FilterDetachedPortsAction:Exec end***")
+	return nil
+}
+
+func (this *FilterDetachedPortsAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***FilterDetachedPortsAction:RollBack begin***")
+	klog.Infof("***FilterDetachedPortsAction:RollBack end***")
+}
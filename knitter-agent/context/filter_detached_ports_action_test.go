@@ -0,0 +1,38 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"path"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFilterDetachedPortsAction(t *testing.T) {
+	patchAttachedPorts("port-1", "port-2")
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestFilterDetachedPortsAction\n", t, func() {
+		transInfo := newHotPlugTransInfo("port-1", "port-3")
+		action := FilterDetachedPortsAction{}
+		err := action.Exec(transInfo)
+		convey.So(err, convey.ShouldBeNil)
+		ports := transInfo.AppInfo.(*KnitterInfo).ports
+		convey.So(len(ports), convey.ShouldEqual, 1)
+		convey.So(path.Base(ports[0].Key), convey.ShouldEqual, "port-2")
+		convey.So(transInfo.Times, convey.ShouldEqual, 1)
+	})
+}
@@ -16,6 +16,7 @@ package context
 import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
 	"github.com/ZTE/Knitter/knitter-agent/domain/manager"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/ZTE/Knitter/pkg/trans-dsl"
 )
@@ -46,12 +47,18 @@ func (this *GeneralModeGetMgrPortAction) Exec(transInfo *transdsl.TransInfo) (er
 		TenantID:    portObj.LazyAttr.TenantID,
 		CIDR:        portObj.LazyAttr.Cidr,
 		GatewayIP:   portObj.LazyAttr.GatewayIP,
-		MTU:         agtCtx.Mtu,
+		MTU:         agtCtx.GetPodMtu(portObj.LazyAttr.NetAttr.Mtu, isOverlayPort(portObj)),
 	}
 	klog.Infof("***GeneralModeGetMgrPortAction:Exec end***")
 	return nil
 }
 
+// isOverlayPort tells the ports carried by br-tun, bond and vhost-user ports
+// go out of the physnets directly.
+func isOverlayPort(portObj *portobj.PortObj) bool {
+	return portObj.EagerAttr.Bond == nil && portObj.LazyAttr.NetAttr.Provider.NetworkType == "vxlan"
+}
+
 func (this *GeneralModeGetMgrPortAction) RollBack(transInfo *transdsl.TransInfo) {
 	klog.Infof("***GeneralModeGetMgrPortAction:RollBack begin***")
 	klog.Infof("***GeneralModeGetMgrPortAction:RollBack end***")
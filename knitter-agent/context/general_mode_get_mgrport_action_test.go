@@ -10,6 +10,7 @@ import (
 	//"github.com/smartystreets/goconvey/convey"
 	//"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
 	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/pod-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
@@ -47,6 +48,19 @@ func TestGeneralModeGetMgrPortActionSucc(t *testing.T) {
 	})
 }
 
+func TestIsOverlayPort(t *testing.T) {
+	convey.Convey("TestIsOverlayPort\n", t, func() {
+		portObj := &portobj.PortObj{}
+		portObj.LazyAttr.NetAttr.Provider.NetworkType = "vxlan"
+		convey.So(isOverlayPort(portObj), convey.ShouldBeTrue)
+		portObj.EagerAttr.Bond = &monitor.PortBond{}
+		convey.So(isOverlayPort(portObj), convey.ShouldBeFalse)
+		portObj.EagerAttr.Bond = nil
+		portObj.LazyAttr.NetAttr.Provider.NetworkType = "vlan"
+		convey.So(isOverlayPort(portObj), convey.ShouldBeFalse)
+	})
+}
+
 func TestGeneralModeGetMgrPortActionRollBack(t *testing.T) {
 	action := &GeneralModeGetMgrPortAction{}
 	cniParam := &cni.CniParam{PodNs: "nw001", TenantID: "tenant1"}
@@ -94,6 +94,16 @@ func CheckPortsVnicType(portsObj []*portobj.PortObj) error {
 	agtCtx := cni.GetGlobalContext()
 
 	for _, portObj := range portsObj {
+		// physnets of bond port are given by the bond attribute
+		if portObj.EagerAttr.Bond != nil {
+			continue
+		}
+		// accelerated ports of DPDK physnets are vhost-user ports on OVS
+		if portObj.EagerAttr.Accelerate == "true" &&
+			ovs.IsDpdkPhysnet(portObj.LazyAttr.NetAttr.Provider.PhysicalNetwork, agtCtx.PaasNwConfPath) {
+			portObj.EagerAttr.VnicType = "normal"
+			continue
+		}
 		VnicType, errSetVnicType := checkVnicType(agtCtx, portObj.LazyAttr.NetAttr.Provider.PhysicalNetwork, portObj.EagerAttr.VnicType)
 		if errSetVnicType != nil {
 			klog.Errorf("checkVnicType:%v error:%v", portObj.EagerAttr.VnicType,
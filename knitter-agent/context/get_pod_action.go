@@ -58,6 +58,10 @@ func (this *GetPodAction) Exec(transInfo *transdsl.TransInfo) (err error) {
 		err = errors.New("mtr.GetPodRole.GetPod " + monitorPod.ErrorMsg)
 		return err
 	}
+	// the tenant of the namespace is mapped by knitter-monitor
+	if monitorPod.TenantId != "" {
+		knitterObj.CniParam.TenantID = monitorPod.TenantId
+	}
 	//todo to delete but podjson is using
 	_, podJSON, err := clusterMgrObj.ClusterMgrRole.GetPod(podNs, podName)
 	//if trans is detach, need to judge return code 200
@@ -0,0 +1,39 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type IsBondPort struct {
+}
+
+func (this *IsBondPort) Ok(transInfo *transdsl.TransInfo) bool {
+	portObj := transInfo.AppInfo.(*KnitterInfo).podObj.PortObjs[transInfo.RepeatIdx]
+	if portObj.EagerAttr.Bond != nil {
+		klog.Infof("***IsBondPort: true***")
+		return true
+	}
+	klog.Infof("***IsBondPort: false***")
+	return false
+}
+
+type IsNotBondPort struct {
+}
+
+func (this *IsNotBondPort) Ok(transInfo *transdsl.TransInfo) bool {
+	return !new(IsBondPort).Ok(transInfo)
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/pod-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+func TestIsBondPort(t *testing.T) {
+	portObjs := []*portobj.PortObj{
+		{EagerAttr: portobj.PortEagerAttr{VnicType: "normal"}},
+		{EagerAttr: portobj.PortEagerAttr{VnicType: "normal", Bond: &monitor.PortBond{Mode: "active-backup"}}},
+	}
+	knitterInfo := &KnitterInfo{podObj: &podobj.PodObj{PortObjs: portObjs}}
+
+	convey.Convey("TestIsBondPort\n", t, func() {
+		transInfo := &transdsl.TransInfo{AppInfo: knitterInfo, RepeatIdx: 0}
+		convey.So(new(IsBondPort).Ok(transInfo), convey.ShouldBeFalse)
+		convey.So(new(IsNotBondPort).Ok(transInfo), convey.ShouldBeTrue)
+		transInfo.RepeatIdx = 1
+		convey.So(new(IsBondPort).Ok(transInfo), convey.ShouldBeTrue)
+		convey.So(new(IsNotBondPort).Ok(transInfo), convey.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"path"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/bond"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// IsBondPortToDetach tells the bond ports by the bond records in local db,
+// the port id is the last part of the key of the port in pod.
+type IsBondPortToDetach struct {
+}
+
+func (this *IsBondPortToDetach) Ok(transInfo *transdsl.TransInfo) bool {
+	port := transInfo.AppInfo.(*KnitterInfo).ports[transInfo.RepeatIdx]
+	if bond.IsBondPort(cni.GetGlobalContext().DB, path.Base(port.Key)) {
+		klog.Infof("***IsBondPortToDetach: true***")
+		return true
+	}
+	klog.Infof("***IsBondPortToDetach: false***")
+	return false
+}
+
+type IsNotBondPortToDetach struct {
+}
+
+func (this *IsNotBondPortToDetach) Ok(transInfo *transdsl.TransInfo) bool {
+	return !new(IsBondPortToDetach).Ok(transInfo)
+}
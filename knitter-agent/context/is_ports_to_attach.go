@@ -11,28 +11,22 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package brtunsubrole
+package context
 
-const (
-	InvalidTunnulPortID int = 4096
-	VxlanPortOffset     int = 2
+import (
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
 )
 
-type PortIDAllocatorRole struct {
-	portIds [InvalidTunnulPortID - VxlanPortOffset]bool
+type IsPortsToAttach struct {
 }
 
-func (this *PortIDAllocatorRole) Alloc() int {
-	for index := range this.portIds {
-		if this.portIds[index] == false {
-			this.portIds[index] = true
-			return index + VxlanPortOffset
-		}
+func (this *IsPortsToAttach) Ok(transInfo *transdsl.TransInfo) bool {
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	if len(knitterInfo.podObj.PortObjs) > 0 {
+		klog.Infof("***IsPortsToAttach: true***")
+		return true
 	}
-	return InvalidTunnulPortID
-}
-
-func (this *PortIDAllocatorRole) Free(portID int) error {
-	this.portIds[portID-VxlanPortOffset] = false
-	return nil
+	klog.Infof("***IsPortsToAttach: false***")
+	return false
 }
@@ -0,0 +1,48 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// IsVhostUserPort tells the accelerated ports of vlan or flat networks whose
+// physnet is mapped to an OVS bridge of the netdev datapath.
+type IsVhostUserPort struct {
+}
+
+func (this *IsVhostUserPort) Ok(transInfo *transdsl.TransInfo) bool {
+	portObj := transInfo.AppInfo.(*KnitterInfo).podObj.PortObjs[transInfo.RepeatIdx]
+	provider := portObj.LazyAttr.NetAttr.Provider
+	if portObj.EagerAttr.Accelerate == "true" && portObj.EagerAttr.VnicType == constvalue.MechDriverOvs &&
+		portObj.EagerAttr.Bond == nil &&
+		(provider.NetworkType == "vlan" || provider.NetworkType == "flat") &&
+		ovs.IsDpdkPhysnet(provider.PhysicalNetwork, cni.GetGlobalContext().PaasNwConfPath) {
+		klog.Infof("***IsVhostUserPort: true***")
+		return true
+	}
+	klog.Infof("***IsVhostUserPort: false***")
+	return false
+}
+
+type IsNotVhostUserPort struct {
+}
+
+func (this *IsNotVhostUserPort) Ok(transInfo *transdsl.TransInfo) bool {
+	return !new(IsVhostUserPort).Ok(transInfo)
+}
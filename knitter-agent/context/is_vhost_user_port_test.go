@@ -0,0 +1,70 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"testing"
+
+	"github.com/golang/gostub"
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/pod-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+func TestIsVhostUserPort(t *testing.T) {
+	stubs := gostub.Stub(&ovs.GetOvsBrg, func(phyNw string, confPath string) (string, error) {
+		return "br-" + phyNw, nil
+	})
+	stubs.Stub(&ovs.GetBridgeDatapathType, func(bridge string) (string, error) {
+		if bridge == "br-physnet1" {
+			return "netdev", nil
+		}
+		return "", nil
+	})
+	defer stubs.Reset()
+
+	newPortObj := func(accelerate, networkType, physnet string) *portobj.PortObj {
+		portObj := &portobj.PortObj{EagerAttr: portobj.PortEagerAttr{VnicType: "normal", Accelerate: accelerate}}
+		portObj.LazyAttr.NetAttr.Provider = iaasaccessor.NetworkExtenAttrs{
+			NetworkType: networkType, PhysicalNetwork: physnet}
+		return portObj
+	}
+	bondPortObj := newPortObj("true", "vlan", "physnet1")
+	bondPortObj.EagerAttr.Bond = &monitor.PortBond{Mode: "active-backup"}
+	portObjs := []*portobj.PortObj{
+		newPortObj("true", "vlan", "physnet1"),
+		newPortObj("true", "flat", "physnet1"),
+		newPortObj("false", "vlan", "physnet1"),
+		newPortObj("true", "vxlan", "physnet1"),
+		newPortObj("true", "vlan", "physnet2"),
+		bondPortObj,
+	}
+	knitterInfo := &KnitterInfo{podObj: &podobj.PodObj{PortObjs: portObjs}}
+
+	convey.Convey("TestIsVhostUserPort\n", t, func() {
+		oks := make([]bool, 0)
+		for idx := range portObjs {
+			transInfo := &transdsl.TransInfo{AppInfo: knitterInfo, RepeatIdx: idx}
+			ok := new(IsVhostUserPort).Ok(transInfo)
+			convey.So(new(IsNotVhostUserPort).Ok(transInfo), convey.ShouldEqual, !ok)
+			oks = append(oks, ok)
+		}
+		convey.So(oks, convey.ShouldResemble, []bool{true, true, false, false, false, false})
+	})
+}
@@ -15,11 +15,13 @@ package context
 
 import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/bond"
 	"github.com/ZTE/Knitter/knitter-agent/domain/manager"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/pod-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/port-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/physical-resource-role"
+	"github.com/ZTE/Knitter/knitter-agent/domain/vhostuser"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-agt"
 
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-obj"
@@ -35,6 +37,9 @@ type KnitterInfo struct {
 	mgrPort             *manager.Port
 	vethNameOk          bool
 	vethPair            *ovs.VethPair
+	bond                *bond.Bond
+	bondSlaveLinks      []*bind.Link
+	vhostUser           *vhostuser.VhostUser
 	Nics                []bind.Dpdknic
 	Chan                chan int
 	ChanFlag            bool
@@ -0,0 +1,58 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"path"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/qos"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// RemovePortQosAction removes the qos of the port before its veth is
+// detached from br-int, the ofport of the DSCP flows is still valid then.
+type RemovePortQosAction struct {
+}
+
+func (this *RemovePortQosAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***RemovePortQosAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "RemovePortQosAction")
+		}
+		AppendActionName(&err, "RemovePortQosAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portID := path.Base(knitterInfo.ports[transInfo.RepeatIdx].Key)
+	db := cni.GetGlobalContext().DB
+	portQos, err := qos.GetQos(db, portID)
+	if err != nil {
+		klog.Infof("***RemovePortQosAction:Exec end, no qos***")
+		return nil
+	}
+	err = qos.RemoveQos(portQos)
+	if err != nil {
+		klog.Warningf("RemovePortQosAction:Exec:qos.RemoveQos port[%s] err: %v", portID, err)
+	}
+	qos.DeleteQos(db, portID)
+	klog.Infof("***RemovePortQosAction:Exec end***")
+	return nil
+}
+
+func (this *RemovePortQosAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***RemovePortQosAction:RollBack begin***")
+	klog.Infof("***RemovePortQosAction:RollBack end***")
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package context
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/role/pod-role"
+	"github.com/ZTE/Knitter/knitter-agent/domain/vhostuser"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// SaveVhostUserPortAction stores the port and passes the socket to the DPDK
+// application in pod by net-config.json, nothing is made in the netns of pod.
+type SaveVhostUserPortAction struct {
+}
+
+func (this *SaveVhostUserPortAction) Exec(transInfo *transdsl.TransInfo) (err error) {
+	klog.Infof("***SaveVhostUserPortAction:Exec begin***")
+	defer func() {
+		if p := recover(); p != nil {
+			RecoverErr(p, &err, "SaveVhostUserPortAction")
+		}
+		AppendActionName(&err, "SaveVhostUserPortAction")
+	}()
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+
+	nic, err := bind.BuildNormalNic(knitterInfo.mgrPort, "")
+	if err != nil {
+		klog.Errorf("SaveVhostUserPortAction:Exec:bind.BuildNormalNic err: %v", err)
+		return errobj.ErrBuildNormalNicFailed
+	}
+	nic.Accelerate = "true"
+	nic.SocketPath = knitterInfo.vhostUser.SocketPath
+	nic.SocketName = vhostuser.GetSocketName(knitterInfo.vhostUser)
+
+	err = knitterInfo.podObj.PortRole.StoreToDB(cni.GetGlobalContext().DB, knitterInfo.mgrPort,
+		portObj, nic.BusInfo)
+	if err != nil {
+		klog.Errorf("SaveVhostUserPortAction:Exec:transInfo.podObj.PortRole.StoreToDB err: %v", err)
+		return errobj.ErrStorePod2DBFailed
+	}
+
+	err = knitterInfo.podObj.PortRole.StoreToDB(cni.GetGlobalContext().RemoteDB, knitterInfo.mgrPort,
+		portObj, nic.BusInfo)
+	if err != nil {
+		klog.Errorf("SaveVhostUserPortAction:Exec:transInfo.podObj.PortRole.StoreToDB(remote) err: %v", err)
+		return errobj.ErrStorePod2etcdFailed
+	}
+
+	knitterInfo.Nics = append(knitterInfo.Nics, *nic)
+	klog.Infof("***SaveVhostUserPortAction:Exec end***")
+	return nil
+}
+
+func (this *SaveVhostUserPortAction) RollBack(transInfo *transdsl.TransInfo) {
+	klog.Infof("***SaveVhostUserPortAction:RollBack begin***")
+	knitterInfo := transInfo.AppInfo.(*KnitterInfo)
+	portObj := knitterInfo.podObj.PortObjs[transInfo.RepeatIdx]
+
+	portObj.LazyAttr.ID = knitterInfo.mgrPort.ID
+	knitterObj := knitterInfo.KnitterObj
+	portObj.LazyAttr.TenantID = knitterObj.CniParam.TenantID
+	portObj.EagerAttr.PodNs = knitterObj.CniParam.PodNs
+	portObj.EagerAttr.PodName = knitterObj.CniParam.PodName
+
+	err := podrole.DeleteFromDB(cni.GetGlobalContext().DB, portObj)
+	if err != nil {
+		klog.Errorf("SaveVhostUserPortAction:podrole.DeleteFromDB return err:%v", err)
+	}
+	err = podrole.DeleteFromDB(cni.GetGlobalContext().RemoteDB, portObj)
+	if err != nil {
+		klog.Errorf("SaveVhostUserPortAction:podrole.DeleteFromDB(remote) return err:%v", err)
+	}
+	klog.Infof("***SaveVhostUserPortAction:RollBack end***")
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/manager"
+	"github.com/ZTE/Knitter/knitter-agent/infra/util/implement"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/vishvananda/netlink"
+)
+
+// BondSlave is a VF or the pod side of a veth pair enslaved by the bond of a
+// port. HostName is the name of the VF in host, or the name of the bridge side
+// of the veth pair.
+type BondSlave struct {
+	Name     string `json:"name"`
+	HostName string `json:"host_name"`
+	IsVf     bool   `json:"is_vf"`
+	Physnet  string `json:"physnet,omitempty"`
+	PciAddr  string `json:"pci_addr,omitempty"`
+}
+
+// BondConf is the Linux bond made in pod for a port, its name is the name of
+// the port.
+type BondConf struct {
+	Name   string      `json:"name"`
+	Mode   string      `json:"mode"`
+	Miimon int         `json:"miimon"`
+	Slaves []BondSlave `json:"slaves"`
+}
+
+// GetBondSlaveName names the slaves of bond eth1 as eth1s0, eth1s1 in pod.
+func GetBondSlaveName(bondName string, idx int) string {
+	return bondName + "s" + strconv.Itoa(idx)
+}
+
+var AttachBondToPod = func(netNs string, port *manager.Port, conf *BondConf, slaveLinks []*Link) error {
+	pod := NewTempPod()
+	pod.SetNameSpace(&implement.NameSpace{})
+	pod.SetNetLink(&implement.NetLink{})
+
+	pid, err := NetNSToPID(netNs)
+	if err != nil {
+		klog.Errorf("AttachBondToPod: NetNSToPID(%s) error: %v", netNs, err)
+		return err
+	}
+	container := NewContainer(pid)
+	container.SetNetLink(&implement.NetLink{})
+	container.SetNetNs(&implement.NetNs{})
+
+	err = pod.AddBondToContainer(container, slaveLinks, port, conf)
+	if err != nil {
+		return fmt.Errorf("%v:bind-AttachBondToPod:pod.AddBondToContainer error", err)
+	}
+	return nil
+}
+
+var DetachBondFromPod = func(netNs string, conf *BondConf) error {
+	pod := NewTempPod()
+	pod.SetNameSpace(&implement.NameSpace{})
+	pod.SetNetLink(&implement.NetLink{})
+
+	pid, err := NetNSToPID(netNs)
+	if err != nil {
+		klog.Errorf("DetachBondFromPod: NetNSToPID(%s) error: %v", netNs, err)
+		return err
+	}
+	container := NewContainer(pid)
+	container.SetNetLink(&implement.NetLink{})
+	container.SetNetNs(&implement.NetNs{})
+
+	return pod.DeleteBondInContainer(container, conf)
+}
+
+// AddBondToContainer moves the slaves into the container, then makes the bond
+// with the MAC, MTU and IP of the port in it and enslaves the slaves.
+func (self *Pod) AddBondToContainer(container *Container, slaveLinks []*Link, port *manager.Port, conf *BondConf) error {
+	if len(slaveLinks) != len(conf.Slaves) {
+		return fmt.Errorf("bind-Pod-AddBondToContainer: %d slave links for %d slaves", len(slaveLinks), len(conf.Slaves))
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	vmNs, err := self.NameSpace.Get()
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-AddBondToContainer:netns.Get error", err)
+	}
+	defer vmNs.Close()
+
+	slaveNames := make([]string, 0, len(slaveLinks))
+	for _, slaveLink := range slaveLinks {
+		slaveNames = append(slaveNames, slaveLink.Attrs().Name)
+		if !container.BindLink(slaveLink) {
+			return fmt.Errorf("bind-Pod-AddBondToContainer: move slave[%s] to container error", slaveLink.Attrs().Name)
+		}
+	}
+
+	containerNs, err := container.NetNs.GetFromPid(container.Pid)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-AddBondToContainer:netns.GetFromPid error", err)
+	}
+	defer containerNs.Close()
+	err = self.NameSpace.Set(containerNs)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-AddBondToContainer:netns.Set error", err)
+	}
+	defer self.NameSpace.Set(vmNs)
+
+	bondLink, err := self.addBond(conf, port)
+	if err != nil {
+		return err
+	}
+	for i, slaveName := range slaveNames {
+		err = self.enslave(bondLink, slaveName, conf.Slaves[i].Name)
+		if err != nil {
+			return err
+		}
+	}
+	if !bondLink.SetUp() {
+		return errors.New("bind-Pod-AddBondToContainer:netlink.LinkSetUp error")
+	}
+	addr := port.MakeAddr()
+	if !bondLink.SetAddr(&addr) {
+		return errors.New("bind-Pod-AddBondToContainer:setAddr error")
+	}
+
+	dstIPNet := port.GetIPNet()
+	if dstIPNet == nil {
+		return errors.New("bind-Pod-AddBondToContainer:net.ParseCIDR error")
+	}
+	err = RouteAddFunc(CreateRoute(bondLink.Attrs().Index, dstIPNet, port.GetGatewayIP()))
+	if err != nil {
+		klog.Errorf("bind-Pod-AddBondToContainer:netlink.RouteAdd error!-%v", err)
+	}
+	klog.Infof("bind-Pod-AddBondToContainer: add bond[%s] mode[%s] with slaves%v and addr[%v] SUCC",
+		conf.Name, conf.Mode, slaveNames, addr.IPNet)
+	return nil
+}
+
+func (self *Pod) addBond(conf *BondConf, port *manager.Port) (*Link, error) {
+	mode := netlink.StringToBondMode(conf.Mode)
+	if mode == netlink.BOND_MODE_UNKNOWN {
+		return nil, fmt.Errorf("bind-Pod-addBond: unknown bond mode[%s]", conf.Mode)
+	}
+	bond := netlink.NewLinkBond(netlink.LinkAttrs{Name: conf.Name})
+	bond.Mode = mode
+	bond.Miimon = conf.Miimon
+	err := self.NetLink.LinkAdd(bond)
+	if err != nil {
+		klog.Errorf("bind-Pod-addBond: netlink.LinkAdd(bond:%s) error: %v", conf.Name, err)
+		return nil, fmt.Errorf("%v:bind-Pod-addBond:netlink.LinkAdd error", err)
+	}
+	link, err := self.NetLink.LinkByName(conf.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%v:bind-Pod-addBond:netlink.LinkByName error", err)
+	}
+	bondLink := &Link{Link: link, Netlink: self.NetLink}
+	if !bondLink.SetMac(port.MACAddress) {
+		return nil, errors.New("bind-Pod-addBond:netlink.SetMac error")
+	}
+	if !bondLink.SetMtu(port.MTU) {
+		return nil, errors.New("bind-Pod-addBond:netlink.LinkSetMTU error")
+	}
+	return bondLink, nil
+}
+
+// enslave renames the slave and adds it to the bond, slaves must be down
+// before they are enslaved.
+func (self *Pod) enslave(bondLink *Link, slaveName, newName string) error {
+	link, err := self.NetLink.LinkByName(slaveName)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-enslave:netlink.LinkByName(%s) error", err, slaveName)
+	}
+	err = self.NetLink.LinkSetDown(link)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-enslave:netlink.LinkSetDown(%s) error", err, slaveName)
+	}
+	err = self.NetLink.LinkSetName(link, newName)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-enslave:netlink.LinkSetName(%s) error", err, newName)
+	}
+	err = self.NetLink.LinkSetMasterByIndex(link, bondLink.Attrs().Index)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-enslave:netlink.LinkSetMasterByIndex(%s) error", err, newName)
+	}
+	err = self.NetLink.LinkSetUp(link)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-enslave:netlink.LinkSetUp(%s) error", err, newName)
+	}
+	return nil
+}
+
+// DeleteBondInContainer deletes the bond, the VF slaves are renamed back and
+// moved to host, the veth slaves are deleted with their peers in host. It goes
+// on with the other slaves when one fails, the last error is returned.
+func (self *Pod) DeleteBondInContainer(container *Container, conf *BondConf) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	vmNs, err := self.NameSpace.Get()
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-DeleteBondInContainer:netns.Get error", err)
+	}
+	defer vmNs.Close()
+	containerNs, err := container.NetNs.GetFromPid(container.Pid)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-DeleteBondInContainer:netns.GetFromPid error", err)
+	}
+	defer containerNs.Close()
+	err = self.NameSpace.Set(containerNs)
+	if err != nil {
+		return fmt.Errorf("%v:bind-Pod-DeleteBondInContainer:netns.Set error", err)
+	}
+	defer self.NameSpace.Set(vmNs)
+
+	var lastErr error
+	bond, err := self.NetLink.LinkByName(conf.Name)
+	if err == nil {
+		err = self.NetLink.LinkDel(bond)
+	}
+	if err != nil {
+		klog.Warningf("bind-Pod-DeleteBondInContainer: delete bond[%s] error: %v", conf.Name, err)
+		lastErr = err
+	}
+	for _, slave := range conf.Slaves {
+		link, err := self.NetLink.LinkByName(slave.Name)
+		if err != nil && slave.IsVf {
+			// the VF keeps its host name if it is not enslaved yet
+			link, err = self.NetLink.LinkByName(slave.HostName)
+		}
+		if err != nil {
+			klog.Warningf("bind-Pod-DeleteBondInContainer: slave[%s] not found: %v", slave.Name, err)
+			continue
+		}
+		if !slave.IsVf {
+			err = self.NetLink.LinkDel(link)
+		} else {
+			err = self.moveVfToHost(link, slave.HostName, int(vmNs))
+		}
+		if err != nil {
+			klog.Warningf("bind-Pod-DeleteBondInContainer: release slave[%s] error: %v", slave.Name, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (self *Pod) moveVfToHost(link netlink.Link, hostName string, hostNsFd int) error {
+	err := self.NetLink.LinkSetDown(link)
+	if err != nil {
+		return err
+	}
+	err = self.NetLink.LinkSetName(link, hostName)
+	if err != nil {
+		return err
+	}
+	return self.NetLink.LinkSetNsFd(link, hostNsFd)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bind
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/manager"
+	"github.com/ZTE/Knitter/knitter-agent/infra/util/base"
+)
+
+type fakeNameSpace struct {
+	inPod bool
+}
+
+func (self *fakeNameSpace) Set(ns netns.NsHandle) error {
+	self.inPod = ns == fakePodNs
+	return nil
+}
+
+func (self *fakeNameSpace) Get() (netns.NsHandle, error) {
+	return fakeHostNs, nil
+}
+
+func (self *fakeNameSpace) Close() {
+}
+
+type fakeNetNs struct {
+}
+
+func (self *fakeNetNs) GetFromPid(pid int) (netns.NsHandle, error) {
+	return fakePodNs, nil
+}
+
+// the handles are closed by the code under test, they must not be real fds
+const (
+	fakeHostNs netns.NsHandle = 100000
+	fakePodNs  netns.NsHandle = 100001
+)
+
+type fakeLink struct {
+	netlink.Link
+	inPod  bool
+	up     bool
+	master int
+}
+
+// fakeBondNetLink keeps the links of host and pod in one map, links are
+// looked up only in the netns the pod is in.
+type fakeBondNetLink struct {
+	base.NetLink
+	ns    *fakeNameSpace
+	links map[string]*fakeLink
+	index int
+}
+
+func newFakeBondNetLink(ns *fakeNameSpace, names ...string) *fakeBondNetLink {
+	self := &fakeBondNetLink{ns: ns, links: make(map[string]*fakeLink)}
+	for _, name := range names {
+		self.add(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name}}, false)
+	}
+	return self
+}
+
+func (self *fakeBondNetLink) add(link netlink.Link, inPod bool) {
+	self.index++
+	link.Attrs().Index = self.index
+	self.links[link.Attrs().Name] = &fakeLink{Link: link, inPod: inPod}
+}
+
+func (self *fakeBondNetLink) get(link netlink.Link) *fakeLink {
+	return self.links[link.Attrs().Name]
+}
+
+func (self *fakeBondNetLink) LinkByName(name string) (netlink.Link, error) {
+	link, ok := self.links[name]
+	if !ok || link.inPod != self.ns.inPod {
+		return nil, errors.New("Link not found")
+	}
+	return link.Link, nil
+}
+
+func (self *fakeBondNetLink) LinkAdd(link netlink.Link) error {
+	self.add(link, self.ns.inPod)
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkDel(link netlink.Link) error {
+	delete(self.links, link.Attrs().Name)
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetNsPid(link netlink.Link, pid int) error {
+	self.get(link).inPod = true
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetNsFd(link netlink.Link, fd int) error {
+	self.get(link).inPod = false
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetName(link netlink.Link, name string) error {
+	fl := self.get(link)
+	delete(self.links, link.Attrs().Name)
+	link.Attrs().Name = name
+	self.links[name] = fl
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetUp(link netlink.Link) error {
+	self.get(link).up = true
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetDown(link netlink.Link) error {
+	self.get(link).up = false
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetMasterByIndex(link netlink.Link, index int) error {
+	self.get(link).master = index
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetMac(link netlink.Link, mac net.HardwareAddr) error {
+	link.Attrs().HardwareAddr = mac
+	return nil
+}
+
+func (self *fakeBondNetLink) LinkSetMTU(link netlink.Link, mtu int) error {
+	link.Attrs().MTU = mtu
+	return nil
+}
+
+func (self *fakeBondNetLink) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	return nil
+}
+
+func TestPod_AddBondToContainer(t *testing.T) {
+	ns := &fakeNameSpace{}
+	nl := newFakeBondNetLink(ns, "vf1", "vf2")
+	pod := NewTempPod()
+	pod.SetNameSpace(ns)
+	pod.SetNetLink(nl)
+	container := NewContainer(1)
+	container.SetNetLink(nl)
+	container.SetNetNs(&fakeNetNs{})
+	RouteAddFunc = func(route *netlink.Route) error { return nil }
+	defer func() { RouteAddFunc = nil }()
+
+	port := &manager.Port{Name: "eth1", MACAddress: "fa:16:3e:00:00:01", MTU: "1500", CIDR: "10.0.0.0/24",
+		GatewayIP: "10.0.0.1", FixedIPs: []manager.IP{{Address: "10.0.0.5"}}}
+	conf := &BondConf{Name: "eth1", Mode: "802.3ad", Miimon: 100, Slaves: []BondSlave{
+		{Name: "eth1s0", HostName: "vf1", IsVf: true},
+		{Name: "eth1s1", HostName: "vf2", IsVf: true}}}
+	slaveLinks := []*Link{
+		{Link: nl.links["vf1"].Link, Netlink: nl},
+		{Link: nl.links["vf2"].Link, Netlink: nl}}
+
+	Convey("TestPod_AddBondToContainer", t, func() {
+		So(pod.AddBondToContainer(container, slaveLinks, port, conf), ShouldBeNil)
+		So(ns.inPod, ShouldBeFalse)
+		bond := nl.links["eth1"]
+		So(bond.inPod && bond.up, ShouldBeTrue)
+		So(bond.Link.(*netlink.Bond).Mode, ShouldEqual, netlink.BOND_MODE_802_3AD)
+		So(bond.Link.(*netlink.Bond).Miimon, ShouldEqual, 100)
+		So(bond.Attrs().HardwareAddr.String(), ShouldEqual, "fa:16:3e:00:00:01")
+		for _, name := range []string{"eth1s0", "eth1s1"} {
+			So(nl.links[name].inPod && nl.links[name].up, ShouldBeTrue)
+			So(nl.links[name].master, ShouldEqual, bond.Attrs().Index)
+		}
+
+		Convey("delete bond and move vfs back to host\n", func() {
+			So(pod.DeleteBondInContainer(container, conf), ShouldBeNil)
+			So(ns.inPod, ShouldBeFalse)
+			So(nl.links, ShouldNotContainKey, "eth1")
+			So(nl.links["vf1"].inPod || nl.links["vf2"].inPod, ShouldBeFalse)
+		})
+	})
+
+	Convey("TestPod_AddBondToContainer of veths", t, func() {
+		nl := newFakeBondNetLink(ns, "veth1", "veth2")
+		pod.SetNetLink(nl)
+		container.SetNetLink(nl)
+		slaveLinks := []*Link{
+			{Link: nl.links["veth1"].Link, Netlink: nl},
+			{Link: nl.links["veth2"].Link, Netlink: nl}}
+		conf := &BondConf{Name: "eth1", Mode: "active-backup", Slaves: []BondSlave{
+			{Name: "eth1s0", HostName: "vethb1"},
+			{Name: "eth1s1", HostName: "vethb2"}}}
+		So(pod.AddBondToContainer(container, slaveLinks, port, conf), ShouldBeNil)
+		So(nl.links["eth1"].Link.(*netlink.Bond).Mode, ShouldEqual, netlink.BOND_MODE_ACTIVE_BACKUP)
+
+		Convey("veth slaves are deleted\n", func() {
+			So(pod.DeleteBondInContainer(container, conf), ShouldBeNil)
+			So(nl.links, ShouldBeEmpty)
+		})
+	})
+
+	Convey("TestPod_AddBondToContainer with unknown mode", t, func() {
+		nl := newFakeBondNetLink(ns, "vf1", "vf2")
+		pod.SetNetLink(nl)
+		container.SetNetLink(nl)
+		slaveLinks := []*Link{
+			{Link: nl.links["vf1"].Link, Netlink: nl},
+			{Link: nl.links["vf2"].Link, Netlink: nl}}
+		conf := &BondConf{Name: "eth1", Mode: "balance-rr-x", Slaves: []BondSlave{
+			{Name: "eth1s0", HostName: "vf1", IsVf: true},
+			{Name: "eth1s1", HostName: "vf2", IsVf: true}}}
+		So(pod.AddBondToContainer(container, slaveLinks, port, conf), ShouldNotBeNil)
+		So(ns.inPod, ShouldBeFalse)
+		So(nl.links["vf1"].inPod, ShouldBeTrue)
+
+		Convey("vfs not enslaved are moved back to host\n", func() {
+			So(pod.DeleteBondInContainer(container, conf), ShouldNotBeNil)
+			So(nl.links["vf1"].inPod || nl.links["vf2"].inPod, ShouldBeFalse)
+		})
+	})
+}
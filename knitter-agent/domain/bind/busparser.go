@@ -28,7 +28,9 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/adapter"
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
 	"github.com/ZTE/Knitter/knitter-agent/domain/manager"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/port-role"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
 	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/antonholmquist/jason"
 	"net"
@@ -55,6 +57,7 @@ func BuildNormalNic(paasPort *manager.Port, netFunc string) (*Dpdknic, error) {
 		Mac:        paasPort.MACAddress,
 		Function:   netFunc,
 		Accelerate: "false",
+		MTU:        paasPort.MTU,
 	}
 	return normalNic, nil
 }
@@ -79,7 +82,15 @@ func DestroyResidualBrintIntfcs() {
 	}
 }
 
-var getAllBrintIntfcs = func(bridge string) ([]string, error) {
+// listOvsPorts lists the ports of the bridge except its local port.
+func listOvsPorts(bridge string) ([]string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		ports, err := client.ListPorts(bridge)
+		if err != nil {
+			klog.Errorf("listOvsPorts: list ports of %s error: %v", bridge, err)
+		}
+		return ports, err
+	}
 	ovsctl, _ := exec.LookPath("ovs-vsctl")
 	ovsctlArgs := []string{"list-ports", bridge}
 	ovsctlOutput, err := exec.Command(ovsctl, ovsctlArgs...).CombinedOutput()
@@ -88,8 +99,14 @@ var getAllBrintIntfcs = func(bridge string) ([]string, error) {
 			string(ovsctlOutput))
 		return nil, err
 	}
+	return strings.Fields(string(ovsctlOutput)), nil
+}
 
-	ovsPorts := strings.Fields(string(ovsctlOutput))
+var getAllBrintIntfcs = func(bridge string) ([]string, error) {
+	ovsPorts, err := listOvsPorts(bridge)
+	if err != nil {
+		return nil, err
+	}
 	var ports []string
 	for _, port := range ovsPorts {
 		if strings.HasPrefix(port, "veth") {
@@ -100,6 +117,11 @@ var getAllBrintIntfcs = func(bridge string) ([]string, error) {
 }
 
 var getAllBrNames = func() ([]string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		brNames := client.ListBridges()
+		klog.Infof("getAllBrNames: get all bridges result: %v", brNames)
+		return brNames, nil
+	}
 	ovsctlArgs := []string{"list-br"}
 	ovsctlOutput, err := osencap.Exec(constvalue.OvsVsctl, ovsctlArgs...)
 	if err != nil {
@@ -172,6 +194,16 @@ var ListNousedOvsBrInterfaces = func(ports []string) []string {
 }
 
 func isInterfaceDetached(ifName string) bool {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		iface, ok := client.GetInterface(ifName)
+		if !ok {
+			klog.Errorf("isInterfaceDetached: interface %s not found", ifName)
+			return false
+		}
+		detached := strings.Contains(iface.String(constvalue.OvsIfErrorField), constvalue.OvsIfErrorNoDev)
+		klog.Tracef("isInterfaceDetached: found %s detached: %v", ifName, detached)
+		return detached
+	}
 	args := []string{"list", "interface", ifName}
 	output, err := osencap.Exec(constvalue.OvsVsctl, args...)
 	if err != nil {
@@ -211,6 +243,13 @@ var DelOvsBrInterfaces = func(brName string, ifs []string) {
 var GetEthtoolOutputFunc func(ethName string) ([]byte, error)
 
 func AddVethToOvsWithVlanID(ovsBridge, vethPort, vlanID string) error {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		portColumns, err := ovsdb.ColumnsFromArgs("tag=" + vlanID)
+		if err != nil {
+			return err
+		}
+		return client.AddPort(ovsBridge, vethPort, false, portColumns, nil)
+	}
 	_, err := osencap.Exec("ovs-vsctl", "add-port", ovsBridge, vethPort, "tag="+vlanID)
 	return err
 }
@@ -247,11 +286,17 @@ func DeactivateVethPort(vethPort string) error {
 }
 
 func DelVethFromOvs(ovsBridge, vethPort string) error {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		return client.DelPort(ovsBridge, vethPort, false)
+	}
 	_, err := osencap.Exec("ovs-vsctl", "del-port", ovsBridge, vethPort)
 	return err
 }
 
 func GetOVSList() (string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		return strings.Join(client.ListBridges(), "\n"), nil
+	}
 	OVSList, err := osencap.Exec("ovs-vsctl", "list-br")
 	return OVSList, err
 }
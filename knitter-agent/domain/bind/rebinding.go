@@ -39,6 +39,9 @@ type Dpdknic struct {
 	Accelerate string      `json:"accelerate"`
 	BusInfos   []string    `json:"bus_infos"`
 	BondMode   string      `json:"bond_type"`
+	SocketPath string      `json:"socket_path,omitempty"`
+	SocketName string      `json:"socket_name,omitempty"`
+	MTU        string      `json:"mtu,omitempty"`
 	Metadata   interface{} `json:"metadata"`
 }
 
@@ -0,0 +1,185 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bond
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/device-plugin"
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/sriov"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+var ErrSamePfOfSlaves = errors.New("physnets of bond slaves are on the same pf")
+
+// Bond records the bond made in pod for a port and the VFs or veths enslaved
+// by it, the record is kept in local db until the port is detached.
+type Bond struct {
+	PortID      string `json:"port_id"`
+	ContainerID string `json:"container_id"`
+	bind.BondConf
+}
+
+func NewBond(portID, containerID, name string, portBond *monitor.PortBond) *Bond {
+	return &Bond{
+		PortID:      portID,
+		ContainerID: containerID,
+		BondConf: bind.BondConf{
+			Name:   name,
+			Mode:   portBond.Mode,
+			Miimon: portBond.Miimon,
+			Slaves: make([]bind.BondSlave, 0),
+		},
+	}
+}
+
+func (self *Bond) IsVfBond() bool {
+	return len(self.Slaves) > 0 && self.Slaves[0].IsVf
+}
+
+// AddVethSlave records the veth pair enslaved by the bond by the name of its
+// bridge side.
+func (self *Bond) AddVethSlave(vethNameOfBridge string) {
+	self.Slaves = append(self.Slaves, bind.BondSlave{
+		Name:     bind.GetBondSlaveName(self.Name, len(self.Slaves)),
+		HostName: vethNameOfBridge,
+	})
+}
+
+// GetBusInfos returns the PCI addresses of the VF slaves.
+func (self *Bond) GetBusInfos() []string {
+	busInfos := make([]string, 0)
+	for _, slave := range self.Slaves {
+		if slave.IsVf {
+			busInfos = append(busInfos, slave.PciAddr)
+		}
+	}
+	return busInfos
+}
+
+// GetSlaveKeyOfPortTable keys the veth slaves of the bond in the port table
+// of br-int, so that they are not taken as residual interfaces.
+func GetSlaveKeyOfPortTable(portID string, idx int) string {
+	return portID + "/" + strconv.Itoa(idx)
+}
+
+var allocVfForPod = deviceplugin.AllocVfForPod
+
+// AllocVfSlaves takes one VF of each physnet for the port, the VFs get the MAC
+// of the port and the VLAN of the network before they are moved into pod. The
+// VFs taken are released if any of them fails.
+func (self *Bond) AllocVfSlaves(podNs, podName string, physnets []string, mac string, vlan int) ([]*bind.Link, error) {
+	vfMgr := sriov.GetVfManagerSingleton()
+	links := make([]*bind.Link, 0)
+	slaves := make([]bind.BondSlave, 0)
+	pfs := make([]string, 0)
+	for i, physnet := range physnets {
+		vf, err := allocVfForPod(podNs, podName, physnet, self.PortID, self.ContainerID)
+		if err != nil {
+			klog.Errorf("Bond.AllocVfSlaves: alloc vf of physnet[%s] for port[%s] error: %v", physnet, self.PortID, err)
+			self.ReleaseVfSlaves()
+			return nil, err
+		}
+		for _, pf := range pfs {
+			if pf == vf.Pf {
+				klog.Errorf("Bond.AllocVfSlaves: physnets%v of port[%s] are on the same pf[%s]", physnets, self.PortID, vf.Pf)
+				self.ReleaseVfSlaves()
+				return nil, ErrSamePfOfSlaves
+			}
+		}
+		conf := &sriov.VfConfig{MacAddress: mac, Vlan: vlan, SpoofChk: true, LinkState: sriov.VfLinkStateAuto}
+		err = vfMgr.ConfigVf(vf, conf)
+		if err != nil {
+			self.ReleaseVfSlaves()
+			return nil, err
+		}
+		link, err := vfMgr.GetVfLink(vf)
+		if err != nil {
+			self.ReleaseVfSlaves()
+			return nil, err
+		}
+		links = append(links, link)
+		pfs = append(pfs, vf.Pf)
+		slaves = append(slaves, bind.BondSlave{
+			Name:     bind.GetBondSlaveName(self.Name, i),
+			HostName: vf.Name,
+			IsVf:     true,
+			Physnet:  physnet,
+			PciAddr:  vf.PciAddr,
+		})
+	}
+	self.Slaves = slaves
+	return links, nil
+}
+
+// ReleaseVfSlaves gives the VFs taken by the port back to the VF manager, the
+// VFs should have been moved back to host.
+func (self *Bond) ReleaseVfSlaves() error {
+	vfMgr := sriov.GetVfManagerSingleton()
+	vfs, err := vfMgr.GetVfsOfPort(self.PortID)
+	if err != nil {
+		klog.Errorf("Bond.ReleaseVfSlaves: get vfs of port[%s] error: %v", self.PortID, err)
+		return err
+	}
+	var lastErr error
+	for _, vf := range vfs {
+		err = vfMgr.ReleaseVf(vf)
+		if err != nil {
+			klog.Errorf("Bond.ReleaseVfSlaves: release vf[%s] of port[%s] error: %v", vf.PciAddr, self.PortID, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+var GetBond = func(db dbaccessor.DbAccessor, portID string) (*Bond, error) {
+	value, err := db.ReadLeaf(dbaccessor.GetKeyOfBond(portID))
+	if err != nil {
+		return nil, err
+	}
+	bond := &Bond{}
+	err = json.Unmarshal([]byte(value), bond)
+	if err != nil {
+		klog.Errorf("GetBond: json.Unmarshal(%s) error: %v", value, err)
+		return nil, err
+	}
+	return bond, nil
+}
+
+func SaveBond(db dbaccessor.DbAccessor, bond *Bond) error {
+	value, _ := json.Marshal(bond)
+	err := db.SaveLeaf(dbaccessor.GetKeyOfBond(bond.PortID), string(value))
+	if err != nil {
+		klog.Errorf("SaveBond: save bond of port[%s] error: %v", bond.PortID, err)
+	}
+	return err
+}
+
+func DeleteBond(db dbaccessor.DbAccessor, portID string) error {
+	err := db.DeleteLeaf(dbaccessor.GetKeyOfBond(portID))
+	if err != nil {
+		klog.Warningf("DeleteBond: delete bond of port[%s] error: %v", portID, err)
+	}
+	return err
+}
+
+func IsBondPort(db dbaccessor.DbAccessor, portID string) bool {
+	_, err := GetBond(db, portID)
+	return err == nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bond
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/sriov"
+	"github.com/ZTE/Knitter/pkg/leveldb"
+)
+
+func TestBondRecord(t *testing.T) {
+	Convey("TestBondRecord", t, func() {
+		root, _ := ioutil.TempDir("", "bond")
+		defer os.RemoveAll(root)
+		db, _ := leveldb.NewLevelDBClient(filepath.Join(root, "db"))
+
+		b := NewBond("port1", "container1", "eth1", &monitor.PortBond{Mode: "active-backup", Miimon: 100})
+		b.AddVethSlave("vethb1")
+		b.AddVethSlave("vethb2")
+		So(b.IsVfBond(), ShouldBeFalse)
+		So(b.Slaves[1], ShouldResemble, bind.BondSlave{Name: "eth1s1", HostName: "vethb2"})
+		So(b.GetBusInfos(), ShouldBeEmpty)
+
+		So(IsBondPort(db, "port1"), ShouldBeFalse)
+		So(SaveBond(db, b), ShouldBeNil)
+		So(IsBondPort(db, "port1"), ShouldBeTrue)
+		saved, err := GetBond(db, "port1")
+		So(err, ShouldBeNil)
+		So(saved, ShouldResemble, b)
+		So(DeleteBond(db, "port1"), ShouldBeNil)
+		So(IsBondPort(db, "port1"), ShouldBeFalse)
+	})
+}
+
+func TestBond_AllocVfSlaves(t *testing.T) {
+	vfMgr := &sriov.VfManager{}
+	stubs := gostub.StubFunc(&sriov.GetVfManagerSingleton, vfMgr)
+	defer stubs.Reset()
+	vfs := map[string]*sriov.Vf{
+		"physnet1": {Pf: "enp4s0f0", Index: 1, PciAddr: "0000:03:10.1", Name: "enp4s0f0v1"},
+		"physnet2": {Pf: "enp5s0f0", Index: 2, PciAddr: "0000:04:10.2", Name: "enp5s0f0v2"},
+		"physnet3": {Pf: "enp4s0f0", Index: 3, PciAddr: "0000:03:10.3", Name: "enp4s0f0v3"},
+	}
+	allocated := make([]*sriov.Vf, 0)
+	stubs.Stub(&allocVfForPod, func(podNs, podName, physnet, portID, containerID string) (*sriov.Vf, error) {
+		allocated = append(allocated, vfs[physnet])
+		return vfs[physnet], nil
+	})
+	confs := make(map[string]sriov.VfConfig)
+	monkey.PatchInstanceMethod(reflect.TypeOf(vfMgr), "ConfigVf",
+		func(_ *sriov.VfManager, vf *sriov.Vf, conf *sriov.VfConfig) error {
+			confs[vf.Name] = *conf
+			return nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(vfMgr), "GetVfLink",
+		func(_ *sriov.VfManager, vf *sriov.Vf) (*bind.Link, error) {
+			return &bind.Link{IsVf: true, Pf: vf.Pf}, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(vfMgr), "GetVfsOfPort",
+		func(_ *sriov.VfManager, portID string) ([]*sriov.Vf, error) {
+			return allocated, nil
+		})
+	released := make([]*sriov.Vf, 0)
+	monkey.PatchInstanceMethod(reflect.TypeOf(vfMgr), "ReleaseVf",
+		func(_ *sriov.VfManager, vf *sriov.Vf) error {
+			released = append(released, vf)
+			return nil
+		})
+	defer monkey.UnpatchAll()
+
+	Convey("TestBond_AllocVfSlaves", t, func() {
+		allocated = allocated[:0]
+		released = released[:0]
+		b := NewBond("port1", "container1", "eth1", &monitor.PortBond{Mode: "802.3ad", Miimon: 100})
+
+		Convey("one vf of each physnet\n", func() {
+			links, err := b.AllocVfSlaves("ns", "pod", []string{"physnet1", "physnet2"}, "fa:16:3e:00:00:01", 100)
+			So(err, ShouldBeNil)
+			So(len(links), ShouldEqual, 2)
+			So(b.IsVfBond(), ShouldBeTrue)
+			So(b.Slaves[1], ShouldResemble, bind.BondSlave{Name: "eth1s1", HostName: "enp5s0f0v2", IsVf: true,
+				Physnet: "physnet2", PciAddr: "0000:04:10.2"})
+			So(b.GetBusInfos(), ShouldResemble, []string{"0000:03:10.1", "0000:04:10.2"})
+			So(confs["enp4s0f0v1"], ShouldResemble, sriov.VfConfig{MacAddress: "fa:16:3e:00:00:01", Vlan: 100,
+				SpoofChk: true, LinkState: sriov.VfLinkStateAuto})
+
+			So(b.ReleaseVfSlaves(), ShouldBeNil)
+			So(released, ShouldResemble, allocated)
+		})
+
+		Convey("physnets on the same pf\n", func() {
+			_, err := b.AllocVfSlaves("ns", "pod", []string{"physnet1", "physnet3"}, "fa:16:3e:00:00:01", 0)
+			So(err, ShouldEqual, ErrSamePfOfSlaves)
+			So(released, ShouldResemble, allocated)
+		})
+	})
+}
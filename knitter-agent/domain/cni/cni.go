@@ -132,7 +132,38 @@ func (self *CniParam) setPodNameBy(cniparam string) error {
 	return nil
 }
 
+// setTenantID sets the tenant of the pod attached to the node, the tenant of
+// a new pod is set by its record in knitter-monitor when it is attached.
 func (self *CniParam) setTenantID() error {
-	self.TenantID = self.PodNs
+	self.TenantID = GetPodTenantID(self.PodNs, self.PodName)
 	return nil
 }
+
+// GetPodTenantID returns the tenant of the pod attached to the node, which is
+// in the key of the pod recorded for the node. The namespace is returned if
+// the pod is not attached, as the tenant of a namespace is itself by default.
+var GetPodTenantID = func(podNs, podName string) string {
+	agtCtx := GetGlobalContext()
+	if agtCtx.DB == nil {
+		return podNs
+	}
+	key := dbaccessor.GetKeyOfPodForNode(agtCtx.ClusterID, agtCtx.HostIP, podNs, podName)
+	keyOfPodSelf, err := agtCtx.DB.ReadLeaf(key)
+	if err != nil {
+		return podNs
+	}
+	tenantID := getTenantIDOfKey(keyOfPodSelf)
+	if tenantID == "" {
+		klog.Warningf("GetPodTenantID: no tenant in key[%v] of pod[%v/%v]", keyOfPodSelf, podNs, podName)
+		return podNs
+	}
+	return tenantID
+}
+
+func getTenantIDOfKey(key string) string {
+	prefix := dbaccessor.GetKeyOfTenants() + "/"
+	if !strings.HasPrefix(key, prefix) {
+		return ""
+	}
+	return strings.Split(strings.TrimPrefix(key, prefix), "/")[0]
+}
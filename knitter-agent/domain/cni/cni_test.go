@@ -1,10 +1,17 @@
 package cni
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/antonholmquist/jason"
 	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/golang/gostub"
+	"github.com/golang/mock/gomock"
 	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/mock/mock-pkg/mock-dbaccessor"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
 )
 
 func TestInit(t *testing.T) {
@@ -48,3 +55,67 @@ func TestAnalyzeCniParam(t *testing.T) {
 		})
 	})
 }
+
+func TestGetPodTenantID(t *testing.T) {
+	Convey("TestGetPodTenantID\n", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		agtCtx := &AgentContext{DB: mockDB, ClusterID: "cluster", HostIP: "192.168.1.1"}
+		stubs := gostub.StubFunc(&GetGlobalContext, agtCtx)
+		defer stubs.Reset()
+		key := dbaccessor.GetKeyOfPodForNode("cluster", "192.168.1.1", "ns1", "pod1")
+
+		Convey("tenant of the attached pod differs from its namespace\n", func() {
+			mockDB.EXPECT().ReadLeaf(key).Return(dbaccessor.GetKeyOfPodSelf("tenant1", "ns1", "pod1"), nil)
+			So(GetPodTenantID("ns1", "pod1"), ShouldEqual, "tenant1")
+
+			mockDB.EXPECT().ReadLeaf(key).Return(dbaccessor.GetKeyOfPodSelf("tenant1", "ns1", "pod1"), nil)
+			cniParam := &CniParam{PodNs: "ns1", PodName: "pod1"}
+			So(cniParam.setTenantID(), ShouldBeNil)
+			So(cniParam.TenantID, ShouldEqual, "tenant1")
+		})
+
+		Convey("namespace is the tenant of the pod not attached\n", func() {
+			mockDB.EXPECT().ReadLeaf(key).Return("", errors.New("key not found"))
+			So(GetPodTenantID("ns1", "pod1"), ShouldEqual, "ns1")
+			mockDB.EXPECT().ReadLeaf(key).Return("/illegal/key", nil)
+			So(GetPodTenantID("ns1", "pod1"), ShouldEqual, "ns1")
+		})
+	})
+}
+
+func TestSetSriovConf(t *testing.T) {
+	Convey("TestSetSriovConf\n", t, func() {
+		cfg, _ := jason.NewObjectFromBytes([]byte(`{"sriov": {"physnet_pf_map": {"physnet1": "enp4s0f0"},
+			"default_max_vfs": 4, "vf_num": {"enp4s0f0": 16, "enp5s0f0": -1},
+			"vf_ranges": {"enp4s0f0": "0-7", "enp5s0f0": "7-0"}, "device_plugin": true}}`))
+		agtCtx := &AgentContext{}
+		agtCtx.SetSriovConf(cfg)
+		So(*agtCtx.PhysnetPfMap, ShouldResemble, map[string]string{"physnet1": "enp4s0f0"})
+		So(agtCtx.DefaultMaxVfs, ShouldEqual, 4)
+		So(*agtCtx.VfNumMap, ShouldResemble, map[string]int{"enp4s0f0": 16})
+		So(*agtCtx.VfRanges, ShouldResemble, map[string]Range{"enp4s0f0": {Start: 0, End: 7}})
+		So(agtCtx.VfRangeConfigured, ShouldBeTrue)
+		So(agtCtx.DevicePluginOfVfs, ShouldBeTrue)
+
+		cfg, _ = jason.NewObjectFromBytes([]byte(`{}`))
+		agtCtx.SetSriovConf(cfg)
+		So(*agtCtx.PhysnetPfMap, ShouldBeEmpty)
+		So(agtCtx.DefaultMaxVfs, ShouldEqual, DefaultMaxVfs)
+		So(agtCtx.VfRangeConfigured, ShouldBeFalse)
+		So(agtCtx.DevicePluginOfVfs, ShouldBeFalse)
+	})
+}
+
+func TestParseVfRange(t *testing.T) {
+	Convey("TestParseVfRange\n", t, func() {
+		vfRange, err := ParseVfRange("2-5")
+		So(err, ShouldBeNil)
+		So(vfRange, ShouldResemble, Range{Start: 2, End: 5})
+		for _, rangeStr := range []string{"", "5", "a-5", "2-b", "5-2", "-1-2"} {
+			_, err = ParseVfRange(rangeStr)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -30,18 +30,25 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/infra"
 	"github.com/ZTE/Knitter/knitter-agent/infra/k8s"
 	"github.com/ZTE/Knitter/pkg/leveldb"
+	"strconv"
 	"time"
 )
 
 const DefaultSyncVnisIntvalInMin = 60
 
+const DefaultMaxVfs = 8
+
 type Range struct {
 	Start int
 	End   int
 }
 
 type AgentContext struct {
+	// Mtu is the configured MTU of the pod interfaces, empty to derive it
+	// from the underlay by GetPodMtu
 	Mtu                string
+	InternalIP         string
+	Tunnel             dbaccessor.Tunnel
 	VMID               string
 	ClusterID          string
 	oseToken           string
@@ -54,6 +61,8 @@ type AgentContext struct {
 	VfNumMap           *map[string]int
 	VfRanges           *map[string]Range
 	VfRangeConfigured  bool
+	DevicePluginOfVfs  bool
+	NetworkPolicy      bool
 	ExternalIP         string
 	SendVdp            bool
 	ClusterType        string
@@ -150,6 +159,7 @@ func InitConfigration4Agent(cfg *jason.Object) error {
 
 	ctx.SetRunMode(cfg)
 	ctx.SetSyncSwitch(cfg)
+	ctx.NetworkPolicy, _ = cfg.GetBoolean("network_policy", "enable")
 
 	err = ctx.SetVMID(cfg)
 	if err != nil {
@@ -169,14 +179,19 @@ func InitConfigration4Agent(cfg *jason.Object) error {
 		return fmt.Errorf("%v:InitEnv4Agent:setHostType  error", err)
 	}
 
-	mtu, err := cfg.GetString("host", "mtu")
+	ctx.Mtu, err = cfg.GetString("host", "mtu")
 	if err != nil {
-		klog.Warningf("InitEnv4Agent: get mtu FAILED, error: %v, use default 1500 byte", err)
-		mtu = constvalue.DefaultMtu
+		klog.Infof("InitEnv4Agent: no mtu configured, derive it from the underlay")
 	}
-	ctx.Mtu = mtu
 	klog.Infof("InitEnv4Agent:get MTU = :", ctx.Mtu)
 
+	ctx.InternalIP, _ = cfg.GetString("internal", "ip")
+	err = ctx.SetTunnel(cfg)
+	if err != nil {
+		klog.Errorf("InitEnv4Agent:SetTunnel error!-%v", err)
+		return fmt.Errorf("%v:InitEnv4Agent:SetTunnel error", err)
+	}
+
 	ctx.SendVdp, err = cfg.GetBoolean("sdn_proxy", "send_vdp")
 	if err != nil {
 		klog.Errorf("InitEnv4Agent:get send_vdp error: %v, set to default true", err)
@@ -209,6 +224,8 @@ func InitConfigration4Agent(cfg *jason.Object) error {
 		klog.Errorf("InitEnv4Agent:SetExternalIp error!-%v", err)
 		return fmt.Errorf("%v:InitEnv4Agent:SetExternalIp  error", err)
 	}
+	ctx.SetSriovConf(cfg)
+
 	//todo to delete
 	ctx.AdminTenantUUID = constvalue.PaaSTenantAdminDefaultUUID
 
@@ -386,3 +403,83 @@ func (self *AgentContext) GetClusterType(cfg *jason.Object) (string, error) {
 	klog.Infof("GetClusterType:cluster_type is: %v", clusterType)
 	return clusterType, nil
 }
+
+// SetSriovConf reads the PFs of physical networks and the VFs to create on
+// them from the "sriov" section: physnet_pf_map, default_max_vfs, vf_num and
+// vf_ranges, and whether the VFs are advertised to kubelet by device_plugin.
+func (self *AgentContext) SetSriovConf(cfg *jason.Object) {
+	physnetPfMap := make(map[string]string)
+	vfNumMap := make(map[string]int)
+	vfRanges := make(map[string]Range)
+	self.PhysnetPfMap = &physnetPfMap
+	self.VfNumMap = &vfNumMap
+	self.VfRanges = &vfRanges
+	self.VfRangeConfigured = false
+
+	maxVfs, err := cfg.GetInt64("sriov", "default_max_vfs")
+	if err != nil || maxVfs < 0 {
+		maxVfs = DefaultMaxVfs
+	}
+	self.DefaultMaxVfs = int(maxVfs)
+
+	pfs, _ := cfg.GetObject("sriov", "physnet_pf_map")
+	if pfs != nil {
+		for physnet, value := range pfs.Map() {
+			pf, err := value.String()
+			if err != nil || pf == "" {
+				klog.Warningf("SetSriovConf: pf of physnet[%s] is invalid, skip it", physnet)
+				continue
+			}
+			physnetPfMap[physnet] = pf
+		}
+	}
+
+	vfNums, _ := cfg.GetObject("sriov", "vf_num")
+	if vfNums != nil {
+		for pf, value := range vfNums.Map() {
+			num, err := value.Int64()
+			if err != nil || num < 0 {
+				klog.Warningf("SetSriovConf: vf_num of pf[%s] is invalid, skip it", pf)
+				continue
+			}
+			vfNumMap[pf] = int(num)
+		}
+	}
+
+	ranges, _ := cfg.GetObject("sriov", "vf_ranges")
+	if ranges != nil {
+		for pf, value := range ranges.Map() {
+			rangeStr, _ := value.String()
+			vfRange, err := ParseVfRange(rangeStr)
+			if err != nil {
+				klog.Warningf("SetSriovConf: vf_ranges of pf[%s] error: %v, skip it", pf, err)
+				continue
+			}
+			vfRanges[pf] = vfRange
+		}
+	}
+	self.VfRangeConfigured = len(vfRanges) > 0
+	self.DevicePluginOfVfs, _ = cfg.GetBoolean("sriov", "device_plugin")
+	klog.Infof("SetSriovConf: PhysnetPfMap: %v, DefaultMaxVfs: %d, VfNumMap: %v, VfRanges: %v, DevicePluginOfVfs: %v",
+		physnetPfMap, self.DefaultMaxVfs, vfNumMap, vfRanges, self.DevicePluginOfVfs)
+}
+
+// ParseVfRange parses the VF indexes "start-end" of a PF, both are included.
+func ParseVfRange(rangeStr string) (Range, error) {
+	bounds := strings.Split(rangeStr, "-")
+	if len(bounds) != 2 {
+		return Range{}, fmt.Errorf("vf range[%s] is not start-end", rangeStr)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return Range{}, fmt.Errorf("%v:vf range[%s] start error", err, rangeStr)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return Range{}, fmt.Errorf("%v:vf range[%s] end error", err, rangeStr)
+	}
+	if start < 0 || end < start {
+		return Range{}, fmt.Errorf("vf range[%s] is invalid", rangeStr)
+	}
+	return Range{Start: start, End: end}, nil
+}
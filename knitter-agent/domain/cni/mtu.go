@@ -0,0 +1,114 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cni
+
+import (
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/antonholmquist/jason"
+	"github.com/vishvananda/netlink"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// SetTunnel reads the tunnel to the other agents as
+// {"tunnel": {"type": "geneve", "dst_port": 6081}}, vxlan is used by default.
+func (self *AgentContext) SetTunnel(cfg *jason.Object) error {
+	tunnelType, err := cfg.GetString("tunnel", "type")
+	if err != nil {
+		tunnelType = dbaccessor.TunnelTypeVxlan
+	}
+	dstPort, err := cfg.GetInt64("tunnel", "dst_port")
+	if err != nil {
+		dstPort = constvalue.DefaultVxlanDstPort
+		if tunnelType == dbaccessor.TunnelTypeGeneve {
+			dstPort = constvalue.DefaultGeneveDstPort
+		}
+	}
+	tunnel := dbaccessor.Tunnel{Type: tunnelType, DstPort: int(dstPort)}
+	if !tunnel.IsValid() {
+		klog.Errorf("SetTunnel: invalid tunnel: %+v", tunnel)
+		return errors.New("invalid tunnel type or dst_port")
+	}
+	self.Tunnel = tunnel
+	klog.Infof("SetTunnel: tunnel: %+v", self.Tunnel)
+	return nil
+}
+
+// GetPodMtu returns the MTU of a pod interface attached to a network. The
+// MTU of the network wins, then the mtu of the host in the configuration.
+// Otherwise it is the MTU of the underlay interface holding the internal ip,
+// read every time to follow the changes of the underlay. The overhead of the
+// tunnel is taken off only for the overlay interfaces carried by br-tun, the
+// others like SR-IOV, vhost-user and neutron ports get the whole underlay.
+func (self *AgentContext) GetPodMtu(networkMtu int, overlay bool) string {
+	if networkMtu > 0 {
+		return strconv.Itoa(networkMtu)
+	}
+	if self.Mtu != "" {
+		return self.Mtu
+	}
+	if self.InternalIP == "" {
+		return constvalue.DefaultMtu
+	}
+
+	underlayMtu, err := getUnderlayMtu(self.InternalIP)
+	if err != nil {
+		klog.Warningf("GetPodMtu: getUnderlayMtu(%s) error: %v, use default %s byte",
+			self.InternalIP, err, constvalue.DefaultMtu)
+		underlayMtu, _ = strconv.Atoi(constvalue.DefaultMtu)
+	}
+	if !overlay {
+		return strconv.Itoa(underlayMtu)
+	}
+	return strconv.Itoa(underlayMtu - getTunnelOverhead(self.Tunnel.Type, self.InternalIP))
+}
+
+func getTunnelOverhead(tunnelType, underlayIP string) int {
+	overhead := constvalue.VxlanOverhead
+	if tunnelType == dbaccessor.TunnelTypeGeneve {
+		overhead = constvalue.GeneveOverhead
+	}
+	if ip := net.ParseIP(underlayIP); ip != nil && ip.To4() == nil {
+		overhead += constvalue.Ipv6UnderlayOverhead
+	}
+	return overhead
+}
+
+var getUnderlayMtu = func(underlayIP string) (int, error) {
+	ip := net.ParseIP(underlayIP)
+	if ip == nil {
+		return 0, errors.New("invalid underlay ip")
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return 0, err
+	}
+	for _, link := range links {
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.IP.Equal(ip) {
+				return link.Attrs().MTU, nil
+			}
+		}
+	}
+	return 0, errors.New("no interface holds the underlay ip")
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cni
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+)
+
+func TestSetTunnel(t *testing.T) {
+	setTunnel := func(cfg string) (dbaccessor.Tunnel, error) {
+		cfgObj, _ := jason.NewObjectFromBytes([]byte(cfg))
+		agtCtx := &AgentContext{}
+		err := agtCtx.SetTunnel(cfgObj)
+		return agtCtx.Tunnel, err
+	}
+
+	Convey("TestSetTunnel\n", t, func() {
+		tunnel, err := setTunnel(`{}`)
+		So(err, ShouldBeNil)
+		So(tunnel, ShouldResemble, dbaccessor.Tunnel{Type: "vxlan", DstPort: 6789})
+
+		tunnel, err = setTunnel(`{"tunnel": {"dst_port": 4789}}`)
+		So(err, ShouldBeNil)
+		So(tunnel, ShouldResemble, dbaccessor.Tunnel{Type: "vxlan", DstPort: 4789})
+
+		tunnel, err = setTunnel(`{"tunnel": {"type": "geneve"}}`)
+		So(err, ShouldBeNil)
+		So(tunnel, ShouldResemble, dbaccessor.Tunnel{Type: "geneve", DstPort: 6081})
+
+		_, err = setTunnel(`{"tunnel": {"type": "gre"}}`)
+		So(err, ShouldNotBeNil)
+		_, err = setTunnel(`{"tunnel": {"dst_port": 65536}}`)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestGetPodMtu(t *testing.T) {
+	Convey("TestGetPodMtu\n", t, func() {
+		stubs := gostub.StubFunc(&getUnderlayMtu, 9000, nil)
+		defer stubs.Reset()
+		agtCtx := &AgentContext{InternalIP: "192.168.1.10",
+			Tunnel: dbaccessor.Tunnel{Type: "vxlan", DstPort: 4789}}
+
+		Convey("derived from the underlay\n", func() {
+			So(agtCtx.GetPodMtu(0, true), ShouldEqual, "8950")
+			agtCtx.Tunnel.Type = "geneve"
+			So(agtCtx.GetPodMtu(0, true), ShouldEqual, "8942")
+			agtCtx.InternalIP = "fd00::10"
+			So(agtCtx.GetPodMtu(0, true), ShouldEqual, "8922")
+		})
+
+		Convey("no tunnel overhead off the overlay\n", func() {
+			So(agtCtx.GetPodMtu(0, false), ShouldEqual, "9000")
+			So(agtCtx.GetPodMtu(1500, false), ShouldEqual, "1500")
+		})
+
+		Convey("overridden by the configuration and the network\n", func() {
+			agtCtx.Mtu = "1400"
+			So(agtCtx.GetPodMtu(0, true), ShouldEqual, "1400")
+			So(agtCtx.GetPodMtu(8000, true), ShouldEqual, "8000")
+		})
+
+		Convey("underlay unknown\n", func() {
+			stubs.StubFunc(&getUnderlayMtu, 0, errors.New("no interface"))
+			So(agtCtx.GetPodMtu(0, true), ShouldEqual, "1450")
+			So(agtCtx.GetPodMtu(0, false), ShouldEqual, "1500")
+			agtCtx.InternalIP = ""
+			So(agtCtx.GetPodMtu(0, true), ShouldEqual, "1500")
+		})
+	})
+}
@@ -19,12 +19,15 @@ const (
 	OvsBrint             string = "br-int"
 	OvsBrtun             string = "br-tun"
 	OvsBrcheck           string = "br-nwnode-check"
+	OvsdbEndpoint        string = "unix:/var/run/openvswitch/db.sock"
 	OvsBr0               string = "obr0"
 	OvsIfErrorField      string = "error"
 	OvsIfErrorNoDev      string = "No such device"
 	CheckNousedIfsIntval int    = 5 * 60
 	InvalidNetID         int    = 4096
 	DefaultTunIntPort    uint   = 1
+	BrtunTunnelOfPort    uint   = 2
+	BrtunTunnelPort      string = "tun-paas"
 	DefaultMtu           string = "1500"
 
 	MaxRetryTimesOfOvsOp    int = 40
@@ -54,9 +57,59 @@ const (
 const (
 	BondBackup  = "active-backup"
 	BondBalance = "balance-xor"
+	Bond8023AD  = "802.3ad"
 	BondPairNum = 2
 )
 
+const (
+	OvsDatapathNetdev        = "netdev"
+	OvsIfTypeVhostUserClient = "dpdkvhostuserclient"
+	VhostUserSockDir         = "/var/lib/knitter/vhost-user"
+	VhostUserIfPrefix        = "vhu"
+	VhostUserIfNameMaxLen    = 14
+)
+
+const (
+	QosMaxDscp = 63
+	// QosDscpFlowPriority is above the NORMAL flow of br-int
+	QosDscpFlowPriority = 10
+	// QosDscpFlowCookie marks the DSCP flows of br-int to be deleted by cookie
+	QosDscpFlowCookie = "0x4b51"
+)
+
+// pipeline of br-int enforcing the network policies, the IP packets leave
+// table 0 for the classify table, which sends the packets from or to the
+// pods selected by policies through conntrack
+const (
+	BrintPolicyClassifyTable = 70
+	BrintPolicyEgressTable   = 71
+	BrintPolicyIngressTable  = 72
+	BrintPolicyAcceptTable   = 73
+	BrintPolicyEntryPriority = 5
+	NetPolicyFlowCookie      = "0x4b50"
+	// NetPolicyCtZoneField keeps the local VLAN of the port as conntrack zone
+	NetPolicyCtZoneField = "NXM_NX_REG6[0..15]"
+)
+
+// the flows of a tenant network on br-tun carry the cookie
+// BrtunNetFlowCookieBase|vni, they are dumped by BrtunNetFlowCookieMask
+const (
+	BrtunNetFlowCookieBase uint64 = 0x4b5400000000
+	BrtunNetFlowCookieMask uint64 = 0xffffffff00000000
+)
+
+// the vxlan tunnels use the port 6789 unless configured, the clusters
+// deployed before the port was configurable keep working
+const (
+	DefaultVxlanDstPort  = 6789
+	DefaultGeneveDstPort = 6081
+	// the overhead is the outer Ethernet, IPv4, UDP and vxlan headers,
+	// 8 more bytes are kept for the options of geneve
+	VxlanOverhead        = 50
+	GeneveOverhead       = 58
+	Ipv6UnderlayOverhead = 20
+)
+
 const (
 	EventFailForCreatepod   = "CreatePodNetworkFailed."
 	EventFailForGetpod      = "GetPodFailed."
@@ -67,8 +120,12 @@ const (
 
 const HTTPDefaultTimeoutInSec = 60 // default http GET/POST request timeout in second
 
+const SyncPodNetworksIntervalInSec = 10 // interval to hot-plug interfaces of pods on node
+
 const PaaSTenantAdminDefaultUUID = "admin"
 
+const DefaultPortName = "eth0" // interface of the default network of pod
+
 const SKIP string = "REPLICATE-SKIP"
 
 const LocalDBDataDir = "/root/nwnode/data-dir"
@@ -0,0 +1,148 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deviceplugin
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/sriov"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const KubeletCheckpointFile = "kubelet_internal_checkpoint"
+
+type podDevicesEntry struct {
+	PodUID        string
+	ContainerName string
+	ResourceName  string
+	DeviceIDs     json.RawMessage
+}
+
+type checkpointData struct {
+	PodDeviceEntries []podDevicesEntry
+}
+
+// kubeletCheckpoint is the checkpoint of the devices allocated by kubelet, the
+// entries are under Data since kubernetes 1.10.
+type kubeletCheckpoint struct {
+	Data             *checkpointData
+	PodDeviceEntries []podDevicesEntry
+}
+
+// parseDeviceIDs accepts the device ids as a list, or grouped by NUMA node as
+// kubelet writes them since kubernetes 1.20.
+func parseDeviceIDs(raw json.RawMessage) []string {
+	var ids []string
+	if json.Unmarshal(raw, &ids) == nil {
+		return ids
+	}
+	var idsOfNodes map[string][]string
+	if json.Unmarshal(raw, &idsOfNodes) != nil {
+		return nil
+	}
+	nodes := make([]string, 0)
+	for node := range idsOfNodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		ids = append(ids, idsOfNodes[node]...)
+	}
+	return ids
+}
+
+func readCheckpointEntries() ([]podDevicesEntry, error) {
+	path := filepath.Join(PluginDir, KubeletCheckpointFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		klog.Errorf("readCheckpointEntries: read kubelet checkpoint %s error: %v", path, err)
+		return nil, err
+	}
+	checkpoint := &kubeletCheckpoint{}
+	err = json.Unmarshal(data, checkpoint)
+	if err != nil {
+		klog.Errorf("readCheckpointEntries: json.Unmarshal kubelet checkpoint error: %v", err)
+		return nil, err
+	}
+	if checkpoint.Data != nil {
+		return checkpoint.Data.PodDeviceEntries, nil
+	}
+	return checkpoint.PodDeviceEntries, nil
+}
+
+// GetDevicesOfPod returns the devices of the resource allocated to the pod
+// by kubelet.
+func GetDevicesOfPod(podUID, resourceName string) ([]string, error) {
+	entries, err := readCheckpointEntries()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	for _, entry := range entries {
+		if entry.PodUID == podUID && entry.ResourceName == resourceName {
+			ids = append(ids, parseDeviceIDs(entry.DeviceIDs)...)
+		}
+	}
+	return ids, nil
+}
+
+// GetAllocatedDevices returns the devices of the resource allocated to all
+// pods by kubelet.
+func GetAllocatedDevices(resourceName string) ([]string, error) {
+	entries, err := readCheckpointEntries()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	for _, entry := range entries {
+		if entry.ResourceName == resourceName {
+			ids = append(ids, parseDeviceIDs(entry.DeviceIDs)...)
+		}
+	}
+	return ids, nil
+}
+
+var getPodUID = func(podNs, podName string) (string, error) {
+	_, pod, err := cni.GetGlobalContext().K8s.GetPod(podNs, podName)
+	if err != nil {
+		return "", err
+	}
+	if pod == nil {
+		return "", errors.New("pod not found")
+	}
+	return pod.GetString("metadata", "uid")
+}
+
+// AllocVfForPod takes a VF of the physnet for the port of the pod. The pod
+// requesting the VF resource of the physnet gets one of the VFs reserved for
+// it by kubelet, the other pods get a VF not reserved.
+func AllocVfForPod(podNs, podName, physnet, portID, containerID string) (*sriov.Vf, error) {
+	vfMgr := sriov.GetVfManagerSingleton()
+	podUID, err := getPodUID(podNs, podName)
+	if err != nil {
+		klog.Warningf("AllocVfForPod: get uid of pod[%s/%s] error: %v", podNs, podName, err)
+		return vfMgr.AllocVf(physnet, portID, containerID)
+	}
+	ids, err := GetDevicesOfPod(podUID, GetVfResourceName(physnet))
+	if err != nil || len(ids) == 0 {
+		return vfMgr.AllocVf(physnet, portID, containerID)
+	}
+	klog.Infof("AllocVfForPod: pod[%s/%s] has vfs%v reserved", podNs, podName, ids)
+	return vfMgr.AllocReservedVf(physnet, ids, portID, containerID)
+}
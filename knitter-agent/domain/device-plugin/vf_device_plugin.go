@@ -0,0 +1,278 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deviceplugin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/sriov"
+	"github.com/ZTE/Knitter/pkg/device-plugin/v1beta1"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const (
+	ResourceNamePrefix        = "knitter.io/"
+	ResourceNameSuffixOfVf    = "_vf"
+	DefaultCheckIntervalInSec = 5
+	KubeletDialTimeoutInSec   = 5
+	// kubelet writes its checkpoint after the devices are allocated, the
+	// reservations younger than it are never cleared
+	StaleReservationTimeoutInSec = 60
+)
+
+// PluginDir is where kubelet listens on kubelet.sock and device plugins put
+// their sockets.
+var PluginDir = v1beta1.DevicePluginPath
+
+// GetVfResourceName returns the extended resource of the VFs of the physnet,
+// e.g. knitter.io/physnet1_vf.
+func GetVfResourceName(physnet string) string {
+	return ResourceNamePrefix + physnet + ResourceNameSuffixOfVf
+}
+
+// VfDevicePlugin advertises the VFs of the PF of a physnet to kubelet, the
+// devices are identified by the PCI addresses of the VFs.
+type VfDevicePlugin struct {
+	Physnet       string
+	ResourceName  string
+	PluginDir     string
+	Endpoint      string
+	VfMgr         *sriov.VfManager
+	CheckInterval time.Duration
+
+	server *grpc.Server
+	stop   chan struct{}
+}
+
+func NewVfDevicePlugin(physnet string, vfMgr *sriov.VfManager) *VfDevicePlugin {
+	return &VfDevicePlugin{
+		Physnet:       physnet,
+		ResourceName:  GetVfResourceName(physnet),
+		PluginDir:     PluginDir,
+		Endpoint:      "knitter-" + physnet + ".sock",
+		VfMgr:         vfMgr,
+		CheckInterval: DefaultCheckIntervalInSec * time.Second,
+	}
+}
+
+// StartVfDevicePlugins runs a device plugin for each physnet with a PF.
+func StartVfDevicePlugins() {
+	vfMgr := sriov.GetVfManagerSingleton()
+	physnets := make([]string, 0)
+	for physnet := range vfMgr.PhysnetPfMap {
+		physnets = append(physnets, physnet)
+	}
+	sort.Strings(physnets)
+	for _, physnet := range physnets {
+		go NewVfDevicePlugin(physnet, vfMgr).Run()
+	}
+}
+
+func (self *VfDevicePlugin) socketPath() string {
+	return filepath.Join(self.PluginDir, self.Endpoint)
+}
+
+// Run keeps the device plugin registered, kubelet removes the sockets of the
+// plugins when it restarts and the plugin is started again then.
+func (self *VfDevicePlugin) Run() {
+	for {
+		if self.server == nil {
+			err := self.Start()
+			if err != nil {
+				klog.Errorf("VfDevicePlugin.Run: start device plugin of resource[%s] error: %v", self.ResourceName, err)
+			}
+		} else if _, err := os.Stat(self.socketPath()); err != nil {
+			klog.Warningf("VfDevicePlugin.Run: socket of resource[%s] is removed, restart it", self.ResourceName)
+			self.Stop()
+			continue
+		}
+		time.Sleep(self.CheckInterval)
+	}
+}
+
+func (self *VfDevicePlugin) Start() error {
+	os.Remove(self.socketPath())
+	lis, err := net.Listen("unix", self.socketPath())
+	if err != nil {
+		klog.Errorf("VfDevicePlugin.Start: listen on %s error: %v", self.socketPath(), err)
+		return err
+	}
+	self.stop = make(chan struct{})
+	self.server = grpc.NewServer()
+	v1beta1.RegisterDevicePluginServer(self.server, self)
+	go self.server.Serve(lis)
+
+	err = self.register()
+	if err != nil {
+		self.Stop()
+		return err
+	}
+	klog.Infof("VfDevicePlugin.Start: device plugin of resource[%s] is registered", self.ResourceName)
+	return nil
+}
+
+func (self *VfDevicePlugin) Stop() {
+	if self.server == nil {
+		return
+	}
+	close(self.stop)
+	self.server.Stop()
+	self.server = nil
+	os.Remove(self.socketPath())
+}
+
+func dialUnixSocket(path string, timeout time.Duration) (*grpc.ClientConn, error) {
+	return grpc.Dial(path, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(timeout),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+}
+
+func (self *VfDevicePlugin) register() error {
+	kubeletSocket := filepath.Join(self.PluginDir, filepath.Base(v1beta1.KubeletSocket))
+	conn, err := dialUnixSocket(kubeletSocket, KubeletDialTimeoutInSec*time.Second)
+	if err != nil {
+		klog.Errorf("VfDevicePlugin.register: dial kubelet on %s error: %v", kubeletSocket, err)
+		return err
+	}
+	defer conn.Close()
+
+	client := v1beta1.NewRegistrationClient(conn)
+	req := &v1beta1.RegisterRequest{
+		Version:      v1beta1.Version,
+		Endpoint:     self.Endpoint,
+		ResourceName: self.ResourceName,
+	}
+	_, err = client.Register(context.Background(), req)
+	if err != nil {
+		klog.Errorf("VfDevicePlugin.register: register %v error: %v", req, err)
+		return err
+	}
+	return nil
+}
+
+// clearStaleReservations frees the reserved VFs no longer allocated to any pod
+// in the kubelet checkpoint, nothing is freed if the checkpoint is unreadable.
+func (self *VfDevicePlugin) clearStaleReservations() {
+	allocated, err := GetAllocatedDevices(self.ResourceName)
+	if err != nil {
+		return
+	}
+	before := time.Now().Add(-StaleReservationTimeoutInSec * time.Second)
+	err = self.VfMgr.ClearStaleReservations(self.Physnet, allocated, before)
+	if err != nil {
+		klog.Warningf("VfDevicePlugin.clearStaleReservations: clear vfs of physnet[%s] error: %v", self.Physnet, err)
+	}
+}
+
+// getDevices lists all VFs of the physnet. A VF used by a pod without the
+// resource is unhealthy, so that kubelet never allocates it again, and so is
+// a free VF missing from host.
+func (self *VfDevicePlugin) getDevices() []*v1beta1.Device {
+	self.clearStaleReservations()
+	devices := make([]*v1beta1.Device, 0)
+	vfs, err := self.VfMgr.ListVfsOfPhysnet(self.Physnet)
+	if err != nil {
+		klog.Errorf("VfDevicePlugin.getDevices: list vfs of physnet[%s] error: %v", self.Physnet, err)
+		return devices
+	}
+	for _, vf := range vfs {
+		health := v1beta1.Healthy
+		if vf.Used && !vf.Reserved {
+			health = v1beta1.Unhealthy
+		} else if !vf.Used {
+			if _, err := self.VfMgr.GetVfNetdevName(vf.Pf, vf.Index); err != nil {
+				health = v1beta1.Unhealthy
+			}
+		}
+		devices = append(devices, &v1beta1.Device{ID: vf.PciAddr, Health: health})
+	}
+	return devices
+}
+
+func (self *VfDevicePlugin) GetDevicePluginOptions(ctx context.Context, e *v1beta1.Empty) (*v1beta1.DevicePluginOptions, error) {
+	return &v1beta1.DevicePluginOptions{}, nil
+}
+
+func (self *VfDevicePlugin) ListAndWatch(e *v1beta1.Empty, s v1beta1.DevicePlugin_ListAndWatchServer) error {
+	stop := self.stop
+	devices := self.getDevices()
+	err := s.Send(&v1beta1.ListAndWatchResponse{Devices: devices})
+	if err != nil {
+		klog.Errorf("VfDevicePlugin.ListAndWatch: send devices of resource[%s] error: %v", self.ResourceName, err)
+		return err
+	}
+
+	ticker := time.NewTicker(self.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			newDevices := self.getDevices()
+			if reflect.DeepEqual(newDevices, devices) {
+				continue
+			}
+			devices = newDevices
+			err = s.Send(&v1beta1.ListAndWatchResponse{Devices: devices})
+			if err != nil {
+				klog.Errorf("VfDevicePlugin.ListAndWatch: send devices of resource[%s] error: %v", self.ResourceName, err)
+				return err
+			}
+		}
+	}
+}
+
+// Allocate reserves the VFs chosen by kubelet, the attach of the pod takes
+// them later. Their PCI addresses are passed to the containers in env.
+func (self *VfDevicePlugin) Allocate(ctx context.Context, req *v1beta1.AllocateRequest) (*v1beta1.AllocateResponse, error) {
+	resp := &v1beta1.AllocateResponse{}
+	for _, containerReq := range req.ContainerRequests {
+		err := self.VfMgr.ReserveVfs(self.Physnet, containerReq.DevicesIDs)
+		if err != nil {
+			klog.Errorf("VfDevicePlugin.Allocate: reserve vfs%v error: %v", containerReq.DevicesIDs, err)
+			return nil, fmt.Errorf("%v:reserve vfs%v error", err, containerReq.DevicesIDs)
+		}
+		resp.ContainerResponses = append(resp.ContainerResponses, &v1beta1.ContainerAllocateResponse{
+			Envs: map[string]string{self.envName(): strings.Join(containerReq.DevicesIDs, ",")},
+		})
+	}
+	return resp, nil
+}
+
+// envName returns the env of the VFs in container, e.g. KNITTER_PHYSNET1_VF.
+func (self *VfDevicePlugin) envName() string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, self.Physnet)
+	return "KNITTER_" + strings.ToUpper(name) + "_VF"
+}
+
+func (self *VfDevicePlugin) PreStartContainer(ctx context.Context, req *v1beta1.PreStartContainerRequest) (*v1beta1.PreStartContainerResponse, error) {
+	return &v1beta1.PreStartContainerResponse{}, nil
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deviceplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/sriov"
+	"github.com/ZTE/Knitter/pkg/device-plugin/v1beta1"
+	"github.com/ZTE/Knitter/pkg/leveldb"
+)
+
+type fakeKubelet struct {
+	server *grpc.Server
+	reqs   chan *v1beta1.RegisterRequest
+}
+
+func (self *fakeKubelet) Register(ctx context.Context, req *v1beta1.RegisterRequest) (*v1beta1.Empty, error) {
+	self.reqs <- req
+	return &v1beta1.Empty{}, nil
+}
+
+// startFakeKubelet serves the registration service on kubelet.sock of dir.
+func startFakeKubelet(dir string) *fakeKubelet {
+	lis, _ := net.Listen("unix", filepath.Join(dir, "kubelet.sock"))
+	kubelet := &fakeKubelet{server: grpc.NewServer(), reqs: make(chan *v1beta1.RegisterRequest, 10)}
+	v1beta1.RegisterRegistrationServer(kubelet.server, kubelet)
+	go kubelet.server.Serve(lis)
+	return kubelet
+}
+
+// newTestVfManager manages 4 VFs of enp4s0f0 in a fake sysfs tree, VF N is
+// 0000:03:10.N with netdev enp4s0f0vN.
+func newTestVfManager(root string) *sriov.VfManager {
+	pfDevice := filepath.Join(root, "class", "net", "enp4s0f0", "device")
+	os.MkdirAll(pfDevice, 0755)
+	ioutil.WriteFile(filepath.Join(pfDevice, "sriov_totalvfs"), []byte("4"), 0644)
+	ioutil.WriteFile(filepath.Join(pfDevice, "sriov_numvfs"), []byte("4"), 0644)
+	vfs := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		vfDevice := filepath.Join(root, "bus", "pci", "devices", fmt.Sprintf("0000:03:10.%d", i))
+		os.MkdirAll(filepath.Join(vfDevice, "net", "enp4s0f0v"+strconv.Itoa(i)), 0755)
+		os.Symlink(vfDevice, filepath.Join(pfDevice, "virtfn"+strconv.Itoa(i)))
+		vfs = append(vfs, vfDevice)
+	}
+	db, _ := leveldb.NewLevelDBClient(filepath.Join(root, "db"))
+	vfMgr := &sriov.VfManager{
+		SysfsRoot:     root,
+		DB:            db,
+		PhysnetPfMap:  map[string]string{"physnet1": "enp4s0f0"},
+		DefaultMaxVfs: 4,
+	}
+	for i := range vfs {
+		vf := &sriov.Vf{Pf: "enp4s0f0", Index: i}
+		vf.PciAddr, _ = vfMgr.GetVfPciAddr("enp4s0f0", i)
+		vf.Name, _ = vfMgr.GetVfNetdevName("enp4s0f0", i)
+		db.SaveLeaf(fmt.Sprintf("/phys/sriov/enp4s0f0/vfs/%d", i), fmt.Sprintf(
+			`{"pf":"enp4s0f0","index":%d,"pci_addr":"%s","name":"%s"}`, i, vf.PciAddr, vf.Name))
+	}
+	return vfMgr
+}
+
+func toJSON(vf *sriov.Vf) string {
+	value, _ := json.Marshal(vf)
+	return string(value)
+}
+
+func TestVfDevicePlugin(t *testing.T) {
+	Convey("TestVfDevicePlugin", t, func() {
+		dir, _ := ioutil.TempDir("", "device-plugins")
+		defer os.RemoveAll(dir)
+		kubelet := startFakeKubelet(dir)
+		defer kubelet.server.Stop()
+		vfMgr := newTestVfManager(dir)
+
+		plugin := NewVfDevicePlugin("physnet1", vfMgr)
+		plugin.PluginDir = dir
+		plugin.CheckInterval = 20 * time.Millisecond
+		So(plugin.Start(), ShouldBeNil)
+		defer plugin.Stop()
+
+		req := <-kubelet.reqs
+		So(*req, ShouldResemble, v1beta1.RegisterRequest{Version: v1beta1.Version,
+			Endpoint: "knitter-physnet1.sock", ResourceName: "knitter.io/physnet1_vf"})
+
+		conn, err := dialUnixSocket(filepath.Join(dir, req.Endpoint), time.Second)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		client := v1beta1.NewDevicePluginClient(conn)
+
+		Convey("vfs are listed and watched\n", func() {
+			stream, err := client.ListAndWatch(context.Background(), &v1beta1.Empty{})
+			So(err, ShouldBeNil)
+			resp, err := stream.Recv()
+			So(err, ShouldBeNil)
+			So(len(resp.Devices), ShouldEqual, 4)
+			So(*resp.Devices[2], ShouldResemble, v1beta1.Device{ID: "0000:03:10.2", Health: v1beta1.Healthy})
+
+			os.RemoveAll(filepath.Join(dir, "bus", "pci", "devices", "0000:03:10.2", "net"))
+			resp, err = stream.Recv()
+			So(err, ShouldBeNil)
+			So(resp.Devices[2].Health, ShouldEqual, v1beta1.Unhealthy)
+		})
+
+		Convey("vf used by a pod without the resource is unhealthy\n", func() {
+			_, err := vfMgr.AllocVf("physnet1", "port1", "container1")
+			So(err, ShouldBeNil)
+			So(vfMgr.ReserveVfs("physnet1", []string{"0000:03:10.1"}), ShouldBeNil)
+			_, err = vfMgr.AllocReservedVf("physnet1", []string{"0000:03:10.1"}, "port2", "container2")
+			So(err, ShouldBeNil)
+			devices := plugin.getDevices()
+			So(devices[0].Health, ShouldEqual, v1beta1.Unhealthy)
+			So(devices[1].Health, ShouldEqual, v1beta1.Healthy)
+			So(devices[2].Health, ShouldEqual, v1beta1.Healthy)
+		})
+
+		Convey("reservations not in the kubelet checkpoint are cleared\n", func() {
+			stubs := gostub.Stub(&PluginDir, dir)
+			defer stubs.Reset()
+			So(vfMgr.ReserveVfs("physnet1", []string{"0000:03:10.1", "0000:03:10.2"}), ShouldBeNil)
+			ioutil.WriteFile(filepath.Join(dir, KubeletCheckpointFile), []byte(`{"Data": {"PodDeviceEntries": [
+				{"PodUID": "uid-pod1", "ContainerName": "c1", "ResourceName": "knitter.io/physnet1_vf",
+				"DeviceIDs": ["0000:03:10.2"]}]}}`), 0644)
+
+			plugin.getDevices()
+			vfs, _ := vfMgr.ListVfsOfPhysnet("physnet1")
+			So(vfs[1].Reserved, ShouldBeTrue)
+
+			vfs[1].ReservedTime -= StaleReservationTimeoutInSec + 1
+			vfs[2].ReservedTime -= StaleReservationTimeoutInSec + 1
+			vfMgr.DB.SaveLeaf("/phys/sriov/enp4s0f0/vfs/1", toJSON(vfs[1]))
+			vfMgr.DB.SaveLeaf("/phys/sriov/enp4s0f0/vfs/2", toJSON(vfs[2]))
+			plugin.getDevices()
+			vfs, _ = vfMgr.ListVfsOfPhysnet("physnet1")
+			So(vfs[1].Reserved, ShouldBeFalse)
+			So(vfs[2].Reserved, ShouldBeTrue)
+		})
+
+		Convey("allocated vfs are reserved\n", func() {
+			resp, err := client.Allocate(context.Background(), &v1beta1.AllocateRequest{
+				ContainerRequests: []*v1beta1.ContainerAllocateRequest{{DevicesIDs: []string{"0000:03:10.1"}}}})
+			So(err, ShouldBeNil)
+			So(resp.ContainerResponses[0].Envs, ShouldResemble, map[string]string{"KNITTER_PHYSNET1_VF": "0000:03:10.1"})
+			vfs, _ := vfMgr.ListVfsOfPhysnet("physnet1")
+			So(vfs[1].Reserved, ShouldBeTrue)
+			So(vfs[0].Reserved, ShouldBeFalse)
+
+			_, err = client.Allocate(context.Background(), &v1beta1.AllocateRequest{
+				ContainerRequests: []*v1beta1.ContainerAllocateRequest{{DevicesIDs: []string{"0000:03:11.0"}}}})
+			So(err, ShouldNotBeNil)
+
+			vfMgr.AllocVf("physnet1", "port1", "container1")
+			_, err = client.Allocate(context.Background(), &v1beta1.AllocateRequest{
+				ContainerRequests: []*v1beta1.ContainerAllocateRequest{{DevicesIDs: []string{"0000:03:10.0"}}}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("socket is removed after stopped\n", func() {
+			plugin.Stop()
+			_, err := os.Stat(filepath.Join(dir, req.Endpoint))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestAllocVfForPod(t *testing.T) {
+	Convey("TestAllocVfForPod", t, func() {
+		dir, _ := ioutil.TempDir("", "device-plugins")
+		defer os.RemoveAll(dir)
+		vfMgr := newTestVfManager(dir)
+		stubs := gostub.Stub(&PluginDir, dir)
+		defer stubs.Reset()
+		stubs.Stub(&sriov.GetVfManagerSingleton, func() *sriov.VfManager { return vfMgr })
+		stubs.Stub(&getPodUID, func(podNs, podName string) (string, error) {
+			return "uid-" + podName, nil
+		})
+		vfMgr.ReserveVfs("physnet1", []string{"0000:03:10.0", "0000:03:10.2"})
+		ioutil.WriteFile(filepath.Join(dir, KubeletCheckpointFile), []byte(`{"Data": {"PodDeviceEntries": [
+			{"PodUID": "uid-pod1", "ContainerName": "c1", "ResourceName": "knitter.io/physnet1_vf", "DeviceIDs": ["0000:03:10.2"]},
+			{"PodUID": "uid-pod2", "ContainerName": "c1", "ResourceName": "knitter.io/physnet1_vf", "DeviceIDs": {"0": ["0000:03:10.0"]}}],
+			"RegisteredDevices": {}}, "Checksum": 1}`), 0644)
+
+		vf, err := AllocVfForPod("ns", "pod1", "physnet1", "port1", "container1")
+		So(err, ShouldBeNil)
+		So(vf.PciAddr, ShouldEqual, "0000:03:10.2")
+		vf, err = AllocVfForPod("ns", "pod2", "physnet1", "port2", "container2")
+		So(err, ShouldBeNil)
+		So(vf.PciAddr, ShouldEqual, "0000:03:10.0")
+
+		// pods without the resource never take the reserved vfs
+		vf, err = AllocVfForPod("ns", "pod3", "physnet1", "port3", "container3")
+		So(err, ShouldBeNil)
+		So(vf.PciAddr, ShouldEqual, "0000:03:10.1")
+		vf, err = AllocVfForPod("ns", "pod4", "physnet1", "port4", "container4")
+		So(err, ShouldBeNil)
+		So(vf.PciAddr, ShouldEqual, "0000:03:10.3")
+		_, err = AllocVfForPod("ns", "pod5", "physnet1", "port5", "container5")
+		So(err, ShouldEqual, sriov.ErrNoFreeVf)
+	})
+}
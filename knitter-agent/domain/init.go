@@ -15,7 +15,10 @@ package domain
 
 import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/device-plugin"
+	"github.com/ZTE/Knitter/knitter-agent/domain/netpolicy"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/bridge-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/cluster-mgr-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/db-obj"
@@ -25,6 +28,7 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/bridge-role/brint-sub-role"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/knitter-agent-role"
+	"github.com/ZTE/Knitter/knitter-agent/domain/sriov"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/antonholmquist/jason"
 	"strconv"
@@ -42,12 +46,29 @@ func Init(cfg *jason.Object) error {
 		klog.Errorf("domain.Init: WaitOvsUsable error: %v", err)
 		return err
 	}
+	err = ovs.ConnectOvsdb()
+	if err != nil {
+		klog.Warningf("domain.Init: ConnectOvsdb error: %v, change bridges by ovs-vsctl", err)
+	}
 
 	if bridgeObj.BrtunRole.SyncSwitch(cfg) {
 		klog.Errorf("domain.Init: bridgeObj.BrtunRole.SyncSwitch is true, start sync")
 		go bridgeObj.BrtunRole.StartSync()
 	}
 
+	err = sriov.GetVfManagerSingleton().InitVfs()
+	if err != nil {
+		klog.Errorf("domain.Init: InitVfs error: %v", err)
+	}
+	if cni.GetGlobalContext().DevicePluginOfVfs {
+		deviceplugin.StartVfDevicePlugins()
+	}
+
+	err = netpolicy.Start(cni.GetGlobalContext().NetworkPolicy)
+	if err != nil {
+		klog.Errorf("domain.Init: netpolicy.Start error: %v", err)
+	}
+
 	bind.DestroyResidualBrintIntfcs()
 	tenantNetworkMap := brintsubrole.GetTenantNetworkTableSingleton().GetAll()
 	for key, value := range tenantNetworkMap {
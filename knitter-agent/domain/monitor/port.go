@@ -31,6 +31,27 @@ type PortEagerAttr struct {
 	FixIP        string      `json:"fix_ip"`
 	IPGroupName  string      `json:"ip_group_name"`
 	Metadata     interface{} `json:"metadata"`
+	Bond         *PortBond   `json:"bond,omitempty"`
+	Qos          *PortQos    `json:"qos,omitempty"`
+}
+
+// PortBond asks for a Linux bond in pod for the port, it is made of one VF of
+// each physnet, or of two veths attached to br-int if physnets is empty.
+type PortBond struct {
+	Mode     string   `json:"mode"`
+	Miimon   int      `json:"miimon"`
+	Physnets []string `json:"physnets,omitempty"`
+}
+
+// PortQos limits the rate of the port on br-int and marks the DSCP of the IP
+// packets from pod, rates are in kbps and bursts in kb, 0 means no limit.
+// Ingress is the traffic br-int receives from pod, egress is the reverse.
+type PortQos struct {
+	IngressRate  int64 `json:"ingress_rate,omitempty"`
+	IngressBurst int64 `json:"ingress_burst,omitempty"`
+	EgressRate   int64 `json:"egress_rate,omitempty"`
+	EgressBurst  int64 `json:"egress_burst,omitempty"`
+	Dscp         *int  `json:"dscp,omitempty"`
 }
 
 type PortLazyAttr struct {
@@ -0,0 +1,387 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// priorities of the flows of a port in the policy tables
+const (
+	egressClassifyPriority  = 50
+	ingressClassifyPriority = 40
+	allowPriority           = 30
+	denyPriority            = 20
+	ctStatePriority         = 100
+	ctInvalidPriority       = 110
+	commitPriority          = 10
+)
+
+type Flow struct {
+	Table    int
+	Priority int
+	Match    string
+	Actions  string
+}
+
+// Key identifies the flow in the table as ovs-ofctl --strict does.
+func (f Flow) Key() string {
+	key := fmt.Sprintf("table=%d,priority=%d", f.Table, f.Priority)
+	if f.Match != "" {
+		key += "," + f.Match
+	}
+	return key
+}
+
+func (f Flow) String() string {
+	return fmt.Sprintf("cookie=%s,%s,actions=%s", constvalue.NetPolicyFlowCookie, f.Key(), f.Actions)
+}
+
+// PodPort is an interface of pod with its knitter network and IPs.
+type PodPort struct {
+	ID      string
+	Network string
+	IfName  string
+	IPs     []string
+}
+
+type PodInfo struct {
+	Namespace string
+	Name      string
+	Labels    map[string]string
+	Ports     []*PodPort
+}
+
+func (pod *PodInfo) getPort(portID string) *PodPort {
+	for _, port := range pod.Ports {
+		if port.ID == portID {
+			return port
+		}
+	}
+	return nil
+}
+
+// LocalPort is a port of pod attached to br-int on this node.
+type LocalPort struct {
+	PortID  string
+	PodNs   string
+	PodName string
+	MAC     string
+	OfPort  string
+	VlanID  int
+}
+
+// Cluster is the view of pods and namespaces to resolve the peers.
+type Cluster struct {
+	Pods            []*PodInfo
+	NamespaceLabels map[string]map[string]string
+	// LoadPorts fills the ports of the pod when they are needed, it may be nil
+	LoadPorts func(pod *PodInfo)
+}
+
+func (c *Cluster) getPod(podNs, podName string) *PodInfo {
+	for _, pod := range c.Pods {
+		if pod.Namespace == podNs && pod.Name == podName {
+			return c.loadPorts(pod)
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) loadPorts(pod *PodInfo) *PodInfo {
+	if pod.Ports == nil && c.LoadPorts != nil {
+		c.LoadPorts(pod)
+	}
+	return pod
+}
+
+// BaseFlows make up the pipeline without any policy, the packets of the
+// ports not selected by policies go through table 70 to NORMAL.
+func BaseFlows() []Flow {
+	resubmit := func(table int) string {
+		return fmt.Sprintf("resubmit(,%d)", table)
+	}
+	flows := []Flow{
+		{Table: 0, Priority: constvalue.BrintPolicyEntryPriority, Match: "ip",
+			Actions: resubmit(constvalue.BrintPolicyClassifyTable)},
+		{Table: constvalue.BrintPolicyClassifyTable, Actions: "NORMAL"},
+		{Table: constvalue.BrintPolicyEgressTable, Actions: "drop"},
+		{Table: constvalue.BrintPolicyIngressTable, Actions: resubmit(constvalue.BrintPolicyAcceptTable)},
+		{Table: constvalue.BrintPolicyAcceptTable, Priority: commitPriority, Match: "ct_state=+new+trk,ip",
+			Actions: fmt.Sprintf("ct(commit,zone=%s),NORMAL", constvalue.NetPolicyCtZoneField)},
+		{Table: constvalue.BrintPolicyAcceptTable, Actions: "NORMAL"},
+	}
+	for _, table := range []int{constvalue.BrintPolicyEgressTable, constvalue.BrintPolicyIngressTable} {
+		flows = append(flows,
+			Flow{Table: table, Priority: ctStatePriority, Match: "ct_state=+est+trk",
+				Actions: resubmit(constvalue.BrintPolicyAcceptTable)},
+			Flow{Table: table, Priority: ctStatePriority, Match: "ct_state=+rel+trk",
+				Actions: resubmit(constvalue.BrintPolicyAcceptTable)},
+			Flow{Table: table, Priority: ctInvalidPriority, Match: "ct_state=+inv+trk", Actions: "drop"})
+	}
+	return flows
+}
+
+// Compile returns the flows of br-int enforcing the policies on the local
+// ports, including the base flows. The packets allowed by egress rules go on
+// to the ingress rules of the destination, and the new connections allowed
+// by both are committed to conntrack in the zone of the local VLAN.
+func Compile(policies []*Policy, localPorts []*LocalPort, cluster *Cluster) []Flow {
+	flows := make(map[string]Flow)
+	for _, flow := range BaseFlows() {
+		flows[flow.Key()] = flow
+	}
+	for _, localPort := range localPorts {
+		pod := cluster.getPod(localPort.PodNs, localPort.PodName)
+		if pod == nil {
+			continue
+		}
+		port := pod.getPort(localPort.PortID)
+		if port == nil {
+			continue
+		}
+		var ingressPolicies, egressPolicies []*Policy
+		for _, policy := range policies {
+			if !policy.selects(pod, port) {
+				continue
+			}
+			if policy.isIngress() {
+				ingressPolicies = append(ingressPolicies, policy)
+			}
+			if policy.isEgress() {
+				egressPolicies = append(egressPolicies, policy)
+			}
+		}
+		for _, flow := range compileEgress(localPort, port, egressPolicies, cluster) {
+			flows[flow.Key()] = flow
+		}
+		for _, flow := range compileIngress(localPort, port, ingressPolicies, cluster) {
+			flows[flow.Key()] = flow
+		}
+	}
+	return sortFlows(flows)
+}
+
+func sortFlows(flows map[string]Flow) []Flow {
+	keys := make([]string, 0, len(flows))
+	for key := range flows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]Flow, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, flows[key])
+	}
+	return sorted
+}
+
+func classifyActions(localPort *LocalPort, table int) string {
+	return fmt.Sprintf("load:%d->%s,ct(table=%d,zone=%s)", localPort.VlanID, constvalue.NetPolicyCtZoneField,
+		table, constvalue.NetPolicyCtZoneField)
+}
+
+func compileEgress(localPort *LocalPort, port *PodPort, policies []*Policy, cluster *Cluster) []Flow {
+	if len(policies) == 0 {
+		return nil
+	}
+	inPort := "in_port=" + localPort.OfPort
+	flows := []Flow{
+		{Table: constvalue.BrintPolicyClassifyTable, Priority: egressClassifyPriority, Match: "ip," + inPort,
+			Actions: classifyActions(localPort, constvalue.BrintPolicyEgressTable)},
+		{Table: constvalue.BrintPolicyEgressTable, Priority: denyPriority, Match: "ip," + inPort, Actions: "drop"},
+	}
+	allow := fmt.Sprintf("resubmit(,%d)", constvalue.BrintPolicyIngressTable)
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Egress {
+			for _, match := range compileRule(policy, port, rule.To, rule.Ports, "nw_dst", cluster) {
+				flows = append(flows, Flow{Table: constvalue.BrintPolicyEgressTable, Priority: allowPriority,
+					Match: "ct_state=+new+trk," + match + "," + inPort, Actions: allow})
+			}
+		}
+	}
+	return flows
+}
+
+func compileIngress(localPort *LocalPort, port *PodPort, policies []*Policy, cluster *Cluster) []Flow {
+	if len(policies) == 0 {
+		return nil
+	}
+	dlDst := "dl_dst=" + localPort.MAC
+	flows := []Flow{
+		{Table: constvalue.BrintPolicyClassifyTable, Priority: ingressClassifyPriority, Match: "ip," + dlDst,
+			Actions: classifyActions(localPort, constvalue.BrintPolicyIngressTable)},
+		{Table: constvalue.BrintPolicyIngressTable, Priority: denyPriority, Match: "ip," + dlDst, Actions: "drop"},
+	}
+	allow := fmt.Sprintf("resubmit(,%d)", constvalue.BrintPolicyAcceptTable)
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Ingress {
+			for _, match := range compileRule(policy, port, rule.From, rule.Ports, "nw_src", cluster) {
+				flows = append(flows, Flow{Table: constvalue.BrintPolicyIngressTable, Priority: allowPriority,
+					Match: "ct_state=+new+trk," + match + "," + dlDst, Actions: allow})
+			}
+		}
+	}
+	return flows
+}
+
+// compileRule returns the matches of the packets allowed by a rule, which
+// are the cross product of the peer addresses and the protocol ports.
+func compileRule(policy *Policy, port *PodPort, peers []networkingv1.NetworkPolicyPeer,
+	policyPorts []networkingv1.NetworkPolicyPort, nwField string, cluster *Cluster) []string {
+	protoMatches := compilePorts(policyPorts)
+	if len(peers) == 0 {
+		return protoMatches
+	}
+	matches := make([]string, 0)
+	for _, cidr := range resolvePeers(policy, port, peers, cluster) {
+		for _, protoMatch := range protoMatches {
+			matches = append(matches, protoMatch+","+nwField+"="+cidr)
+		}
+	}
+	return matches
+}
+
+// compilePorts returns "ip" for any port. Named ports are not supported,
+// they match nothing.
+func compilePorts(policyPorts []networkingv1.NetworkPolicyPort) []string {
+	if len(policyPorts) == 0 {
+		return []string{"ip"}
+	}
+	matches := make([]string, 0)
+	for _, policyPort := range policyPorts {
+		protocol := v1.ProtocolTCP
+		if policyPort.Protocol != nil {
+			protocol = *policyPort.Protocol
+		}
+		match := strings.ToLower(string(protocol))
+		if policyPort.Port != nil {
+			if policyPort.Port.Type != intstr.Int {
+				klog.Warningf("compilePorts: named port[%s] is not supported", policyPort.Port.StrVal)
+				continue
+			}
+			match += fmt.Sprintf(",tp_dst=%d", policyPort.Port.IntVal)
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// resolvePeers returns the IPv4 addresses and CIDRs of the peers.
+func resolvePeers(policy *Policy, port *PodPort, peers []networkingv1.NetworkPolicyPeer, cluster *Cluster) []string {
+	cidrs := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(cidr string) {
+		if !seen[cidr] {
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			for _, cidr := range subtractCIDRs(peer.IPBlock.CIDR, peer.IPBlock.Except) {
+				add(cidr)
+			}
+			continue
+		}
+		for _, pod := range cluster.Pods {
+			if !isPeerPod(policy, &peer, pod, cluster.NamespaceLabels) {
+				continue
+			}
+			for _, peerPort := range cluster.loadPorts(pod).Ports {
+				if !policy.isPeerPort(port, peerPort) {
+					continue
+				}
+				for _, ip := range peerPort.IPs {
+					if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+						add(ip)
+					}
+				}
+			}
+		}
+	}
+	return cidrs
+}
+
+// isPeerPod selects the pods of the namespace of policy by podSelector, the
+// pods of the namespaces selected by namespaceSelector, or the pods selected
+// by both.
+func isPeerPod(policy *Policy, peer *networkingv1.NetworkPolicyPeer, pod *PodInfo,
+	namespaceLabels map[string]map[string]string) bool {
+	if peer.NamespaceSelector == nil {
+		if pod.Namespace != policy.Namespace {
+			return false
+		}
+	} else if !matchLabelSelector(peer.NamespaceSelector, namespaceLabels[pod.Namespace]) {
+		return false
+	}
+	return peer.PodSelector == nil || matchLabelSelector(peer.PodSelector, pod.Labels)
+}
+
+// subtractCIDRs returns the IPv4 CIDRs covering cidr except the excepts.
+func subtractCIDRs(cidr string, excepts []string) []string {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil || ipNet.IP.To4() == nil {
+		klog.Warningf("subtractCIDRs: cidr[%s] is not an IPv4 CIDR", cidr)
+		return nil
+	}
+	ipNets := []*net.IPNet{{IP: ipNet.IP.To4(), Mask: ipNet.Mask}}
+	for _, except := range excepts {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err != nil || exceptNet.IP.To4() == nil {
+			klog.Warningf("subtractCIDRs: except[%s] is not an IPv4 CIDR", except)
+			continue
+		}
+		exceptNet.IP = exceptNet.IP.To4()
+		rest := make([]*net.IPNet, 0)
+		for _, ipNet := range ipNets {
+			rest = append(rest, subtractCIDR(ipNet, exceptNet)...)
+		}
+		ipNets = rest
+	}
+	cidrs := make([]string, 0, len(ipNets))
+	for _, ipNet := range ipNets {
+		cidrs = append(cidrs, ipNet.String())
+	}
+	return cidrs
+}
+
+// subtractCIDR splits ipNet into halves until the halves are out of except.
+func subtractCIDR(ipNet, except *net.IPNet) []*net.IPNet {
+	ones, bits := ipNet.Mask.Size()
+	exceptOnes, _ := except.Mask.Size()
+	if !ipNet.Contains(except.IP) && !except.Contains(ipNet.IP) {
+		return []*net.IPNet{ipNet}
+	}
+	if exceptOnes <= ones {
+		return nil
+	}
+	mask := net.CIDRMask(ones+1, bits)
+	lowIP := make(net.IP, len(ipNet.IP))
+	copy(lowIP, ipNet.IP)
+	highIP := make(net.IP, len(ipNet.IP))
+	copy(highIP, ipNet.IP)
+	highIP[ones/8] |= 0x80 >> uint(ones%8)
+	return append(subtractCIDR(&net.IPNet{IP: lowIP, Mask: mask}, except),
+		subtractCIDR(&net.IPNet{IP: highIP, Mask: mask}, except)...)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func flowsOfTable(flows []Flow, table int) []string {
+	strs := make([]string, 0)
+	for _, flow := range flows {
+		if flow.Table == table && flow.Priority < ctStatePriority && flow.Priority > 0 {
+			strs = append(strs, flow.Key()+",actions="+flow.Actions)
+		}
+	}
+	return strs
+}
+
+func TestCompile(t *testing.T) {
+	web := &PodInfo{Namespace: "ns1", Name: "web", Labels: map[string]string{"app": "web"},
+		Ports: []*PodPort{{ID: "p1", Network: "net_api", IfName: "eth0", IPs: []string{"10.0.0.2"}},
+			{ID: "p2", Network: "control", IfName: "eth1", IPs: []string{"192.168.0.2"}}}}
+	db := &PodInfo{Namespace: "ns1", Name: "db", Labels: map[string]string{"app": "db"},
+		Ports: []*PodPort{{ID: "p3", Network: "net_api", IfName: "eth0", IPs: []string{"10.0.0.3"}},
+			{ID: "p4", Network: "control", IfName: "eth1", IPs: []string{"192.168.0.3"}}}}
+	client := &PodInfo{Namespace: "ns2", Name: "client", Labels: map[string]string{"app": "client"},
+		Ports: []*PodPort{{ID: "p5", Network: "net_api", IfName: "eth0", IPs: []string{"10.0.0.4", "fd00::4"}}}}
+	cluster := &Cluster{Pods: []*PodInfo{web, db, client},
+		NamespaceLabels: map[string]map[string]string{"ns1": {"team": "a"}, "ns2": {"team": "b"}}}
+	localPorts := []*LocalPort{
+		{PortID: "p1", PodNs: "ns1", PodName: "web", MAC: "fa:16:3e:00:00:01", OfPort: "11", VlanID: 2},
+		{PortID: "p2", PodNs: "ns1", PodName: "web", MAC: "fa:16:3e:00:00:02", OfPort: "12", VlanID: 3},
+	}
+	tcp := v1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+
+	Convey("TestCompile", t, func() {
+		Convey("no policy\n", func() {
+			flows := Compile(nil, localPorts, cluster)
+			So(flows, ShouldResemble, sortFlows(func() map[string]Flow {
+				flows := make(map[string]Flow)
+				for _, flow := range BaseFlows() {
+					flows[flow.Key()] = flow
+				}
+				return flows
+			}()))
+			So(flowsOfTable(flows, 0), ShouldResemble, []string{"table=0,priority=5,ip,actions=resubmit(,70)"})
+		})
+
+		Convey("ingress from pods of namespace on eth0\n", func() {
+			np := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					Ingress: []networkingv1.NetworkPolicyIngressRule{{
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+						From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{"team": "b"}}}},
+					}},
+				}}
+			flows := Compile([]*Policy{NewPolicyFromNetworkPolicy(np)}, localPorts, cluster)
+			So(flowsOfTable(flows, 70), ShouldResemble, []string{
+				"table=70,priority=40,ip,dl_dst=fa:16:3e:00:00:01," +
+					"actions=load:2->NXM_NX_REG6[0..15],ct(table=72,zone=NXM_NX_REG6[0..15])"})
+			So(flowsOfTable(flows, 71), ShouldBeEmpty)
+			So(flowsOfTable(flows, 72), ShouldResemble, []string{
+				"table=72,priority=20,ip,dl_dst=fa:16:3e:00:00:01,actions=drop",
+				"table=72,priority=30,ct_state=+new+trk,tcp,tp_dst=80,nw_src=10.0.0.4,dl_dst=fa:16:3e:00:00:01," +
+					"actions=resubmit(,73)"})
+		})
+
+		Convey("egress to ipBlock with except and deny all ingress on network\n", func() {
+			knp := &KnitterNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "knp"},
+				Spec: KnitterNetworkPolicySpec{Networks: []string{"control"}, NetworkPolicySpec: networkingv1.NetworkPolicySpec{
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+					Egress: []networkingv1.NetworkPolicyEgressRule{{
+						To: []networkingv1.NetworkPolicyPeer{
+							{IPBlock: &networkingv1.IPBlock{CIDR: "192.168.0.0/24", Except: []string{"192.168.0.0/25"}}},
+							{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+						},
+					}},
+				}}}
+			flows := Compile([]*Policy{NewPolicyFromKnitterNetworkPolicy(knp)}, localPorts, cluster)
+			So(flowsOfTable(flows, 70), ShouldResemble, []string{
+				"table=70,priority=40,ip,dl_dst=fa:16:3e:00:00:02," +
+					"actions=load:3->NXM_NX_REG6[0..15],ct(table=72,zone=NXM_NX_REG6[0..15])",
+				"table=70,priority=50,ip,in_port=12," +
+					"actions=load:3->NXM_NX_REG6[0..15],ct(table=71,zone=NXM_NX_REG6[0..15])"})
+			So(flowsOfTable(flows, 71), ShouldResemble, []string{
+				"table=71,priority=20,ip,in_port=12,actions=drop",
+				"table=71,priority=30,ct_state=+new+trk,ip,nw_dst=192.168.0.128/25,in_port=12,actions=resubmit(,72)",
+				"table=71,priority=30,ct_state=+new+trk,ip,nw_dst=192.168.0.3,in_port=12,actions=resubmit(,72)"})
+			So(flowsOfTable(flows, 72), ShouldResemble, []string{
+				"table=72,priority=20,ip,dl_dst=fa:16:3e:00:00:02,actions=drop"})
+		})
+
+		Convey("pods of other namespace and other interfaces are not selected\n", func() {
+			np := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "ns2", Name: "np"}}
+			flows := Compile([]*Policy{NewPolicyFromNetworkPolicy(np)}, localPorts, cluster)
+			So(flowsOfTable(flows, 70), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestSubtractCIDRs(t *testing.T) {
+	Convey("TestSubtractCIDRs", t, func() {
+		So(subtractCIDRs("10.0.0.0/8", nil), ShouldResemble, []string{"10.0.0.0/8"})
+		So(subtractCIDRs("10.0.0.0/30", []string{"10.0.0.1/32"}), ShouldResemble,
+			[]string{"10.0.0.0/32", "10.0.0.2/31"})
+		So(subtractCIDRs("10.0.0.0/24", []string{"10.0.0.0/16"}), ShouldBeEmpty)
+		So(subtractCIDRs("fd00::/64", nil), ShouldBeNil)
+	})
+}
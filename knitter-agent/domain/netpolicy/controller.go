@@ -0,0 +1,222 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// controller watches the policies, pods and namespaces, and compiles them
+// into the policy flows of br-int whenever one of them changes.
+type controller struct {
+	enabled    bool
+	trigger    chan struct{}
+	syncLock   sync.Mutex
+	programmer flowProgrammer
+
+	npStore  cache.Store
+	knpStore cache.Store
+	podStore cache.Store
+	nsStore  cache.Store
+	// the policies are enforced once the core informers are synced, the
+	// KnitterNetworkPolicy is not waited for
+	coreInformers []cache.Controller
+	knpInformer   cache.Controller
+
+	portsLock sync.Mutex
+	podPorts  map[string][]*PodPort
+}
+
+var netPolicyController *controller
+
+// Start installs the base flows of the policy tables and watches the
+// policies if network policy is enabled.
+func Start(enabled bool) error {
+	ctrl := &controller{enabled: enabled, trigger: make(chan struct{}, 1), podPorts: make(map[string][]*PodPort)}
+	netPolicyController = ctrl
+	if !enabled {
+		return ctrl.sync(true)
+	}
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: cni.GetGlobalContext().K8s.ServerURL})
+	if err != nil {
+		klog.Errorf("netpolicy.Start: kubernetes.NewForConfig error: %v", err)
+		return err
+	}
+	var npInformer, podInformer, nsInformer cache.Controller
+	ctrl.npStore, npInformer = ctrl.newInformer(cache.NewListWatchFromClient(clientSet.NetworkingV1().RESTClient(),
+		"networkpolicies", v1.NamespaceAll, fields.Everything()), &networkingv1.NetworkPolicy{}, ctrl.onChange)
+	ctrl.knpStore, ctrl.knpInformer = ctrl.newInformer(newKnitterNetworkPolicyListWatch(clientSet.CoreV1().RESTClient()),
+		&KnitterNetworkPolicy{}, ctrl.onChange)
+	ctrl.podStore, podInformer = ctrl.newInformer(cache.NewListWatchFromClient(clientSet.CoreV1().RESTClient(),
+		"pods", v1.NamespaceAll, fields.Everything()), &v1.Pod{}, ctrl.onPodChange)
+	ctrl.nsStore, nsInformer = ctrl.newInformer(cache.NewListWatchFromClient(clientSet.CoreV1().RESTClient(),
+		"namespaces", v1.NamespaceAll, fields.Everything()), &v1.Namespace{}, ctrl.onChange)
+	ctrl.coreInformers = []cache.Controller{npInformer, podInformer, nsInformer}
+
+	for _, informer := range append(ctrl.coreInformers, ctrl.knpInformer) {
+		go informer.Run(make(chan struct{}))
+	}
+	go ctrl.worker()
+	// the pipeline is passed through until the informers are synced
+	return ctrl.programmer.Sync(BaseFlows(), true)
+}
+
+// Resync compiles all the policies again and adds all the flows again, it
+// is called periodically as the flows are lost when ovs-vswitchd restarts.
+func Resync() {
+	if netPolicyController == nil {
+		return
+	}
+	err := netPolicyController.sync(true)
+	if err != nil {
+		klog.Warningf("netpolicy.Resync: sync error: %v", err)
+	}
+}
+
+func (self *controller) newInformer(lw cache.ListerWatcher, objType runtime.Object,
+	onChange func(oldObj, newObj interface{})) (cache.Store, cache.Controller) {
+	return cache.NewInformer(lw, objType, time.Second*0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { onChange(nil, obj) },
+		UpdateFunc: onChange,
+		DeleteFunc: func(obj interface{}) { onChange(obj, nil) },
+	})
+}
+
+func (self *controller) onChange(oldObj, newObj interface{}) {
+	self.notify()
+}
+
+// onPodChange forgets the ports of the pod, they are changed by hot-plug
+// with the annotation of the pod.
+func (self *controller) onPodChange(oldObj, newObj interface{}) {
+	for _, obj := range []interface{}{oldObj, newObj} {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pod, ok := obj.(*v1.Pod); ok {
+			self.portsLock.Lock()
+			delete(self.podPorts, pod.Namespace+"/"+pod.Name)
+			self.portsLock.Unlock()
+		}
+	}
+	self.notify()
+}
+
+func (self *controller) notify() {
+	select {
+	case self.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (self *controller) worker() {
+	for range self.trigger {
+		err := self.sync(false)
+		if err != nil {
+			klog.Warningf("netpolicy.worker: sync error: %v", err)
+		}
+	}
+}
+
+// hasSynced tells if the NetworkPolicy, pods and namespaces are synced, the
+// KnitterNetworkPolicy is added to the flows as soon as it is synced, so the
+// NetworkPolicy is enforced even if the CRD can not be listed.
+func (self *controller) hasSynced() bool {
+	for _, informer := range self.coreInformers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *controller) loadPorts(pod *PodInfo) {
+	key := pod.Namespace + "/" + pod.Name
+	self.portsLock.Lock()
+	ports, ok := self.podPorts[key]
+	self.portsLock.Unlock()
+	if !ok {
+		var err error
+		ports, err = getPodPorts(pod.Namespace, pod.Name)
+		if err != nil {
+			klog.Warningf("netpolicy.loadPorts: getPodPorts(%s) error: %v", key, err)
+			pod.Ports = []*PodPort{}
+			return
+		}
+		self.portsLock.Lock()
+		self.podPorts[key] = ports
+		self.portsLock.Unlock()
+	}
+	pod.Ports = ports
+}
+
+func (self *controller) listPolicies() []*Policy {
+	policies := make([]*Policy, 0)
+	for _, obj := range self.npStore.List() {
+		if np, ok := obj.(*networkingv1.NetworkPolicy); ok {
+			policies = append(policies, NewPolicyFromNetworkPolicy(np))
+		}
+	}
+	for _, obj := range self.knpStore.List() {
+		if knp, ok := obj.(*KnitterNetworkPolicy); ok {
+			policies = append(policies, NewPolicyFromKnitterNetworkPolicy(knp))
+		}
+	}
+	return policies
+}
+
+func (self *controller) getCluster() *Cluster {
+	cluster := &Cluster{NamespaceLabels: make(map[string]map[string]string), LoadPorts: self.loadPorts}
+	for _, obj := range self.podStore.List() {
+		if pod, ok := obj.(*v1.Pod); ok && pod.DeletionTimestamp == nil {
+			cluster.Pods = append(cluster.Pods, &PodInfo{Namespace: pod.Namespace, Name: pod.Name, Labels: pod.Labels})
+		}
+	}
+	for _, obj := range self.nsStore.List() {
+		if ns, ok := obj.(*v1.Namespace); ok {
+			cluster.NamespaceLabels[ns.Name] = ns.Labels
+		}
+	}
+	return cluster
+}
+
+func (self *controller) sync(full bool) error {
+	self.syncLock.Lock()
+	defer self.syncLock.Unlock()
+	if !self.enabled {
+		return self.programmer.Sync(BaseFlows(), full)
+	}
+	if !self.hasSynced() {
+		return nil
+	}
+	localPorts, err := listLocalPorts()
+	if err != nil {
+		return fmt.Errorf("%v:listLocalPorts error", err)
+	}
+	return self.programmer.Sync(Compile(self.listPolicies(), localPorts, self.getCluster()), full)
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const (
+	KnitterNetworkPolicyAPIPath  = "/apis/knitter.io/v1"
+	KnitterNetworkPolicyResource = "knitternetworkpolicies"
+)
+
+// crdRecheckInterval is how long the KnitterNetworkPolicy is not watched
+// before it is listed again, when its CRD is not created in the cluster.
+var crdRecheckInterval = time.Minute
+
+// KnitterNetworkPolicy is the network policy of the secondary networks, its
+// spec is the spec of NetworkPolicy with the knitter networks it applies to.
+type KnitterNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KnitterNetworkPolicySpec `json:"spec"`
+}
+
+type KnitterNetworkPolicySpec struct {
+	Networks                       []string `json:"networks"`
+	networkingv1.NetworkPolicySpec `json:",inline"`
+}
+
+type KnitterNetworkPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []KnitterNetworkPolicy `json:"items"`
+}
+
+func (in *KnitterNetworkPolicy) DeepCopyInto(out *KnitterNetworkPolicy) {
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Spec.Networks != nil {
+		out.Spec.Networks = make([]string, len(in.Spec.Networks))
+		copy(out.Spec.Networks, in.Spec.Networks)
+	}
+	in.Spec.NetworkPolicySpec.DeepCopyInto(&out.Spec.NetworkPolicySpec)
+}
+
+func (in *KnitterNetworkPolicy) DeepCopyObject() runtime.Object {
+	out := &KnitterNetworkPolicy{}
+	in.DeepCopyInto(out)
+	return out
+}
+
+func (in *KnitterNetworkPolicyList) DeepCopyObject() runtime.Object {
+	out := &KnitterNetworkPolicyList{TypeMeta: in.TypeMeta}
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]KnitterNetworkPolicy, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+	return out
+}
+
+// knitterNetworkPolicyDecoder decodes the watch events of the CRD, which is
+// not registered in the scheme of client-go.
+type knitterNetworkPolicyDecoder struct {
+	decoder *json.Decoder
+	closer  io.Closer
+}
+
+func (d *knitterNetworkPolicyDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	var event struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+	err := d.decoder.Decode(&event)
+	if err != nil {
+		return "", nil, err
+	}
+	if event.Type == watch.Error {
+		status := &metav1.Status{}
+		err = json.Unmarshal(event.Object, status)
+		return event.Type, status, err
+	}
+	knp := &KnitterNetworkPolicy{}
+	err = json.Unmarshal(event.Object, knp)
+	if err != nil {
+		klog.Errorf("knitterNetworkPolicyDecoder: json.Unmarshal(%s) error: %v", string(event.Object), err)
+		return "", nil, err
+	}
+	return event.Type, knp, nil
+}
+
+func (d *knitterNetworkPolicyDecoder) Close() {
+	d.closer.Close()
+}
+
+// newKnitterNetworkPolicyListWatch lists and watches the KnitterNetworkPolicy,
+// a missing CRD is taken as no KnitterNetworkPolicy, and it is listed again
+// every crdRecheckInterval until the CRD is created.
+func newKnitterNetworkPolicyListWatch(restClient rest.Interface) *cache.ListWatch {
+	crdMissing := false
+	request := func(options metav1.ListOptions) *rest.Request {
+		req := restClient.Get().AbsPath(KnitterNetworkPolicyAPIPath, KnitterNetworkPolicyResource).
+			Param("resourceVersion", options.ResourceVersion)
+		if options.TimeoutSeconds != nil {
+			req = req.Param("timeoutSeconds", strconv.FormatInt(*options.TimeoutSeconds, 10))
+		}
+		return req
+	}
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			body, err := request(options).Do().Raw()
+			if apierrors.IsNotFound(err) {
+				if !crdMissing {
+					klog.Warningf("newKnitterNetworkPolicyListWatch: CRD %s.knitter.io not found, "+
+						"no KnitterNetworkPolicy is enforced until it is created", KnitterNetworkPolicyResource)
+				}
+				crdMissing = true
+				return &KnitterNetworkPolicyList{}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if crdMissing {
+				klog.Infof("newKnitterNetworkPolicyListWatch: CRD %s.knitter.io found", KnitterNetworkPolicyResource)
+			}
+			crdMissing = false
+			list := &KnitterNetworkPolicyList{}
+			err = json.Unmarshal(body, list)
+			if err != nil {
+				klog.Errorf("newKnitterNetworkPolicyListWatch: json.Unmarshal(%s) error: %v", string(body), err)
+				return nil, err
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			if crdMissing {
+				idle := watch.NewFake()
+				time.AfterFunc(crdRecheckInterval, idle.Stop)
+				return idle, nil
+			}
+			stream, err := request(options).Param("watch", "true").Stream()
+			if err != nil {
+				return nil, err
+			}
+			return watch.NewStreamWatcher(&knitterNetworkPolicyDecoder{decoder: json.NewDecoder(stream),
+				closer: stream}), nil
+		},
+	}
+}
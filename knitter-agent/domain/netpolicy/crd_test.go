@@ -0,0 +1,71 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKnitterNetworkPolicyListWatch(t *testing.T) {
+	crdCreated := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !crdCreated {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"kind":"KnitterNetworkPolicyList","metadata":{"resourceVersion":"10"},` +
+			`"items":[{"metadata":{"name":"knp1","namespace":"ns1"},"spec":{"networks":["net1"]}}]}`))
+	}))
+	defer server.Close()
+	stubs := gostub.Stub(&crdRecheckInterval, time.Millisecond*10)
+	defer stubs.Reset()
+
+	Convey("TestKnitterNetworkPolicyListWatch", t, func() {
+		clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		So(err, ShouldBeNil)
+		lw := newKnitterNetworkPolicyListWatch(clientSet.CoreV1().RESTClient())
+
+		Convey("missing CRD is taken as no KnitterNetworkPolicy\n", func() {
+			crdCreated = false
+			list, err := lw.List(metav1.ListOptions{})
+			So(err, ShouldBeNil)
+			So(list.(*KnitterNetworkPolicyList).Items, ShouldBeEmpty)
+
+			watcher, err := lw.Watch(metav1.ListOptions{})
+			So(err, ShouldBeNil)
+			_, open := <-watcher.ResultChan()
+			So(open, ShouldBeFalse)
+		})
+
+		Convey("policies are listed once the CRD is created\n", func() {
+			crdCreated = false
+			lw.List(metav1.ListOptions{})
+			crdCreated = true
+			list, err := lw.List(metav1.ListOptions{})
+			So(err, ShouldBeNil)
+			So(len(list.(*KnitterNetworkPolicyList).Items), ShouldEqual, 1)
+			So(list.(*KnitterNetworkPolicyList).Items[0].Spec.Networks, ShouldResemble, []string{"net1"})
+		})
+	})
+}
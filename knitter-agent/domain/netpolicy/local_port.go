@@ -0,0 +1,140 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/domain/role/port-role"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// getOvsColumn maps the names of the rows of the table to the integer
+// column, a column of no value is "[]" as ovs-vsctl prints it.
+func getOvsColumn(table, column string) (map[string]string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		values := make(map[string]string)
+		for _, row := range client.ListRows(table) {
+			value := "[]"
+			if i, ok := row.Int(column); ok {
+				value = strconv.FormatInt(i, 10)
+			}
+			values[row.String("name")] = value
+		}
+		return values, nil
+	}
+	output, err := osencap.Exec(constvalue.OvsVsctl, "--format=csv", "--no-headings",
+		"--columns=name,"+column, "list", table)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), ",", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		values[strings.Trim(fields[0], `"`)] = strings.Trim(fields[1], `"`)
+	}
+	return values, nil
+}
+
+// listLocalPorts lists the ports of the pods on this node whose veths are
+// attached to br-int, the bond and vhost-user ports are not included.
+var listLocalPorts = func() ([]*LocalPort, error) {
+	agtCtx := cni.GetGlobalContext()
+	nodes, err := agtCtx.DB.ReadDir(dbaccessor.GetKeyOfPaasInterfacesForNode(agtCtx.ClusterID, agtCtx.HostIP))
+	if err != nil {
+		if errobj.IsKeyNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ofPorts, err := getOvsColumn("Interface", "ofport")
+	if err != nil {
+		return nil, fmt.Errorf("%v:get ofport of interfaces error", err)
+	}
+	vlanIDs, err := getOvsColumn("Port", "tag")
+	if err != nil {
+		return nil, fmt.Errorf("%v:get tag of ports error", err)
+	}
+
+	localPorts := make([]*LocalPort, 0)
+	for _, node := range nodes {
+		value, err := agtCtx.DB.ReadLeaf(node.Value)
+		if err != nil {
+			klog.Warningf("listLocalPorts: ReadLeaf(%s) error: %v", node.Value, err)
+			continue
+		}
+		port := iaasaccessor.Interface{}
+		err = json.Unmarshal([]byte(value), &port)
+		if err != nil {
+			klog.Warningf("listLocalPorts: json.Unmarshal(%s) error: %v", value, err)
+			continue
+		}
+		vethName, err := portrole.GetPortTableSingleton().Get(port.Id)
+		if err != nil {
+			continue
+		}
+		ofPort := ofPorts[vethName]
+		if ofPort == "" || ofPort == "-1" || ofPort == "[]" {
+			continue
+		}
+		vlanID, _ := strconv.Atoi(vlanIDs[vethName])
+		localPorts = append(localPorts, &LocalPort{PortID: port.Id, PodNs: port.PodNs, PodName: port.PodName,
+			MAC: port.MacAddress, OfPort: ofPort, VlanID: vlanID})
+	}
+	return localPorts, nil
+}
+
+// getPodPorts gets the ports of pod from knitter-monitor, no port is
+// returned for the pod unknown to knitter-monitor.
+var getPodPorts = func(podNs, podName string) ([]*PodPort, error) {
+	statusCode, body, err := cni.GetGlobalContext().MtrC.GetPod(podNs, podName)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode == http.StatusNotFound {
+		return []*PodPort{}, nil
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("get pod from knitter-monitor return statuscode %d", statusCode)
+	}
+	pod := &monitor.Pod{}
+	err = json.Unmarshal(body, pod)
+	if err != nil {
+		return nil, err
+	}
+	podPorts := make([]*PodPort, 0, len(pod.Ports))
+	for _, port := range pod.Ports {
+		podPort := &PodPort{ID: port.LazyAttr.ID, Network: port.EagerAttr.NetworkName, IfName: port.EagerAttr.PortName}
+		for _, fixedIP := range port.LazyAttr.FixedIps {
+			podPort.IPs = append(podPort.IPs, fixedIP.IPAddress)
+		}
+		podPorts = append(podPorts, podPort)
+	}
+	return podPorts, nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb/ovsdbtest"
+)
+
+func TestGetOvsColumnWithOvsdb(t *testing.T) {
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+	defer server.Close()
+	client, err := ovsdb.Dial(server.Endpoint())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer client.Close()
+	stubs := gostub.StubFunc(&ovs.GetOvsdbClient, client)
+	defer stubs.Reset()
+
+	Convey("TestGetOvsColumnWithOvsdb", t, func() {
+		So(client.AddBridge("br-int", nil), ShouldBeNil)
+		So(client.AddPort("br-int", "veth1", false, ovsdb.Row{"tag": int64(3)}, ovsdb.Row{"ofport_request": int64(5)}),
+			ShouldBeNil)
+		So(client.AddPort("br-int", "veth2", false, nil, ovsdb.Row{"ofport_request": int64(6)}), ShouldBeNil)
+
+		ofPorts, err := getOvsColumn("Interface", "ofport")
+		So(err, ShouldBeNil)
+		So(ofPorts, ShouldResemble, map[string]string{"br-int": "65534", "veth1": "5", "veth2": "6"})
+		vlanIDs, err := getOvsColumn("Port", "tag")
+		So(err, ShouldBeNil)
+		So(vlanIDs, ShouldResemble, map[string]string{"br-int": "[]", "veth1": "3", "veth2": "[]"})
+		So(client.DelBridge("br-int", false), ShouldBeNil)
+	})
+}
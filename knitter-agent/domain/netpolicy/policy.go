@@ -0,0 +1,102 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// Policy is a NetworkPolicy of kubernetes or a KnitterNetworkPolicy. The
+// NetworkPolicy applies to the default interface eth0 of pods, and the
+// KnitterNetworkPolicy applies to the interfaces on its networks.
+type Policy struct {
+	Namespace string
+	Name      string
+	Networks  []string
+	Spec      networkingv1.NetworkPolicySpec
+}
+
+func NewPolicyFromNetworkPolicy(np *networkingv1.NetworkPolicy) *Policy {
+	return &Policy{Namespace: np.Namespace, Name: np.Name, Spec: np.Spec}
+}
+
+func NewPolicyFromKnitterNetworkPolicy(knp *KnitterNetworkPolicy) *Policy {
+	return &Policy{Namespace: knp.Namespace, Name: knp.Name, Networks: knp.Spec.Networks,
+		Spec: knp.Spec.NetworkPolicySpec}
+}
+
+func (p *Policy) hasPolicyType(policyType networkingv1.PolicyType) bool {
+	for _, t := range p.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// isIngress and isEgress follow the defaults of policyTypes: ingress is
+// always isolated, egress only if the policy has egress rules.
+func (p *Policy) isIngress() bool {
+	return len(p.Spec.PolicyTypes) == 0 || p.hasPolicyType(networkingv1.PolicyTypeIngress)
+}
+
+func (p *Policy) isEgress() bool {
+	if len(p.Spec.PolicyTypes) == 0 {
+		return len(p.Spec.Egress) != 0
+	}
+	return p.hasPolicyType(networkingv1.PolicyTypeEgress)
+}
+
+// isPolicyPort tells whether the interface of pod is governed by the policy.
+func (p *Policy) isPolicyPort(port *PodPort) bool {
+	if p.Networks == nil {
+		return port.IfName == constvalue.DefaultPortName
+	}
+	for _, network := range p.Networks {
+		if port.Network == network {
+			return true
+		}
+	}
+	return false
+}
+
+// isPeerPort tells whether the interface of a peer pod talks with the local
+// port under the policy: the default interface for NetworkPolicy, or the
+// interface on the same network for KnitterNetworkPolicy.
+func (p *Policy) isPeerPort(local, peer *PodPort) bool {
+	if p.Networks == nil {
+		return peer.IfName == constvalue.DefaultPortName
+	}
+	return peer.Network == local.Network
+}
+
+func (p *Policy) selects(pod *PodInfo, port *PodPort) bool {
+	return pod.Namespace == p.Namespace && p.isPolicyPort(port) &&
+		matchLabelSelector(&p.Spec.PodSelector, pod.Labels)
+}
+
+// matchLabelSelector matches nothing if the selector is illegal.
+func matchLabelSelector(labelSelector *metav1.LabelSelector, podLabels map[string]string) bool {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		klog.Warningf("matchLabelSelector: label selector[%v] is illegal: %v", labelSelector, err)
+		return false
+	}
+	return selector.Matches(labels.Set(podLabels))
+}
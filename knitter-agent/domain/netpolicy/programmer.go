@@ -0,0 +1,91 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// flowProgrammer keeps the policy flows applied to br-int, and applies the
+// difference to the wanted flows by one ovs-ofctl add-flows of flow_mod
+// commands. All the policy flows are replaced on the first sync, as the
+// flows applied by the last run of agent are unknown.
+type flowProgrammer struct {
+	applied map[string]Flow
+}
+
+// execFlowMods is stubbed in tests.
+var execFlowMods = func(bridge string, flowMods []string) error {
+	file, err := ioutil.TempFile("", "knitter-flows")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(strings.Join(flowMods, "\n") + "\n")
+	file.Close()
+	if err != nil {
+		return err
+	}
+	_, err = osencap.Exec(constvalue.OvsOfctl, "add-flows", bridge, file.Name())
+	return err
+}
+
+// Sync adds all the flows again if full is true, the flows are lost when
+// ovs-vswitchd restarts.
+func (self *flowProgrammer) Sync(flows []Flow, full bool) error {
+	wanted := make(map[string]Flow, len(flows))
+	for _, flow := range flows {
+		wanted[flow.Key()] = flow
+	}
+	flowMods := make([]string, 0)
+	if self.applied == nil {
+		flowMods = append(flowMods, "delete cookie="+constvalue.NetPolicyFlowCookie+"/-1")
+	}
+	deleted := make([]string, 0)
+	for key := range self.applied {
+		if _, ok := wanted[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Strings(deleted)
+	for _, key := range deleted {
+		flowMods = append(flowMods, "delete_strict "+key)
+	}
+	for _, flow := range flows {
+		applied, ok := self.applied[flow.Key()]
+		if full || !ok || applied.Actions != flow.Actions {
+			flowMods = append(flowMods, "add "+flow.String())
+		}
+	}
+	if len(flowMods) == 0 {
+		return nil
+	}
+	err := execFlowMods(constvalue.OvsBrint, flowMods)
+	if err != nil {
+		klog.Errorf("flowProgrammer.Sync: apply %d flow mods error: %v", len(flowMods), err)
+		// replace all of them next time
+		self.applied = nil
+		return err
+	}
+	klog.Infof("flowProgrammer.Sync: apply %d flow mods SUCC, %d policy flows", len(flowMods), len(wanted))
+	self.applied = wanted
+	return nil
+}
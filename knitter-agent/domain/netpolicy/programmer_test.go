@@ -0,0 +1,68 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netpolicy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFlowProgrammer_Sync(t *testing.T) {
+	var flowMods []string
+	var execErr error
+	stubs := gostub.Stub(&execFlowMods, func(bridge string, mods []string) error {
+		flowMods = mods
+		return execErr
+	})
+	defer stubs.Reset()
+
+	flow1 := Flow{Table: 71, Priority: 20, Match: "ip,in_port=1", Actions: "drop"}
+	flow2 := Flow{Table: 72, Priority: 20, Match: "ip,dl_dst=fa:16:3e:00:00:01", Actions: "drop"}
+
+	Convey("TestFlowProgrammer_Sync", t, func() {
+		programmer := &flowProgrammer{}
+		execErr = nil
+		So(programmer.Sync([]Flow{flow1}, false), ShouldBeNil)
+		So(flowMods, ShouldResemble, []string{"delete cookie=0x4b50/-1",
+			"add cookie=0x4b50,table=71,priority=20,ip,in_port=1,actions=drop"})
+
+		Convey("only the difference is applied\n", func() {
+			flowMods = nil
+			So(programmer.Sync([]Flow{flow1}, false), ShouldBeNil)
+			So(flowMods, ShouldBeNil)
+
+			flow1.Actions = "NORMAL"
+			So(programmer.Sync([]Flow{flow1, flow2}, false), ShouldBeNil)
+			So(flowMods, ShouldResemble, []string{
+				"add cookie=0x4b50,table=71,priority=20,ip,in_port=1,actions=NORMAL",
+				"add cookie=0x4b50,table=72,priority=20,ip,dl_dst=fa:16:3e:00:00:01,actions=drop"})
+
+			So(programmer.Sync([]Flow{flow2}, true), ShouldBeNil)
+			So(flowMods, ShouldResemble, []string{"delete_strict table=71,priority=20,ip,in_port=1",
+				"add cookie=0x4b50,table=72,priority=20,ip,dl_dst=fa:16:3e:00:00:01,actions=drop"})
+			flow1.Actions = "drop"
+		})
+
+		Convey("all flows are replaced after failure\n", func() {
+			execErr = errors.New("ovs-ofctl error")
+			So(programmer.Sync([]Flow{flow2}, false), ShouldNotBeNil)
+			execErr = nil
+			So(programmer.Sync([]Flow{flow2}, false), ShouldBeNil)
+			So(flowMods[0], ShouldEqual, "delete cookie=0x4b50/-1")
+		})
+	})
+}
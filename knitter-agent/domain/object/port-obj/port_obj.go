@@ -34,6 +34,8 @@ type PortEagerAttr struct {
 	FixIP        string
 	IPGroupName  string
 	Metadata     interface{}
+	Bond         *monitor.PortBond
+	Qos          *monitor.PortQos
 }
 
 type PortLazyAttr struct {
@@ -66,6 +68,7 @@ type NetworkAttrs struct {
 	Description string                         `json:"description"`
 	SubnetID    string                         `json:"subnet_id"`
 	Provider    iaasaccessor.NetworkExtenAttrs `json:"provider"`
+	Mtu         int                            `json:"mtu,omitempty"`
 }
 
 type PfLinkResponse struct {
@@ -119,6 +122,8 @@ func CreatePortObj(podNs, podName string, port *monitor.Port) (*PortObj, error)
 	portObj.EagerAttr.Accelerate = portObj.BuildPortRole.Accelerate
 	portObj.EagerAttr.FixIP = portObj.BuildPortRole.FixIP
 	portObj.EagerAttr.IPGroupName = portObj.BuildPortRole.IPGroupName
+	portObj.EagerAttr.Bond = portObj.BuildPortRole.Bond
+	portObj.EagerAttr.Qos = portObj.BuildPortRole.Qos
 	portObj.EagerAttr.PodNs = podNs
 	portObj.EagerAttr.PodName = podName
 	portObj.LazyAttr.ID = port.LazyAttr.ID
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovs
+
+import (
+	"strings"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// GetBridgeDatapathType returns "netdev" for bridges of the userspace (DPDK)
+// datapath, and "system" or "" for bridges of the kernel datapath.
+var GetBridgeDatapathType = func(bridge string) (string, error) {
+	if client := GetOvsdbClient(); client != nil {
+		bridgeRow, ok := client.GetBridge(bridge)
+		if !ok {
+			klog.Errorf("GetBridgeDatapathType: bridge[%s] not found", bridge)
+			return "", ovsdb.ErrNoBridge
+		}
+		return bridgeRow.String("datapath_type"), nil
+	}
+	output, err := osencap.Exec(constvalue.OvsVsctl, "get", "Bridge", bridge, "datapath_type")
+	if err != nil {
+		klog.Errorf("GetBridgeDatapathType: get datapath_type of bridge[%s] error: %v", bridge, err)
+		return "", err
+	}
+	return strings.Trim(strings.TrimSpace(output), "\""), nil
+}
+
+// IsDpdkPhysnet tells whether the physnet is mapped to an OVS bridge of the
+// netdev datapath in bridge_mappings.
+func IsDpdkPhysnet(phyNw, confPath string) bool {
+	bridge, err := GetOvsBrg(phyNw, confPath)
+	if err != nil {
+		return false
+	}
+	datapathType, err := GetBridgeDatapathType(bridge)
+	if err != nil {
+		return false
+	}
+	return datapathType == constvalue.OvsDatapathNetdev
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovs
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+)
+
+func TestIsDpdkPhysnet(t *testing.T) {
+	datapathTypes := map[string]string{"br-phy1": "netdev", "br-phy2": ""}
+	var bridges []string
+	stubs := Stub(&osencap.Exec, func(cmd string, args ...string) (string, error) {
+		bridge := args[2]
+		bridges = append(bridges, bridge)
+		datapathType, ok := datapathTypes[bridge]
+		if !ok {
+			return "", errors.New("no bridge named " + bridge)
+		}
+		return "\"" + datapathType + "\"\n", nil
+	})
+	defer stubs.Reset()
+
+	Convey("TestIsDpdkPhysnet\n", t, func() {
+		So(IsDpdkPhysnet("physnet1", "ovsTestOK.conf"), ShouldBeTrue)
+		So(IsDpdkPhysnet("physnet2", "ovsTestOK.conf"), ShouldBeFalse)
+		So(IsDpdkPhysnet("physnet3", "ovsTestOK.conf"), ShouldBeFalse)
+		So(bridges, ShouldResemble, []string{"br-phy1", "br-phy2"})
+
+		delete(datapathTypes, "br-phy1")
+		So(IsDpdkPhysnet("physnet1", "ovsTestOK.conf"), ShouldBeFalse)
+	})
+}
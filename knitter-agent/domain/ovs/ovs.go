@@ -19,6 +19,7 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
 	"github.com/ZTE/Knitter/knitter-agent/err-obj"
 	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/go-ini/ini"
 	"github.com/vishvananda/netlink"
@@ -380,7 +381,12 @@ func addOvsBr(brName string) error {
 	retryInterval := constvalue.MaxRetryIntervalOfOvsOp
 	ovsctlArgs := []string{"--if-exists", "del-br", brName, "--", "add-br", brName}
 	for i := 0; i < maxRetryTimes; i++ {
-		_, err := osencap.Exec(constvalue.OvsVsctl, ovsctlArgs...)
+		var err error
+		if client := connectOvsdbClient(); client != nil {
+			err = client.ReplaceBridge(brName, nil)
+		} else {
+			_, err = osencap.Exec(constvalue.OvsVsctl, ovsctlArgs...)
+		}
 		if err != nil {
 			klog.Errorf("ovsctrl add-br %s [%d] times failed, total retry %d times!", brName, i, maxRetryTimes)
 			TimeSleepFunc(time.Duration(retryInterval) * time.Second)
@@ -396,7 +402,12 @@ func delOvsBr(brName string) error {
 	retryInterval := constvalue.MaxRetryIntervalOfOvsOp
 	ovsctlArgs := []string{"del-br", brName}
 	for i := 0; i < maxRetryTimes; i++ {
-		_, err := osencap.Exec(constvalue.OvsVsctl, ovsctlArgs...)
+		var err error
+		if client := connectOvsdbClient(); client != nil {
+			err = client.DelBridge(brName, false)
+		} else {
+			_, err = osencap.Exec(constvalue.OvsVsctl, ovsctlArgs...)
+		}
 		if err != nil {
 			klog.Errorf("ovsctrl del-br %s [%d] times failed, total retry %d times!", brName, i, maxRetryTimes)
 			TimeSleepFunc(time.Duration(retryInterval) * time.Second)
@@ -457,6 +468,18 @@ func WaitOvsUsable() error {
 }
 
 func checkOvsPort(br, port string) (string, error) {
+	if client := connectOvsdbClient(); client != nil {
+		err := client.AddPort(br, port, true, nil, ovsdb.Row{"options": ovsdb.OvsMap{"df_default": "false"}})
+		if err != nil {
+			klog.Errorf("checkOvsPort: add port: %s FAIL, error: %v", port, err)
+			return "", err
+		}
+		err = client.DelPort("", port, true)
+		if err != nil {
+			klog.Errorf("checkOvsPort: del port: %s FAIL, error: %v", port, err)
+		}
+		return "", err
+	}
 	argsAdd := []string{"--if-exists", "del-port", port,
 		"--", "add-port", br, port,
 		"--", "set", "Interface", port, "options:df_default=false"}
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovs
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+var ovsdbClient *ovsdb.Client
+
+// ConnectOvsdb connects to ovsdb-server if not connected, the bridges and
+// ports are read and changed by OVSDB transactions instead of ovs-vsctl since
+// then.
+func ConnectOvsdb() error {
+	if ovsdbClient != nil {
+		return nil
+	}
+	client, err := ovsdb.Dial(constvalue.OvsdbEndpoint)
+	if err != nil {
+		klog.Errorf("ConnectOvsdb: ovsdb.Dial(%s) error: %v", constvalue.OvsdbEndpoint, err)
+		return err
+	}
+	ovsdbClient = client
+	return nil
+}
+
+// GetOvsdbClient returns nil if not connected, the callers exec ovs-vsctl
+// instead.
+var GetOvsdbClient = func() *ovsdb.Client {
+	return ovsdbClient
+}
+
+// connectOvsdbClient is used while waiting for Open vSwitch, so it is probed
+// by OVSDB transactions as soon as ovsdb-server is up.
+func connectOvsdbClient() *ovsdb.Client {
+	if client := GetOvsdbClient(); client != nil {
+		return client
+	}
+	if ConnectOvsdb() != nil {
+		return nil
+	}
+	return GetOvsdbClient()
+}
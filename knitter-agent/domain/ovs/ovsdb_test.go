@@ -0,0 +1,61 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovs
+
+import (
+	"testing"
+
+	. "github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb/ovsdbtest"
+)
+
+func TestOvsWithOvsdb(t *testing.T) {
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+	defer server.Close()
+	client, err := ovsdb.Dial(server.Endpoint())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer client.Close()
+	stubs := StubFunc(&GetOvsdbClient, client)
+	defer stubs.Reset()
+
+	Convey("TestOvsWithOvsdb\n", t, func() {
+		Convey("wait ovs usable by transactions\n", func() {
+			transactions := server.Transactions
+			So(WaitOvsUsable(), ShouldBeNil)
+			So(server.Transactions, ShouldEqual, transactions+4)
+			_, ok := client.GetBridge(constvalue.OvsBrcheck)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("datapath type of bridge\n", func() {
+			So(client.AddBridge("br-phy1", ovsdb.Row{"datapath_type": "netdev"}), ShouldBeNil)
+			So(client.AddBridge("br-phy2", nil), ShouldBeNil)
+			So(IsDpdkPhysnet("physnet1", "ovsTestOK.conf"), ShouldBeTrue)
+			So(IsDpdkPhysnet("physnet2", "ovsTestOK.conf"), ShouldBeFalse)
+			_, err := GetBridgeDatapathType("br-phy3")
+			So(err, ShouldEqual, ovsdb.ErrNoBridge)
+			So(client.DelBridge("br-phy1", false), ShouldBeNil)
+			So(client.DelBridge("br-phy2", false), ShouldBeNil)
+		})
+	})
+}
@@ -360,8 +360,8 @@ func CollectNoUsedPod() ([]Pod, error) {
 	return podsOfNw, nil
 }
 
-func GetKeysOfAllPortsInPodDir(ns, name string) ([]*client.Node, error) {
-	keyOfInterfaceGroupInPod := dbaccessor.GetKeyOfInterfaceGroupInPod(ns, ns, name)
+func GetKeysOfAllPortsInPodDir(tenantID, ns, name string) ([]*client.Node, error) {
+	keyOfInterfaceGroupInPod := dbaccessor.GetKeyOfInterfaceGroupInPod(tenantID, ns, name)
 	portsOfPod, err := adapter.ReadDirFromDb(keyOfInterfaceGroupInPod)
 	if err != nil {
 		return nil, err
@@ -393,8 +393,8 @@ func GetPortInfo(ports []*client.Node) ([]iaasaccessor.Interface, error) {
 	return portsForDel, errors.New("there were errors, when get port info")
 }
 
-func GetPortsOfPod(ns, name string) ([]iaasaccessor.Interface, error) {
-	ports, err := GetKeysOfAllPortsInPodDir(ns, name)
+func GetPortsOfPod(tenantID, ns, name string) ([]iaasaccessor.Interface, error) {
+	ports, err := GetKeysOfAllPortsInPodDir(tenantID, ns, name)
 	if err != nil {
 		return nil, err
 	}
@@ -417,23 +417,23 @@ func RecyclePortSource(port iaasaccessor.Interface) error {
 	return nil
 }
 
-func ClearPortInDb(ns, name string, port iaasaccessor.Interface) error {
+func ClearPortInDb(tenantID, ns, name string, port iaasaccessor.Interface) error {
 	agtCtx := cni.GetGlobalContext()
 	var errLeaf error
 	intererfaceID := port.Id + ns + name
 	// delete port from etcd
-	dirOfInterface := dbaccessor.GetKeyOfInterface(ns, intererfaceID)
+	dirOfInterface := dbaccessor.GetKeyOfInterface(tenantID, intererfaceID)
 	errDir := adapter.ClearDirFromDb(dirOfInterface)
 	if errDir != nil {
 		return errDir
 	}
-	keyInterfaceInPod := dbaccessor.GetKeyOfInterfaceInPod(ns, port.Id, ns, name)
+	keyInterfaceInPod := dbaccessor.GetKeyOfInterfaceInPod(tenantID, port.Id, ns, name)
 	errKeyInterfaceInPod := adapter.ClearLeafFromDb(keyInterfaceInPod)
 	if errKeyInterfaceInPod != nil {
 		errLeaf = errKeyInterfaceInPod
 		klog.Errorf("ClearPortInDb:ClearLeafFromDb(keyInterfaceInPod) error! %v", errKeyInterfaceInPod)
 	}
-	keyInterfaceInNetwork := dbaccessor.GetKeyOfInterfaceInNetwork(ns, port.NetworkId, intererfaceID)
+	keyInterfaceInNetwork := dbaccessor.GetKeyOfInterfaceInNetwork(tenantID, port.NetworkId, intererfaceID)
 	errKeyInterfaceInNetwork := adapter.ClearLeafFromDb(keyInterfaceInNetwork)
 	if errKeyInterfaceInNetwork != nil {
 		errLeaf = errKeyInterfaceInNetwork
@@ -485,9 +485,9 @@ func ClearPodInDb(tenantID, ns, name string) error {
 	return nil
 }
 
-func ClearPortsOfNoUsedPod(ns, name string) error {
+func ClearPortsOfNoUsedPod(tenantID, ns, name string) error {
 	var errClear error
-	delPorts, err := GetPortsOfPod(ns, name)
+	delPorts, err := GetPortsOfPod(tenantID, ns, name)
 	klog.Infof("ClearPortsOfNoUsedPod:delPorts:%v", delPorts)
 	if err != nil && strings.Contains(err.Error(), "key not found") == false {
 		return err
@@ -500,7 +500,7 @@ func ClearPortsOfNoUsedPod(ns, name string) error {
 		if errClear != nil {
 			continue
 		}
-		errClear = ClearPortInDb(ns, name, port)
+		errClear = ClearPortInDb(tenantID, ns, name, port)
 	}
 	return errClear
 }
@@ -544,10 +544,11 @@ func RegularCollectAndClear() {
 	klog.Infof("RegularCollectAndClear:recyclePodList: %v", recyclePodList)
 	for _, recyclePod := range recyclePodList {
 		klog.Debugf("RegularCollectAndClear: recyclePod[%v]", recyclePod)
-		err := ClearPortsOfNoUsedPod(recyclePod.PodNs, recyclePod.PodName)
+		tenantID := cni.GetPodTenantID(recyclePod.PodNs, recyclePod.PodName)
+		err := ClearPortsOfNoUsedPod(tenantID, recyclePod.PodNs, recyclePod.PodName)
 		if err == nil {
 			//	klog.Infof("RegularCollectAndClear:ClearPortsOfNoUsedPod ns[%v]-name[%v] has no error! ClearPodInDb!", recyclePod.PodNs, recyclePod.PodName)
-			err := ClearPodInDb(recyclePod.PodNs, recyclePod.PodNs, recyclePod.PodName)
+			err := ClearPodInDb(tenantID, recyclePod.PodNs, recyclePod.PodName)
 			if err != nil {
 				klog.Warningf("RegularCollectAndClear:ClearPodInDb error:%v", err)
 			}
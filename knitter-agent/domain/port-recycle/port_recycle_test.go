@@ -441,7 +441,7 @@ func TestGetKeysOfAllPortsInPodDirSucc(t *testing.T) {
 		&cni.AgentContext{ClusterID: "cluster1", HostIP: "node1"})
 
 	convey.Convey("TestGetKeysOfAllPortsInPodDirSucc\n", t, func() {
-		ports, err := GetKeysOfAllPortsInPodDir("ns1", "name1")
+		ports, err := GetKeysOfAllPortsInPodDir("ns1", "ns1", "name1")
 		fmt.Printf("ports:%v\n", ports)
 		convey.So(err, convey.ShouldBeNil)
 		convey.So(len(ports), convey.ShouldEqual, 3)
@@ -475,7 +475,7 @@ func TestGetKeysOfAllPortsInPodDirErr(t *testing.T) {
 		&cni.AgentContext{ClusterID: "cluster1", HostIP: "node1"})
 
 	convey.Convey("TestGetKeysOfAllPortsInPodDirErr\n", t, func() {
-		ports, err := GetKeysOfAllPortsInPodDir("ns1", "name1")
+		ports, err := GetKeysOfAllPortsInPodDir("ns1", "ns1", "name1")
 		fmt.Printf("ports:%v\n", ports)
 		convey.So(errobj.IsEqual(err, errobj.ErrDbKeyNotFound), convey.ShouldBeTrue)
 		convey.So(ports, convey.ShouldBeNil)
@@ -530,7 +530,7 @@ func TestGetPortInfoSucc(t *testing.T) {
 	}
 
 	stubs.StubFuncSeq(&adapter.ReadLeafFromDb, outputs1)
-	ports, _ := GetKeysOfAllPortsInPodDir("ns1", "name1")
+	ports, _ := GetKeysOfAllPortsInPodDir("ns1", "ns1", "name1")
 
 	convey.Convey("TestGetPortInfoSucc\n", t, func() {
 		portsInfo, _ := GetPortInfo(ports)
@@ -595,7 +595,7 @@ func TestGetPortInfoErr(t *testing.T) {
 	}
 
 	stubs.StubFuncSeq(&adapter.ReadLeafFromDb, outputs1)
-	ports, _ := GetKeysOfAllPortsInPodDir("ns1", "name1")
+	ports, _ := GetKeysOfAllPortsInPodDir("ns1", "ns1", "name1")
 
 	convey.Convey("TestGetPortInfoErr\n", t, func() {
 		portsInfo, _ := GetPortInfo(ports)
@@ -654,7 +654,7 @@ func TestGetPortsOfPodSucc(t *testing.T) {
 	stubs.StubFuncSeq(&adapter.ReadLeafFromDb, outputs1)
 
 	convey.Convey("TestGetPortsOfPodSucc\n", t, func() {
-		interfaces, err := GetPortsOfPod("ns1", "name1")
+		interfaces, err := GetPortsOfPod("ns1", "ns1", "name1")
 		fmt.Printf("ports:%v\n", interfaces)
 		convey.So(err, convey.ShouldBeNil)
 		convey.So(len(interfaces), convey.ShouldEqual, 3)
@@ -696,7 +696,7 @@ func TestGetPortsOfPodErrInBranch1(t *testing.T) {
 		&cni.AgentContext{ClusterID: "cluster1", HostIP: "node1"})
 
 	convey.Convey("TestGetPortOfPodErrInBranch1\n", t, func() {
-		interfaces, err := GetPortsOfPod("ns1", "name1")
+		interfaces, err := GetPortsOfPod("ns1", "ns1", "name1")
 		fmt.Printf("interfaces:%v\n", interfaces)
 		convey.So(errobj.IsEqual(err, errobj.ErrDbKeyNotFound), convey.ShouldBeTrue)
 		convey.So(len(interfaces), convey.ShouldEqual, 0)
@@ -752,7 +752,7 @@ func TestGetPortsOfPodErrInBranch2(t *testing.T) {
 	stubs.StubFuncSeq(&adapter.ReadLeafFromDb, outputs1)
 
 	convey.Convey("TestGetPortOfPodErrInBranch2\n", t, func() {
-		interfaces, err := GetPortsOfPod("ns1", "name1")
+		interfaces, err := GetPortsOfPod("ns1", "ns1", "name1")
 		fmt.Printf("interfaces:%v\n", interfaces)
 		convey.So(errobj.IsEqual(err, errobj.ErrNwPortInfo), convey.ShouldBeTrue)
 		convey.So(len(interfaces), convey.ShouldEqual, 0)
@@ -786,7 +786,7 @@ func TestClearPortInDbSucc(t *testing.T) {
 		NetworkId: "networkId1",
 	}
 	convey.Convey("TestClearPortInDbSucc\n", t, func() {
-		err := ClearPortInDb("ns1", "name1", port)
+		err := ClearPortInDb("ns1", "ns1", "name1", port)
 		convey.So(err, convey.ShouldBeNil)
 	})
 }
@@ -800,7 +800,7 @@ func TestClearPortInDbErrInBranch1(t *testing.T) {
 		NetworkId: "networkId1",
 	}
 	convey.Convey("TestClearPortInDbErrInBranch1\n", t, func() {
-		err := ClearPortInDb("ns1", "name1", port)
+		err := ClearPortInDb("ns1", "ns1", "name1", port)
 		convey.So(errobj.IsEqual(err, errobj.ErrDbConnRefused), convey.ShouldBeTrue)
 	})
 }
@@ -824,7 +824,7 @@ func TestClearPortInDbErrInBranch2(t *testing.T) {
 	}
 
 	convey.Convey("TestClearPortInDbErrInBranch2\n", t, func() {
-		err := ClearPortInDb("ns1", "name1", port)
+		err := ClearPortInDb("ns1", "ns1", "name1", port)
 		convey.So(errobj.IsEqual(err, errobj.ErrDbConnRefused), convey.ShouldBeTrue)
 	})
 }
@@ -848,7 +848,7 @@ func TestClearPortInDbErrInBranch3(t *testing.T) {
 	}
 
 	convey.Convey("TestClearPortInDbErrInBranch3\n", t, func() {
-		err := ClearPortInDb("ns1", "name1", port)
+		err := ClearPortInDb("ns1", "ns1", "name1", port)
 		convey.So(errobj.IsEqual(err, errobj.ErrDbConnRefused), convey.ShouldBeTrue)
 	})
 }
@@ -873,7 +873,7 @@ func TestClearPortInDbErrInBranch4(t *testing.T) {
 	}
 
 	convey.Convey("TestClearPortInDbErrInBranch4\n", t, func() {
-		err := ClearPortInDb("ns1", "name1", port)
+		err := ClearPortInDb("ns1", "ns1", "name1", port)
 		convey.So(errobj.IsEqual(err, errobj.ErrDbConnRefused), convey.ShouldBeTrue)
 	})
 }
@@ -963,7 +963,7 @@ func TestClearPortsOfNoUsedPodSucc(t *testing.T) {
 	stubs.StubFunc(&adapter.ClearLeafFromRemoteDB, nil)
 
 	convey.Convey("TestClearPortsOfNoUsedPodSucc\n", t, func() {
-		err := ClearPortsOfNoUsedPod("ns1", "name1")
+		err := ClearPortsOfNoUsedPod("ns1", "ns1", "name1")
 		convey.So(err, convey.ShouldBeNil)
 	})
 }
@@ -1017,7 +1017,7 @@ func TestClearPortsOfNoUsedPodErrInBranch1(t *testing.T) {
 	stubs.StubFuncSeq(&adapter.ReadLeafFromDb, outputs1)
 
 	convey.Convey("TestClearPortsOfNoUsedPodErrInBranch1\n", t, func() {
-		err := ClearPortsOfNoUsedPod("ns1", "name1")
+		err := ClearPortsOfNoUsedPod("ns1", "ns1", "name1")
 		fmt.Printf("error:%v", err)
 		convey.So(errobj.IsEqual(err, errobj.ErrNwPortInfo), convey.ShouldBeTrue)
 	})
@@ -1073,7 +1073,7 @@ func TestClearPortsOfNoUsedPodErrInBranch2(t *testing.T) {
 	stubs.StubFunc(&adapter.DestroyPort, errors.New("error delete port"))
 
 	convey.Convey("TestClearPortsOfNoUsedPodErrInBranch1\n", t, func() {
-		err := ClearPortsOfNoUsedPod("ns1", "name1")
+		err := ClearPortsOfNoUsedPod("ns1", "ns1", "name1")
 		convey.So(errobj.IsEqual(err, errobj.ErrNwRecyclePort), convey.ShouldBeTrue)
 	})
 }
@@ -1131,7 +1131,7 @@ func TestClearPortsOfNoUsedPodErrInBranch3(t *testing.T) {
 	stubs.StubFunc(&adapter.ClearLeafFromRemoteDB, nil)
 
 	convey.Convey("TestClearPortsOfNoUsedPodErrInBranch1\n", t, func() {
-		err := ClearPortsOfNoUsedPod("ns1", "name1")
+		err := ClearPortsOfNoUsedPod("ns1", "ns1", "name1")
 		convey.So(errobj.IsEqual(err, errobj.ErrDbConnRefused), convey.ShouldBeTrue)
 	})
 }
@@ -0,0 +1,270 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// Qos is applied to the br-int veth of the port: ingress is limited by the
+// ingress policing of the interface, egress by a linux-htb QoS of the port,
+// and the DSCP is set by a flow of br-int matching the packets from the veth,
+// the packets go on to the network policies then.
+type Qos struct {
+	PortID   string `json:"port_id"`
+	VethName string `json:"veth_name"`
+	OfPort   string `json:"of_port,omitempty"`
+	monitor.PortQos
+}
+
+func NewQos(portID, vethName string, portQos *monitor.PortQos) *Qos {
+	return &Qos{PortID: portID, VethName: vethName, PortQos: *portQos}
+}
+
+var getOfPort = func(ifName string) (string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		iface, _ := client.GetInterface(ifName)
+		ofPort, ok := iface.Int("ofport")
+		if !ok || ofPort <= 0 {
+			return "", fmt.Errorf("ofport of interface[%s] is not allocated", ifName)
+		}
+		return strconv.FormatInt(ofPort, 10), nil
+	}
+	output, err := osencap.Exec(constvalue.OvsVsctl, "get", "Interface", ifName, "ofport")
+	if err != nil {
+		return "", err
+	}
+	ofPort := strings.TrimSpace(output)
+	if ofPort == "" || ofPort == "[]" || ofPort == "-1" {
+		return "", fmt.Errorf("ofport of interface[%s] is not allocated", ifName)
+	}
+	return ofPort, nil
+}
+
+// getQosRows finds the QoS or Queue rows made for the port.
+func getQosRows(table, portID string) []string {
+	output, err := osencap.Exec(constvalue.OvsVsctl, "--bare", "--columns=_uuid", "find", table,
+		"external_ids:port-id="+portID)
+	if err != nil {
+		klog.Warningf("getQosRows: find %s of port[%s] error: %v", table, portID, err)
+		return nil
+	}
+	return strings.Fields(output)
+}
+
+func getDscpFlows(qos *Qos) []string {
+	tos := *qos.Dscp << 2
+	flows := make([]string, 0)
+	for _, proto := range []string{"ip", "ipv6"} {
+		flows = append(flows, fmt.Sprintf("cookie=%s,table=0,priority=%d,in_port=%s,%s,actions=mod_nw_tos:%d,resubmit(,%d)",
+			constvalue.QosDscpFlowCookie, constvalue.QosDscpFlowPriority, qos.OfPort, proto, tos,
+			constvalue.BrintPolicyClassifyTable))
+	}
+	return flows
+}
+
+func setIngressPolicing(qos *Qos) error {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		return client.SetColumns("Interface", qos.VethName, ovsdb.Row{
+			"ingress_policing_rate": qos.IngressRate, "ingress_policing_burst": qos.IngressBurst})
+	}
+	_, err := osencap.Exec(constvalue.OvsVsctl, "set", "Interface", qos.VethName,
+		fmt.Sprintf("ingress_policing_rate=%d", qos.IngressRate),
+		fmt.Sprintf("ingress_policing_burst=%d", qos.IngressBurst))
+	return err
+}
+
+// setEgressQos makes the QoS of the port only once, so that applying it
+// again does not leave the old rows behind.
+func setEgressQos(qos *Qos) error {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		return setEgressQosByOvsdb(client, qos)
+	}
+	output, err := osencap.Exec(constvalue.OvsVsctl, "get", "Port", qos.VethName, "qos")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(output) != "[]" {
+		return nil
+	}
+	maxRate := fmt.Sprintf("other-config:max-rate=%d", qos.EgressRate*1000)
+	externalID := "external_ids:port-id=" + qos.PortID
+	args := []string{"--", "set", "Port", qos.VethName, "qos=@qos",
+		"--", "--id=@qos", "create", "QoS", "type=linux-htb", maxRate, externalID, "queues:0=@queue",
+		"--", "--id=@queue", "create", "Queue", maxRate, externalID}
+	if qos.EgressBurst != 0 {
+		args = append(args, fmt.Sprintf("other-config:burst=%d", qos.EgressBurst*1000))
+	}
+	_, err = osencap.Exec(constvalue.OvsVsctl, args...)
+	return err
+}
+
+func setEgressQosByOvsdb(client *ovsdb.Client, qos *Qos) error {
+	port, ok := client.GetPort(qos.VethName)
+	if !ok {
+		return ovsdb.ErrNoPort
+	}
+	if len(port.UUIDs("qos")) != 0 {
+		return nil
+	}
+	maxRate := strconv.FormatInt(qos.EgressRate*1000, 10)
+	externalIDs := ovsdb.OvsMap{"port-id": qos.PortID}
+	queueConfig := ovsdb.OvsMap{"max-rate": maxRate}
+	if qos.EgressBurst != 0 {
+		queueConfig["burst"] = strconv.FormatInt(qos.EgressBurst*1000, 10)
+	}
+	return client.CreatePortQos(qos.VethName,
+		ovsdb.Row{"type": "linux-htb", "other_config": ovsdb.OvsMap{"max-rate": maxRate}, "external_ids": externalIDs},
+		ovsdb.Row{"other_config": queueConfig, "external_ids": externalIDs})
+}
+
+// ApplyQos is idempotent, it is called on attach and again by the reconciler
+// as the flows are lost when ovs-vswitchd restarts.
+var ApplyQos = func(qos *Qos) error {
+	err := setIngressPolicing(qos)
+	if err != nil {
+		klog.Errorf("ApplyQos: set ingress policing of port[%s] error: %v", qos.PortID, err)
+		return err
+	}
+	if qos.EgressRate != 0 {
+		err = setEgressQos(qos)
+		if err != nil {
+			klog.Errorf("ApplyQos: set egress qos of port[%s] error: %v", qos.PortID, err)
+			return err
+		}
+	}
+	if qos.Dscp != nil {
+		qos.OfPort, err = getOfPort(qos.VethName)
+		if err != nil {
+			klog.Errorf("ApplyQos: get ofport of port[%s] error: %v", qos.PortID, err)
+			return err
+		}
+		for _, flow := range getDscpFlows(qos) {
+			_, err = osencap.Exec(constvalue.OvsOfctl, "add-flow", constvalue.OvsBrint, flow)
+			if err != nil {
+				klog.Errorf("ApplyQos: add flow[%s] error: %v", flow, err)
+				return err
+			}
+		}
+	}
+	klog.Infof("ApplyQos: apply qos[%+v] to veth[%s] of port[%s] SUCC", qos.PortQos, qos.VethName, qos.PortID)
+	return nil
+}
+
+// RemoveQos goes on with the errors, the veth may have been deleted.
+var RemoveQos = func(qos *Qos) error {
+	var errs []string
+	if qos.Dscp != nil && qos.OfPort != "" {
+		_, err := osencap.Exec(constvalue.OvsOfctl, "del-flows", constvalue.OvsBrint,
+			fmt.Sprintf("cookie=%s/-1,in_port=%s", constvalue.QosDscpFlowCookie, qos.OfPort))
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if client := ovs.GetOvsdbClient(); client != nil {
+		err := client.RemovePortQos(qos.VethName, map[string]string{"port-id": qos.PortID})
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	} else {
+		errs = append(errs, removeQosRows(qos)...)
+	}
+	if len(errs) != 0 {
+		klog.Errorf("RemoveQos: remove qos of port[%s] error: %v", qos.PortID, errs)
+		return errors.New(strings.Join(errs, "; "))
+	}
+	klog.Infof("RemoveQos: remove qos of port[%s] SUCC", qos.PortID)
+	return nil
+}
+
+// removeQosRows clears the QoS of the port and destroys the QoS and Queue
+// rows made for it, by ovs-vsctl.
+func removeQosRows(qos *Qos) []string {
+	var errs []string
+	_, err := osencap.Exec(constvalue.OvsVsctl, "--if-exists", "clear", "Port", qos.VethName, "qos",
+		"--", "--if-exists", "set", "Interface", qos.VethName, "ingress_policing_rate=0", "ingress_policing_burst=0")
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, table := range []string{"QoS", "Queue"} {
+		for _, uuid := range getQosRows(table, qos.PortID) {
+			_, err = osencap.Exec(constvalue.OvsVsctl, "--if-exists", "destroy", table, uuid)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	return errs
+}
+
+var GetQos = func(db dbaccessor.DbAccessor, portID string) (*Qos, error) {
+	value, err := db.ReadLeaf(dbaccessor.GetKeyOfQos(portID))
+	if err != nil {
+		return nil, err
+	}
+	qos := &Qos{}
+	err = json.Unmarshal([]byte(value), qos)
+	if err != nil {
+		klog.Errorf("GetQos: json.Unmarshal(%s) error: %v", value, err)
+		return nil, err
+	}
+	return qos, nil
+}
+
+func GetAllQoses(db dbaccessor.DbAccessor) ([]*Qos, error) {
+	nodes, err := db.ReadDir(dbaccessor.GetKeyOfQoses())
+	if err != nil {
+		return nil, err
+	}
+	qoses := make([]*Qos, 0)
+	for _, node := range nodes {
+		qos := &Qos{}
+		err = json.Unmarshal([]byte(node.Value), qos)
+		if err != nil {
+			klog.Warningf("GetAllQoses: json.Unmarshal(%s) error: %v", node.Value, err)
+			continue
+		}
+		qoses = append(qoses, qos)
+	}
+	return qoses, nil
+}
+
+func SaveQos(db dbaccessor.DbAccessor, qos *Qos) error {
+	value, _ := json.Marshal(qos)
+	err := db.SaveLeaf(dbaccessor.GetKeyOfQos(qos.PortID), string(value))
+	if err != nil {
+		klog.Errorf("SaveQos: save qos of port[%s] error: %v", qos.PortID, err)
+	}
+	return err
+}
+
+func DeleteQos(db dbaccessor.DbAccessor, portID string) error {
+	err := db.DeleteLeaf(dbaccessor.GetKeyOfQos(portID))
+	if err != nil {
+		klog.Warningf("DeleteQos: delete qos of port[%s] error: %v", portID, err)
+	}
+	return err
+}
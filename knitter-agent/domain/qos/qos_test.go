@@ -0,0 +1,167 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package qos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb/ovsdbtest"
+	"github.com/ZTE/Knitter/pkg/leveldb"
+)
+
+func TestQos(t *testing.T) {
+	var cmds []string
+	portQos := ""
+	stubs := gostub.Stub(&osencap.Exec, func(cmd string, args ...string) (string, error) {
+		line := cmd + " " + strings.Join(args, " ")
+		cmds = append(cmds, line)
+		switch {
+		case strings.HasSuffix(line, "ofport"):
+			return "5\n", nil
+		case strings.HasSuffix(line, "qos"):
+			return portQos, nil
+		case strings.Contains(line, "find QoS"):
+			return "qos-uuid\n", nil
+		case strings.Contains(line, "find Queue"):
+			return "queue-uuid\n", nil
+		}
+		return "", nil
+	})
+	defer stubs.Reset()
+
+	dscp := 46
+	qos := NewQos("port1", "veth1", &monitor.PortQos{IngressRate: 1000, IngressBurst: 100,
+		EgressRate: 2000, EgressBurst: 200, Dscp: &dscp})
+
+	Convey("TestQos", t, func() {
+		cmds = nil
+		portQos = "[]\n"
+
+		Convey("apply and remove\n", func() {
+			So(ApplyQos(qos), ShouldBeNil)
+			So(qos.OfPort, ShouldEqual, "5")
+			So(cmds, ShouldResemble, []string{
+				"ovs-vsctl set Interface veth1 ingress_policing_rate=1000 ingress_policing_burst=100",
+				"ovs-vsctl get Port veth1 qos",
+				"ovs-vsctl -- set Port veth1 qos=@qos -- --id=@qos create QoS type=linux-htb other-config:max-rate=2000000 " +
+					"external_ids:port-id=port1 queues:0=@queue -- --id=@queue create Queue other-config:max-rate=2000000 " +
+					"external_ids:port-id=port1 other-config:burst=200000",
+				"ovs-vsctl get Interface veth1 ofport",
+				"ovs-ofctl add-flow br-int cookie=0x4b51,table=0,priority=10,in_port=5,ip,actions=mod_nw_tos:184,resubmit(,70)",
+				"ovs-ofctl add-flow br-int cookie=0x4b51,table=0,priority=10,in_port=5,ipv6,actions=mod_nw_tos:184,resubmit(,70)",
+			})
+
+			cmds = nil
+			So(RemoveQos(qos), ShouldBeNil)
+			So(cmds, ShouldResemble, []string{
+				"ovs-ofctl del-flows br-int cookie=0x4b51/-1,in_port=5",
+				"ovs-vsctl --if-exists clear Port veth1 qos -- --if-exists set Interface veth1 " +
+					"ingress_policing_rate=0 ingress_policing_burst=0",
+				"ovs-vsctl --bare --columns=_uuid find QoS external_ids:port-id=port1",
+				"ovs-vsctl --if-exists destroy QoS qos-uuid",
+				"ovs-vsctl --bare --columns=_uuid find Queue external_ids:port-id=port1",
+				"ovs-vsctl --if-exists destroy Queue queue-uuid",
+			})
+		})
+
+		Convey("apply again keeps the egress qos\n", func() {
+			portQos = "qos-uuid\n"
+			So(ApplyQos(qos), ShouldBeNil)
+			So(len(cmds), ShouldEqual, 5)
+			So(cmds[2], ShouldEqual, "ovs-vsctl get Interface veth1 ofport")
+		})
+
+		Convey("record in local db\n", func() {
+			root, _ := ioutil.TempDir("", "qos")
+			defer os.RemoveAll(root)
+			db, _ := leveldb.NewLevelDBClient(filepath.Join(root, "db"))
+			So(SaveQos(db, qos), ShouldBeNil)
+			saved, err := GetQos(db, qos.PortID)
+			So(err, ShouldBeNil)
+			So(saved, ShouldResemble, qos)
+			qoses, err := GetAllQoses(db)
+			So(err, ShouldBeNil)
+			So(qoses, ShouldResemble, []*Qos{qos})
+			So(DeleteQos(db, qos.PortID), ShouldBeNil)
+			_, err = GetQos(db, qos.PortID)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestQosWithOvsdb(t *testing.T) {
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+	defer server.Close()
+	client, err := ovsdb.Dial(server.Endpoint())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer client.Close()
+	var cmds []string
+	stubs := gostub.Stub(&osencap.Exec, func(cmd string, args ...string) (string, error) {
+		cmds = append(cmds, cmd+" "+strings.Join(args, " "))
+		return "", nil
+	})
+	defer stubs.Reset()
+	stubs.StubFunc(&ovs.GetOvsdbClient, client)
+
+	dscp := 46
+	qos := NewQos("port1", "veth1", &monitor.PortQos{IngressRate: 1000, IngressBurst: 100,
+		EgressRate: 2000, EgressBurst: 200, Dscp: &dscp})
+
+	Convey("TestQosWithOvsdb", t, func() {
+		So(client.AddBridge("br-int", nil), ShouldBeNil)
+		So(client.AddPort("br-int", "veth1", false, nil, ovsdb.Row{"ofport_request": int64(5)}), ShouldBeNil)
+
+		So(ApplyQos(qos), ShouldBeNil)
+		So(qos.OfPort, ShouldEqual, "5")
+		iface, _ := client.GetInterface("veth1")
+		rate, _ := iface.Int("ingress_policing_rate")
+		So(rate, ShouldEqual, 1000)
+		qosRows, queueRows := server.Rows("QoS"), server.Rows("Queue")
+		So(qosRows, ShouldHaveLength, 1)
+		So(qosRows[0].Map("other_config"), ShouldResemble, map[string]string{"max-rate": "2000000"})
+		So(queueRows, ShouldHaveLength, 1)
+		So(queueRows[0].Map("other_config"), ShouldResemble, map[string]string{"max-rate": "2000000", "burst": "200000"})
+		So(queueRows[0].Map("external_ids"), ShouldResemble, map[string]string{"port-id": "port1"})
+		// only the dscp flows are added by ovs-ofctl
+		So(cmds, ShouldHaveLength, 2)
+
+		So(ApplyQos(qos), ShouldBeNil)
+		So(server.Rows("QoS"), ShouldHaveLength, 1)
+
+		So(RemoveQos(qos), ShouldBeNil)
+		So(server.Rows("QoS"), ShouldBeEmpty)
+		So(server.Rows("Queue"), ShouldBeEmpty)
+		port, _ := client.GetPort("veth1")
+		So(port.UUIDs("qos"), ShouldBeEmpty)
+
+		So(client.DelBridge("br-int", false), ShouldBeNil)
+		So(ApplyQos(qos), ShouldEqual, ovsdb.ErrNoRow)
+	})
+}
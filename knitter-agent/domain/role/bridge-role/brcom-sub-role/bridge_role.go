@@ -14,14 +14,31 @@ limitations under the License.
 package brcomsubrole
 
 import (
+	"io/ioutil"
+	"os"
+	"strings"
+
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
 	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/pkg/klog"
 )
 
 type BridgeRole struct {
 }
 
 func (this *BridgeRole) AddBridge(bridge string, properties ...string) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		columns, err := ovsdb.ColumnsFromArgs(properties...)
+		if err == nil {
+			err = client.AddBridge(bridge, columns)
+		}
+		if err != nil {
+			klog.Errorf("AddBridge: add bridge %s error: %v", bridge, err)
+		}
+		return
+	}
 	args := []string{"--may-exist", "add-br", bridge}
 	if len(properties) > 0 {
 		args = append(args, "--", "set", "Bridge", bridge)
@@ -31,6 +48,13 @@ func (this *BridgeRole) AddBridge(bridge string, properties ...string) {
 }
 
 func (this *BridgeRole) ForceAddBridge(bridge string, properties ...string) (string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		columns, err := ovsdb.ColumnsFromArgs(properties...)
+		if err != nil {
+			return "", err
+		}
+		return "", client.ReplaceBridge(bridge, columns)
+	}
 	args := []string{"--if-exists", "del-br", bridge, "--", "add-br",
 		bridge}
 	if len(properties) > 0 {
@@ -49,3 +73,27 @@ func (this *BridgeRole) OfctlExec(args ...string) (string, error) {
 	args = append([]string{"-O", "OpenFlow10"}, args...)
 	return osencap.Exec(constvalue.OvsOfctl, args...)
 }
+
+// OfctlBundle applies the flow_mod commands, e.g. "add ...", "delete ...",
+// to the bridge by one ovs-ofctl call. They are applied atomically in an
+// OpenFlow 1.4 bundle, or one by one if the bridge does not support bundles.
+func (this *BridgeRole) OfctlBundle(bridge string, flowMods []string) (string, error) {
+	file, err := ioutil.TempFile("", "knitter-flows")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(strings.Join(flowMods, "\n") + "\n")
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	output, err := osencap.Exec(constvalue.OvsOfctl, "-O", "OpenFlow14", "--bundle", "add-flows", bridge, file.Name())
+	if err == nil {
+		return output, nil
+	}
+	klog.Warningf("OfctlBundle: bundle of %d flow mods to %s error: %v, apply them without bundle",
+		len(flowMods), bridge, err)
+	return this.OfctlExec("add-flows", bridge, file.Name())
+}
@@ -16,7 +16,9 @@ package brcomsubrole
 import (
 	"fmt"
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
 	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
 )
 
 type PortRole struct {
@@ -24,6 +26,14 @@ type PortRole struct {
 }
 
 func (this *PortRole) AddPort(bridge string, port string, ofPort uint, properties ...string) (string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		ifaceColumns, err := ovsdb.ColumnsFromArgs(properties...)
+		if err != nil {
+			return "", err
+		}
+		ifaceColumns["ofport_request"] = ofPort
+		return "", client.AddPort(bridge, port, true, nil, ifaceColumns)
+	}
 	args := []string{"--if-exists", "del-port", port, "--", "add-port", bridge, port, "--",
 		"set", "Interface", port, fmt.Sprintf("ofport_request=%d", ofPort)}
 	if len(properties) > 0 {
@@ -35,5 +45,8 @@ func (this *PortRole) AddPort(bridge string, port string, ofPort uint, propertie
 // DeletePort removes an interface from the bridge. (It is an error if the
 // interface is not currently a bridge port.)
 func (this *PortRole) DeletePort(port string) (string, error) {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		return "", client.DelPort("", port, false)
+	}
 	return this.BridgeRole.VsctlExec("del-port", port)
 }
@@ -0,0 +1,71 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package brcomsubrole
+
+import (
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb/ovsdbtest"
+)
+
+func TestPortRoleWithOvsdb(t *testing.T) {
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+	defer server.Close()
+	client, err := ovsdb.Dial(server.Endpoint())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer client.Close()
+	stubs := gostub.StubFunc(&ovs.GetOvsdbClient, client)
+	defer stubs.Reset()
+
+	Convey("TestPortRoleWithOvsdb", t, func() {
+		bridgeRole := &BridgeRole{}
+		portRole := &PortRole{}
+		_, err := bridgeRole.ForceAddBridge("br-tun", "protocols=OpenFlow10,OpenFlow14")
+		So(err, ShouldBeNil)
+		bridgeRole.AddBridge("br-int")
+		bridge, ok := client.GetBridge("br-tun")
+		So(ok, ShouldBeTrue)
+		So(bridge["protocols"], ShouldResemble, ovsdb.OvsSet{"OpenFlow10", "OpenFlow14"})
+
+		_, err = portRole.AddPort("br-tun", "patch-int-paas", 1, "type=patch", "options:peer=patch-tun-paas")
+		So(err, ShouldBeNil)
+		iface, ok := client.GetInterface("patch-int-paas")
+		So(ok, ShouldBeTrue)
+		ofPort, _ := iface.Int("ofport")
+		So(ofPort, ShouldEqual, 1)
+		So(iface.Map("options"), ShouldResemble, map[string]string{"peer": "patch-tun-paas"})
+
+		// added again as ovs-vsctl --if-exists del-port -- add-port
+		_, err = portRole.AddPort("br-tun", "patch-int-paas", 1, "type=patch", "options:peer=patch-tun-paas")
+		So(err, ShouldBeNil)
+		So(server.Rows("Interface"), ShouldHaveLength, 3)
+
+		_, err = portRole.DeletePort("patch-int-paas")
+		So(err, ShouldBeNil)
+		_, ok = client.GetPort("patch-int-paas")
+		So(ok, ShouldBeFalse)
+		_, err = portRole.DeletePort("patch-int-paas")
+		So(err, ShouldEqual, ovsdb.ErrNoPort)
+	})
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/bridge-role/brtun-sub-role"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/port-role"
 	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
 	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/agt-mgr"
 	"github.com/ZTE/Knitter/pkg/klog"
@@ -237,7 +238,8 @@ func CreatePort4Gw(defaultNetName, vethName string) (*manager.Port, error) {
 		klog.Errorf("manager port extract error! -%v", err)
 		return nil, err
 	}
-	port.MTU = ctx.Mtu
+	// the default network of gateway is a vxlan network
+	port.MTU = ctx.GetPodMtu(0, true)
 	return port, nil
 }
 
@@ -264,6 +266,7 @@ func DeleteGwIaasPort4Etcd() error {
 	return nil
 }
 
+// OvsVsctl changes the ports if ovsdb-server is not connected.
 func OvsVsctl(args ...string) error {
 	ovsctl, _ := exec.LookPath("ovs-vsctl")
 	ovsctlOutput, err := exec.Command(ovsctl, args...).CombinedOutput()
@@ -276,6 +279,13 @@ func OvsVsctl(args ...string) error {
 }
 
 func AddPort2Ovs(bridge, port string, properties ...string) error {
+	if client := ovs.GetOvsdbClient(); client != nil {
+		portColumns, err := ovsdb.ColumnsFromArgs(properties...)
+		if err != nil {
+			return err
+		}
+		return client.AddPort(bridge, port, true, portColumns, nil)
+	}
 	args := []string{"--if-exists", "del-port", port, "--", "add-port", bridge, port}
 	if len(properties) > 0 {
 		args = append(args, properties...)
@@ -14,36 +14,39 @@ limitations under the License.
 package brtunsubrole
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/bridge-role/brcom-sub-role"
 	"github.com/ZTE/Knitter/knitter-agent/err-obj"
 	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/ZTE/Knitter/pkg/klog"
-	"strconv"
+	"reflect"
+	"sort"
 	"sync"
 )
 
-type TunPort struct {
-	Agent *dbaccessor.Agent `json:"agent"`
-	ID    int               `json:"port_id"`
-	Name  string            `json:"port_name"`
-}
-
 type TunNet struct {
 	ID     string `json:"net_id"`
 	Vni    int    `json:"vxlan_id"`
 	VlanID string `json:"vlan_id"`
 }
 
+// FlowMgrRole keeps one flow based tunnel port on br-tun, the remote agent
+// of a packet is chosen by the flows. The broadcast of a network is flooded
+// to the agents hosting pods of it, which are given by knitter-manager.
 type FlowMgrRole struct {
-	FlowTableRole       FlowTableRole
-	PortIDAllocatorRole PortIDAllocatorRole
-	PortRole            brcomsubrole.PortRole
-	PortList            []*TunPort
-	NetList             []*TunNet
+	FlowTableRole FlowTableRole
+	PortRole      brcomsubrole.PortRole
+	NetList       []*TunNet
+	// Members are the ips of the remote agents hosting pods of each network
+	Members map[string][]string
+	// AllNetworkMembers are the ips of the remote agents not reporting their
+	// networks, the broadcast of all networks is flooded to them
+	AllNetworkMembers []string
+	LocalIP           string
+	lock              sync.Mutex
 }
 
 var flowMgr *FlowMgrRole
@@ -56,35 +59,14 @@ func GetFlowMgrSingleton() *FlowMgrRole {
 		return flowMgr
 	}
 
-	flowMgr = &FlowMgrRole{}
+	flowMgr = &FlowMgrRole{Members: make(map[string][]string)}
 	flowMgr.FlowTableRole.Init()
 	return flowMgr
 }
 
-func (this *FlowMgrRole) getPort(agent *dbaccessor.Agent) *TunPort {
-	for _, port := range this.PortList {
-		if port.Agent.Id == agent.Id {
-			return port
-		}
-	}
-	return nil
-}
-
-func (this *FlowMgrRole) isLinked(agent *dbaccessor.Agent) bool {
-	port := this.getPort(agent)
-	if port == nil {
-		return false
-	}
-
-	if port.ID == InvalidTunnulPortID &&
-		port.Name == strconv.Itoa(InvalidTunnulPortID) {
-		return false
-	}
-
-	return true
-}
-
 func (this *FlowMgrRole) AddNetwork(networkID string, vni int, vlanID string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	newNet := TunNet{ID: networkID, Vni: vni, VlanID: vlanID}
 	for _, net := range this.NetList {
 		if (net.ID == newNet.ID) || (net.Vni == newNet.Vni) ||
@@ -94,14 +76,22 @@ func (this *FlowMgrRole) AddNetwork(networkID string, vni int, vlanID string) er
 		}
 	}
 
+	err := this.FlowTableRole.AddNetworkFlows(&newNet, this.LocalIP,
+		getFloodIPs(this.Members[newNet.ID], this.AllNetworkMembers))
+	if err != nil {
+		klog.Error("Add-Net[", newNet.ID, "] error, add flows error:", err.Error())
+		return err
+	}
 	this.NetList = append(this.NetList, &newNet)
 	klog.Info("Add-Net[", newNet.ID, "][", newNet.Vni,
 		"vs", newNet.VlanID, "]-OK")
-	this.FlowTableRole.Update(this.NetList, this.PortList)
+	triggerSync()
 	return nil
 }
 
 func (this *FlowMgrRole) RemoveNetwork(netID string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	var indexOfNet int = constvalue.InvalidNetID
 	for index, net := range this.NetList {
 		if net.ID == netID {
@@ -109,6 +99,8 @@ func (this *FlowMgrRole) RemoveNetwork(netID string) error {
 		}
 	}
 	if indexOfNet != constvalue.InvalidNetID {
+		// the flows left by an error are deleted by the next Update
+		this.FlowTableRole.RemoveNetworkFlows(this.NetList[indexOfNet])
 		tmpNetList := this.NetList
 		this.NetList = append(tmpNetList[:indexOfNet], tmpNetList[indexOfNet+1:]...)
 	} else {
@@ -116,105 +108,133 @@ func (this *FlowMgrRole) RemoveNetwork(netID string) error {
 		return errors.New("delete-Net-error:Cannot-find")
 	}
 	klog.Error("Del-Net[", netID, "]-OK")
-	this.FlowTableRole.Update(this.NetList, this.PortList)
+	triggerSync()
 	return nil
 }
 
-func (this *FlowMgrRole) createVxlan(remote, local *dbaccessor.Agent) (int, error) {
-	portID := this.PortIDAllocatorRole.Alloc()
-	arg0 := "type=vxlan"
-	arg1 := "options:df_default=false"
-	arg2 := "options:in_key=flow"
-	arg3 := "options:out_key=flow"
-	arg4 := "options:dst_port=6789"
-	arg5 := fmt.Sprintf("options:remote_ip=%s", remote.Ip)
-	arg6 := fmt.Sprintf("options:local_ip=%s", local.Ip)
-	_, err := this.PortRole.AddPort(constvalue.OvsBrtun, remote.Id, uint(portID),
-		arg0, arg1, arg2, arg3, arg4, arg5, arg6)
-	if err != nil {
-		this.PortIDAllocatorRole.Free(portID)
-		klog.Warning("Add-PORT-for-agent[", remote.Ip,
-			"] error:", err.Error())
-		return InvalidTunnulPortID, err
+// GetNetworkIDs returns the networks hosting local pods, they are reported
+// to knitter-manager on each sync.
+func (this *FlowMgrRole) GetNetworkIDs() []string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	networkIDs := make([]string, 0, len(this.NetList))
+	for _, net := range this.NetList {
+		networkIDs = append(networkIDs, net.ID)
 	}
-	return portID, nil
+	sort.Strings(networkIDs)
+	return networkIDs
 }
 
-func (this *FlowMgrRole) createLink(remote, local *dbaccessor.Agent) error {
-	portID, err := this.createVxlan(remote, local)
+// createTunnel adds the flow based tunnel port, its type and UDP destination
+// port are configured by the tunnel of the agent context.
+func (this *FlowMgrRole) createTunnel(localIP string) error {
+	tunnel := cni.GetGlobalContext().Tunnel
+	_, err := this.PortRole.AddPort(constvalue.OvsBrtun, constvalue.BrtunTunnelPort, constvalue.BrtunTunnelOfPort,
+		fmt.Sprintf("type=%s", tunnel.Type),
+		"options:df_default=false",
+		"options:in_key=flow",
+		"options:out_key=flow",
+		fmt.Sprintf("options:dst_port=%d", tunnel.DstPort),
+		"options:remote_ip=flow",
+		fmt.Sprintf("options:local_ip=%s", localIP))
 	if err != nil {
-		klog.Warning("Create-Vxlan-tunnel-with[", remote.Ip, "] error.")
+		klog.Warning("Add-tunnel-PORT-with-local-ip[", localIP, "] error:", err.Error())
 		return err
 	}
-
-	port := this.getPort(remote)
-	if port == nil {
-		klog.Info("Craate-port-for-new-agent")
-		port = &TunPort{ID: portID,
-			Name: remote.Id, Agent: remote}
-		this.PortList = append(this.PortList, port)
-	} else {
-		klog.Info("Create-port-for-down-agent")
-		port.ID = portID
-		port.Name = remote.Id
-		port.Agent = remote
-	}
-
-	portData, _ := json.Marshal(port)
-	klog.Info("Create-PORT-for-agent[", remote.Ip,
-		"] PORT:", string(portData))
-
-	this.FlowTableRole.Update(this.NetList, this.PortList)
 	return nil
 }
 
-func (this *FlowMgrRole) deleteLink(agent *dbaccessor.Agent) error {
-	portTun := this.getPort(agent)
-	if portTun == nil {
-		return errors.New("cannot-find-tunnel-port")
+// getRemoteIPs drops the local agent from the ips.
+func getRemoteIPs(ips []string, localIP string) []string {
+	remoteIPs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		if ip != localIP {
+			remoteIPs = append(remoteIPs, ip)
+		}
 	}
+	sort.Strings(remoteIPs)
+	return remoteIPs
+}
 
-	_, err := this.PortRole.DeletePort(agent.Id)
-	if err != nil {
-		klog.Warning("Del-PORT-of-agent[", agent.Ip,
-			"] error:", err.Error())
-		return err
+// getRemoteMembers drops the local agent from the members of the networks.
+func getRemoteMembers(members map[string][]string, localIP string) map[string][]string {
+	remoteMembers := make(map[string][]string)
+	for netID, ips := range members {
+		remoteIPs := getRemoteIPs(ips, localIP)
+		if len(remoteIPs) > 0 {
+			remoteMembers[netID] = remoteIPs
+		}
 	}
+	return remoteMembers
+}
 
-	this.PortIDAllocatorRole.Free(portTun.ID)
-	portTun.ID = InvalidTunnulPortID
-	portTun.Name = strconv.Itoa(InvalidTunnulPortID)
-	portTun.Agent = agent
-
-	portData, _ := json.Marshal(portTun)
-	klog.Info("Delete-PORT-of-agent[", agent.Ip,
-		"] PORT:", string(portData))
+// getFloodIPs returns the remote agents the broadcast of a network is flooded
+// to, which are its members and the agents not reporting their networks.
+func getFloodIPs(members, allNetworkMembers []string) []string {
+	ipSet := make(map[string]bool)
+	for _, ip := range append(append([]string{}, members...), allNetworkMembers...) {
+		ipSet[ip] = true
+	}
+	if len(ipSet) == 0 {
+		return nil
+	}
+	ips := make([]string, 0, len(ipSet))
+	for ip := range ipSet {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
 
-	this.FlowTableRole.Update(this.NetList, this.PortList)
-	return nil
+// getFloodMembers returns the flood ips of each local network.
+func (this *FlowMgrRole) getFloodMembers(members map[string][]string, allNetworkMembers []string) map[string][]string {
+	floodMembers := make(map[string][]string)
+	for _, net := range this.NetList {
+		if ips := getFloodIPs(members[net.ID], allNetworkMembers); ips != nil {
+			floodMembers[net.ID] = ips
+		}
+	}
+	return floodMembers
 }
 
+// Sync creates the tunnel port on the first sync, then only the flooding
+// flows of the local networks whose remote agents are changed are updated.
 func (this *FlowMgrRole) Sync(topo *dbaccessor.Sync) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
 	var local *dbaccessor.Agent = topo.Client
-	for _, remote := range topo.Agents {
-		if remote.Id == local.Id {
-			klog.Info("Skip-self[", remote.Ip, "]")
-			continue
-		}
-		if this.isLinked(remote) == false &&
-			remote.Status == dbaccessor.AgentStatusReady {
-			klog.Info("Add-port-for-agent[", remote.Ip, "]")
-			this.createLink(remote, local)
-			continue
+	members := getRemoteMembers(topo.Members, local.Ip)
+	allNetworkMembers := getRemoteIPs(topo.AllNetworkMembers, local.Ip)
+	floodMembers := this.getFloodMembers(members, allNetworkMembers)
+
+	if this.LocalIP != local.Ip {
+		err := this.createTunnel(local.Ip)
+		if err != nil {
+			return err
 		}
-		if this.isLinked(remote) == true &&
-			remote.Status == dbaccessor.AgentStatusDown {
-			klog.Info("Del-port-for-agent[", remote.Ip, "]")
-			this.deleteLink(remote)
-			continue
+		err = this.FlowTableRole.Update(local.Ip, this.NetList, floodMembers)
+		if err != nil {
+			return err
 		}
-		klog.Info("Link-with-agent[", remote.Ip, "] OK")
+		this.LocalIP = local.Ip
+		this.Members, this.AllNetworkMembers = members, allNetworkMembers
+		return nil
 	}
 
+	oldFloodMembers := this.getFloodMembers(this.Members, this.AllNetworkMembers)
+	changedNets := make([]*TunNet, 0)
+	for _, net := range this.NetList {
+		if !reflect.DeepEqual(oldFloodMembers[net.ID], floodMembers[net.ID]) {
+			klog.Info("Agents-of-net[", net.ID, "] changed to ", floodMembers[net.ID])
+			changedNets = append(changedNets, net)
+		}
+	}
+	// the members are kept if the flows are not updated, so the next sync
+	// updates them again
+	err := this.FlowTableRole.UpdateFloodFlows(changedNets, floodMembers)
+	if err != nil {
+		return err
+	}
+	this.Members, this.AllNetworkMembers = members, allNetworkMembers
 	return nil
 }
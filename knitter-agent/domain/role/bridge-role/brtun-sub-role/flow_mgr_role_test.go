@@ -0,0 +1,107 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package brtunsubrole
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+)
+
+func TestFlowMgrRoleSync(t *testing.T) {
+	var cmds []string
+	var flowMods [][]string
+	stubs := gostub.Stub(&osencap.Exec, func(cmd string, args ...string) (string, error) {
+		line := cmd + " " + strings.Join(args, " ")
+		cmds = append(cmds, line)
+		if strings.Contains(line, "add-flows") {
+			content, _ := ioutil.ReadFile(args[len(args)-1])
+			flowMods = append(flowMods, strings.Split(strings.TrimSpace(string(content)), "\n"))
+		}
+		return "", nil
+	})
+	defer stubs.Reset()
+	stubs.Stub(&cni.GetGlobalContext().Tunnel, dbaccessor.Tunnel{Type: "geneve", DstPort: 6081})
+
+	local := &dbaccessor.Agent{Id: "1", Ip: "192.168.1.1"}
+	Convey("TestFlowMgrRoleSync", t, func() {
+		cmds, flowMods = nil, nil
+		flowMgr := &FlowMgrRole{Members: make(map[string][]string)}
+		So(flowMgr.AddNetwork("net1", 100, "2"), ShouldBeNil)
+		So(flowMgr.AddNetwork("net2", 200, "3"), ShouldBeNil)
+		So(flowMgr.GetNetworkIDs(), ShouldResemble, []string{"net1", "net2"})
+		cmds, flowMods = nil, nil
+
+		topo := &dbaccessor.Sync{Client: local, Members: map[string][]string{
+			"net1": {"192.168.1.1", "192.168.1.3", "192.168.1.2"}, "net3": {"192.168.1.4"}}}
+		So(flowMgr.Sync(topo), ShouldBeNil)
+		So(cmds[0], ShouldEqual, "ovs-vsctl --if-exists del-port tun-paas -- add-port br-tun tun-paas -- "+
+			"set Interface tun-paas ofport_request=2 type=geneve options:df_default=false options:in_key=flow "+
+			"options:out_key=flow options:dst_port=6081 options:remote_ip=flow options:local_ip=192.168.1.1")
+		So(flowMgr.Members, ShouldResemble, map[string][]string{
+			"net1": {"192.168.1.2", "192.168.1.3"}, "net3": {"192.168.1.4"}})
+		So(flowMods, ShouldHaveLength, 1)
+		So(flowMods[0], ShouldContain, "add cookie=0x4b5400000064,table=21,priority=1,dl_vlan=2,actions=strip_vlan,"+
+			"set_tunnel:100,set_field:192.168.1.2->tun_dst,output:2,set_field:192.168.1.3->tun_dst,output:2")
+
+		Convey("only the flooding flows of the changed networks are updated\n", func() {
+			cmds, flowMods = nil, nil
+			topo.Members = map[string][]string{"net1": {"192.168.1.3", "192.168.1.2"},
+				"net2": {"192.168.1.1"}, "net3": {"192.168.1.5"}}
+			So(flowMgr.Sync(topo), ShouldBeNil)
+			So(flowMods, ShouldBeEmpty)
+
+			topo.Members = map[string][]string{"net1": {"192.168.1.3"}, "net2": {"192.168.1.4"}}
+			So(flowMgr.Sync(topo), ShouldBeNil)
+			So(cmds, ShouldHaveLength, 1)
+			So(flowMods[0], ShouldResemble, []string{
+				"add cookie=0x4b5400000064,table=21,priority=1,dl_vlan=2,actions=strip_vlan,set_tunnel:100," +
+					"set_field:192.168.1.3->tun_dst,output:2",
+				"add cookie=0x4b54000000c8,table=21,priority=1,dl_vlan=3,actions=strip_vlan,set_tunnel:200," +
+					"set_field:192.168.1.4->tun_dst,output:2"})
+		})
+
+		Convey("new network floods to its members\n", func() {
+			cmds, flowMods = nil, nil
+			So(flowMgr.AddNetwork("net3", 300, "4"), ShouldBeNil)
+			So(flowMods[0][2], ShouldEqual, "add cookie=0x4b540000012c,table=21,priority=1,dl_vlan=4,"+
+				"actions=strip_vlan,set_tunnel:300,set_field:192.168.1.4->tun_dst,output:2")
+		})
+
+		Convey("agents not reporting networks are flooded by all networks\n", func() {
+			cmds, flowMods = nil, nil
+			topo.AllNetworkMembers = []string{"192.168.1.1", "192.168.1.5"}
+			So(flowMgr.Sync(topo), ShouldBeNil)
+			So(flowMgr.AllNetworkMembers, ShouldResemble, []string{"192.168.1.5"})
+			So(flowMods[0], ShouldResemble, []string{
+				"add cookie=0x4b5400000064,table=21,priority=1,dl_vlan=2,actions=strip_vlan,set_tunnel:100," +
+					"set_field:192.168.1.2->tun_dst,output:2,set_field:192.168.1.3->tun_dst,output:2," +
+					"set_field:192.168.1.5->tun_dst,output:2",
+				"add cookie=0x4b54000000c8,table=21,priority=1,dl_vlan=3,actions=strip_vlan,set_tunnel:200," +
+					"set_field:192.168.1.5->tun_dst,output:2"})
+
+			cmds, flowMods = nil, nil
+			So(flowMgr.AddNetwork("net4", 400, "5"), ShouldBeNil)
+			So(flowMods[0][2], ShouldEqual, "add cookie=0x4b5400000190,table=21,priority=1,dl_vlan=5,"+
+				"actions=strip_vlan,set_tunnel:400,set_field:192.168.1.5->tun_dst,output:2")
+		})
+	})
+}
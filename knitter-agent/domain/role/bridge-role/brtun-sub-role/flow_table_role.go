@@ -18,6 +18,9 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
 	"github.com/ZTE/Knitter/knitter-agent/domain/role/bridge-role/brcom-sub-role"
 	"github.com/ZTE/Knitter/pkg/klog"
+	"net"
+	"regexp"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -27,9 +30,11 @@ type FlowTableRole struct {
 	portRole   brcomsubrole.PortRole
 }
 
+var cookieRegexp = regexp.MustCompile(`cookie=(0x[0-9a-f]+)`)
+
 func (this FlowTableRole) Init() {
 	for {
-		_, err := this.bridgeRole.ForceAddBridge(constvalue.OvsBrtun)
+		_, err := this.bridgeRole.ForceAddBridge(constvalue.OvsBrtun, "protocols=OpenFlow10,OpenFlow14")
 		if err == nil {
 			break
 		} else {
@@ -48,63 +53,173 @@ func (this FlowTableRole) Init() {
 }
 
 func (this FlowTableRole) addDefaultFlow() {
-	this.addFlow("table=0,priority=2,in_port=1,actions=resubmit(,1)")
-	this.addFlow("table=0,priority=1,actions=resubmit(,4)")
-	this.addFlow("table=1,priority=1,dl_dst=00:00:00:00:00:00/01:00:00:00:00:00,actions=resubmit(,20)")
-	this.addFlow("table=1,priority=1,dl_dst=01:00:00:00:00:00/01:00:00:00:00:00,actions=resubmit(,21)")
-	this.addFlow("table=1,priority=0,actions=drop")
-	this.addFlow("table=4,priority=0,actions=drop")
-	this.addFlow("table=10,priority=1,actions=learn(table=20,priority=1,hard_timeout=300,NXM_OF_VLAN_TCI[0..11],NXM_OF_ETH_DST[]=NXM_OF_ETH_SRC[],load:0->NXM_OF_VLAN_TCI[],load:NXM_NX_TUN_ID[]->NXM_NX_TUN_ID[],output:NXM_OF_IN_PORT[]),output:1")
-	this.addFlow("table=20,priority=0,actions=resubmit(,21)")
-	this.addFlow("table=21,priority=0,actions=drop")
+	flowMods := []string{
+		"add table=0,priority=2,in_port=1,actions=resubmit(,1)",
+		"add table=0,priority=1,actions=resubmit(,4)",
+		"add table=1,priority=1,dl_dst=00:00:00:00:00:00/01:00:00:00:00:00,actions=resubmit(,20)",
+		"add table=1,priority=1,dl_dst=01:00:00:00:00:00/01:00:00:00:00:00,actions=resubmit(,21)",
+		"add table=1,priority=0,actions=drop",
+		"add table=4,priority=0,actions=drop",
+		"add table=20,priority=0,actions=resubmit(,21)",
+		"add table=21,priority=0,actions=drop",
+	}
+	_, err := this.bridgeRole.OfctlBundle(constvalue.OvsBrtun, flowMods)
+	if err != nil {
+		klog.Errorf("addDefaultFlow: OfctlBundle error: %v", err)
+	}
 }
 
 func (this FlowTableRole) delAllFlow() {
-	this.deleteFlows("")
+	this.bridgeRole.OfctlExec("del-flows", constvalue.OvsBrtun)
+}
+
+// getNetworkCookie returns the cookie of the flows of the network.
+func getNetworkCookie(vni int) uint64 {
+	return constvalue.BrtunNetFlowCookieBase | uint64(uint32(vni))
 }
 
-// deleteFlows deletes all matching flows from the bridge. The arguments are
-// passed to fmt.Sprintf().
-func (this FlowTableRole) deleteFlows(flow string, args ...interface{}) (string, error) {
-	if len(args) > 0 {
-		flow = fmt.Sprintf(flow, args...)
+// getLearnFlowMod learns the MAC of the remote pod of the network with the
+// address of its agent, which is the remote ip of the flow based tunnel port.
+// The learned flows carry the cookie of the network, so they are deleted
+// with the network.
+func (this FlowTableRole) getLearnFlowMod(net *TunNet, localIP string) string {
+	tunSrc, tunDst := "NXM_NX_TUN_IPV4_SRC[]", "NXM_NX_TUN_IPV4_DST[]"
+	if isIPv6(localIP) {
+		tunSrc, tunDst = "NXM_NX_TUN_IPV6_SRC[]", "NXM_NX_TUN_IPV6_DST[]"
 	}
-	return this.bridgeRole.OfctlExec("del-flows", constvalue.OvsBrtun, flow)
+	cookie := getNetworkCookie(net.Vni)
+	return fmt.Sprintf("add cookie=%#x,table=10,priority=1,dl_vlan=%s,actions=learn(table=20,cookie=%#x,"+
+		"priority=1,hard_timeout=300,NXM_OF_VLAN_TCI[0..11],NXM_OF_ETH_DST[]=NXM_OF_ETH_SRC[],"+
+		"load:0->NXM_OF_VLAN_TCI[],load:NXM_NX_TUN_ID[]->NXM_NX_TUN_ID[],load:%s->%s,"+
+		"output:NXM_OF_IN_PORT[]),output:1", cookie, net.VlanID, cookie, tunSrc, tunDst)
 }
 
-// addFlow adds a flow to the bridge. The arguments are passed to fmt.Sprintf().
-func (this FlowTableRole) addFlow(flow string, args ...interface{}) (string, error) {
-	if len(args) > 0 {
-		flow = fmt.Sprintf(flow, args...)
+func isIPv6(ip string) bool {
+	addr := net.ParseIP(ip)
+	return addr != nil && addr.To4() == nil
+}
+
+func (this FlowTableRole) getNetworkFlowMods(net *TunNet, localIP string, remoteIPs []string) []string {
+	cookie := getNetworkCookie(net.Vni)
+	return []string{
+		fmt.Sprintf("add cookie=%#x,table=4,priority=1,tun_id=%d,actions=mod_vlan_vid:%s,resubmit(,10)",
+			cookie, net.Vni, net.VlanID),
+		this.getLearnFlowMod(net, localIP),
+		this.getFloodFlowMod(net, remoteIPs),
 	}
-	return this.bridgeRole.OfctlExec("add-flow", constvalue.OvsBrtun, flow)
 }
 
-func (this FlowTableRole) Update(NetList []*TunNet, PortList []*TunPort) {
-	this.deleteFlows("table=4")
-	this.deleteFlows("table=21")
-	this.addDefaultFlow()
+// getFloodFlowMod floods the broadcast of the network to the agents hosting
+// pods of it through the flow based tunnel port.
+func (this FlowTableRole) getFloodFlowMod(net *TunNet, remoteIPs []string) string {
+	return fmt.Sprintf("add cookie=%#x,table=21,priority=1,dl_vlan=%s,actions=strip_vlan,set_tunnel:%d%s",
+		getNetworkCookie(net.Vni), net.VlanID, net.Vni, this.getOutput(remoteIPs))
+}
 
-	outputStr := this.getOutput(PortList)
+// getDelNetworkFlowMod deletes the flows of the network, including the MACs
+// learned on it.
+func (this FlowTableRole) getDelNetworkFlowMod(cookie uint64) string {
+	return fmt.Sprintf("delete cookie=%#x/-1", cookie)
+}
+
+// AddNetworkFlows adds the flows of the new network, the flows of the other
+// networks are untouched.
+func (this FlowTableRole) AddNetworkFlows(net *TunNet, localIP string, remoteIPs []string) error {
+	_, err := this.bridgeRole.OfctlBundle(constvalue.OvsBrtun, this.getNetworkFlowMods(net, localIP, remoteIPs))
+	if err != nil {
+		klog.Errorf("AddNetworkFlows: add flows of network[%s] error: %v", net.ID, err)
+		return err
+	}
+	return nil
+}
+
+// RemoveNetworkFlows deletes the flows of the network by its cookie.
+func (this FlowTableRole) RemoveNetworkFlows(net *TunNet) error {
+	_, err := this.bridgeRole.OfctlBundle(constvalue.OvsBrtun,
+		[]string{this.getDelNetworkFlowMod(getNetworkCookie(net.Vni))})
+	if err != nil {
+		klog.Errorf("RemoveNetworkFlows: delete flows of network[%s] error: %v", net.ID, err)
+		return err
+	}
+	return nil
+}
+
+// getNetworkCookies returns the cookies of the network flows on br-tun.
+func (this FlowTableRole) getNetworkCookies() (map[uint64]bool, error) {
+	output, err := this.bridgeRole.OfctlExec("dump-flows", constvalue.OvsBrtun,
+		fmt.Sprintf("cookie=%#x/%#x", constvalue.BrtunNetFlowCookieBase, constvalue.BrtunNetFlowCookieMask))
+	if err != nil {
+		return nil, err
+	}
+	cookies := make(map[uint64]bool)
+	for _, match := range cookieRegexp.FindAllStringSubmatch(output, -1) {
+		cookie, err := strconv.ParseUint(match[1], 0, 64)
+		if err == nil {
+			cookies[cookie] = true
+		}
+	}
+	return cookies, nil
+}
+
+// UpdateFloodFlows replaces the flooding flows of the networks whose agents
+// are changed, the flows of the other networks are untouched.
+func (this FlowTableRole) UpdateFloodFlows(nets []*TunNet, members map[string][]string) error {
+	if len(nets) == 0 {
+		return nil
+	}
+	flowMods := make([]string, 0, len(nets))
+	for _, net := range nets {
+		flowMods = append(flowMods, this.getFloodFlowMod(net, members[net.ID]))
+	}
+	_, err := this.bridgeRole.OfctlBundle(constvalue.OvsBrtun, flowMods)
+	if err != nil {
+		klog.Errorf("UpdateFloodFlows: OfctlBundle error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Update replaces the flows of all the networks for the local ip, and deletes
+// the flows of the networks not in NetList with the MACs learned on them, in
+// one bundle.
+func (this FlowTableRole) Update(localIP string, NetList []*TunNet, members map[string][]string) error {
+	cookies, err := this.getNetworkCookies()
+	if err != nil {
+		klog.Warningf("Update: getNetworkCookies error: %v", err)
+	}
+	for _, net := range NetList {
+		delete(cookies, getNetworkCookie(net.Vni))
+	}
+	staleCookies := make([]uint64, 0, len(cookies))
+	for cookie := range cookies {
+		staleCookies = append(staleCookies, cookie)
+	}
+	sort.Slice(staleCookies, func(i, j int) bool { return staleCookies[i] < staleCookies[j] })
+
+	flowMods := make([]string, 0)
+	for _, cookie := range staleCookies {
+		flowMods = append(flowMods, this.getDelNetworkFlowMod(cookie))
+	}
 	for _, net := range NetList {
-		flow1 := fmt.Sprintf("table=4, priority=1, tun_id=%d, actions=mod_vlan_vid:%s,resubmit(,10)",
-			net.Vni, net.VlanID)
-		this.addFlow(flow1)
-		flow2 := fmt.Sprintf("table=21, priority=1, dl_vlan=%s, actions=strip_vlan,set_tunnel:%d%s",
-			net.VlanID, net.Vni, outputStr)
-		this.addFlow(flow2)
+		flowMods = append(flowMods, this.getNetworkFlowMods(net, localIP, members[net.ID])...)
+	}
+	_, err = this.bridgeRole.OfctlBundle(constvalue.OvsBrtun, flowMods)
+	if err != nil {
+		klog.Errorf("Update: OfctlBundle error: %v", err)
+		return err
 	}
+	return nil
 }
 
-func (self FlowTableRole) getOutput(PortList []*TunPort) string {
+func (self FlowTableRole) getOutput(remoteIPs []string) string {
 	var outputList string
-	for _, port := range PortList {
-		if port.ID == InvalidTunnulPortID &&
-			port.Name == strconv.Itoa(InvalidTunnulPortID) {
-			continue
+	for _, ip := range remoteIPs {
+		tunDst := "tun_dst"
+		if isIPv6(ip) {
+			tunDst = "tun_ipv6_dst"
 		}
-		outputList += fmt.Sprintf(",output:%d", port.ID)
+		outputList += fmt.Sprintf(",set_field:%s->%s,output:%d", ip, tunDst, constvalue.BrtunTunnelOfPort)
 	}
-	klog.Info("Update-FLOW-TABLE-with-ports[", outputList, "]")
+	klog.Info("Update-FLOW-TABLE-with-agents[", outputList, "]")
 	return outputList
 }
@@ -0,0 +1,117 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package brtunsubrole
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+)
+
+func TestFlowTableRole(t *testing.T) {
+	var cmds []string
+	var flowMods [][]string
+	dumpOutput := ""
+	var bundleErr error
+	stubs := gostub.Stub(&osencap.Exec, func(cmd string, args ...string) (string, error) {
+		line := cmd + " " + strings.Join(args, " ")
+		cmds = append(cmds, line)
+		if strings.Contains(line, "add-flows") {
+			content, _ := ioutil.ReadFile(args[len(args)-1])
+			flowMods = append(flowMods, strings.Split(strings.TrimSpace(string(content)), "\n"))
+			if strings.Contains(line, "--bundle") {
+				return "", bundleErr
+			}
+		}
+		if strings.Contains(line, "dump-flows") {
+			return dumpOutput, nil
+		}
+		return "", nil
+	})
+	defer stubs.Reset()
+
+	flowTable := FlowTableRole{}
+	net1 := &TunNet{ID: "net1", Vni: 100, VlanID: "2"}
+	net2 := &TunNet{ID: "net2", Vni: 200, VlanID: "3"}
+	remoteIPs := []string{"192.168.1.2", "192.168.1.3"}
+
+	Convey("TestFlowTableRole", t, func() {
+		cmds, flowMods, dumpOutput, bundleErr = nil, nil, "", nil
+
+		Convey("add and remove network\n", func() {
+			So(flowTable.AddNetworkFlows(net1, "192.168.1.1", remoteIPs), ShouldBeNil)
+			So(cmds, ShouldHaveLength, 1)
+			So(cmds[0], ShouldStartWith, "ovs-ofctl -O OpenFlow14 --bundle add-flows br-tun ")
+			So(flowMods[0], ShouldResemble, []string{
+				"add cookie=0x4b5400000064,table=4,priority=1,tun_id=100,actions=mod_vlan_vid:2,resubmit(,10)",
+				"add cookie=0x4b5400000064,table=10,priority=1,dl_vlan=2,actions=learn(table=20,cookie=0x4b5400000064," +
+					"priority=1,hard_timeout=300,NXM_OF_VLAN_TCI[0..11],NXM_OF_ETH_DST[]=NXM_OF_ETH_SRC[]," +
+					"load:0->NXM_OF_VLAN_TCI[],load:NXM_NX_TUN_ID[]->NXM_NX_TUN_ID[]," +
+					"load:NXM_NX_TUN_IPV4_SRC[]->NXM_NX_TUN_IPV4_DST[],output:NXM_OF_IN_PORT[]),output:1",
+				"add cookie=0x4b5400000064,table=21,priority=1,dl_vlan=2,actions=strip_vlan,set_tunnel:100," +
+					"set_field:192.168.1.2->tun_dst,output:2,set_field:192.168.1.3->tun_dst,output:2"})
+
+			So(flowTable.RemoveNetworkFlows(net1), ShouldBeNil)
+			So(flowMods[1], ShouldResemble, []string{"delete cookie=0x4b5400000064/-1"})
+		})
+
+		Convey("update replaces flows and deletes stale networks\n", func() {
+			dumpOutput = "NXST_FLOW reply (xid=0x4):\n" +
+				" cookie=0x4b5400000064, duration=1.0s, table=4, n_packets=0, priority=1,tun_id=0x64 actions=...\n" +
+				" cookie=0x4b540000012c, duration=1.0s, table=4, n_packets=0, priority=1,tun_id=0x12c actions=...\n" +
+				" cookie=0x4b540000012c, duration=1.0s, table=21, n_packets=0, priority=1,dl_vlan=4 actions=...\n"
+			So(flowTable.Update("192.168.1.1", []*TunNet{net1, net2}, map[string][]string{"net1": remoteIPs[:1]}), ShouldBeNil)
+			So(cmds[0], ShouldEqual, "ovs-ofctl -O OpenFlow10 dump-flows br-tun cookie=0x4b5400000000/0xffffffff00000000")
+			So(flowMods, ShouldHaveLength, 1)
+			So(flowMods[0], ShouldResemble, []string{
+				"delete cookie=0x4b540000012c/-1",
+				"add cookie=0x4b5400000064,table=4,priority=1,tun_id=100,actions=mod_vlan_vid:2,resubmit(,10)",
+				"add cookie=0x4b5400000064,table=10,priority=1,dl_vlan=2,actions=learn(table=20,cookie=0x4b5400000064," +
+					"priority=1,hard_timeout=300,NXM_OF_VLAN_TCI[0..11],NXM_OF_ETH_DST[]=NXM_OF_ETH_SRC[]," +
+					"load:0->NXM_OF_VLAN_TCI[],load:NXM_NX_TUN_ID[]->NXM_NX_TUN_ID[]," +
+					"load:NXM_NX_TUN_IPV4_SRC[]->NXM_NX_TUN_IPV4_DST[],output:NXM_OF_IN_PORT[]),output:1",
+				"add cookie=0x4b5400000064,table=21,priority=1,dl_vlan=2,actions=strip_vlan,set_tunnel:100," +
+					"set_field:192.168.1.2->tun_dst,output:2",
+				"add cookie=0x4b54000000c8,table=4,priority=1,tun_id=200,actions=mod_vlan_vid:3,resubmit(,10)",
+				"add cookie=0x4b54000000c8,table=10,priority=1,dl_vlan=3,actions=learn(table=20,cookie=0x4b54000000c8," +
+					"priority=1,hard_timeout=300,NXM_OF_VLAN_TCI[0..11],NXM_OF_ETH_DST[]=NXM_OF_ETH_SRC[]," +
+					"load:0->NXM_OF_VLAN_TCI[],load:NXM_NX_TUN_ID[]->NXM_NX_TUN_ID[]," +
+					"load:NXM_NX_TUN_IPV4_SRC[]->NXM_NX_TUN_IPV4_DST[],output:NXM_OF_IN_PORT[]),output:1",
+				"add cookie=0x4b54000000c8,table=21,priority=1,dl_vlan=3,actions=strip_vlan,set_tunnel:200"})
+		})
+
+		Convey("update flooding flows of the networks\n", func() {
+			So(flowTable.UpdateFloodFlows(nil, nil), ShouldBeNil)
+			So(cmds, ShouldBeEmpty)
+			So(flowTable.UpdateFloodFlows([]*TunNet{net2}, map[string][]string{"net2": {"fd00::2"}}), ShouldBeNil)
+			So(flowMods[0], ShouldResemble, []string{
+				"add cookie=0x4b54000000c8,table=21,priority=1,dl_vlan=3,actions=strip_vlan,set_tunnel:200," +
+					"set_field:fd00::2->tun_ipv6_dst,output:2"})
+		})
+
+		Convey("flows are added without bundle if bundle is unsupported\n", func() {
+			bundleErr = errors.New("OFPBFC_BAD_VERSION")
+			So(flowTable.AddNetworkFlows(net2, "fd00::1", nil), ShouldBeNil)
+			So(cmds, ShouldHaveLength, 2)
+			So(cmds[1], ShouldStartWith, "ovs-ofctl -O OpenFlow10 add-flows br-tun ")
+			So(flowMods[1], ShouldResemble, flowMods[0])
+		})
+	})
+}
@@ -17,9 +17,13 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"errors"
+	"fmt"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
 	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"strconv"
@@ -52,6 +56,17 @@ func getManager() string {
 var clientSyncWithManager *SyncClientRole = &SyncClientRole{
 	Host: getManager(), API: "/tenants/admin/sync"}
 
+// syncTrigger asks HeartBeat to sync at once, the other agents know the
+// changes of the local networks earlier.
+var syncTrigger = make(chan struct{}, 1)
+
+func triggerSync() {
+	select {
+	case syncTrigger <- struct{}{}:
+	default:
+	}
+}
+
 func (this *SyncClientRole) Init() error {
 	this.Host = clientSyncWithManager.Host
 	this.API = clientSyncWithManager.API
@@ -68,8 +83,15 @@ func (this *SyncClientRole) Init() error {
 	return nil
 }
 
+// getSyncURL carries the tunnel of the agent, knitter-manager rejects it if
+// the other agents of the cluster use another one. The local networks are
+// carried too, so the other agents flood their broadcast to this agent.
 func (this *SyncClientRole) getSyncURL() string {
-	return this.Host + this.API + "/" + this.InternalIP
+	tunnel := cni.GetGlobalContext().Tunnel
+	networkIDs := GetFlowMgrSingleton().GetNetworkIDs()
+	return fmt.Sprintf("%s%s/%s?tunnel_type=%s&tunnel_dst_port=%d&network_ids=%s",
+		this.Host, this.API, this.InternalIP, tunnel.Type, tunnel.DstPort,
+		url.QueryEscape(strings.Join(networkIDs, ",")))
 }
 
 func (this *SyncClientRole) syncToMgr() (*http.Response, error) {
@@ -89,8 +111,9 @@ func (this *SyncClientRole) syncToMgr() (*http.Response, error) {
 func (self *SyncClientRole) getTopoData(resp *http.Response) (*dbaccessor.Sync, error) {
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(resp.Body)
 		klog.Error("Get Request to Manager return ERROR:[",
-			resp.StatusCode, "] vs 200 wanted.")
+			resp.StatusCode, "] vs 200 wanted, body: ", string(body))
 		return nil, errors.New("respond-code-is-error")
 	}
 
@@ -151,12 +174,69 @@ func (self *SyncClientRole) getInterVal() int {
 	return intervalSecond
 }
 
+// membersWatchClient waits longer than knitter-manager holds a members watch.
+var membersWatchClient = &http.Client{Timeout: 2 * time.Minute}
+
+func (this *SyncClientRole) getMembersWatchURL(version string) string {
+	return fmt.Sprintf("%s%s/%s/members?members_version=%s",
+		this.Host, this.API, this.InternalIP, url.QueryEscape(version))
+}
+
+// watchMembers returns the members version once it is changed from the
+// version, or when knitter-manager ends the watch.
+func (this *SyncClientRole) watchMembers(version string) (string, error) {
+	resp, err := membersWatchClient.Get(this.getMembersWatchURL(version))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("watch members return[%d]: %s", resp.StatusCode, string(body))
+	}
+	var watchRsp dbaccessor.MembersWatchRsp
+	err = json.Unmarshal(body, &watchRsp)
+	if err != nil {
+		return "", err
+	}
+	return watchRsp.MembersVersion, nil
+}
+
+// WatchMembers triggers a sync once the members of the networks are changed
+// by another agent, so the broadcast of a network reaches its new members at
+// once instead of on the next heartbeat.
+func (this *SyncClientRole) WatchMembers(version string) {
+	for {
+		newVersion, err := this.watchMembers(version)
+		if err != nil {
+			klog.Warningf("WatchMembers: watchMembers error: %v, retry later", err)
+			time.Sleep(time.Duration(dbaccessor.DefaultIntervalTimeInSecond) * time.Second)
+			continue
+		}
+		if newVersion != version {
+			klog.Infof("WatchMembers: members version changed to %s", newVersion)
+			version = newVersion
+			triggerSync()
+		}
+	}
+}
+
 func (this *SyncClientRole) HeartBeat() {
 	intervalSecond := this.getInterVal()
 	klog.Trace("Sync-interval-time-is:", intervalSecond, " seconds")
 	cycleTimer := time.NewTicker(time.Duration(intervalSecond) * time.Second)
 	for {
 		select {
+		case <-syncTrigger:
+			topo, err := this.Sync()
+			if err != nil {
+				klog.Warningf("SYNC ERROR:%v", err)
+				break
+			}
+			this.FlowMgrRole.Sync(topo)
 		case <-cycleTimer.C:
 			topo, err := this.Sync()
 			if err != nil {
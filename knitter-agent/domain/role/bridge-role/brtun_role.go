@@ -61,6 +61,7 @@ func (this *BrtunRole) SyncSwitch(cfg *jason.Object) bool {
 }
 
 func (this *BrtunRole) StartSync() {
+	go this.SyncClientRole.WatchMembers(this.SyncClientRole.Data.MembersVersion)
 	for {
 		this.SyncClientRole.HeartBeat()
 	}
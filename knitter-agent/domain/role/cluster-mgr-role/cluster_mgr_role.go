@@ -15,8 +15,13 @@ package clustermgrrole
 
 import (
 	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
 )
 
 type ClusterMgrRole interface {
 	GetPod(podNs, podName string) (int, *jason.Object, error)
+	RecordPodEvent(podNs, podName, eventType, reason, message string) error
+	SetPodNetworkReady(podNs, podName string, ready bool, reason, message string) error
+	SetPodNetworkStatus(podNs, podName string, statuses []netattachdef.NetworkStatus) error
 }
@@ -14,7 +14,13 @@ limitations under the License.
 package clustermgrrole
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/pkg/k8s-event"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
 	"github.com/antonholmquist/jason"
 )
 
@@ -29,3 +35,40 @@ func (this *K8sRole) GetPod(podNs, podName string) (int, *jason.Object, error) {
 	}
 	return statusCode, podJSON, nil
 }
+
+func (this *K8sRole) RecordPodEvent(podNs, podName, eventType, reason, message string) error {
+	agtCtx := cni.GetGlobalContext()
+	// kubectl describe finds the events of pod by its uid
+	podUID := ""
+	_, podJSON, err := agtCtx.K8s.GetPod(podNs, podName)
+	if err == nil && podJSON != nil {
+		podUID, _ = podJSON.GetString("metadata", "uid")
+	} else {
+		klog.Warningf("RecordPodEvent: GetPod(%v/%v) error: %v, record event without uid", podNs, podName, err)
+	}
+	event := k8sevent.NewPodEvent(podNs, podName, podUID, k8sevent.ComponentAgent, agtCtx.HostIP,
+		eventType, reason, message)
+	eventByte, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%v:marshal event error", err)
+	}
+	return agtCtx.K8s.CreateEvent(podNs, eventByte)
+}
+
+func (this *K8sRole) SetPodNetworkReady(podNs, podName string, ready bool, reason, message string) error {
+	agtCtx := cni.GetGlobalContext()
+	patch, err := k8sevent.NewNetworkReadyPatch(ready, reason, message)
+	if err != nil {
+		return fmt.Errorf("%v:new network ready patch error", err)
+	}
+	return agtCtx.K8s.PatchPodStatus(podNs, podName, patch)
+}
+
+func (this *K8sRole) SetPodNetworkStatus(podNs, podName string, statuses []netattachdef.NetworkStatus) error {
+	agtCtx := cni.GetGlobalContext()
+	patch, err := netattachdef.NewNetworkStatusPatch(statuses)
+	if err != nil {
+		return fmt.Errorf("%v:new network status patch error", err)
+	}
+	return agtCtx.K8s.PatchPod(podNs, podName, patch)
+}
@@ -81,6 +81,30 @@ func (this PodRole) Delete(cniParam *cni.CniParam) {
 	podObjRepo.Remove(cniParam)
 }
 
+// SaveCniArgs stores the CNI args of the attached pod in local DB, they are
+// used to hot-plug interfaces into the running pod.
+func (this PodRole) SaveCniArgs(cniParam *cni.CniParam, reqBody []byte) error {
+	agtCtx := cni.GetGlobalContext()
+	key := dbaccessor.GetKeyOfCniArgsForPod(cniParam.TenantID, cniParam.PodNs, cniParam.PodName)
+	err := agtCtx.DB.SaveLeaf(key, string(reqBody))
+	if err != nil {
+		klog.Errorf("SaveCniArgs: DB.SaveLeaf(%v) error: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+func (this PodRole) GetCniArgs(tenantID, podNs, podName string) ([]byte, error) {
+	agtCtx := cni.GetGlobalContext()
+	key := dbaccessor.GetKeyOfCniArgsForPod(tenantID, podNs, podName)
+	value, err := agtCtx.DB.ReadLeaf(key)
+	if err != nil {
+		klog.Errorf("GetCniArgs: DB.ReadLeaf(%v) error: %v", key, err)
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
 func (this PodRole) IsMigration(cniParam *cni.CniParam) bool {
 	agtCtx := cni.GetGlobalContext()
 	keyOfVMID := dbaccessor.GetKeyOfVmidForPod(cniParam.TenantID,
@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
 	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/pod-obj"
 	"github.com/ZTE/Knitter/knitter-agent/infra"
 	"github.com/ZTE/Knitter/pkg/klog"
@@ -29,8 +30,7 @@ type NetConfigFileRole struct {
 func (this NetConfigFileRole) Create(podObj *podobj.PodObj, nics []bind.Dpdknic) error {
 	nicDevs := bind.Nicdevs{}
 	nicDevs.Dpdknics = nics
-	agtCtx := cni.GetGlobalContext()
-	nicDevs.MTU = agtCtx.Mtu
+	nicDevs.MTU = getDefaultNicMtu(nics)
 	var busInfoPath string
 	busInfoPath = "/var/lib/kubelet/pods/" + podObj.PodID + "/volumes/kubernetes.io~empty-dir/config"
 	_, err := os.Stat(busInfoPath)
@@ -58,3 +58,17 @@ func (this NetConfigFileRole) Create(podObj *podobj.PodObj, nics []bind.Dpdknic)
 	klog.Infof("cmdAdd success!")
 	return nil
 }
+
+// getDefaultNicMtu returns the MTU of eth0 for the readers of the pod level
+// mtu, each nic carries the MTU of its own network.
+func getDefaultNicMtu(nics []bind.Dpdknic) string {
+	for _, nic := range nics {
+		if nic.Name == constvalue.DefaultPortName {
+			return nic.MTU
+		}
+	}
+	if len(nics) > 0 {
+		return nics[0].MTU
+	}
+	return cni.GetGlobalContext().GetPodMtu(0, true)
+}
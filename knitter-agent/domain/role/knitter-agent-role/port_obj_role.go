@@ -53,9 +53,13 @@ func build(portObj *portobj.PortObj, port *iaasaccessor.Interface) {
 	portObj.EagerAttr.PodName = port.PodName
 	portObj.EagerAttr.Accelerate = port.Accelerate
 	portObj.LazyAttr.ID = port.Id
-	portObj.LazyAttr.TenantID = port.PodNs
+	portObj.LazyAttr.TenantID = port.TenantID
+	if portObj.LazyAttr.TenantID == "" {
+		portObj.LazyAttr.TenantID = port.PodNs
+	}
 	portObj.LazyAttr.NetAttr.ID = port.NetworkId
 	portObj.LazyAttr.BusInfos = port.BusInfos
+	portObj.LazyAttr.BondInfo.BondType = port.BondMode
 	portObj.LazyAttr.MacAddress = port.MacAddress
 	portObj.LazyAttr.OrgDriver = port.OrgDriver
 }
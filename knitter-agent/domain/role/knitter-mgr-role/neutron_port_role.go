@@ -50,7 +50,8 @@ func (this NeutronPortRole) Create(tenantID string, podName string, eagerAttr *p
 		klog.Errorf("NeutronPortRole:Create:mport.Extract error! -%v", err)
 		return nil, err
 	}
-	mport.MTU = agtCtx.Mtu
+	// the neutron port is a nic of the vm, the overlay is out of the vm
+	mport.MTU = agtCtx.GetPodMtu(0, false)
 	mport.NetworkName = eagerAttr.NetworkName
 	return mport, nil
 }
@@ -50,12 +50,31 @@ func (this *PortRole) Attach(cniObj *cni.CniParam, port *manager.Port, vethNameO
 	return nic, nil
 }
 
+// AttachBond makes the bond of the port in pod over the slave links, the nic
+// returned carries the bond mode and the PCI addresses of the VF slaves.
+func (this *PortRole) AttachBond(cniObj *cni.CniParam, port *manager.Port, conf *bind.BondConf,
+	slaveLinks []*bind.Link, busInfos []string) (*bind.Dpdknic, error) {
+	err := bind.AttachBondToPod(cniObj.Netns, port, conf, slaveLinks)
+	if err != nil {
+		klog.Errorf("PortRole:AttachBond:bind.AttachBondToPod error: %v", err)
+		return nil, errobj.ErrAttachBondToPodFailed
+	}
+	nic, err := bind.BuildNormalNic(port, "")
+	if err != nil {
+		klog.Errorf("PortRole:AttachBond:adapter.BuildNormalNic error: %v", err)
+		return nil, errobj.ErrBuildNormalNicFailed
+	}
+	nic.BusInfos = busInfos
+	nic.BondMode = conf.Mode
+	return nic, nil
+}
+
 func (this *PortRole) StoreToDB(db dbaccessor.DbAccessor, mport *manager.Port,
 	portObj *portobj.PortObj, businfo string) error {
 	pod := bind.NewPod(this.podDataRole.PodName, this.podDataRole.PodID,
 		this.podDataRole.PodNs, this.podDataRole.PodType)
 	etcdPort := this.makePaasPort(mport, portObj, businfo)
-	err := storeSaveInterface(db, this.podDataRole.PodNs, pod, mport, etcdPort)
+	err := storeSaveInterface(db, this.podDataRole.TenantID, pod, mport, etcdPort)
 	if err != nil {
 		return err
 	}
@@ -97,11 +116,13 @@ func (this *PortRole) makePaasPort(iaasPort *manager.Port, portObj *portobj.Port
 		BusInfo:      businfo,
 		NetPlane:     portObj.EagerAttr.NetworkPlane,
 		NetPlaneName: portObj.EagerAttr.NetworkName,
-		TenantID:     iaasPort.TenantID,
+		TenantID:     this.podDataRole.TenantID,
 		NicType:      portObj.EagerAttr.VnicType,
 		PodName:      this.podDataRole.PodName,
 		PodNs:        this.podDataRole.PodNs,
 		Accelerate:   "false",
+		BusInfos:     portObj.LazyAttr.BusInfos,
+		BondMode:     portObj.LazyAttr.BondInfo.BondType,
 	}
 	return paasPort
 }
@@ -35,6 +35,8 @@ type PortBuilderRole struct {
 	Accelerate   string
 	FixIP        string
 	IPGroupName  string
+	Bond         *monitor.PortBond
+	Qos          *monitor.PortQos
 }
 
 func (this *PortBuilderRole) Transform(port *monitor.Port) error {
@@ -108,6 +110,16 @@ func (this *PortBuilderRole) Transform(port *monitor.Port) error {
 	}
 
 	ipGroupName := port.EagerAttr.IPGroupName
+	bond := port.EagerAttr.Bond
+	if bond != nil && !isBondMode(bond.Mode) {
+		klog.Errorf("bond mode[%s] is illegal", bond.Mode)
+		return errors.New("bond mode is illegal")
+	}
+	qos := port.EagerAttr.Qos
+	if qos != nil && qos.Dscp != nil && (*qos.Dscp < 0 || *qos.Dscp > constvalue.QosMaxDscp) {
+		klog.Errorf("qos dscp[%d] is illegal", *qos.Dscp)
+		return errors.New("qos dscp is illegal")
+	}
 	this.NetworkName = networkName
 	this.NetworkPlane = portFunc
 	this.PortName = portName
@@ -115,6 +127,8 @@ func (this *PortBuilderRole) Transform(port *monitor.Port) error {
 	this.Accelerate = isUseDpdk
 	this.FixIP = ipAddress
 	this.IPGroupName = ipGroupName
+	this.Bond = bond
+	this.Qos = qos
 
 	return nil
 }
@@ -147,6 +161,18 @@ func isVnicType(vnicType string) bool {
 	return false
 }
 
+func isBondMode(bondMode string) bool {
+	switch bondMode {
+	case constvalue.BondBackup:
+		return true
+	case constvalue.Bond8023AD:
+		return true
+	case constvalue.BondBalance:
+		return true
+	}
+	return false
+}
+
 func isIPLegitimate(ipAddress string) (bool, error) {
 	return regexp.MatchString(ipReg, ipAddress)
 }
@@ -0,0 +1,81 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sriov
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const DefaultSysfsRoot = "/sys"
+
+func (self *VfManager) pfDevicePath(pf string) string {
+	return filepath.Join(self.SysfsRoot, "class", "net", pf, "device")
+}
+
+func (self *VfManager) vfDevicePath(pf string, index int) string {
+	return filepath.Join(self.pfDevicePath(pf), "virtfn"+strconv.Itoa(index))
+}
+
+func readSysfsInt(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("%v:parse %s error", err, path)
+	}
+	return value, nil
+}
+
+func (self *VfManager) GetTotalVfs(pf string) (int, error) {
+	return readSysfsInt(filepath.Join(self.pfDevicePath(pf), "sriov_totalvfs"))
+}
+
+func (self *VfManager) GetNumVfs(pf string) (int, error) {
+	return readSysfsInt(filepath.Join(self.pfDevicePath(pf), "sriov_numvfs"))
+}
+
+func (self *VfManager) setNumVfs(pf string, num int) error {
+	path := filepath.Join(self.pfDevicePath(pf), "sriov_numvfs")
+	return ioutil.WriteFile(path, []byte(strconv.Itoa(num)), 0644)
+}
+
+// GetVfPciAddr returns the PCI address of the VF, virtfnN of the PF device is
+// a link to the device of the VF.
+func (self *VfManager) GetVfPciAddr(pf string, index int) (string, error) {
+	dest, err := os.Readlink(self.vfDevicePath(pf, index))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(dest), nil
+}
+
+// GetVfNetdevName returns the name of the VF in the host network namespace,
+// it fails when the VF has been moved into a pod or bound to a userspace driver.
+func (self *VfManager) GetVfNetdevName(pf string, index int) (string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(self.vfDevicePath(pf, index), "net"))
+	if err != nil {
+		return "", err
+	}
+	if len(infos) == 0 {
+		return "", fmt.Errorf("vf[%d] of pf[%s] has no netdev", index, pf)
+	}
+	return infos[0].Name(), nil
+}
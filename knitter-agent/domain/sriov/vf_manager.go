@@ -0,0 +1,585 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sriov
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/infra/util/base"
+	"github.com/ZTE/Knitter/knitter-agent/infra/util/implement"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// link states of VF, the same as IFLA_VF_LINK_STATE_*
+const (
+	VfLinkStateAuto    uint32 = 0
+	VfLinkStateEnable  uint32 = 1
+	VfLinkStateDisable uint32 = 2
+)
+
+const ZeroMacAddress = "00:00:00:00:00:00"
+
+var (
+	ErrPfNotConfigured = errors.New("no pf is configured for the physical network")
+	ErrNoFreeVf        = errors.New("no free vf")
+	ErrVfNotFound      = errors.New("vf not found")
+	ErrVfInUse         = errors.New("vf is used by another port")
+)
+
+// Vf is the record of a VF in the local DB, a used VF belongs to a port and a
+// reserved VF is allocated to a pod by kubelet through the device plugin.
+type Vf struct {
+	Pf           string `json:"pf"`
+	Index        int    `json:"index"`
+	PciAddr      string `json:"pci_addr"`
+	Name         string `json:"name"`
+	Used         bool   `json:"used"`
+	Reserved     bool   `json:"reserved"`
+	ReservedTime int64  `json:"reserved_time,omitempty"`
+	PortID       string `json:"port_id"`
+	ContainerID  string `json:"container_id"`
+}
+
+// VfConfig is set on the VF through its PF before the VF is moved into pod.
+type VfConfig struct {
+	MacAddress string
+	Vlan       int
+	SpoofChk   bool
+	Trust      bool
+	LinkState  uint32
+}
+
+var resetVfConfig = VfConfig{
+	MacAddress: ZeroMacAddress,
+	SpoofChk:   true,
+	LinkState:  VfLinkStateAuto,
+}
+
+type VfManager struct {
+	SysfsRoot     string
+	Netlink       base.NetLink
+	DB            dbaccessor.DbAccessor
+	PhysnetPfMap  map[string]string
+	DefaultMaxVfs int
+	VfNumMap      map[string]int
+	VfRanges      map[string]cni.Range
+	lock          sync.Mutex
+}
+
+var (
+	vfManager     *VfManager
+	vfManagerOnce sync.Once
+)
+
+var GetVfManagerSingleton = func() *VfManager {
+	vfManagerOnce.Do(func() {
+		vfManager = NewVfManager(cni.GetGlobalContext())
+	})
+	return vfManager
+}
+
+func NewVfManager(agtCtx *cni.AgentContext) *VfManager {
+	mgr := &VfManager{
+		SysfsRoot:     DefaultSysfsRoot,
+		Netlink:       &implement.NetLink{},
+		DB:            agtCtx.DB,
+		PhysnetPfMap:  make(map[string]string),
+		DefaultMaxVfs: agtCtx.DefaultMaxVfs,
+		VfNumMap:      make(map[string]int),
+		VfRanges:      make(map[string]cni.Range),
+	}
+	if agtCtx.PhysnetPfMap != nil {
+		mgr.PhysnetPfMap = *agtCtx.PhysnetPfMap
+	}
+	if agtCtx.VfNumMap != nil {
+		mgr.VfNumMap = *agtCtx.VfNumMap
+	}
+	if agtCtx.VfRangeConfigured && agtCtx.VfRanges != nil {
+		mgr.VfRanges = *agtCtx.VfRanges
+	}
+	return mgr
+}
+
+func (self *VfManager) GetPfs() []string {
+	pfs := make([]string, 0)
+	for _, pf := range self.PhysnetPfMap {
+		if !isStringInSlice(pf, pfs) {
+			pfs = append(pfs, pf)
+		}
+	}
+	sort.Strings(pfs)
+	return pfs
+}
+
+func isStringInSlice(str string, strs []string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// InitVfs creates the VFs on all configured PFs and syncs the VF records with
+// them, the free VFs are reset. A failed PF doesn't stop the others.
+func (self *VfManager) InitVfs() error {
+	var firstErr error
+	for _, pf := range self.GetPfs() {
+		err := self.initVfsOfPf(pf)
+		if err != nil {
+			klog.Errorf("VfManager.InitVfs: init vfs of pf[%s] error: %v", pf, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (self *VfManager) initVfsOfPf(pf string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	vfs, err := self.listVfs(pf)
+	if err != nil {
+		return err
+	}
+	num, err := self.createVfs(pf, vfs)
+	if err != nil {
+		return err
+	}
+
+	start, end := self.getVfRange(pf, num)
+	records := make(map[int]*Vf)
+	for _, vf := range vfs {
+		if vf.Index >= start && vf.Index <= end {
+			records[vf.Index] = vf
+			continue
+		}
+		if vf.Used {
+			klog.Warningf("VfManager.initVfsOfPf: vf[%d] of pf[%s] out of range is used by port[%s], keep it",
+				vf.Index, pf, vf.PortID)
+			continue
+		}
+		self.deleteVf(pf, vf.Index)
+	}
+
+	for index := start; index <= end; index++ {
+		record, ok := records[index]
+		if ok && record.Used {
+			continue
+		}
+		vf := &Vf{Pf: pf, Index: index}
+		if ok && record.Reserved {
+			vf.Reserved, vf.ReservedTime = true, record.ReservedTime
+		}
+		vf.PciAddr, err = self.GetVfPciAddr(pf, index)
+		if err != nil {
+			klog.Errorf("VfManager.initVfsOfPf: get pci address of vf[%d] of pf[%s] error: %v", index, pf, err)
+			return err
+		}
+		vf.Name, _ = self.GetVfNetdevName(pf, index)
+		err = self.ResetVf(vf)
+		if err != nil {
+			klog.Warningf("VfManager.initVfsOfPf: reset vf[%d] of pf[%s] error: %v", index, pf, err)
+		}
+		err = self.saveVf(vf)
+		if err != nil {
+			return err
+		}
+	}
+	klog.Infof("VfManager.initVfsOfPf: pf[%s] has %d vfs, vfs[%d-%d] are managed", pf, num, start, end)
+	return nil
+}
+
+// createVfs sets sriov_numvfs of the PF to vf_num of the PF or default_max_vfs.
+// The number of VFs can't be changed without removing all VFs first, so the
+// VFs are kept as they are if any of them is used.
+func (self *VfManager) createVfs(pf string, vfs []*Vf) (int, error) {
+	total, err := self.GetTotalVfs(pf)
+	if err != nil {
+		klog.Errorf("VfManager.createVfs: get total vfs of pf[%s] error: %v", pf, err)
+		return 0, err
+	}
+	num, err := self.GetNumVfs(pf)
+	if err != nil {
+		klog.Errorf("VfManager.createVfs: get num vfs of pf[%s] error: %v", pf, err)
+		return 0, err
+	}
+
+	// the VFs made by others are kept unless vf_num of the PF is configured
+	wanted, configured := self.VfNumMap[pf]
+	if !configured {
+		if num > 0 {
+			return num, nil
+		}
+		wanted = self.DefaultMaxVfs
+	}
+	if wanted > total {
+		klog.Warningf("VfManager.createVfs: pf[%s] supports %d vfs at most, less than %d", pf, total, wanted)
+		wanted = total
+	}
+	if num == wanted {
+		return num, nil
+	}
+	for _, vf := range vfs {
+		if vf.Used {
+			klog.Warningf("VfManager.createVfs: vf[%d] of pf[%s] is used, keep %d vfs", vf.Index, pf, num)
+			return num, nil
+		}
+	}
+
+	if num > 0 {
+		err = self.setNumVfs(pf, 0)
+		if err != nil {
+			klog.Errorf("VfManager.createVfs: remove vfs of pf[%s] error: %v", pf, err)
+			return 0, err
+		}
+	}
+	err = self.setNumVfs(pf, wanted)
+	if err != nil {
+		klog.Errorf("VfManager.createVfs: create %d vfs on pf[%s] error: %v", wanted, pf, err)
+		return 0, err
+	}
+	klog.Infof("VfManager.createVfs: create %d vfs on pf[%s] SUCC", wanted, pf)
+	return wanted, nil
+}
+
+func (self *VfManager) getVfRange(pf string, num int) (int, int) {
+	start, end := 0, num-1
+	if vfRange, ok := self.VfRanges[pf]; ok {
+		if vfRange.Start > start {
+			start = vfRange.Start
+		}
+		if vfRange.End < end {
+			end = vfRange.End
+		}
+	}
+	return start, end
+}
+
+func (self *VfManager) getPfOfPhysnet(physnet string) (string, error) {
+	pf, ok := self.PhysnetPfMap[physnet]
+	if !ok {
+		klog.Errorf("VfManager.getPfOfPhysnet: physnet[%s] has no pf", physnet)
+		return "", ErrPfNotConfigured
+	}
+	return pf, nil
+}
+
+func (self *VfManager) ListVfsOfPhysnet(physnet string) ([]*Vf, error) {
+	pf, err := self.getPfOfPhysnet(physnet)
+	if err != nil {
+		return nil, err
+	}
+	return self.listVfs(pf)
+}
+
+// AllocVf takes a free VF of the PF of the physical network for the port, the
+// VF already taken by the port is returned again for a retried attach. VFs
+// reserved for pods by kubelet are left to them.
+func (self *VfManager) AllocVf(physnet, portID, containerID string) (*Vf, error) {
+	return self.allocVf(physnet, portID, containerID, func(vf *Vf) bool {
+		return !vf.Reserved
+	})
+}
+
+// AllocReservedVf takes one of the VFs reserved for the pod of the port.
+func (self *VfManager) AllocReservedVf(physnet string, pciAddrs []string, portID, containerID string) (*Vf, error) {
+	return self.allocVf(physnet, portID, containerID, func(vf *Vf) bool {
+		return isStringInSlice(vf.PciAddr, pciAddrs)
+	})
+}
+
+func (self *VfManager) allocVf(physnet, portID, containerID string, isCandidate func(vf *Vf) bool) (*Vf, error) {
+	pf, err := self.getPfOfPhysnet(physnet)
+	if err != nil {
+		return nil, err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	vfs, err := self.listVfs(pf)
+	if err != nil {
+		return nil, err
+	}
+	for _, vf := range vfs {
+		if vf.Used && vf.PortID == portID {
+			return vf, nil
+		}
+	}
+	for _, vf := range vfs {
+		if vf.Used || !isCandidate(vf) {
+			continue
+		}
+		name, err := self.GetVfNetdevName(pf, vf.Index)
+		if err != nil {
+			klog.Warningf("VfManager.allocVf: vf[%d] of pf[%s] is not in host, skip it, error: %v", vf.Index, pf, err)
+			continue
+		}
+		vf.Name = name
+		vf.Used = true
+		vf.PortID = portID
+		vf.ContainerID = containerID
+		err = self.saveVf(vf)
+		if err != nil {
+			return nil, err
+		}
+		klog.Infof("VfManager.allocVf: alloc vf[%s] of pf[%s] to port[%s] SUCC", vf.Name, pf, portID)
+		return vf, nil
+	}
+	klog.Errorf("VfManager.allocVf: pf[%s] has no free vf for port[%s]", pf, portID)
+	return nil, ErrNoFreeVf
+}
+
+// ReserveVfs marks the VFs allocated by kubelet, so that they are only taken
+// by the pod they are allocated to. No VF is reserved if any of them is not
+// found or used by another port.
+func (self *VfManager) ReserveVfs(physnet string, pciAddrs []string) error {
+	pf, err := self.getPfOfPhysnet(physnet)
+	if err != nil {
+		return err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	vfs, err := self.listVfs(pf)
+	if err != nil {
+		return err
+	}
+	reservedVfs := make([]*Vf, 0, len(pciAddrs))
+	for _, pciAddr := range pciAddrs {
+		var reserved *Vf
+		for _, vf := range vfs {
+			if vf.PciAddr == pciAddr {
+				reserved = vf
+			}
+		}
+		if reserved == nil {
+			klog.Errorf("VfManager.ReserveVfs: vf[%s] not found on pf[%s]", pciAddr, pf)
+			return ErrVfNotFound
+		}
+		if reserved.Used {
+			klog.Errorf("VfManager.ReserveVfs: vf[%s] is used by port[%s]", pciAddr, reserved.PortID)
+			return ErrVfInUse
+		}
+		reservedVfs = append(reservedVfs, reserved)
+	}
+	now := time.Now().Unix()
+	for _, vf := range reservedVfs {
+		vf.Reserved, vf.ReservedTime = true, now
+		err = self.saveVf(vf)
+		if err != nil {
+			return err
+		}
+	}
+	klog.Infof("VfManager.ReserveVfs: reserve vfs%v of pf[%s] SUCC", pciAddrs, pf)
+	return nil
+}
+
+// ClearStaleReservations frees the VFs reserved before the time and not used,
+// whose PCI addresses are not allocated by kubelet any more, e.g. the pod is
+// deleted.
+func (self *VfManager) ClearStaleReservations(physnet string, allocated []string, before time.Time) error {
+	pf, err := self.getPfOfPhysnet(physnet)
+	if err != nil {
+		return err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	vfs, err := self.listVfs(pf)
+	if err != nil {
+		return err
+	}
+	for _, vf := range vfs {
+		if !vf.Reserved || vf.Used || vf.ReservedTime >= before.Unix() || isStringInSlice(vf.PciAddr, allocated) {
+			continue
+		}
+		vf.Reserved, vf.ReservedTime = false, 0
+		err = self.saveVf(vf)
+		if err != nil {
+			return err
+		}
+		klog.Infof("VfManager.ClearStaleReservations: vf[%s] of pf[%s] is not allocated by kubelet, free it",
+			vf.PciAddr, pf)
+	}
+	return nil
+}
+
+func (self *VfManager) GetVfOfPort(portID string) (*Vf, error) {
+	for _, pf := range self.GetPfs() {
+		vfs, err := self.listVfs(pf)
+		if err != nil {
+			return nil, err
+		}
+		for _, vf := range vfs {
+			if vf.Used && vf.PortID == portID {
+				return vf, nil
+			}
+		}
+	}
+	return nil, ErrVfNotFound
+}
+
+// GetVfsOfPort returns all VFs taken by the port, a bonded port takes one VF
+// of each of its physnets.
+func (self *VfManager) GetVfsOfPort(portID string) ([]*Vf, error) {
+	portVfs := make([]*Vf, 0)
+	for _, pf := range self.GetPfs() {
+		vfs, err := self.listVfs(pf)
+		if err != nil {
+			return nil, err
+		}
+		for _, vf := range vfs {
+			if vf.Used && vf.PortID == portID {
+				portVfs = append(portVfs, vf)
+			}
+		}
+	}
+	return portVfs, nil
+}
+
+// ReleaseVf resets the VF and gives it back to the free VFs of its PF, it
+// should be called after the VF is moved back to host. The reservation of the
+// VF is kept, as kubelet may still have it allocated to the pod, e.g. when the
+// attach of the pod is rolled back, it is cleared by ClearStaleReservations.
+func (self *VfManager) ReleaseVf(vf *Vf) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	err := self.ResetVf(vf)
+	if err != nil {
+		klog.Warningf("VfManager.ReleaseVf: reset vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+	}
+	vf.Used = false
+	vf.PortID = ""
+	vf.ContainerID = ""
+	vf.Name, _ = self.GetVfNetdevName(vf.Pf, vf.Index)
+	err = self.saveVf(vf)
+	if err != nil {
+		return err
+	}
+	klog.Infof("VfManager.ReleaseVf: release vf[%d] of pf[%s] SUCC", vf.Index, vf.Pf)
+	return nil
+}
+
+// ConfigVf sets MAC, VLAN, spoof checking, trust and link state of the VF.
+// Drivers without trust or link state support are tolerated when the default
+// value is wanted.
+func (self *VfManager) ConfigVf(vf *Vf, conf *VfConfig) error {
+	pfLink, err := self.Netlink.LinkByName(vf.Pf)
+	if err != nil {
+		klog.Errorf("VfManager.ConfigVf: get link of pf[%s] error: %v", vf.Pf, err)
+		return err
+	}
+	if conf.MacAddress != "" {
+		mac, err := net.ParseMAC(conf.MacAddress)
+		if err != nil {
+			return fmt.Errorf("%v:parse mac[%s] of vf error", err, conf.MacAddress)
+		}
+		err = self.Netlink.LinkSetVfHardwareAddr(pfLink, vf.Index, mac)
+		if err != nil {
+			klog.Errorf("VfManager.ConfigVf: set mac of vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+			return err
+		}
+	}
+	err = self.Netlink.LinkSetVfVlan(pfLink, vf.Index, conf.Vlan)
+	if err != nil {
+		klog.Errorf("VfManager.ConfigVf: set vlan of vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+		return err
+	}
+	err = self.Netlink.LinkSetVfSpoofchk(pfLink, vf.Index, conf.SpoofChk)
+	if err != nil {
+		klog.Errorf("VfManager.ConfigVf: set spoofchk of vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+		return err
+	}
+	err = self.Netlink.LinkSetVfTrust(pfLink, vf.Index, conf.Trust)
+	if err != nil {
+		klog.Warningf("VfManager.ConfigVf: set trust of vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+		if conf.Trust {
+			return err
+		}
+	}
+	err = self.Netlink.LinkSetVfState(pfLink, vf.Index, conf.LinkState)
+	if err != nil {
+		klog.Warningf("VfManager.ConfigVf: set link state of vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+		if conf.LinkState != VfLinkStateAuto {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *VfManager) ResetVf(vf *Vf) error {
+	conf := resetVfConfig
+	return self.ConfigVf(vf, &conf)
+}
+
+// GetVfLink returns the link of the VF in host, it can be moved into pod by
+// bind.Pod.AddLinkToContainer.
+func (self *VfManager) GetVfLink(vf *Vf) (*bind.Link, error) {
+	link, err := self.Netlink.LinkByName(vf.Name)
+	if err != nil {
+		klog.Errorf("VfManager.GetVfLink: get link of vf[%s] error: %v", vf.Name, err)
+		return nil, err
+	}
+	return &bind.Link{Link: link, Netlink: self.Netlink, IsVf: true, Pf: vf.Pf}, nil
+}
+
+func (self *VfManager) listVfs(pf string) ([]*Vf, error) {
+	nodes, err := self.DB.ReadDir(dbaccessor.GetKeyOfSriovVfs(pf))
+	if err != nil {
+		klog.Errorf("VfManager.listVfs: read vfs of pf[%s] error: %v", pf, err)
+		return nil, err
+	}
+	vfs := make([]*Vf, 0)
+	for _, node := range nodes {
+		vf := &Vf{}
+		err = json.Unmarshal([]byte(node.Value), vf)
+		if err != nil {
+			klog.Warningf("VfManager.listVfs: json.Unmarshal(%s) error: %v, skip it", node.Value, err)
+			continue
+		}
+		vfs = append(vfs, vf)
+	}
+	sort.Slice(vfs, func(i, j int) bool { return vfs[i].Index < vfs[j].Index })
+	return vfs, nil
+}
+
+func (self *VfManager) saveVf(vf *Vf) error {
+	value, _ := json.Marshal(vf)
+	err := self.DB.SaveLeaf(dbaccessor.GetKeyOfSriovVf(vf.Pf, vf.Index), string(value))
+	if err != nil {
+		klog.Errorf("VfManager.saveVf: save vf[%d] of pf[%s] error: %v", vf.Index, vf.Pf, err)
+	}
+	return err
+}
+
+func (self *VfManager) deleteVf(pf string, index int) error {
+	err := self.DB.DeleteLeaf(dbaccessor.GetKeyOfSriovVf(pf, index))
+	if err != nil {
+		klog.Warningf("VfManager.deleteVf: delete vf[%d] of pf[%s] error: %v", index, pf, err)
+	}
+	return err
+}
@@ -0,0 +1,302 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sriov
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vishvananda/netlink"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/infra/util/base"
+	"github.com/ZTE/Knitter/pkg/leveldb"
+)
+
+type fakeVfNetLink struct {
+	base.NetLink
+	vfs      map[int]*VfConfig
+	trustErr error
+}
+
+func (self *fakeVfNetLink) getVf(vf int) *VfConfig {
+	if _, ok := self.vfs[vf]; !ok {
+		self.vfs[vf] = &VfConfig{}
+	}
+	return self.vfs[vf]
+}
+
+func (self *fakeVfNetLink) LinkByName(name string) (netlink.Link, error) {
+	return &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: name}}, nil
+}
+
+func (self *fakeVfNetLink) LinkSetVfHardwareAddr(link netlink.Link, vf int, mac net.HardwareAddr) error {
+	self.getVf(vf).MacAddress = mac.String()
+	return nil
+}
+
+func (self *fakeVfNetLink) LinkSetVfVlan(link netlink.Link, vf, vlan int) error {
+	self.getVf(vf).Vlan = vlan
+	return nil
+}
+
+func (self *fakeVfNetLink) LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error {
+	self.getVf(vf).SpoofChk = check
+	return nil
+}
+
+func (self *fakeVfNetLink) LinkSetVfTrust(link netlink.Link, vf int, state bool) error {
+	if self.trustErr != nil {
+		return self.trustErr
+	}
+	self.getVf(vf).Trust = state
+	return nil
+}
+
+func (self *fakeVfNetLink) LinkSetVfState(link netlink.Link, vf int, state uint32) error {
+	self.getVf(vf).LinkState = state
+	return nil
+}
+
+// makeFakeSysfs builds the sysfs tree of a PF with totalVfs VFs, virtfnN of
+// the PF links to the PCI device of VF N which has netdev <pf>vN.
+func makeFakeSysfs(root, pf string, totalVfs int) {
+	pfDevice := filepath.Join(root, "class", "net", pf, "device")
+	os.MkdirAll(pfDevice, 0755)
+	ioutil.WriteFile(filepath.Join(pfDevice, "sriov_totalvfs"), []byte(strconv.Itoa(totalVfs)+"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(pfDevice, "sriov_numvfs"), []byte("0\n"), 0644)
+	for i := 0; i < totalVfs; i++ {
+		vfDevice := filepath.Join(root, "bus", "pci", "devices", fmt.Sprintf("0000:03:10.%d", i))
+		os.MkdirAll(filepath.Join(vfDevice, "net", fmt.Sprintf("%sv%d", pf, i)), 0755)
+		os.Symlink(vfDevice, filepath.Join(pfDevice, "virtfn"+strconv.Itoa(i)))
+	}
+}
+
+func readFakeNumVfs(root, pf string) int {
+	num, _ := readSysfsInt(filepath.Join(root, "class", "net", pf, "device", "sriov_numvfs"))
+	return num
+}
+
+func newTestVfManager(t *testing.T) (*VfManager, *fakeVfNetLink, func()) {
+	root, _ := ioutil.TempDir("", "sriov")
+	makeFakeSysfs(root, "enp4s0f0", 8)
+	db, err := leveldb.NewLevelDBClient(filepath.Join(root, "db"))
+	if err != nil {
+		t.Fatalf("NewLevelDBClient error: %v", err)
+	}
+	fakeNetlink := &fakeVfNetLink{vfs: make(map[int]*VfConfig)}
+	mgr := &VfManager{
+		SysfsRoot:     root,
+		Netlink:       fakeNetlink,
+		DB:            db,
+		PhysnetPfMap:  map[string]string{"physnet1": "enp4s0f0"},
+		DefaultMaxVfs: 4,
+		VfNumMap:      make(map[string]int),
+		VfRanges:      make(map[string]cni.Range),
+	}
+	return mgr, fakeNetlink, func() { os.RemoveAll(root) }
+}
+
+func TestInitVfs(t *testing.T) {
+	Convey("TestInitVfs", t, func() {
+		mgr, fakeNetlink, clean := newTestVfManager(t)
+		defer clean()
+
+		Convey("vfs are created and recorded as free\n", func() {
+			So(mgr.InitVfs(), ShouldBeNil)
+			So(readFakeNumVfs(mgr.SysfsRoot, "enp4s0f0"), ShouldEqual, 4)
+			vfs, _ := mgr.listVfs("enp4s0f0")
+			So(len(vfs), ShouldEqual, 4)
+			So(*vfs[1], ShouldResemble, Vf{Pf: "enp4s0f0", Index: 1, PciAddr: "0000:03:10.1", Name: "enp4s0f0v1"})
+			So(*fakeNetlink.vfs[1], ShouldResemble, resetVfConfig)
+		})
+
+		Convey("vfs made by others are kept without vf_num\n", func() {
+			ioutil.WriteFile(filepath.Join(mgr.SysfsRoot, "class", "net", "enp4s0f0", "device", "sriov_numvfs"),
+				[]byte("2\n"), 0644)
+			So(mgr.InitVfs(), ShouldBeNil)
+			So(readFakeNumVfs(mgr.SysfsRoot, "enp4s0f0"), ShouldEqual, 2)
+
+			mgr.VfNumMap["enp4s0f0"] = 3
+			So(mgr.InitVfs(), ShouldBeNil)
+			So(readFakeNumVfs(mgr.SysfsRoot, "enp4s0f0"), ShouldEqual, 3)
+		})
+
+		Convey("vf_num is limited by sriov_totalvfs\n", func() {
+			mgr.VfNumMap["enp4s0f0"] = 16
+			So(mgr.InitVfs(), ShouldBeNil)
+			So(readFakeNumVfs(mgr.SysfsRoot, "enp4s0f0"), ShouldEqual, 8)
+		})
+
+		Convey("only vfs in vf_ranges are managed\n", func() {
+			So(mgr.InitVfs(), ShouldBeNil)
+			mgr.VfRanges["enp4s0f0"] = cni.Range{Start: 1, End: 2}
+			So(mgr.InitVfs(), ShouldBeNil)
+			vfs, _ := mgr.listVfs("enp4s0f0")
+			So(len(vfs), ShouldEqual, 2)
+			So(vfs[0].Index, ShouldEqual, 1)
+			So(vfs[1].Index, ShouldEqual, 2)
+		})
+
+		Convey("vfs in use are kept after restart\n", func() {
+			So(mgr.InitVfs(), ShouldBeNil)
+			vf, err := mgr.AllocVf("physnet1", "port1", "container1")
+			So(err, ShouldBeNil)
+			mgr.VfNumMap["enp4s0f0"] = 6
+			So(mgr.InitVfs(), ShouldBeNil)
+			So(readFakeNumVfs(mgr.SysfsRoot, "enp4s0f0"), ShouldEqual, 4)
+			used, err := mgr.GetVfOfPort("port1")
+			So(err, ShouldBeNil)
+			So(used, ShouldResemble, vf)
+		})
+
+		Convey("pf without sysfs fails\n", func() {
+			mgr.PhysnetPfMap["physnet2"] = "enp5s0f0"
+			So(mgr.InitVfs(), ShouldNotBeNil)
+			So(readFakeNumVfs(mgr.SysfsRoot, "enp4s0f0"), ShouldEqual, 4)
+		})
+	})
+}
+
+func TestAllocAndReleaseVf(t *testing.T) {
+	Convey("TestAllocAndReleaseVf", t, func() {
+		mgr, fakeNetlink, clean := newTestVfManager(t)
+		defer clean()
+		mgr.VfNumMap["enp4s0f0"] = 3
+		So(mgr.InitVfs(), ShouldBeNil)
+
+		Convey("free vfs are allocated in order\n", func() {
+			vf, err := mgr.AllocVf("physnet1", "port1", "container1")
+			So(err, ShouldBeNil)
+			So(vf.Index, ShouldEqual, 0)
+			So(vf.Used, ShouldBeTrue)
+			again, err := mgr.AllocVf("physnet1", "port1", "container1")
+			So(err, ShouldBeNil)
+			So(again, ShouldResemble, vf)
+
+			// vf moved into a pod out of the knowledge of agent is skipped
+			os.RemoveAll(filepath.Join(mgr.SysfsRoot, "bus", "pci", "devices", "0000:03:10.1", "net"))
+			vf, err = mgr.AllocVf("physnet1", "port2", "container1")
+			So(err, ShouldBeNil)
+			So(vf.Index, ShouldEqual, 2)
+			So(vf.Name, ShouldEqual, "enp4s0f0v2")
+
+			_, err = mgr.AllocVf("physnet1", "port3", "container1")
+			So(err, ShouldEqual, ErrNoFreeVf)
+			_, err = mgr.AllocVf("physnet2", "port3", "container1")
+			So(err, ShouldEqual, ErrPfNotConfigured)
+		})
+
+		Convey("vf is configured before moved and reset after released\n", func() {
+			vf, _ := mgr.AllocVf("physnet1", "port1", "container1")
+			conf := &VfConfig{MacAddress: "fa:16:3e:00:00:01", Vlan: 100, Trust: true, LinkState: VfLinkStateEnable}
+			So(mgr.ConfigVf(vf, conf), ShouldBeNil)
+			So(*fakeNetlink.vfs[0], ShouldResemble, *conf)
+			link, err := mgr.GetVfLink(vf)
+			So(err, ShouldBeNil)
+			So(link.IsVf, ShouldBeTrue)
+			So(link.GetName(), ShouldEqual, "enp4s0f0v0")
+
+			So(mgr.ReleaseVf(vf), ShouldBeNil)
+			So(*fakeNetlink.vfs[0], ShouldResemble, resetVfConfig)
+			_, err = mgr.GetVfOfPort("port1")
+			So(err, ShouldEqual, ErrVfNotFound)
+			vf, _ = mgr.AllocVf("physnet1", "port2", "container2")
+			So(vf.Index, ShouldEqual, 0)
+		})
+
+		Convey("vfs of port are listed\n", func() {
+			vf, _ := mgr.AllocVf("physnet1", "port1", "container1")
+			mgr.AllocVf("physnet1", "port2", "container1")
+			vfs, err := mgr.GetVfsOfPort("port1")
+			So(err, ShouldBeNil)
+			So(vfs, ShouldResemble, []*Vf{vf})
+			vfs, err = mgr.GetVfsOfPort("port3")
+			So(err, ShouldBeNil)
+			So(vfs, ShouldBeEmpty)
+		})
+
+		Convey("trust unsupported by driver is tolerated unless wanted\n", func() {
+			vf, _ := mgr.AllocVf("physnet1", "port1", "container1")
+			fakeNetlink.trustErr = errors.New("operation not supported")
+			So(mgr.ConfigVf(vf, &VfConfig{Vlan: 100}), ShouldBeNil)
+			So(mgr.ConfigVf(vf, &VfConfig{Vlan: 100, Trust: true}), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestReserveVfs(t *testing.T) {
+	Convey("TestReserveVfs", t, func() {
+		mgr, _, clean := newTestVfManager(t)
+		defer clean()
+		So(mgr.InitVfs(), ShouldBeNil)
+
+		Convey("vf used by another port is never reserved\n", func() {
+			mgr.AllocVf("physnet1", "port1", "container1")
+			So(mgr.ReserveVfs("physnet1", []string{"0000:03:10.1", "0000:03:10.0"}), ShouldEqual, ErrVfInUse)
+			So(mgr.ReserveVfs("physnet1", []string{"0000:03:10.1", "0000:03:11.0"}), ShouldEqual, ErrVfNotFound)
+			vfs, _ := mgr.listVfs("enp4s0f0")
+			So(vfs[1].Reserved, ShouldBeFalse)
+		})
+
+		Convey("stale reservations are cleared\n", func() {
+			So(mgr.ReserveVfs("physnet1", []string{"0000:03:10.0", "0000:03:10.1", "0000:03:10.2"}), ShouldBeNil)
+			_, err := mgr.AllocReservedVf("physnet1", []string{"0000:03:10.0"}, "port1", "container1")
+			So(err, ShouldBeNil)
+
+			So(mgr.ClearStaleReservations("physnet1", []string{"0000:03:10.1"}, time.Now().Add(-time.Minute)), ShouldBeNil)
+			vfs, _ := mgr.listVfs("enp4s0f0")
+			So(vfs[2].Reserved, ShouldBeTrue)
+
+			So(mgr.ClearStaleReservations("physnet1", []string{"0000:03:10.1"}, time.Now().Add(time.Minute)), ShouldBeNil)
+			vfs, _ = mgr.listVfs("enp4s0f0")
+			So(vfs[0].Reserved, ShouldBeTrue)
+			So(vfs[1].Reserved, ShouldBeTrue)
+			So(vfs[2].Reserved, ShouldBeFalse)
+			So(vfs[2].ReservedTime, ShouldEqual, 0)
+			vf, err := mgr.AllocVf("physnet1", "port2", "container2")
+			So(err, ShouldBeNil)
+			So(vf.Index, ShouldEqual, 2)
+		})
+
+		Convey("reservation is kept when the vf is released\n", func() {
+			So(mgr.ReserveVfs("physnet1", []string{"0000:03:10.0"}), ShouldBeNil)
+			vf, err := mgr.AllocReservedVf("physnet1", []string{"0000:03:10.0"}, "port1", "container1")
+			So(err, ShouldBeNil)
+			So(mgr.ReleaseVf(vf), ShouldBeNil)
+
+			vfs, _ := mgr.listVfs("enp4s0f0")
+			So(vfs[0].Used, ShouldBeFalse)
+			So(vfs[0].Reserved, ShouldBeTrue)
+			vf, err = mgr.AllocVf("physnet1", "port2", "container2")
+			So(err, ShouldBeNil)
+			So(vf.Index, ShouldEqual, 1)
+
+			So(mgr.ClearStaleReservations("physnet1", []string{"0000:03:10.0"}, time.Now().Add(time.Minute)), ShouldBeNil)
+			vfs, _ = mgr.listVfs("enp4s0f0")
+			So(vfs[0].Reserved, ShouldBeTrue)
+			So(mgr.ClearStaleReservations("physnet1", []string{}, time.Now().Add(time.Minute)), ShouldBeNil)
+			vfs, _ = mgr.listVfs("enp4s0f0")
+			So(vfs[0].Reserved, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vhostuser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// VhostUser is the dpdkvhostuserclient port made on the OVS bridge of the
+// physnet for an accelerated port. OVS connects to the socket served by the
+// DPDK application in pod, the socket is in the directory of the pod under
+// VhostUserSockDir, and only the directory of the pod needs to be mounted
+// into the pod.
+type VhostUser struct {
+	PortID      string `json:"port_id"`
+	ContainerID string `json:"container_id"`
+	Bridge      string `json:"bridge"`
+	IfName      string `json:"if_name"`
+	SocketPath  string `json:"socket_path"`
+}
+
+func NewVhostUser(portID, containerID, podID, portName, bridge string) *VhostUser {
+	return &VhostUser{
+		PortID:      portID,
+		ContainerID: containerID,
+		Bridge:      bridge,
+		IfName:      GetIfName(portID),
+		SocketPath:  filepath.Join(GetSocketDir(podID), portName+".sock"),
+	}
+}
+
+// GetIfName names the OVS interface after the port as the tap devices of
+// neutron, e.g. vhu5c2b7e3a-1f.
+func GetIfName(portID string) string {
+	ifName := constvalue.VhostUserIfPrefix + portID
+	if len(ifName) > constvalue.VhostUserIfNameMaxLen {
+		ifName = ifName[:constvalue.VhostUserIfNameMaxLen]
+	}
+	return ifName
+}
+
+var sockDir = constvalue.VhostUserSockDir
+
+// GetSocketDir returns the directory of the sockets of the pod, it is named
+// after the pod uid to be mounted with subPathExpr $(POD_UID).
+func GetSocketDir(podID string) string {
+	return filepath.Join(sockDir, podID)
+}
+
+// GetSocketName returns the name of the socket in the directory of the pod,
+// the DPDK application finds the socket by it under the mount path.
+func GetSocketName(vhostUser *VhostUser) string {
+	return filepath.Base(vhostUser.SocketPath)
+}
+
+// AddVhostUserPort adds the port to the bridge with the VLAN tag of the
+// network, no tag is set if vlanID is empty.
+var AddVhostUserPort = func(vhostUser *VhostUser, vlanID, mac string) error {
+	err := os.MkdirAll(filepath.Dir(vhostUser.SocketPath), 0755)
+	if err != nil {
+		klog.Errorf("AddVhostUserPort: make socket dir of port[%s] error: %v", vhostUser.PortID, err)
+		return err
+	}
+	portArgs := make([]string, 0)
+	if vlanID != "" {
+		portArgs = append(portArgs, "tag="+vlanID)
+	}
+	ifaceArgs := []string{"type=" + constvalue.OvsIfTypeVhostUserClient,
+		"options:vhost-server-path=" + vhostUser.SocketPath,
+		"external_ids:iface-id=" + vhostUser.PortID,
+		"external_ids:attached-mac=" + mac}
+	if client := ovs.GetOvsdbClient(); client != nil {
+		err = addVhostUserPortByOvsdb(client, vhostUser, portArgs, ifaceArgs)
+	} else {
+		args := append([]string{"--may-exist", "add-port", vhostUser.Bridge, vhostUser.IfName}, portArgs...)
+		args = append(append(args, "--", "set", "Interface", vhostUser.IfName), ifaceArgs...)
+		_, err = osencap.Exec(constvalue.OvsVsctl, args...)
+	}
+	if err != nil {
+		klog.Errorf("AddVhostUserPort: add port[%s] to bridge[%s] error: %v", vhostUser.IfName, vhostUser.Bridge, err)
+		return err
+	}
+	klog.Infof("AddVhostUserPort: add port[%s] with socket[%s] to bridge[%s] SUCC",
+		vhostUser.IfName, vhostUser.SocketPath, vhostUser.Bridge)
+	return nil
+}
+
+// addVhostUserPortByOvsdb keeps the port if it exists and sets the columns of
+// its interface, as "ovs-vsctl --may-exist add-port".
+func addVhostUserPortByOvsdb(client *ovsdb.Client, vhostUser *VhostUser, portArgs, ifaceArgs []string) error {
+	ifaceColumns, err := ovsdb.ColumnsFromArgs(ifaceArgs...)
+	if err != nil {
+		return err
+	}
+	if _, ok := client.GetPort(vhostUser.IfName); ok {
+		return client.SetColumns("Interface", vhostUser.IfName, ifaceColumns)
+	}
+	portColumns, err := ovsdb.ColumnsFromArgs(portArgs...)
+	if err != nil {
+		return err
+	}
+	return client.AddPort(vhostUser.Bridge, vhostUser.IfName, false, portColumns, ifaceColumns)
+}
+
+// DelVhostUserPort removes the port from the bridge and the socket from the
+// directory of the pod. The directory is kept, as it may be mounted into the
+// running pod, it is removed by RemoveSocketDir when the pod is deleted.
+var DelVhostUserPort = func(vhostUser *VhostUser) error {
+	var err error
+	if client := ovs.GetOvsdbClient(); client != nil {
+		err = client.DelPort(vhostUser.Bridge, vhostUser.IfName, true)
+	} else {
+		_, err = osencap.Exec(constvalue.OvsVsctl, "--if-exists", "del-port", vhostUser.Bridge, vhostUser.IfName)
+	}
+	if err != nil {
+		klog.Errorf("DelVhostUserPort: del port[%s] from bridge[%s] error: %v", vhostUser.IfName, vhostUser.Bridge, err)
+		return err
+	}
+	err = os.Remove(vhostUser.SocketPath)
+	if err != nil && !os.IsNotExist(err) {
+		klog.Warningf("DelVhostUserPort: remove socket[%s] error: %v", vhostUser.SocketPath, err)
+	}
+	return nil
+}
+
+// RemoveSocketDir removes the directory of the sockets of the deleted pod.
+var RemoveSocketDir = func(podID string) {
+	if podID == "" {
+		return
+	}
+	err := os.RemoveAll(GetSocketDir(podID))
+	if err != nil {
+		klog.Warningf("RemoveSocketDir: remove socket dir of pod[%s] error: %v", podID, err)
+	}
+}
+
+var GetVhostUser = func(db dbaccessor.DbAccessor, portID string) (*VhostUser, error) {
+	value, err := db.ReadLeaf(dbaccessor.GetKeyOfVhostUser(portID))
+	if err != nil {
+		return nil, err
+	}
+	vhostUser := &VhostUser{}
+	err = json.Unmarshal([]byte(value), vhostUser)
+	if err != nil {
+		klog.Errorf("GetVhostUser: json.Unmarshal(%s) error: %v", value, err)
+		return nil, err
+	}
+	return vhostUser, nil
+}
+
+func SaveVhostUser(db dbaccessor.DbAccessor, vhostUser *VhostUser) error {
+	value, _ := json.Marshal(vhostUser)
+	err := db.SaveLeaf(dbaccessor.GetKeyOfVhostUser(vhostUser.PortID), string(value))
+	if err != nil {
+		klog.Errorf("SaveVhostUser: save vhost-user of port[%s] error: %v", vhostUser.PortID, err)
+	}
+	return err
+}
+
+func DeleteVhostUser(db dbaccessor.DbAccessor, portID string) error {
+	err := db.DeleteLeaf(dbaccessor.GetKeyOfVhostUser(portID))
+	if err != nil {
+		klog.Warningf("DeleteVhostUser: delete vhost-user of port[%s] error: %v", portID, err)
+	}
+	return err
+}
+
+func IsVhostUserPort(db dbaccessor.DbAccessor, portID string) bool {
+	_, err := GetVhostUser(db, portID)
+	return err == nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vhostuser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/gostub"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/ovs"
+	"github.com/ZTE/Knitter/knitter-agent/infra/os-encap"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb/ovsdbtest"
+	"github.com/ZTE/Knitter/pkg/leveldb"
+)
+
+func TestVhostUserPort(t *testing.T) {
+	root, _ := ioutil.TempDir("", "vhost-user")
+	defer os.RemoveAll(root)
+	var cmds [][]string
+	stubs := gostub.Stub(&osencap.Exec, func(cmd string, args ...string) (string, error) {
+		cmds = append(cmds, append([]string{cmd}, args...))
+		return "", nil
+	})
+	defer stubs.Reset()
+
+	Convey("TestVhostUserPort", t, func() {
+		cmds = nil
+		vhostUser := NewVhostUser("5c2b7e3a-1f4d-4e1b", "container1", "pod-uid", "eth1", "br-phy1")
+		So(vhostUser.IfName, ShouldEqual, "vhu5c2b7e3a-1f")
+		So(vhostUser.SocketPath, ShouldEqual, "/var/lib/knitter/vhost-user/pod-uid/eth1.sock")
+		So(GetSocketName(vhostUser), ShouldEqual, "eth1.sock")
+		vhostUser.SocketPath = filepath.Join(root, "pod-uid", "eth1.sock")
+
+		Convey("port of vlan network\n", func() {
+			So(AddVhostUserPort(vhostUser, "100", "fa:16:3e:00:00:01"), ShouldBeNil)
+			_, err := os.Stat(filepath.Join(root, "pod-uid"))
+			So(err, ShouldBeNil)
+			So(cmds[0], ShouldResemble, []string{"ovs-vsctl", "--may-exist", "add-port", "br-phy1", "vhu5c2b7e3a-1f",
+				"tag=100", "--", "set", "Interface", "vhu5c2b7e3a-1f", "type=dpdkvhostuserclient",
+				"options:vhost-server-path=" + vhostUser.SocketPath, "external_ids:iface-id=5c2b7e3a-1f4d-4e1b",
+				"external_ids:attached-mac=fa:16:3e:00:00:01"})
+
+			So(DelVhostUserPort(vhostUser), ShouldBeNil)
+			So(cmds[1], ShouldResemble, []string{"ovs-vsctl", "--if-exists", "del-port", "br-phy1", "vhu5c2b7e3a-1f"})
+			_, err = os.Stat(filepath.Join(root, "pod-uid"))
+			So(err, ShouldBeNil)
+
+			sockDirStub := gostub.Stub(&sockDir, root)
+			defer sockDirStub.Reset()
+			RemoveSocketDir("pod-uid")
+			_, err = os.Stat(filepath.Join(root, "pod-uid"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("port of flat network\n", func() {
+			So(AddVhostUserPort(vhostUser, "", "fa:16:3e:00:00:01"), ShouldBeNil)
+			So(cmds[0][5], ShouldEqual, "--")
+		})
+
+		Convey("record in local db\n", func() {
+			db, _ := leveldb.NewLevelDBClient(filepath.Join(root, "db"))
+			So(IsVhostUserPort(db, vhostUser.PortID), ShouldBeFalse)
+			So(SaveVhostUser(db, vhostUser), ShouldBeNil)
+			saved, err := GetVhostUser(db, vhostUser.PortID)
+			So(err, ShouldBeNil)
+			So(saved, ShouldResemble, vhostUser)
+			So(DeleteVhostUser(db, vhostUser.PortID), ShouldBeNil)
+			So(IsVhostUserPort(db, vhostUser.PortID), ShouldBeFalse)
+		})
+	})
+}
+
+func TestVhostUserPortWithOvsdb(t *testing.T) {
+	root, _ := ioutil.TempDir("", "vhost-user")
+	defer os.RemoveAll(root)
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+	defer server.Close()
+	client, err := ovsdb.Dial(server.Endpoint())
+	if err != nil {
+		t.Fatalf("Dial error: %v", err)
+	}
+	defer client.Close()
+	stubs := gostub.StubFunc(&ovs.GetOvsdbClient, client)
+	defer stubs.Reset()
+
+	Convey("TestVhostUserPortWithOvsdb", t, func() {
+		So(client.AddBridge("br-phy1", nil), ShouldBeNil)
+		vhostUser := NewVhostUser("5c2b7e3a-1f4d-4e1b", "container1", "pod-uid", "eth1", "br-phy1")
+		vhostUser.SocketPath = filepath.Join(root, "pod-uid", "eth1.sock")
+
+		So(AddVhostUserPort(vhostUser, "100", "fa:16:3e:00:00:01"), ShouldBeNil)
+		port, ok := client.GetPort("vhu5c2b7e3a-1f")
+		So(ok, ShouldBeTrue)
+		tag, _ := port.Int("tag")
+		So(tag, ShouldEqual, 100)
+		iface, _ := client.GetInterface("vhu5c2b7e3a-1f")
+		So(iface.String("type"), ShouldEqual, "dpdkvhostuserclient")
+		So(iface.Map("external_ids"), ShouldResemble, map[string]string{
+			"iface-id": "5c2b7e3a-1f4d-4e1b", "attached-mac": "fa:16:3e:00:00:01"})
+
+		// the port is kept as ovs-vsctl --may-exist does
+		So(AddVhostUserPort(vhostUser, "100", "fa:16:3e:00:00:02"), ShouldBeNil)
+		newPort, _ := client.GetPort("vhu5c2b7e3a-1f")
+		So(newPort.UUID(), ShouldEqual, port.UUID())
+		iface, _ = client.GetInterface("vhu5c2b7e3a-1f")
+		So(iface.Map("external_ids")["attached-mac"], ShouldEqual, "fa:16:3e:00:00:02")
+
+		So(DelVhostUserPort(vhostUser), ShouldBeNil)
+		_, ok = client.GetPort("vhu5c2b7e3a-1f")
+		So(ok, ShouldBeFalse)
+		So(DelVhostUserPort(vhostUser), ShouldBeNil)
+		So(client.DelBridge("br-phy1", false), ShouldBeNil)
+	})
+}
@@ -36,6 +36,7 @@ var (
 	ErrMapNtFound            = errors.New("map not found")
 	ErrInvalidStateCode      = errors.New("invalide state code")
 	ErrAttachVethToPodFailed = errors.New("attach veth to pod failed")
+	ErrAttachBondToPodFailed = errors.New("attach bond to pod failed")
 	ErrBuildNormalNicFailed  = errors.New("build normal nic failed")
 	ErrIncRefcountFailed     = errors.New("inc refcount failed")
 	ErrContinue              = errors.New("continue")
@@ -14,6 +14,7 @@ limitations under the License.
 package k8s
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/ZTE/Knitter/pkg/klog"
@@ -26,6 +27,7 @@ import (
 var GetFunc func(url string) (resp *http.Response, err error)
 var CloseFunc func(resp *http.Response) (err error)
 var ReadAllFunc func(resp *http.Response) (body []byte, err error)
+var DoFunc func(req *http.Request) (resp *http.Response, err error)
 
 func Get(url string) (*http.Response, error) {
 	client := &http.Client{}
@@ -45,10 +47,16 @@ func ReadAll(resp *http.Response) ([]byte, error) {
 	return ioutil.ReadAll(resp.Body)
 }
 
+func Do(req *http.Request) (*http.Response, error) {
+	client := &http.Client{}
+	return client.Do(req)
+}
+
 func init() {
 	GetFunc = Get
 	CloseFunc = Close
 	ReadAllFunc = ReadAll
+	DoFunc = Do
 }
 
 type K8sClient struct {
@@ -210,3 +218,42 @@ func (self *K8sClient) GetNodeInfo(nodeIP string) (*jason.Object, error) {
 	klog.Errorf("GetNodeInfo:Not match err nodeIp is %v", nodeIP)
 	return nil, errors.New("not match nodeIP")
 }
+
+func (self *K8sClient) CreateEvent(podNs string, event []byte) error {
+	url := self.ServerURL + "/api/v1/namespaces/" + podNs + "/events"
+	return self.Send("POST", url, "application/json", event)
+}
+
+// PatchPodStatus patches the status subresource of the pod, the patch is a
+// strategic merge patch, so the conditions are merged by their types.
+func (self *K8sClient) PatchPodStatus(podNs, podName string, patch []byte) error {
+	url := self.ServerURL + "/api/v1/namespaces/" + podNs + "/pods/" + podName + "/status"
+	return self.Send("PATCH", url, "application/strategic-merge-patch+json", patch)
+}
+
+// PatchPod patches the metadata of the pod with a strategic merge patch.
+func (self *K8sClient) PatchPod(podNs, podName string, patch []byte) error {
+	url := self.ServerURL + "/api/v1/namespaces/" + podNs + "/pods/" + podName
+	return self.Send("PATCH", url, "application/strategic-merge-patch+json", patch)
+}
+
+func (self *K8sClient) Send(method, url, contentType string, body []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("Send: new request[%s %s] error: %v", method, url, err)
+		return fmt.Errorf("%v:new request error", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := DoFunc(req)
+	if err != nil {
+		klog.Errorf("Send: request[%s %s] error: %v", method, url, err)
+		return err
+	}
+	defer CloseFunc(resp)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		rspBody, _ := ReadAllFunc(resp)
+		klog.Errorf("Send: request[%s %s] return code: %d, body: %s", method, url, resp.StatusCode, string(rspBody))
+		return fmt.Errorf("%d:%s %s return code error", resp.StatusCode, method, url)
+	}
+	return nil
+}
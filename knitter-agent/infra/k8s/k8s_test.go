@@ -0,0 +1,61 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCreateEventAndPatchPodStatus(t *testing.T) {
+	requests := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = r.Header.Get("Content-Type") + " " + string(body)
+		switch r.URL.Path {
+		case "/api/v1/namespaces/admin/events":
+			w.WriteHeader(http.StatusCreated)
+		case "/api/v1/namespaces/admin/pods/pod1/status", "/api/v1/namespaces/admin/pods/pod1":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	convey.Convey("TestCreateEventAndPatchPodStatus", t, func() {
+		client := &K8sClient{ServerURL: server.URL}
+		err := client.CreateEvent("admin", []byte(`{"reason": "AttachFailed"}`))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(requests["POST /api/v1/namespaces/admin/events"], convey.ShouldEqual,
+			`application/json {"reason": "AttachFailed"}`)
+
+		err = client.PatchPodStatus("admin", "pod1", []byte(`{"status": {}}`))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(requests["PATCH /api/v1/namespaces/admin/pods/pod1/status"], convey.ShouldEqual,
+			`application/strategic-merge-patch+json {"status": {}}`)
+
+		err = client.PatchPodStatus("admin", "pod2", []byte(`{"status": {}}`))
+		convey.So(err, convey.ShouldNotBeNil)
+
+		err = client.PatchPod("admin", "pod1", []byte(`{"metadata": {}}`))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(requests["PATCH /api/v1/namespaces/admin/pods/pod1"], convey.ShouldEqual,
+			`application/strategic-merge-patch+json {"metadata": {}}`)
+	})
+}
@@ -0,0 +1,419 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const (
+	DatabaseName = "Open_vSwitch"
+	monitorID    = "knitter"
+	rpcTimeout   = 30 * time.Second
+	dialTimeout  = 5 * time.Second
+	// waitTimeout bounds the wait for ovs-vswitchd to apply a transaction
+	waitTimeout  = 30 * time.Second
+	waitInterval = 10 * time.Millisecond
+)
+
+// the tables kept in the cache of the client
+var monitoredTables = []string{"Open_vSwitch", "Bridge", "Port", "Interface"}
+
+var (
+	ErrNotConnected = errors.New("ovsdb: not connected")
+	ErrRPCTimeout   = errors.New("ovsdb: rpc timeout")
+	ErrWaitTimeout  = errors.New("ovsdb: wait for ovs-vswitchd timeout")
+)
+
+type rpcMessage struct {
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+	ID     json.RawMessage `json:"id,omitempty"`
+}
+
+// Client talks to ovsdb-server by JSON-RPC (RFC 7047). It monitors the
+// Open_vSwitch, Bridge, Port and Interface tables and keeps them in its
+// cache; the updates caused by a transaction are in the cache when the
+// transaction returns, as ovsdb-server sends them before the reply. The
+// cache is not read once the connection is lost, and the client connects
+// again to load it on the next transaction or read.
+type Client struct {
+	network string
+	address string
+
+	connectLock sync.Mutex
+	lock        sync.Mutex
+	conn        net.Conn
+	encoder     *json.Encoder
+	nextID      int
+	pending     map[int]chan *rpcMessage
+
+	cacheLock sync.RWMutex
+	// cacheConn is the connection whose monitor loads and updates the cache
+	cacheConn net.Conn
+	cache     map[string]map[UUID]Row
+}
+
+// Dial connects to the endpoint "unix:<path>" or "tcp:<host>:<port>".
+func Dial(endpoint string) (*Client, error) {
+	parts := strings.SplitN(endpoint, ":", 2)
+	if len(parts) != 2 || (parts[0] != "unix" && parts[0] != "tcp") {
+		return nil, fmt.Errorf("ovsdb: illegal endpoint %s", endpoint)
+	}
+	c := &Client{network: parts[0], address: parts[1]}
+	err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) Close() error {
+	c.lock.Lock()
+	conn := c.conn
+	c.lock.Unlock()
+	if conn == nil {
+		return nil
+	}
+	c.disconnect(conn)
+	return nil
+}
+
+func (c *Client) connect() error {
+	c.connectLock.Lock()
+	defer c.connectLock.Unlock()
+	c.lock.Lock()
+	connected := c.conn != nil
+	c.lock.Unlock()
+	if connected {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(c.network, c.address, dialTimeout)
+	if err != nil {
+		klog.Errorf("ovsdb.connect: dial %s:%s error: %v", c.network, c.address, err)
+		return err
+	}
+	c.lock.Lock()
+	c.conn = conn
+	c.encoder = json.NewEncoder(conn)
+	c.pending = make(map[int]chan *rpcMessage)
+	c.lock.Unlock()
+	go c.read(conn)
+
+	requests := make(map[string]interface{}, len(monitoredTables))
+	for _, table := range monitoredTables {
+		requests[table] = struct{}{}
+	}
+	// the updates following the initial rows wait for the cache lock
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+	result, err := c.call("monitor", DatabaseName, monitorID, requests)
+	if err != nil {
+		klog.Errorf("ovsdb.connect: monitor error: %v", err)
+		c.disconnect(conn)
+		return err
+	}
+	c.cacheConn = conn
+	c.cache = make(map[string]map[UUID]Row)
+	for _, table := range monitoredTables {
+		c.cache[table] = make(map[UUID]Row)
+	}
+	return c.applyUpdatesLocked(result)
+}
+
+// disconnect closes the connection, the cache loaded by it is not valid any
+// more as it is not updated.
+func (c *Client) disconnect(conn net.Conn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn != conn {
+		return
+	}
+	conn.Close()
+	c.conn = nil
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	c.pending = nil
+}
+
+func (c *Client) send(msg interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	return c.encoder.Encode(msg)
+}
+
+func (c *Client) call(method string, params ...interface{}) (json.RawMessage, error) {
+	c.lock.Lock()
+	if c.conn == nil {
+		c.lock.Unlock()
+		return nil, ErrNotConnected
+	}
+	conn := c.conn
+	id := c.nextID
+	c.nextID++
+	ch := make(chan *rpcMessage, 1)
+	c.pending[id] = ch
+	err := c.encoder.Encode(map[string]interface{}{"method": method, "params": params, "id": id})
+	c.lock.Unlock()
+	if err != nil {
+		c.disconnect(conn)
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrNotConnected
+		}
+		if len(resp.Error) != 0 && string(resp.Error) != "null" {
+			return nil, fmt.Errorf("ovsdb: %s error: %s", method, string(resp.Error))
+		}
+		return resp.Result, nil
+	case <-time.After(rpcTimeout):
+		c.lock.Lock()
+		delete(c.pending, id)
+		c.lock.Unlock()
+		return nil, ErrRPCTimeout
+	}
+}
+
+func (c *Client) read(conn net.Conn) {
+	decoder := json.NewDecoder(conn)
+	for {
+		msg := &rpcMessage{}
+		err := decoder.Decode(msg)
+		if err != nil {
+			klog.Warningf("ovsdb.read: connection closed: %v", err)
+			c.disconnect(conn)
+			return
+		}
+		switch msg.Method {
+		case "echo":
+			c.send(map[string]interface{}{"result": msg.Params, "error": nil, "id": msg.ID})
+		case "update":
+			var params []json.RawMessage
+			if err = unmarshal(msg.Params, &params); err != nil || len(params) != 2 {
+				klog.Warningf("ovsdb.read: illegal update %s", string(msg.Params))
+				continue
+			}
+			c.applyUpdates(conn, params[1])
+		case "":
+			var id int
+			if err = json.Unmarshal(msg.ID, &id); err != nil {
+				continue
+			}
+			c.lock.Lock()
+			ch, ok := c.pending[id]
+			delete(c.pending, id)
+			c.lock.Unlock()
+			if ok {
+				ch <- msg
+			}
+		}
+	}
+}
+
+func (c *Client) applyUpdates(conn net.Conn, data json.RawMessage) error {
+	c.cacheLock.Lock()
+	defer c.cacheLock.Unlock()
+	if c.cacheConn != conn {
+		return nil
+	}
+	return c.applyUpdatesLocked(data)
+}
+
+// applyUpdatesLocked applies the <table-updates> to the cache, a row without
+// "new" is deleted.
+func (c *Client) applyUpdatesLocked(data json.RawMessage) error {
+	var tableUpdates map[string]map[string]struct {
+		New map[string]interface{} `json:"new"`
+	}
+	err := unmarshal(data, &tableUpdates)
+	if err != nil {
+		klog.Errorf("ovsdb.applyUpdates: unmarshal %s error: %v", string(data), err)
+		return err
+	}
+	for table, rowUpdates := range tableUpdates {
+		rows, ok := c.cache[table]
+		if !ok {
+			continue
+		}
+		for uuid, rowUpdate := range rowUpdates {
+			if rowUpdate.New == nil {
+				delete(rows, UUID(uuid))
+				continue
+			}
+			row := DecodeRow(rowUpdate.New)
+			row["_uuid"] = UUID(uuid)
+			rows[UUID(uuid)] = row
+		}
+	}
+	return nil
+}
+
+// Transact commits the operations in one transaction, the first error of
+// the operations or the commit is returned.
+func (c *Client) Transact(ops ...Operation) ([]OperationResult, error) {
+	err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	params := []interface{}{DatabaseName}
+	for _, op := range ops {
+		params = append(params, op)
+	}
+	data, err := c.call("transact", params...)
+	if err != nil {
+		return nil, err
+	}
+	var objs []map[string]interface{}
+	err = unmarshal(data, &objs)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]OperationResult, 0, len(objs))
+	for _, obj := range objs {
+		results = append(results, newOperationResult(obj))
+	}
+	for idx, result := range results {
+		if result.Error != "" {
+			klog.Errorf("ovsdb.Transact: operation[%d] of %d error: %s: %s", idx, len(ops), result.Error, result.Details)
+			return results, fmt.Errorf("ovsdb: %s: %s", result.Error, result.Details)
+		}
+	}
+	return results, nil
+}
+
+// TransactAndWait commits the operations and waits for ovs-vswitchd to apply
+// them, as ovs-vsctl does, so the ofports of the new interfaces are known
+// when it returns.
+func (c *Client) TransactAndWait(ops ...Operation) ([]OperationResult, error) {
+	ops = append(ops,
+		Operation{Op: "mutate", Table: "Open_vSwitch",
+			Mutations: []Mutation{{Column: "next_cfg", Mutator: "+=", Value: 1}}},
+		Operation{Op: "select", Table: "Open_vSwitch", Columns: []string{"next_cfg"}})
+	results, err := c.Transact(ops...)
+	if err != nil {
+		return results, err
+	}
+	selected := results[len(ops)-1]
+	if len(selected.Rows) == 0 {
+		return results[:len(ops)-2], nil
+	}
+	nextCfg, _ := selected.Rows[0].Int("next_cfg")
+	for start := time.Now(); time.Since(start) < waitTimeout; time.Sleep(waitInterval) {
+		for _, row := range c.ListRows("Open_vSwitch") {
+			if curCfg, _ := row.Int("cur_cfg"); curCfg >= nextCfg {
+				return results[:len(ops)-2], nil
+			}
+		}
+	}
+	return results[:len(ops)-2], ErrWaitTimeout
+}
+
+// loadCache connects again if the connection is lost, so that the reads get
+// the rows of ovsdb-server rather than the stale cache; nothing is read if it
+// fails to connect.
+func (c *Client) loadCache() {
+	c.connect()
+}
+
+// isCacheValidLocked tells if the cache is loaded and updated by the current
+// connection, it is called with the cache lock held.
+func (c *Client) isCacheValidLocked() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.conn != nil && c.conn == c.cacheConn
+}
+
+func (c *Client) ListRows(table string) []Row {
+	c.loadCache()
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+	if !c.isCacheValidLocked() {
+		return []Row{}
+	}
+	rows := make([]Row, 0, len(c.cache[table]))
+	for _, row := range c.cache[table] {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (c *Client) GetRow(table string, uuid UUID) (Row, bool) {
+	c.loadCache()
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+	if !c.isCacheValidLocked() {
+		return nil, false
+	}
+	row, ok := c.cache[table][uuid]
+	return row, ok
+}
+
+// FindRow finds the row by the name column, which is unique in the Bridge,
+// Port and Interface tables.
+func (c *Client) FindRow(table, name string) (Row, bool) {
+	c.loadCache()
+	c.cacheLock.RLock()
+	defer c.cacheLock.RUnlock()
+	if !c.isCacheValidLocked() {
+		return nil, false
+	}
+	for _, row := range c.cache[table] {
+		if row.String("name") == name {
+			return row, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Client) GetBridge(name string) (Row, bool) {
+	return c.FindRow("Bridge", name)
+}
+
+func (c *Client) GetPort(name string) (Row, bool) {
+	return c.FindRow("Port", name)
+}
+
+func (c *Client) GetInterface(name string) (Row, bool) {
+	return c.FindRow("Interface", name)
+}
+
+// GetBridgeOfPort returns the bridge having the port.
+func (c *Client) GetBridgeOfPort(portUUID UUID) (Row, bool) {
+	for _, bridge := range c.ListRows("Bridge") {
+		for _, uuid := range bridge.UUIDs("ports") {
+			if uuid == portUUID {
+				return bridge, true
+			}
+		}
+	}
+	return nil, false
+}
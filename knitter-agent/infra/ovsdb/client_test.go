@@ -0,0 +1,243 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb/ovsdbtest"
+)
+
+func portNames(c *ovsdb.Client, bridge string) []string {
+	names := make([]string, 0)
+	bridgeRow, ok := c.GetBridge(bridge)
+	if !ok {
+		return names
+	}
+	for _, uuid := range bridgeRow.UUIDs("ports") {
+		if port, ok := c.GetRow("Port", uuid); ok {
+			names = append(names, port.String("name"))
+		}
+	}
+	return names
+}
+
+func TestClient(t *testing.T) {
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+	defer server.Close()
+
+	Convey("TestClient", t, func() {
+		c, err := ovsdb.Dial(server.Endpoint())
+		So(err, ShouldBeNil)
+		defer c.Close()
+
+		Convey("bridges\n", func() {
+			So(c.AddBridge("br-int", nil), ShouldBeNil)
+			bridge, ok := c.GetBridge("br-int")
+			So(ok, ShouldBeTrue)
+			So(portNames(c, "br-int"), ShouldResemble, []string{"br-int"})
+			iface, _ := c.GetInterface("br-int")
+			So(iface.String("type"), ShouldEqual, "internal")
+
+			columns, err := ovsdb.ColumnsFromArgs("protocols=OpenFlow10,OpenFlow14", "external_ids:owner=knitter")
+			So(err, ShouldBeNil)
+			So(c.AddBridge("br-int", columns), ShouldBeNil)
+			bridge, _ = c.GetBridge("br-int")
+			So(bridge.Map("external_ids"), ShouldResemble, map[string]string{"owner": "knitter"})
+
+			So(c.ReplaceBridge("br-int", nil), ShouldBeNil)
+			newBridge, _ := c.GetBridge("br-int")
+			So(newBridge.UUID(), ShouldNotEqual, bridge.UUID())
+			So(newBridge.Map("external_ids"), ShouldBeEmpty)
+			So(server.Rows("Bridge"), ShouldHaveLength, 1)
+
+			So(c.DelBridge("br-int", false), ShouldBeNil)
+			_, ok = c.GetBridge("br-int")
+			So(ok, ShouldBeFalse)
+			So(server.Rows("Port"), ShouldBeEmpty)
+			So(server.Rows("Interface"), ShouldBeEmpty)
+			So(c.DelBridge("br-int", false), ShouldEqual, ovsdb.ErrNoBridge)
+			So(c.DelBridge("br-int", true), ShouldBeNil)
+		})
+
+		Convey("ports\n", func() {
+			So(c.AddBridge("br-int", nil), ShouldBeNil)
+			So(c.AddBridge("br-tun", nil), ShouldBeNil)
+			So(c.AddPort("br-none", "veth1", false, nil, nil), ShouldEqual, ovsdb.ErrNoBridge)
+
+			ifaceColumns, _ := ovsdb.ColumnsFromArgs("type=patch", "options:peer=patch-int", "ofport_request=2")
+			So(c.AddPort("br-tun", "patch-tun", false, nil, ifaceColumns), ShouldBeNil)
+			iface, _ := c.GetInterface("patch-tun")
+			So(iface.Map("options"), ShouldResemble, map[string]string{"peer": "patch-int"})
+			ofPort, _ := iface.Int("ofport")
+			So(ofPort, ShouldEqual, 2)
+
+			So(c.AddPort("br-int", "veth1", false, ovsdb.Row{"tag": 3}, nil), ShouldBeNil)
+			port, _ := c.GetPort("veth1")
+			tag, _ := port.Int("tag")
+			So(tag, ShouldEqual, 3)
+			iface, _ = c.GetInterface("veth1")
+			_, ok := iface.Int("ofport")
+			So(ok, ShouldBeTrue)
+			So(c.AddPort("br-int", "veth1", false, nil, nil), ShouldEqual, ovsdb.ErrPortExists)
+
+			// moved to br-tun in one transaction
+			transactions := server.Transactions
+			So(c.AddPort("br-tun", "veth1", true, nil, nil), ShouldBeNil)
+			So(server.Transactions, ShouldEqual, transactions+1)
+			So(portNames(c, "br-int"), ShouldResemble, []string{"br-int"})
+			So(portNames(c, "br-tun"), ShouldContain, "veth1")
+
+			So(c.DelPort("br-int", "veth1", false), ShouldEqual, ovsdb.ErrNoPort)
+			So(c.DelPort("", "veth1", false), ShouldBeNil)
+			So(c.DelPort("br-tun", "veth1", true), ShouldBeNil)
+			_, ok = c.GetInterface("veth1")
+			So(ok, ShouldBeFalse)
+
+			So(c.DelBridge("br-int", false), ShouldBeNil)
+			So(c.DelBridge("br-tun", false), ShouldBeNil)
+		})
+
+		Convey("list and set\n", func() {
+			So(c.AddBridge("br-tun", nil), ShouldBeNil)
+			So(c.AddBridge("br-int", nil), ShouldBeNil)
+			So(c.AddPort("br-int", "veth2", false, nil, nil), ShouldBeNil)
+			So(c.AddPort("br-int", "veth1", false, nil, nil), ShouldBeNil)
+			So(c.ListBridges(), ShouldResemble, []string{"br-int", "br-tun"})
+			ports, err := c.ListPorts("br-int")
+			So(err, ShouldBeNil)
+			So(ports, ShouldResemble, []string{"veth1", "veth2"})
+			_, err = c.ListPorts("br-none")
+			So(err, ShouldEqual, ovsdb.ErrNoBridge)
+
+			So(c.SetColumns("Interface", "veth1", ovsdb.Row{"ingress_policing_rate": int64(100)}), ShouldBeNil)
+			iface, _ := c.GetInterface("veth1")
+			rate, _ := iface.Int("ingress_policing_rate")
+			So(rate, ShouldEqual, 100)
+			So(c.SetColumns("Interface", "veth3", ovsdb.Row{"ingress_policing_rate": int64(100)}), ShouldEqual, ovsdb.ErrNoRow)
+			So(c.SetColumns("Interface", "veth1", ovsdb.Row{"external_ids": ovsdb.OvsMap{"iface-id": "port1", "owner": "knitter"}}),
+				ShouldBeNil)
+			So(c.SetColumns("Interface", "veth1", ovsdb.Row{"external_ids": ovsdb.OvsMap{"iface-id": "port2"}}), ShouldBeNil)
+			iface, _ = c.GetInterface("veth1")
+			So(iface.Map("external_ids"), ShouldResemble, map[string]string{"iface-id": "port2", "owner": "knitter"})
+
+			So(c.DelBridge("br-int", false), ShouldBeNil)
+			So(c.DelBridge("br-tun", false), ShouldBeNil)
+		})
+
+		Convey("qos of port\n", func() {
+			So(c.AddBridge("br-int", nil), ShouldBeNil)
+			So(c.AddPort("br-int", "veth1", false, nil, ovsdb.Row{"ingress_policing_rate": int64(100)}), ShouldBeNil)
+			externalIDs := map[string]string{"port-id": "port1"}
+			So(c.CreatePortQos("veth3", nil, nil), ShouldEqual, ovsdb.ErrNoPort)
+			So(c.CreatePortQos("veth1", ovsdb.Row{"type": "linux-htb", "external_ids": externalIDs},
+				ovsdb.Row{"external_ids": externalIDs}), ShouldBeNil)
+			qosRows, queueRows := server.Rows("QoS"), server.Rows("Queue")
+			So(qosRows, ShouldHaveLength, 1)
+			So(queueRows, ShouldHaveLength, 1)
+			So(qosRows[0]["queues"], ShouldResemble, ovsdb.OvsMap{int64(0): queueRows[0].UUID()})
+			port, _ := c.GetPort("veth1")
+			So(port.UUIDs("qos"), ShouldResemble, []ovsdb.UUID{qosRows[0].UUID()})
+
+			So(c.RemovePortQos("veth1", map[string]string{"port-id": "port2"}), ShouldBeNil)
+			So(server.Rows("QoS"), ShouldHaveLength, 1)
+			So(c.RemovePortQos("veth1", externalIDs), ShouldBeNil)
+			So(server.Rows("QoS"), ShouldBeEmpty)
+			So(server.Rows("Queue"), ShouldBeEmpty)
+			port, _ = c.GetPort("veth1")
+			So(port.UUIDs("qos"), ShouldBeEmpty)
+			iface, _ := c.GetInterface("veth1")
+			rate, _ := iface.Int("ingress_policing_rate")
+			So(rate, ShouldEqual, 0)
+			So(c.RemovePortQos("veth3", externalIDs), ShouldBeNil)
+
+			So(c.DelBridge("br-int", false), ShouldBeNil)
+		})
+
+		Convey("transaction error\n", func() {
+			_, err := c.Transact(ovsdb.Operation{Op: "insert", Table: "Bridge", Row: ovsdb.Row{"name": "br-x"}},
+				ovsdb.Operation{Op: "insert", Table: "NoTable", Row: ovsdb.Row{}})
+			So(err, ShouldNotBeNil)
+			_, ok := c.GetBridge("br-x")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("reconnect\n", func() {
+			c.Close()
+			So(c.AddBridge("br-int", nil), ShouldBeNil)
+			_, ok := c.GetBridge("br-int")
+			So(ok, ShouldBeTrue)
+			So(c.DelBridge("br-int", false), ShouldBeNil)
+		})
+
+		Convey("reconnect on read\n", func() {
+			So(c.AddBridge("br-int", nil), ShouldBeNil)
+			c.Close()
+			other, err := ovsdb.Dial(server.Endpoint())
+			So(err, ShouldBeNil)
+			defer other.Close()
+			So(other.DelBridge("br-int", false), ShouldBeNil)
+			So(other.AddBridge("br-tun", nil), ShouldBeNil)
+
+			_, ok := c.GetBridge("br-int")
+			So(ok, ShouldBeFalse)
+			_, ok = c.GetBridge("br-tun")
+			So(ok, ShouldBeTrue)
+			So(c.DelBridge("br-tun", false), ShouldBeNil)
+		})
+	})
+}
+
+func TestClientLostServer(t *testing.T) {
+	server, err := ovsdbtest.NewFakeServer()
+	if err != nil {
+		t.Fatalf("NewFakeServer error: %v", err)
+	}
+
+	Convey("TestClientLostServer", t, func() {
+		c, err := ovsdb.Dial(server.Endpoint())
+		So(err, ShouldBeNil)
+		defer c.Close()
+		So(c.AddBridge("br-int", nil), ShouldBeNil)
+		So(c.ListRows("Bridge"), ShouldHaveLength, 1)
+
+		// the stale cache is not read when the client fails to connect again
+		c.Close()
+		server.Close()
+		_, ok := c.GetBridge("br-int")
+		So(ok, ShouldBeFalse)
+		So(c.ListRows("Bridge"), ShouldBeEmpty)
+		_, err = c.Transact()
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestColumnsFromArgs(t *testing.T) {
+	Convey("TestColumnsFromArgs", t, func() {
+		row, err := ovsdb.ColumnsFromArgs("type=vxlan", "options:remote_ip=10.0.0.2", "options:key=flow", "tag=5")
+		So(err, ShouldBeNil)
+		So(row, ShouldResemble, ovsdb.Row{"type": "vxlan", "tag": int64(5),
+			"options": ovsdb.OvsMap{"remote_ip": "10.0.0.2", "key": "flow"}})
+		_, err = ovsdb.ColumnsFromArgs("tag=x")
+		So(err, ShouldNotBeNil)
+		_, err = ovsdb.ColumnsFromArgs("type")
+		So(err, ShouldNotBeNil)
+	})
+}
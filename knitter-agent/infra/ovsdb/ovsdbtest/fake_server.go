@@ -0,0 +1,575 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/ZTE/Knitter/knitter-agent/infra/ovsdb"
+)
+
+// FakeServer is an in-process ovsdb-server of the tables used by knitter,
+// for tests. It implements the monitor and transact methods of RFC 7047
+// without schema, removes the Bridge, Port and Interface rows no longer
+// referred from the Open_vSwitch row, checks the names are unique, and acts
+// as ovs-vswitchd by assigning ofports and catching up next_cfg on commit.
+type FakeServer struct {
+	dir      string
+	listener net.Listener
+
+	lock       sync.Mutex
+	tables     map[string]map[ovsdb.UUID]ovsdb.Row
+	sessions   map[*fakeSession]bool
+	uuidSeq    int
+	nextOfPort int64
+	// Transactions counts the transactions committed
+	Transactions int
+}
+
+type fakeSession struct {
+	conn      net.Conn
+	lock      sync.Mutex
+	encoder   *json.Encoder
+	monitorID interface{}
+	monitors  map[string]bool
+}
+
+var gcTables = []string{"Bridge", "Port", "Interface"}
+
+// refColumns are the references from the root Open_vSwitch row
+var refColumns = map[string]string{"Open_vSwitch": "bridges", "Bridge": "ports", "Port": "interfaces"}
+
+func NewFakeServer() (*FakeServer, error) {
+	dir, err := ioutil.TempDir("", "fake-ovsdb")
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "db.sock"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	s := &FakeServer{dir: dir, listener: listener, sessions: make(map[*fakeSession]bool), nextOfPort: 1,
+		tables: map[string]map[ovsdb.UUID]ovsdb.Row{"Open_vSwitch": {}, "Bridge": {}, "Port": {}, "Interface": {},
+			"QoS": {}, "Queue": {}}}
+	rootUUID := s.newUUID()
+	s.tables["Open_vSwitch"][rootUUID] = ovsdb.Row{"_uuid": rootUUID, "bridges": ovsdb.OvsSet{},
+		"next_cfg": int64(0), "cur_cfg": int64(0)}
+	go s.accept()
+	return s, nil
+}
+
+func (s *FakeServer) Endpoint() string {
+	return "unix:" + s.listener.Addr().String()
+}
+
+// Close closes the listener and all the connections.
+func (s *FakeServer) Close() {
+	s.listener.Close()
+	s.lock.Lock()
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.lock.Unlock()
+	os.RemoveAll(s.dir)
+}
+
+// Rows returns the rows of the table.
+func (s *FakeServer) Rows(table string) []ovsdb.Row {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rows := make([]ovsdb.Row, 0, len(s.tables[table]))
+	for _, row := range s.tables[table] {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (s *FakeServer) newUUID() ovsdb.UUID {
+	s.uuidSeq++
+	return ovsdb.UUID(fmt.Sprintf("00000000-0000-0000-0000-%012d", s.uuidSeq))
+}
+
+func (s *FakeServer) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		session := &fakeSession{conn: conn, encoder: json.NewEncoder(conn), monitors: make(map[string]bool)}
+		s.lock.Lock()
+		s.sessions[session] = true
+		s.lock.Unlock()
+		go s.serve(session)
+	}
+}
+
+func (session *fakeSession) send(msg interface{}) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	session.encoder.Encode(msg)
+}
+
+func (s *FakeServer) serve(session *fakeSession) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, session)
+		s.lock.Unlock()
+		session.conn.Close()
+	}()
+	decoder := json.NewDecoder(session.conn)
+	decoder.UseNumber()
+	for {
+		var msg struct {
+			Method string          `json:"method"`
+			Params []interface{}   `json:"params"`
+			ID     json.RawMessage `json:"id"`
+		}
+		if err := decoder.Decode(&msg); err != nil {
+			return
+		}
+		var result interface{}
+		var rpcErr interface{}
+		switch msg.Method {
+		case "echo":
+			result = msg.Params
+		case "monitor":
+			result = s.monitor(session, msg.Params)
+		case "transact":
+			result = s.transact(msg.Params)
+		case "":
+			continue
+		default:
+			rpcErr = "unknown method"
+		}
+		session.send(map[string]interface{}{"result": result, "error": rpcErr, "id": msg.ID})
+	}
+}
+
+func encodeRow(row ovsdb.Row) map[string]interface{} {
+	obj := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		obj[column] = ovsdb.EncodeValue(value)
+	}
+	return obj
+}
+
+func (s *FakeServer) monitor(session *fakeSession, params []interface{}) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tableUpdates := make(map[string]interface{})
+	if len(params) != 3 {
+		return tableUpdates
+	}
+	session.monitorID = params[1]
+	requests, _ := params[2].(map[string]interface{})
+	for table := range requests {
+		session.monitors[table] = true
+		rowUpdates := make(map[string]interface{})
+		for uuid, row := range s.tables[table] {
+			rowUpdates[string(uuid)] = map[string]interface{}{"new": encodeRow(row)}
+		}
+		tableUpdates[table] = rowUpdates
+	}
+	return tableUpdates
+}
+
+func (s *FakeServer) transact(params []interface{}) interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tables := make(map[string]map[ovsdb.UUID]ovsdb.Row, len(s.tables))
+	for table, rows := range s.tables {
+		tables[table] = make(map[ovsdb.UUID]ovsdb.Row, len(rows))
+		for uuid, row := range rows {
+			tables[table][uuid] = row
+		}
+	}
+	txn := &fakeTxn{server: s, tables: tables, namedUUIDs: make(map[ovsdb.NamedUUID]ovsdb.UUID)}
+	results := make([]interface{}, 0)
+	for _, param := range params[1:] {
+		obj, _ := param.(map[string]interface{})
+		result, err := txn.execute(obj)
+		if err != nil {
+			return append(results, map[string]interface{}{"error": "constraint violation", "details": err.Error()})
+		}
+		results = append(results, result)
+	}
+	if err := txn.commit(); err != nil {
+		return append(results, map[string]interface{}{"error": "constraint violation", "details": err.Error()})
+	}
+
+	updates := make(map[string]map[string]interface{})
+	for table, rows := range tables {
+		rowUpdates := make(map[string]interface{})
+		for uuid, old := range s.tables[table] {
+			if _, ok := rows[uuid]; !ok {
+				rowUpdates[string(uuid)] = map[string]interface{}{"old": encodeRow(old)}
+			}
+		}
+		for uuid, row := range rows {
+			if old, ok := s.tables[table][uuid]; !ok || !reflect.DeepEqual(old, row) {
+				rowUpdates[string(uuid)] = map[string]interface{}{"new": encodeRow(row)}
+			}
+		}
+		if len(rowUpdates) != 0 {
+			updates[table] = rowUpdates
+		}
+	}
+	s.tables = tables
+	s.Transactions++
+	// the updates are sent before the reply, as ovsdb-server does
+	for session := range s.sessions {
+		sessionUpdates := make(map[string]interface{})
+		for table, rowUpdates := range updates {
+			if session.monitors[table] {
+				sessionUpdates[table] = rowUpdates
+			}
+		}
+		if len(sessionUpdates) != 0 {
+			session.send(map[string]interface{}{"method": "update",
+				"params": []interface{}{session.monitorID, sessionUpdates}, "id": nil})
+		}
+	}
+	return results
+}
+
+type fakeTxn struct {
+	server     *FakeServer
+	tables     map[string]map[ovsdb.UUID]ovsdb.Row
+	namedUUIDs map[ovsdb.NamedUUID]ovsdb.UUID
+}
+
+func (txn *fakeTxn) resolve(value interface{}) interface{} {
+	switch v := value.(type) {
+	case ovsdb.NamedUUID:
+		return txn.namedUUIDs[v]
+	case ovsdb.OvsSet:
+		set := make(ovsdb.OvsSet, 0, len(v))
+		for _, elem := range v {
+			set = append(set, txn.resolve(elem))
+		}
+		return set
+	case ovsdb.OvsMap:
+		ovsMap := make(ovsdb.OvsMap, len(v))
+		for key, elem := range v {
+			ovsMap[key] = txn.resolve(elem)
+		}
+		return ovsMap
+	}
+	return value
+}
+
+func (txn *fakeTxn) decodeRow(value interface{}) ovsdb.Row {
+	obj, _ := value.(map[string]interface{})
+	row := ovsdb.DecodeRow(obj)
+	for column, elem := range row {
+		row[column] = txn.resolve(elem)
+	}
+	return row
+}
+
+func toSet(value interface{}) ovsdb.OvsSet {
+	if set, ok := value.(ovsdb.OvsSet); ok {
+		return set
+	}
+	if value == nil {
+		return ovsdb.OvsSet{}
+	}
+	return ovsdb.OvsSet{value}
+}
+
+func setKeys(set ovsdb.OvsSet) []string {
+	keys := make([]string, 0, len(set))
+	for _, elem := range set {
+		keys = append(keys, fmt.Sprint(elem))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// valueKeys formats the elements of a set, or the pairs of a map, as the
+// keys compared by the conditions.
+func valueKeys(value interface{}) []string {
+	ovsMap, ok := value.(ovsdb.OvsMap)
+	if !ok {
+		return setKeys(toSet(value))
+	}
+	keys := make([]string, 0, len(ovsMap))
+	for key, elem := range ovsMap {
+		keys = append(keys, fmt.Sprintf("%v=%v", key, elem))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (txn *fakeTxn) match(row ovsdb.Row, where []interface{}) (bool, error) {
+	for _, cond := range where {
+		parts, ok := cond.([]interface{})
+		if !ok || len(parts) != 3 {
+			return false, fmt.Errorf("illegal condition %v", cond)
+		}
+		column, _ := parts[0].(string)
+		function, _ := parts[1].(string)
+		rowKeys := valueKeys(row[column])
+		condKeys := valueKeys(txn.resolve(ovsdb.DecodeValue(parts[2])))
+		var matched bool
+		switch function {
+		case "==", "!=":
+			matched = reflect.DeepEqual(rowKeys, condKeys) == (function == "==")
+		case "includes":
+			included := make(map[string]bool, len(rowKeys))
+			for _, key := range rowKeys {
+				included[key] = true
+			}
+			matched = true
+			for _, key := range condKeys {
+				matched = matched && included[key]
+			}
+		default:
+			return false, fmt.Errorf("unsupported function %s", function)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (txn *fakeTxn) matchRows(table string, obj map[string]interface{}) ([]ovsdb.Row, error) {
+	rows, ok := txn.tables[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", table)
+	}
+	where, _ := obj["where"].([]interface{})
+	matched := make([]ovsdb.Row, 0)
+	for _, row := range rows {
+		ok, err := txn.match(row, where)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, row)
+		}
+	}
+	return matched, nil
+}
+
+func mutate(value interface{}, mutator string, arg interface{}) (interface{}, error) {
+	switch mutator {
+	case "+=":
+		i, _ := value.(int64)
+		delta, _ := arg.(int64)
+		return i + delta, nil
+	case "insert", "delete":
+		// an empty map column is decoded as an empty set
+		if _, ok := arg.(ovsdb.OvsMap); ok && len(toSet(value)) == 0 {
+			value = ovsdb.OvsMap{}
+		}
+		if ovsMap, ok := value.(ovsdb.OvsMap); ok {
+			newMap := make(ovsdb.OvsMap, len(ovsMap))
+			for key, elem := range ovsMap {
+				newMap[key] = elem
+			}
+			if argMap, ok := arg.(ovsdb.OvsMap); ok {
+				for key, elem := range argMap {
+					if mutator == "insert" {
+						if _, ok := newMap[key]; !ok {
+							newMap[key] = elem
+						}
+					} else if newMap[key] == elem {
+						delete(newMap, key)
+					}
+				}
+			} else if mutator == "delete" {
+				for _, key := range toSet(arg) {
+					delete(newMap, key)
+				}
+			}
+			return newMap, nil
+		}
+		set := make(ovsdb.OvsSet, 0)
+		for _, elem := range toSet(value) {
+			deleted := false
+			for _, argElem := range toSet(arg) {
+				deleted = deleted || (mutator == "delete" && elem == argElem)
+			}
+			if !deleted {
+				set = append(set, elem)
+			}
+		}
+		if mutator == "insert" {
+			for _, argElem := range toSet(arg) {
+				exists := false
+				for _, elem := range set {
+					exists = exists || elem == argElem
+				}
+				if !exists {
+					set = append(set, argElem)
+				}
+			}
+		}
+		return set, nil
+	}
+	return nil, fmt.Errorf("unsupported mutator %s", mutator)
+}
+
+func (txn *fakeTxn) execute(obj map[string]interface{}) (map[string]interface{}, error) {
+	op, _ := obj["op"].(string)
+	table, _ := obj["table"].(string)
+	switch op {
+	case "insert":
+		rows, ok := txn.tables[table]
+		if !ok {
+			return nil, fmt.Errorf("unknown table %s", table)
+		}
+		uuid := txn.server.newUUID()
+		if name, ok := obj["uuid-name"].(string); ok {
+			txn.namedUUIDs[ovsdb.NamedUUID(name)] = uuid
+		}
+		row := txn.decodeRow(obj["row"])
+		row["_uuid"] = uuid
+		rows[uuid] = row
+		return map[string]interface{}{"uuid": ovsdb.EncodeValue(uuid)}, nil
+	case "select":
+		rows, err := txn.matchRows(table, obj)
+		if err != nil {
+			return nil, err
+		}
+		columns, _ := obj["columns"].([]interface{})
+		selected := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			if columns == nil {
+				selected = append(selected, encodeRow(row))
+				continue
+			}
+			partial := make(ovsdb.Row)
+			for _, column := range columns {
+				partial[column.(string)] = row[column.(string)]
+			}
+			selected = append(selected, encodeRow(partial))
+		}
+		return map[string]interface{}{"rows": selected}, nil
+	case "update", "mutate", "delete":
+		rows, err := txn.matchRows(table, obj)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if op == "delete" {
+				delete(txn.tables[table], row.UUID())
+				continue
+			}
+			newRow := make(ovsdb.Row, len(row))
+			for column, value := range row {
+				newRow[column] = value
+			}
+			if op == "update" {
+				for column, value := range txn.decodeRow(obj["row"]) {
+					newRow[column] = value
+				}
+			} else {
+				mutations, _ := obj["mutations"].([]interface{})
+				for _, mutation := range mutations {
+					parts, ok := mutation.([]interface{})
+					if !ok || len(parts) != 3 {
+						return nil, fmt.Errorf("illegal mutation %v", mutation)
+					}
+					column, _ := parts[0].(string)
+					mutator, _ := parts[1].(string)
+					newRow[column], err = mutate(newRow[column], mutator, txn.resolve(ovsdb.DecodeValue(parts[2])))
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			txn.tables[table][row.UUID()] = newRow
+		}
+		return map[string]interface{}{"count": len(rows)}, nil
+	}
+	return nil, fmt.Errorf("unsupported operation %s", op)
+}
+
+// commit removes the rows not referred, checks the names and acts as
+// ovs-vswitchd.
+func (txn *fakeTxn) commit() error {
+	referred := map[ovsdb.UUID]bool{}
+	for _, table := range []string{"Open_vSwitch", "Bridge", "Port"} {
+		for uuid, row := range txn.tables[table] {
+			if table != "Open_vSwitch" && !referred[uuid] {
+				continue
+			}
+			for _, ref := range toSet(row[refColumns[table]]) {
+				if refUUID, ok := ref.(ovsdb.UUID); ok {
+					referred[refUUID] = true
+				}
+			}
+		}
+	}
+	for _, table := range gcTables {
+		names := make(map[string]bool)
+		for uuid, row := range txn.tables[table] {
+			if !referred[uuid] {
+				delete(txn.tables[table], uuid)
+				continue
+			}
+			name := row.String("name")
+			if names[name] {
+				return fmt.Errorf("duplicate %s name %s", table, name)
+			}
+			names[name] = true
+		}
+	}
+
+	bridges := make(map[string]bool)
+	for _, row := range txn.tables["Bridge"] {
+		bridges[row.String("name")] = true
+	}
+	for uuid, row := range txn.tables["Interface"] {
+		if _, ok := row.Int("ofport"); ok {
+			continue
+		}
+		newRow := make(ovsdb.Row, len(row)+1)
+		for column, value := range row {
+			newRow[column] = value
+		}
+		if ofPort, ok := row.Int("ofport_request"); ok {
+			newRow["ofport"] = ofPort
+		} else if row.String("type") == "internal" && bridges[row.String("name")] {
+			newRow["ofport"] = int64(65534)
+		} else {
+			newRow["ofport"] = txn.server.nextOfPort
+			txn.server.nextOfPort++
+		}
+		txn.tables["Interface"][uuid] = newRow
+	}
+	for uuid, row := range txn.tables["Open_vSwitch"] {
+		if row["cur_cfg"] != row["next_cfg"] {
+			newRow := make(ovsdb.Row, len(row))
+			for column, value := range row {
+				newRow[column] = value
+			}
+			newRow["cur_cfg"] = row["next_cfg"]
+			txn.tables["Open_vSwitch"][uuid] = newRow
+		}
+	}
+	return nil
+}
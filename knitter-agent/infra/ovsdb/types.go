@@ -0,0 +1,295 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// UUID refers to a row, NamedUUID refers to a row inserted by the same
+// transaction with the uuid-name.
+type UUID string
+type NamedUUID string
+
+// OvsSet and OvsMap are the set and map values of the columns of OVSDB, the
+// atoms are string, int64, float64, bool and UUID.
+type OvsSet []interface{}
+type OvsMap map[interface{}]interface{}
+
+// Row maps the columns to the values decoded from the wire, a column of at
+// most one value is an atom if it has one, or an empty OvsSet.
+type Row map[string]interface{}
+
+func (row Row) UUID() UUID {
+	uuid, _ := row["_uuid"].(UUID)
+	return uuid
+}
+
+func (row Row) atom(column string) interface{} {
+	value := row[column]
+	if set, ok := value.(OvsSet); ok {
+		if len(set) != 1 {
+			return nil
+		}
+		return set[0]
+	}
+	return value
+}
+
+// String returns "" if the column has no value.
+func (row Row) String(column string) string {
+	value, _ := row.atom(column).(string)
+	return value
+}
+
+// Int returns false if the column has no value.
+func (row Row) Int(column string) (int64, bool) {
+	value, ok := row.atom(column).(int64)
+	return value, ok
+}
+
+func (row Row) UUIDs(column string) []UUID {
+	uuids := make([]UUID, 0)
+	switch value := row[column].(type) {
+	case UUID:
+		uuids = append(uuids, value)
+	case OvsSet:
+		for _, elem := range value {
+			if uuid, ok := elem.(UUID); ok {
+				uuids = append(uuids, uuid)
+			}
+		}
+	}
+	return uuids
+}
+
+// Map returns the map column with the keys and values formatted as strings.
+func (row Row) Map(column string) map[string]string {
+	values := make(map[string]string)
+	if ovsMap, ok := row[column].(OvsMap); ok {
+		for key, value := range ovsMap {
+			values[fmt.Sprint(key)] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
+
+// Operation is an operation of a transaction, see RFC 7047 section 5.2.
+type Operation struct {
+	Op        string
+	Table     string
+	Row       Row
+	Where     []Condition
+	Mutations []Mutation
+	Columns   []string
+	UUIDName  string
+}
+
+func (op Operation) MarshalJSON() ([]byte, error) {
+	obj := map[string]interface{}{"op": op.Op}
+	if op.Table != "" {
+		obj["table"] = op.Table
+	}
+	if op.Row != nil {
+		row := make(map[string]interface{}, len(op.Row))
+		for column, value := range op.Row {
+			row[column] = EncodeValue(value)
+		}
+		obj["row"] = row
+	}
+	switch op.Op {
+	case "update", "delete", "mutate", "select", "wait":
+		where := op.Where
+		if where == nil {
+			where = []Condition{}
+		}
+		obj["where"] = where
+	}
+	if op.Op == "mutate" {
+		obj["mutations"] = op.Mutations
+	}
+	if op.Columns != nil {
+		obj["columns"] = op.Columns
+	}
+	if op.UUIDName != "" {
+		obj["uuid-name"] = op.UUIDName
+	}
+	return json.Marshal(obj)
+}
+
+// Condition is encoded as [column, function, value].
+type Condition struct {
+	Column   string
+	Function string
+	Value    interface{}
+}
+
+func (cond Condition) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{cond.Column, cond.Function, EncodeValue(cond.Value)})
+}
+
+// Mutation is encoded as [column, mutator, value].
+type Mutation struct {
+	Column  string
+	Mutator string
+	Value   interface{}
+}
+
+func (mutation Mutation) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{mutation.Column, mutation.Mutator, EncodeValue(mutation.Value)})
+}
+
+// OperationResult is the result of an operation, a transaction has one
+// more result than its operations if the commit fails.
+type OperationResult struct {
+	Count   int64
+	UUID    UUID
+	Rows    []Row
+	Error   string
+	Details string
+}
+
+func newOperationResult(obj map[string]interface{}) OperationResult {
+	result := OperationResult{}
+	if count, ok := DecodeValue(obj["count"]).(int64); ok {
+		result.Count = count
+	}
+	if uuid, ok := DecodeValue(obj["uuid"]).(UUID); ok {
+		result.UUID = uuid
+	}
+	if rows, ok := obj["rows"].([]interface{}); ok {
+		for _, row := range rows {
+			if rowObj, ok := row.(map[string]interface{}); ok {
+				result.Rows = append(result.Rows, DecodeRow(rowObj))
+			}
+		}
+	}
+	result.Error, _ = obj["error"].(string)
+	result.Details, _ = obj["details"].(string)
+	return result
+}
+
+func Eq(column string, value interface{}) Condition {
+	return Condition{Column: column, Function: "==", Value: value}
+}
+
+// EncodeValue converts the value to the wire format of RFC 7047.
+func EncodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case UUID:
+		return []interface{}{"uuid", string(v)}
+	case NamedUUID:
+		return []interface{}{"named-uuid", string(v)}
+	case OvsSet:
+		elems := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, EncodeValue(elem))
+		}
+		return []interface{}{"set", elems}
+	case []string:
+		elems := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, elem)
+		}
+		return []interface{}{"set", elems}
+	case []UUID:
+		elems := make([]interface{}, 0, len(v))
+		for _, elem := range v {
+			elems = append(elems, EncodeValue(elem))
+		}
+		return []interface{}{"set", elems}
+	case OvsMap:
+		pairs := make([]interface{}, 0, len(v))
+		for key, elem := range v {
+			pairs = append(pairs, []interface{}{EncodeValue(key), EncodeValue(elem)})
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return fmt.Sprint(pairs[i]) < fmt.Sprint(pairs[j])
+		})
+		return []interface{}{"map", pairs}
+	case map[string]string:
+		ovsMap := make(OvsMap, len(v))
+		for key, elem := range v {
+			ovsMap[key] = elem
+		}
+		return EncodeValue(ovsMap)
+	case int:
+		return int64(v)
+	case uint:
+		return int64(v)
+	}
+	return value
+}
+
+// DecodeValue converts the value of the wire format decoded with UseNumber.
+func DecodeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		if len(v) != 2 {
+			return v
+		}
+		tag, _ := v[0].(string)
+		switch tag {
+		case "uuid":
+			uuid, _ := v[1].(string)
+			return UUID(uuid)
+		case "named-uuid":
+			uuid, _ := v[1].(string)
+			return NamedUUID(uuid)
+		case "set":
+			elems, _ := v[1].([]interface{})
+			set := make(OvsSet, 0, len(elems))
+			for _, elem := range elems {
+				set = append(set, DecodeValue(elem))
+			}
+			return set
+		case "map":
+			pairs, _ := v[1].([]interface{})
+			ovsMap := make(OvsMap, len(pairs))
+			for _, pair := range pairs {
+				kv, ok := pair.([]interface{})
+				if ok && len(kv) == 2 {
+					ovsMap[DecodeValue(kv[0])] = DecodeValue(kv[1])
+				}
+			}
+			return ovsMap
+		}
+	}
+	return value
+}
+
+// DecodeRow converts the columns of the row of the wire format.
+func DecodeRow(obj map[string]interface{}) Row {
+	row := make(Row, len(obj))
+	for column, value := range obj {
+		row[column] = DecodeValue(value)
+	}
+	return row
+}
+
+// unmarshal decodes the JSON with the numbers kept as json.Number.
+func unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoBridge   = errors.New("ovsdb: no bridge")
+	ErrNoPort     = errors.New("ovsdb: no port")
+	ErrPortExists = errors.New("ovsdb: port exists")
+	ErrNoRow      = errors.New("ovsdb: no row")
+)
+
+const (
+	newIfaceName  = "new_iface"
+	newPortName   = "new_port"
+	newBridgeName = "new_bridge"
+	newQosName    = "new_qos"
+	newQueueName  = "new_queue"
+)
+
+// columns of the arguments "column=value" of ovs-vsctl which are not strings
+var (
+	intColumns = map[string]bool{"ofport_request": true, "tag": true, "mtu_request": true,
+		"ingress_policing_rate": true, "ingress_policing_burst": true}
+	setColumns = map[string]bool{"protocols": true}
+)
+
+// ColumnsFromArgs converts the arguments of ovs-vsctl as "type=patch" and
+// "options:peer=patch-tun" to the columns of a row.
+func ColumnsFromArgs(args ...string) (Row, error) {
+	row := make(Row)
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("ovsdb: illegal column argument %s", arg)
+		}
+		column, value := kv[0], strings.Trim(kv[1], `"`)
+		if keys := strings.SplitN(column, ":", 2); len(keys) == 2 {
+			ovsMap, ok := row[keys[0]].(OvsMap)
+			if !ok {
+				ovsMap = make(OvsMap)
+				row[keys[0]] = ovsMap
+			}
+			ovsMap[keys[1]] = value
+			continue
+		}
+		switch {
+		case intColumns[column]:
+			i, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("ovsdb: illegal integer column argument %s", arg)
+			}
+			row[column] = i
+		case setColumns[column]:
+			set := make(OvsSet, 0)
+			for _, elem := range strings.Split(value, ",") {
+				set = append(set, elem)
+			}
+			row[column] = set
+		default:
+			row[column] = value
+		}
+	}
+	return row, nil
+}
+
+func copyColumns(row Row, columns Row) Row {
+	for column, value := range columns {
+		row[column] = value
+	}
+	return row
+}
+
+// insertPortOps inserts the port with its interface of the same name.
+func insertPortOps(name string, portColumns, ifaceColumns Row) []Operation {
+	return []Operation{
+		{Op: "insert", Table: "Interface", UUIDName: newIfaceName,
+			Row: copyColumns(Row{"name": name}, ifaceColumns)},
+		{Op: "insert", Table: "Port", UUIDName: newPortName,
+			Row: copyColumns(Row{"name": name, "interfaces": NamedUUID(newIfaceName)}, portColumns)},
+	}
+}
+
+func mutateSetOp(table string, uuid UUID, column, mutator string, value interface{}) Operation {
+	where := []Condition{}
+	if uuid != "" {
+		where = append(where, Eq("_uuid", uuid))
+	}
+	return Operation{Op: "mutate", Table: table, Where: where,
+		Mutations: []Mutation{{Column: column, Mutator: mutator, Value: OvsSet{value}}}}
+}
+
+// delPortOps removes the port from its bridge, the rows of the port and its
+// interfaces are deleted as they are not referred any more.
+func (c *Client) delPortOps(bridge, name string) ([]Operation, error) {
+	port, ok := c.GetPort(name)
+	if !ok {
+		return nil, ErrNoPort
+	}
+	bridgeRow, ok := c.GetBridgeOfPort(port.UUID())
+	if !ok || (bridge != "" && bridgeRow.String("name") != bridge) {
+		return nil, ErrNoPort
+	}
+	return []Operation{mutateSetOp("Bridge", bridgeRow.UUID(), "ports", "delete", port.UUID())}, nil
+}
+
+// AddBridge adds the bridge as "ovs-vsctl --may-exist add-br", the columns
+// are set whether the bridge exists or not.
+func (c *Client) AddBridge(name string, columns Row) error {
+	if bridge, ok := c.GetBridge(name); ok {
+		if len(columns) == 0 {
+			return nil
+		}
+		_, err := c.TransactAndWait(Operation{Op: "update", Table: "Bridge",
+			Where: []Condition{Eq("_uuid", bridge.UUID())}, Row: columns})
+		return err
+	}
+	_, err := c.TransactAndWait(c.addBridgeOps(name, columns)...)
+	return err
+}
+
+// ReplaceBridge deletes the bridge if it exists and adds it again in one
+// transaction, as "ovs-vsctl --if-exists del-br -- add-br".
+func (c *Client) ReplaceBridge(name string, columns Row) error {
+	ops := make([]Operation, 0)
+	if bridge, ok := c.GetBridge(name); ok {
+		ops = append(ops, mutateSetOp("Open_vSwitch", "", "bridges", "delete", bridge.UUID()))
+	}
+	_, err := c.TransactAndWait(append(ops, c.addBridgeOps(name, columns)...)...)
+	return err
+}
+
+func (c *Client) addBridgeOps(name string, columns Row) []Operation {
+	ops := insertPortOps(name, nil, Row{"type": "internal"})
+	return append(ops,
+		Operation{Op: "insert", Table: "Bridge", UUIDName: newBridgeName,
+			Row: copyColumns(Row{"name": name, "ports": NamedUUID(newPortName)}, columns)},
+		mutateSetOp("Open_vSwitch", "", "bridges", "insert", NamedUUID(newBridgeName)))
+}
+
+func (c *Client) DelBridge(name string, ifExists bool) error {
+	bridge, ok := c.GetBridge(name)
+	if !ok {
+		if ifExists {
+			return nil
+		}
+		return ErrNoBridge
+	}
+	_, err := c.TransactAndWait(mutateSetOp("Open_vSwitch", "", "bridges", "delete", bridge.UUID()))
+	return err
+}
+
+// AddPort adds the port with an interface of the same name to the bridge,
+// the port of the same name is deleted in the same transaction if replace
+// is true, as "ovs-vsctl --if-exists del-port -- add-port".
+func (c *Client) AddPort(bridge, name string, replace bool, portColumns, ifaceColumns Row) error {
+	bridgeRow, ok := c.GetBridge(bridge)
+	if !ok {
+		return ErrNoBridge
+	}
+	ops := make([]Operation, 0)
+	if _, ok := c.GetPort(name); ok {
+		if !replace {
+			return ErrPortExists
+		}
+		delOps, err := c.delPortOps("", name)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, delOps...)
+	}
+	ops = append(ops, insertPortOps(name, portColumns, ifaceColumns)...)
+	ops = append(ops, mutateSetOp("Bridge", bridgeRow.UUID(), "ports", "insert", NamedUUID(newPortName)))
+	_, err := c.TransactAndWait(ops...)
+	return err
+}
+
+// DelPort deletes the port from the bridge, or from any bridge if bridge is
+// empty.
+func (c *Client) DelPort(bridge, name string, ifExists bool) error {
+	ops, err := c.delPortOps(bridge, name)
+	if err != nil {
+		if err == ErrNoPort && ifExists {
+			return nil
+		}
+		return err
+	}
+	_, err = c.TransactAndWait(ops...)
+	return err
+}
+
+// ListBridges returns the names of the bridges, as "ovs-vsctl list-br".
+func (c *Client) ListBridges() []string {
+	names := make([]string, 0)
+	for _, bridge := range c.ListRows("Bridge") {
+		names = append(names, bridge.String("name"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListPorts returns the names of the ports of the bridge except its local
+// port, as "ovs-vsctl list-ports".
+func (c *Client) ListPorts(bridge string) ([]string, error) {
+	bridgeRow, ok := c.GetBridge(bridge)
+	if !ok {
+		return nil, ErrNoBridge
+	}
+	names := make([]string, 0)
+	for _, uuid := range bridgeRow.UUIDs("ports") {
+		port, ok := c.GetRow("Port", uuid)
+		if ok && port.String("name") != bridge {
+			names = append(names, port.String("name"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SetColumns sets the columns of the row of the Bridge, Port or Interface
+// table, as "ovs-vsctl set". The keys of a map column are set and its other
+// keys are kept.
+func (c *Client) SetColumns(table, name string, columns Row) error {
+	row, ok := c.FindRow(table, name)
+	if !ok {
+		return ErrNoRow
+	}
+	where := []Condition{Eq("_uuid", row.UUID())}
+	updated := make(Row)
+	mutations := make([]Mutation, 0)
+	for column, value := range columns {
+		ovsMap, ok := value.(OvsMap)
+		if !ok {
+			updated[column] = value
+			continue
+		}
+		keys := make(OvsSet, 0, len(ovsMap))
+		for key := range ovsMap {
+			keys = append(keys, key)
+		}
+		mutations = append(mutations, Mutation{Column: column, Mutator: "delete", Value: keys},
+			Mutation{Column: column, Mutator: "insert", Value: ovsMap})
+	}
+	ops := make([]Operation, 0)
+	if len(updated) != 0 {
+		ops = append(ops, Operation{Op: "update", Table: table, Where: where, Row: updated})
+	}
+	if len(mutations) != 0 {
+		ops = append(ops, Operation{Op: "mutate", Table: table, Where: where, Mutations: mutations})
+	}
+	_, err := c.TransactAndWait(ops...)
+	return err
+}
+
+// CreatePortQos creates the QoS with the queue 0 and sets it to the port in
+// one transaction, as "ovs-vsctl set Port <port> qos=@qos -- --id=@qos create
+// QoS ... queues:0=@queue -- --id=@queue create Queue ...".
+func (c *Client) CreatePortQos(port string, qosColumns, queueColumns Row) error {
+	portRow, ok := c.GetPort(port)
+	if !ok {
+		return ErrNoPort
+	}
+	_, err := c.TransactAndWait(
+		Operation{Op: "insert", Table: "Queue", UUIDName: newQueueName, Row: queueColumns},
+		Operation{Op: "insert", Table: "QoS", UUIDName: newQosName,
+			Row: copyColumns(Row{"queues": OvsMap{int64(0): NamedUUID(newQueueName)}}, qosColumns)},
+		Operation{Op: "update", Table: "Port", Where: []Condition{Eq("_uuid", portRow.UUID())},
+			Row: Row{"qos": NamedUUID(newQosName)}})
+	return err
+}
+
+// RemovePortQos clears the QoS of the port and the ingress policing of its
+// interface if they exist, and deletes the QoS and Queue rows whose
+// external_ids include the externalIDs, in one transaction.
+func (c *Client) RemovePortQos(port string, externalIDs map[string]string) error {
+	ops := make([]Operation, 0)
+	if portRow, ok := c.GetPort(port); ok {
+		ops = append(ops, Operation{Op: "update", Table: "Port",
+			Where: []Condition{Eq("_uuid", portRow.UUID())}, Row: Row{"qos": OvsSet{}}})
+	}
+	if iface, ok := c.GetInterface(port); ok {
+		ops = append(ops, Operation{Op: "update", Table: "Interface",
+			Where: []Condition{Eq("_uuid", iface.UUID())},
+			Row:   Row{"ingress_policing_rate": int64(0), "ingress_policing_burst": int64(0)}})
+	}
+	for _, table := range []string{"QoS", "Queue"} {
+		ops = append(ops, Operation{Op: "delete", Table: table,
+			Where: []Condition{{Column: "external_ids", Function: "includes", Value: externalIDs}}})
+	}
+	_, err := c.TransactAndWait(ops...)
+	return err
+}
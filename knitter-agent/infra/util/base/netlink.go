@@ -28,4 +28,14 @@ type NetLink interface {
 	AddrAdd(link netlink.Link, addr *netlink.Addr) error
 	LinkSetMTU(link netlink.Link, mtu int) error
 	LinkSetMac(link netlink.Link, mac net.HardwareAddr) error
+	LinkByName(name string) (netlink.Link, error)
+	LinkSetVfHardwareAddr(link netlink.Link, vf int, mac net.HardwareAddr) error
+	LinkSetVfVlan(link netlink.Link, vf, vlan int) error
+	LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error
+	LinkSetVfTrust(link netlink.Link, vf int, state bool) error
+	LinkSetVfState(link netlink.Link, vf int, state uint32) error
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	LinkSetMasterByIndex(link netlink.Link, masterIndex int) error
+	LinkSetNsFd(link netlink.Link, fd int) error
 }
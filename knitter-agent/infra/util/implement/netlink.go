@@ -16,6 +16,8 @@ package implement
 import (
 	"github.com/ZTE/Knitter/knitter-agent/infra/util/base"
 	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
 	"net"
 )
 
@@ -47,3 +49,51 @@ func (self *NetLink) LinkSetMTU(link netlink.Link, mtu int) error {
 func (self *NetLink) LinkSetMac(link netlink.Link, mac net.HardwareAddr) error {
 	return netlink.LinkSetHardwareAddr(link, mac)
 }
+func (self *NetLink) LinkByName(name string) (netlink.Link, error) {
+	return netlink.LinkByName(name)
+}
+func (self *NetLink) LinkSetVfHardwareAddr(link netlink.Link, vf int, mac net.HardwareAddr) error {
+	return netlink.LinkSetVfHardwareAddr(link, vf, mac)
+}
+func (self *NetLink) LinkSetVfVlan(link netlink.Link, vf, vlan int) error {
+	return netlink.LinkSetVfVlan(link, vf, vlan)
+}
+func (self *NetLink) LinkSetVfSpoofchk(link netlink.Link, vf int, check bool) error {
+	return netlink.LinkSetVfSpoofchk(link, vf, check)
+}
+func (self *NetLink) LinkSetVfTrust(link netlink.Link, vf int, state bool) error {
+	return netlink.LinkSetVfTrust(link, vf, state)
+}
+func (self *NetLink) LinkAdd(link netlink.Link) error {
+	return netlink.LinkAdd(link)
+}
+func (self *NetLink) LinkDel(link netlink.Link) error {
+	return netlink.LinkDel(link)
+}
+func (self *NetLink) LinkSetMasterByIndex(link netlink.Link, masterIndex int) error {
+	return netlink.LinkSetMasterByIndex(link, masterIndex)
+}
+func (self *NetLink) LinkSetNsFd(link netlink.Link, fd int) error {
+	return netlink.LinkSetNsFd(link, fd)
+}
+
+// LinkSetVfState is the same as `ip link set $link vf $vf state $state`, the
+// vendored netlink has no helper for it.
+func (self *NetLink) LinkSetVfState(link netlink.Link, vf int, state uint32) error {
+	req := nl.NewNetlinkRequest(unix.RTM_SETLINK, unix.NLM_F_ACK)
+	msg := nl.NewIfInfomsg(unix.AF_UNSPEC)
+	msg.Index = int32(link.Attrs().Index)
+	req.AddData(msg)
+
+	data := nl.NewRtAttr(unix.IFLA_VFINFO_LIST, nil)
+	info := nl.NewRtAttrChild(data, nl.IFLA_VF_INFO, nil)
+	vfmsg := nl.VfLinkState{
+		Vf:        uint32(vf),
+		LinkState: state,
+	}
+	nl.NewRtAttrChild(info, nl.IFLA_VF_LINK_STATE, vfmsg.Serialize())
+	req.AddData(data)
+
+	_, err := req.Execute(unix.NETLINK_ROUTE, 0)
+	return err
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/domain/port-recycle"
 	"github.com/ZTE/Knitter/knitter-agent/infra"
 	_ "github.com/ZTE/Knitter/knitter-agent/routers"
+	"github.com/ZTE/Knitter/knitter-agent/scheduler"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/antonholmquist/jason"
 )
@@ -74,6 +75,7 @@ func main() {
 	}
 
 	go portrecycle.RecycleResourseByTimer()
+	go scheduler.SyncPodNetworksByTimer()
 
 	beego.Run()
 }
@@ -0,0 +1,213 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZTE/Knitter/knitter-agent/context"
+	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/netpolicy"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/db-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-monitor-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-obj"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/knitter-agent/trans"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+type podLock struct {
+	sync.Mutex
+	refs int
+}
+
+var (
+	podLocks     = make(map[string]*podLock)
+	podLocksLock sync.Mutex
+)
+
+// lockPod serializes attach, detach and hot-plug of the same pod and returns
+// the function to unlock it, the lock is released when no one holds or waits
+// for it.
+func lockPod(podNs, podName string) func() {
+	key := podNs + "/" + podName
+	podLocksLock.Lock()
+	lock, ok := podLocks[key]
+	if !ok {
+		lock = &podLock{}
+		podLocks[key] = lock
+	}
+	lock.refs++
+	podLocksLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		podLocksLock.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(podLocks, key)
+		}
+		podLocksLock.Unlock()
+	}
+}
+
+// SyncPodNetworksByTimer hot-plugs the interfaces of the pods on the node
+// periodically, according to the ports of pods in knitter-monitor.
+func SyncPodNetworksByTimer() {
+	klog.Infof("SyncPodNetworksByTimer: start, interval is [%v]s", constvalue.SyncPodNetworksIntervalInSec)
+	ticker := time.NewTicker(time.Duration(constvalue.SyncPodNetworksIntervalInSec) * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		SyncPodNetworks()
+		ReapplyPortQoses()
+		netpolicy.Resync()
+	}
+}
+
+func SyncPodNetworks() {
+	pods, err := getPodsOfNode()
+	if err != nil {
+		klog.Warningf("SyncPodNetworks: getPodsOfNode error: %v", err)
+		return
+	}
+	for _, pod := range pods {
+		err := HotPlug(pod[0], pod[1])
+		if err != nil {
+			klog.Warningf("SyncPodNetworks: HotPlug pod[%v/%v] error: %v", pod[0], pod[1], err)
+		}
+	}
+}
+
+func getPodsOfNode() ([][2]string, error) {
+	agtCtx := cni.GetGlobalContext()
+	keyOfPodsForNode := dbaccessor.GetKeyOfPodsForNode(agtCtx.ClusterID, agtCtx.HostIP)
+	nsNodes, err := agtCtx.DB.ReadDir(keyOfPodsForNode)
+	if err != nil {
+		if errobj.IsKeyNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	pods := make([][2]string, 0)
+	for _, nsNode := range nsNodes {
+		podNodes, err := agtCtx.DB.ReadDir(nsNode.Key)
+		if err != nil {
+			klog.Warningf("getPodsOfNode: ReadDir(%v) error: %v", nsNode.Key, err)
+			continue
+		}
+		podNs := strings.TrimPrefix(nsNode.Key, keyOfPodsForNode+"/")
+		for _, podNode := range podNodes {
+			pods = append(pods, [2]string{podNs, path.Base(podNode.Key)})
+		}
+	}
+	return pods, nil
+}
+
+// HotPlug attaches the new ports to the running pod and detaches the removed
+// ones, the CNI args saved when the pod attached are used.
+func HotPlug(podNs, podName string) (err error) {
+	unlock := lockPod(podNs, podName)
+	defer unlock()
+
+	dbObj := dbobj.GetDbObjSingleton()
+	reqBody, err := dbObj.PodRole.GetCniArgs(cni.GetPodTenantID(podNs, podName), podNs, podName)
+	if err != nil {
+		if errobj.IsKeyNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	knitterObj, err := knitterobj.CreateKnitterObj(reqBody)
+	if err != nil {
+		klog.Errorf("HotPlug : knitterobj.CreateKnitterObj(reqBody: %s) error, error is %v", string(reqBody), err)
+		return err
+	}
+	if knitterObj.PodProtectionRole.IsDetaching() {
+		return nil
+	}
+
+	changed, err := isPodNetworksChanged(knitterObj)
+	if err != nil || !changed {
+		return err
+	}
+	klog.Infof("HotPlug: networks of pod[%v/%v] changed, start hot-plug", podNs, podName)
+	err = generalModeHotPlugWithDDDTrans(knitterObj, reqBody)
+	if err != nil {
+		return err
+	}
+	reportPodNetworkStatus(knitterObj.CniParam)
+	return nil
+}
+
+func isPodNetworksChanged(knitterObj *knitterobj.KnitterObj) (bool, error) {
+	cniParam := knitterObj.CniParam
+	monitorPod, err := knittermonitorobj.GetKnitterMgrObjSingleton().GetPodRole.GetPod(cniParam.PodNs, cniParam.PodName)
+	if err != nil {
+		klog.Errorf("isPodNetworksChanged: GetPodRole.GetPod(podNs:[%v], podName: [%v])err, error is [%v]",
+			cniParam.PodNs, cniParam.PodName, err)
+		return false, err
+	}
+	if !monitorPod.IsSuccessful {
+		return false, errors.New("get pod from monitor " + monitorPod.ErrorMsg)
+	}
+
+	dbObj := dbobj.GetDbObjSingleton()
+	attachedPorts, err := dbObj.PodRole.GetAllPorts(cniParam.TenantID, cniParam.PodNs, cniParam.PodName, cniParam.DB)
+	if err != nil && !errobj.IsKeyNotFoundError(err) {
+		return false, err
+	}
+	if len(attachedPorts) != len(monitorPod.Ports) {
+		return true, nil
+	}
+	attachedPortIDs := make(map[string]bool)
+	for _, port := range attachedPorts {
+		attachedPortIDs[path.Base(port.Key)] = true
+	}
+	for _, port := range monitorPod.Ports {
+		if !attachedPortIDs[port.LazyAttr.ID] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func generalModeHotPlugWithDDDTrans(knitterObj *knitterobj.KnitterObj, reqBody []byte) (err error) {
+	transInfo := &transdsl.TransInfo{AppInfo: &context.KnitterInfo{ReqBody: reqBody, Nics: make([]bind.Dpdknic, 0), IsAttachOrDetachFlag: true}}
+	defer func() {
+		if p := recover(); p != nil {
+			context.RecoverErr(p, &err, "generalModeHotPlugWithDDDTrans")
+		}
+		if transInfo.AppInfo.(*context.KnitterInfo).ChanFlag {
+			transInfo.AppInfo.(*context.KnitterInfo).Chan <- 1
+		}
+	}()
+
+	transInfo.AppInfo.(*context.KnitterInfo).KnitterObj = knitterObj
+
+	trans := trans.NewGeneralModeHotPlugTrans()
+	err = trans.Exec(transInfo)
+	if err != nil {
+		trans.RollBack(transInfo)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestLockPod(t *testing.T) {
+	convey.Convey("TestLockPod", t, func() {
+		var wg sync.WaitGroup
+		var runningLock sync.Mutex
+		running, maxRunning := 0, 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock := lockPod("ns1", "pod1")
+				runningLock.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				runningLock.Unlock()
+				time.Sleep(time.Millisecond)
+				runningLock.Lock()
+				running--
+				runningLock.Unlock()
+				unlock()
+			}()
+		}
+		wg.Wait()
+		convey.So(maxRunning, convey.ShouldEqual, 1)
+		convey.So(podLocks, convey.ShouldBeEmpty)
+	})
+}
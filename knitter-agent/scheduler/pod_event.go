@@ -0,0 +1,107 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/cluster-mgr-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-monitor-obj"
+	"github.com/ZTE/Knitter/pkg/k8s-event"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+// reportAttachFailure records the failed action of attach on the pod, which
+// is hidden in the CNI error of kubelet otherwise. Reports are asynchronous
+// to not delay the CNI result.
+var reportAttachFailure = func(cniParam *cni.CniParam, errFragment string, err error) {
+	podNs, podName := cniParam.PodNs, cniParam.PodName
+	message := fmt.Sprintf("attach interfaces of pod failed: %v", err)
+	if errFragment != "" {
+		message = fmt.Sprintf("attach interfaces of pod failed in %s: %v", errFragment, err)
+	}
+	go func() {
+		role := clustermgrobj.GetClusterMgrObjSingleton().ClusterMgrRole
+		errEvent := role.RecordPodEvent(podNs, podName, v1.EventTypeWarning, k8sevent.ReasonAttachFailed, message)
+		if errEvent != nil {
+			klog.Warningf("reportAttachFailure: RecordPodEvent of pod[%v/%v] error: %v", podNs, podName, errEvent)
+		}
+		errCond := role.SetPodNetworkReady(podNs, podName, false, k8sevent.ReasonAttachFailed, message)
+		if errCond != nil {
+			klog.Warningf("reportAttachFailure: SetPodNetworkReady of pod[%v/%v] error: %v", podNs, podName, errCond)
+		}
+	}()
+}
+
+// reportPodNetworkReady sets the NetworkReady condition of the pod after all
+// of its interfaces are attached.
+var reportPodNetworkReady = func(cniParam *cni.CniParam) {
+	podNs, podName := cniParam.PodNs, cniParam.PodName
+	go func() {
+		role := clustermgrobj.GetClusterMgrObjSingleton().ClusterMgrRole
+		err := role.SetPodNetworkReady(podNs, podName, true, k8sevent.ReasonInterfacesAttached,
+			"all interfaces of pod are attached")
+		if err != nil {
+			klog.Warningf("reportPodNetworkReady: SetPodNetworkReady of pod[%v/%v] error: %v", podNs, podName, err)
+		}
+	}()
+}
+
+// reportPodNetworkStatus writes the interfaces of the pod to the multus
+// network-status annotation, the ports are got from knitter-monitor so that
+// the interfaces attached by hot-plug are included.
+var reportPodNetworkStatus = func(cniParam *cni.CniParam) {
+	podNs, podName := cniParam.PodNs, cniParam.PodName
+	go func() {
+		monitorPod, err := knittermonitorobj.GetKnitterMgrObjSingleton().GetPodRole.GetPod(podNs, podName)
+		if err != nil || !monitorPod.IsSuccessful {
+			klog.Warningf("reportPodNetworkStatus: GetPod of pod[%v/%v] failed, error: %v", podNs, podName, err)
+			return
+		}
+		role := clustermgrobj.GetClusterMgrObjSingleton().ClusterMgrRole
+		err = role.SetPodNetworkStatus(podNs, podName, newNetworkStatuses(monitorPod.Ports))
+		if err != nil {
+			klog.Warningf("reportPodNetworkStatus: SetPodNetworkStatus of pod[%v/%v] error: %v", podNs, podName, err)
+		}
+	}()
+}
+
+func newNetworkStatuses(ports []*monitor.Port) []netattachdef.NetworkStatus {
+	statuses := make([]netattachdef.NetworkStatus, 0, len(ports))
+	for _, port := range ports {
+		status := netattachdef.NetworkStatus{
+			Name:      port.EagerAttr.NetworkName,
+			Interface: port.EagerAttr.PortName,
+			Mac:       port.LazyAttr.MacAddress,
+			Default:   port.EagerAttr.PortName == constvalue.DefaultPortName,
+		}
+		// ports of multus network selections are named by the selected definitions
+		if metadata, ok := port.EagerAttr.Metadata.(map[string]interface{}); ok {
+			if nadName, ok := metadata[netattachdef.PortMetadataKey].(string); ok && nadName != "" {
+				status.Name = nadName
+			}
+		}
+		for _, fixedIP := range port.LazyAttr.FixedIps {
+			status.IPs = append(status.IPs, fixedIP.IPAddress)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-agent/domain/monitor"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+func TestNewNetworkStatuses(t *testing.T) {
+	podByte := []byte(`{"ports": [
+		{"eager_attr": {"network_name": "net_api", "port_name": "eth0", "metadata": {}},
+			"lazy_attr": {"mac_address": "fa:16:3e:00:00:01", "fixed_ips": [{"ip_address": "10.0.0.10"}]}},
+		{"eager_attr": {"network_name": "net_media", "port_name": "net1", "metadata": {"network_attachment": "ns1/media"}},
+			"lazy_attr": {"mac_address": "fa:16:3e:00:00:02", "fixed_ips": [{"ip_address": "10.1.0.10"}]}}]}`)
+
+	convey.Convey("TestNewNetworkStatuses", t, func() {
+		pod := &monitor.Pod{}
+		convey.So(json.Unmarshal(podByte, pod), convey.ShouldBeNil)
+		convey.So(newNetworkStatuses(pod.Ports), convey.ShouldResemble, []netattachdef.NetworkStatus{
+			{Name: "net_api", Interface: "eth0", IPs: []string{"10.0.0.10"}, Mac: "fa:16:3e:00:00:01", Default: true},
+			{Name: "ns1/media", Interface: "net1", IPs: []string{"10.1.0.10"}, Mac: "fa:16:3e:00:00:02"},
+		})
+	})
+}
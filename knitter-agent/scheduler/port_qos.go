@@ -0,0 +1,59 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/domain/cni"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/bridge-obj"
+	"github.com/ZTE/Knitter/knitter-agent/domain/qos"
+	"github.com/ZTE/Knitter/knitter-agent/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// ReapplyPortQoses applies the qos of the ports attached to br-int again,
+// the DSCP flows are lost when ovs-vswitchd restarts. The qos of the port
+// not in the port table of br-int any more is forgotten.
+func ReapplyPortQoses() {
+	db := cni.GetGlobalContext().DB
+	portQoses, err := qos.GetAllQoses(db)
+	if err != nil {
+		if !errobj.IsKeyNotFoundError(err) {
+			klog.Warningf("ReapplyPortQoses: qos.GetAllQoses error: %v", err)
+		}
+		return
+	}
+	bridgeObj := bridgeobj.GetBridgeObjSingleton()
+	for _, portQos := range portQoses {
+		vethName, err := bridgeObj.BrintRole.GetPortTable(portQos.PortID)
+		if err != nil || vethName != portQos.VethName {
+			klog.Infof("ReapplyPortQoses: port[%s] is not attached to br-int, forget its qos", portQos.PortID)
+			qos.DeleteQos(db, portQos.PortID)
+			continue
+		}
+		ofPort := portQos.OfPort
+		err = qos.ApplyQos(portQos)
+		if err != nil {
+			klog.Warningf("ReapplyPortQoses: qos.ApplyQos port[%s] error: %v", portQos.PortID, err)
+			continue
+		}
+		if _, err = qos.GetQos(db, portQos.PortID); err != nil {
+			// the port is detached meanwhile
+			qos.RemoveQos(portQos)
+			continue
+		}
+		if portQos.OfPort != ofPort {
+			qos.SaveQos(db, portQos)
+		}
+	}
+}
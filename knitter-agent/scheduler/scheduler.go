@@ -17,6 +17,7 @@ import (
 	"github.com/ZTE/Knitter/knitter-agent/context"
 	"github.com/ZTE/Knitter/knitter-agent/domain/bind"
 	"github.com/ZTE/Knitter/knitter-agent/domain/const-value"
+	"github.com/ZTE/Knitter/knitter-agent/domain/object/db-obj"
 	"github.com/ZTE/Knitter/knitter-agent/domain/object/knitter-obj"
 	"github.com/ZTE/Knitter/knitter-agent/infra"
 	"github.com/ZTE/Knitter/knitter-agent/trans"
@@ -47,8 +48,20 @@ func Attach(reqBody []byte) (err error) {
 			"error, error is %v", string(reqBody), err)
 		return err
 	}
+	unlock := lockPod(knitterObj.CniParam.PodNs, knitterObj.CniParam.PodName)
+	defer unlock()
 	err = generalModeAttachWithDDDTrans(knitterObj, reqBody)
-	return err
+	if err != nil {
+		return err
+	}
+	dbObj := dbobj.GetDbObjSingleton()
+	err = dbObj.PodRole.SaveCniArgs(knitterObj.CniParam, reqBody)
+	if err != nil {
+		klog.Warningf("Attach : dbObj.PodRole.SaveCniArgs error, hot-plug is unavailable for the pod, error is %v", err)
+	}
+	reportPodNetworkReady(knitterObj.CniParam)
+	reportPodNetworkStatus(knitterObj.CniParam)
+	return nil
 
 }
 
@@ -62,6 +75,8 @@ func Detach(reqBody []byte) (err error) {
 	if knitterObj.PodProtectionRole.TryAddDetachingTag() != true {
 		return nil
 	}
+	unlock := lockPod(knitterObj.CniParam.PodNs, knitterObj.CniParam.PodName)
+	defer unlock()
 
 	err = generalModeDetachWithDDDTrans(knitterObj, reqBody)
 	return err
@@ -84,6 +99,7 @@ func generalModeAttachWithDDDTrans(knitterObj *knitterobj.KnitterObj, reqBody []
 	trans := trans.NewGeneralModeAttachTrans()
 	err = trans.Exec(transInfo)
 	if err != nil {
+		reportAttachFailure(knitterObj.CniParam, transInfo.ErrFragment, err)
 		trans.RollBack(transInfo)
 	}
 	return err
@@ -33,7 +33,10 @@ var BuildIaasPort = func(portObj *portobj.PortObj) *iaasaccessor.Interface {
 	iaasPort.Name = portObj.EagerAttr.PortName
 	iaasPort.NicType = portObj.EagerAttr.VnicType
 	iaasPort.PodNs = portObj.EagerAttr.PodNs
-	iaasPort.TenantID = portObj.EagerAttr.PodNs
+	iaasPort.TenantID = portObj.LazyAttr.TenantID
+	if iaasPort.TenantID == "" {
+		iaasPort.TenantID = portObj.EagerAttr.PodNs
+	}
 	iaasPort.PodName = portObj.EagerAttr.PodName
 	iaasPort.Accelerate = portObj.EagerAttr.Accelerate
 	iaasPort.Id = portObj.LazyAttr.ID
@@ -41,11 +41,33 @@ func newConnectToBrdepProcedure() transdsl.Fragment {
 }
 
 func newAddPortToPodProcedure() transdsl.Fragment {
+	procedure := &transdsl.Procedure{
+		Fragments: []transdsl.Fragment{
+			&transdsl.Optional{Spec: new(context.IsVhostUserPort),
+				Fragment: newAttachVhostUserPortProcedure()},
+			&transdsl.Optional{Spec: new(context.IsNotVhostUserPort),
+				Fragment: newAttachPortToBrintProcedure()}}}
+	return procedure
+}
+
+func newAttachVhostUserPortProcedure() transdsl.Fragment {
+	procedure := &transdsl.Procedure{
+		Fragments: []transdsl.Fragment{
+			new(context.GeneralModeGetMgrPortAction),
+			new(context.AttachVhostUserPortAction),
+			new(context.SaveVhostUserPortAction)}}
+	return procedure
+}
+
+func newAttachPortToBrintProcedure() transdsl.Fragment {
 	procedure := &transdsl.Procedure{
 		Fragments: []transdsl.Fragment{
 			&transdsl.Optional{Spec: new(context.IsTenantNetworkNotExist),
 				Fragment: newAttachTenantNetworkToBridgeProcedure()},
-			newAttachPodToBridgeProcedure()}}
+			&transdsl.Optional{Spec: new(context.IsNotBondPort),
+				Fragment: newAttachPodToBridgeProcedure()},
+			&transdsl.Optional{Spec: new(context.IsBondPort),
+				Fragment: newAttachBondToPodProcedure()}}}
 	return procedure
 }
 
@@ -63,7 +85,17 @@ func newAttachPodToBridgeProcedure() transdsl.Fragment {
 			new(context.GeneralModeGetMgrPortAction),
 			new(context.CreateVethPairAction),
 			new(context.AttachPortToBrIntAction),
+			new(context.ApplyPortQosAction),
 			new(context.AttachPortToPodAction),
 			new(context.SaveVethToLocalDBAction)}}
 	return procedure
 }
+
+func newAttachBondToPodProcedure() transdsl.Fragment {
+	procedure := &transdsl.Procedure{
+		Fragments: []transdsl.Fragment{
+			new(context.GeneralModeGetMgrPortAction),
+			new(context.CreateBondSlavesAction),
+			new(context.AttachBondToPodAction)}}
+	return procedure
+}
@@ -37,7 +37,20 @@ func NewGeneralModeDetachTrans() *transdsl.Transaction {
 func newRemovePortFromPodProcedure() transdsl.Fragment {
 	procedure := &transdsl.Procedure{
 		Fragments: []transdsl.Fragment{
-			newDetachPodFromBridgeProcedure(),
+			&transdsl.Optional{Spec: new(context.IsVhostUserPortToDetach),
+				Fragment: new(context.DetachVhostUserPortAction)},
+			&transdsl.Optional{Spec: new(context.IsNotVhostUserPortToDetach),
+				Fragment: newRemovePortFromBrintProcedure()}}}
+	return procedure
+}
+
+func newRemovePortFromBrintProcedure() transdsl.Fragment {
+	procedure := &transdsl.Procedure{
+		Fragments: []transdsl.Fragment{
+			&transdsl.Optional{Spec: new(context.IsNotBondPortToDetach),
+				Fragment: newDetachPodFromBridgeProcedure()},
+			&transdsl.Optional{Spec: new(context.IsBondPortToDetach),
+				Fragment: newDetachBondFromPodProcedure()},
 			&transdsl.Optional{Spec: new(context.IsTenantNetworkNeedDel),
 				Fragment: new(context.RemoveNetFromFlowMgrAction)}}}
 	return procedure
@@ -46,12 +59,21 @@ func newRemovePortFromPodProcedure() transdsl.Fragment {
 func newDetachPodFromBridgeProcedure() transdsl.Fragment {
 	procedure := &transdsl.Procedure{
 		Fragments: []transdsl.Fragment{
+			new(context.RemovePortQosAction),
 			new(context.DetachPortFromBrintAction),
 			new(context.DestroyVethPairAction),
 			new(context.DestroyNeutronPortAction)}}
 	return procedure
 }
 
+func newDetachBondFromPodProcedure() transdsl.Fragment {
+	procedure := &transdsl.Procedure{
+		Fragments: []transdsl.Fragment{
+			new(context.DetachBondFromPodAction),
+			new(context.DestroyBondPortAction)}}
+	return procedure
+}
+
 func newGeneralModePhysicalResourceCleanupProcedure() transdsl.Fragment {
 	procedure := &transdsl.Procedure{
 		Fragments: []transdsl.Fragment{
@@ -0,0 +1,44 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trans
+
+import (
+	"github.com/ZTE/Knitter/knitter-agent/context"
+	"github.com/ZTE/Knitter/pkg/trans-dsl"
+)
+
+// NewGeneralModeHotPlugTrans makes the interfaces of the running pod match
+// the ports got from knitter-monitor, removed ports are detached before new
+// ports are attached.
+func NewGeneralModeHotPlugTrans() *transdsl.Transaction {
+	trans := &transdsl.Transaction{
+		Fragments: []transdsl.Fragment{
+			new(context.GetPodAction),
+			new(context.FilterDetachedPortsAction),
+			&transdsl.Repeat{FuncVar: newRemovePortFromPodProcedure},
+			new(context.FilterAttachedPortsAction),
+			&transdsl.Optional{Spec: new(context.IsPortsToAttach),
+				Fragment: newHotPlugPortsToPodProcedure()},
+		},
+	}
+	return trans
+}
+
+func newHotPlugPortsToPodProcedure() transdsl.Fragment {
+	procedure := &transdsl.Procedure{
+		Fragments: []transdsl.Fragment{
+			new(context.GetNetworkAttrsAction),
+			&transdsl.Repeat{FuncVar: newAddPortToPodProcedure}}}
+	return procedure
+}
@@ -22,6 +22,8 @@ const (
 	VlanTransparentSegmentationID  = "0"
 	MinVlanID                      = 1
 	MaxVlanID                      = 4095
+	MinNetworkMtu                  = 68
+	MaxNetworkMtu                  = 65535
 
 	NetworkNotFound = "NetworkNotFound"
 	NetworkInUse    = "NetworkInUse"
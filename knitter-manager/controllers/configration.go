@@ -40,6 +40,19 @@ func (self *CfgController) CfgOpenStack(body []byte) {
 	self.ServeJSON()
 }
 
+func (self *CfgController) CfgVnfm(body []byte) {
+	var encapConf models.EncapVnfm
+	json.Unmarshal(body, &encapConf)
+	cfg, err := models.HandleVnfmConfg(encapConf.Config)
+	if err != nil {
+		HandleErr(&self.Controller, err)
+		return
+	}
+
+	self.Data["json"] = models.EncapVnfm{Config: cfg}
+	self.ServeJSON()
+}
+
 func (self *CfgController) CfgRegularCheck(timeInterval string) {
 	err := models.HandleRegularCheckConfg(timeInterval)
 	if err != nil {
@@ -74,6 +87,13 @@ func (self *CfgController) Post() {
 		return
 	}
 
+	vnfm, err := cfg.GetObject("vnfm")
+	if err == nil && vnfm != nil {
+		klog.Info("vnfm:", *vnfm)
+		self.CfgVnfm(body)
+		return
+	}
+
 	time, err := cfg.GetString("regular_check")
 	if err == nil && time != "" {
 		klog.Info("Regular Check Interval Time is --->", time)
@@ -0,0 +1,101 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/astaxie/beego"
+
+	"github.com/ZTE/Knitter/knitter-manager/err-obj"
+	"github.com/ZTE/Knitter/knitter-manager/models"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// Operations about exceptional ports
+type ExceptPortController struct {
+	beego.Controller
+}
+
+// @Title GetAll
+// @Description get all exceptional ports
+// @Success 200 {object} []models.ExceptPort
+// @Failure 500 read exceptional ports error
+// @router / [get]
+func (self *ExceptPortController) GetAll() {
+	defer RecoverRsp500(&self.Controller)
+	ports, err := models.GetAllExceptPorts()
+	if err != nil {
+		klog.Errorf("ExceptPortController GetAll: GetAllExceptPorts error: %v", err)
+		Err500(&self.Controller, err)
+		return
+	}
+	self.Data["json"] = ports
+	self.ServeJSON()
+}
+
+// @Title Retry
+// @Description retry the failed operations of the exceptional port now
+// @Param	port_id		path 	string	true		"The port id"
+// @Success 200 {object} models.ExceptPort
+// @Failure 404 exceptional port not exist
+// @Failure 500 retry error
+// @router /:port_id/retry [post]
+func (self *ExceptPortController) Retry() {
+	defer RecoverRsp500(&self.Controller)
+	portID := self.GetString(":port_id")
+	port, err := models.RetryExceptPort(portID)
+	if err != nil {
+		klog.Errorf("ExceptPortController Retry: RetryExceptPort[id: %s] error: %v", portID, err)
+		if errobj.IsEqual(err, errobj.ErrExceptPortNotExist) {
+			NotfoundErr404(&self.Controller, err)
+			return
+		}
+		Err500(&self.Controller, err)
+		return
+	}
+	self.Data["json"] = port
+	self.ServeJSON()
+}
+
+// @Title Delete
+// @Description drop the exceptional port record without releasing the port
+// @Param	port_id		path 	string	true		"The port id"
+// @Success 204 no content
+// @Failure 404 exceptional port not exist
+// @Failure 500 delete error
+// @router /:port_id [delete]
+func (self *ExceptPortController) Delete() {
+	defer RecoverRsp500(&self.Controller)
+	portID := self.GetString(":port_id")
+	_, err := models.GetExceptPort(portID)
+	if err != nil {
+		klog.Errorf("ExceptPortController Delete: GetExceptPort[id: %s] error: %v", portID, err)
+		if errobj.IsEqual(err, errobj.ErrExceptPortNotExist) {
+			NotfoundErr404(&self.Controller, err)
+			return
+		}
+		Err500(&self.Controller, err)
+		return
+	}
+
+	err = models.DeleteExceptPort(portID)
+	if err != nil {
+		klog.Errorf("ExceptPortController Delete: DeleteExceptPort[id: %s] error: %v", portID, err)
+		Err500(&self.Controller, err)
+		return
+	}
+	self.Redirect(self.Ctx.Request.URL.RequestURI(), http.StatusNoContent)
+	self.ServeJSON()
+}
@@ -48,6 +48,7 @@ type Network struct {
 	Description     string                   `json:"description"`
 	SubnetID        string                   `json:"subnet_id"`
 	AllocationPools []subnets.AllocationPool `json:"allocation_pools"`
+	Mtu             int                      `json:"mtu,omitempty"`
 }
 
 type EncapPaasNetwork struct {
@@ -116,6 +117,20 @@ func CreateDefaultNetwork() error {
 	return nil
 }
 
+// getNetworkMtu returns the optional mtu of the network, 0 if it is not given.
+func getNetworkMtu(req *jason.Object) (int, error) {
+	value, err := req.GetValue("mtu")
+	if err != nil {
+		return 0, nil
+	}
+	mtu, err := value.Int64()
+	if err != nil || mtu < constvalue.MinNetworkMtu || mtu > constvalue.MaxNetworkMtu {
+		klog.Errorf("getNetworkMtu: invalid mtu: %v", value.Interface())
+		return 0, errobj.ErrInvalidMtu
+	}
+	return int(mtu), nil
+}
+
 func (self *NetworkController) CreateNetwork(req *jason.Object) error {
 	net := models.Net{}
 	net.Network.Name, _ = req.GetString("name")
@@ -127,6 +142,10 @@ func (self *NetworkController) CreateNetwork(req *jason.Object) error {
 		return models.BuildErrWithCode(http.StatusBadRequest, errors.New("invalid gateway"))
 	}
 	net.Subnet.GatewayIp = gw
+	net.Mtu, err = getNetworkMtu(req)
+	if err != nil {
+		return models.BuildErrWithCode(http.StatusBadRequest, err)
+	}
 
 	allocationPools, errAllocationPools := req.GetObjectArray("allocation_pools")
 	if errAllocationPools != nil {
@@ -177,7 +196,7 @@ func (self *NetworkController) CreateNetwork(req *jason.Object) error {
 		Owner: net.TenantUUID, CreateTime: net.CreateTime,
 		Status:      constvalue.NetworkStatActive,
 		Description: net.Description, SubnetID: net.Subnet.Id,
-		AllocationPools: net.Subnet.AllocationPools}
+		AllocationPools: net.Subnet.AllocationPools, Mtu: net.Mtu}
 
 	self.Data["json"] = EncapPaasNetwork{Network: &cnw}
 	self.ServeJSON()
@@ -221,6 +240,10 @@ func (self *NetworkController) CreateProviderNetwork(req *jason.Object) (err err
 		}
 	}
 	net.Provider.SegmentationID = segID
+	net.Mtu, err = getNetworkMtu(req)
+	if err != nil {
+		return models.BuildErrWithCode(http.StatusBadRequest, err)
+	}
 
 	net.Public, _ = req.GetBoolean("public")
 	net.TenantUUID = self.GetString(":user")
@@ -256,7 +279,8 @@ func (self *NetworkController) CreateProviderNetwork(req *jason.Object) (err err
 		NetworkType:     net.Provider.NetworkType,
 		PhysicalNetwork: net.Provider.PhysicalNetwork,
 		SegmentationID:  net.Provider.SegmentationID,
-		AllocationPools: net.Subnet.AllocationPools}
+		AllocationPools: net.Subnet.AllocationPools,
+		Mtu:             net.Mtu}
 	self.Data["json"] = EncapCreateProviderNetwork{Network: &cnw}
 	self.ServeJSON()
 	return nil
@@ -375,7 +399,8 @@ func makeNetworkInfo(net *models.PaasNetwork) *Network {
 		Owner:           net.Owner,
 		ExternalNet:     net.ExternalNet,
 		CreateTime:      net.CreateTime,
-		AllocationPools: net.AllocationPools}
+		AllocationPools: net.AllocationPools,
+		Mtu:             net.Mtu}
 	return &nw
 }
 
@@ -396,7 +421,8 @@ func transNetObjToNetwork(netObj *models.NetworkObject, subnetObj *models.Subnet
 		ExternalNet:     netObj.IsExternal,
 		CreateTime:      netObj.CreateTime,
 		Status:          constvalue.NetworkStatActive,
-		AllocationPools: allocPool}
+		AllocationPools: allocPool,
+		Mtu:             netObj.Mtu}
 	return &nw
 }
 
@@ -59,7 +59,7 @@ func (c *CniMasterPortController) Post() {
 			UnmarshalErr403(&c.Controller, err1)
 			return
 		}
-		resp, err2 := models.CreateBulkPorts(req)
+		resp, err2 := models.CreateBulkPortsOnce(req)
 		if err2 != nil {
 			klog.Errorf("CreateBulkPorts failed, error: %v", err2)
 			UnmarshalErr403(&c.Controller, err2)
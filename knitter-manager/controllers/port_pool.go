@@ -0,0 +1,43 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/ZTE/Knitter/knitter-manager/models"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// Operations about port pools
+type PortPoolController struct {
+	beego.Controller
+}
+
+// @Title GetAll
+// @Description get the port pools of networks
+// @Success 200 {object} []models.PortPoolStatus
+// @Failure 500 read pooled ports error
+// @router / [get]
+func (self *PortPoolController) GetAll() {
+	defer RecoverRsp500(&self.Controller)
+	pools, err := models.GetPortPoolsStatus()
+	if err != nil {
+		klog.Errorf("PortPoolController GetAll: GetPortPoolsStatus error: %v", err)
+		Err500(&self.Controller, err)
+		return
+	}
+	self.Data["json"] = pools
+	self.ServeJSON()
+}
@@ -15,7 +15,10 @@ package controllers
 
 import (
 	"errors"
+	"strings"
+
 	"github.com/ZTE/Knitter/knitter-manager/models"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/astaxie/beego"
 )
@@ -43,9 +46,53 @@ func (o *SyncController) Get() {
 
 	syncMgt := models.GetSyncMgt()
 	requestIP := o.GetString(":internal_ip")
-	syncRsp := syncMgt.Sync(requestIP)
+	syncRsp, err := syncMgt.Sync(requestIP, o.getTunnel(), o.getNetworks())
+	if err != nil {
+		klog.Errorf("Sync-error-from[%s]: %v", requestIP, err)
+		HandleErr406(&o.Controller, err)
+		return
+	}
 	klog.Tracef("Sync-OK-from[%s]-Data: %v", syncRsp)
 
 	o.Data["json"] = syncRsp
 	o.ServeJSON()
 }
+
+// @Title watch the members of the networks
+// @Description wait until the members version is changed from the query parameter members_version
+// @Success 200 {object} dbaccessor.MembersWatchRsp
+// @router /:internal_ip/members [get]
+func (o *SyncController) WatchMembers() {
+	defer RecoverRsp500(&o.Controller)
+	version := o.GetString("members_version")
+	o.Data["json"] = dbaccessor.MembersWatchRsp{
+		MembersVersion: models.GetSyncMgt().WatchMembers(version, models.MembersWatchTimeout)}
+	o.ServeJSON()
+}
+
+// getTunnel returns the tunnel of the agent given by the query parameters
+// tunnel_type and tunnel_dst_port, agents of old versions give no tunnel.
+func (o *SyncController) getTunnel() *dbaccessor.Tunnel {
+	tunnelType := o.GetString("tunnel_type")
+	if tunnelType == "" {
+		return nil
+	}
+	dstPort, _ := o.GetInt("tunnel_dst_port")
+	return &dbaccessor.Tunnel{Type: tunnelType, DstPort: dstPort}
+}
+
+// getNetworks returns the networks hosting pods on the agent given by the
+// query parameter network_ids, nil if the agent does not report them.
+func (o *SyncController) getNetworks() []string {
+	values, ok := o.Ctx.Request.URL.Query()["network_ids"]
+	if !ok {
+		return nil
+	}
+	networks := make([]string, 0)
+	for _, networkID := range strings.Split(values[0], ",") {
+		if networkID != "" {
+			networks = append(networks, networkID)
+		}
+	}
+	return networks
+}
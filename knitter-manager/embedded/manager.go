@@ -123,6 +123,10 @@ func (_ *NetworkManager) ListPorts(networkID string) ([]*iaas.Interface, error)
 	return GetPortManager().ListPorts(networkID)
 }
 
+func (_ *NetworkManager) UpdatePortName(id, name string) error {
+	return GetPortManager().UpdatePortName(id, name)
+}
+
 /***************************************************************
 *
 ****************************************************************/
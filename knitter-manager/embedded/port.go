@@ -162,7 +162,10 @@ func (self *Interfaces) CreatePort(networkID, subnetID, networkPlane,
 		LOG.Error("EMBEDDED-CreatePort-error:[", subnetID, "][alloc-ip-error]")
 		return nil, err
 	}
-	newMac := getMacAddr(net.ParseIP(ip).To4())
+	newMac := mac
+	if newMac == "" {
+		newMac = getMacAddr(net.ParseIP(ip).To4())
+	}
 	newPort := Interface{}
 	newPort.IP = ip
 	newPort.ID = uuid.NewUUID()
@@ -193,7 +196,7 @@ func (self *Interfaces) CreateBulkPorts(req *mgriaas.MgrBulkPortsReq) ([]*iaas.I
 	ports := make([]*iaas.Interface, 0)
 	for _, reqPort := range req.Ports {
 		port, err := self.CreatePort(reqPort.NetworkId, reqPort.SubnetId, reqPort.PortName,
-			reqPort.FixIP, "", "normal")
+			reqPort.FixIP, reqPort.FixMac, "normal")
 		if err != nil {
 			//rollback
 			LOG.Infof("EMBEDDED-CreateBulkPorts-start-rollback")
@@ -258,3 +261,21 @@ func (self *Interfaces) ListPorts(networkID string) ([]*iaas.Interface, error) {
 	LOG.Tracef("ListPorts: list SUCC, result: %+v", ports)
 	return ports, nil
 }
+
+func (self *Interfaces) UpdatePortName(id, name string) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	port, ok := self.list[id]
+	if !ok {
+		return errors.New("update-port-error:not-exist")
+	}
+
+	oldName := port.Name
+	port.Name = name
+	err := port.save()
+	if err != nil {
+		port.Name = oldName
+		return err
+	}
+	return nil
+}
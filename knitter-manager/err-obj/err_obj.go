@@ -52,6 +52,7 @@ var (
 	ErrNetworkHasIGsInUse               = errors.New("network has ip groups in use")
 	ErrEtcdRestoreFromEtcdAdmToolFailed = errors.New("etcd restore from EtcdAdmTool failed err")
 	ErrArgTypeMismatch                  = errors.New("argument type mismatch")
+	ErrExceptPortNotExist               = errors.New("exceptional port not exist")
 )
 
 var (
@@ -74,6 +75,12 @@ var (
 	ErrNetworkTypeNotSupported     = errors.New("network type not supported")
 	ErrVlanTransparentConflictArgs = errors.New("vlan_transparent confict args")
 	ErrInvalidVlanID               = errors.New("invalid vlan ID")
+	ErrInvalidMtu                  = errors.New("invalid mtu")
+)
+
+var (
+	ErrInvalidTunnel  = errors.New("invalid tunnel")
+	ErrTunnelConflict = errors.New("tunnel conflicts with the cluster")
 )
 
 var (
@@ -28,6 +28,7 @@ import (
 	"github.com/ZTE/Knitter/pkg/openstack"
 	"github.com/ZTE/Knitter/pkg/uuid"
 	"github.com/ZTE/Knitter/pkg/version"
+	"github.com/ZTE/Knitter/pkg/vnfm"
 	"github.com/antonholmquist/jason"
 	"github.com/rackspace/gophercloud"
 	"strings"
@@ -153,13 +154,7 @@ var InitIaaS = func() error {
 }
 
 var CheckOpenstackConfig = func(conf *openstack.OpenStackConf) (*gophercloud.ProviderClient, error) {
-	var config gophercloud.AuthOptions
-	config.Username = conf.Username
-	config.Password = conf.Password
-	config.TenantName = conf.TenantName
-	config.TenantID = conf.Tenantid
-	config.IdentityEndpoint = conf.Url + "/tokens"
-	c, err := openstack.AuthenticatedClientV2(config)
+	c, err := openstack.AuthenticatedClient(conf)
 	if err != nil {
 		klog.Error("CheckOpenstackConfig call AuthenticatedClient Error:", err)
 		return nil, err
@@ -297,6 +292,8 @@ func InitIaasByKnitterJSON(tenantID string) error {
 			return err
 		}
 		return InitNoAuth(cfg)
+	case constvalue.VNFM:
+		return InitVnfm()
 	default:
 		klog.Errorf("Scene[%v] is err", Scene)
 		return errors.New("Scene err")
@@ -463,7 +460,29 @@ func InitNoauthOpenStack(openstackConf noauth_openstack.NoauthOpenStackConf, neu
 	SaveDefaultPhysnet(neutronConf.ProviderConf.PhyscialNetwork)
 }
 
+var InitVnfm = func() error {
+	cfg := common.GetVnfmCfg()
+	klog.Info("Now-Use-VNFM, Configration:", cfg)
+	v := vnfm.NewVnfm()
+	err := v.SetVnfmConfig(cfg)
+	if err != nil {
+		klog.Errorf("IaaS-SetVnfmConfig-ERROR: %v", err)
+		return err
+	}
+	err = v.Auth()
+	if err != nil {
+		klog.Errorf("IaaS-auth-ERROR: %v", err)
+		return err
+	}
+	klog.Info("IaaS-auth-OK")
+	SetIaaS(constvalue.DefaultIaasTenantID, v)
+	return nil
+}
+
 var GetSceneByKnitterJSON = func() (string, error) {
+	if common.GetDataBase() != nil && common.GetVnfmCfg() != "" {
+		return constvalue.VNFM, nil
+	}
 	return constvalue.EMBEDDED, nil
 }
 
@@ -491,6 +510,8 @@ func InitIaasForDefault(paasTenantID string) (*IaaSObj, error) {
 			return nil, err
 		}
 		err = InitNoAuth(cfg)
+	case constvalue.VNFM:
+		err = InitVnfm()
 	default:
 		klog.Errorf("Scene[%v] is err", Scene)
 		return nil, errors.New("Scene err")
@@ -57,5 +57,8 @@ func main() {
 		klog.Errorf("controllers.CreateDefaulNetwork() err, error is [%v]", err)
 		return
 	}
+	models.StartExceptPortReaper()
+	models.StartPortPoolRefiller()
+	models.StartBulkPortsReqCleaner()
 	beego.Run()
 }
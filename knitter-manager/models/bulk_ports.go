@@ -0,0 +1,212 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/uuid"
+)
+
+const (
+	DefaultBulkPortsChunkSize = 20
+	MaxBulkPortsChunkSize     = 100
+
+	bulkPortsTagPrefix = "kb-"
+)
+
+var BulkPortsChunkSize int = DefaultBulkPortsChunkSize
+
+func SetBulkPortsChunkSize(cfg *jason.Object) {
+	chunkSize, _ := cfg.GetString("bulk_ports", "chunk_size")
+	BulkPortsChunkSize, _ = ConvertBulkPortsChunkSize(chunkSize)
+	klog.Infof("BulkPortsChunkSize:%v", BulkPortsChunkSize)
+}
+
+func ConvertBulkPortsChunkSize(chunkSize string) (int, bool) {
+	if chunkSize == "" {
+		return DefaultBulkPortsChunkSize, false
+	}
+	size, err := strconv.Atoi(chunkSize)
+	if err != nil {
+		return DefaultBulkPortsChunkSize, false
+	}
+	if size <= 0 || size > MaxBulkPortsChunkSize {
+		return DefaultBulkPortsChunkSize, false
+	}
+	return size, true
+}
+
+// MakeBulkPortsTag returns the prefix added to the names of the ports created
+// by one chunk of a bulk request, so that they can be found by ListPorts when
+// the result of the creation is unknown. The nonce differs on each call, as
+// the hot-plugs of a pod reuse the req id of its creation, so the ports
+// created before are never taken as the ports of the chunk.
+func MakeBulkPortsTag(reqID, nonce string, chunkIdx int) string {
+	return bulkPortsTagPrefix + reqID + "-" + nonce + "-" + strconv.Itoa(chunkIdx) + "_"
+}
+
+var newBulkPortsNonce = func() string {
+	return uuid.GetUUID8Byte(uuid.NewUUID())
+}
+
+// CreateIaasBulkPortsInChunks creates the ports of req chunk by chunk. A chunk
+// failed or timed out is reconciled with the ports tagged by it: all of them
+// created are adopted, otherwise the created ones are deleted before retrying.
+// If a chunk can not be created, ports of the previous chunks are rolled back.
+var CreateIaasBulkPortsInChunks = func(iaasObj iaasaccessor.IaaS, req *mgriaas.MgrBulkPortsReq) (
+	[]*iaasaccessor.Interface, error) {
+	if len(req.Ports) == 0 {
+		return nil, nil
+	}
+
+	reqID, nonce := req.TranId, newBulkPortsNonce()
+
+	tenantID := req.Ports[0].TenantID
+	ports := make([]*iaasaccessor.Interface, 0, len(req.Ports))
+	for idx, chunk := range splitBulkPortsReq(req, BulkPortsChunkSize) {
+		chunkPorts, err := createBulkPortsChunk(iaasObj, chunk, MakeBulkPortsTag(reqID, nonce, idx))
+		if err != nil {
+			klog.Errorf("CreateIaasBulkPortsInChunks: create chunk[%d] of req[%s] FAILED, error: %v",
+				idx, reqID, err)
+			rollbackIaasPorts(iaasObj, tenantID, ports)
+			return nil, err
+		}
+		ports = append(ports, chunkPorts...)
+	}
+
+	klog.Infof("CreateIaasBulkPortsInChunks: create %d ports of req[%s] SUCC", len(ports), reqID)
+	return ports, nil
+}
+
+func splitBulkPortsReq(req *mgriaas.MgrBulkPortsReq, chunkSize int) []*mgriaas.MgrBulkPortsReq {
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkPortsChunkSize
+	}
+
+	chunks := make([]*mgriaas.MgrBulkPortsReq, 0, (len(req.Ports)+chunkSize-1)/chunkSize)
+	for start := 0; start < len(req.Ports); start += chunkSize {
+		end := start + chunkSize
+		if end > len(req.Ports) {
+			end = len(req.Ports)
+		}
+		chunks = append(chunks, &mgriaas.MgrBulkPortsReq{TranId: req.TranId, Ports: req.Ports[start:end]})
+	}
+	return chunks
+}
+
+func tagBulkPortsReq(chunk *mgriaas.MgrBulkPortsReq, tag string) *mgriaas.MgrBulkPortsReq {
+	taggedReq := &mgriaas.MgrBulkPortsReq{TranId: chunk.TranId}
+	for _, port := range chunk.Ports {
+		taggedPort := *port
+		taggedPort.PortName = tag + port.PortName
+		taggedReq.Ports = append(taggedReq.Ports, &taggedPort)
+	}
+	return taggedReq
+}
+
+func untagIaasPorts(ports []*iaasaccessor.Interface, tag string) []*iaasaccessor.Interface {
+	for _, port := range ports {
+		port.Name = strings.TrimPrefix(port.Name, tag)
+	}
+	return ports
+}
+
+func createBulkPortsChunk(iaasObj iaasaccessor.IaaS, chunk *mgriaas.MgrBulkPortsReq, tag string) (
+	[]*iaasaccessor.Interface, error) {
+	taggedReq := tagBulkPortsReq(chunk, tag)
+
+	var err error
+	for idx := 0; idx < OpenStackOpsRetryTime; idx++ {
+		var ports []*iaasaccessor.Interface
+		ports, err = iaasObj.CreateBulkPorts(taggedReq)
+		if err == nil {
+			return untagIaasPorts(ports, tag), nil
+		}
+		klog.Warningf("createBulkPortsChunk: %d times create ports tagged[%s] error: %v", idx, tag, err)
+
+		adopted, errReconcile := reconcileBulkPortsChunk(iaasObj, taggedReq, tag)
+		if errReconcile != nil {
+			klog.Errorf("createBulkPortsChunk: reconcileBulkPortsChunk tagged[%s] error: %v", tag, errReconcile)
+			return nil, fmt.Errorf("%v:reconcile bulk ports error", errReconcile)
+		}
+		if adopted != nil {
+			return untagIaasPorts(adopted, tag), nil
+		}
+	}
+
+	klog.Errorf("createBulkPortsChunk: create ports tagged[%s] FAILED, error: %v", tag, err)
+	return nil, err
+}
+
+// reconcileBulkPortsChunk returns the ports tagged with tag if all ports of
+// the chunk were created, otherwise deletes them and returns nil.
+func reconcileBulkPortsChunk(iaasObj iaasaccessor.IaaS, taggedReq *mgriaas.MgrBulkPortsReq, tag string) (
+	[]*iaasaccessor.Interface, error) {
+	tagged, err := listTaggedIaasPorts(iaasObj, taggedReq, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tagged) == len(taggedReq.Ports) {
+		klog.Infof("reconcileBulkPortsChunk: adopt %d ports tagged[%s]", len(tagged), tag)
+		return tagged, nil
+	}
+
+	klog.Infof("reconcileBulkPortsChunk: found %d ports tagged[%s], expect %d, delete them",
+		len(tagged), tag, len(taggedReq.Ports))
+	rollbackIaasPorts(iaasObj, taggedReq.Ports[0].TenantID, tagged)
+	return nil, nil
+}
+
+func listTaggedIaasPorts(iaasObj iaasaccessor.IaaS, taggedReq *mgriaas.MgrBulkPortsReq, tag string) (
+	[]*iaasaccessor.Interface, error) {
+	tagged := make([]*iaasaccessor.Interface, 0)
+	listed := make(map[string]bool)
+	for _, req := range taggedReq.Ports {
+		if listed[req.NetworkId] {
+			continue
+		}
+		listed[req.NetworkId] = true
+
+		ports, err := iaasObj.ListPorts(req.NetworkId)
+		if err != nil {
+			klog.Errorf("listTaggedIaasPorts: ListPorts of network[%s] error: %v", req.NetworkId, err)
+			return nil, err
+		}
+		for _, port := range ports {
+			if strings.HasPrefix(port.Name, tag) {
+				tagged = append(tagged, port)
+			}
+		}
+	}
+	return tagged, nil
+}
+
+func rollbackIaasPorts(iaasObj iaasaccessor.IaaS, tenantID string, ports []*iaasaccessor.Interface) {
+	for _, port := range ports {
+		err := iaasObj.DeletePort(port.Id)
+		if err != nil {
+			klog.Warningf("rollbackIaasPorts: DeletePort[id: %s] error: %v", port.Id, err)
+			SaveExceptPort(tenantID, port.Id, PortTypeNonattatched, "", "create bulk ports failed, then delete it failed")
+		}
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-agt"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const (
+	DefaultBulkPortsReqRetentionInSec = 600
+	MaxBulkPortsReqRetentionInSec     = 86400
+)
+
+var (
+	BulkPortsReqRetentionInSec     int = DefaultBulkPortsReqRetentionInSec
+	BulkPortsReqCleanIntervalInSec     = 60
+
+	bulkPortsReqLocksLock sync.Mutex
+	bulkPortsReqLocks     = make(map[string]*bulkPortsReqLock)
+)
+
+// BulkPortsReqRecord is the outcome of a bulk ports request, a retry with the
+// same request id and body is answered with it instead of creating ports again.
+type BulkPortsReqRecord struct {
+	ReqID      string                  `json:"req_id"`
+	TenantID   string                  `json:"tenant_id"`
+	Digest     string                  `json:"digest"`
+	Resp       *mgragt.CreatePortsResp `json:"resp"`
+	CreateTime string                  `json:"create_time"`
+}
+
+type bulkPortsReqLock struct {
+	sync.Mutex
+	refs int
+}
+
+func SetBulkPortsReqRetention(cfg *jason.Object) {
+	retention, _ := cfg.GetString("bulk_ports", "req_id_retention")
+	BulkPortsReqRetentionInSec, _ = ConvertBulkPortsReqRetention(retention)
+	klog.Infof("BulkPortsReqRetentionInSec:%v", BulkPortsReqRetentionInSec)
+}
+
+func ConvertBulkPortsReqRetention(retention string) (int, bool) {
+	if retention == "" {
+		return DefaultBulkPortsReqRetentionInSec, false
+	}
+	seconds, err := strconv.Atoi(retention)
+	if err != nil {
+		return DefaultBulkPortsReqRetentionInSec, false
+	}
+	if seconds <= 0 || seconds > MaxBulkPortsReqRetentionInSec {
+		return DefaultBulkPortsReqRetentionInSec, false
+	}
+	return seconds, true
+}
+
+// lockBulkPortsReq serialises the requests with the same key and returns the
+// function to unlock it.
+func lockBulkPortsReq(key string) func() {
+	bulkPortsReqLocksLock.Lock()
+	lock, ok := bulkPortsReqLocks[key]
+	if !ok {
+		lock = &bulkPortsReqLock{}
+		bulkPortsReqLocks[key] = lock
+	}
+	lock.refs++
+	bulkPortsReqLocksLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		bulkPortsReqLocksLock.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(bulkPortsReqLocks, key)
+		}
+		bulkPortsReqLocksLock.Unlock()
+	}
+}
+
+func makeBulkPortsReqDigest(req *mgriaas.MgrBulkPortsReq) string {
+	reqBytes, _ := json.Marshal(req.Ports)
+	sum := sha256.Sum256(reqBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+func getBulkPortsReqRecord(tenantID, reqID string) (*BulkPortsReqRecord, error) {
+	value, err := common.GetDataBase().ReadLeaf(dbaccessor.GetKeyOfBulkPortsReq(tenantID, reqID))
+	if err != nil {
+		return nil, err
+	}
+	var record BulkPortsReqRecord
+	err = json.Unmarshal([]byte(value), &record)
+	if err != nil {
+		klog.Errorf("getBulkPortsReqRecord: json.Unmarshal(%s) FAILED, error: %v", value, err)
+		return nil, fmt.Errorf("%v:unmarshal bulk ports request failed", err)
+	}
+	return &record, nil
+}
+
+func saveBulkPortsReqRecord(record *BulkPortsReqRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("%v:marshal bulk ports request failed", err)
+	}
+	return common.GetDataBase().SaveLeaf(dbaccessor.GetKeyOfBulkPortsReq(record.TenantID, record.ReqID), string(value))
+}
+
+func deleteBulkPortsReqRecord(tenantID, reqID string) error {
+	err := common.GetDataBase().DeleteLeaf(dbaccessor.GetKeyOfBulkPortsReq(tenantID, reqID))
+	if err != nil && !IsKeyNotFoundError(err) {
+		klog.Warningf("deleteBulkPortsReqRecord: DeleteLeaf req[%s/%s] error: %v", tenantID, reqID, err)
+		return err
+	}
+	return nil
+}
+
+func isBulkPortsReqRecordExpired(record *BulkPortsReqRecord, now time.Time) bool {
+	createTime, err := time.Parse(ExceptPortTimeFormat, record.CreateTime)
+	if err != nil {
+		return true
+	}
+	return now.Sub(createTime) >= time.Duration(BulkPortsReqRetentionInSec)*time.Second
+}
+
+// isBulkPortsReqReplayable checks the ports of the recorded response are still
+// alive, a request repeated after its ports are deleted must create new ones.
+func isBulkPortsReqReplayable(record *BulkPortsReqRecord, digest string) bool {
+	if record.Digest != digest || record.Resp == nil || isBulkPortsReqRecordExpired(record, time.Now().UTC()) {
+		return false
+	}
+	for _, port := range record.Resp.Ports {
+		_, err := GetPortObjRepoSingleton().Get(port.PortID)
+		if err != nil {
+			klog.Infof("isBulkPortsReqReplayable: port[id: %s] of req[%s] not exist", port.PortID, record.ReqID)
+			return false
+		}
+	}
+	return true
+}
+
+// CreateBulkPortsOnce creates the bulk ports at most once for a request id in
+// the retention window. Duplicates are serialised, and a replay of a succeeded
+// request returns the original response. Failed requests are not recorded,
+// their ports are rolled back and they can be retried.
+func CreateBulkPortsOnce(req *mgriaas.MgrBulkPortsReq) (*mgragt.CreatePortsResp, error) {
+	if req.TranId == "" || len(req.Ports) == 0 {
+		return CreateBulkPorts(req)
+	}
+
+	tenantID := req.Ports[0].TenantId
+	unlock := lockBulkPortsReq(tenantID + "/" + req.TranId)
+	defer unlock()
+
+	digest := makeBulkPortsReqDigest(req)
+	record, err := getBulkPortsReqRecord(tenantID, req.TranId)
+	if err == nil && isBulkPortsReqReplayable(record, digest) {
+		klog.Infof("CreateBulkPortsOnce: replay req[%s] of tenant[%s] created at %s",
+			req.TranId, tenantID, record.CreateTime)
+		return record.Resp, nil
+	}
+	if err != nil && !IsKeyNotFoundError(err) {
+		klog.Warningf("CreateBulkPortsOnce: getBulkPortsReqRecord req[%s] error: %v", req.TranId, err)
+	}
+
+	resp, err := CreateBulkPorts(req)
+	if err != nil {
+		return nil, err
+	}
+
+	record = &BulkPortsReqRecord{ReqID: req.TranId, TenantID: tenantID, Digest: digest, Resp: resp,
+		CreateTime: time.Now().UTC().Format(ExceptPortTimeFormat)}
+	err = saveBulkPortsReqRecord(record)
+	if err != nil {
+		klog.Warningf("CreateBulkPortsOnce: saveBulkPortsReqRecord req[%s] error: %v", req.TranId, err)
+	}
+	return resp, nil
+}
+
+// StartBulkPortsReqCleaner deletes the records of bulk ports requests out of
+// the retention window periodically.
+func StartBulkPortsReqCleaner() {
+	go func() {
+		for {
+			time.Sleep(time.Duration(BulkPortsReqCleanIntervalInSec) * time.Second)
+			CleanBulkPortsReqs()
+		}
+	}()
+}
+
+func CleanBulkPortsReqs() {
+	key := dbaccessor.GetKeyOfBulkPortsReqs()
+	tenantNodes, err := common.GetDataBase().ReadDir(key)
+	if err != nil {
+		if !IsKeyNotFoundError(err) {
+			klog.Warningf("CleanBulkPortsReqs: ReadDir FAILED, error: %v", err)
+		}
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, tenantNode := range tenantNodes {
+		tenantID := strings.TrimPrefix(tenantNode.Key, key+"/")
+		reqNodes, err := common.GetDataBase().ReadDir(tenantNode.Key)
+		if err != nil {
+			klog.Warningf("CleanBulkPortsReqs: ReadDir of tenant[%s] FAILED, error: %v", tenantID, err)
+			continue
+		}
+		for _, reqNode := range reqNodes {
+			var record BulkPortsReqRecord
+			err := json.Unmarshal([]byte(reqNode.Value), &record)
+			if err == nil && !isBulkPortsReqRecordExpired(&record, now) {
+				continue
+			}
+			reqID := strings.TrimPrefix(reqNode.Key, tenantNode.Key+"/")
+			unlock := lockBulkPortsReq(tenantID + "/" + reqID)
+			deleteBulkPortsReqRecord(tenantID, reqID)
+			unlock()
+		}
+	}
+}
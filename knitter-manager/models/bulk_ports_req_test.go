@@ -0,0 +1,184 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bouk/monkey"
+	"github.com/coreos/etcd/client"
+	"github.com/golang/gostub"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/knitter-manager/tests/mock/db-mock"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-agt"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+)
+
+func makeTestBulkPortsReqRecord(req *mgriaas.MgrBulkPortsReq, createTime time.Time) string {
+	record := &BulkPortsReqRecord{ReqID: req.TranId, TenantID: "tenant_id", Digest: makeBulkPortsReqDigest(req),
+		Resp:       &mgragt.CreatePortsResp{Ports: []mgragt.CreatePortInfo{{Name: "eth0", PortID: "port-0"}}},
+		CreateTime: createTime.UTC().Format(ExceptPortTimeFormat)}
+	value, _ := json.Marshal(record)
+	return string(value)
+}
+
+func TestConvertBulkPortsReqRetention(t *testing.T) {
+	Convey("TestConvertBulkPortsReqRetention", t, func() {
+		for _, retention := range []string{"", "abc", "0", "86401"} {
+			seconds, ok := ConvertBulkPortsReqRetention(retention)
+			So(seconds, ShouldEqual, DefaultBulkPortsReqRetentionInSec)
+			So(ok, ShouldBeFalse)
+		}
+		seconds, ok := ConvertBulkPortsReqRetention("300")
+		So(seconds, ShouldEqual, 300)
+		So(ok, ShouldBeTrue)
+	})
+}
+
+func TestCreateBulkPortsOnce(t *testing.T) {
+	Convey("TestCreateBulkPortsOnce", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		GetPortObjRepoSingleton().Init()
+		GetPortObjRepoSingleton().Add(&PortObj{ID: "port-0"})
+
+		created := 0
+		resp := &mgragt.CreatePortsResp{Ports: []mgragt.CreatePortInfo{{Name: "eth0", PortID: "port-new"}}}
+		monkey.Patch(CreateBulkPorts, func(req *mgriaas.MgrBulkPortsReq) (*mgragt.CreatePortsResp, error) {
+			created++
+			return resp, nil
+		})
+		defer monkey.UnpatchAll()
+
+		req := makeTestBulkPortsReq(1)
+		req.Ports[0].TenantId = "tenant_id"
+		reqKey := dbaccessor.GetKeyOfBulkPortsReq("tenant_id", "req1")
+
+		Convey("first request is created and recorded\n", func() {
+			mockDB.EXPECT().ReadLeaf(reqKey).Return("", errors.New("100: Key not found"))
+			mockDB.EXPECT().SaveLeaf(reqKey, gomock.Any()).Return(nil)
+			result, err := CreateBulkPortsOnce(req)
+			So(err, ShouldBeNil)
+			So(result, ShouldEqual, resp)
+			So(created, ShouldEqual, 1)
+		})
+
+		Convey("retried request replays the original response\n", func() {
+			mockDB.EXPECT().ReadLeaf(reqKey).Return(makeTestBulkPortsReqRecord(req, time.Now()), nil)
+			result, err := CreateBulkPortsOnce(req)
+			So(err, ShouldBeNil)
+			So(result.Ports[0].PortID, ShouldEqual, "port-0")
+			So(created, ShouldEqual, 0)
+		})
+
+		Convey("request with same id but different ports is created again\n", func() {
+			record := makeTestBulkPortsReqRecord(makeTestBulkPortsReq(2), time.Now())
+			mockDB.EXPECT().ReadLeaf(reqKey).Return(record, nil)
+			mockDB.EXPECT().SaveLeaf(reqKey, gomock.Any()).Return(nil)
+			result, err := CreateBulkPortsOnce(req)
+			So(err, ShouldBeNil)
+			So(result, ShouldEqual, resp)
+			So(created, ShouldEqual, 1)
+		})
+
+		Convey("expired record or deleted ports are not replayed\n", func() {
+			mockDB.EXPECT().ReadLeaf(reqKey).Return(makeTestBulkPortsReqRecord(req, time.Now().Add(-time.Hour)), nil)
+			mockDB.EXPECT().SaveLeaf(reqKey, gomock.Any()).Return(nil)
+			_, err := CreateBulkPortsOnce(req)
+			So(err, ShouldBeNil)
+
+			GetPortObjRepoSingleton().Del("port-0")
+			mockDB.EXPECT().ReadLeaf(reqKey).Return(makeTestBulkPortsReqRecord(req, time.Now()), nil)
+			mockDB.EXPECT().SaveLeaf(reqKey, gomock.Any()).Return(nil)
+			_, err = CreateBulkPortsOnce(req)
+			So(err, ShouldBeNil)
+			So(created, ShouldEqual, 2)
+		})
+
+		Convey("failed request is not recorded\n", func() {
+			monkey.Patch(CreateBulkPorts, func(req *mgriaas.MgrBulkPortsReq) (*mgragt.CreatePortsResp, error) {
+				return nil, errors.New("create bulk ports failed")
+			})
+			mockDB.EXPECT().ReadLeaf(reqKey).Return("", errors.New("100: Key not found"))
+			_, err := CreateBulkPortsOnce(req)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("request without id is not deduplicated\n", func() {
+			req.TranId = ""
+			_, err := CreateBulkPortsOnce(req)
+			So(err, ShouldBeNil)
+			So(created, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestLockBulkPortsReq(t *testing.T) {
+	Convey("TestLockBulkPortsReq", t, func() {
+		var wg sync.WaitGroup
+		running, maxRunning := 0, 0
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock := lockBulkPortsReq("tenant_id/req1")
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				time.Sleep(time.Millisecond)
+				running--
+				unlock()
+			}()
+		}
+		wg.Wait()
+		So(maxRunning, ShouldEqual, 1)
+		So(bulkPortsReqLocks, ShouldBeEmpty)
+	})
+}
+
+func TestCleanBulkPortsReqs(t *testing.T) {
+	Convey("TestCleanBulkPortsReqs", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+
+		req := makeTestBulkPortsReq(1)
+		reqsKey := dbaccessor.GetKeyOfBulkPortsReqs()
+		tenantKey := reqsKey + "/tenant_id"
+		reqNodes := []*client.Node{
+			{Key: tenantKey + "/req1", Value: makeTestBulkPortsReqRecord(req, time.Now())},
+			{Key: tenantKey + "/req2", Value: makeTestBulkPortsReqRecord(req, time.Now().Add(-time.Hour))},
+		}
+		gomock.InOrder(
+			mockDB.EXPECT().ReadDir(reqsKey).Return([]*client.Node{{Key: tenantKey, Dir: true}}, nil),
+			mockDB.EXPECT().ReadDir(tenantKey).Return(reqNodes, nil),
+			mockDB.EXPECT().DeleteLeaf(dbaccessor.GetKeyOfBulkPortsReq("tenant_id", "req2")).Return(nil),
+		)
+		CleanBulkPortsReqs()
+	})
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/gostub"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-manager/tests"
+	"github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/agt-mgr"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+)
+
+func makeTestBulkPortsReq(num int) *mgriaas.MgrBulkPortsReq {
+	req := &mgriaas.MgrBulkPortsReq{TranId: "req1"}
+	for i := 0; i < num; i++ {
+		req.Ports = append(req.Ports, &mgriaas.MgrPortReq{
+			AgtPortReq: agtmgr.AgtPortReq{
+				TenantID: "tenant_id",
+				PortName: "eth" + strconv.Itoa(i),
+			},
+			NetworkId: "network-id",
+			SubnetId:  "subnet-id",
+		})
+	}
+	return req
+}
+
+func makeTestIaasPorts(req *mgriaas.MgrBulkPortsReq, idPrefix string) []*iaasaccessor.Interface {
+	ports := make([]*iaasaccessor.Interface, 0)
+	for i, port := range req.Ports {
+		ports = append(ports, &iaasaccessor.Interface{
+			Id:        idPrefix + strconv.Itoa(i),
+			Name:      port.PortName,
+			NetworkId: port.NetworkId,
+			SubnetId:  port.SubnetId,
+		})
+	}
+	return ports
+}
+
+func TestConvertBulkPortsChunkSize(t *testing.T) {
+	Convey("TestConvertBulkPortsChunkSize", t, func() {
+		size, ok := ConvertBulkPortsChunkSize("")
+		So(size, ShouldEqual, DefaultBulkPortsChunkSize)
+		So(ok, ShouldBeFalse)
+		size, ok = ConvertBulkPortsChunkSize("abc")
+		So(size, ShouldEqual, DefaultBulkPortsChunkSize)
+		So(ok, ShouldBeFalse)
+		size, ok = ConvertBulkPortsChunkSize("0")
+		So(size, ShouldEqual, DefaultBulkPortsChunkSize)
+		So(ok, ShouldBeFalse)
+		size, ok = ConvertBulkPortsChunkSize("101")
+		So(size, ShouldEqual, DefaultBulkPortsChunkSize)
+		So(ok, ShouldBeFalse)
+		size, ok = ConvertBulkPortsChunkSize("5")
+		So(size, ShouldEqual, 5)
+		So(ok, ShouldBeTrue)
+	})
+}
+
+func TestCreateIaasBulkPortsInChunks(t *testing.T) {
+	Convey("TestCreateIaasBulkPortsInChunks", t, func() {
+		cfgMock := gomock.NewController(t)
+		defer cfgMock.Finish()
+		mockIaas := test.NewMockIaaS(cfgMock)
+		stubs := gostub.Stub(&BulkPortsChunkSize, 2)
+		defer stubs.Reset()
+		stubs.StubFunc(&newBulkPortsNonce, "nonce1")
+
+		Convey("split into chunks and tag port names\n", func() {
+			req := makeTestBulkPortsReq(5)
+			sizes := make([]int, 0)
+			mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).DoAndReturn(
+				func(chunk *mgriaas.MgrBulkPortsReq) ([]*iaasaccessor.Interface, error) {
+					tag := MakeBulkPortsTag("req1", "nonce1", len(sizes))
+					for _, port := range chunk.Ports {
+						So(strings.HasPrefix(port.PortName, tag), ShouldBeTrue)
+					}
+					sizes = append(sizes, len(chunk.Ports))
+					return makeTestIaasPorts(chunk, tag), nil
+				}).Times(3)
+
+			ports, err := CreateIaasBulkPortsInChunks(mockIaas, req)
+			So(err, ShouldBeNil)
+			So(sizes, ShouldResemble, []int{2, 2, 1})
+			So(len(ports), ShouldEqual, 5)
+			for i, port := range ports {
+				So(port.Name, ShouldEqual, "eth"+strconv.Itoa(i))
+			}
+			So(req.Ports[0].PortName, ShouldEqual, "eth0")
+		})
+
+		Convey("adopt ports created by a timed out chunk\n", func() {
+			req := makeTestBulkPortsReq(2)
+			tag := MakeBulkPortsTag("req1", "nonce1", 0)
+			created := makeTestIaasPorts(tagBulkPortsReq(req, tag), "id")
+			others := []*iaasaccessor.Interface{{Id: "other", Name: "eth0", NetworkId: "network-id"}}
+
+			mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(nil, errors.New("timeout"))
+			mockIaas.EXPECT().ListPorts("network-id").Return(append(others, created...), nil)
+
+			ports, err := CreateIaasBulkPortsInChunks(mockIaas, req)
+			So(err, ShouldBeNil)
+			So(len(ports), ShouldEqual, 2)
+			So(ports[0].Id, ShouldEqual, "id0")
+			So(ports[0].Name, ShouldEqual, "eth0")
+			So(ports[1].Name, ShouldEqual, "eth1")
+		})
+
+		Convey("delete partially created ports then retry\n", func() {
+			req := makeTestBulkPortsReq(2)
+			tag := MakeBulkPortsTag("req1", "nonce1", 0)
+			partial := makeTestIaasPorts(tagBulkPortsReq(req, tag), "partial")[:1]
+
+			gomock.InOrder(
+				mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(nil, errors.New("timeout")),
+				mockIaas.EXPECT().ListPorts("network-id").Return(partial, nil),
+				mockIaas.EXPECT().DeletePort("partial0").Return(nil),
+				mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).DoAndReturn(
+					func(chunk *mgriaas.MgrBulkPortsReq) ([]*iaasaccessor.Interface, error) {
+						return makeTestIaasPorts(chunk, "id"), nil
+					}),
+			)
+
+			ports, err := CreateIaasBulkPortsInChunks(mockIaas, req)
+			So(err, ShouldBeNil)
+			So(len(ports), ShouldEqual, 2)
+			So(ports[1].Name, ShouldEqual, "eth1")
+		})
+
+		Convey("keep the ports of the pod created before when a hot-plug chunk failed\n", func() {
+			req := makeTestBulkPortsReq(1)
+			live := makeTestIaasPorts(tagBulkPortsReq(req, MakeBulkPortsTag("req1", "nonce0", 0)), "live")
+
+			mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(nil, errors.New("timeout")).
+				Times(OpenStackOpsRetryTime)
+			mockIaas.EXPECT().ListPorts("network-id").Return(live, nil).Times(OpenStackOpsRetryTime)
+			mockIaas.EXPECT().DeletePort(gomock.Any()).Times(0)
+
+			ports, err := CreateIaasBulkPortsInChunks(mockIaas, req)
+			So(err, ShouldNotBeNil)
+			So(ports, ShouldBeNil)
+		})
+
+		Convey("roll back previous chunks when a chunk failed\n", func() {
+			req := makeTestBulkPortsReq(3)
+			gomock.InOrder(
+				mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).DoAndReturn(
+					func(chunk *mgriaas.MgrBulkPortsReq) ([]*iaasaccessor.Interface, error) {
+						return makeTestIaasPorts(chunk, "id"), nil
+					}),
+				mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(nil, errors.New("timeout")),
+				mockIaas.EXPECT().ListPorts("network-id").Return(nil, errors.New("list error")),
+				mockIaas.EXPECT().DeletePort("id0").Return(nil),
+				mockIaas.EXPECT().DeletePort("id1").Return(nil),
+			)
+
+			ports, err := CreateIaasBulkPortsInChunks(mockIaas, req)
+			So(err, ShouldNotBeNil)
+			So(ports, ShouldBeNil)
+		})
+	})
+}
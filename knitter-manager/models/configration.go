@@ -31,6 +31,7 @@ import (
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/ZTE/Knitter/pkg/noauth_openstack"
 	"github.com/ZTE/Knitter/pkg/openstack"
+	"github.com/ZTE/Knitter/pkg/vnfm"
 	"github.com/antonholmquist/jason"
 )
 
@@ -42,6 +43,10 @@ type VnpmConf struct {
 	URL string `json:"url"`
 }
 
+type EncapVnfm struct {
+	Config *vnfm.VnfmConf `json:"vnfm"`
+}
+
 type EncapNoauthOpenStackConf struct {
 	NoauthOpenStack noauth_openstack.NoauthOpenStackConf `json:"noauth_openstack"`
 }
@@ -133,14 +138,38 @@ func HandleOpenStackConfg(conf *openstack.OpenStackConf) (*openstack.OpenStackCo
 	return &cfg, nil
 }
 
-//var CheckVnfmConfig = func(conf vnfm.VnfmConf) error {
-//	delURL := dbaccessor.GetVnfmDeletePortUrl(conf.URL, conf.NfInstanceID, "testnwport")
-//	statusCode, _ := http.GetHTTPClientObj().Delete(delURL)
-//	if statusCode != 404 {
-//		return errors.New("checkvnfmconfig err")
-//	}
-//	return nil
-//}
+var CheckVnfmConfig = func(conf vnfm.VnfmConf) error {
+	err := vnfm.CheckVnfm(conf)
+	if err != nil {
+		klog.Errorf("CheckVnfmConfig: CheckVnfm[%+v] err: %v", conf, err)
+		return errors.New("checkvnfmconfig err")
+	}
+	return nil
+}
+
+func HandleVnfmConfg(conf *vnfm.VnfmConf) (*vnfm.VnfmConf, error) {
+	if conf == nil || conf.URL == "" || conf.NfInstanceID == "" {
+		klog.Errorf("HandleVnfmConfg: vnfm config[%+v] is invalid", conf)
+		return nil, BuildErrWithCode(NETHTTP.StatusBadRequest, vnfm.ErrInvalidConf)
+	}
+	err := CheckVnfmConfig(*conf)
+	if err != nil {
+		return nil, BuildErrWithCode(NETHTTP.StatusBadRequest, err)
+	}
+	value, _ := json.Marshal(conf)
+	err = SaveVnfmConfg(value)
+	if err != nil {
+		klog.Errorf("SaveVnfmConfg err: %v", err)
+		return nil, BuildErrWithCode(NETHTTP.StatusInternalServerError, err)
+	}
+	saveAdminTenantInfoWithIaasTenantID()
+	err = iaas.InitVnfm()
+	if err != nil {
+		klog.Errorf("InitVnfm err: %v", err)
+		return nil, BuildErrWithCode(NETHTTP.StatusInternalServerError, err)
+	}
+	return conf, nil
+}
 
 func saveAdminTenantInfoWithIaasTenantID() {
 	iaasTenantID, _ := iaas.GetIaasTenantIDByPaasTenantID(constvalue.PaaSTenantAdminDefaultUUID)
@@ -152,6 +181,11 @@ func saveAdminTenantInfoWithIaasTenantID() {
 
 func initIaas(cfg *jason.Object) error {
 	//common.SetIaaSTenants(uuid.NIL.String())
+	if common.GetVnfmCfg() != "" {
+		klog.Info("Now-Use-VNFM")
+		saveAdminTenantInfoWithIaasTenantID()
+		return iaas.InitVnfm()
+	}
 	embedded, err := cfg.GetBoolean("iaas", "embedded")
 	if err == nil && embedded == true {
 		klog.Info("Now-Use-embedded-network-server")
@@ -206,8 +240,16 @@ func InitEnv4Manger(confObj *jason.Object) error {
 	}
 
 	GetSyncMgt().SetInterval(interval)
+	err = SetSyncTunnel(confObj)
+	if err != nil {
+		klog.Errorf("InitEnv4Manger: SetSyncTunnel error: %v", err)
+		return err
+	}
 	SetNetQuota(confObj)
 	UpdateEtcd4NetQuota()
+	SetBulkPortsChunkSize(confObj)
+	SetBulkPortsReqRetention(confObj)
+	SetPortPoolConfs(confObj)
 
 	LoadAllResourcesToCache()
 	CancelResidualTenants()
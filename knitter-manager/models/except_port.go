@@ -0,0 +1,323 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZTE/Knitter/knitter-manager/const-value"
+	"github.com/ZTE/Knitter/knitter-manager/err-obj"
+	"github.com/ZTE/Knitter/knitter-manager/iaas"
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/vnfm"
+)
+
+const (
+	ExceptPortTimeFormat = "2006-01-02T15:04:05Z"
+
+	ExceptStatPending  = "pending"
+	ExceptStatRetrying = "retrying"
+)
+
+var (
+	ExceptPortReapIntervalInSec = 30
+	ExceptPortBackoffBaseInSec  = 30
+	ExceptPortBackoffMaxInSec   = 3600
+
+	exceptPortReapLock sync.Mutex
+)
+
+func saveExceptPortToDB(port *ExceptPort) error {
+	url := dbaccessor.GetKeyOfExceptionalPort(port.ID)
+	value, err := json.Marshal(port)
+	if err != nil {
+		klog.Errorf("saveExceptPortToDB: marshal exceptional port[%v] failed, error:%v", port, err)
+		return fmt.Errorf("%v:marshal exceptional port failed", err)
+	}
+
+	err = common.GetDataBase().SaveLeaf(url, string(value))
+	if err != nil {
+		klog.Errorf("saveExceptPortToDB: save exceptional port[%v] to DB failed, error:%v", port, err)
+		return fmt.Errorf("%v:save exceptional port to DB failed", err)
+	}
+	return nil
+}
+
+func GetExceptPort(portID string) (*ExceptPort, error) {
+	value, err := common.GetDataBase().ReadLeaf(dbaccessor.GetKeyOfExceptionalPort(portID))
+	if err != nil {
+		if IsKeyNotFoundError(err) {
+			return nil, errobj.ErrExceptPortNotExist
+		}
+		klog.Errorf("GetExceptPort: ReadLeaf port[id: %s] FAILED, error: %v", portID, err)
+		return nil, err
+	}
+
+	var port ExceptPort
+	err = json.Unmarshal([]byte(value), &port)
+	if err != nil {
+		klog.Errorf("GetExceptPort: json.Unmarshal(%s) FAILED, error: %v", value, err)
+		return nil, fmt.Errorf("%v:unmarshal exceptional port failed", err)
+	}
+	return &port, nil
+}
+
+func GetAllExceptPorts() ([]*ExceptPort, error) {
+	nodes, err := common.GetDataBase().ReadDir(dbaccessor.GetKeyOfExceptionalPortGroup())
+	if err != nil {
+		if IsKeyNotFoundError(err) {
+			return []*ExceptPort{}, nil
+		}
+		klog.Errorf("GetAllExceptPorts: ReadDir FAILED, error: %v", err)
+		return nil, err
+	}
+
+	ports := make([]*ExceptPort, 0)
+	for _, node := range nodes {
+		var port ExceptPort
+		err := json.Unmarshal([]byte(node.Value), &port)
+		if err != nil {
+			klog.Warningf("GetAllExceptPorts: json.Unmarshal(%s) FAILED, error: %v, skip it", node.Value, err)
+			continue
+		}
+		ports = append(ports, &port)
+	}
+	return ports, nil
+}
+
+func DeleteExceptPort(portID string) error {
+	err := common.GetDataBase().DeleteLeaf(dbaccessor.GetKeyOfExceptionalPort(portID))
+	if err != nil && !IsKeyNotFoundError(err) {
+		klog.Errorf("DeleteExceptPort: DeleteLeaf port[id: %s] FAILED, error: %v", portID, err)
+		return err
+	}
+	klog.Infof("DeleteExceptPort: delete exceptional port[id: %s] SUCC", portID)
+	return nil
+}
+
+// StartExceptPortReaper retries the failed operations of the exceptional
+// ports periodically, each port is retried with an exponential backoff.
+func StartExceptPortReaper() {
+	go func() {
+		for {
+			time.Sleep(time.Duration(ExceptPortReapIntervalInSec) * time.Second)
+			ReapExceptPorts()
+		}
+	}()
+}
+
+func ReapExceptPorts() {
+	ports, err := GetAllExceptPorts()
+	if err != nil {
+		klog.Warningf("ReapExceptPorts: GetAllExceptPorts error: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, port := range ports {
+		if !isExceptPortDue(port, now) {
+			continue
+		}
+		RetryExceptPort(port.ID)
+	}
+}
+
+func isExceptPortDue(port *ExceptPort, now time.Time) bool {
+	next, err := time.Parse(ExceptPortTimeFormat, port.NextRetryTime)
+	if err != nil {
+		return true
+	}
+	return !now.Before(next)
+}
+
+func getExceptPortBackoff(retryTimes int) time.Duration {
+	backoff := ExceptPortBackoffBaseInSec
+	for i := 1; i < retryTimes && backoff < ExceptPortBackoffMaxInSec; i++ {
+		backoff *= 2
+	}
+	if backoff > ExceptPortBackoffMaxInSec {
+		backoff = ExceptPortBackoffMaxInSec
+	}
+	return time.Duration(backoff) * time.Second
+}
+
+// RetryExceptPort retries the expected operations of the exceptional port
+// now, the record is cleared on success, otherwise the next retry is delayed.
+func RetryExceptPort(portID string) (*ExceptPort, error) {
+	exceptPortReapLock.Lock()
+	defer exceptPortReapLock.Unlock()
+
+	port, err := GetExceptPort(portID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reapExceptPort(port)
+	if err == nil {
+		return port, DeleteExceptPort(portID)
+	}
+
+	port.RetryTimes++
+	port.ExceptStat = ExceptStatRetrying
+	port.LastError = err.Error()
+	port.NextRetryTime = time.Now().UTC().Add(getExceptPortBackoff(port.RetryTimes)).Format(ExceptPortTimeFormat)
+	klog.Warningf("RetryExceptPort: %d times reap port[id: %s] error: %v, next retry at %s",
+		port.RetryTimes, portID, err, port.NextRetryTime)
+	saveErr := saveExceptPortToDB(port)
+	if saveErr != nil {
+		klog.Errorf("RetryExceptPort: saveExceptPortToDB[%v] error: %v", port, saveErr)
+	}
+	return port, err
+}
+
+// MarkExceptPortDestroying marks the exceptional port saved by a failed
+// destroy, so its logical or physical record is not taken as in use. The
+// port is saved to be deleted if the record is missing.
+func MarkExceptPortDestroying(tenantID, portID string) error {
+	exceptPortReapLock.Lock()
+	defer exceptPortReapLock.Unlock()
+
+	port, err := GetExceptPort(portID)
+	if err == errobj.ErrExceptPortNotExist {
+		klog.Warningf("MarkExceptPortDestroying: port[id: %s] is not exceptional, save it to be deleted", portID)
+		port = newExceptPort(tenantID, portID, PortTypeNonattatched, "", "destroy port failed")
+	} else if err != nil {
+		klog.Errorf("MarkExceptPortDestroying: GetExceptPort[id: %s] error: %v", portID, err)
+		return err
+	}
+
+	port.Destroying = true
+	err = saveExceptPortToDB(port)
+	if err != nil {
+		klog.Errorf("MarkExceptPortDestroying: saveExceptPortToDB[%v] error: %v", port, err)
+		return err
+	}
+	return nil
+}
+
+// reapExceptPort returns nil if the port is released or no longer needs to be.
+var reapExceptPort = func(port *ExceptPort) error {
+	inUse, err := isExceptPortInUse(port.ID)
+	if err != nil {
+		return err
+	}
+	if inUse && !port.Destroying {
+		klog.Infof("reapExceptPort: port[id: %s] is in use, drop it from exceptional ports", port.ID)
+		return nil
+	}
+
+	err = releaseExceptPort(port)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return deleteOrphanedPortRecords(port.ID)
+	}
+	return nil
+}
+
+func releaseExceptPort(port *ExceptPort) error {
+
+	tenantID := port.TenantID
+	if tenantID == "" {
+		tenantID = constvalue.PaaSTenantAdminDefaultUUID
+	}
+	iaasObj := iaas.GetIaaS(tenantID)
+	if iaasObj == nil {
+		return fmt.Errorf("iaas of tenant[%s] not found", tenantID)
+	}
+
+	_, err := iaasObj.GetPort(port.ID)
+	if err != nil {
+		if isErrPortNotFound(err) {
+			klog.Infof("releaseExceptPort: port[id: %s] not exist in iaas", port.ID)
+			return nil
+		}
+		return fmt.Errorf("%v:get port from iaas error", err)
+	}
+
+	for _, op := range port.ExpectProcOps {
+		switch op {
+		case PortDetachOp:
+			if port.HostID == "" {
+				continue
+			}
+			err = iaasObj.DetachPortFromVM(port.HostID, port.ID)
+		case PortDeleteOp:
+			err = iaasObj.DeletePort(port.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("%v:%s error", err, op)
+		}
+	}
+
+	klog.Infof("reapExceptPort: reap port[id: %s] SUCC", port.ID)
+	return nil
+}
+
+// deleteOrphanedPortRecords deletes the records left by a failed destroy
+// after its port is released in iaas.
+func deleteOrphanedPortRecords(portID string) error {
+	err := DeleteLogicalPort(portID)
+	if err != nil {
+		return fmt.Errorf("%v:delete orphaned logical port error", err)
+	}
+	GetPortObjRepoSingleton().Del(portID)
+
+	err = DeletePhysicalPort(portID)
+	if err != nil {
+		return fmt.Errorf("%v:delete orphaned physical port error", err)
+	}
+	GetPhysPortObjRepoSingleton().Del(portID)
+
+	klog.Infof("deleteOrphanedPortRecords: delete records of port[id: %s] SUCC", portID)
+	return nil
+}
+
+func isExceptPortInUse(portID string) (bool, error) {
+	_, err := GetLogicalPort(portID)
+	if err == nil {
+		return true, nil
+	}
+	if !IsKeyNotFoundError(err) {
+		return false, fmt.Errorf("%v:get logical port error", err)
+	}
+
+	_, err = GetPhysicalPort(portID)
+	if err == nil {
+		return true, nil
+	}
+	if !IsKeyNotFoundError(err) {
+		return false, fmt.Errorf("%v:get physical port error", err)
+	}
+	return false, nil
+}
+
+// isErrPortNotFound checks the VNFM not-found error first, then falls back
+// to the 404 status reported in errors of the other IaaS drivers
+func isErrPortNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == vnfm.ErrPortNotFound {
+		return true
+	}
+	return strings.Contains(err.Error(), "404")
+}
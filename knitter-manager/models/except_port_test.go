@@ -0,0 +1,231 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/gostub"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-manager/err-obj"
+	"github.com/ZTE/Knitter/knitter-manager/iaas"
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/knitter-manager/tests"
+	"github.com/ZTE/Knitter/knitter-manager/tests/mock/db-mock"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/vnfm"
+)
+
+func TestGetExceptPortBackoff(t *testing.T) {
+	Convey("TestGetExceptPortBackoff", t, func() {
+		So(getExceptPortBackoff(1), ShouldEqual, 30*time.Second)
+		So(getExceptPortBackoff(2), ShouldEqual, 60*time.Second)
+		So(getExceptPortBackoff(4), ShouldEqual, 240*time.Second)
+		So(getExceptPortBackoff(100), ShouldEqual, 3600*time.Second)
+	})
+}
+
+func TestIsExceptPortDue(t *testing.T) {
+	Convey("TestIsExceptPortDue", t, func() {
+		now := time.Now().UTC()
+		port := &ExceptPort{NextRetryTime: now.Add(time.Minute).Format(ExceptPortTimeFormat)}
+		So(isExceptPortDue(port, now), ShouldBeFalse)
+		port.NextRetryTime = now.Add(-time.Minute).Format(ExceptPortTimeFormat)
+		So(isExceptPortDue(port, now), ShouldBeTrue)
+		port.NextRetryTime = ""
+		So(isExceptPortDue(port, now), ShouldBeTrue)
+	})
+}
+
+func TestRetryExceptPort(t *testing.T) {
+	Convey("TestRetryExceptPort", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		mockIaas := test.NewMockIaaS(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		stubs.StubFunc(&iaas.GetIaaS, mockIaas)
+
+		keyNotFound := errors.New("100: Key not found")
+		exceptKey := dbaccessor.GetKeyOfExceptionalPort("port-id")
+		port := ExceptPort{ID: "port-id", TenantID: "tenant-id", PortType: PortTypeAttatched,
+			HostID: "vm-id", ExpectProcOps: []string{PortDetachOp, PortDeleteOp}}
+		portStr, _ := json.Marshal(port)
+
+		Convey("record not exist\n", func() {
+			mockDB.EXPECT().ReadLeaf(exceptKey).Return("", keyNotFound)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldEqual, errobj.ErrExceptPortNotExist)
+		})
+
+		Convey("detach and delete orphaned port then clear record\n", func() {
+			gomock.InOrder(
+				mockDB.EXPECT().ReadLeaf(exceptKey).Return(string(portStr), nil),
+				mockDB.EXPECT().ReadLeaf(createLogicalPortKey("port-id")).Return("", keyNotFound),
+				mockDB.EXPECT().ReadLeaf(createPhysicalPortKey("port-id")).Return("", keyNotFound),
+				mockIaas.EXPECT().GetPort("port-id").Return(nil, nil),
+				mockIaas.EXPECT().DetachPortFromVM("vm-id", "port-id").Return(nil),
+				mockIaas.EXPECT().DeletePort("port-id").Return(nil),
+				mockDB.EXPECT().DeleteLeaf(exceptKey).Return(nil),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("port in use only clears record\n", func() {
+			gomock.InOrder(
+				mockDB.EXPECT().ReadLeaf(exceptKey).Return(string(portStr), nil),
+				mockDB.EXPECT().ReadLeaf(createLogicalPortKey("port-id")).Return(`{"id": "port-id"}`, nil),
+				mockDB.EXPECT().DeleteLeaf(exceptKey).Return(nil),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("port not exist in iaas clears record\n", func() {
+			gomock.InOrder(
+				mockDB.EXPECT().ReadLeaf(exceptKey).Return(string(portStr), nil),
+				mockDB.EXPECT().ReadLeaf(createLogicalPortKey("port-id")).Return("", keyNotFound),
+				mockDB.EXPECT().ReadLeaf(createPhysicalPortKey("port-id")).Return("", keyNotFound),
+				mockIaas.EXPECT().GetPort("port-id").Return(nil, errors.New("got 404 instead")),
+				mockDB.EXPECT().DeleteLeaf(exceptKey).Return(nil),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("port not exist in vnfm clears record\n", func() {
+			gomock.InOrder(
+				mockDB.EXPECT().ReadLeaf(exceptKey).Return(string(portStr), nil),
+				mockDB.EXPECT().ReadLeaf(createLogicalPortKey("port-id")).Return("", keyNotFound),
+				mockDB.EXPECT().ReadLeaf(createPhysicalPortKey("port-id")).Return("", keyNotFound),
+				mockIaas.EXPECT().GetPort("port-id").Return(nil, vnfm.ErrPortNotFound),
+				mockDB.EXPECT().DeleteLeaf(exceptKey).Return(nil),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("failed retry is delayed with backoff\n", func() {
+			var saved ExceptPort
+			gomock.InOrder(
+				mockDB.EXPECT().ReadLeaf(exceptKey).Return(string(portStr), nil),
+				mockDB.EXPECT().ReadLeaf(createLogicalPortKey("port-id")).Return("", keyNotFound),
+				mockDB.EXPECT().ReadLeaf(createPhysicalPortKey("port-id")).Return("", keyNotFound),
+				mockIaas.EXPECT().GetPort("port-id").Return(nil, nil),
+				mockIaas.EXPECT().DetachPortFromVM("vm-id", "port-id").Return(nil),
+				mockIaas.EXPECT().DeletePort("port-id").Return(errors.New("connection timeout")),
+				mockDB.EXPECT().SaveLeaf(exceptKey, gomock.Any()).DoAndReturn(
+					func(_, value string) error {
+						return json.Unmarshal([]byte(value), &saved)
+					}),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldNotBeNil)
+			So(saved.RetryTimes, ShouldEqual, 1)
+			So(saved.ExceptStat, ShouldEqual, ExceptStatRetrying)
+			So(saved.LastError, ShouldContainSubstring, "connection timeout")
+			So(isExceptPortDue(&saved, time.Now().UTC()), ShouldBeFalse)
+		})
+	})
+}
+
+// deleteFailedPortService fails to delete ports in iaas like PortService does
+// after its retries.
+type deleteFailedPortService struct {
+	PortService
+}
+
+func (self *deleteFailedPortService) DeletePort(tranID TranID, portID, tenantID string) error {
+	SaveExceptPort(tenantID, portID, PortTypeNonattatched, "", "delete port failed")
+	return errors.New("connection timeout")
+}
+
+func TestReapExceptPortOfFailedDestroy(t *testing.T) {
+	Convey("TestReapExceptPortOfFailedDestroy", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		mockIaas := test.NewMockIaaS(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		stubs.StubFunc(&iaas.GetIaaS, mockIaas)
+		stubs.StubFunc(&GetPortServiceObj, &deleteFailedPortService{})
+
+		leaves := map[string]string{}
+		keyNotFound := errors.New("100: Key not found")
+		mockDB.EXPECT().ReadLeaf(gomock.Any()).DoAndReturn(func(key string) (string, error) {
+			value, ok := leaves[key]
+			if !ok {
+				return "", keyNotFound
+			}
+			return value, nil
+		}).AnyTimes()
+		mockDB.EXPECT().SaveLeaf(gomock.Any(), gomock.Any()).DoAndReturn(func(key, value string) error {
+			leaves[key] = value
+			return nil
+		}).AnyTimes()
+		mockDB.EXPECT().DeleteLeaf(gomock.Any()).DoAndReturn(func(key string) error {
+			if _, ok := leaves[key]; !ok {
+				return keyNotFound
+			}
+			delete(leaves, key)
+			return nil
+		}).AnyTimes()
+
+		portObj := &PortObj{ID: "port-id", TenantID: "tenant-id", NetworkID: "network-id"}
+		So(GetPortObjRepoSingleton().Add(portObj), ShouldBeNil)
+		defer GetPortObjRepoSingleton().Del("port-id")
+		So(SaveLogicalPort(&LogicalPort{ID: "port-id", TenantID: "tenant-id"}), ShouldBeNil)
+
+		So(DestroyLogicalPort("port-id"), ShouldNotBeNil)
+		exceptPort, err := GetExceptPort("port-id")
+		So(err, ShouldBeNil)
+		So(exceptPort.Destroying, ShouldBeTrue)
+
+		Convey("orphaned records are kept until the port is deleted in iaas\n", func() {
+			gomock.InOrder(
+				mockIaas.EXPECT().GetPort("port-id").Return(nil, nil),
+				mockIaas.EXPECT().DeletePort("port-id").Return(errors.New("connection timeout")),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldNotBeNil)
+			_, err = GetExceptPort("port-id")
+			So(err, ShouldBeNil)
+			_, err = GetLogicalPort("port-id")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("orphaned records are deleted with the port\n", func() {
+			gomock.InOrder(
+				mockIaas.EXPECT().GetPort("port-id").Return(nil, nil),
+				mockIaas.EXPECT().DeletePort("port-id").Return(nil),
+			)
+			_, err := RetryExceptPort("port-id")
+			So(err, ShouldBeNil)
+			_, err = GetExceptPort("port-id")
+			So(err, ShouldEqual, errobj.ErrExceptPortNotExist)
+			_, err = GetLogicalPort("port-id")
+			So(IsKeyNotFoundError(err), ShouldBeTrue)
+			_, err = GetPortObjRepoSingleton().Get("port-id")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -313,7 +313,7 @@ func (self *IPGroup) createIps(ips *[]IPInDB) error {
 	klog.Infof("IpGroup bulkPortsReq: [%v]", *bulkPortsReq)
 
 	if len(bulkPortsReq.Ports) > 0 {
-		ports, err = iaas.CreateBulkPorts(bulkPortsReq)
+		ports, err = CreateIaasBulkPortsInChunks(iaas, bulkPortsReq)
 		if err != nil {
 			klog.Errorf("IpGroup Create CreateBulkPorts error: [%v], ipgroup: [%v]", err.Error(), self.ID)
 			return BuildErrWithCode(http.StatusInternalServerError, err)
@@ -666,8 +666,10 @@ func (self *IPGroup) Update() (*IPGroupObject, error) {
 	return getIGFromCache(self.TenantID, self.ID)
 }
 
-func (self *IPGroup) ObtainIP() (*iaasaccessor.Interface, error) {
-	klog.Infof("Now in ObtainIP IpGroup Function")
+// ObtainIP occupies a free ip of the group, the ip is fixIP if it is not
+// empty, e.g. the ip pinned to the pod of a StatefulSet.
+func (self *IPGroup) ObtainIP(fixIP string) (*iaasaccessor.Interface, error) {
+	klog.Infof("Now in ObtainIP IpGroup Function, fixIP: [%v]", fixIP)
 	lockIG()
 	defer unlockIG()
 
@@ -679,19 +681,18 @@ func (self *IPGroup) ObtainIP() (*iaasaccessor.Interface, error) {
 	klog.Infof("ObtainIP get ig: [%+v]", igObject)
 
 	igInDb := TransIGObjectToIGInDB(igObject)
+	if fixIP != "" {
+		ip, err := findFreeIP(igInDb, fixIP)
+		if err != nil {
+			klog.Errorf("ObtainIP findFreeIP error: [%v], id: [%v]", err.Error(), self.ID)
+			return nil, err
+		}
+		return self.obtainPortOfIP(igInDb, ip.PortID)
+	}
 	for _, ip := range igInDb.IPs {
 		if !ip.Used {
-			port, err := iaas.GetIaaS(self.TenantID).GetPort(ip.PortID)
-			if err != nil {
-				klog.Errorf("ObtainIP GetPort error: [%v], portID: [%v]", err.Error(), ip.PortID)
-				continue
-			}
-			port.IPGroupID = self.ID
-
-			newIGInDB := makeNewIPGroupObject(igInDb, ip.PortID, true)
-			err = saveIGToDBAndCache(newIGInDB)
+			port, err := self.obtainPortOfIP(igInDb, ip.PortID)
 			if err != nil {
-				klog.Errorf("ObtainIP saveIGToDBAndCache error: [%v], portID: [%v]", err.Error(), ip.PortID)
 				continue
 			}
 			return port, nil
@@ -700,6 +701,37 @@ func (self *IPGroup) ObtainIP() (*iaasaccessor.Interface, error) {
 	return nil, errors.New("no available IP. group name:[" + igInDb.Name + "]")
 }
 
+func (self *IPGroup) obtainPortOfIP(igInDb *IPGroupInDB, portID string) (*iaasaccessor.Interface, error) {
+	port, err := iaas.GetIaaS(self.TenantID).GetPort(portID)
+	if err != nil {
+		klog.Errorf("ObtainIP GetPort error: [%v], portID: [%v]", err.Error(), portID)
+		return nil, err
+	}
+	port.IPGroupID = self.ID
+
+	newIGInDB := makeNewIPGroupObject(igInDb, portID, true)
+	err = saveIGToDBAndCache(newIGInDB)
+	if err != nil {
+		klog.Errorf("ObtainIP saveIGToDBAndCache error: [%v], portID: [%v]", err.Error(), portID)
+		return nil, err
+	}
+	return port, nil
+}
+
+func findFreeIP(igInDb *IPGroupInDB, ipAddr string) (*IPInDB, error) {
+	for i, ip := range igInDb.IPs {
+		if ip.IPAddr != ipAddr {
+			continue
+		}
+		if ip.Used {
+			return nil, BuildErrWithCode(http.StatusConflict, errors.New("ip["+ipAddr+"] is in use"))
+		}
+		return &igInDb.IPs[i], nil
+	}
+	return nil, BuildErrWithCode(http.StatusNotFound,
+		errors.New("ip["+ipAddr+"] is not in group["+igInDb.Name+"]"))
+}
+
 func makeNewIPGroupObject(oldIG *IPGroupInDB, portID string, used bool) *IPGroupInDB {
 	newIG := &IPGroupInDB{ID: oldIG.ID, TenantID: oldIG.TenantID, Name: oldIG.Name, NetworkID: oldIG.NetworkID}
 	for _, newIP := range oldIG.IPs {
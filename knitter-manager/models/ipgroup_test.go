@@ -87,3 +87,21 @@ func TestMakeBulkPortsReq(t *testing.T) {
 		So(ports.Ports[0].FixIP, ShouldBeEmpty)
 	})
 }
+
+func TestFindFreeIP(t *testing.T) {
+	igInDb := &IPGroupInDB{Name: "ig0", IPs: []IPInDB{
+		{IPAddr: "1.1.1.1", Used: true, PortID: "port0"},
+		{IPAddr: "1.1.1.2", Used: false, PortID: "port1"}}}
+
+	Convey("TestFindFreeIP:", t, func() {
+		ip, err := findFreeIP(igInDb, "1.1.1.2")
+		So(err, ShouldBeNil)
+		So(ip.PortID, ShouldEqual, "port1")
+
+		_, err = findFreeIP(igInDb, "1.1.1.1")
+		So(err.Error(), ShouldEqual, "409::ip[1.1.1.1] is in use")
+
+		_, err = findFreeIP(igInDb, "1.1.1.3")
+		So(err.Error(), ShouldEqual, "404::ip[1.1.1.3] is not in group[ig0]")
+	})
+}
@@ -61,6 +61,8 @@ type Network struct {
 	IsExternal  bool   `json:"is_external"`
 	CreateTime  string `json:"create_time"`
 	Description string `json:"description"`
+	// Mtu overrides the MTU of the pod interfaces derived by knitter-agent
+	Mtu int `json:"mtu,omitempty"`
 }
 
 func getNetworksKey() string {
@@ -166,6 +168,7 @@ type NetworkObject struct {
 	IsExternal  bool   `json:"is_external"`
 	CreateTime  string `json:"create_time"`
 	Description string `json:"description"`
+	Mtu         int    `json:"mtu,omitempty"`
 }
 
 type NetworkObjectRepo struct {
@@ -392,6 +395,7 @@ func TransNetworkToNetworkObject(net *Network) *NetworkObject {
 		IsExternal:  net.IsExternal,
 		CreateTime:  net.CreateTime,
 		Description: net.Description,
+		Mtu:         net.Mtu,
 	}
 }
 
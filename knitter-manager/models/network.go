@@ -67,6 +67,7 @@ type Net struct {
 	CreateTime      string `json:"create_time"`
 	Status          string `json:"state"`
 	Description     string `json:"description"`
+	Mtu             int    `json:"mtu,omitempty"`
 }
 
 type PaasNetwork struct {
@@ -83,6 +84,7 @@ type PaasNetwork struct {
 	SubnetID        string                         `json:"subnet_id"`
 	Provider        iaasaccessor.NetworkExtenAttrs `json:"provider"`
 	AllocationPools []subnets.AllocationPool       `json:"allocation_pools"`
+	Mtu             int                            `json:"mtu,omitempty"`
 }
 
 type EncapPaasNetwork struct {
@@ -103,6 +105,7 @@ type CreateProviderNetwork struct {
 	SegmentationID  string                   `json:"provider:segmentation_id"`
 	VlanTransparent bool                     `json:"vlan_transparent"`
 	AllocationPools []subnets.AllocationPool `json:"allocation_pools"`
+	Mtu             int                      `json:"mtu,omitempty"`
 }
 
 type EncapCreateProviderNetwork struct {
@@ -163,6 +166,7 @@ func transNetObjToPaasNetwork(netObj *NetworkObject, subnetObj *SubnetObject) *P
 			SegmentationID:  netObj.ExtAttrs.SegmentationID,
 		},
 		AllocationPools: allocPool,
+		Mtu:             netObj.Mtu,
 	}
 }
 
@@ -356,6 +360,13 @@ var DeleteNetwork = func(id string) error {
 		return errobj.ErrNetworkHasPortsInUse
 	}
 
+	defer ResumePortPool(id)
+	err = DrainPortPool(id)
+	if err != nil {
+		klog.Errorf("DelNetwork: DrainPortPool of network[id: %s] FAIL, error: %v", id, err)
+		return err
+	}
+
 	if !netObj.IsExternal {
 		err = forceDeleteNetwork(netObj.TenantID, id)
 		if err != nil && !isErrNetworkNotFound(err) {
@@ -512,6 +523,7 @@ func saveNetwork(net *Net,
 		IsExternal:  net.ExternalNet,
 		CreateTime:  net.CreateTime,
 		Description: net.Description,
+		Mtu:         net.Mtu,
 	}
 	err := SaveNetwork(network)
 	if err != nil {
@@ -963,6 +975,7 @@ func TransNetToNetwork(net *Net) (*Network, *Subnet) {
 		IsExternal:  net.ExternalNet,
 		CreateTime:  net.CreateTime,
 		Description: net.Description,
+		Mtu:         net.Mtu,
 	}
 
 	tmpSubnet := &Subnet{
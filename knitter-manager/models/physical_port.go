@@ -414,12 +414,14 @@ func DetachAndDelete(tranID TranID, vmID, portID, paasTenantID string) error {
 	errDetach := GetPortServiceObj().DetachPortFromVM(tranID, paasTenantID, &PortVMOpsReq{VMID: vmID, PortID: portID})
 	if errDetach != nil {
 		klog.Infof("@@PhyPortController:Detach port[id: %s] from vm[id: %s] failed, error: %v", portID, vmID, errDetach)
+		MarkExceptPortDestroying(paasTenantID, portID)
 		return errDetach
 	}
 	//delete
 	errDelete := GetPortServiceObj().DeletePort("", portID, paasTenantID)
 	if errDelete != nil {
 		klog.Infof("@@PhyPortController: Delete port[id: %s] failed, error: %v", portID, errDelete)
+		MarkExceptPortDestroying(paasTenantID, portID)
 		return errDelete
 	}
 
@@ -27,7 +27,6 @@ import (
 	"github.com/ZTE/Knitter/knitter-manager/err-obj"
 	"github.com/ZTE/Knitter/knitter-manager/iaas"
 	"github.com/ZTE/Knitter/knitter-manager/public"
-	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/ZTE/Knitter/pkg/iaas-accessor"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/agt-mgr"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-agt"
@@ -61,7 +60,8 @@ const (
 )
 
 type ExceptPort struct {
-	ID string `json:"id"`
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
 
 	PortType string `json:"port_type"`
 	HostID   string `json:"host_id"`
@@ -71,30 +71,36 @@ type ExceptPort struct {
 	ExceptStat   string `json:"except_stat"`
 
 	ExpectProcOps []string `json:"expect_proc_ops"`
+	// Destroying marks the port whose records are left by a failed destroy,
+	// they are orphans and deleted after the port is reaped
+	Destroying bool `json:"destroying,omitempty"`
+
+	RetryTimes    int    `json:"retry_times"`
+	NextRetryTime string `json:"next_retry_time"`
+	LastError     string `json:"last_error"`
 }
 
-func SaveExceptPort(portID, portType, hostID, exceptReason string) error {
-	klog.Infof("SaveExceptPort: start to save port[id: %s] to exceptional ports repo", portID)
-	port := ExceptPort{ID: portID, PortType: portType, ExceptReason: exceptReason}
+func newExceptPort(tenantID, portID, portType, hostID, exceptReason string) *ExceptPort {
+	port := &ExceptPort{ID: portID, TenantID: tenantID, PortType: portType, ExceptReason: exceptReason}
 	if portType == PortTypeAttatched {
 		port.HostID = hostID
 		port.ExpectProcOps = []string{PortDetachOp, PortDeleteOp}
 	} else {
 		port.ExpectProcOps = []string{PortDeleteOp}
 	}
-	port.ExceptTime = time.Now().UTC().Format("2006-01-02T15:04:05Z")
-
-	url := dbaccessor.GetKeyOfExceptionalPort(port.ID)
-	value, err := json.Marshal(port)
-	if err != nil {
-		klog.Errorf("SaveExceptPort: marshal exceptional port[%v] failed, error:%v", port, err)
-		return fmt.Errorf("%v:marshal exceptional port failed", err)
-	}
+	port.ExceptTime = time.Now().UTC().Format(ExceptPortTimeFormat)
+	port.ExceptStat = ExceptStatPending
+	port.NextRetryTime = port.ExceptTime
+	return port
+}
 
-	err = common.GetDataBase().SaveLeaf(url, string(value))
+func SaveExceptPort(tenantID, portID, portType, hostID, exceptReason string) error {
+	klog.Infof("SaveExceptPort: start to save port[id: %s] to exceptional ports repo", portID)
+	port := newExceptPort(tenantID, portID, portType, hostID, exceptReason)
+	err := saveExceptPortToDB(port)
 	if err != nil {
-		klog.Errorf("SaveExceptPort: save exceptional port[%v] to DB failed, error:%v", port, err)
-		return fmt.Errorf("%v:save exceptional port to DB failed", err)
+		klog.Errorf("SaveExceptPort: saveExceptPortToDB[%v] failed, error:%v", port, err)
+		return err
 	}
 
 	klog.Infof("SaveExceptPort: finish to save port[id: %s] to exceptional ports repo", portID)
@@ -158,7 +164,7 @@ var createBulkPortsFromIPGroup = func(req *mgriaas.MgrBulkPortsReq) ([]*iaasacce
 	}()
 	for _, port := range req.Ports {
 		ig := IPGroup{TenantID: port.TenantId, NetworkID: port.NetworkId, ID: port.IPGroupId, Name: port.IPGroupName}
-		newPort, err := ig.ObtainIP()
+		newPort, err := ig.ObtainIP(port.FixIP)
 		if err != nil {
 			klog.Errorf("CreateBulkPortsFromIPGroup ObtainIP failed. error: [%v]", err.Error())
 			return portsFromIPGroup, err
@@ -193,6 +199,7 @@ var createPortFromIPGroup = func(reqObj *CreatePortReq, networkID string) (*iaas
 	mgrPortReq.TenantId = reqObj.TenantID
 	mgrPortReq.NetworkId = networkID
 	mgrPortReq.IPGroupName = igInModel.Name
+	mgrPortReq.FixIP = reqObj.FixIP
 	mgrBulkPortsReqFromIPGroup.Ports = append(mgrBulkPortsReqFromIPGroup.Ports, mgrPortReq)
 	intersFromIPGroup, err := createBulkPortsFromIPGroup(mgrBulkPortsReqFromIPGroup)
 	if err != nil {
@@ -205,6 +212,35 @@ var createPortFromIPGroup = func(reqObj *CreatePortReq, networkID string) (*iaas
 	return intersFromIPGroup[0], nil
 }
 
+// createPortWithSecurity creates the port by a bulk request of one port, because
+// only the bulk ports request to IaaS carries the port security attributes.
+var createPortWithSecurity = func(iaasObj iaasaccessor.IaaS, reqObj *CreatePortReq,
+	networkID, subnetID, portName string) (*iaasaccessor.Interface, error) {
+	mgrPortReq := &mgriaas.MgrPortReq{
+		AgtPortReq: reqObj.AgtPortReq,
+		TenantId:   reqObj.TenantID,
+		NetworkId:  networkID,
+		SubnetId:   subnetID,
+	}
+	mgrPortReq.PortName = portName
+	mgrPortReq.FixIP = ""
+	bulkReq := &mgriaas.MgrBulkPortsReq{Ports: []*mgriaas.MgrPortReq{mgrPortReq}}
+	ports, err := iaasObj.CreateBulkPorts(bulkReq)
+	if err != nil {
+		klog.Errorf("createPortWithSecurity: CreateBulkPorts[%v] failed, error: %v", reqObj.PortSecurity, err)
+		return nil, fmt.Errorf("%v:CreatePort error", err)
+	}
+	if len(ports) != 1 {
+		klog.Errorf("createPortWithSecurity: CreateBulkPorts return %d ports, expect 1", len(ports))
+		for _, port := range ports {
+			iaasObj.DeletePort(port.Id)
+		}
+		return nil, errobj.ErrOpenstackCreateBulkPortsFailed
+	}
+	klog.Infof("createPortWithSecurity: CreatePort OK, port detail is :%v", ports[0])
+	return ports[0], nil
+}
+
 func (self *PortOps) CreatePort(tranID TranID, iaasObj iaasaccessor.IaaS, reqObj *CreatePortReq) (*iaasaccessor.Interface, error) {
 	paasNetworkInfo, err := GetNetworkByName(reqObj.TenantID, reqObj.NetworkName)
 	if err != nil {
@@ -225,6 +261,10 @@ func (self *PortOps) CreatePort(tranID TranID, iaasObj iaasaccessor.IaaS, reqObj
 		return createPortFromIPGroup(reqObj, networkID)
 	}
 
+	if reqObj.PortSecurity.IsSet() {
+		return createPortWithSecurity(iaasObj, reqObj, networkID, subnetID, portName)
+	}
+
 	port, err := iaasObj.CreatePort(networkID, subnetID, portName, "", "", reqObj.VnicType)
 	if err != nil {
 		klog.Errorf(" CreatePort failed, error: %v", err)
@@ -427,7 +467,7 @@ func (self *PortService) CreatePort(tranID TranID, reqObj *CreatePortReq) (*Port
 			delErr := portOpsObj.DeletePort(iaas.GetIaaS(reqObj.TenantID), tranID, portInfo.Id)
 			if delErr != nil {
 				klog.Errorf("CreatePort: delete port[id: %s] failed, error: %v", portInfo.Id, err)
-				SaveExceptPort(portInfo.Id, PortTypeNonattatched, "", "delete port failed")
+				SaveExceptPort(reqObj.TenantID, portInfo.Id, PortTypeNonattatched, "", "delete port failed")
 			}
 		}
 	}()
@@ -452,7 +492,7 @@ var AssembleResponse = func(iaasObj iaasaccessor.IaaS, port *iaasaccessor.Interf
 			klog.Errorf(" assembleResponse: recyle port after attach->getport->failed failed, error: %v", delPortErr)
 			klog.Errorf(" assembleResponse: EXCEPT-MARK->PORT[id:%s]", port.Id)
 			// save exceptional port
-			SaveExceptPort(port.Id, PortTypeNonattatched, "", "create port failed, then delete it failed")
+			SaveExceptPort("", port.Id, PortTypeNonattatched, "", "create port failed, then delete it failed")
 		}
 		return nil, errors.New("AssembleResponse: GetSubnet error")
 	}
@@ -464,7 +504,7 @@ var AssembleResponse = func(iaasObj iaasaccessor.IaaS, port *iaasaccessor.Interf
 			klog.Errorf(" assembleResponse: recyle port after attach->getport->failed failed, error: %v", delPortErr)
 			klog.Errorf(" assembleResponse: EXCEPT-MARK->PORT[id:%s]", port.Id)
 			// save exceptional port
-			SaveExceptPort(port.Id, PortTypeNonattatched, "", "create port failed, then delete it failed")
+			SaveExceptPort("", port.Id, PortTypeNonattatched, "", "create port failed, then delete it failed")
 		}
 		return nil, errors.New("AssembleResponse: makeResponse error")
 	}
@@ -514,7 +554,7 @@ func (self *PortService) DeletePort(tranID TranID, portID, tenantID string) erro
 			klog.Errorf(" DeletePort: delete port[id: %s] failed, error: %v, retry %d times timeout, exit",
 				portID, err, OpenStackOpsRetryTime)
 			// save exceptional port
-			SaveExceptPort(portID, PortTypeNonattatched, "", "delete port failed")
+			SaveExceptPort(tenantID, portID, PortTypeNonattatched, "", "delete port failed")
 			return err
 		}
 		time.Sleep(OpenStackOpsRetryIntval * time.Second)
@@ -534,7 +574,7 @@ func (self *PortService) AttachPortToVM(tranID TranID, tenantID string, reqObj *
 		// need later process the port according to the error code
 		klog.Errorf(" AttachPortToVM: attach port[id: %s] to vm[id: %s] error: %v, retry %d times timeout, just exit",
 			reqObj.PortID, reqObj.VMID, err, OpenStackOpsRetryTime)
-		SaveExceptPort(reqObj.PortID, PortTypeAttatched, reqObj.VMID, "attach port to vm failed")
+		SaveExceptPort(tenantID, reqObj.PortID, PortTypeAttatched, reqObj.VMID, "attach port to vm failed")
 		return fmt.Errorf("%v:attach port to vm error", err)
 	}
 	klog.Infof(" AttachPortToVM: attach port[id: %s] "+
@@ -553,7 +593,7 @@ func (self *PortService) AttachPortToVM(tranID TranID, tenantID string, reqObj *
 		if detachErr != nil {
 			klog.Errorf(" AttachPortToVM: Detach port[id: %s] failed, error: %v", reqObj.PortID, err)
 			// save exceptional port
-			SaveExceptPort(reqObj.PortID, PortTypeAttatched, reqObj.VMID,
+			SaveExceptPort(tenantID, reqObj.PortID, PortTypeAttatched, reqObj.VMID,
 				"attach port to vm but wait ACTIVE status timeout")
 		}
 		return fmt.Errorf("%v:checkNicStatus error", err)
@@ -572,7 +612,7 @@ func (self *PortService) DetachPortFromVM(tranID TranID, tenantID string, reqObj
 			klog.Errorf(" DetachPortFromVM: detach port[%s] from vm[%s] error: %v, retry %d times timeout, just exit",
 				reqObj.PortID, reqObj.VMID, err, OpenStackOpsRetryTime)
 			// save exceptional port
-			SaveExceptPort(reqObj.PortID, PortTypeAttatched, reqObj.VMID, "detach port from vm failed")
+			SaveExceptPort(tenantID, reqObj.PortID, PortTypeAttatched, reqObj.VMID, "detach port from vm failed")
 			return errors.New("detach port from vm error")
 		}
 
@@ -593,7 +633,7 @@ func (self *PortService) DetachPortFromVM(tranID TranID, tenantID string, reqObj
 	if err != nil {
 		klog.Errorf("cmdDel: DeletePort port[id: %s] failed, error: %v", reqObj.PortID, err)
 		// save exceptional port
-		SaveExceptPort(reqObj.PortID, PortTypeNonattatched, reqObj.VMID,
+		SaveExceptPort(tenantID, reqObj.PortID, PortTypeNonattatched, reqObj.VMID,
 			"detach port from vm but wait DOWN status timeout")
 		return fmt.Errorf("%v:checkNicStatus error", err)
 	}
@@ -711,12 +751,15 @@ func CreateBulkPorts(req *mgriaas.MgrBulkPortsReq) (*mgragt.CreatePortsResp, err
 		return nil, err
 	}
 
-	// create iaas ports
-	intersNotFixIP, err = createNormalBulkPorts(mgrBulkPortsReqNotFixIP)
+	// claim pooled ports first, then create iaas ports for the left
+	intersNotFixIP, mgrBulkPortsReqNotFixIP = ClaimPooledPorts(mgrBulkPortsReqNotFixIP)
+	var intersCreated []*iaasaccessor.Interface
+	intersCreated, err = createNormalBulkPorts(mgrBulkPortsReqNotFixIP)
 	if err != nil {
 		klog.Errorf("CreateBulkPorts: createNormalBulkPorts FAIL, error: %v", err)
 		return nil, err
 	}
+	intersNotFixIP = append(intersNotFixIP, intersCreated...)
 
 	intersAll = append(intersAll, intersFromIPGroup...)
 	intersAll = append(intersAll, intersNotFixIP...)
@@ -789,6 +832,7 @@ func DestroyLogicalPort(portID string) error {
 		err = destroyNormalLogicalPort(portObj.ID, portObj.TenantID)
 		if err != nil {
 			klog.Errorf("DestroyLogicalPort: destroyNormalLogicalPort(portID: %s) error: [%v]", portID, err)
+			MarkExceptPortDestroying(portObj.TenantID, portID)
 			return err
 		}
 	}
@@ -1241,18 +1285,12 @@ var createIaasBulkPorts = func(req *mgriaas.MgrBulkPortsReq) ([]*iaasaccessor.In
 		return nil, nil
 	}
 
-	var err error = nil
-	var ports []*iaasaccessor.Interface
-	for idx := 0; idx < OpenStackOpsRetryTime; idx++ {
-		ports, err = iaas.GetIaaS(req.Ports[0].TenantID).CreateBulkPorts(req)
-		klog.Errorf("%d times, error: %v", idx, err)
-		if err == nil {
-			return ports, nil
-		}
+	ports, err := CreateIaasBulkPortsInChunks(iaas.GetIaaS(req.Ports[0].TenantID), req)
+	if err != nil {
+		klog.Errorf("createIaasBulkPorts: iaas create bulk ports FAIL, error: %v", err)
+		return nil, err
 	}
-
-	klog.Errorf("createIaasBulkPorts: iaas create bulk ports FAIL, error: %v", err)
-	return nil, err
+	return ports, nil
 }
 
 func LoadAllPortObjs() error {
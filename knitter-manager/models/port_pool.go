@@ -0,0 +1,473 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/knitter-manager/iaas"
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const (
+	MaxPortPoolSize           = 100
+	DefaultPortPoolRefillRate = 5
+
+	pooledPortName = "knitter-pooled"
+)
+
+var (
+	PortPoolRefillIntervalInSec = 10
+
+	portPoolConfs    = make(map[string]*PortPoolConf)
+	drainingNetworks = make(map[string]bool)
+	unpooledNetworks = make(map[string]bool)
+	portPoolLock     sync.Mutex
+)
+
+// PortPoolConf is the warm pool of unbound ports of a network. When the pool
+// has less than MinSize ports, it is refilled up to MaxSize with at most
+// RefillRate ports each round.
+type PortPoolConf struct {
+	MinSize    int `json:"min_size"`
+	MaxSize    int `json:"max_size"`
+	RefillRate int `json:"refill_rate"`
+}
+
+// PooledPort is an unbound port created in IaaS ahead of pods. It is not a
+// logical port until claimed, so it is not counted in the usage of network.
+type PooledPort struct {
+	ID           string `json:"id"`
+	TenantID     string `json:"tenant_id"`
+	IaasTenantID string `json:"iaas_tenant_id"`
+	NetworkID    string `json:"network_id"`
+	SubnetID     string `json:"subnet_id"`
+	IP           string `json:"ip"`
+	MacAddress   string `json:"mac_address"`
+	CreateTime   string `json:"create_time"`
+}
+
+type PortPoolStatus struct {
+	NetworkID   string `json:"network_id"`
+	NetworkName string `json:"network_name"`
+	TenantID    string `json:"tenant_id"`
+	Size        int    `json:"size"`
+	PortPoolConf
+}
+
+// SetPortPoolConfs reads the pools of networks from "port_pool", which maps
+// network names to their pools, e.g.
+// "port_pool": {"net_api": {"min_size": "4", "max_size": "16", "refill_rate": "4"}}
+func SetPortPoolConfs(cfg *jason.Object) {
+	confs := make(map[string]*PortPoolConf)
+	poolsObj, err := cfg.GetObject("port_pool")
+	if err == nil {
+		for networkName, value := range poolsObj.Map() {
+			confObj, err := value.Object()
+			if err != nil {
+				klog.Warningf("SetPortPoolConfs: port pool of network[%s] is not an object, skip it", networkName)
+				continue
+			}
+			minSize, _ := confObj.GetString("min_size")
+			maxSize, _ := confObj.GetString("max_size")
+			refillRate, _ := confObj.GetString("refill_rate")
+			conf, ok := ConvertPortPoolConf(minSize, maxSize, refillRate)
+			if !ok {
+				klog.Warningf("SetPortPoolConfs: invalid port pool[min_size: %s, max_size: %s, refill_rate: %s] "+
+					"of network[%s], skip it", minSize, maxSize, refillRate, networkName)
+				continue
+			}
+			confs[networkName] = conf
+		}
+	}
+
+	portPoolLock.Lock()
+	portPoolConfs = confs
+	portPoolLock.Unlock()
+	klog.Infof("PortPoolConfs:%v", confs)
+}
+
+func ConvertPortPoolConf(minSize, maxSize, refillRate string) (*PortPoolConf, bool) {
+	conf := &PortPoolConf{RefillRate: DefaultPortPoolRefillRate}
+	var err error
+	conf.MinSize, err = strconv.Atoi(minSize)
+	if err != nil {
+		return nil, false
+	}
+	conf.MaxSize, err = strconv.Atoi(maxSize)
+	if err != nil {
+		return nil, false
+	}
+	if refillRate != "" {
+		conf.RefillRate, err = strconv.Atoi(refillRate)
+		if err != nil {
+			return nil, false
+		}
+	}
+	if conf.MinSize <= 0 || conf.MinSize > conf.MaxSize || conf.MaxSize > MaxPortPoolSize ||
+		conf.RefillRate <= 0 {
+		return nil, false
+	}
+	return conf, true
+}
+
+func GetPortPoolConf(networkName string) *PortPoolConf {
+	portPoolLock.Lock()
+	defer portPoolLock.Unlock()
+	return portPoolConfs[networkName]
+}
+
+// getPortPoolConfOfTenant returns the pool of the network, no port is pooled
+// if the IaaS of the tenant can not rename the ports when they are claimed,
+// as VNFM names the cps when they are created.
+func getPortPoolConfOfTenant(networkName, tenantID string) *PortPoolConf {
+	conf := GetPortPoolConf(networkName)
+	if conf == nil {
+		return nil
+	}
+	iaasObj := iaas.GetIaaS(tenantID)
+	if iaasObj == nil || iaasObj.GetType() != "VNFM" {
+		return conf
+	}
+	portPoolLock.Lock()
+	defer portPoolLock.Unlock()
+	if !unpooledNetworks[networkName] {
+		klog.Warningf("getPortPoolConfOfTenant: VNFM can not rename ports, port pool of network[%s] is ignored",
+			networkName)
+		unpooledNetworks[networkName] = true
+	}
+	return nil
+}
+
+func savePooledPort(port *PooledPort) error {
+	value, err := json.Marshal(port)
+	if err != nil {
+		klog.Errorf("savePooledPort: json.Marshal(%v) FAILED, error: %v", port, err)
+		return fmt.Errorf("%v:marshal pooled port failed", err)
+	}
+	err = common.GetDataBase().SaveLeaf(dbaccessor.GetKeyOfPooledPort(port.NetworkID, port.ID), string(value))
+	if err != nil {
+		klog.Errorf("savePooledPort: SaveLeaf port[%v] FAILED, error: %v", port, err)
+		return fmt.Errorf("%v:save pooled port failed", err)
+	}
+	return nil
+}
+
+func deletePooledPort(port *PooledPort) error {
+	return common.GetDataBase().DeleteLeaf(dbaccessor.GetKeyOfPooledPort(port.NetworkID, port.ID))
+}
+
+func getPooledPorts(networkID string) ([]*PooledPort, error) {
+	nodes, err := common.GetDataBase().ReadDir(dbaccessor.GetKeyOfPooledPortsOfNetwork(networkID))
+	if err != nil {
+		if IsKeyNotFoundError(err) {
+			return []*PooledPort{}, nil
+		}
+		klog.Errorf("getPooledPorts: ReadDir of network[id: %s] FAILED, error: %v", networkID, err)
+		return nil, err
+	}
+
+	ports := make([]*PooledPort, 0, len(nodes))
+	for _, node := range nodes {
+		var port PooledPort
+		err := json.Unmarshal([]byte(node.Value), &port)
+		if err != nil {
+			klog.Warningf("getPooledPorts: json.Unmarshal(%s) FAILED, error: %v, skip it", node.Value, err)
+			continue
+		}
+		ports = append(ports, &port)
+	}
+	return ports, nil
+}
+
+func getPooledNetworkIDs() ([]string, error) {
+	key := dbaccessor.GetKeyOfPooledPorts()
+	nodes, err := common.GetDataBase().ReadDir(key)
+	if err != nil {
+		if IsKeyNotFoundError(err) {
+			return []string{}, nil
+		}
+		klog.Errorf("getPooledNetworkIDs: ReadDir FAILED, error: %v", err)
+		return nil, err
+	}
+
+	networkIDs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		networkIDs = append(networkIDs, strings.TrimPrefix(node.Key, key+"/"))
+	}
+	return networkIDs, nil
+}
+
+var getIaasTenantID = func(paasTenantID string) string {
+	iaasTenantID, _ := iaas.GetIaasTenantIDByPaasTenantID(paasTenantID)
+	return iaasTenantID
+}
+
+// releasePooledPort removes the port from pool before deleting it from IaaS,
+// so that it can not be claimed in the meantime.
+func releasePooledPort(port *PooledPort) {
+	err := deletePooledPort(port)
+	if err != nil {
+		klog.Warningf("releasePooledPort: deletePooledPort[%v] error: %v", port, err)
+		return
+	}
+	iaasObj := iaas.GetIaaS(port.TenantID)
+	if iaasObj == nil {
+		SaveExceptPort(port.TenantID, port.ID, PortTypeNonattatched, "", "release pooled port, but iaas not found")
+		return
+	}
+	rollbackIaasPorts(iaasObj, port.TenantID, []*iaasaccessor.Interface{{Id: port.ID}})
+}
+
+// StartPortPoolRefiller keeps the port pools of networks between their
+// watermarks periodically.
+func StartPortPoolRefiller() {
+	go func() {
+		for {
+			time.Sleep(time.Duration(PortPoolRefillIntervalInSec) * time.Second)
+			RefillPortPools()
+		}
+	}()
+}
+
+// RefillPortPools refills the pools of the configured networks, and drains the
+// pools whose network is deleted or no longer configured.
+func RefillPortPools() {
+	for _, netObj := range GetNetObjRepoSingleton().List() {
+		conf := getPortPoolConfOfTenant(netObj.Name, netObj.TenantID)
+		if conf == nil {
+			continue
+		}
+		err := refillPortPool(netObj, conf)
+		if err != nil {
+			klog.Warningf("RefillPortPools: refill port pool of network[id: %s] error: %v", netObj.ID, err)
+		}
+	}
+
+	networkIDs, err := getPooledNetworkIDs()
+	if err != nil {
+		return
+	}
+	for _, networkID := range networkIDs {
+		netObj, err := GetNetObjRepoSingleton().Get(networkID)
+		if err == nil && getPortPoolConfOfTenant(netObj.Name, netObj.TenantID) != nil {
+			continue
+		}
+		klog.Infof("RefillPortPools: port pool of network[id: %s] is not configured, drain it", networkID)
+		drainPortPool(networkID)
+	}
+}
+
+func refillPortPool(netObj *NetworkObject, conf *PortPoolConf) error {
+	pooled, err := getPooledPorts(netObj.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(pooled) > conf.MaxSize {
+		for _, port := range pooled[conf.MaxSize:] {
+			releasePooledPort(port)
+		}
+		return nil
+	}
+	if len(pooled) >= conf.MinSize {
+		return nil
+	}
+
+	num := conf.MaxSize - len(pooled)
+	if num > conf.RefillRate {
+		num = conf.RefillRate
+	}
+	return createPooledPorts(netObj, num)
+}
+
+func createPooledPorts(netObj *NetworkObject, num int) error {
+	iaasObj := iaas.GetIaaS(netObj.TenantID)
+	if iaasObj == nil {
+		return fmt.Errorf("iaas of tenant[%s] not found", netObj.TenantID)
+	}
+	subnetID := netObj.SubnetID
+	if subnetID == "" {
+		var err error
+		subnetID, err = iaasObj.GetSubnetID(netObj.ID)
+		if err != nil {
+			return fmt.Errorf("%v:GetSubnetID error", err)
+		}
+	}
+
+	req := &mgriaas.MgrBulkPortsReq{}
+	for i := 0; i < num; i++ {
+		portReq := &mgriaas.MgrPortReq{TenantId: netObj.TenantID, NetworkId: netObj.ID, SubnetId: subnetID}
+		portReq.TenantID = netObj.TenantID
+		portReq.NetworkName = netObj.Name
+		portReq.PortName = pooledPortName
+		portReq.VnicType = "normal"
+		req.Ports = append(req.Ports, portReq)
+	}
+	ports, err := CreateIaasBulkPortsInChunks(iaasObj, req)
+	if err != nil {
+		return err
+	}
+
+	iaasTenantID := getIaasTenantID(netObj.TenantID)
+	createTime := time.Now().UTC().Format(ExceptPortTimeFormat)
+	portPoolLock.Lock()
+	defer portPoolLock.Unlock()
+	if drainingNetworks[netObj.ID] {
+		klog.Infof("createPooledPorts: network[id: %s] is draining, delete the ports created", netObj.ID)
+		rollbackIaasPorts(iaasObj, netObj.TenantID, ports)
+		return nil
+	}
+	for _, port := range ports {
+		pooledPort := &PooledPort{ID: port.Id, TenantID: netObj.TenantID, IaasTenantID: iaasTenantID,
+			NetworkID: netObj.ID, SubnetID: port.SubnetId, IP: port.Ip, MacAddress: port.MacAddress,
+			CreateTime: createTime}
+		err = savePooledPort(pooledPort)
+		if err != nil {
+			rollbackIaasPorts(iaasObj, netObj.TenantID, []*iaasaccessor.Interface{port})
+		}
+	}
+	klog.Infof("createPooledPorts: add %d ports to port pool of network[id: %s]", len(ports), netObj.ID)
+	return nil
+}
+
+// DrainPortPool deletes the pooled ports of the network and stops refilling
+// its pool until ResumePortPool, it is called before deleting the network.
+func DrainPortPool(networkID string) error {
+	portPoolLock.Lock()
+	drainingNetworks[networkID] = true
+	portPoolLock.Unlock()
+	return drainPortPool(networkID)
+}
+
+func ResumePortPool(networkID string) {
+	portPoolLock.Lock()
+	delete(drainingNetworks, networkID)
+	portPoolLock.Unlock()
+}
+
+func drainPortPool(networkID string) error {
+	pooled, err := getPooledPorts(networkID)
+	if err != nil {
+		return err
+	}
+	for _, port := range pooled {
+		releasePooledPort(port)
+	}
+	err = common.GetDataBase().DeleteDir(dbaccessor.GetKeyOfPooledPortsOfNetwork(networkID))
+	if err != nil && !IsKeyNotFoundError(err) {
+		klog.Warningf("drainPortPool: DeleteDir of network[id: %s] error: %v", networkID, err)
+	}
+	klog.Infof("drainPortPool: release %d pooled ports of network[id: %s]", len(pooled), networkID)
+	return nil
+}
+
+func isPortReqPoolable(req *mgriaas.MgrPortReq) bool {
+	return req.IPGroupId == "" && req.FixIP == "" && req.FixMac == "" && !req.PortSecurity.IsSet() &&
+		(req.VnicType == "" || req.VnicType == "normal")
+}
+
+// takePooledPort removes a pooled port of the subnet created by the same IaaS
+// tenant from pool, a port deleted by others in the meantime is skipped.
+func takePooledPort(networkID, subnetID, iaasTenantID string) *PooledPort {
+	portPoolLock.Lock()
+	defer portPoolLock.Unlock()
+	pooled, err := getPooledPorts(networkID)
+	if err != nil {
+		return nil
+	}
+	for _, port := range pooled {
+		if port.SubnetID != subnetID || port.IaasTenantID != iaasTenantID {
+			continue
+		}
+		err = deletePooledPort(port)
+		if err != nil {
+			klog.Warningf("takePooledPort: deletePooledPort[%v] error: %v, skip it", port, err)
+			continue
+		}
+		return port
+	}
+	return nil
+}
+
+func claimPooledPort(req *mgriaas.MgrPortReq) *iaasaccessor.Interface {
+	if !isPortReqPoolable(req) || getPortPoolConfOfTenant(req.NetworkName, req.TenantID) == nil {
+		return nil
+	}
+	iaasObj := iaas.GetIaaS(req.TenantID)
+	if iaasObj == nil {
+		return nil
+	}
+
+	port := takePooledPort(req.NetworkId, req.SubnetId, getIaasTenantID(req.TenantID))
+	if port == nil {
+		klog.Infof("claimPooledPort: port pool of network[id: %s] is empty", req.NetworkId)
+		return nil
+	}
+	err := iaasObj.UpdatePortName(port.ID, req.PortName)
+	if err != nil {
+		klog.Warningf("claimPooledPort: UpdatePortName(id: %s, name: %s) error: %v, delete it",
+			port.ID, req.PortName, err)
+		rollbackIaasPorts(iaasObj, req.TenantID, []*iaasaccessor.Interface{{Id: port.ID}})
+		return nil
+	}
+
+	klog.Infof("claimPooledPort: claim pooled port[id: %s] as %s", port.ID, req.PortName)
+	return &iaasaccessor.Interface{Id: port.ID, Name: req.PortName, Status: portStatusDown,
+		Ip: port.IP, MacAddress: port.MacAddress, NetworkId: port.NetworkID, SubnetId: port.SubnetID}
+}
+
+// ClaimPooledPorts claims pooled ports for the ports of req without fixed ip,
+// mac or port security, and returns them with the request of the ports left.
+var ClaimPooledPorts = func(req *mgriaas.MgrBulkPortsReq) ([]*iaasaccessor.Interface, *mgriaas.MgrBulkPortsReq) {
+	claimed := make([]*iaasaccessor.Interface, 0)
+	left := &mgriaas.MgrBulkPortsReq{TranId: req.TranId, Ports: []*mgriaas.MgrPortReq{}}
+	for _, portReq := range req.Ports {
+		port := claimPooledPort(portReq)
+		if port == nil {
+			left.Ports = append(left.Ports, portReq)
+			continue
+		}
+		claimed = append(claimed, port)
+	}
+	return claimed, left
+}
+
+func GetPortPoolsStatus() ([]*PortPoolStatus, error) {
+	pools := make([]*PortPoolStatus, 0)
+	for _, netObj := range GetNetObjRepoSingleton().List() {
+		conf := getPortPoolConfOfTenant(netObj.Name, netObj.TenantID)
+		if conf == nil {
+			continue
+		}
+		pooled, err := getPooledPorts(netObj.ID)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, &PortPoolStatus{NetworkID: netObj.ID, NetworkName: netObj.Name,
+			TenantID: netObj.TenantID, Size: len(pooled), PortPoolConf: *conf})
+	}
+	return pools, nil
+}
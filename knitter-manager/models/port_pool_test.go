@@ -0,0 +1,231 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/coreos/etcd/client"
+	"github.com/golang/gostub"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-manager/iaas"
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/knitter-manager/tests"
+	"github.com/ZTE/Knitter/knitter-manager/tests/mock/db-mock"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+)
+
+func makeTestPooledPortNode(id, subnetID string) *client.Node {
+	port := PooledPort{ID: id, TenantID: "tenant-id", IaasTenantID: "iaas-tenant-id",
+		NetworkID: "network-id", SubnetID: subnetID, IP: "10.0.0.2", MacAddress: "fa:16:3e:00:00:02"}
+	value, _ := json.Marshal(port)
+	return &client.Node{Key: dbaccessor.GetKeyOfPooledPort("network-id", id), Value: string(value)}
+}
+
+func TestConvertPortPoolConf(t *testing.T) {
+	Convey("TestConvertPortPoolConf", t, func() {
+		conf, ok := ConvertPortPoolConf("4", "16", "")
+		So(ok, ShouldBeTrue)
+		So(*conf, ShouldResemble, PortPoolConf{MinSize: 4, MaxSize: 16, RefillRate: DefaultPortPoolRefillRate})
+		conf, ok = ConvertPortPoolConf("4", "16", "8")
+		So(ok, ShouldBeTrue)
+		So(conf.RefillRate, ShouldEqual, 8)
+		for _, invalid := range [][]string{{"", "16", ""}, {"0", "16", ""}, {"17", "16", ""},
+			{"4", "101", ""}, {"4", "16", "0"}, {"4", "abc", ""}} {
+			_, ok = ConvertPortPoolConf(invalid[0], invalid[1], invalid[2])
+			So(ok, ShouldBeFalse)
+		}
+	})
+}
+
+func TestSetPortPoolConfs(t *testing.T) {
+	Convey("TestSetPortPoolConfs", t, func() {
+		cfg, _ := jason.NewObjectFromBytes([]byte(`{"port_pool": {
+			"net_api": {"min_size": "4", "max_size": "16", "refill_rate": "4"},
+			"net_media": {"min_size": "8", "max_size": "4"}}}`))
+		SetPortPoolConfs(cfg)
+		defer SetPortPoolConfs(&jason.Object{})
+		So(GetPortPoolConf("net_api"), ShouldResemble, &PortPoolConf{MinSize: 4, MaxSize: 16, RefillRate: 4})
+		So(GetPortPoolConf("net_media"), ShouldBeNil)
+	})
+}
+
+func TestClaimPooledPorts(t *testing.T) {
+	Convey("TestClaimPooledPorts", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		mockIaas := test.NewMockIaaS(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		stubs.StubFunc(&iaas.GetIaaS, mockIaas)
+		stubs.StubFunc(&getIaasTenantID, "iaas-tenant-id")
+		stubs.Stub(&portPoolConfs, map[string]*PortPoolConf{"net_api": {MinSize: 1, MaxSize: 2, RefillRate: 1}})
+		stubs.Stub(&unpooledNetworks, map[string]bool{})
+		iaasType := "TECS"
+		mockIaas.EXPECT().GetType().DoAndReturn(func() string { return iaasType }).AnyTimes()
+
+		req := makeTestBulkPortsReq(2)
+		for _, port := range req.Ports {
+			port.NetworkName = "net_api"
+		}
+		pooledKey := dbaccessor.GetKeyOfPooledPortsOfNetwork("network-id")
+		nodes := []*client.Node{makeTestPooledPortNode("pooled-0", "other-subnet-id"),
+			makeTestPooledPortNode("pooled-1", "subnet-id")}
+
+		Convey("claim and rename pooled port\n", func() {
+			req.Ports[1].FixIP = "10.0.0.9"
+			gomock.InOrder(
+				mockDB.EXPECT().ReadDir(pooledKey).Return(nodes, nil),
+				mockDB.EXPECT().DeleteLeaf(dbaccessor.GetKeyOfPooledPort("network-id", "pooled-1")).Return(nil),
+				mockIaas.EXPECT().UpdatePortName("pooled-1", "eth0").Return(nil),
+			)
+			claimed, left := ClaimPooledPorts(req)
+			So(len(claimed), ShouldEqual, 1)
+			So(*claimed[0], ShouldResemble, iaasaccessor.Interface{Id: "pooled-1", Name: "eth0", Status: portStatusDown,
+				Ip: "10.0.0.2", MacAddress: "fa:16:3e:00:00:02", NetworkId: "network-id", SubnetId: "subnet-id"})
+			So(left.Ports, ShouldResemble, []*mgriaas.MgrPortReq{req.Ports[1]})
+		})
+
+		Convey("pooled port claimed by others is skipped\n", func() {
+			req.Ports = req.Ports[:1]
+			gomock.InOrder(
+				mockDB.EXPECT().ReadDir(pooledKey).Return(nodes, nil),
+				mockDB.EXPECT().DeleteLeaf(dbaccessor.GetKeyOfPooledPort("network-id", "pooled-1")).
+					Return(errors.New("100: Key not found")),
+			)
+			claimed, left := ClaimPooledPorts(req)
+			So(len(claimed), ShouldEqual, 0)
+			So(len(left.Ports), ShouldEqual, 1)
+		})
+
+		Convey("pooled port failed to rename is deleted\n", func() {
+			req.Ports = req.Ports[:1]
+			gomock.InOrder(
+				mockDB.EXPECT().ReadDir(pooledKey).Return(nodes, nil),
+				mockDB.EXPECT().DeleteLeaf(dbaccessor.GetKeyOfPooledPort("network-id", "pooled-1")).Return(nil),
+				mockIaas.EXPECT().UpdatePortName("pooled-1", "eth0").Return(errors.New("500")),
+				mockIaas.EXPECT().DeletePort("pooled-1").Return(nil),
+			)
+			claimed, left := ClaimPooledPorts(req)
+			So(len(claimed), ShouldEqual, 0)
+			So(len(left.Ports), ShouldEqual, 1)
+		})
+
+		Convey("no port is pooled by VNFM\n", func() {
+			iaasType = "VNFM"
+			claimed, left := ClaimPooledPorts(req)
+			So(len(claimed), ShouldEqual, 0)
+			So(len(left.Ports), ShouldEqual, 2)
+			So(unpooledNetworks["net_api"], ShouldBeTrue)
+		})
+
+		Convey("network without port pool\n", func() {
+			req.Ports[0].NetworkName = "net_media"
+			req.Ports[1].NetworkName = "net_media"
+			claimed, left := ClaimPooledPorts(req)
+			So(len(claimed), ShouldEqual, 0)
+			So(len(left.Ports), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestRefillPortPool(t *testing.T) {
+	Convey("TestRefillPortPool", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		mockIaas := test.NewMockIaaS(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		stubs.StubFunc(&iaas.GetIaaS, mockIaas)
+		stubs.StubFunc(&getIaasTenantID, "iaas-tenant-id")
+
+		netObj := &NetworkObject{ID: "network-id", Name: "net_api", SubnetID: "subnet-id", TenantID: "tenant-id"}
+		conf := &PortPoolConf{MinSize: 2, MaxSize: 4, RefillRate: 3}
+		pooledKey := dbaccessor.GetKeyOfPooledPortsOfNetwork("network-id")
+		var created *mgriaas.MgrBulkPortsReq
+		stubs.Stub(&CreateIaasBulkPortsInChunks, func(_ iaasaccessor.IaaS, req *mgriaas.MgrBulkPortsReq) (
+			[]*iaasaccessor.Interface, error) {
+			created = req
+			return makeTestIaasPorts(req, "new-"), nil
+		})
+
+		Convey("refill at most refill rate ports below min size\n", func() {
+			mockDB.EXPECT().ReadDir(pooledKey).Return([]*client.Node{makeTestPooledPortNode("pooled-0", "subnet-id")}, nil)
+			mockDB.EXPECT().SaveLeaf(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+			So(refillPortPool(netObj, conf), ShouldBeNil)
+			So(len(created.Ports), ShouldEqual, 3)
+			So(created.Ports[0].PortName, ShouldEqual, pooledPortName)
+			So(created.Ports[0].SubnetId, ShouldEqual, "subnet-id")
+		})
+
+		Convey("no refill above min size\n", func() {
+			mockDB.EXPECT().ReadDir(pooledKey).Return([]*client.Node{makeTestPooledPortNode("pooled-0", "subnet-id"),
+				makeTestPooledPortNode("pooled-1", "subnet-id")}, nil)
+			So(refillPortPool(netObj, conf), ShouldBeNil)
+			So(created, ShouldBeNil)
+		})
+
+		Convey("ports created for draining network are deleted\n", func() {
+			stubs.Stub(&drainingNetworks, map[string]bool{"network-id": true})
+			mockDB.EXPECT().ReadDir(pooledKey).Return(nil, errors.New("100: Key not found"))
+			mockIaas.EXPECT().DeletePort(gomock.Any()).Return(nil).Times(3)
+			So(refillPortPool(netObj, conf), ShouldBeNil)
+		})
+
+		Convey("extra ports above max size are released\n", func() {
+			conf.MaxSize = 1
+			gomock.InOrder(
+				mockDB.EXPECT().ReadDir(pooledKey).Return([]*client.Node{makeTestPooledPortNode("pooled-0", "subnet-id"),
+					makeTestPooledPortNode("pooled-1", "subnet-id")}, nil),
+				mockDB.EXPECT().DeleteLeaf(dbaccessor.GetKeyOfPooledPort("network-id", "pooled-1")).Return(nil),
+				mockIaas.EXPECT().DeletePort("pooled-1").Return(nil),
+			)
+			So(refillPortPool(netObj, conf), ShouldBeNil)
+			So(created, ShouldBeNil)
+		})
+	})
+}
+
+func TestDrainPortPool(t *testing.T) {
+	Convey("TestDrainPortPool", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		mockIaas := test.NewMockIaaS(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		stubs.StubFunc(&iaas.GetIaaS, mockIaas)
+
+		pooledKey := dbaccessor.GetKeyOfPooledPortsOfNetwork("network-id")
+		gomock.InOrder(
+			mockDB.EXPECT().ReadDir(pooledKey).Return([]*client.Node{makeTestPooledPortNode("pooled-0", "subnet-id")}, nil),
+			mockDB.EXPECT().DeleteLeaf(dbaccessor.GetKeyOfPooledPort("network-id", "pooled-0")).Return(nil),
+			mockIaas.EXPECT().DeletePort("pooled-0").Return(nil),
+			mockDB.EXPECT().DeleteDir(pooledKey).Return(nil),
+		)
+		So(DrainPortPool("network-id"), ShouldBeNil)
+		So(drainingNetworks["network-id"], ShouldBeTrue)
+		ResumePortPool("network-id")
+		So(drainingNetworks["network-id"], ShouldBeFalse)
+	})
+}
@@ -349,6 +349,7 @@ func TestCreateBulkPortsOKWithRetry(t *testing.T) {
 			mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(nil, errors.New("connection timeout")),
 			mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(inters, nil),
 		)
+		mockIaas.EXPECT().ListPorts(gomock.Any()).Return(nil, nil).Times(2)
 
 		nics, err := GetPortServiceObj().CreateBulkPorts(&mgrBulkPortsReq)
 		So(err, ShouldEqual, nil)
@@ -421,6 +422,7 @@ func TestCreateBulkPortsError(t *testing.T) {
 
 		errStr := "connection timeout"
 		mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).Return(nil, errors.New(errStr)).Times(3)
+		mockIaas.EXPECT().ListPorts(gomock.Any()).Return(nil, nil).Times(6)
 
 		nics, err := GetPortServiceObj().CreateBulkPorts(&mgrBulkPortsReq)
 		So(err.Error(), ShouldEqual, errStr)
@@ -504,6 +506,53 @@ func TestCreatePortOK0(t *testing.T) {
 	})
 }
 
+func TestCreatePortWithPortSecurity(t *testing.T) {
+	Convey("TestCreatePortWithPortSecurity", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockIaas := test.NewMockIaaS(mockCtl)
+
+		monkey.Patch(GetNetworkByName, func(tid, netName string) (*PaasNetwork, error) {
+			return &PaasNetwork{ID: "network-id", SubnetID: "subnet-id"}, nil
+		})
+		defer monkey.UnpatchAll()
+		monkey.Patch(iaas.GetIaasTenantIDByPaasTenantID, func(paasTenantID string) (string, error) {
+			return "iaasTenantID", nil
+		})
+
+		enabled := true
+		req := &CreatePortReq{
+			AgtPortReq: agtmgr.AgtPortReq{
+				TenantID:    "paas-tenant",
+				NetworkName: "network-name",
+				PortName:    "port-name",
+				VnicType:    "normal",
+				FixIP:       "10.0.0.2",
+				PortSecurity: agtmgr.PortSecurity{
+					SecurityGroups:      []string{"sg0"},
+					PortSecurityEnabled: &enabled,
+				}}}
+		iaasPort := &iaasaccessor.Interface{Id: "port-id", Name: "port-name"}
+		mockIaas.EXPECT().CreateBulkPorts(gomock.Any()).DoAndReturn(
+			func(bulkReq *mgriaas.MgrBulkPortsReq) ([]*iaasaccessor.Interface, error) {
+				So(len(bulkReq.Ports), ShouldEqual, 1)
+				So(bulkReq.Ports[0].NetworkId, ShouldEqual, "network-id")
+				So(bulkReq.Ports[0].SubnetId, ShouldEqual, "subnet-id")
+				So(bulkReq.Ports[0].PortName, ShouldEqual, "port-name")
+				So(bulkReq.Ports[0].FixIP, ShouldEqual, "")
+				So(bulkReq.Ports[0].SecurityGroups, ShouldResemble, []string{"sg0"})
+				So(*bulkReq.Ports[0].PortSecurityEnabled, ShouldBeTrue)
+				return []*iaasaccessor.Interface{iaasPort}, nil
+			})
+
+		portOps := &PortOps{}
+		port, err := portOps.CreatePort("", mockIaas, req)
+		So(err, ShouldBeNil)
+		So(port, ShouldEqual, iaasPort)
+		So(req.FixIP, ShouldEqual, "10.0.0.2")
+	})
+}
+
 func TestAttachPortToVMErr(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -14,16 +14,22 @@ limitations under the License.
 package models
 
 import (
+	"crypto/sha1"
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"net"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ZTE/Knitter/knitter-manager/err-obj"
 	"github.com/ZTE/Knitter/knitter-manager/public"
 	"github.com/ZTE/Knitter/pkg/db-accessor"
 	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/antonholmquist/jason"
 	"sync"
 )
 
@@ -31,10 +37,21 @@ type SyncMgt struct {
 	Active bool
 	Data   dbaccessor.Sync
 	lock   sync.Mutex
+	// tunnelPinned is set when the tunnel is given by the configuration of
+	// knitter-manager, the agents can not change it then
+	tunnelPinned bool
+	// membersVersion digests the members of the networks, membersChanged is
+	// closed when it is changed to wake up the watching agents
+	membersVersion string
+	membersChanged chan struct{}
 }
 
 const DefaultTTL int = 10
 
+// MembersWatchTimeout bounds the wait of a members watch, the agents watch
+// again after it.
+const MembersWatchTimeout = 30 * time.Second
+
 var syncManager SyncMgt = SyncMgt{Active: false}
 
 func GetSyncMgt() *SyncMgt {
@@ -55,6 +72,154 @@ func (self *SyncMgt) SetInterval(interval string) error {
 	return nil
 }
 
+// SetTunnel pins the tunnel of the cluster, nil lets the first agent
+// syncing with knitter-manager decide it.
+func (self *SyncMgt) SetTunnel(tunnel *dbaccessor.Tunnel) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if tunnel == nil {
+		self.tunnelPinned = false
+		return nil
+	}
+	if !tunnel.IsValid() {
+		klog.Errorf("SetTunnel: invalid tunnel: %+v", *tunnel)
+		return errobj.ErrInvalidTunnel
+	}
+	self.tunnelPinned = true
+	self.Data.Tunnel = tunnel
+	return self.save()
+}
+
+// SetSyncTunnel pins the tunnel of the cluster if it is configured as
+// {"tunnel": {"type": "vxlan", "dst_port": 4789}}.
+func SetSyncTunnel(cfg *jason.Object) error {
+	tunnelType, err := cfg.GetString("tunnel", "type")
+	if err != nil {
+		klog.Infof("SetSyncTunnel: no tunnel configured, decided by the agents")
+		return GetSyncMgt().SetTunnel(nil)
+	}
+	dstPort, _ := cfg.GetInt64("tunnel", "dst_port")
+	return GetSyncMgt().SetTunnel(&dbaccessor.Tunnel{Type: tunnelType, DstPort: int(dstPort)})
+}
+
+// checkTunnel makes sure that all agents use the same tunnel. The tunnel of
+// an agent is adopted if no tunnel is pinned and no other agent is ready,
+// so the tunnel can be changed by restarting all agents.
+func (self *SyncMgt) checkTunnel(reqIP string, tunnel *dbaccessor.Tunnel) error {
+	if tunnel == nil {
+		return nil
+	}
+	if !tunnel.IsValid() {
+		return errobj.ErrInvalidTunnel
+	}
+	if self.Data.Tunnel != nil && *self.Data.Tunnel == *tunnel {
+		return nil
+	}
+	if self.Data.Tunnel != nil && (self.tunnelPinned || self.hasOtherReadyAgent(reqIP)) {
+		klog.Errorf("checkTunnel: tunnel[%+v] of agent[%s] conflicts with tunnel[%+v] of the cluster",
+			*tunnel, reqIP, *self.Data.Tunnel)
+		return errobj.ErrTunnelConflict
+	}
+	klog.Infof("checkTunnel: tunnel of the cluster is set to [%+v] by agent[%s]", *tunnel, reqIP)
+	self.Data.Tunnel = tunnel
+	return self.save()
+}
+
+func (self *SyncMgt) hasOtherReadyAgent(reqIP string) bool {
+	for _, agent := range self.Data.Agents {
+		if agent.Ip != reqIP && agent.Status == dbaccessor.AgentStatusReady {
+			return true
+		}
+	}
+	return false
+}
+
+// setAgentNetworks records the networks hosting pods on the agent, nil keeps
+// the networks recorded before.
+func (self *SyncMgt) setAgentNetworks(reqIP string, networks []string) {
+	agent := self.getAgent(reqIP)
+	if agent == nil || networks == nil {
+		return
+	}
+	sort.Strings(networks)
+	if reflect.DeepEqual(agent.Networks, networks) {
+		return
+	}
+	klog.Infof("setAgentNetworks: networks of agent[%s] changed to %v", reqIP, networks)
+	agent.Networks = networks
+	self.save()
+}
+
+// getMembers returns the ips of the ready agents hosting each network, and
+// the ips of the agents never reporting their networks. The latter are
+// members of all networks, so the agents of old versions still receive the
+// broadcast.
+func (self *SyncMgt) getMembers() (map[string][]string, []string) {
+	members := make(map[string][]string)
+	var unknownIPs []string
+	for _, agent := range self.Data.Agents {
+		if agent.Status != dbaccessor.AgentStatusReady {
+			continue
+		}
+		if agent.Networks == nil {
+			unknownIPs = append(unknownIPs, agent.Ip)
+			continue
+		}
+		for _, networkID := range agent.Networks {
+			members[networkID] = append(members[networkID], agent.Ip)
+		}
+	}
+	for networkID, ips := range members {
+		ips = append(ips, unknownIPs...)
+		sort.Strings(ips)
+		members[networkID] = ips
+	}
+	sort.Strings(unknownIPs)
+	return members, unknownIPs
+}
+
+func getMembersVersion(members map[string][]string, allNetworkMembers []string) string {
+	data, _ := json.Marshal([]interface{}{members, allNetworkMembers})
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
+
+// notifyMembers wakes up the agents watching the members if they are
+// changed, so the agents hosting a network learn its new members at once.
+func (self *SyncMgt) notifyMembers(version string) {
+	if self.membersChanged != nil && version == self.membersVersion {
+		return
+	}
+	klog.Infof("notifyMembers: members version changed to %s", version)
+	self.membersVersion = version
+	if self.membersChanged != nil {
+		close(self.membersChanged)
+	}
+	self.membersChanged = make(chan struct{})
+}
+
+// WatchMembers waits until the members version is changed from the one
+// known by the agent or the timeout, and returns the current version.
+func (self *SyncMgt) WatchMembers(version string, timeout time.Duration) string {
+	self.lock.Lock()
+	if self.membersChanged == nil {
+		self.notifyMembers(getMembersVersion(self.getMembers()))
+	}
+	if self.membersVersion != version {
+		defer self.lock.Unlock()
+		return self.membersVersion
+	}
+	changed := self.membersChanged
+	self.lock.Unlock()
+
+	select {
+	case <-changed:
+	case <-time.After(timeout):
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.membersVersion
+}
+
 func (self *SyncMgt) getDefaultTTL() int {
 	return DefaultTTL * self.getNumbOfReadyAgents()
 }
@@ -165,10 +330,15 @@ func (self *SyncMgt) FlushTTL(reqIP string) error {
 	return nil
 }
 
-func (self *SyncMgt) Sync(reqIP string) *dbaccessor.SyncRsp {
+func (self *SyncMgt) Sync(reqIP string, tunnel *dbaccessor.Tunnel, networks []string) (*dbaccessor.SyncRsp, error) {
 	self.lock.Lock()
 	defer self.lock.Unlock()
 	klog.Trace("SYNC:[", reqIP, "] request at:", time.Now().String())
+	err := self.checkTunnel(reqIP, tunnel)
+	if err != nil {
+		return nil, err
+	}
+
 	if self.isExist(reqIP) == false {
 		agent := dbaccessor.Agent{Id: self.getAgentID(reqIP),
 			Ip: reqIP, Status: dbaccessor.AgentStatusReady}
@@ -182,13 +352,19 @@ func (self *SyncMgt) Sync(reqIP string) *dbaccessor.SyncRsp {
 		self.save()
 	}
 
+	self.setAgentNetworks(reqIP, networks)
 	self.FlushTTL(reqIP)
 	self.Data.Client = self.getAgent(reqIP)
 
-	rspData := dbaccessor.SyncRsp{Interval: self.Data.Interval, Client: *self.Data.Client}
+	members, allNetworkMembers := self.getMembers()
+	version := getMembersVersion(members, allNetworkMembers)
+	self.notifyMembers(version)
+	rspData := dbaccessor.SyncRsp{Interval: self.Data.Interval, Tunnel: self.Data.Tunnel,
+		Client: *self.Data.Client, Members: members, AllNetworkMembers: allNetworkMembers,
+		MembersVersion: version}
 	for _, agent := range self.Data.Agents {
 		rspData.Agents = append(rspData.Agents, *agent)
 	}
 
-	return &rspData
+	return &rspData, nil
 }
@@ -0,0 +1,138 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/gostub"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-manager/err-obj"
+	"github.com/ZTE/Knitter/knitter-manager/public"
+	"github.com/ZTE/Knitter/knitter-manager/tests/mock/db-mock"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+)
+
+func TestSyncMgtSync(t *testing.T) {
+	Convey("TestSyncMgtSync", t, func() {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		mockDB := mockdbaccessor.NewMockDbAccessor(mockCtl)
+		stubs := gostub.StubFunc(&common.GetDataBase, mockDB)
+		defer stubs.Reset()
+		var saved string
+		mockDB.EXPECT().SaveLeaf(dbaccessor.GetKeyOfTopoSyncData(), gomock.Any()).DoAndReturn(
+			func(key, value string) error {
+				saved = value
+				return nil
+			}).AnyTimes()
+		mockDB.EXPECT().ReadLeaf(dbaccessor.GetKeyOfTopoSyncData()).DoAndReturn(
+			func(key string) (string, error) {
+				return saved, nil
+			}).AnyTimes()
+
+		syncMgt := &SyncMgt{}
+		vxlan := &dbaccessor.Tunnel{Type: dbaccessor.TunnelTypeVxlan, DstPort: 4789}
+		geneve := &dbaccessor.Tunnel{Type: dbaccessor.TunnelTypeGeneve, DstPort: 6081}
+
+		Convey("adopted from the first agent\n", func() {
+			rsp, err := syncMgt.Sync("192.168.1.1", vxlan, nil)
+			So(err, ShouldBeNil)
+			So(rsp.Tunnel, ShouldResemble, vxlan)
+			rsp, err = syncMgt.Sync("192.168.1.2", &dbaccessor.Tunnel{Type: "vxlan", DstPort: 4789}, nil)
+			So(err, ShouldBeNil)
+			So(len(rsp.Agents), ShouldEqual, 2)
+
+			_, err = syncMgt.Sync("192.168.1.3", geneve, nil)
+			So(err, ShouldEqual, errobj.ErrTunnelConflict)
+			_, err = syncMgt.Sync("192.168.1.3", &dbaccessor.Tunnel{Type: "gre", DstPort: 4789}, nil)
+			So(err, ShouldEqual, errobj.ErrInvalidTunnel)
+
+			rsp, err = syncMgt.Sync("192.168.1.4", nil, nil)
+			So(err, ShouldBeNil)
+			So(rsp.Tunnel, ShouldResemble, vxlan)
+		})
+
+		Convey("changed when no other agent is ready\n", func() {
+			_, err := syncMgt.Sync("192.168.1.1", vxlan, nil)
+			So(err, ShouldBeNil)
+			rsp, err := syncMgt.Sync("192.168.1.1", geneve, nil)
+			So(err, ShouldBeNil)
+			So(rsp.Tunnel, ShouldResemble, geneve)
+		})
+
+		Convey("pinned by the configuration\n", func() {
+			So(syncMgt.SetTunnel(&dbaccessor.Tunnel{Type: "vxlan", DstPort: 0}), ShouldEqual, errobj.ErrInvalidTunnel)
+			So(syncMgt.SetTunnel(geneve), ShouldBeNil)
+			_, err := syncMgt.Sync("192.168.1.1", vxlan, nil)
+			So(err, ShouldEqual, errobj.ErrTunnelConflict)
+			rsp, err := syncMgt.Sync("192.168.1.1", geneve, nil)
+			So(err, ShouldBeNil)
+			So(rsp.Tunnel, ShouldResemble, geneve)
+		})
+
+		Convey("members of the networks\n", func() {
+			_, err := syncMgt.Sync("192.168.1.1", vxlan, []string{"net2", "net1"})
+			So(err, ShouldBeNil)
+			_, err = syncMgt.Sync("192.168.1.2", vxlan, []string{})
+			So(err, ShouldBeNil)
+			rsp, err := syncMgt.Sync("192.168.1.3", vxlan, []string{"net1"})
+			So(err, ShouldBeNil)
+			So(rsp.Members, ShouldResemble, map[string][]string{
+				"net1": {"192.168.1.1", "192.168.1.3"}, "net2": {"192.168.1.1"}})
+
+			rsp, err = syncMgt.Sync("192.168.1.3", vxlan, nil)
+			So(err, ShouldBeNil)
+			So(rsp.Members["net1"], ShouldResemble, []string{"192.168.1.1", "192.168.1.3"})
+			rsp, err = syncMgt.Sync("192.168.1.1", vxlan, []string{"net2"})
+			So(err, ShouldBeNil)
+			So(rsp.Members, ShouldResemble, map[string][]string{
+				"net1": {"192.168.1.3"}, "net2": {"192.168.1.1"}})
+
+			Convey("agents not reporting networks are members of all networks\n", func() {
+				rsp, err = syncMgt.Sync("192.168.1.4", nil, nil)
+				So(err, ShouldBeNil)
+				So(rsp.Members, ShouldResemble, map[string][]string{
+					"net1": {"192.168.1.3", "192.168.1.4"}, "net2": {"192.168.1.1", "192.168.1.4"}})
+				So(rsp.AllNetworkMembers, ShouldResemble, []string{"192.168.1.4"})
+			})
+		})
+
+		Convey("members watch\n", func() {
+			rsp, err := syncMgt.Sync("192.168.1.1", vxlan, []string{"net1"})
+			So(err, ShouldBeNil)
+			version := rsp.MembersVersion
+			So(version, ShouldNotBeEmpty)
+			So(syncMgt.WatchMembers("", time.Second), ShouldEqual, version)
+			So(syncMgt.WatchMembers(version, time.Millisecond), ShouldEqual, version)
+
+			rsp, err = syncMgt.Sync("192.168.1.2", vxlan, []string{})
+			So(err, ShouldBeNil)
+			So(rsp.MembersVersion, ShouldEqual, version)
+
+			newVersion := make(chan string)
+			go func() {
+				newVersion <- syncMgt.WatchMembers(version, time.Minute)
+			}()
+			time.Sleep(10 * time.Millisecond)
+			rsp, err = syncMgt.Sync("192.168.1.2", vxlan, []string{"net1"})
+			So(err, ShouldBeNil)
+			So(rsp.MembersVersion, ShouldNotEqual, version)
+			So(<-newVersion, ShouldEqual, rsp.MembersVersion)
+		})
+	})
+}
@@ -25,7 +25,6 @@ import (
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/ZTE/Knitter/pkg/openstack"
 	"github.com/astaxie/beego/context"
-	"github.com/rackspace/gophercloud"
 	"net/http"
 	"strconv"
 	"strings"
@@ -563,15 +562,18 @@ func (self *ExclusiveTenant) Create() error {
 		return BuildErrWithCode(http.StatusConflict, errors.New("tenant is already exist"))
 	}
 	opstk := openstack.NewOpenstack()
-	conf := gophercloud.AuthOptions{
-		IdentityEndpoint: self.IaasEndpoint + "/tokens",
-		Username:         self.IaasUserName,
-		Password:         self.IaasPaasword,
-		TenantName:       self.IaasTenantName,
-		AllowReauth:      true,
-	}
-	opstk.SetConfig(conf)
-	err := opstk.Auth()
+	conf := openstack.OpenStackConf{
+		Url:        self.IaasEndpoint,
+		Username:   self.IaasUserName,
+		Password:   self.IaasPaasword,
+		TenantName: self.IaasTenantName,
+	}
+	err := opstk.SetOpenStackConf(conf)
+	if err != nil {
+		klog.Errorf("IaaS-config-ERROR: %v", err)
+		return BuildErrWithCode(http.StatusNotAcceptable, err)
+	}
+	err = opstk.Auth()
 	if err != nil {
 		klog.Errorf("IaaS-auth-ERROR")
 		return BuildErrWithCode(http.StatusNotAcceptable, errors.New("auth error"))
@@ -72,6 +72,11 @@ func init() {
 
 	beego.Router("/nw/v1/configuration", &controllers.InitCfgController{}, "post:Post")
 
+	beego.Router("/nw/v1/exceptional_ports", &controllers.ExceptPortController{}, "get:GetAll")
+	beego.Router("/nw/v1/exceptional_ports/:port_id/retry", &controllers.ExceptPortController{}, "post:Retry")
+	beego.Router("/nw/v1/exceptional_ports/:port_id", &controllers.ExceptPortController{}, "delete:Delete")
+	beego.Router("/nw/v1/port_pools", &controllers.PortPoolController{}, "get:GetAll")
+
 	beego.Router("/api/v1/tenants/:user/port", &controllers.CniMasterPortController{}, "post:Post")
 	beego.Router("/api/v1/tenants/:user/port/:port_id", &controllers.CniMasterPortController{}, "delete:Delete")
 	beego.Router("/api/v1/tenants/:user/port/:vm_id/:port_id", &controllers.CniMasterPortController{}, "post:Attach")
@@ -88,7 +93,18 @@ func init() {
 	beego.Router("/api/v1/tenants/:user/networks", &controllers.PaasNetController{}, "post:Post")
 	beego.Router("/api/v1/tenants/:user/networks/:network_name", &controllers.PaasNetController{}, "get:Get")
 
+	beego.Router("/api/v1/tenants/:user/ipgroups", &controllers.IPGroupController{}, "get:GetAll")
+	beego.Router("/api/v1/tenants/:user/ipgroups", &controllers.IPGroupController{}, "post:Post")
+	beego.Router("/api/v1/tenants/:user/ipgroups/:group", &controllers.IPGroupController{}, "get:Get")
+	beego.Router("/api/v1/tenants/:user/ipgroups/:group", &controllers.IPGroupController{}, "put:Put")
+	beego.Router("/api/v1/tenants/:user/ipgroups/:group", &controllers.IPGroupController{}, "delete:Delete")
+
+	beego.Router("/api/v1/tenants/:user", &controllers.TenantController{}, "get:Get")
+	beego.Router("/api/v1/tenants/:user", &controllers.TenantController{}, "post:Post")
+	beego.Router("/api/v1/tenants/:user", &controllers.TenantController{}, "delete:Delete")
+
 	beego.Router("/api/v1/tenants/:user/sync/:internal_ip", &controllers.SyncController{}, "get:Get")
+	beego.Router("/api/v1/tenants/:user/sync/:internal_ip/members", &controllers.SyncController{}, "get:WatchMembers")
 
 	beego.Router("/api/v1/tenants/admin/health", &controllers.HealthController{}, "get:Get")
 
@@ -330,6 +330,16 @@ func (_mr *_MockIaaSRecorder) SetAttachReq(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetAttachReq", arg0)
 }
 
+func (_m *MockIaaS) UpdatePortName(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "UpdatePortName", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockIaaSRecorder) UpdatePortName(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdatePortName", arg0, arg1)
+}
+
 func (_m *MockIaaS) UpdateRouter(_param0 string, _param1 string, _param2 string) error {
 	ret := _m.ctrl.Call(_m, "UpdateRouter", _param0, _param1, _param2)
 	ret0, _ := ret[0].(error)
@@ -23,8 +23,11 @@ import (
 	"github.com/ZTE/Knitter/knitter-manager/models"
 	"github.com/ZTE/Knitter/knitter-manager/public"
 	_ "github.com/ZTE/Knitter/knitter-manager/routers"
+	"github.com/ZTE/Knitter/pkg/http"
 	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/mock"
 	"github.com/ZTE/Knitter/pkg/openstack"
+	"github.com/ZTE/Knitter/pkg/vnfm"
 	"github.com/antonholmquist/jason"
 	"github.com/coreos/etcd/client"
 	"github.com/golang/gostub"
@@ -411,27 +414,27 @@ func TestSaveVnfmConfg(t *testing.T) {
 	})
 }
 
-//func TestCheckVnfmConfig(t *testing.T) {
-//	conf := vnfm.VnfmConf{
-//		URL: "http://10.62.100.202:80/api/nf_m_i/v1/",
-//		NfInstanceID: "1",
-//	}
-//	cfgMock := gomock.NewController(t)
-//	defer cfgMock.Finish()
-//	mockHTTPMethods := mock_http.NewMockHTTPMethods(cfgMock)
-//	stubs := gostub.StubFunc(&http.GetHTTPClientObj, mockHTTPMethods)
-//	defer stubs.Reset()
-//	Convey("TestCheckVnfmConfig---OK\n", t, func() {
-//		mockHTTPMethods.EXPECT().Delete(gomock.Any()).Return(404, errors.New("404 err"))
-//		err := models.CheckVnfmConfig(conf)
-//		So(err, ShouldEqual, nil)
-//	})
-//	Convey("TestCheckVnfmConfig---ERR\n", t, func() {
-//		mockHTTPMethods.EXPECT().Delete(gomock.Any()).Return(500, errors.New("500 err"))
-//		err := models.CheckVnfmConfig(conf)
-//		So(err, ShouldNotEqual, nil)
-//	})
-//}
+func TestCheckVnfmConfig(t *testing.T) {
+	conf := vnfm.VnfmConf{
+		URL:          "http://10.62.100.202:80/api/nf_m_i/v1/",
+		NfInstanceID: "1",
+	}
+	cfgMock := gomock.NewController(t)
+	defer cfgMock.Finish()
+	mockHTTPMethods := mock_http.NewMockHTTPMethods(cfgMock)
+	stubs := gostub.StubFunc(&http.GetHTTPClientObj, mockHTTPMethods)
+	defer stubs.Reset()
+	Convey("TestCheckVnfmConfig---OK\n", t, func() {
+		mockHTTPMethods.EXPECT().Delete(gomock.Any()).Return(nil, 404, "")
+		err := models.CheckVnfmConfig(conf)
+		So(err, ShouldEqual, nil)
+	})
+	Convey("TestCheckVnfmConfig---ERR\n", t, func() {
+		mockHTTPMethods.EXPECT().Delete(gomock.Any()).Return(nil, 500, "500 err")
+		err := models.CheckVnfmConfig(conf)
+		So(err, ShouldNotEqual, nil)
+	})
+}
 
 func TestInitConfigurationOK(t *testing.T) {
 	cfgMock := gomock.NewController(t)
@@ -0,0 +1,103 @@
+package apps
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ZTE/Knitter/knitter-monitor/services"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// AdmissionReview is the admission.k8s.io AdmissionReview, v1beta1 and v1
+// share the fields used here, the client-go vendored has no admission api.
+type AdmissionReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Request         *AdmissionRequest  `json:"request,omitempty"`
+	Response        *AdmissionResponse `json:"response,omitempty"`
+}
+
+type AdmissionRequest struct {
+	UID       types.UID               `json:"uid"`
+	Kind      metav1.GroupVersionKind `json:"kind"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Name      string                  `json:"name,omitempty"`
+	Operation string                  `json:"operation"`
+	Object    runtime.RawExtension    `json:"object,omitempty"`
+	OldObject runtime.RawExtension    `json:"oldObject,omitempty"`
+}
+
+type AdmissionResponse struct {
+	UID     types.UID      `json:"uid"`
+	Allowed bool           `json:"allowed"`
+	Result  *metav1.Status `json:"status,omitempty"`
+}
+
+const (
+	AdmissionOperationCreate = "CREATE"
+	AdmissionOperationUpdate = "UPDATE"
+)
+
+func GetAdmissionApp() AdmissionAppInterface {
+	return &admissionApp{}
+}
+
+type AdmissionAppInterface interface {
+	ReviewPod(review *AdmissionReview) *AdmissionReview
+}
+
+type admissionApp struct {
+}
+
+// ReviewPod rejects the pods whose networks annotation is invalid, updates of
+// pods are only checked if the annotation is changed.
+func (aa *admissionApp) ReviewPod(review *AdmissionReview) *AdmissionReview {
+	req := review.Request
+	resp := &AdmissionReview{TypeMeta: review.TypeMeta, Response: &AdmissionResponse{UID: req.UID, Allowed: true}}
+	if req.Kind.Kind != "Pod" ||
+		(req.Operation != AdmissionOperationCreate && req.Operation != AdmissionOperationUpdate) {
+		return resp
+	}
+
+	pod := &v1.Pod{}
+	err := json.Unmarshal(req.Object.Raw, pod)
+	if err != nil {
+		klog.Errorf("admissionApp.ReviewPod: json.Unmarshal pod of request[%v] err, error is [%v]", req.UID, err)
+		return denyAdmission(resp, http.StatusBadRequest, metav1.StatusReasonBadRequest, "decode pod error: "+err.Error())
+	}
+	if req.Operation == AdmissionOperationUpdate {
+		oldPod := &v1.Pod{}
+		err = json.Unmarshal(req.OldObject.Raw, oldPod)
+		if err == nil && !services.IsPodNetworksChanged(oldPod, pod) {
+			return resp
+		}
+	}
+
+	podNs := req.Namespace
+	if podNs == "" {
+		podNs = pod.Namespace
+	}
+	err = services.ValidatePodNetworks(podNs, pod)
+	if err != nil {
+		klog.Warningf("admissionApp.ReviewPod: deny %v of pod[%v/%v%v], error is [%v]",
+			req.Operation, podNs, pod.Name, pod.GenerateName, err)
+		return denyAdmission(resp, http.StatusUnprocessableEntity, metav1.StatusReasonInvalid,
+			"invalid networks annotation: "+err.Error())
+	}
+	return resp
+}
+
+func denyAdmission(review *AdmissionReview, code int32, reason metav1.StatusReason, message string) *AdmissionReview {
+	review.Response.Allowed = false
+	review.Response.Result = &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Reason:  reason,
+		Message: message,
+	}
+	return review
+}
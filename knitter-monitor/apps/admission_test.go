@@ -0,0 +1,68 @@
+package apps
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/smartystreets/goconvey/convey"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ZTE/Knitter/knitter-monitor/services"
+)
+
+func newPodAdmissionReview(operation string, pod, oldPod *v1.Pod) *AdmissionReview {
+	review := &AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &AdmissionRequest{
+			UID:       "req-1",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: "admin",
+			Operation: operation,
+		},
+	}
+	review.Request.Object.Raw, _ = json.Marshal(pod)
+	if oldPod != nil {
+		review.Request.OldObject.Raw, _ = json.Marshal(oldPod)
+	}
+	return review
+}
+
+func TestAdmissionApp_ReviewPod(t *testing.T) {
+	validated := 0
+	monkey.Patch(services.ValidatePodNetworks, func(podNs string, k8sPod *v1.Pod) error {
+		validated++
+		if k8sPod.Annotations["networks"] == "invalid" {
+			return errors.New("ports[0]: attach_to_network is required")
+		}
+		return nil
+	})
+	defer monkey.UnpatchAll()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1",
+		Annotations: map[string]string{"networks": "invalid"}}}
+
+	convey.Convey("TestAdmissionApp_ReviewPod", t, func() {
+		validated = 0
+		resp := GetAdmissionApp().ReviewPod(newPodAdmissionReview(AdmissionOperationCreate, pod, nil))
+		convey.So(resp.APIVersion, convey.ShouldEqual, "admission.k8s.io/v1")
+		convey.So(resp.Response.UID, convey.ShouldEqual, "req-1")
+		convey.So(resp.Response.Allowed, convey.ShouldBeFalse)
+		convey.So(resp.Response.Result.Message, convey.ShouldContainSubstring, "attach_to_network is required")
+
+		resp = GetAdmissionApp().ReviewPod(newPodAdmissionReview(AdmissionOperationUpdate, pod, pod))
+		convey.So(resp.Response.Allowed, convey.ShouldBeTrue)
+		convey.So(validated, convey.ShouldEqual, 1)
+
+		validPod := pod.DeepCopy()
+		validPod.Annotations["networks"] = `{"ports": [{"attach_to_network": "net_api"}]}`
+		resp = GetAdmissionApp().ReviewPod(newPodAdmissionReview(AdmissionOperationUpdate, validPod, pod))
+		convey.So(resp.Response.Allowed, convey.ShouldBeTrue)
+		convey.So(validated, convey.ShouldEqual, 2)
+
+		resp = GetAdmissionApp().ReviewPod(newPodAdmissionReview("DELETE", pod, nil))
+		convey.So(resp.Response.Allowed, convey.ShouldBeTrue)
+		convey.So(validated, convey.ShouldEqual, 2)
+	})
+}
@@ -24,6 +24,9 @@ type PortEagerAttrForAgent struct {
 	Metadata     interface{} `json:"metadata"`
 	Combinable   string      `json:"combinable"`
 	Roles        []string    `json:"roles"`
+
+	Bond *services.BondAttr `json:"bond,omitempty"`
+	Qos  *services.QosAttr  `json:"qos,omitempty"`
 }
 
 type PortLazyAttrForAgent struct {
@@ -51,6 +54,8 @@ func newPortForAgent(port *services.Port) *PortForAgent {
 	p.EagerAttr.Metadata = port.EagerAttr.Metadata
 	p.EagerAttr.Combinable = port.EagerAttr.Combinable
 	p.EagerAttr.Roles = port.EagerAttr.Roles
+	p.EagerAttr.Bond = port.EagerAttr.Bond
+	p.EagerAttr.Qos = port.EagerAttr.Qos
 
 	p.LazyAttr.ID = port.LazyAttr.ID
 	p.LazyAttr.Name = port.LazyAttr.Name
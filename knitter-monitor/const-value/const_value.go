@@ -1,7 +1,8 @@
 package constvalue
 
 const (
-	WaitForCacheSyncTimes = 100
+	WaitForCacheSyncTimes  = 100
+	MaxUpdatePodRetryTimes = 10
 )
 const (
 	LogicalPortDefaultVnicType = "normal"
@@ -10,6 +11,23 @@ const HTTPDefaultTimeoutInSec = 60 // default http GET/POST request timeout in s
 
 const PaaSTenantAdminDefaultUUID = "admin"
 
+// annotations of namespace
+const (
+	// NamespaceTenantAnnotation is the knitter tenant of the pods in the
+	// namespace, the namespace name is used if it is not set
+	NamespaceTenantAnnotation = "knitter.io/tenant"
+	// NamespaceDefaultNetworkAnnotation is the network attached by the pods
+	// without networks annotation in the namespace
+	NamespaceDefaultNetworkAnnotation = "knitter.io/default-network"
+)
+
+// StickyIPAnnotation of StatefulSet set to "true" pins each pod of it to the
+// same ips across restarts and reschedules
+const StickyIPAnnotation = "knitter.io/sticky-ip"
+
+// MaxIPsInGroup is the max ip count of an ip group in knitter-manager
+const MaxIPsInGroup = 32
+
 const (
 	NetPlaneStd     = "std"
 	NetPlaneEio     = "eio"
@@ -24,3 +42,23 @@ const (
 	DefaultVnicType     = "normal"
 	DefaultIsAccelerate = "false"
 )
+
+// MaxAllowedAddressPairs is the default max_allowed_address_pair of neutron
+const MaxAllowedAddressPairs = 10
+
+// modes of the bond made in pod for a port with bond attribute
+const (
+	BondModeActiveBackup = "active-backup"
+	BondMode8023AD       = "802.3ad"
+	BondModeBalanceXor   = "balance-xor"
+)
+
+const (
+	DefaultBondMode   = BondModeActiveBackup
+	DefaultBondMiimon = 100
+	// BondSlaveNum is the count of VFs or veths enslaved by the bond
+	BondSlaveNum = 2
+)
+
+// MaxQosDscp is the max value of the 6-bit DSCP field
+const MaxQosDscp = 63
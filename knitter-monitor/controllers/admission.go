@@ -0,0 +1,50 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/astaxie/beego"
+
+	"github.com/ZTE/Knitter/knitter-monitor/apps"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+type AdmissionController struct {
+	beego.Controller
+}
+
+// Title Post
+// Description validating admission webhook of the networks annotation of pods
+// Success 200 {object} apps.AdmissionReview
+// Failure 400 : bad admission review
+// router /api/v1/admission/pods [post]
+func (ac *AdmissionController) Post() {
+	review := &apps.AdmissionReview{}
+	err := json.Unmarshal(ac.Ctx.Input.RequestBody, review)
+	if err == nil && review.Request == nil {
+		err = errors.New("admission review has no request")
+	}
+	if err != nil {
+		klog.Errorf("AdmissionController.Post: decode admission review err, error is [%v]", err)
+		errobj.HandleErr(&ac.Controller, errobj.BuildErrWithCode(http.StatusBadRequest, err))
+		return
+	}
+	ac.Data["json"] = apps.GetAdmissionApp().ReviewPod(review)
+	ac.ServeJSON()
+}
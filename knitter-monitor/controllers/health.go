@@ -0,0 +1,48 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/astaxie/beego"
+
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+)
+
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+type Health struct {
+	Role           string                      `json:"role"`
+	LeaderElection *infra.LeaderElectionStatus `json:"leader_election"`
+}
+
+type HealthController struct {
+	beego.Controller
+}
+
+// Title Get
+// Description get health and leader election state of knitter-monitor
+// Success 200 {object} controllers.Health
+// router /api/v1/health [get]
+func (hc *HealthController) Get() {
+	status := infra.GetLeaderElectionStatus()
+	health := Health{Role: RoleStandby, LeaderElection: status}
+	if status.IsLeader {
+		health.Role = RoleLeader
+	}
+	hc.Data["json"] = health
+	hc.ServeJSON()
+}
@@ -19,6 +19,7 @@ type PodDaoInterface interface {
 	Get(podNs, podName string) (*PodForDB, error)
 	Save(podForDB *PodForDB) error
 	Delete(podNs, podName string) error
+	GetAll() ([]*PodForDB, error)
 }
 
 type podDao struct {
@@ -75,6 +76,47 @@ func (pd *podDao) Delete(podNs, podName string) error {
 
 }
 
+// GetAll returns all pods stored by the monitor, pods are stored under
+// the namespace directories of GetKeyOfMonitorPods.
+func (pd *podDao) GetAll() ([]*PodForDB, error) {
+	klog.Debugf("podDao.GetAll start")
+	nsNodes, err := infra.GetDataBase().ReadDir(dbaccessor.GetKeyOfMonitorPods())
+	if err != nil {
+		if errobj.IsKeyNotFoundError(err) {
+			return []*PodForDB{}, nil
+		}
+		klog.Errorf("podDao.GetAll: infra.GetDataBase().ReadDir(key:[%v]) err, error is [%v]",
+			dbaccessor.GetKeyOfMonitorPods(), err)
+		return nil, err
+	}
+
+	podsForDB := make([]*PodForDB, 0)
+	for _, nsNode := range nsNodes {
+		if !nsNode.Dir {
+			continue
+		}
+		podNodes, err := infra.GetDataBase().ReadDir(nsNode.Key)
+		if err != nil {
+			if errobj.IsKeyNotFoundError(err) {
+				continue
+			}
+			klog.Errorf("podDao.GetAll: infra.GetDataBase().ReadDir(key:[%v]) err, error is [%v]", nsNode.Key, err)
+			return nil, err
+		}
+		for _, podNode := range podNodes {
+			podForDB := &PodForDB{}
+			err = json.Unmarshal([]byte(podNode.Value), podForDB)
+			if err != nil {
+				klog.Warningf("podDao.GetAll: json.Unmarshal([]byte(value:[%v])) err, error is [%v], skip it", podNode.Value, err)
+				continue
+			}
+			podsForDB = append(podsForDB, podForDB)
+		}
+	}
+	klog.Debugf("podDao.GetAll SUCC, pods count is [%v]", len(podsForDB))
+	return podsForDB, nil
+}
+
 type PodForDB struct {
 	TenantId     string       `json:"tenant_id"`
 	PodID        string       `json:"pod_id"`
@@ -84,4 +126,7 @@ type PodForDB struct {
 	IsSuccessful bool         `json:"is_successful"`
 	ErrorMsg     string       `json:"error_msg"`
 	Ports        []*PortForDB `json:"ports"`
+	// PendingDelete is set once the pod is deleted in k8s and cleared with
+	// the pod record after its ports are released.
+	PendingDelete bool `json:"pending_delete,omitempty"`
 }
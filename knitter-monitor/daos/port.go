@@ -14,11 +14,18 @@ type PortForDB struct {
 	PodName      string      `json:"pod_name"`
 	PodNs        string      `json:"pod_ns"`
 	FixIP        string      `json:"fix_ip"`
+	FixMac       string      `json:"fix_mac,omitempty"`
 	IPGroupName  string      `json:"ip_group_name"`
 	Metadata     interface{} `json:"metadata"`
 	Combinable   string      `json:"combinable"`
 	Roles        []string    `json:"roles"`
 
+	SecurityGroups      []string            `json:"security_groups,omitempty"`
+	PortSecurityEnabled *bool               `json:"port_security_enabled,omitempty"`
+	AllowedAddressPairs []ports.AddressPair `json:"allowed_address_pairs,omitempty"`
+	Bond                *BondForDB          `json:"bond,omitempty"`
+	Qos                 *QosForDB           `json:"qos,omitempty"`
+
 	ID         string     `json:"id"`
 	LazyName   string     `json:"lazy_name"`
 	TenantID   string     `json:"tenant_id"`
@@ -27,3 +34,19 @@ type PortForDB struct {
 	GatewayIP  string     `json:"gateway_ip"`
 	Cidr       string     `json:"cidr"`
 }
+
+// BondForDB is the bond made in pod for the port, see services.BondAttr.
+type BondForDB struct {
+	Mode     string   `json:"mode"`
+	Miimon   int      `json:"miimon"`
+	Physnets []string `json:"physnets,omitempty"`
+}
+
+// QosForDB is the rate limiting and DSCP marking of the port, see services.QosAttr.
+type QosForDB struct {
+	IngressRate  int64 `json:"ingress_rate,omitempty"`
+	IngressBurst int64 `json:"ingress_burst,omitempty"`
+	EgressRate   int64 `json:"egress_rate,omitempty"`
+	EgressBurst  int64 `json:"egress_burst,omitempty"`
+	Dscp         *int  `json:"dscp,omitempty"`
+}
@@ -0,0 +1,123 @@
+package daos
+
+import (
+	"encoding/json"
+
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+func GetStatefulSetDao() StatefulSetDaoInterface {
+	//do not delete, for monkey ut
+	klog.Debugf("")
+	return &statefulSetDao{}
+}
+
+type StatefulSetDaoInterface interface {
+	Get(stsNs, stsName string) (*StatefulSetForDB, error)
+	Save(stsForDB *StatefulSetForDB) error
+	Delete(stsNs, stsName string) error
+	GetAll() ([]*StatefulSetForDB, error)
+}
+
+type statefulSetDao struct {
+}
+
+func (sd *statefulSetDao) Get(stsNs, stsName string) (*StatefulSetForDB, error) {
+	key := dbaccessor.GetKeyOfMonitorStatefulSet(stsNs, stsName)
+	value, err := infra.GetDataBase().ReadLeaf(key)
+	if err != nil {
+		klog.Errorf("statefulSetDao.Get: infra.GetDataBase().ReadLeaf(key:[%v]) err, error is [%v]", key, err)
+		return nil, err
+	}
+	stsForDB := &StatefulSetForDB{}
+	err = json.Unmarshal([]byte(value), stsForDB)
+	if err != nil {
+		klog.Errorf("statefulSetDao.Get: json.Unmarshal([]byte(value:[%v])) err, error is [%v]", value, err)
+		return nil, err
+	}
+	return stsForDB, nil
+}
+
+func (sd *statefulSetDao) Save(stsForDB *StatefulSetForDB) error {
+	key := dbaccessor.GetKeyOfMonitorStatefulSet(stsForDB.Namespace, stsForDB.Name)
+	stsByte, err := json.Marshal(stsForDB)
+	if err != nil {
+		klog.Errorf("statefulSetDao.Save: json.Marshal(stsForDB :[%v]) err, error is [%v]", stsForDB, err)
+		return err
+	}
+	err = infra.GetDataBase().SaveLeaf(key, string(stsByte))
+	if err != nil {
+		klog.Errorf("statefulSetDao.Save: infra.GetDataBase().SaveLeaf(key:[%v]) err, error is [%v]", key, err)
+		return err
+	}
+	return nil
+}
+
+func (sd *statefulSetDao) Delete(stsNs, stsName string) error {
+	key := dbaccessor.GetKeyOfMonitorStatefulSet(stsNs, stsName)
+	err := infra.GetDataBase().DeleteLeaf(key)
+	if err != nil && !errobj.IsKeyNotFoundError(err) {
+		klog.Errorf("statefulSetDao.Delete: infra.GetDataBase().DeleteLeaf(key:[%v]) err, error is [%v]", key, err)
+		return err
+	}
+	return nil
+}
+
+// GetAll returns all StatefulSets with sticky ips, they are stored under the
+// namespace directories of GetKeyOfMonitorStatefulSets.
+func (sd *statefulSetDao) GetAll() ([]*StatefulSetForDB, error) {
+	nsNodes, err := infra.GetDataBase().ReadDir(dbaccessor.GetKeyOfMonitorStatefulSets())
+	if err != nil {
+		if errobj.IsKeyNotFoundError(err) {
+			return []*StatefulSetForDB{}, nil
+		}
+		klog.Errorf("statefulSetDao.GetAll: infra.GetDataBase().ReadDir(key:[%v]) err, error is [%v]",
+			dbaccessor.GetKeyOfMonitorStatefulSets(), err)
+		return nil, err
+	}
+
+	stssForDB := make([]*StatefulSetForDB, 0)
+	for _, nsNode := range nsNodes {
+		if !nsNode.Dir {
+			continue
+		}
+		stsNodes, err := infra.GetDataBase().ReadDir(nsNode.Key)
+		if err != nil {
+			if errobj.IsKeyNotFoundError(err) {
+				continue
+			}
+			klog.Errorf("statefulSetDao.GetAll: infra.GetDataBase().ReadDir(key:[%v]) err, error is [%v]", nsNode.Key, err)
+			return nil, err
+		}
+		for _, stsNode := range stsNodes {
+			stsForDB := &StatefulSetForDB{}
+			err = json.Unmarshal([]byte(stsNode.Value), stsForDB)
+			if err != nil {
+				klog.Warningf("statefulSetDao.GetAll: json.Unmarshal([]byte(value:[%v])) err, error is [%v], skip it", stsNode.Value, err)
+				continue
+			}
+			stssForDB = append(stssForDB, stsForDB)
+		}
+	}
+	return stssForDB, nil
+}
+
+// StatefulSetForDB records the ip groups of a StatefulSet with sticky ips,
+// one group for each network attached by its pods.
+type StatefulSetForDB struct {
+	Namespace string                `json:"namespace"`
+	Name      string                `json:"name"`
+	TenantID  string                `json:"tenant_id"`
+	IPGroups  []*StickyIPGroupForDB `json:"ip_groups"`
+}
+
+// StickyIPGroupForDB pins the ordinals of pods to the ips of the group.
+type StickyIPGroupForDB struct {
+	NetworkName string         `json:"network_name"`
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	IPs         map[int]string `json:"ips"`
+}
@@ -24,6 +24,28 @@ var (
 	ErrJasonGetStringFailed     = errors.New("jason get string failed")
 	ErrGetPortConfigError       = errors.New("get port config error")
 	ErrPodNSOrPodNameIsNil      = errors.New(" podNs is nil or podName is nil")
+
+	ErrInvalidSecurityGroups        = errors.New("security_groups must be a list of security group uuids")
+	ErrInvalidPortSecurityEnabled   = errors.New("port_security_enabled must be true or false")
+	ErrInvalidAllowedAddressPairs   = errors.New("allowed_address_pairs must be a list of {ip_address, mac_address}")
+	ErrTooManyAllowedAddressPairs   = errors.New("too many allowed_address_pairs")
+	ErrPortSecurityDisabledConflict = errors.New("security_groups and allowed_address_pairs need port security enabled")
+	ErrPortSecurityWithIPGroup      = errors.New("port security is unsupported for port from ip group")
+
+	ErrInvalidBond            = errors.New("bond must be an object of {mode, miimon, physnets}")
+	ErrInvalidBondMode        = errors.New("bond mode must be active-backup, 802.3ad or balance-xor")
+	ErrInvalidBondMiimon      = errors.New("bond miimon must be a non-negative integer")
+	ErrInvalidBondPhysnets    = errors.New("bond physnets must be 2 different physnets for direct port and empty for normal port")
+	ErrBondUnsupportedNicType = errors.New("bond is only supported for port with nic_type normal or direct")
+
+	ErrInvalidQos            = errors.New("qos must be an object of {ingress_rate, ingress_burst, egress_rate, egress_burst, dscp}")
+	ErrInvalidQosRate        = errors.New("qos rate and burst must be non-negative integers in kbps and kb")
+	ErrQosBurstWithoutRate   = errors.New("qos burst needs the rate of the same direction")
+	ErrInvalidQosDscp        = errors.New("qos dscp must be an integer between 0 and 63")
+	ErrQosUnsupportedNicType = errors.New("qos is only supported for port with nic_type normal and without bond")
+
+	ErrTenantNotRegistered = errors.New("tenant is not registered in knitter-manager")
+	ErrNetworkNotExist     = errors.New("network does not exist")
 )
 
 func GetErrMsg(respData []byte) string {
@@ -52,6 +74,43 @@ func IsKeyNotFoundError(err error) bool {
 	return false
 }
 
+// InvalidNetworksError is the error caused by a bad networks annotation of pod.
+type InvalidNetworksError struct {
+	Err error
+}
+
+func (e *InvalidNetworksError) Error() string {
+	return e.Err.Error()
+}
+
+func NewInvalidNetworksError(err error) error {
+	if err == nil || IsInvalidNetworksError(err) {
+		return err
+	}
+	return &InvalidNetworksError{Err: err}
+}
+
+func IsInvalidNetworksError(err error) bool {
+	_, ok := err.(*InvalidNetworksError)
+	return ok
+}
+
+// messages of neutron IpAddressGenerationFailure and knitter-manager ip group
+var ipExhaustedMsgs = []string{"ipaddressgenerationfailure", "no more ip addresses", "no available ip"}
+
+func IsIPExhaustedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	errStr := strings.ToLower(err.Error())
+	for _, msg := range ipExhaustedMsgs {
+		if strings.Contains(errStr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
 func Err500(o *beego.Controller, err error) {
 	HandleErr(o, BuildErrWithCode(http.StatusInternalServerError, err))
 }
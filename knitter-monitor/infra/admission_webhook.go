@@ -0,0 +1,50 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"strconv"
+
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const DefaultAdmissionWebhookPort = 6443
+
+// AdmissionWebhookConfig is the https listener of the admission webhook,
+// kube-apiserver only calls webhooks over https.
+type AdmissionWebhookConfig struct {
+	Enabled  bool
+	Port     int
+	CertFile string
+	KeyFile  string
+}
+
+func NewAdmissionWebhookConfig(confObj *jason.Object) *AdmissionWebhookConfig {
+	cfg := &AdmissionWebhookConfig{Port: DefaultAdmissionWebhookPort}
+	cfg.Enabled, _ = confObj.GetBoolean("admission_webhook", "enabled")
+	portStr, _ := confObj.GetString("admission_webhook", "port")
+	if port, err := strconv.Atoi(portStr); err == nil && port > 0 {
+		cfg.Port = port
+	}
+	cfg.CertFile, _ = confObj.GetString("admission_webhook", "cert_file")
+	cfg.KeyFile, _ = confObj.GetString("admission_webhook", "key_file")
+	if cfg.Enabled && (cfg.CertFile == "" || cfg.KeyFile == "") {
+		klog.Errorf("NewAdmissionWebhookConfig: cert_file and key_file are required, disable admission webhook")
+		cfg.Enabled = false
+	}
+	klog.Infof("NewAdmissionWebhookConfig: admission webhook config is [%+v]", *cfg)
+	return cfg
+}
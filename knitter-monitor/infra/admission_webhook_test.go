@@ -0,0 +1,37 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewAdmissionWebhookConfig(t *testing.T) {
+	convey.Convey("TestNewAdmissionWebhookConfig", t, func() {
+		confObj, _ := jason.NewObjectFromBytes([]byte(`{"admission_webhook": {"enabled": true, "port": "8443",
+			"cert_file": "/etc/knitter/tls.crt", "key_file": "/etc/knitter/tls.key"}}`))
+		cfg := NewAdmissionWebhookConfig(confObj)
+		convey.So(cfg.Enabled, convey.ShouldBeTrue)
+		convey.So(cfg.Port, convey.ShouldEqual, 8443)
+		convey.So(cfg.CertFile, convey.ShouldEqual, "/etc/knitter/tls.crt")
+
+		confObj, _ = jason.NewObjectFromBytes([]byte(`{"admission_webhook": {"enabled": true}}`))
+		cfg = NewAdmissionWebhookConfig(confObj)
+		convey.So(cfg.Enabled, convey.ShouldBeFalse)
+		convey.So(cfg.Port, convey.ShouldEqual, DefaultAdmissionWebhookPort)
+	})
+}
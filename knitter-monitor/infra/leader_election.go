@@ -0,0 +1,414 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/antonholmquist/jason"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/uuid"
+)
+
+const (
+	DefaultLeaseNamespace     = "kube-system"
+	DefaultLeaseName          = "knitter-monitor"
+	DefaultLeaseDurationInSec = 15
+	DefaultRenewDeadlineInSec = 10
+	DefaultRetryPeriodInSec   = 2
+
+	coordinationAPIPath    = "/apis/coordination.k8s.io/v1"
+	coordinationAPIVersion = "coordination.k8s.io/v1"
+	leaseKind              = "Lease"
+)
+
+var ErrLeaseHeldByOthers = errors.New("lease is held by others")
+
+// Lease is the subset of coordination.k8s.io/v1 Lease used by the election
+type Lease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              LeaseSpec `json:"spec,omitempty"`
+}
+
+type LeaseSpec struct {
+	HolderIdentity       *string           `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32            `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *metav1.MicroTime `json:"acquireTime,omitempty"`
+	RenewTime            *metav1.MicroTime `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32            `json:"leaseTransitions,omitempty"`
+}
+
+type LeaseLockAPI interface {
+	Get() (*Lease, error)
+	Create(lease *Lease) (*Lease, error)
+	Update(lease *Lease) (*Lease, error)
+}
+
+type LeaseLock struct {
+	Client    rest.Interface
+	Namespace string
+	Name      string
+}
+
+func (ll *LeaseLock) leasesPath() string {
+	return coordinationAPIPath + "/namespaces/" + ll.Namespace + "/leases"
+}
+
+func (ll *LeaseLock) Get() (*Lease, error) {
+	body, err := ll.Client.Get().AbsPath(ll.leasesPath(), ll.Name).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalLease(body)
+}
+
+func (ll *LeaseLock) Create(lease *Lease) (*Lease, error) {
+	lease.APIVersion = coordinationAPIVersion
+	lease.Kind = leaseKind
+	lease.Name = ll.Name
+	lease.Namespace = ll.Namespace
+	leaseBytes, err := json.Marshal(lease)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ll.Client.Post().AbsPath(ll.leasesPath()).
+		SetHeader("Content-Type", "application/json").Body(leaseBytes).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalLease(body)
+}
+
+func (ll *LeaseLock) Update(lease *Lease) (*Lease, error) {
+	leaseBytes, err := json.Marshal(lease)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ll.Client.Put().AbsPath(ll.leasesPath(), ll.Name).
+		SetHeader("Content-Type", "application/json").Body(leaseBytes).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalLease(body)
+}
+
+func unmarshalLease(body []byte) (*Lease, error) {
+	lease := &Lease{}
+	err := json.Unmarshal(body, lease)
+	if err != nil {
+		klog.Errorf("unmarshalLease: json.Unmarshal(%s) err, error is [%v]", string(body), err)
+		return nil, err
+	}
+	return lease, nil
+}
+
+type LeaderElectionConfig struct {
+	Enabled       bool
+	Namespace     string
+	Name          string
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+func NewLeaderElectionConfig(confObj *jason.Object) *LeaderElectionConfig {
+	cfg := &LeaderElectionConfig{
+		Namespace:     DefaultLeaseNamespace,
+		Name:          DefaultLeaseName,
+		LeaseDuration: DefaultLeaseDurationInSec * time.Second,
+		RenewDeadline: DefaultRenewDeadlineInSec * time.Second,
+		RetryPeriod:   DefaultRetryPeriodInSec * time.Second,
+	}
+	cfg.Enabled, _ = confObj.GetBoolean("leader_election", "enabled")
+	if namespace, _ := confObj.GetString("leader_election", "namespace"); namespace != "" {
+		cfg.Namespace = namespace
+	}
+	if name, _ := confObj.GetString("leader_election", "name"); name != "" {
+		cfg.Name = name
+	}
+	cfg.LeaseDuration = getDurationInSec(confObj, "lease_duration", cfg.LeaseDuration)
+	cfg.RenewDeadline = getDurationInSec(confObj, "renew_deadline", cfg.RenewDeadline)
+	cfg.RetryPeriod = getDurationInSec(confObj, "retry_period", cfg.RetryPeriod)
+	if cfg.RenewDeadline >= cfg.LeaseDuration || cfg.RetryPeriod >= cfg.RenewDeadline {
+		klog.Warningf("NewLeaderElectionConfig: need retry_period < renew_deadline < lease_duration, use default")
+		cfg.LeaseDuration = DefaultLeaseDurationInSec * time.Second
+		cfg.RenewDeadline = DefaultRenewDeadlineInSec * time.Second
+		cfg.RetryPeriod = DefaultRetryPeriodInSec * time.Second
+	}
+
+	cfg.Identity = os.Getenv("POD_NAME")
+	if cfg.Identity == "" {
+		cfg.Identity, _ = os.Hostname()
+	}
+	cfg.Identity += "_" + uuid.GetUUID8Byte(uuid.NewUUID())
+	klog.Infof("NewLeaderElectionConfig: leader election config is [%+v]", *cfg)
+	return cfg
+}
+
+func getDurationInSec(confObj *jason.Object, key string, defaultValue time.Duration) time.Duration {
+	valueStr, _ := confObj.GetString("leader_election", key)
+	value, err := strconv.Atoi(valueStr)
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return time.Duration(value) * time.Second
+}
+
+type LeaderElectionStatus struct {
+	Enabled        bool   `json:"enabled"`
+	Identity       string `json:"identity,omitempty"`
+	IsLeader       bool   `json:"is_leader"`
+	Leader         string `json:"leader,omitempty"`
+	LastTransition string `json:"last_transition,omitempty"`
+	LastRenew      string `json:"last_renew,omitempty"`
+}
+
+type LeaderElector struct {
+	Config           *LeaderElectionConfig
+	Lock             LeaseLockAPI
+	OnStartedLeading func(stopCh <-chan struct{})
+	OnStoppedLeading func()
+
+	lock           sync.RWMutex
+	isLeader       bool
+	leader         string
+	lastTransition time.Time
+	lastRenew      time.Time
+	observedRV     string
+	observedTime   time.Time
+}
+
+func NewLeaderElector(cfg *LeaderElectionConfig, lock LeaseLockAPI) *LeaderElector {
+	return &LeaderElector{Config: cfg, Lock: lock}
+}
+
+var leaderElector *LeaderElector
+
+func SetLeaderElector(le *LeaderElector) {
+	leaderElector = le
+}
+
+func GetLeaderElector() *LeaderElector {
+	return leaderElector
+}
+
+func GetLeaderElectionStatus() *LeaderElectionStatus {
+	le := GetLeaderElector()
+	if le == nil {
+		return &LeaderElectionStatus{Enabled: false, IsLeader: true}
+	}
+	return le.Status()
+}
+
+func (le *LeaderElector) Status() *LeaderElectionStatus {
+	le.lock.RLock()
+	defer le.lock.RUnlock()
+	status := &LeaderElectionStatus{
+		Enabled:  true,
+		Identity: le.Config.Identity,
+		IsLeader: le.isLeader,
+		Leader:   le.leader,
+	}
+	if !le.lastTransition.IsZero() {
+		status.LastTransition = le.lastTransition.UTC().Format(time.RFC3339)
+	}
+	if !le.lastRenew.IsZero() {
+		status.LastRenew = le.lastRenew.UTC().Format(time.RFC3339)
+	}
+	return status
+}
+
+func (le *LeaderElector) IsLeader() bool {
+	le.lock.RLock()
+	defer le.lock.RUnlock()
+	return le.isLeader
+}
+
+// Run blocks until stopCh is closed. OnStartedLeading is called once the
+// lease is acquired, OnStoppedLeading is called if the lease can not be
+// renewed within RenewDeadline, then the elector becomes a candidate again.
+func (le *LeaderElector) Run(stopCh <-chan struct{}) {
+	for {
+		if !le.acquire(stopCh) {
+			return
+		}
+		leaderStopCh := make(chan struct{})
+		if le.OnStartedLeading != nil {
+			go le.OnStartedLeading(leaderStopCh)
+		}
+		le.renew(stopCh)
+		close(leaderStopCh)
+		le.setLeader(false, le.leader)
+		if le.OnStoppedLeading != nil {
+			le.OnStoppedLeading()
+		}
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+	}
+}
+
+func (le *LeaderElector) acquire(stopCh <-chan struct{}) bool {
+	klog.Infof("LeaderElector.acquire: [%s] try to acquire lease [%s/%s]",
+		le.Config.Identity, le.Config.Namespace, le.Config.Name)
+	for {
+		err := le.tryAcquireOrRenew()
+		if err == nil {
+			klog.Infof("LeaderElector.acquire: [%s] become leader", le.Config.Identity)
+			return true
+		}
+		if err != ErrLeaseHeldByOthers {
+			klog.Warningf("LeaderElector.acquire: tryAcquireOrRenew err, error is [%v]", err)
+		}
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(le.Config.RetryPeriod):
+		}
+	}
+}
+
+func (le *LeaderElector) renew(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(le.Config.RetryPeriod):
+		}
+
+		err := le.tryAcquireOrRenew()
+		if err == nil {
+			continue
+		}
+		klog.Warningf("LeaderElector.renew: tryAcquireOrRenew err, error is [%v]", err)
+		if err == ErrLeaseHeldByOthers || time.Since(le.getLastRenew()) > le.Config.RenewDeadline {
+			klog.Errorf("LeaderElector.renew: [%s] lost leadership", le.Config.Identity)
+			return
+		}
+	}
+}
+
+func (le *LeaderElector) tryAcquireOrRenew() error {
+	now := metav1.NowMicro()
+	leaseDuration := int32(le.Config.LeaseDuration / time.Second)
+	identity := le.Config.Identity
+
+	lease, err := le.Lock.Get()
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("%v:get lease error", err)
+		}
+		transitions := int32(0)
+		lease = &Lease{Spec: LeaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &leaseDuration,
+			AcquireTime:          &now,
+			RenewTime:            &now,
+			LeaseTransitions:     &transitions,
+		}}
+		lease, err = le.Lock.Create(lease)
+		if err != nil {
+			return fmt.Errorf("%v:create lease error", err)
+		}
+		le.renewed(lease, identity, now.Time)
+		return nil
+	}
+
+	le.observe(lease, now.Time)
+	holder := ""
+	if lease.Spec.HolderIdentity != nil {
+		holder = *lease.Spec.HolderIdentity
+	}
+	if holder != "" && holder != identity && !le.isLeaseExpired(lease, now.Time) {
+		le.setLeader(false, holder)
+		return ErrLeaseHeldByOthers
+	}
+
+	if holder != identity {
+		transitions := int32(0)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.HolderIdentity = &identity
+		lease.Spec.AcquireTime = &now
+		lease.Spec.LeaseTransitions = &transitions
+	}
+	lease.Spec.LeaseDurationSeconds = &leaseDuration
+	lease.Spec.RenewTime = &now
+	lease, err = le.Lock.Update(lease)
+	if err != nil {
+		return fmt.Errorf("%v:update lease error", err)
+	}
+	le.renewed(lease, identity, now.Time)
+	return nil
+}
+
+func (le *LeaderElector) renewed(lease *Lease, identity string, now time.Time) {
+	le.observe(lease, now)
+	le.setLeader(true, identity)
+	le.lock.Lock()
+	le.lastRenew = now
+	le.lock.Unlock()
+}
+
+// observe records the local time when the lease is seen changed, the expiry
+// of the lease held by others is judged by the local clock to avoid skew.
+func (le *LeaderElector) observe(lease *Lease, now time.Time) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	if le.observedRV == "" || le.observedRV != lease.ResourceVersion {
+		le.observedRV = lease.ResourceVersion
+		le.observedTime = now
+	}
+}
+
+func (le *LeaderElector) isLeaseExpired(lease *Lease, now time.Time) bool {
+	leaseDuration := le.Config.LeaseDuration
+	if lease.Spec.LeaseDurationSeconds != nil {
+		leaseDuration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	le.lock.RLock()
+	defer le.lock.RUnlock()
+	return now.Sub(le.observedTime) > leaseDuration
+}
+
+func (le *LeaderElector) getLastRenew() time.Time {
+	le.lock.RLock()
+	defer le.lock.RUnlock()
+	return le.lastRenew
+}
+
+func (le *LeaderElector) setLeader(isLeader bool, leader string) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	if le.isLeader != isLeader || le.leader != leader {
+		le.lastTransition = time.Now()
+	}
+	le.isLeader = isLeader
+	le.leader = leader
+}
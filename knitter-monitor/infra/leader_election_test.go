@@ -0,0 +1,240 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antonholmquist/jason"
+	"github.com/smartystreets/goconvey/convey"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+type fakeLeaseLock struct {
+	lock      sync.Mutex
+	lease     *Lease
+	rv        int
+	updateErr error
+}
+
+func (fl *fakeLeaseLock) Get() (*Lease, error) {
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+	if fl.lease == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "lease")
+	}
+	lease := *fl.lease
+	return &lease, nil
+}
+
+func (fl *fakeLeaseLock) Create(lease *Lease) (*Lease, error) {
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+	if fl.lease != nil {
+		return nil, errors.New("already exists")
+	}
+	return fl.save(lease), nil
+}
+
+func (fl *fakeLeaseLock) Update(lease *Lease) (*Lease, error) {
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+	if fl.updateErr != nil {
+		return nil, fl.updateErr
+	}
+	if lease.ResourceVersion != fl.lease.ResourceVersion {
+		return nil, errors.New("conflict")
+	}
+	return fl.save(lease), nil
+}
+
+func (fl *fakeLeaseLock) save(lease *Lease) *Lease {
+	fl.rv++
+	saved := *lease
+	saved.ResourceVersion = strconv.Itoa(fl.rv)
+	fl.lease = &saved
+	result := saved
+	return &result
+}
+
+func (fl *fakeLeaseLock) holder() string {
+	fl.lock.Lock()
+	defer fl.lock.Unlock()
+	if fl.lease == nil || fl.lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *fl.lease.Spec.HolderIdentity
+}
+
+func newTestLeaderElectionConfig(identity string) *LeaderElectionConfig {
+	return &LeaderElectionConfig{
+		Enabled:       true,
+		Identity:      identity,
+		LeaseDuration: 300 * time.Millisecond,
+		RenewDeadline: 200 * time.Millisecond,
+		RetryPeriod:   20 * time.Millisecond,
+	}
+}
+
+func TestNewLeaderElectionConfig(t *testing.T) {
+	convey.Convey("TestNewLeaderElectionConfig", t, func() {
+		confObj, _ := jason.NewObjectFromBytes([]byte(`{"leader_election": {"enabled": true,
+			"namespace": "knitter", "lease_duration": "30", "renew_deadline": "20", "retry_period": "5"}}`))
+		cfg := NewLeaderElectionConfig(confObj)
+		convey.So(cfg.Enabled, convey.ShouldBeTrue)
+		convey.So(cfg.Namespace, convey.ShouldEqual, "knitter")
+		convey.So(cfg.Name, convey.ShouldEqual, DefaultLeaseName)
+		convey.So(cfg.LeaseDuration, convey.ShouldEqual, 30*time.Second)
+		convey.So(cfg.RenewDeadline, convey.ShouldEqual, 20*time.Second)
+		convey.So(cfg.RetryPeriod, convey.ShouldEqual, 5*time.Second)
+		convey.So(cfg.Identity, convey.ShouldNotBeEmpty)
+
+		confObj, _ = jason.NewObjectFromBytes([]byte(`{"leader_election": {"lease_duration": "5"}}`))
+		cfg = NewLeaderElectionConfig(confObj)
+		convey.So(cfg.Enabled, convey.ShouldBeFalse)
+		convey.So(cfg.LeaseDuration, convey.ShouldEqual, DefaultLeaseDurationInSec*time.Second)
+	})
+}
+
+func TestLeaderElector_TryAcquireOrRenew(t *testing.T) {
+	convey.Convey("TestLeaderElector_TryAcquireOrRenew", t, func() {
+		lock := &fakeLeaseLock{}
+		le1 := NewLeaderElector(newTestLeaderElectionConfig("monitor-1"), lock)
+		le2 := NewLeaderElector(newTestLeaderElectionConfig("monitor-2"), lock)
+
+		convey.So(le1.tryAcquireOrRenew(), convey.ShouldBeNil)
+		convey.So(le1.IsLeader(), convey.ShouldBeTrue)
+		convey.So(lock.holder(), convey.ShouldEqual, "monitor-1")
+
+		convey.So(le2.tryAcquireOrRenew(), convey.ShouldEqual, ErrLeaseHeldByOthers)
+		convey.So(le2.IsLeader(), convey.ShouldBeFalse)
+		convey.So(le2.Status().Leader, convey.ShouldEqual, "monitor-1")
+
+		convey.So(le1.tryAcquireOrRenew(), convey.ShouldBeNil)
+		convey.So(le2.tryAcquireOrRenew(), convey.ShouldEqual, ErrLeaseHeldByOthers)
+
+		time.Sleep(350 * time.Millisecond)
+		convey.So(le2.tryAcquireOrRenew(), convey.ShouldBeNil)
+		convey.So(le2.IsLeader(), convey.ShouldBeTrue)
+		convey.So(lock.holder(), convey.ShouldEqual, "monitor-2")
+		convey.So(*lock.lease.Spec.LeaseTransitions, convey.ShouldEqual, 1)
+	})
+}
+
+func TestLeaderElector_Run(t *testing.T) {
+	convey.Convey("TestLeaderElector_Run", t, func() {
+		lock := &fakeLeaseLock{}
+		le := NewLeaderElector(newTestLeaderElectionConfig("monitor-1"), lock)
+		started := make(chan struct{})
+		workerStopped := make(chan struct{})
+		stopped := make(chan struct{}, 1)
+		le.OnStartedLeading = func(stopCh <-chan struct{}) {
+			close(started)
+			<-stopCh
+			close(workerStopped)
+		}
+		le.OnStoppedLeading = func() {
+			stopped <- struct{}{}
+		}
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go le.Run(stopCh)
+
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+		}
+		convey.So(le.IsLeader(), convey.ShouldBeTrue)
+
+		lock.lock.Lock()
+		lock.updateErr = errors.New("apiserver unavailable")
+		lock.lock.Unlock()
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+		}
+		convey.So(le.IsLeader(), convey.ShouldBeFalse)
+		_, ok := <-workerStopped
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
+func TestGetLeaderElectionStatusWithoutElector(t *testing.T) {
+	convey.Convey("TestGetLeaderElectionStatusWithoutElector", t, func() {
+		SetLeaderElector(nil)
+		status := GetLeaderElectionStatus()
+		convey.So(status.Enabled, convey.ShouldBeFalse)
+		convey.So(status.IsLeader, convey.ShouldBeTrue)
+	})
+}
+
+func TestLeaseLock(t *testing.T) {
+	var stored []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/coordination.k8s.io/v1/namespaces/kube-system/leases/knitter-monitor":
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure",
+					"reason": "NotFound", "code": 404}`))
+				return
+			}
+			w.Write(stored)
+		case r.Method == http.MethodPost && r.URL.Path == "/apis/coordination.k8s.io/v1/namespaces/kube-system/leases":
+			body, _ := ioutil.ReadAll(r.Body)
+			lease := &Lease{}
+			json.Unmarshal(body, lease)
+			lease.ResourceVersion = "1"
+			stored, _ = json.Marshal(lease)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(stored)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+
+	convey.Convey("TestLeaseLock", t, func() {
+		clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		convey.So(err, convey.ShouldBeNil)
+		lock := &LeaseLock{Client: clientSet.CoreV1().RESTClient(), Namespace: "kube-system", Name: "knitter-monitor"}
+
+		_, err = lock.Get()
+		convey.So(apierrors.IsNotFound(err), convey.ShouldBeTrue)
+
+		holder := "monitor-1"
+		lease, err := lock.Create(&Lease{Spec: LeaseSpec{HolderIdentity: &holder}})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(lease.ResourceVersion, convey.ShouldEqual, "1")
+		convey.So(lease.Kind, convey.ShouldEqual, "Lease")
+
+		lease, err = lock.Get()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(*lease.Spec.HolderIdentity, convey.ShouldEqual, "monitor-1")
+	})
+}
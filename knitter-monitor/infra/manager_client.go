@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/antonholmquist/jason"
@@ -20,6 +22,10 @@ import (
 
 var managerClient *ManagerClient
 
+// managerNetworkNotExistMsg prefixes the message of knitter-manager when the
+// queried network does not exist
+const managerNetworkNotExistMsg = "network not exist"
+
 type ManagerClient struct {
 	// Header *http.Header
 	URLKnitterManager string
@@ -118,11 +124,23 @@ func (mc *ManagerClient) PostBytes(postURL string, postData []byte) (int, []byte
 	return statuscode, body, nil
 }
 
+func (mc *ManagerClient) PutBytes(putURL string, putData []byte) (int, []byte, error) {
+	resp, err := HTTPPut(putURL, "application/json", putData)
+	klog.Infof("ManagerClient.PutBytes:putURL is [%v]", putURL)
+	if err != nil {
+		klog.Errorf("masterclient put error! -%v", err)
+		return http.StatusInternalServerError, nil, err
+	}
+	defer HTTPClose(resp)
+	body, _ := HTTPReadAll(resp)
+	return resp.StatusCode, body, nil
+}
+
 func (mc *ManagerClient) Delete(deleteURL string) (b []byte, statusCode int, e error) {
 	resp, err := HTTPDelete(deleteURL)
 	if err != nil {
 		klog.Errorf("##masterclient delete http.DefaultClient.Do error! -%v", err)
-		return nil, resp.StatusCode, err
+		return nil, http.StatusInternalServerError, err
 	}
 	defer HTTPClose(resp)
 	body, _ := HTTPReadAll(resp)
@@ -162,6 +180,54 @@ func (mc *ManagerClient) CheckKnitterManager() error {
 	return nil
 }
 
+// CreateTenant creates the tenant with its default network in
+// knitter-manager, it is not an error if the tenant already exists.
+func (mc *ManagerClient) CreateTenant(tenantID string) error {
+	postURL := mc.GetTenantURL(tenantID)
+	klog.Infof("CreateTenant: Post url: %s", postURL)
+	statusCode, rspByte, err := mc.PostBytes(postURL, nil)
+	if err != nil {
+		klog.Errorf("CreateTenant: mc.PostBytes(postURL: %s) error! -%v", postURL, err)
+		return fmt.Errorf("%v:CreateTenant: mc.PostBytes error", err)
+	}
+	if statusCode == http.StatusConflict {
+		klog.Infof("CreateTenant: tenant[%s] already exists", tenantID)
+		return nil
+	}
+	if !IsHttpMethodStatusSuccess(statusCode) {
+		klog.Errorf("CreateTenant: mc.PostBytes(postURL: %s) ok, but return status code is %v, message: %v",
+			postURL, statusCode, errobj.GetErrMsg(rspByte))
+		return fmt.Errorf("CreateTenant: create tenant[%s] return status code: %v, message: %v",
+			tenantID, statusCode, errobj.GetErrMsg(rspByte))
+	}
+	return nil
+}
+
+// CancelTenant deletes the tenant and its networks in knitter-manager
+// asynchronously, it is not an error if the tenant does not exist or is
+// being deleted.
+func (mc *ManagerClient) CancelTenant(tenantID string) error {
+	deleteURL := mc.GetTenantURL(tenantID)
+	klog.Infof("CancelTenant: delete url: %s", deleteURL)
+	rspByte, statusCode, err := mc.Delete(deleteURL)
+	if err != nil {
+		klog.Errorf("CancelTenant: mc.Delete(deleteURL: %s) error! -%v", deleteURL, err)
+		return fmt.Errorf("%v:CancelTenant: mc.Delete error", err)
+	}
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict:
+		klog.Infof("CancelTenant: tenant[%s] not exist or being deleted, status code: %v", tenantID, statusCode)
+		return nil
+	}
+	if !IsHttpMethodStatusSuccess(statusCode) {
+		klog.Errorf("CancelTenant: mc.Delete(deleteURL: %s) ok, but return status code is %v, message: %v",
+			deleteURL, statusCode, errobj.GetErrMsg(rspByte))
+		return fmt.Errorf("CancelTenant: cancel tenant[%s] return status code: %v, message: %v",
+			tenantID, statusCode, errobj.GetErrMsg(rspByte))
+	}
+	return nil
+}
+
 func (mc *ManagerClient) DeleteNeutronPort(tenantID string, portID string) (e error) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -278,6 +344,24 @@ func post(url string, contentType string, postData []byte) (*http.Response, erro
 	return response, nil
 }
 
+func put(url string, contentType string, putData []byte) (*http.Response, error) {
+	client := &http.Client{Timeout: constvalue.HTTPDefaultTimeoutInSec * time.Second}
+	request, err := http.NewRequest("PUT", url, bytes.NewReader(putData))
+	if err != nil {
+		klog.Error("Put: http NewRequest error: ", err.Error())
+		return nil, fmt.Errorf("%v:http NewRequest error", err)
+	}
+
+	request.Header.Set("Content-Type", contentType)
+	response, err := client.Do(request)
+	if err != nil {
+		klog.Error("Put: client.Do error: ", err.Error())
+		return nil, errors.New("http client.Do error")
+	}
+
+	return response, nil
+}
+
 func NewGUID(podID string) string {
 	return podID
 }
@@ -306,6 +390,19 @@ var HTTPPost = func(url string, bodyType string, postData []byte) (*http.Respons
 	return resp, err
 }
 
+var HTTPPut = func(url string, bodyType string, putData []byte) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	for i := 1; i < MaxRetryTimesForHTTPReq; i++ {
+		resp, err = put(url, bodyType, putData)
+		if err == nil {
+			return resp, nil
+		}
+		time.Sleep(time.Second * time.Duration(i))
+	}
+	return resp, err
+}
+
 func MakeURLReqIDSuffix(reqID string) string {
 	return "?" + "req_id=" + reqID
 }
@@ -325,6 +422,11 @@ type ManagerCreatePortReq struct {
 	FixIP       string `json:"ip_addr"`
 	ClusterID   string `json:"cluster_id"`
 	IPGroupName string `json:"ip_group_name"`
+	FixMac      string `json:"mac_addr,omitempty"`
+
+	SecurityGroups      []string            `json:"security_groups,omitempty"`
+	PortSecurityEnabled *bool               `json:"port_security_enabled,omitempty"`
+	AllowedAddressPairs []ports.AddressPair `json:"allowed_address_pairs,omitempty"`
 }
 
 type CreatePortInfo struct {
@@ -367,3 +469,218 @@ func (mc *ManagerClient) GetDefaultNetWork(tenantID string) (string, error) {
 func (mc ManagerClient) GetDefaultNetworkURL(tenantID string) string {
 	return mc.GetTenantURL(tenantID) + "/networks/placeholder" + "?default=true"
 }
+
+func (mc *ManagerClient) GetNetworkURL(tenantID, networkName string) string {
+	return mc.GetTenantURL(tenantID) + "/networks/" + networkName + "?default=false"
+}
+
+func (mc *ManagerClient) GetIPGroupsURL(tenantID, networkID string) string {
+	return mc.GetTenantURL(tenantID) + "/ipgroups?network_id=" + networkID
+}
+
+func (mc *ManagerClient) GetIPGroupURL(tenantID, groupID string) string {
+	return mc.GetTenantURL(tenantID) + "/ipgroups/" + groupID
+}
+
+// GetNetworkID returns the id of the network which the tenant can use,
+// ErrTenantNotRegistered or ErrNetworkNotExist is returned if it is unknown.
+func (mc *ManagerClient) GetNetworkID(tenantID, networkName string) (string, error) {
+	statusCode, body, err := mc.Get(mc.GetNetworkURL(tenantID, networkName))
+	if err != nil {
+		klog.Errorf("GetNetworkID: mc.Get network[%v] of tenant[%v] error: %v", networkName, tenantID, err)
+		return "", err
+	}
+	switch statusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return "", errobj.ErrTenantNotRegistered
+	case http.StatusNotFound:
+		klog.Warningf("GetNetworkID: network[%v] of tenant[%v] not found, message: %v",
+			networkName, tenantID, errobj.GetErrMsg(body))
+		return "", errobj.ErrNetworkNotExist
+	case http.StatusNotAcceptable:
+		// knitter-manager answers 406 for every error of the network query,
+		// only its not-exist message means the network is unknown
+		msg := errobj.GetErrMsg(body)
+		if strings.HasPrefix(msg, managerNetworkNotExistMsg) {
+			klog.Warningf("GetNetworkID: network[%v] of tenant[%v] not found, message: %v",
+				networkName, tenantID, msg)
+			return "", errobj.ErrNetworkNotExist
+		}
+		klog.Errorf("GetNetworkID: get network[%v] of tenant[%v] error: %v", networkName, tenantID, msg)
+		return "", fmt.Errorf("%v:%v:get network error", statusCode, msg)
+	default:
+		return "", fmt.Errorf("%v:get network return code error", statusCode)
+	}
+
+	networkJSON, err := jason.NewObjectFromBytes(body)
+	if err != nil {
+		klog.Errorf("GetNetworkID: jason.NewObjectFromBytes(%v) error: %v", string(body), err)
+		return "", errobj.ErrJasonNewObjectFailed
+	}
+	networkID, err := networkJSON.GetString("network_id")
+	if err != nil {
+		klog.Errorf("GetNetworkID: get network_id error: %v", err)
+		return "", errobj.ErrJasonGetStringFailed
+	}
+	return networkID, nil
+}
+
+// GetIPGroupNames returns the names of ip groups in the network which the
+// tenant can use.
+func (mc *ManagerClient) GetIPGroupNames(tenantID, networkID string) ([]string, error) {
+	statusCode, body, err := mc.Get(mc.GetIPGroupsURL(tenantID, networkID))
+	if err != nil {
+		klog.Errorf("GetIPGroupNames: mc.Get ip groups of network[%v] error: %v", networkID, err)
+		return nil, err
+	}
+	if statusCode == http.StatusUnauthorized {
+		return nil, errobj.ErrTenantNotRegistered
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v:get ip groups return code error", statusCode)
+	}
+
+	var igs struct {
+		IPGroups []struct {
+			Name string `json:"name"`
+		} `json:"ipgroups"`
+	}
+	err = json.Unmarshal(body, &igs)
+	if err != nil {
+		klog.Errorf("GetIPGroupNames: json.Unmarshal(%v) error: %v", string(body), err)
+		return nil, err
+	}
+	names := make([]string, 0, len(igs.IPGroups))
+	for _, ig := range igs.IPGroups {
+		names = append(names, ig.Name)
+	}
+	return names, nil
+}
+
+// IPGroup is the ip group in knitter-manager, the ips are ordered by their
+// creation.
+type IPGroup struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	NetworkID string      `json:"network_id"`
+	IPs       []IPGroupIP `json:"ips"`
+}
+
+type IPGroupIP struct {
+	IPAddr string `json:"ip_addr"`
+	Used   bool   `json:"used"`
+}
+
+type ipGroupReq struct {
+	Name      string `json:"name,omitempty"`
+	IPs       string `json:"ips,omitempty"`
+	NetworkID string `json:"network_id,omitempty"`
+	Size      string `json:"size,omitempty"`
+}
+
+// CreateIPGroup creates the ip group with size ips in the network.
+func (mc *ManagerClient) CreateIPGroup(tenantID, networkID, name string, size int) (*IPGroup, error) {
+	req := ipGroupReq{Name: name, NetworkID: networkID, Size: strconv.Itoa(size)}
+	return mc.operateIPGroup("POST", mc.GetTenantURL(tenantID)+"/ipgroups", req)
+}
+
+// ResizeIPGroup adds ips to the group until it has size ips.
+func (mc *ManagerClient) ResizeIPGroup(tenantID, groupID string, size int) (*IPGroup, error) {
+	req := ipGroupReq{Size: strconv.Itoa(size)}
+	return mc.operateIPGroup("PUT", mc.GetIPGroupURL(tenantID, groupID), req)
+}
+
+// SetIPGroupIPs keeps the ips of the group and deletes the others, the ips
+// deleted must not be in use.
+func (mc *ManagerClient) SetIPGroupIPs(tenantID, groupID string, ips []string) (*IPGroup, error) {
+	req := ipGroupReq{IPs: "[" + strings.Join(ips, ",") + "]"}
+	return mc.operateIPGroup("PUT", mc.GetIPGroupURL(tenantID, groupID), req)
+}
+
+func (mc *ManagerClient) GetIPGroup(tenantID, groupID string) (*IPGroup, error) {
+	statusCode, body, err := mc.Get(mc.GetIPGroupURL(tenantID, groupID))
+	if err != nil {
+		klog.Errorf("GetIPGroup: mc.Get ip group[%v] error: %v", groupID, err)
+		return nil, err
+	}
+	return parseIPGroupResp(statusCode, body)
+}
+
+// GetIPGroupByName returns nil if no group of the name is in the network.
+func (mc *ManagerClient) GetIPGroupByName(tenantID, networkID, name string) (*IPGroup, error) {
+	statusCode, body, err := mc.Get(mc.GetIPGroupsURL(tenantID, networkID))
+	if err != nil {
+		klog.Errorf("GetIPGroupByName: mc.Get ip groups of network[%v] error: %v", networkID, err)
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v:get ip groups return code error", statusCode)
+	}
+	var igs struct {
+		IPGroups []*IPGroup `json:"ipgroups"`
+	}
+	err = json.Unmarshal(body, &igs)
+	if err != nil {
+		klog.Errorf("GetIPGroupByName: json.Unmarshal(%v) error: %v", string(body), err)
+		return nil, err
+	}
+	for _, ig := range igs.IPGroups {
+		if ig.Name == name {
+			return ig, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteIPGroup deletes the ip group and its ips, it is not an error if the
+// group does not exist.
+func (mc *ManagerClient) DeleteIPGroup(tenantID, groupID string) error {
+	rspByte, statusCode, err := mc.Delete(mc.GetIPGroupURL(tenantID, groupID))
+	if err != nil {
+		klog.Errorf("DeleteIPGroup: mc.Delete ip group[%v] error: %v", groupID, err)
+		return err
+	}
+	if statusCode == http.StatusNotFound {
+		return nil
+	}
+	if !IsHttpMethodStatusSuccess(statusCode) {
+		return fmt.Errorf("%v:delete ip group[%v] return code error, message: %v",
+			statusCode, groupID, errobj.GetErrMsg(rspByte))
+	}
+	return nil
+}
+
+func (mc *ManagerClient) operateIPGroup(method, url string, req ipGroupReq) (*IPGroup, error) {
+	reqByte, err := json.Marshal(map[string]ipGroupReq{"ipgroup": req})
+	if err != nil {
+		return nil, err
+	}
+	var statusCode int
+	var body []byte
+	if method == "POST" {
+		statusCode, body, err = mc.PostBytes(url, reqByte)
+	} else {
+		statusCode, body, err = mc.PutBytes(url, reqByte)
+	}
+	if err != nil {
+		klog.Errorf("operateIPGroup: %v ip group[%v] error: %v", method, url, err)
+		return nil, err
+	}
+	return parseIPGroupResp(statusCode, body)
+}
+
+func parseIPGroupResp(statusCode int, body []byte) (*IPGroup, error) {
+	if !IsHttpMethodStatusSuccess(statusCode) {
+		return nil, fmt.Errorf("%v:ip group return code error, message: %v", statusCode, errobj.GetErrMsg(body))
+	}
+	var ig struct {
+		IPGroup *IPGroup `json:"ipgroup"`
+	}
+	err := json.Unmarshal(body, &ig)
+	if err != nil || ig.IPGroup == nil {
+		klog.Errorf("parseIPGroupResp: json.Unmarshal(%v) error: %v", string(body), err)
+		return nil, fmt.Errorf("%v:unmarshal ip group error", err)
+	}
+	return ig.IPGroup, nil
+}
@@ -5,9 +5,13 @@ import (
 	"github.com/antonholmquist/jason"
 	"github.com/golang/gostub"
 	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
 )
 
 func TestInitClient(t *testing.T) {
@@ -336,3 +340,145 @@ func TestCheckKnitterManagerErr4(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 }
+
+func TestGetNetworkIDAndIPGroupNames(t *testing.T) {
+	var ipGroupsQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/tenants/admin/networks/net_api":
+			w.Write([]byte(`{"name": "net_api", "network_id": "net-api-id"}`))
+		case "/api/v1/tenants/admin/networks/net_unknown":
+			w.WriteHeader(http.StatusNotAcceptable)
+			w.Write([]byte(`{"ERROR": "Proccess error", "message": "network not exist:Get network info error"}`))
+		case "/api/v1/tenants/admin/networks/net_busy":
+			w.WriteHeader(http.StatusNotAcceptable)
+			w.Write([]byte(`{"ERROR": "Proccess error", "message": "etcd cluster is unavailable:Get network info error"}`))
+		case "/api/v1/tenants/admin/ipgroups":
+			ipGroupsQuery = r.URL.RawQuery
+			w.Write([]byte(`{"ipgroups": [{"name": "ig1", "id": "ig1-id"}, {"name": "ig2", "id": "ig2-id"}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+	stubs := gostub.Stub(&HTTPGet, get)
+	defer stubs.Reset()
+	stubs.Stub(&HTTPClose, func(resp *http.Response) error { return resp.Body.Close() })
+	stubs.Stub(&HTTPReadAll, func(resp *http.Response) ([]byte, error) { return ioutil.ReadAll(resp.Body) })
+	mc := &ManagerClient{URLKnitterManager: server.URL + "/api/v1"}
+
+	Convey("TestGetNetworkIDAndIPGroupNames\n", t, func() {
+		networkID, err := mc.GetNetworkID("admin", "net_api")
+		So(err, ShouldBeNil)
+		So(networkID, ShouldEqual, "net-api-id")
+
+		_, err = mc.GetNetworkID("admin", "net_unknown")
+		So(err, ShouldEqual, errobj.ErrNetworkNotExist)
+
+		_, err = mc.GetNetworkID("admin", "net_busy")
+		So(err, ShouldNotBeNil)
+		So(err, ShouldNotEqual, errobj.ErrNetworkNotExist)
+
+		_, err = mc.GetNetworkID("tenant1", "net_api")
+		So(err, ShouldEqual, errobj.ErrTenantNotRegistered)
+
+		names, err := mc.GetIPGroupNames("admin", "net-api-id")
+		So(err, ShouldBeNil)
+		So(names, ShouldResemble, []string{"ig1", "ig2"})
+		So(ipGroupsQuery, ShouldEqual, "network_id=net-api-id")
+	})
+}
+
+func TestCreateAndCancelTenant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/tenants/ns1":
+			w.WriteHeader(http.StatusOK)
+		case "/api/v1/tenants/ns2":
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"ERROR": "Internal Server Error", "message": "etcd error"}`))
+		}
+	}))
+	defer server.Close()
+	stubs := gostub.Stub(&HTTPPost, post)
+	defer stubs.Reset()
+	stubs.Stub(&HTTPDelete, Delete)
+	stubs.Stub(&HTTPClose, func(resp *http.Response) error { return resp.Body.Close() })
+	stubs.Stub(&HTTPReadAll, func(resp *http.Response) ([]byte, error) { return ioutil.ReadAll(resp.Body) })
+	mc := &ManagerClient{URLKnitterManager: server.URL + "/api/v1"}
+
+	Convey("TestCreateAndCancelTenant\n", t, func() {
+		So(mc.CreateTenant("ns1"), ShouldBeNil)
+		So(mc.CreateTenant("ns2"), ShouldBeNil)
+		err := mc.CreateTenant("ns3")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "etcd error")
+
+		So(mc.CancelTenant("ns1"), ShouldBeNil)
+		So(mc.CancelTenant("ns2"), ShouldBeNil)
+		So(mc.CancelTenant("ns3"), ShouldNotBeNil)
+	})
+}
+
+func TestIPGroupOperations(t *testing.T) {
+	requests := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests[r.Method+" "+r.URL.Path] = string(body)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/v1/tenants/ns1/ipgroups", "PUT /api/v1/tenants/ns1/ipgroups/ig1", "GET /api/v1/tenants/ns1/ipgroups/ig1":
+			w.Write([]byte(`{"ipgroup": {"id": "ig1", "name": "sts_ns1_web_net_api", "network_id": "net1",
+				"ips": [{"ip_addr": "10.0.0.2", "used": true}, {"ip_addr": "10.0.0.3", "used": false}]}}`))
+		case "GET /api/v1/tenants/ns1/ipgroups":
+			w.Write([]byte(`{"ipgroups": [{"id": "ig1", "name": "sts_ns1_web_net_api", "network_id": "net1"}]}`))
+		case "DELETE /api/v1/tenants/ns1/ipgroups/ig1":
+			w.WriteHeader(http.StatusNoContent)
+		case "DELETE /api/v1/tenants/ns1/ipgroups/ig2":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"ERROR": "Conflict", "message": "ip[10.0.0.2] is in use"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	stubs := gostub.Stub(&HTTPPost, post)
+	defer stubs.Reset()
+	stubs.Stub(&HTTPPut, put)
+	stubs.Stub(&HTTPGet, get)
+	stubs.Stub(&HTTPDelete, Delete)
+	stubs.Stub(&HTTPClose, func(resp *http.Response) error { return resp.Body.Close() })
+	stubs.Stub(&HTTPReadAll, func(resp *http.Response) ([]byte, error) { return ioutil.ReadAll(resp.Body) })
+	mc := &ManagerClient{URLKnitterManager: server.URL + "/api/v1"}
+
+	Convey("TestIPGroupOperations\n", t, func() {
+		ig, err := mc.CreateIPGroup("ns1", "net1", "sts_ns1_web_net_api", 2)
+		So(err, ShouldBeNil)
+		So(ig.ID, ShouldEqual, "ig1")
+		So(ig.IPs, ShouldResemble, []IPGroupIP{{IPAddr: "10.0.0.2", Used: true}, {IPAddr: "10.0.0.3"}})
+		So(requests["POST /api/v1/tenants/ns1/ipgroups"], ShouldEqual,
+			`{"ipgroup":{"name":"sts_ns1_web_net_api","network_id":"net1","size":"2"}}`)
+
+		_, err = mc.ResizeIPGroup("ns1", "ig1", 3)
+		So(err, ShouldBeNil)
+		So(requests["PUT /api/v1/tenants/ns1/ipgroups/ig1"], ShouldEqual, `{"ipgroup":{"size":"3"}}`)
+		_, err = mc.SetIPGroupIPs("ns1", "ig1", []string{"10.0.0.2", "10.0.0.3"})
+		So(err, ShouldBeNil)
+		So(requests["PUT /api/v1/tenants/ns1/ipgroups/ig1"], ShouldEqual, `{"ipgroup":{"ips":"[10.0.0.2,10.0.0.3]"}}`)
+
+		ig, err = mc.GetIPGroupByName("ns1", "net1", "sts_ns1_web_net_api")
+		So(err, ShouldBeNil)
+		So(ig.ID, ShouldEqual, "ig1")
+		ig, err = mc.GetIPGroupByName("ns1", "net1", "sts_ns1_db_net_api")
+		So(err, ShouldBeNil)
+		So(ig, ShouldBeNil)
+		_, err = mc.GetIPGroup("ns1", "ig3")
+		So(err, ShouldNotBeNil)
+
+		So(mc.DeleteIPGroup("ns1", "ig1"), ShouldBeNil)
+		So(mc.DeleteIPGroup("ns1", "ig3"), ShouldBeNil)
+		err = mc.DeleteIPGroup("ns1", "ig2")
+		So(err.Error(), ShouldContainSubstring, "is in use")
+	})
+}
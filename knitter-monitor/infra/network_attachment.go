@@ -0,0 +1,45 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+const networkAttachmentAPIPath = "/apis/k8s.cni.cncf.io/v1"
+
+// GetNetworkAttachmentDefinition gets the NetworkAttachmentDefinition through
+// the REST client, the error is NotFound if the definition or its CRD does
+// not exist.
+var GetNetworkAttachmentDefinition = func(nadNs, nadName string) (*netattachdef.NetworkAttachmentDefinition, error) {
+	if clientSet == nil {
+		return nil, errors.New("kubernetes clientset is nil")
+	}
+	body, err := clientSet.CoreV1().RESTClient().Get().
+		AbsPath(networkAttachmentAPIPath, "namespaces", nadNs, "network-attachment-definitions", nadName).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	nad := &netattachdef.NetworkAttachmentDefinition{}
+	err = json.Unmarshal(body, nad)
+	if err != nil {
+		klog.Errorf("GetNetworkAttachmentDefinition: json.Unmarshal(%s) err, error is [%v]", string(body), err)
+		return nil, err
+	}
+	return nad, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestGetNetworkAttachmentDefinition(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/apis/k8s.cni.cncf.io/v1/namespaces/ns1/network-attachment-definitions/nad1" {
+			w.Write([]byte(`{"apiVersion": "k8s.cni.cncf.io/v1", "kind": "NetworkAttachmentDefinition",
+				"metadata": {"name": "nad1", "namespace": "ns1"},
+				"spec": {"config": "{\"name\": \"net1\", \"type\": \"knitter\"}"}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+	}))
+	defer server.Close()
+	oldClientSet := clientSet
+	defer func() { clientSet = oldClientSet }()
+
+	convey.Convey("TestGetNetworkAttachmentDefinition", t, func() {
+		var err error
+		clientSet, err = kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		convey.So(err, convey.ShouldBeNil)
+
+		nad, err := GetNetworkAttachmentDefinition("ns1", "nad1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(nad.Name, convey.ShouldEqual, "nad1")
+		convey.So(nad.GetKnitterNetworkName(), convey.ShouldEqual, "net1")
+
+		_, err = GetNetworkAttachmentDefinition("ns1", "nad2")
+		convey.So(apierrors.IsNotFound(err), convey.ShouldBeTrue)
+	})
+}
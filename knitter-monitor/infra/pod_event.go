@@ -0,0 +1,59 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/ZTE/Knitter/pkg/k8s-event"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// RecordPodEvent records an event on the pod, events are best effort so the
+// failures are only logged.
+var RecordPodEvent = func(pod *v1.Pod, eventType, reason, message string) {
+	if clientSet == nil {
+		klog.Warningf("RecordPodEvent: kubernetes clientset is nil, skip event[%v] of pod[%v/%v]", reason, pod.Namespace, pod.Name)
+		return
+	}
+	event := k8sevent.NewPodEvent(pod.Namespace, pod.Name, string(pod.UID), k8sevent.ComponentMonitor, "",
+		eventType, reason, message)
+	_, err := clientSet.CoreV1().Events(pod.Namespace).Create(event)
+	if err != nil {
+		klog.Warningf("RecordPodEvent: create event[%v] of pod[%v/%v] err, error is [%v]", reason, pod.Namespace, pod.Name, err)
+		return
+	}
+	klog.Infof("RecordPodEvent: create event[%v] of pod[%v/%v] SUCC", reason, pod.Namespace, pod.Name)
+}
+
+// SetPodNetworkReady sets the NetworkReady condition in status of the pod.
+var SetPodNetworkReady = func(pod *v1.Pod, ready bool, reason, message string) {
+	if clientSet == nil {
+		klog.Warningf("SetPodNetworkReady: kubernetes clientset is nil, skip pod[%v/%v]", pod.Namespace, pod.Name)
+		return
+	}
+	patch, err := k8sevent.NewNetworkReadyPatch(ready, reason, message)
+	if err != nil {
+		klog.Warningf("SetPodNetworkReady: k8sevent.NewNetworkReadyPatch err, error is [%v]", err)
+		return
+	}
+	_, err = clientSet.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.StrategicMergePatchType, patch, "status")
+	if err != nil {
+		klog.Warningf("SetPodNetworkReady: patch status of pod[%v/%v] err, error is [%v]", pod.Namespace, pod.Name, err)
+		return
+	}
+	klog.Infof("SetPodNetworkReady: set pod[%v/%v] %v to %v SUCC", pod.Namespace, pod.Name,
+		k8sevent.ConditionNetworkReady, ready)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/ZTE/Knitter/pkg/k8s-event"
+)
+
+func TestRecordPodEventAndSetPodNetworkReady(t *testing.T) {
+	var event v1.Event
+	var patch map[string]map[string][]v1.PodCondition
+	var patchType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/namespaces/admin/events":
+			json.Unmarshal(body, &event)
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		case r.Method == http.MethodPatch && r.URL.Path == "/api/v1/namespaces/admin/pods/pod1/status":
+			patchType = r.Header.Get("Content-Type")
+			json.Unmarshal(body, &patch)
+			w.Write([]byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "pod1", "namespace": "admin"}}`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer server.Close()
+	oldClientSet := clientSet
+	defer func() { clientSet = oldClientSet }()
+
+	convey.Convey("TestRecordPodEventAndSetPodNetworkReady", t, func() {
+		var err error
+		clientSet, err = kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		convey.So(err, convey.ShouldBeNil)
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin", UID: "pod1-uid"}}
+
+		RecordPodEvent(pod, v1.EventTypeWarning, k8sevent.ReasonIPExhausted, "no more ip addresses")
+		convey.So(event.Reason, convey.ShouldEqual, k8sevent.ReasonIPExhausted)
+		convey.So(event.Type, convey.ShouldEqual, v1.EventTypeWarning)
+		convey.So(event.InvolvedObject.UID, convey.ShouldEqual, pod.UID)
+		convey.So(event.InvolvedObject.Kind, convey.ShouldEqual, "Pod")
+		convey.So(event.Source.Component, convey.ShouldEqual, k8sevent.ComponentMonitor)
+
+		SetPodNetworkReady(pod, false, k8sevent.ReasonIPExhausted, "no more ip addresses")
+		convey.So(patchType, convey.ShouldEqual, "application/strategic-merge-patch+json")
+		conditions := patch["status"]["conditions"]
+		convey.So(len(conditions), convey.ShouldEqual, 1)
+		convey.So(conditions[0].Type, convey.ShouldEqual, k8sevent.ConditionNetworkReady)
+		convey.So(conditions[0].Status, convey.ShouldEqual, v1.ConditionFalse)
+		convey.So(conditions[0].Reason, convey.ShouldEqual, k8sevent.ReasonIPExhausted)
+	})
+}
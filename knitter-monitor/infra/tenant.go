@@ -0,0 +1,45 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// TenantConfig is the lifecycle of the tenants mapped from namespaces, the
+// tenants are managed through knitter-manager API by default.
+type TenantConfig struct {
+	// AutoCreate creates the tenant and its default network when a
+	// namespace appears
+	AutoCreate bool
+	// AutoCancel cancels the tenant when its namespace is deleted and no
+	// ports of the tenant remain
+	AutoCancel bool
+}
+
+var tenantConfig = &TenantConfig{}
+
+func InitTenantConfig(confObj *jason.Object) {
+	cfg := &TenantConfig{}
+	cfg.AutoCreate, _ = confObj.GetBoolean("tenant", "auto_create")
+	cfg.AutoCancel, _ = confObj.GetBoolean("tenant", "auto_cancel")
+	klog.Infof("InitTenantConfig: tenant config is [%+v]", *cfg)
+	tenantConfig = cfg
+}
+
+func GetTenantConfig() *TenantConfig {
+	return tenantConfig
+}
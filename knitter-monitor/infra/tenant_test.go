@@ -0,0 +1,35 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package infra
+
+import (
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestInitTenantConfig(t *testing.T) {
+	defer func() { tenantConfig = &TenantConfig{} }()
+	convey.Convey("TestInitTenantConfig", t, func() {
+		confObj, _ := jason.NewObjectFromBytes([]byte(`{"tenant": {"auto_create": true}}`))
+		InitTenantConfig(confObj)
+		convey.So(GetTenantConfig().AutoCreate, convey.ShouldBeTrue)
+		convey.So(GetTenantConfig().AutoCancel, convey.ShouldBeFalse)
+
+		confObj, _ = jason.NewObjectFromBytes([]byte(`{}`))
+		InitTenantConfig(confObj)
+		convey.So(*GetTenantConfig(), convey.ShouldResemble, TenantConfig{})
+	})
+}
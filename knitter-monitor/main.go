@@ -38,7 +38,16 @@ func main() {
 		return
 	}
 	var stopCh <-chan struct{}
-	go createPortForPodController.Run(1, stopCh)
+	leaderElectionConfig := infra.NewLeaderElectionConfig(confObj)
+	go services.RunCreatePortForPodController(createPortForPodController, leaderElectionConfig, 1, stopCh)
+
+	webhookConfig := infra.NewAdmissionWebhookConfig(confObj)
+	if webhookConfig.Enabled {
+		beego.BConfig.Listen.EnableHTTPS = true
+		beego.BConfig.Listen.HTTPSPort = webhookConfig.Port
+		beego.BConfig.Listen.HTTPSCertFile = webhookConfig.CertFile
+		beego.BConfig.Listen.HTTPSKeyFile = webhookConfig.KeyFile
+	}
 	beego.Run()
 	klog.Infof("main END")
 }
@@ -8,4 +8,6 @@ import (
 
 func init() {
 	beego.Router("/api/v1/pods/:podns/:podname", &controllers.PodController{})
+	beego.Router("/api/v1/health", &controllers.HealthController{})
+	beego.Router("/api/v1/admission/pods", &controllers.AdmissionController{}, "post:Post")
 }
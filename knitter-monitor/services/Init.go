@@ -51,6 +51,7 @@ func InitConfigurations4Monitor(confObj *jason.Object, kubeconfig string) error
 	}
 
 	waitManagerClient()
+	infra.InitTenantConfig(confObj)
 	return nil
 }
 
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/daos"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// BondAttr asks knitter-agent to make a Linux bond in pod for the port, the
+// bond enslaves one VF of each physnet for nic_type direct, or two veths
+// attached to br-int for nic_type normal.
+type BondAttr struct {
+	Mode     string   `json:"mode"`
+	Miimon   int      `json:"miimon"`
+	Physnets []string `json:"physnets,omitempty"`
+}
+
+func isBondMode(mode string) bool {
+	switch mode {
+	case constvalue.BondModeActiveBackup, constvalue.BondMode8023AD, constvalue.BondModeBalanceXor:
+		return true
+	}
+	return false
+}
+
+// transformBond parses the bond attribute as
+// {"mode": "active-backup", "miimon": 100, "physnets": ["physnet1", "physnet2"]},
+// mode and miimon are optional.
+func (ep *EagerPort) transformBond(portJSON *jason.Object, portType string) error {
+	bondObj, err := portJSON.GetObject("attributes", "bond")
+	if err != nil {
+		if _, errValue := portJSON.GetValue("attributes", "bond"); errValue == nil {
+			return errobj.ErrInvalidBond
+		}
+		return nil
+	}
+
+	bond := &BondAttr{Mode: constvalue.DefaultBondMode, Miimon: constvalue.DefaultBondMiimon}
+	if modeValue, err := bondObj.GetValue("mode"); err == nil {
+		bond.Mode, err = modeValue.String()
+		if err != nil || !isBondMode(bond.Mode) {
+			return errobj.ErrInvalidBondMode
+		}
+	}
+	if miimonValue, err := bondObj.GetValue("miimon"); err == nil {
+		miimon, err := miimonValue.Int64()
+		if err != nil || miimon < 0 {
+			return errobj.ErrInvalidBondMiimon
+		}
+		bond.Miimon = int(miimon)
+	}
+	if physnetsValue, err := bondObj.GetValue("physnets"); err == nil {
+		physnets, err := physnetsValue.Array()
+		if err != nil {
+			return errobj.ErrInvalidBondPhysnets
+		}
+		for _, physnetValue := range physnets {
+			physnet, err := physnetValue.String()
+			if err != nil || physnet == "" || isStringInSlice(physnet, bond.Physnets) {
+				return errobj.ErrInvalidBondPhysnets
+			}
+			bond.Physnets = append(bond.Physnets, physnet)
+		}
+	}
+
+	switch portType {
+	case "direct":
+		if len(bond.Physnets) != constvalue.BondSlaveNum {
+			klog.Errorf("transformBond: bond of direct port needs %d physnets, got %v",
+				constvalue.BondSlaveNum, bond.Physnets)
+			return errobj.ErrInvalidBondPhysnets
+		}
+	case "normal":
+		if len(bond.Physnets) != 0 {
+			klog.Errorf("transformBond: bond of normal port is made of veths, physnets %v is illegal", bond.Physnets)
+			return errobj.ErrInvalidBondPhysnets
+		}
+	default:
+		klog.Errorf("transformBond: bond of nic_type[%s] port is unsupported", portType)
+		return errobj.ErrBondUnsupportedNicType
+	}
+	ep.Bond = bond
+	return nil
+}
+
+func newBondForDB(bond *BondAttr) *daos.BondForDB {
+	if bond == nil {
+		return nil
+	}
+	return &daos.BondForDB{Mode: bond.Mode, Miimon: bond.Miimon, Physnets: bond.Physnets}
+}
+
+func newBondFromBondForDB(db *daos.BondForDB) *BondAttr {
+	if db == nil {
+		return nil
+	}
+	return &BondAttr{Mode: db.Mode, Miimon: db.Miimon, Physnets: db.Physnets}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+)
+
+func TestEagerPort_TransformBond(t *testing.T) {
+	transform := func(attrs string) (*EagerPort, error) {
+		portJSON, _ := jason.NewObjectFromBytes([]byte(
+			`{"attach_to_network": "net_api", "attributes": {"nic_name": "bond0"` + attrs + `}}`))
+		ep := &EagerPort{}
+		return ep, ep.Transform(portJSON)
+	}
+
+	convey.Convey("TestEagerPort_TransformBond", t, func() {
+		convey.Convey("no bond\n", func() {
+			ep, err := transform("")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ep.Bond, convey.ShouldBeNil)
+		})
+
+		convey.Convey("bond of veths with default mode and miimon\n", func() {
+			ep, err := transform(`, "bond": {}`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ep.Bond, convey.ShouldResemble, &BondAttr{Mode: "active-backup", Miimon: 100})
+		})
+
+		convey.Convey("bond of vfs\n", func() {
+			ep, err := transform(`, "nic_type": "direct",
+				"bond": {"mode": "802.3ad", "miimon": 50, "physnets": ["physnet1", "physnet2"]}`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ep.Bond, convey.ShouldResemble,
+				&BondAttr{Mode: "802.3ad", Miimon: 50, Physnets: []string{"physnet1", "physnet2"}})
+		})
+
+		convey.Convey("illegal bond\n", func() {
+			_, err := transform(`, "bond": "active-backup"`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidBond)
+			_, err = transform(`, "bond": {"mode": "balance-rr"}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidBondMode)
+			_, err = transform(`, "bond": {"miimon": -1}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidBondMiimon)
+			_, err = transform(`, "bond": {"physnets": ["physnet1", "physnet2"]}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidBondPhysnets)
+			_, err = transform(`, "nic_type": "direct", "bond": {"physnets": ["physnet1"]}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidBondPhysnets)
+			_, err = transform(`, "nic_type": "direct", "bond": {"physnets": ["physnet1", "physnet1"]}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidBondPhysnets)
+			_, err = transform(`, "nic_type": "physical", "bond": {}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrBondUnsupportedNicType)
+		})
+
+		convey.Convey("passed to db and agent\n", func() {
+			portJSON, _ := jason.NewObjectFromBytes([]byte(
+				`{"attach_to_network": "net_api", "attributes": {"bond": {"mode": "balance-xor"}}}`))
+			port := &Port{}
+			convey.So(port.fillPortEagerAttr("ns", "pod", portJSON), convey.ShouldBeNil)
+			portForDB := port.transferToPortForDB()
+			convey.So(portForDB.Bond.Mode, convey.ShouldEqual, "balance-xor")
+			convey.So(newPortFromPortForDB(portForDB).EagerAttr.Bond, convey.ShouldResemble, port.EagerAttr.Bond)
+		})
+	})
+}
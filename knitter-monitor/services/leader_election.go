@@ -0,0 +1,61 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"os"
+
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+var exitOnLostLeadership = func() {
+	klog.Flush()
+	os.Exit(1)
+}
+
+// RunCreatePortForPodController runs the pod workers only on the leader when
+// leader election is enabled, the informer runs on all replicas.
+func RunCreatePortForPodController(cpc *createPortForPodController, cfg *infra.LeaderElectionConfig,
+	workers int, stopCh <-chan struct{}) {
+	if cfg == nil || !cfg.Enabled {
+		cpc.Run(workers, stopCh)
+		return
+	}
+
+	lock := &infra.LeaseLock{
+		Client:    cpc.clientSet.CoreV1().RESTClient(),
+		Namespace: cfg.Namespace,
+		Name:      cfg.Name,
+	}
+	le := infra.NewLeaderElector(cfg, lock)
+	le.OnStartedLeading = func(leaderStopCh <-chan struct{}) {
+		klog.Infof("RunCreatePortForPodController: [%s] started leading, start pod workers", cfg.Identity)
+		cpc.RunWorkers(workers, leaderStopCh)
+	}
+	le.OnStoppedLeading = func() {
+		// workers may be processing a pod, exit and restart as a standby so
+		// that they never run together with the workers of the new leader
+		klog.Errorf("RunCreatePortForPodController: [%s] stopped leading, exit", cfg.Identity)
+		exitOnLostLeadership()
+	}
+	infra.SetLeaderElector(le)
+
+	err := cpc.RunInformer(stopCh)
+	if err != nil {
+		klog.Errorf("RunCreatePortForPodController: cpc.RunInformer err, error is [%v]", err)
+		return
+	}
+	le.Run(stopCh)
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// namespaceStore caches the namespaces watched by createPortForPodController,
+// pods are mapped to knitter tenants by their namespaces.
+var namespaceStore cache.Store
+
+// createdTenants records the tenants created by monitor, the ports of pods
+// are created only after their tenant.
+var createdTenants sync.Map
+
+// GetTenantIDOfNamespace returns the knitter tenant of the pods in the
+// namespace, which is the tenant annotation of the namespace, or the
+// namespace name if it is not annotated.
+func GetTenantIDOfNamespace(nsName string) string {
+	ns := getK8sNamespace(nsName)
+	if ns == nil {
+		return nsName
+	}
+	return getTenantIDOfK8sNamespace(ns)
+}
+
+func getTenantIDOfK8sNamespace(ns *v1.Namespace) string {
+	tenantID := ns.GetObjectMeta().GetAnnotations()[constvalue.NamespaceTenantAnnotation]
+	if tenantID == "" {
+		return ns.GetObjectMeta().GetName()
+	}
+	return tenantID
+}
+
+// getDefaultNetworkOfNamespace returns the default network annotation of the
+// namespace, it is empty if not annotated.
+func getDefaultNetworkOfNamespace(nsName string) string {
+	ns := getK8sNamespace(nsName)
+	if ns == nil {
+		return ""
+	}
+	return ns.GetObjectMeta().GetAnnotations()[constvalue.NamespaceDefaultNetworkAnnotation]
+}
+
+func getK8sNamespace(nsName string) *v1.Namespace {
+	if namespaceStore == nil {
+		return nil
+	}
+	obj, exists, err := namespaceStore.GetByKey(nsName)
+	if err != nil || !exists {
+		return nil
+	}
+	ns, ok := obj.(*v1.Namespace)
+	if !ok {
+		return nil
+	}
+	return ns
+}
+
+func isTenantOfAnyNamespace(tenantID string) bool {
+	if namespaceStore == nil {
+		return false
+	}
+	for _, obj := range namespaceStore.List() {
+		ns, ok := obj.(*v1.Namespace)
+		if ok && getTenantIDOfK8sNamespace(ns) == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureTenantCreated creates the tenant if tenants are created by monitor,
+// the first pods of a new namespace may come before createTenantWorker
+// creates its tenant.
+func ensureTenantCreated(tenantID string) error {
+	if !infra.GetTenantConfig().AutoCreate || tenantID == constvalue.PaaSTenantAdminDefaultUUID {
+		return nil
+	}
+	if _, ok := createdTenants.Load(tenantID); ok {
+		return nil
+	}
+	err := infra.GetManagerClient().CreateTenant(tenantID)
+	if err != nil {
+		klog.Errorf("ensureTenantCreated: infra.GetManagerClient().CreateTenant(tenantID:[%v]) err, error is [%v]", tenantID, err)
+		return err
+	}
+	createdTenants.Store(tenantID, true)
+	return nil
+}
+
+func (cpc *createPortForPodController) newNamespaceInformer() {
+	watchlist := cache.NewListWatchFromClient(cpc.clientSet.CoreV1().RESTClient(), "namespaces", v1.NamespaceAll,
+		fields.Everything())
+
+	cpc.nsStoreIndexer, cpc.nsController = cache.NewIndexerInformer(
+		watchlist,
+		&v1.Namespace{},
+		time.Second*0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    cpc.enqueueAddNamespace,
+			UpdateFunc: cpc.enqueueUpdateNamespace,
+			DeleteFunc: cpc.enqueueDeleteNamespace,
+		},
+		cache.Indexers{},
+	)
+	namespaceStore = cpc.nsStoreIndexer
+}
+
+func (cpc *createPortForPodController) enqueueAddNamespace(obj interface{}) {
+	ns, ok := obj.(*v1.Namespace)
+	if !ok || !infra.GetTenantConfig().AutoCreate {
+		return
+	}
+	tenantID := getTenantIDOfK8sNamespace(ns)
+	cpc.tenantsCreateQueue.Add(tenantID)
+	klog.Infof("enqueueAddNamespace: namespace[%v] tenant[%v]", ns.GetObjectMeta().GetName(), tenantID)
+}
+
+// enqueueUpdateNamespace handles the change of the tenant annotation, the
+// ports created before keep in the old tenant until their pods are deleted.
+func (cpc *createPortForPodController) enqueueUpdateNamespace(oldObj, newObj interface{}) {
+	oldNs, ok := oldObj.(*v1.Namespace)
+	if !ok {
+		return
+	}
+	newNs, ok := newObj.(*v1.Namespace)
+	if !ok {
+		return
+	}
+	oldTenantID := getTenantIDOfK8sNamespace(oldNs)
+	newTenantID := getTenantIDOfK8sNamespace(newNs)
+	if oldTenantID == newTenantID {
+		return
+	}
+	klog.Infof("enqueueUpdateNamespace: namespace[%v] tenant changed from [%v] to [%v]",
+		newNs.GetObjectMeta().GetName(), oldTenantID, newTenantID)
+	if infra.GetTenantConfig().AutoCreate {
+		cpc.tenantsCreateQueue.Add(newTenantID)
+	}
+	if infra.GetTenantConfig().AutoCancel {
+		cpc.tenantsCancelQueue.Add(oldTenantID)
+	}
+}
+
+func (cpc *createPortForPodController) enqueueDeleteNamespace(obj interface{}) {
+	if !infra.GetTenantConfig().AutoCancel {
+		return
+	}
+	ns, ok := obj.(*v1.Namespace)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			klog.Errorf("enqueueDeleteNamespace: couldn't get object from tombstone [%v]", obj)
+			return
+		}
+		ns, ok = tombstone.Obj.(*v1.Namespace)
+		if !ok {
+			klog.Errorf("enqueueDeleteNamespace: tombstone contained object that is not a namespace [%v]", obj)
+			return
+		}
+	}
+	tenantID := getTenantIDOfK8sNamespace(ns)
+	cpc.tenantsCancelQueue.Add(tenantID)
+	klog.Infof("enqueueDeleteNamespace: namespace[%v] tenant[%v]", ns.GetObjectMeta().GetName(), tenantID)
+}
+
+func (cpc *createPortForPodController) createTenantWorker() {
+	klog.Info("createTenantWorker start ")
+	workFunc := func() bool {
+		key, quit := cpc.tenantsCreateQueue.Get()
+		if quit {
+			return true
+		}
+		defer cpc.tenantsCreateQueue.Done(key)
+
+		err := cpc.createTenant(key.(string))
+		if err != nil {
+			klog.Warningf("createTenantWorker: cpc.createTenant(tenantID:[%v]) err, error is [%v], requeue it", key, err)
+			cpc.tenantsCreateQueue.AddRateLimited(key)
+			return false
+		}
+		cpc.tenantsCreateQueue.Forget(key)
+		return false
+	}
+	for {
+		if quit := workFunc(); quit {
+			klog.Infof("createTenantWorker shut down")
+			return
+		}
+	}
+}
+
+func (cpc *createPortForPodController) createTenant(tenantID string) error {
+	if !isTenantOfAnyNamespace(tenantID) {
+		klog.Infof("createTenant: no namespace of tenant[%v] exists, skip creating", tenantID)
+		return nil
+	}
+	return ensureTenantCreated(tenantID)
+}
+
+func (cpc *createPortForPodController) cancelTenantWorker() {
+	klog.Info("cancelTenantWorker start ")
+	workFunc := func() bool {
+		key, quit := cpc.tenantsCancelQueue.Get()
+		if quit {
+			return true
+		}
+		defer cpc.tenantsCancelQueue.Done(key)
+
+		err := cpc.cancelTenant(key.(string))
+		if err != nil {
+			klog.Warningf("cancelTenantWorker: cpc.cancelTenant(tenantID:[%v]) err, error is [%v], requeue it", key, err)
+			cpc.tenantsCancelQueue.AddRateLimited(key)
+			return false
+		}
+		cpc.tenantsCancelQueue.Forget(key)
+		return false
+	}
+	for {
+		if quit := workFunc(); quit {
+			klog.Infof("cancelTenantWorker shut down")
+			return
+		}
+	}
+}
+
+// cancelTenant cancels the tenant after the ports of its pods are deleted by
+// deletePodWorker, the tenant is kept while any namespace is mapped to it.
+func (cpc *createPortForPodController) cancelTenant(tenantID string) error {
+	if tenantID == constvalue.PaaSTenantAdminDefaultUUID {
+		return nil
+	}
+	if isTenantOfAnyNamespace(tenantID) {
+		klog.Infof("cancelTenant: tenant[%v] is still used by namespaces, skip cancelling", tenantID)
+		return nil
+	}
+	pods, err := GetPodService().GetAll()
+	if err != nil {
+		klog.Errorf("cancelTenant: GetPodService().GetAll() err, error is [%v]", err)
+		return err
+	}
+	for _, pod := range pods {
+		if pod.TenantId == tenantID && len(pod.Ports) > 0 {
+			return fmt.Errorf("ports of pod[%v/%v] remain in tenant[%v]", pod.PodNs, pod.PodName, tenantID)
+		}
+	}
+	createdTenants.Delete(tenantID)
+	return infra.GetManagerClient().CancelTenant(tenantID)
+}
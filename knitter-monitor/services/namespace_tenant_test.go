@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/smartystreets/goconvey/convey"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+)
+
+func newK8sNamespace(name string, annotations map[string]string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func newTestNamespaceStore(namespaces ...*v1.Namespace) cache.Store {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, ns := range namespaces {
+		store.Add(ns)
+	}
+	return store
+}
+
+func newTestTenantController() *createPortForPodController {
+	return &createPortForPodController{
+		tenantsCreateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "tenants"),
+		tenantsCancelQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "tenants"),
+	}
+}
+
+func TestGetTenantIDOfNamespace(t *testing.T) {
+	namespaceStore = newTestNamespaceStore(
+		newK8sNamespace("ns1", nil),
+		newK8sNamespace("ns2", map[string]string{
+			constvalue.NamespaceTenantAnnotation:         "tenant2",
+			constvalue.NamespaceDefaultNetworkAnnotation: "net2",
+		}))
+	defer func() { namespaceStore = nil }()
+
+	convey.Convey("TestGetTenantIDOfNamespace", t, func() {
+		convey.So(GetTenantIDOfNamespace("ns1"), convey.ShouldEqual, "ns1")
+		convey.So(GetTenantIDOfNamespace("ns2"), convey.ShouldEqual, "tenant2")
+		convey.So(GetTenantIDOfNamespace("ns3"), convey.ShouldEqual, "ns3")
+
+		convey.So(getDefaultNetworkOfNamespace("ns1"), convey.ShouldEqual, "")
+		convey.So(getDefaultNetworkOfNamespace("ns2"), convey.ShouldEqual, "net2")
+
+		convey.So(isTenantOfAnyNamespace("tenant2"), convey.ShouldBeTrue)
+		convey.So(isTenantOfAnyNamespace("ns2"), convey.ShouldBeFalse)
+	})
+}
+
+func TestNewDelaultNetworkMessageOfNamespace(t *testing.T) {
+	namespaceStore = newTestNamespaceStore(newK8sNamespace("ns1", map[string]string{
+		constvalue.NamespaceDefaultNetworkAnnotation: "net1",
+	}))
+	defer func() { namespaceStore = nil }()
+
+	convey.Convey("TestNewDelaultNetworkMessageOfNamespace", t, func() {
+		networkByte, err := GetDefaultNetworkConfig("ns1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(networkByte, convey.ShouldResemble, createDefaultNwbyte("net1"))
+	})
+}
+
+func TestEnqueueNamespace(t *testing.T) {
+	monkey.Patch(infra.GetTenantConfig, func() *infra.TenantConfig {
+		return &infra.TenantConfig{AutoCreate: true, AutoCancel: true}
+	})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestEnqueueNamespace", t, func() {
+		cpc := newTestTenantController()
+		ns := newK8sNamespace("ns1", nil)
+		cpc.enqueueAddNamespace(ns)
+		key, _ := cpc.tenantsCreateQueue.Get()
+		convey.So(key, convey.ShouldEqual, "ns1")
+
+		newNs := newK8sNamespace("ns1", map[string]string{constvalue.NamespaceTenantAnnotation: "tenant1"})
+		cpc.enqueueUpdateNamespace(ns, newNs)
+		key, _ = cpc.tenantsCreateQueue.Get()
+		convey.So(key, convey.ShouldEqual, "tenant1")
+		key, _ = cpc.tenantsCancelQueue.Get()
+		convey.So(key, convey.ShouldEqual, "ns1")
+
+		cpc.enqueueUpdateNamespace(newNs, newNs)
+		convey.So(cpc.tenantsCreateQueue.Len(), convey.ShouldEqual, 0)
+
+		cpc.enqueueDeleteNamespace(cache.DeletedFinalStateUnknown{Key: "ns1", Obj: newNs})
+		convey.So(cpc.tenantsCancelQueue.Len(), convey.ShouldEqual, 1)
+	})
+}
+
+func TestEnsureTenantCreated(t *testing.T) {
+	monkey.Patch(infra.GetTenantConfig, func() *infra.TenantConfig {
+		return &infra.TenantConfig{AutoCreate: true}
+	})
+	var created []string
+	var mc *infra.ManagerClient
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "CreateTenant", func(_ *infra.ManagerClient, tenantID string) error {
+		created = append(created, tenantID)
+		if tenantID == "tenant2" {
+			return errors.New("create tenant error")
+		}
+		return nil
+	})
+	defer monkey.UnpatchAll()
+	defer createdTenants.Delete("tenant1")
+
+	convey.Convey("TestEnsureTenantCreated", t, func() {
+		convey.So(ensureTenantCreated("tenant1"), convey.ShouldBeNil)
+		convey.So(ensureTenantCreated("tenant1"), convey.ShouldBeNil)
+		convey.So(ensureTenantCreated(constvalue.PaaSTenantAdminDefaultUUID), convey.ShouldBeNil)
+		convey.So(ensureTenantCreated("tenant2"), convey.ShouldNotBeNil)
+		convey.So(created, convey.ShouldResemble, []string{"tenant1", "tenant2"})
+	})
+}
+
+func TestCancelTenant(t *testing.T) {
+	namespaceStore = newTestNamespaceStore(newK8sNamespace("ns1", nil))
+	defer func() { namespaceStore = nil }()
+	pods := []*Pod{{TenantId: "ns2", PodNs: "ns2", PodName: "pod1", Ports: []*Port{{}}}}
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "GetAll", func(_ *podService) ([]*Pod, error) {
+		return pods, nil
+	})
+	var cancelled []string
+	var mc *infra.ManagerClient
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "CancelTenant", func(_ *infra.ManagerClient, tenantID string) error {
+		cancelled = append(cancelled, tenantID)
+		return nil
+	})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestCancelTenant", t, func() {
+		cpc := newTestTenantController()
+		convey.So(cpc.cancelTenant(constvalue.PaaSTenantAdminDefaultUUID), convey.ShouldBeNil)
+		convey.So(cpc.cancelTenant("ns1"), convey.ShouldBeNil)
+		convey.So(cpc.cancelTenant("ns2"), convey.ShouldNotBeNil)
+		convey.So(cancelled, convey.ShouldBeEmpty)
+
+		pods = []*Pod{}
+		convey.So(cpc.cancelTenant("ns2"), convey.ShouldBeNil)
+		convey.So(cancelled, convey.ShouldResemble, []string{"ns2"})
+	})
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+// GetNetworkConfigFromSelections converts the multus networks annotation to
+// the knitter networks message. Like multus, the selected networks are
+// attached besides the default network of the pod, unless one of them is
+// requested as eth0. Only a bad annotation is an InvalidNetworksError,
+// errors of fetching the definitions are returned as they are.
+func GetNetworkConfigFromSelections(podNs, selectionsStr string) ([]byte, error) {
+	elements, err := netattachdef.ParseNetworkSelections(selectionsStr, podNs)
+	if err != nil {
+		klog.Errorf("GetNetworkConfigFromSelections: ParseNetworkSelections(%v) err: %v", selectionsStr, err)
+		return nil, errobj.NewInvalidNetworksError(err)
+	}
+	bluePrintNetworkMessage, err := newNetworkMessageFromSelections(elements)
+	if err != nil {
+		klog.Errorf("GetNetworkConfigFromSelections: newNetworkMessageFromSelections err: %v", err)
+		return nil, err
+	}
+	if !bluePrintNetworkMessage.hasNic(constvalue.DefaultPortName) {
+		defaultNetworkMessage := &BluePrintNetworkMessage{}
+		err = defaultNetworkMessage.NewDelaultNetworkMessage(podNs)
+		if err != nil {
+			klog.Errorf("GetNetworkConfigFromSelections: NewDelaultNetworkMessage err: %v", err)
+			return nil, err
+		}
+		bluePrintNetworkMessage.Ports = append(defaultNetworkMessage.Ports, bluePrintNetworkMessage.Ports...)
+	}
+	networksByte, err := json.Marshal(bluePrintNetworkMessage)
+	if err != nil {
+		klog.Errorf("GetNetworkConfigFromSelections: json.Marshal(bluePrintNetworkMessage) err: %v", err)
+		return nil, err
+	}
+	return networksByte, nil
+}
+
+// newNetworkMessageFromSelections makes a port for each network selection,
+// interfaces are named net1, net2... if not requested, as multus does.
+func newNetworkMessageFromSelections(elements []*netattachdef.NetworkSelectionElement) (*BluePrintNetworkMessage, error) {
+	err := validateNetworkSelections(elements)
+	if err != nil {
+		return nil, errobj.NewInvalidNetworksError(err)
+	}
+	ports := make([]BluePrintPort, 0, len(elements))
+	for i, element := range elements {
+		networkName, err := getKnitterNetworkOfSelection(element)
+		if err != nil {
+			return nil, fmt.Errorf("%v:get network of %s/%s error", err, element.Namespace, element.Name)
+		}
+		nicName := element.InterfaceRequest
+		if nicName == "" {
+			nicName = fmt.Sprintf("net%d", i+1)
+		}
+		ipAddr := ""
+		if len(element.IPRequest) == 1 {
+			// ips are in CIDR notation, the prefix is decided by the subnet
+			ipAddr = strings.Split(element.IPRequest[0], "/")[0]
+		}
+		port := BluePrintPort{
+			AttachToNetwork: networkName,
+			Attributes: BluePrintAttributes{
+				Accelerate: constvalue.DefaultIsAccelerate,
+				Function:   constvalue.DefaultNetworkPlane,
+				NicName:    nicName,
+				NicType:    constvalue.DefaultVnicType,
+				IPAddr:     ipAddr,
+				MacAddr:    element.MacRequest,
+				Metadata: map[string]string{
+					netattachdef.PortMetadataKey: element.Namespace + "/" + element.Name,
+				},
+			},
+		}
+		ports = append(ports, port)
+	}
+	return &BluePrintNetworkMessage{Ports: ports}, nil
+}
+
+// validateNetworkSelections checks the requests of selections which knitter
+// does not support.
+func validateNetworkSelections(elements []*netattachdef.NetworkSelectionElement) error {
+	for _, element := range elements {
+		if len(element.IPRequest) > 1 {
+			return fmt.Errorf("ips of %s/%s is illegal: only one ip is supported", element.Namespace, element.Name)
+		}
+	}
+	return nil
+}
+
+// getKnitterNetworkOfSelection maps the selected NetworkAttachmentDefinition
+// to knitter network, the name of selection is taken as knitter network if
+// the definition does not exist.
+func getKnitterNetworkOfSelection(element *netattachdef.NetworkSelectionElement) (string, error) {
+	nad, err := infra.GetNetworkAttachmentDefinition(element.Namespace, element.Name)
+	if apierrors.IsNotFound(err) {
+		klog.Infof("getKnitterNetworkOfSelection: NetworkAttachmentDefinition %s/%s not found, use it as knitter network",
+			element.Namespace, element.Name)
+		return element.Name, nil
+	}
+	if err != nil {
+		klog.Errorf("getKnitterNetworkOfSelection: GetNetworkAttachmentDefinition(%s/%s) err: %v",
+			element.Namespace, element.Name, err)
+		return "", err
+	}
+	return nad.GetKnitterNetworkName(), nil
+}
+
+func (bpnm *BluePrintNetworkMessage) hasNic(nicName string) bool {
+	for _, port := range bpnm.Ports {
+		if port.Attributes.NicName == nicName {
+			return true
+		}
+	}
+	return false
+}
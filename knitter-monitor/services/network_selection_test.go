@@ -0,0 +1,103 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/gostub"
+	"github.com/smartystreets/goconvey/convey"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+func stubGetNetworkAttachmentDefinition() *gostub.Stubs {
+	return gostub.Stub(&infra.GetNetworkAttachmentDefinition,
+		func(nadNs, nadName string) (*netattachdef.NetworkAttachmentDefinition, error) {
+			if nadName == "nad_api" {
+				nad := &netattachdef.NetworkAttachmentDefinition{}
+				nad.Name = nadName
+				nad.Namespace = nadNs
+				nad.Spec.Config = `{"cniVersion": "0.3.1", "type": "knitter", "attach_to_network": "net_api"}`
+				return nad, nil
+			}
+			return nil, apierrors.NewNotFound(schema.GroupResource{}, nadName)
+		})
+}
+
+func TestGetNetworkConfigFromSelections(t *testing.T) {
+	stubs := stubGetNetworkAttachmentDefinition()
+	defer stubs.Reset()
+	namespaceStore = newTestNamespaceStore(newK8sNamespace("ns1",
+		map[string]string{constvalue.NamespaceDefaultNetworkAnnotation: "net_ns1"}))
+	defer func() { namespaceStore = nil }()
+
+	convey.Convey("TestGetNetworkConfigFromSelections\n", t, func() {
+		convey.Convey("default network is attached besides the selected networks\n", func() {
+			networksByte, err := GetNetworkConfigFromSelections("ns1", "nad_api, net_media@media")
+			convey.So(err, convey.ShouldBeNil)
+			bpnm := &BluePrintNetworkMessage{}
+			convey.So(json.Unmarshal(networksByte, bpnm), convey.ShouldBeNil)
+			convey.So(len(bpnm.Ports), convey.ShouldEqual, 3)
+			convey.So(bpnm.Ports[0].AttachToNetwork, convey.ShouldEqual, "net_ns1")
+			convey.So(bpnm.Ports[0].Attributes.NicName, convey.ShouldEqual, constvalue.DefaultPortName)
+			convey.So(bpnm.Ports[1].AttachToNetwork, convey.ShouldEqual, "net_api")
+			convey.So(bpnm.Ports[1].Attributes.NicName, convey.ShouldEqual, "net1")
+			convey.So(bpnm.Ports[1].Attributes.Metadata[netattachdef.PortMetadataKey], convey.ShouldEqual, "ns1/nad_api")
+			convey.So(bpnm.Ports[2].AttachToNetwork, convey.ShouldEqual, "net_media")
+			convey.So(bpnm.Ports[2].Attributes.NicName, convey.ShouldEqual, "media")
+		})
+
+		convey.Convey("no default network if eth0 is requested\n", func() {
+			networksByte, err := GetNetworkConfigFromSelections("ns1",
+				`[{"name": "nad_api", "interface": "eth0", "ips": ["10.0.0.10/24"], "mac": "c2:b0:57:49:47:f1"}]`)
+			convey.So(err, convey.ShouldBeNil)
+			bpnm := &BluePrintNetworkMessage{}
+			convey.So(json.Unmarshal(networksByte, bpnm), convey.ShouldBeNil)
+			convey.So(len(bpnm.Ports), convey.ShouldEqual, 1)
+			convey.So(bpnm.Ports[0].AttachToNetwork, convey.ShouldEqual, "net_api")
+			convey.So(bpnm.Ports[0].Attributes.IPAddr, convey.ShouldEqual, "10.0.0.10")
+			convey.So(bpnm.Ports[0].Attributes.MacAddr, convey.ShouldEqual, "c2:b0:57:49:47:f1")
+		})
+
+		convey.Convey("more than one ip is illegal\n", func() {
+			_, err := GetNetworkConfigFromSelections("ns1",
+				`[{"name": "nad_api", "ips": ["10.0.0.10/24", "10.0.0.11/24"]}]`)
+			convey.So(err.Error(), convey.ShouldEqual, "ips of ns1/nad_api is illegal: only one ip is supported")
+			convey.So(errobj.IsInvalidNetworksError(err), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("empty selections is illegal\n", func() {
+			_, err := GetNetworkConfigFromSelections("ns1", "[]")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(errobj.IsInvalidNetworksError(err), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("error of fetching definition is not invalid networks\n", func() {
+			stubs.Stub(&infra.GetNetworkAttachmentDefinition,
+				func(nadNs, nadName string) (*netattachdef.NetworkAttachmentDefinition, error) {
+					return nil, apierrors.NewServiceUnavailable("apiserver is unavailable")
+				})
+			_, err := GetNetworkConfigFromSelections("ns1", "nad_api")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(errobj.IsInvalidNetworksError(err), convey.ShouldBeFalse)
+		})
+	})
+}
+
+func TestIsPodNetworksChangedBySelections(t *testing.T) {
+	convey.Convey("TestIsPodNetworksChangedBySelections\n", t, func() {
+		newPod := func(selections string) *v1.Pod {
+			return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1",
+				Annotations: map[string]string{netattachdef.NetworkSelectionAnnotation: selections}}}
+		}
+		convey.So(IsPodNetworksChanged(newPod("nad_api"), newPod("nad_api")), convey.ShouldBeFalse)
+		convey.So(IsPodNetworksChanged(newPod("nad_api"), newPod("nad_api, net_media")), convey.ShouldBeTrue)
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
 	"github.com/ZTE/Knitter/knitter-monitor/infra"
 	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
 )
 
 func GetPodService() PodServiceInterface {
@@ -23,6 +24,9 @@ type PodServiceInterface interface {
 	Save(pod *Pod) error
 	Get(podNs, podName string) (*Pod, error)
 	DeletePodAndPorts(podNs, podName string) error
+	MarkPodPendingDelete(podNs, podName string) error
+	GetAll() ([]*Pod, error)
+	UpdatePodPorts(k8sPod *v1.Pod) (*Pod, error)
 }
 
 type podService struct {
@@ -33,28 +37,12 @@ func (ps *podService) NewPodFromK8sPod(k8sPod *v1.Pod) (*Pod, error) {
 	pod.PodID = string(k8sPod.GetObjectMeta().GetUID())
 	pod.PodName = k8sPod.GetObjectMeta().GetName()
 	pod.PodNs = k8sPod.GetObjectMeta().GetNamespace()
-	pod.TenantId = pod.PodNs
+	pod.TenantId = GetTenantIDOfNamespace(pod.PodNs)
 	pod.IsSuccessful = true
 
-	annotations := k8sPod.GetObjectMeta().GetAnnotations()
-	networksStr, ok := annotations["networks"]
-	klog.Debugf("NewPodFromK8sPod:networksStr is [%v] ", networksStr)
-	var networksByte []byte
-	networksByte = []byte(networksStr)
-
-	if isNetworkNotConfigExist(networksStr, ok) {
-		klog.Warningf("podService.NewPodFromK8sPod: no network message in blurprint")
-		var err error
-		networksByte, err = GetDefaultNetworkConfig()
-		if err != nil {
-			klog.Errorf("podService.NewPodFromK8sPod:GetDefaultNetworkConfig() err, error is [%v]", err)
-			return nil, err
-		}
-	}
-
-	nwJSONObj, err := jason.NewObjectFromBytes(networksByte)
+	nwJSONObj, err := getNetworksJSONObj(k8sPod)
 	if err != nil {
-		klog.Errorf("NewPodFromK8sPod: jason.NewObjectFromBytes([]byte(networksStr: [%v])) err , err is [%v]", networksStr, err)
+		klog.Errorf("NewPodFromK8sPod: getNetworksJSONObj(k8sPod) err , err is [%v]", err)
 		return nil, err
 	}
 	//determine whether it is pod reconstruction
@@ -69,8 +57,14 @@ func (ps *podService) NewPodFromK8sPod(k8sPod *v1.Pod) (*Pod, error) {
 		pod.Ports = reconstructionPod.Ports
 		return pod, err
 	}
+	err = ensureTenantCreated(pod.TenantId)
+	if err != nil {
+		klog.Errorf("NewPodFromK8sPod: ensureTenantCreated(pod.TenantId: [%v]) err , err is [%v]", pod.TenantId, err)
+		return nil, err
+	}
 	//create port
 	pod4CreatePort := pod.transferToPod4CreatePort()
+	pod4CreatePort.StatefulSet = getStatefulSetOfPod(k8sPod)
 	pod.Ports, err = GetPortService().NewPortsWithEagerAttrAndLazyAttr(pod4CreatePort, nwJSONObj)
 	if err != nil {
 		klog.Errorf("NewPodFromK8sPod:  GetPortService().NewPortsWithEagerAttrAndLazyAttr(pod4CreatePort: [%v], nwJSONObj [%v]) err , err is [%v]", pod4CreatePort, nwJSONObj, err)
@@ -80,13 +74,159 @@ func (ps *podService) NewPodFromK8sPod(k8sPod *v1.Pod) (*Pod, error) {
 	return pod, nil
 }
 
+// getNetworksJSONObj returns an InvalidNetworksError if the networks of pod
+// can not be parsed, other errors are transient and worth a retry.
+func getNetworksJSONObj(k8sPod *v1.Pod) (*jason.Object, error) {
+	annotations := k8sPod.GetObjectMeta().GetAnnotations()
+	networksStr, ok := annotations["networks"]
+	klog.Debugf("getNetworksJSONObj:networksStr is [%v] ", networksStr)
+	var networksByte []byte
+	networksByte = []byte(networksStr)
+
+	selectionsStr := annotations[netattachdef.NetworkSelectionAnnotation]
+	if isNetworkNotConfigExist(networksStr, ok) && selectionsStr != "" {
+		klog.Infof("getNetworksJSONObj: use network selections [%v]", selectionsStr)
+		var err error
+		networksByte, err = GetNetworkConfigFromSelections(k8sPod.GetObjectMeta().GetNamespace(), selectionsStr)
+		if err != nil {
+			klog.Errorf("getNetworksJSONObj:GetNetworkConfigFromSelections() err, error is [%v]", err)
+			return nil, err
+		}
+	} else if isNetworkNotConfigExist(networksStr, ok) {
+		klog.Warningf("getNetworksJSONObj: no network message in blurprint")
+		var err error
+		networksByte, err = GetDefaultNetworkConfig(k8sPod.GetObjectMeta().GetNamespace())
+		if err != nil {
+			klog.Errorf("getNetworksJSONObj:GetDefaultNetworkConfig() err, error is [%v]", err)
+			return nil, err
+		}
+	}
+
+	nwJSONObj, err := jason.NewObjectFromBytes(networksByte)
+	if err != nil {
+		klog.Errorf("getNetworksJSONObj: jason.NewObjectFromBytes([]byte(networksStr: [%v])) err , err is [%v]", networksStr, err)
+		return nil, errobj.NewInvalidNetworksError(err)
+	}
+	return nwJSONObj, nil
+}
+
+// IsPodNetworksChanged reports whether the networks annotation or the
+// network selections of the pod are changed by the update.
+func IsPodNetworksChanged(oldPod, newPod *v1.Pod) bool {
+	oldAnnotations := oldPod.GetObjectMeta().GetAnnotations()
+	newAnnotations := newPod.GetObjectMeta().GetAnnotations()
+	return oldAnnotations["networks"] != newAnnotations["networks"] ||
+		oldAnnotations[netattachdef.NetworkSelectionAnnotation] != newAnnotations[netattachdef.NetworkSelectionAnnotation]
+}
+
+// UpdatePodPorts makes the ports of the stored pod match the networks
+// annotation of the k8s pod, ports not in the stored pod are created and
+// ports not in the annotation are deleted, knitter-agent hot-plugs the
+// interfaces according to the updated pod.
+func (ps *podService) UpdatePodPorts(k8sPod *v1.Pod) (*Pod, error) {
+	podNs := k8sPod.GetObjectMeta().GetNamespace()
+	podName := k8sPod.GetObjectMeta().GetName()
+	pod, err := ps.Get(podNs, podName)
+	if err != nil {
+		klog.Errorf("UpdatePodPorts: ps.Get(podNs :[%v], podName:[%v]) err , err is [%v]", podNs, podName, err)
+		return nil, err
+	}
+	if !pod.IsSuccessful {
+		klog.Warningf("UpdatePodPorts: pod[%v/%v] is not created successfully, skip updating", podNs, podName)
+		return pod, nil
+	}
+
+	nwJSONObj, err := getNetworksJSONObj(k8sPod)
+	if err != nil {
+		klog.Errorf("UpdatePodPorts: getNetworksJSONObj(k8sPod) err , err is [%v]", err)
+		return nil, err
+	}
+	pod4CreatePort := pod.transferToPod4CreatePort()
+	pod4CreatePort.StatefulSet = getStatefulSetOfPod(k8sPod)
+	desiredPorts, err := GetPortService().NewPortsWithEagerAttrFromK8s(pod4CreatePort, nwJSONObj)
+	if err != nil {
+		klog.Errorf("UpdatePodPorts: GetPortService().NewPortsWithEagerAttrFromK8s(pod4CreatePort: [%v], nwJSONObj [%v]) err , err is [%v]", pod4CreatePort, nwJSONObj, err)
+		return nil, errobj.NewInvalidNetworksError(err)
+	}
+
+	addedPorts, keptPorts, removedPorts := diffPorts(pod.Ports, desiredPorts)
+	klog.Infof("UpdatePodPorts: pod[%v/%v] add ports count: [%v], remove ports count: [%v]",
+		podNs, podName, len(addedPorts), len(removedPorts))
+	if len(addedPorts) > 0 {
+		err = GetPortService().FillPortsLazyAttr(pod4CreatePort, addedPorts)
+		if err != nil {
+			klog.Errorf("UpdatePodPorts: GetPortService().FillPortsLazyAttr(pod4CreatePort: [%v]) err , err is [%v]", pod4CreatePort, err)
+			return nil, err
+		}
+		pod.Ports = append(pod.Ports, addedPorts...)
+		err = ps.Save(pod)
+		if err != nil {
+			klog.Errorf("UpdatePodPorts: ps.Save(pod: [%v]) err , err is [%v]", pod, err)
+			GetPortService().DeleteBulkPorts(pod.TenantId, getPortIDs(addedPorts))
+			return nil, err
+		}
+	}
+
+	if len(removedPorts) > 0 {
+		// removed ports are kept in the stored pod until they are deleted,
+		// so they are deleted again in retry or with the pod
+		err = GetPortService().DeleteBulkPorts(pod.TenantId, getPortIDs(removedPorts))
+		if err != nil {
+			klog.Errorf("UpdatePodPorts: GetPortService().DeleteBulkPorts(portIDs: [%v]) err , err is [%v]", getPortIDs(removedPorts), err)
+			return nil, err
+		}
+		pod.Ports = append(keptPorts, addedPorts...)
+		err = ps.Save(pod)
+		if err != nil {
+			klog.Errorf("UpdatePodPorts: ps.Save(pod: [%v]) err , err is [%v]", pod, err)
+			return nil, err
+		}
+	}
+	return pod, nil
+}
+
+// diffPorts matches the ports by nic name and network, the stored ports are
+// kept with their lazy attributes.
+func diffPorts(storedPorts, desiredPorts []*Port) (added, kept, removed []*Port) {
+	portKey := func(port *Port) string {
+		return port.EagerAttr.PortName + "/" + port.EagerAttr.NetworkName
+	}
+	desiredKeys := make(map[string]bool)
+	for _, port := range desiredPorts {
+		desiredKeys[portKey(port)] = true
+	}
+	storedKeys := make(map[string]bool)
+	for _, port := range storedPorts {
+		storedKeys[portKey(port)] = true
+		if desiredKeys[portKey(port)] {
+			kept = append(kept, port)
+		} else {
+			removed = append(removed, port)
+		}
+	}
+	for _, port := range desiredPorts {
+		if !storedKeys[portKey(port)] {
+			added = append(added, port)
+		}
+	}
+	return added, kept, removed
+}
+
+func getPortIDs(ports []*Port) []string {
+	portIDs := make([]string, 0)
+	for _, port := range ports {
+		portIDs = append(portIDs, port.LazyAttr.ID)
+	}
+	return portIDs
+}
+
 func isNetworkNotConfigExist(networksStr string, ok bool) bool {
 	return networksStr == "" || !ok || networksStr == "\"\""
 }
 
-func GetDefaultNetworkConfig() ([]byte, error) {
+func GetDefaultNetworkConfig(podNs string) ([]byte, error) {
 	bluePrintNetworkMessage := &BluePrintNetworkMessage{}
-	err := bluePrintNetworkMessage.NewDelaultNetworkMessage()
+	err := bluePrintNetworkMessage.NewDelaultNetworkMessage(podNs)
 	if err != nil {
 		klog.Errorf("GetDefaultNetworkConfig:bluePrintNetworkMessage.NewDelaultNetworkMessage() err: %v", err)
 	}
@@ -102,11 +242,18 @@ type BluePrintNetworkMessage struct {
 	Ports []BluePrintPort `json:"ports"`
 }
 
-func (bpnm *BluePrintNetworkMessage) NewDelaultNetworkMessage() error {
-	netName, err := infra.GetManagerClient().GetDefaultNetWork(constvalue.PaaSTenantAdminDefaultUUID)
-	if err != nil {
-		klog.Errorf("NewDelaultNetworkMessage() err: %v", err)
-		return err
+// NewDelaultNetworkMessage attaches the pod to the default network of its
+// namespace, or the default network of knitter-manager if the namespace is
+// not annotated.
+func (bpnm *BluePrintNetworkMessage) NewDelaultNetworkMessage(podNs string) error {
+	netName := getDefaultNetworkOfNamespace(podNs)
+	if netName == "" {
+		var err error
+		netName, err = infra.GetManagerClient().GetDefaultNetWork(constvalue.PaaSTenantAdminDefaultUUID)
+		if err != nil {
+			klog.Errorf("NewDelaultNetworkMessage() err: %v", err)
+			return err
+		}
 	}
 	ports := make([]BluePrintPort, 0)
 	port := BluePrintPort{
@@ -129,10 +276,13 @@ type BluePrintPort struct {
 }
 
 type BluePrintAttributes struct {
-	Accelerate string `json:"accelerate"`
-	Function   string `json:"function"`
-	NicName    string `json:"nic_name"`
-	NicType    string `json:"nic_type"`
+	Accelerate string            `json:"accelerate"`
+	Function   string            `json:"function"`
+	NicName    string            `json:"nic_name"`
+	NicType    string            `json:"nic_type"`
+	IPAddr     string            `json:"ip_addr,omitempty"`
+	MacAddr    string            `json:"mac_addr,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
 func (ps *podService) Save(pod *Pod) error {
@@ -171,7 +321,11 @@ func (ps *podService) DeletePodAndPorts(podNs, podName string) error {
 	for _, port := range pod.Ports {
 		portIDs = append(portIDs, port.ID)
 	}
-	err = GetPortService().DeleteBulkPorts(podNs, portIDs)
+	tenantID := pod.TenantId
+	if tenantID == "" {
+		tenantID = podNs
+	}
+	err = GetPortService().DeleteBulkPorts(tenantID, portIDs)
 	if err != nil {
 		klog.Errorf("GetPortService().DeleteBulkPorts(podNs:[%v], portIDs:[%v] ) err, error is [%v]", podNs, portIDs, err)
 		return err
@@ -187,6 +341,44 @@ func (ps *podService) DeletePodAndPorts(podNs, podName string) error {
 
 }
 
+// MarkPodPendingDelete records the deletion of the pod durably, so the
+// ports of the pod are still released if the monitor restarts before the
+// deletion is processed.
+func (ps *podService) MarkPodPendingDelete(podNs, podName string) error {
+	podForDB, err := daos.GetPodDao().Get(podNs, podName)
+	if err != nil {
+		if errobj.IsKeyNotFoundError(err) {
+			klog.Infof("podService.MarkPodPendingDelete: pod[%v/%v] not found, no need to mark", podNs, podName)
+			return nil
+		}
+		klog.Errorf("podService.MarkPodPendingDelete: daos.GetPodDao().Get(podNs:[%v], podName[:%v]) err, error is [%v]", podNs, podName, err)
+		return err
+	}
+	if podForDB.PendingDelete {
+		return nil
+	}
+	podForDB.PendingDelete = true
+	err = daos.GetPodDao().Save(podForDB)
+	if err != nil {
+		klog.Errorf("podService.MarkPodPendingDelete: daos.GetPodDao().Save(podForDB:[%v]) err, error is [%v]", podForDB, err)
+		return err
+	}
+	return nil
+}
+
+func (ps *podService) GetAll() ([]*Pod, error) {
+	podsForDB, err := daos.GetPodDao().GetAll()
+	if err != nil {
+		klog.Errorf("podService.GetAll: daos.GetPodDao().GetAll() err, error is [%v]", err)
+		return nil, err
+	}
+	pods := make([]*Pod, 0)
+	for _, podForDB := range podsForDB {
+		pods = append(pods, newPodFromPodForDB(podForDB))
+	}
+	return pods, nil
+}
+
 type Pod struct {
 	TenantId     string
 	PodID        string
@@ -196,6 +388,9 @@ type Pod struct {
 	IsSuccessful bool
 	ErrorMsg     string
 	Ports        []*Port
+	// PendingDelete means the pod is deleted in k8s, but its ports are not
+	// released yet
+	PendingDelete bool
 }
 
 func newPodFromPodForDB(db *daos.PodForDB) *Pod {
@@ -205,14 +400,15 @@ func newPodFromPodForDB(db *daos.PodForDB) *Pod {
 		mports = append(mports, mport)
 	}
 	return &Pod{
-		TenantId:     db.TenantId,
-		PodID:        db.PodID,
-		PodName:      db.PodName,
-		PodNs:        db.PodNs,
-		PodType:      db.PodType,
-		IsSuccessful: db.IsSuccessful,
-		ErrorMsg:     db.ErrorMsg,
-		Ports:        mports,
+		TenantId:      db.TenantId,
+		PodID:         db.PodID,
+		PodName:       db.PodName,
+		PodNs:         db.PodNs,
+		PodType:       db.PodType,
+		IsSuccessful:  db.IsSuccessful,
+		ErrorMsg:      db.ErrorMsg,
+		Ports:         mports,
+		PendingDelete: db.PendingDelete,
 	}
 }
 
@@ -233,14 +429,15 @@ func (p *Pod) transferToPodForDao() *daos.PodForDB {
 		portsForDB = append(portsForDB, portForDB)
 	}
 	return &daos.PodForDB{
-		TenantId:     p.TenantId,
-		PodID:        p.PodID,
-		PodNs:        p.PodNs,
-		PodName:      p.PodName,
-		PodType:      p.PodType,
-		ErrorMsg:     p.ErrorMsg,
-		IsSuccessful: p.IsSuccessful,
-		Ports:        portsForDB,
+		TenantId:      p.TenantId,
+		PodID:         p.PodID,
+		PodNs:         p.PodNs,
+		PodName:       p.PodName,
+		PodType:       p.PodType,
+		ErrorMsg:      p.ErrorMsg,
+		IsSuccessful:  p.IsSuccessful,
+		Ports:         portsForDB,
+		PendingDelete: p.PendingDelete,
 	}
 
 }
@@ -250,4 +447,6 @@ type PodForCreatPort struct {
 	PodID    string
 	PodName  string
 	PodNs    string
+	// StatefulSet is nil if the pod is not owned by a StatefulSet
+	StatefulSet *StatefulSetOfPod
 }
@@ -13,7 +13,9 @@ import (
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
 	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/k8s-event"
 	"github.com/ZTE/Knitter/pkg/klog"
 )
 
@@ -23,12 +25,28 @@ type createPortForPodController struct {
 	podStoreIndexer cache.Indexer
 	podsCreateQueue workqueue.RateLimitingInterface
 	podsDeleteQueue workqueue.RateLimitingInterface
+	podsUpdateQueue workqueue.RateLimitingInterface
+
+	nsController       cache.Controller
+	nsStoreIndexer     cache.Indexer
+	tenantsCreateQueue workqueue.RateLimitingInterface
+	tenantsCancelQueue workqueue.RateLimitingInterface
+
+	stsController     cache.Controller
+	stsStoreIndexer   cache.Indexer
+	statefulSetsQueue workqueue.RateLimitingInterface
 }
 
 func NewCreatePortForPodController() (*createPortForPodController, error) {
 	cpc := &createPortForPodController{
 		podsCreateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
 		podsDeleteQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+		podsUpdateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+
+		tenantsCreateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "tenants"),
+		tenantsCancelQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "tenants"),
+
+		statefulSetsQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "statefulsets"),
 	}
 	cpc.clientSet = infra.GetClientset()
 	if cpc.clientSet == nil {
@@ -44,10 +62,13 @@ func NewCreatePortForPodController() (*createPortForPodController, error) {
 		time.Second*0,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc:    cpc.enqueueCreatePod,
+			UpdateFunc: cpc.enqueueUpdatePod,
 			DeleteFunc: cpc.enqueueDeletePod,
 		},
 		cache.Indexers{},
 	)
+	cpc.newNamespaceInformer()
+	cpc.newStatefulSetInformer()
 	return cpc, nil
 }
 
@@ -64,22 +85,50 @@ func (cpc *createPortForPodController) enqueueCreatePod(obj interface{}) {
 
 }
 
+// enqueueUpdatePod queues the pod whose networks annotation is changed, to
+// hot-plug or hot-unplug its ports.
+func (cpc *createPortForPodController) enqueueUpdatePod(oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	newPod, ok := newObj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	if newPod.GetObjectMeta().GetDeletionTimestamp() != nil || !IsPodNetworksChanged(oldPod, newPod) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(newPod)
+	if err != nil {
+		klog.Errorf("enqueueUpdatePod : MetaNamespaceKeyFunc err, error is [%v]", err)
+		return
+	}
+	cpc.podsUpdateQueue.Add(key)
+	klog.Infof("enqueueUpdatePod END:key is [%v]", key)
+}
+
+// enqueueDeletePod marks the pod pending delete in DB before queueing it, a
+// deletion missed by the queue is picked up by resyncPods after restart.
 func (cpc *createPortForPodController) enqueueDeletePod(obj interface{}) {
 	klog.Infof("enqueueDeletePod start ")
 	klog.Debugf("enqueueDeletePod obj is [%v]", obj)
-
-	if _, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Errorf("enqueueDeletePod : DeletionHandlingMetaNamespaceKeyFunc err, error is [%v]", err)
 		return
 	}
-	cpc.podsDeleteQueue.Add(obj)
-	//key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
-	//if err != nil {
-	//	klog.Errorf("enqueueCreatePod : DeletionHandlingMetaNamespaceKeyFunc err, error is [%v]", err)
-	//	return
-	//}
-	//cpc.podsDeleteQueue.Add(key)
-
-	klog.Infof("enqueueDeletePod END:obj is [%v]", obj)
+	podNs, podName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Errorf("enqueueDeletePod : cache.SplitMetaNamespaceKey(key:[%v]) err, error is [%v]", key, err)
+		return
+	}
+	err = GetPodService().MarkPodPendingDelete(podNs, podName)
+	if err != nil {
+		klog.Warningf("enqueueDeletePod : GetPodService().MarkPodPendingDelete(key:[%v]) err, error is [%v]", key, err)
+	}
+	cpc.podsDeleteQueue.Add(key)
+	klog.Infof("enqueueDeletePod END:key is [%v]", key)
 
 }
 
@@ -109,6 +158,7 @@ func (cpc *createPortForPodController) createPodWorker() {
 
 		if err != nil {
 			klog.Errorf("createPortForPodController.createPodWorker: GetPodService().NewPodFromK8sPod(k8sPod:[%v]) err,error is [%v]", k8sPod, err)
+			reportPodNetworkFailure(k8sPod, err)
 		}
 		if pod == nil {
 			pod = &Pod{PodName: k8sPod.Name, ErrorMsg: err.Error(), IsSuccessful: false, PodNs: k8sPod.Name}
@@ -118,6 +168,17 @@ func (cpc *createPortForPodController) createPodWorker() {
 			//if saving error , add pod to queue
 			klog.Errorf("GetPodService().Save( pod:[%v] ) err ,error is [%v]", pod, err)
 			cpc.podsCreateQueue.Add(key)
+			return false
+		}
+		// pod deleted or its networks changed while its ports were being
+		// created, the events may be processed before the pod is saved
+		obj, exists, _ = cpc.podStoreIndexer.GetByKey(key.(string))
+		if !exists {
+			klog.Infof("createPodWorker: pod[%v] deleted during creating, enqueue it to delete", key)
+			cpc.podsDeleteQueue.Add(key)
+		} else if IsPodNetworksChanged(k8sPod, obj.(*v1.Pod)) {
+			klog.Infof("createPodWorker: pod[%v] networks changed during creating, enqueue it to update", key)
+			cpc.podsUpdateQueue.Add(key)
 		}
 
 		klog.Infof("@crate@ pod is [%v]", pod)
@@ -131,23 +192,104 @@ func (cpc *createPortForPodController) createPodWorker() {
 	}
 }
 
+func (cpc *createPortForPodController) updatePodWorker() {
+	klog.Info("updatePodWorker start ")
+	workFunc := func() bool {
+		key, quit := cpc.podsUpdateQueue.Get()
+		if quit {
+			return true
+		}
+		defer cpc.podsUpdateQueue.Done(key)
+
+		klog.Debugf("cpc.podsUpdateQueue.Get() key :[%v]", key)
+		err := cpc.updatePod(key.(string))
+		if err != nil && !errobj.IsInvalidNetworksError(err) &&
+			cpc.podsUpdateQueue.NumRequeues(key) < constvalue.MaxUpdatePodRetryTimes {
+			klog.Warningf("updatePodWorker: cpc.updatePod(key:[%v]) err, error is [%v], requeue it", key, err)
+			cpc.podsUpdateQueue.AddRateLimited(key)
+			return false
+		}
+		if err != nil {
+			klog.Errorf("updatePodWorker: cpc.updatePod(key:[%v]) err, error is [%v], drop it", key, err)
+			cpc.reportPodUpdateFailure(key.(string), err)
+		}
+		cpc.podsUpdateQueue.Forget(key)
+		return false
+	}
+	for {
+		if quit := workFunc(); quit {
+			klog.Infof("updatePodWorker shut down")
+			return
+		}
+	}
+}
+
+func (cpc *createPortForPodController) updatePod(key string) error {
+	obj, exists, err := cpc.podStoreIndexer.GetByKey(key)
+	if err != nil {
+		klog.Errorf("updatePod: cpc.podStoreIndexer.GetByKey(key:[%v]) err, error is [%v]", key, err)
+		return err
+	}
+	if !exists {
+		klog.Infof("updatePod: pod[%v] has been deleted", key)
+		return nil
+	}
+	_, err = GetPodService().UpdatePodPorts(obj.(*v1.Pod))
+	if errobj.IsKeyNotFoundError(err) {
+		// ports of the pod are being created, createPodWorker queues the
+		// pod again if its networks changed during creating
+		klog.Infof("updatePod: pod[%v] not created yet, skip updating", key)
+		return nil
+	}
+	return err
+}
+
+// reportPodNetworkFailure tells the users why the pod is stuck in creating,
+// which is only saved in the database otherwise.
+func reportPodNetworkFailure(k8sPod *v1.Pod, err error) {
+	reason := getPodNetworkFailedReason(err)
+	infra.RecordPodEvent(k8sPod, v1.EventTypeWarning, reason, err.Error())
+	infra.SetPodNetworkReady(k8sPod, false, reason, err.Error())
+}
+
+// reportPodUpdateFailure only records an event, the attached interfaces of
+// the pod are still working.
+func (cpc *createPortForPodController) reportPodUpdateFailure(key string, err error) {
+	obj, exists, _ := cpc.podStoreIndexer.GetByKey(key)
+	if !exists {
+		return
+	}
+	infra.RecordPodEvent(obj.(*v1.Pod), v1.EventTypeWarning, getPodNetworkFailedReason(err), err.Error())
+}
+
+func getPodNetworkFailedReason(err error) string {
+	switch {
+	case errobj.IsInvalidNetworksError(err):
+		return k8sevent.ReasonInvalidNetworks
+	case errobj.IsIPExhaustedError(err):
+		return k8sevent.ReasonIPExhausted
+	default:
+		return k8sevent.ReasonPortAllocationFailed
+	}
+}
+
 func (cpc *createPortForPodController) deletePodWorker() {
 	klog.Info("deletePodWorker start ")
 	workFunc := func() bool {
-		obj, quit := cpc.podsDeleteQueue.Get()
+		key, quit := cpc.podsDeleteQueue.Get()
 		if quit {
 			return true
 		}
-		defer cpc.podsDeleteQueue.Done(obj)
-
-		klog.Debugf("cpc.podsDeleteQueue.Get() key :[%v]", obj)
+		defer cpc.podsDeleteQueue.Done(key)
 
-		pod := obj.(*v1.Pod)
-		// todo judge k8s recycling resource
-		err := GetPodService().DeletePodAndPorts(pod.Namespace, pod.Name)
+		klog.Debugf("cpc.podsDeleteQueue.Get() key :[%v]", key)
+		err := cpc.deletePod(key.(string))
 		if err != nil {
-			klog.Warningf("GetPodService().DeletePodAndPorts() err, error is [%v]")
+			klog.Warningf("deletePodWorker: cpc.deletePod(key:[%v]) err, error is [%v], requeue it", key, err)
+			cpc.podsDeleteQueue.AddRateLimited(key)
+			return false
 		}
+		cpc.podsDeleteQueue.Forget(key)
 		return false
 	}
 	for {
@@ -158,31 +300,108 @@ func (cpc *createPortForPodController) deletePodWorker() {
 	}
 }
 
+func (cpc *createPortForPodController) deletePod(key string) error {
+	podNs, podName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Errorf("deletePod: cache.SplitMetaNamespaceKey(key:[%v]) err, error is [%v], drop it", key, err)
+		return nil
+	}
+	// a pod with the same name is created again, it takes over the ports
+	_, exists, err := cpc.podStoreIndexer.GetByKey(key)
+	if err == nil && exists {
+		klog.Infof("deletePod: pod[%v] exists again, skip deleting it", key)
+		return nil
+	}
+	return GetPodService().DeletePodAndPorts(podNs, podName)
+}
+
+// resyncPods enqueues the pods stored in DB but not exist in k8s any more,
+// including the pods marked pending delete, to release their ports. The
+// deletions missed during monitor restart or watch gap are recovered here.
+func (cpc *createPortForPodController) resyncPods() error {
+	pods, err := GetPodService().GetAll()
+	if err != nil {
+		klog.Errorf("resyncPods: GetPodService().GetAll() err, error is [%v]", err)
+		return err
+	}
+	for _, pod := range pods {
+		key := pod.PodNs + "/" + pod.PodName
+		_, exists, err := cpc.podStoreIndexer.GetByKey(key)
+		if err != nil {
+			klog.Warningf("resyncPods: cpc.podStoreIndexer.GetByKey(key:[%v]) err, error is [%v]", key, err)
+			continue
+		}
+		if exists {
+			continue
+		}
+		klog.Infof("resyncPods: pod[%v] not exist in k8s, pending delete:[%v], enqueue it to delete", key, pod.PendingDelete)
+		cpc.podsDeleteQueue.Add(key)
+	}
+	return nil
+}
+
 func (cpc *createPortForPodController) Run(workers int, stopCh <-chan struct{}) {
 	defer runtime.HandleCrash()
-	klog.Infof("createPortForPodController.Run : Starting serviceLookupController Manager ")
+	err := cpc.RunInformer(stopCh)
+	if err != nil {
+		klog.Errorf("createPortForPodController.Run: cpc.RunInformer err, error is [%v]", err)
+		return
+	}
+	cpc.RunWorkers(workers, stopCh)
+}
+
+// RunInformer starts the pod, namespace and StatefulSet informers and waits
+// for their caches synced, pods are mapped to tenants by the namespace cache,
+// pods events are queued even if the workers are not running, so a standby
+// monitor takes over with warm cache and queues.
+func (cpc *createPortForPodController) RunInformer(stopCh <-chan struct{}) error {
+	klog.Infof("createPortForPodController.RunInformer : Starting serviceLookupController Manager ")
+	go cpc.nsController.Run(stopCh)
+	go cpc.stsController.Run(stopCh)
 	go cpc.podController.Run(stopCh)
 	var i int
 	for i = 1; i < constvalue.WaitForCacheSyncTimes; i++ {
-		if cache.WaitForCacheSync(stopCh, cpc.podController.HasSynced) {
+		if cache.WaitForCacheSync(stopCh, cpc.nsController.HasSynced, cpc.stsController.HasSynced,
+			cpc.podController.HasSynced) {
 			klog.Infof("cache.WaitForCacheSync(stopCh, cpc.podController.HasSynced) error")
 			break
 		}
 		time.Sleep(time.Second * 1)
 	}
 	if i == constvalue.WaitForCacheSyncTimes {
-		klog.Errorf("createPortForPodController.Run: cache.WaitForCacheSync(stopCh, cpc.podController.HasSynced:[%v]) error,", cpc.podController.HasSynced())
-		return
+		klog.Errorf("createPortForPodController.RunInformer: cache.WaitForCacheSync(stopCh, cpc.podController.HasSynced:[%v]) error,", cpc.podController.HasSynced())
+		return errors.New("wait for pod cache sync timeout")
+	}
+	return nil
+}
+
+func (cpc *createPortForPodController) RunWorkers(workers int, stopCh <-chan struct{}) {
+	defer runtime.HandleCrash()
+	err := cpc.resyncPods()
+	if err != nil {
+		klog.Warningf("createPortForPodController.RunWorkers: cpc.resyncPods() err, error is [%v]", err)
+	}
+	err = cpc.resyncStatefulSets()
+	if err != nil {
+		klog.Warningf("createPortForPodController.RunWorkers: cpc.resyncStatefulSets() err, error is [%v]", err)
 	}
 	for i := 0; i < workers; i++ {
 		go wait.Until(cpc.createPodWorker, time.Second, stopCh)
 		go wait.Until(cpc.deletePodWorker, time.Second, stopCh)
+		go wait.Until(cpc.updatePodWorker, time.Second, stopCh)
 	}
-	klog.Infof("createPortForPodController.Run : Started podWorker")
+	go wait.Until(cpc.createTenantWorker, time.Second, stopCh)
+	go wait.Until(cpc.cancelTenantWorker, time.Second, stopCh)
+	go wait.Until(cpc.syncStatefulSetWorker, time.Second, stopCh)
+	klog.Infof("createPortForPodController.RunWorkers : Started podWorker")
 
 	<-stopCh
 	klog.Infof("Shutting down Service Lookup Controller")
 	cpc.podsDeleteQueue.ShutDown()
 	cpc.podsCreateQueue.ShutDown()
+	cpc.podsUpdateQueue.ShutDown()
+	cpc.tenantsCreateQueue.ShutDown()
+	cpc.tenantsCancelQueue.ShutDown()
+	cpc.statefulSetsQueue.ShutDown()
 
 }
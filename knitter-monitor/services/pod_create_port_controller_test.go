@@ -11,10 +11,13 @@ import (
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
 	"github.com/ZTE/Knitter/knitter-monitor/infra"
 	"github.com/ZTE/Knitter/knitter-monitor/tests/mocks/kubernetes"
+	"github.com/ZTE/Knitter/pkg/k8s-event"
 )
 
 func TestNewCreatePortForPodController(t *testing.T) {
@@ -74,11 +77,31 @@ func TestEnqueueDeletePod(t *testing.T) {
 		ObjectMeta: objectMeta,
 	}
 
-	convey.Convey("TestEnqueueCreatePod", t, func() {
+	var marked []string
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "MarkPodPendingDelete",
+		func(ps *podService, podNs, podName string) error {
+			marked = append(marked, podNs+"/"+podName)
+			return nil
+		})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestEnqueueDeletePod", t, func() {
 		controller.enqueueDeletePod(k8sPod)
-		obj, _ := controller.podsDeleteQueue.Get()
+		key, _ := controller.podsDeleteQueue.Get()
+		controller.podsDeleteQueue.Done(key)
 
-		convey.So(obj.(*v1.Pod), convey.ShouldResemble, k8sPod)
+		convey.So(key.(string), convey.ShouldEqual, "admin/pod1")
+		convey.So(marked, convey.ShouldResemble, []string{"admin/pod1"})
+	})
+
+	convey.Convey("TestEnqueueDeletePodTombstone", t, func() {
+		controller.enqueueDeletePod(cache.DeletedFinalStateUnknown{Key: "admin/pod2", Obj: k8sPod})
+		key, _ := controller.podsDeleteQueue.Get()
+		controller.podsDeleteQueue.Done(key)
+
+		convey.So(key.(string), convey.ShouldEqual, "admin/pod2")
+		convey.So(marked[len(marked)-1], convey.ShouldEqual, "admin/pod2")
 	})
 
 }
@@ -100,7 +123,7 @@ func TestCreatePodWorker(t *testing.T) {
 	mockControlelr := gomock.NewController(t)
 	defer mockControlelr.Finish()
 	mockIndexer := mockcache.NewMockIndexer(mockControlelr)
-	mockIndexer.EXPECT().GetByKey("admin/pod1").Return(k8sPod, true, nil)
+	mockIndexer.EXPECT().GetByKey("admin/pod1").Return(k8sPod, true, nil).Times(2)
 	controller.podStoreIndexer = mockIndexer
 	controller.podsCreateQueue.Add("admin/pod1")
 
@@ -182,3 +205,245 @@ func TestDeletePodWorkerFail(t *testing.T) {
 
 	})
 }
+
+func TestCreatePodWorkerPodDeletedDuringCreating(t *testing.T) {
+	controller := &createPortForPodController{
+		podsCreateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+		podsDeleteQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+	}
+	k8sPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", UID: "testpod-1111", Namespace: "admin"},
+	}
+	mockControlelr := gomock.NewController(t)
+	defer mockControlelr.Finish()
+	mockIndexer := mockcache.NewMockIndexer(mockControlelr)
+	gomock.InOrder(
+		mockIndexer.EXPECT().GetByKey("admin/pod1").Return(k8sPod, true, nil),
+		mockIndexer.EXPECT().GetByKey("admin/pod1").Return(nil, false, nil),
+	)
+	controller.podStoreIndexer = mockIndexer
+	controller.podsCreateQueue.Add("admin/pod1")
+
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "NewPodFromK8sPod",
+		func(ps *podService, k8sPod *v1.Pod) (*Pod, error) {
+			return &Pod{PodName: "pod1"}, nil
+		})
+	defer monkey.UnpatchAll()
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "Save",
+		func(ps *podService, pod *Pod) error {
+			return nil
+		})
+	convey.Convey("TestCreatePodWorkerPodDeletedDuringCreating", t, func() {
+		controller.podsCreateQueue.ShutDown()
+		controller.createPodWorker()
+		convey.So(controller.podsDeleteQueue.Len(), convey.ShouldEqual, 1)
+	})
+}
+
+func TestDeletePod(t *testing.T) {
+	controller := &createPortForPodController{
+		podStoreIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	controller.podStoreIndexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "admin"}})
+
+	var deleted []string
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "DeletePodAndPorts",
+		func(ps *podService, podNs, podName string) error {
+			deleted = append(deleted, podNs+"/"+podName)
+			if podName == "pod3" {
+				return errors.New("delete err")
+			}
+			return nil
+		})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestDeletePod", t, func() {
+		convey.So(controller.deletePod("admin/pod1"), convey.ShouldBeNil)
+		convey.So(controller.deletePod("admin/pod2"), convey.ShouldBeNil)
+		convey.So(controller.deletePod("admin/pod3"), convey.ShouldNotBeNil)
+		convey.So(deleted, convey.ShouldResemble, []string{"admin/pod1", "admin/pod3"})
+	})
+}
+
+func TestDeletePodWorkerRequeueFailedPod(t *testing.T) {
+	controller := &createPortForPodController{
+		podStoreIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		podsDeleteQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+	}
+	controller.podsDeleteQueue.Add("admin/pod1")
+
+	var times int
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "DeletePodAndPorts",
+		func(ps *podService, podNs, podName string) error {
+			times++
+			if times == 1 {
+				return errors.New("delete err")
+			}
+			controller.podsDeleteQueue.ShutDown()
+			return nil
+		})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestDeletePodWorkerRequeueFailedPod", t, func() {
+		controller.deletePodWorker()
+		convey.So(times, convey.ShouldEqual, 2)
+		convey.So(controller.podsDeleteQueue.NumRequeues("admin/pod1"), convey.ShouldEqual, 0)
+	})
+}
+
+func TestResyncPods(t *testing.T) {
+	controller := &createPortForPodController{
+		podStoreIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+		podsDeleteQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+	}
+	controller.podStoreIndexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin"}})
+
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "GetAll",
+		func(ps *podService) ([]*Pod, error) {
+			return []*Pod{
+				{PodNs: "admin", PodName: "pod1"},
+				{PodNs: "admin", PodName: "pod2"},
+				{PodNs: "tenant1", PodName: "pod3", PendingDelete: true},
+			}, nil
+		})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestResyncPods", t, func() {
+		err := controller.resyncPods()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(controller.podsDeleteQueue.Len(), convey.ShouldEqual, 2)
+		key, _ := controller.podsDeleteQueue.Get()
+		convey.So(key.(string), convey.ShouldEqual, "admin/pod2")
+		key, _ = controller.podsDeleteQueue.Get()
+		convey.So(key.(string), convey.ShouldEqual, "tenant1/pod3")
+	})
+}
+
+func TestEnqueueUpdatePod(t *testing.T) {
+	controller := &createPortForPodController{
+		podsUpdateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+	}
+	oldPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin",
+			Annotations: map[string]string{"networks": `{"ports": [{"attach_to_network": "net_api"}]}`}},
+	}
+	newPod := oldPod.DeepCopy()
+	newPod.Labels = map[string]string{"app": "test"}
+
+	convey.Convey("TestEnqueueUpdatePod", t, func() {
+		controller.enqueueUpdatePod(oldPod, newPod)
+		convey.So(controller.podsUpdateQueue.Len(), convey.ShouldEqual, 0)
+
+		newPod.Annotations["networks"] = `{"ports": [{"attach_to_network": "net_api"}, {"attach_to_network": "net_media"}]}`
+		controller.enqueueUpdatePod(oldPod, newPod)
+		key, _ := controller.podsUpdateQueue.Get()
+		convey.So(key.(string), convey.ShouldEqual, "admin/pod1")
+	})
+}
+
+func TestUpdatePod(t *testing.T) {
+	controller := &createPortForPodController{
+		podStoreIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	controller.podStoreIndexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin"}})
+	controller.podStoreIndexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "admin"}})
+
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "UpdatePodPorts",
+		func(ps *podService, k8sPod *v1.Pod) (*Pod, error) {
+			if k8sPod.Name == "pod2" {
+				return nil, errors.New("100: Key not found")
+			}
+			return nil, errors.New("update err")
+		})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestUpdatePod", t, func() {
+		convey.So(controller.updatePod("admin/pod0"), convey.ShouldBeNil)
+		convey.So(controller.updatePod("admin/pod1"), convey.ShouldNotBeNil)
+		convey.So(controller.updatePod("admin/pod2"), convey.ShouldBeNil)
+	})
+}
+
+func TestCreatePodWorkerReportFailure(t *testing.T) {
+	controller := &createPortForPodController{
+		podsCreateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+		podsDeleteQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+		podStoreIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	k8sPod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin", UID: "testpod-1111"}}
+	controller.podStoreIndexer.Add(k8sPod)
+	controller.podsCreateQueue.Add("admin/pod1")
+
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "NewPodFromK8sPod",
+		func(ps *podService, k8sPod *v1.Pod) (*Pod, error) {
+			return nil, errors.New("No more IP addresses available on network net_api")
+		})
+	defer monkey.UnpatchAll()
+	var savedPod *Pod
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "Save",
+		func(ps *podService, pod *Pod) error {
+			savedPod = pod
+			return nil
+		})
+	var reasons []string
+	monkey.Patch(infra.RecordPodEvent, func(pod *v1.Pod, eventType, reason, message string) {
+		reasons = append(reasons, eventType+"/"+reason)
+	})
+	var readyReason string
+	monkey.Patch(infra.SetPodNetworkReady, func(pod *v1.Pod, ready bool, reason, message string) {
+		readyReason = reason
+	})
+
+	convey.Convey("TestCreatePodWorkerReportFailure", t, func() {
+		controller.podsCreateQueue.ShutDown()
+		controller.createPodWorker()
+		convey.So(savedPod.IsSuccessful, convey.ShouldBeFalse)
+		convey.So(reasons, convey.ShouldResemble, []string{v1.EventTypeWarning + "/" + k8sevent.ReasonIPExhausted})
+		convey.So(readyReason, convey.ShouldEqual, k8sevent.ReasonIPExhausted)
+	})
+}
+
+func TestGetPodNetworkFailedReason(t *testing.T) {
+	convey.Convey("TestGetPodNetworkFailedReason", t, func() {
+		err := errobj.NewInvalidNetworksError(errobj.ErrGetPortConfigError)
+		convey.So(getPodNetworkFailedReason(err), convey.ShouldEqual, k8sevent.ReasonInvalidNetworks)
+		convey.So(err.Error(), convey.ShouldEqual, errobj.ErrGetPortConfigError.Error())
+		err = errors.New("no available IP. group name:[ig1]")
+		convey.So(getPodNetworkFailedReason(err), convey.ShouldEqual, k8sevent.ReasonIPExhausted)
+		err = errors.New("create bulk ports timeout")
+		convey.So(getPodNetworkFailedReason(err), convey.ShouldEqual, k8sevent.ReasonPortAllocationFailed)
+	})
+}
+
+func TestUpdatePodWorkerDropInvalidNetworks(t *testing.T) {
+	controller := &createPortForPodController{
+		podsUpdateQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+		podStoreIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}),
+	}
+	controller.podStoreIndexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin"}})
+	controller.podsUpdateQueue.Add("admin/pod1")
+
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "UpdatePodPorts",
+		func(ps *podService, k8sPod *v1.Pod) (*Pod, error) {
+			return nil, errobj.NewInvalidNetworksError(errors.New("bad json"))
+		})
+	defer monkey.UnpatchAll()
+	var reasons []string
+	monkey.Patch(infra.RecordPodEvent, func(pod *v1.Pod, eventType, reason, message string) {
+		reasons = append(reasons, reason)
+	})
+
+	convey.Convey("TestUpdatePodWorkerDropInvalidNetworks", t, func() {
+		controller.podsUpdateQueue.ShutDown()
+		controller.updatePodWorker()
+		convey.So(controller.podsUpdateQueue.NumRequeues("admin/pod1"), convey.ShouldEqual, 0)
+		convey.So(reasons, convey.ShouldResemble, []string{k8sevent.ReasonInvalidNetworks})
+	})
+}
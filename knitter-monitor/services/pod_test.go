@@ -284,7 +284,7 @@ func TestGetDefaultNetworkConfigSucc(t *testing.T) {
 	defer monkey.UnpatchAll()
 
 	convey.Convey("TestGetDefaultNetworkConfigSucc", t, func() {
-		networkByte, err := GetDefaultNetworkConfig()
+		networkByte, err := GetDefaultNetworkConfig("admin")
 		convey.So(networkByte, convey.ShouldResemble, networkByteExpect)
 		convey.So(err, convey.ShouldBeNil)
 	})
@@ -698,3 +698,136 @@ func TestTransferToPodForDaoSucc(t *testing.T) {
 		convey.So(podForDb, convey.ShouldResemble, podForDbExpect)
 	})
 }
+
+func TestPodService_MarkPodPendingDelete(t *testing.T) {
+	podNs := "admin"
+	podName := "test1"
+	podForDb := &daos.PodForDB{
+		TenantId: podNs,
+		PodNs:    podNs,
+		PodName:  podName,
+	}
+
+	mockController := gomock.NewController(t)
+	defer mockController.Finish()
+	mockDao := mockdaos.NewMockPodDaoInterface(mockController)
+	gomock.InOrder(
+		mockDao.EXPECT().Get(podNs, podName).Return(podForDb, nil),
+		mockDao.EXPECT().Save(&daos.PodForDB{TenantId: podNs, PodNs: podNs, PodName: podName,
+			PendingDelete: true}).Return(nil),
+		mockDao.EXPECT().Get(podNs, "test2").Return(nil, errors.New("Key not found")),
+	)
+	monkey.Patch(daos.GetPodDao, func() daos.PodDaoInterface {
+		return mockDao
+	})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestPodService_MarkPodPendingDelete", t, func() {
+		err := GetPodService().MarkPodPendingDelete(podNs, podName)
+		convey.So(err, convey.ShouldBeNil)
+		err = GetPodService().MarkPodPendingDelete(podNs, "test2")
+		convey.So(err, convey.ShouldBeNil)
+	})
+}
+
+func TestPodService_GetAll(t *testing.T) {
+	mockController := gomock.NewController(t)
+	defer mockController.Finish()
+	mockDao := mockdaos.NewMockPodDaoInterface(mockController)
+	mockDao.EXPECT().GetAll().Return([]*daos.PodForDB{
+		{PodNs: "admin", PodName: "test1"},
+		{PodNs: "admin", PodName: "test2", PendingDelete: true},
+	}, nil)
+	monkey.Patch(daos.GetPodDao, func() daos.PodDaoInterface {
+		return mockDao
+	})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestPodService_GetAll", t, func() {
+		pods, err := GetPodService().GetAll()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(pods), convey.ShouldEqual, 2)
+		convey.So(pods[1].PendingDelete, convey.ShouldBeTrue)
+	})
+}
+
+func newTestPort(portName, networkName, portID string) *Port {
+	return &Port{
+		EagerAttr: PortEagerAttr{PortName: portName, NetworkName: networkName},
+		LazyAttr:  PortLazyAttr{ID: portID},
+	}
+}
+
+func TestDiffPorts(t *testing.T) {
+	convey.Convey("TestDiffPorts", t, func() {
+		stored := []*Port{newTestPort("eth0", "net_api", "id0"), newTestPort("eth1", "net_control", "id1")}
+		desired := []*Port{newTestPort("eth0", "net_api", ""), newTestPort("eth2", "net_media", "")}
+		added, kept, removed := diffPorts(stored, desired)
+		convey.So(len(added), convey.ShouldEqual, 1)
+		convey.So(added[0].EagerAttr.PortName, convey.ShouldEqual, "eth2")
+		convey.So(len(kept), convey.ShouldEqual, 1)
+		convey.So(kept[0].LazyAttr.ID, convey.ShouldEqual, "id0")
+		convey.So(len(removed), convey.ShouldEqual, 1)
+		convey.So(removed[0].LazyAttr.ID, convey.ShouldEqual, "id1")
+	})
+}
+
+func TestPodService_UpdatePodPorts(t *testing.T) {
+	k8sPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod1",
+			Namespace:   "admin",
+			Annotations: map[string]string{"networks": `{"ports": []}`},
+		},
+	}
+	stored := &Pod{TenantId: "admin", PodNs: "admin", PodName: "pod1", IsSuccessful: true,
+		Ports: []*Port{newTestPort("eth0", "net_api", "id0"), newTestPort("eth1", "net_control", "id1")}}
+
+	var ps *podService
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "Get", func(_ *podService, podNs, podName string) (*Pod, error) {
+		pod := *stored
+		return &pod, nil
+	})
+	var saved []*Pod
+	monkey.PatchInstanceMethod(reflect.TypeOf(ps), "Save", func(_ *podService, pod *Pod) error {
+		copied := *pod
+		saved = append(saved, &copied)
+		return nil
+	})
+	var service *portService
+	monkey.PatchInstanceMethod(reflect.TypeOf(service), "NewPortsWithEagerAttrFromK8s",
+		func(_ *portService, pod *PodForCreatPort, nwJSON *jason.Object) ([]*Port, error) {
+			return []*Port{newTestPort("eth0", "net_api", ""), newTestPort("eth2", "net_media", "")}, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(service), "FillPortsLazyAttr",
+		func(_ *portService, pod *PodForCreatPort, ports []*Port) error {
+			ports[0].LazyAttr.ID = "id2"
+			return nil
+		})
+	var deleted []string
+	deleteErr := errors.New("delete err")
+	monkey.PatchInstanceMethod(reflect.TypeOf(service), "DeleteBulkPorts",
+		func(_ *portService, tenantID string, portIDs []string) error {
+			deleted = append(deleted, portIDs...)
+			return deleteErr
+		})
+	defer monkey.UnpatchAll()
+
+	convey.Convey("TestPodService_UpdatePodPorts", t, func() {
+		convey.Convey("keep removed ports if deleting failed\n", func() {
+			_, err := GetPodService().UpdatePodPorts(k8sPod)
+			convey.So(err, convey.ShouldEqual, deleteErr)
+			convey.So(len(saved), convey.ShouldEqual, 1)
+			convey.So(len(saved[0].Ports), convey.ShouldEqual, 3)
+			convey.So(deleted, convey.ShouldResemble, []string{"id1"})
+		})
+
+		convey.Convey("create added ports and delete removed ports\n", func() {
+			deleteErr = nil
+			pod, err := GetPodService().UpdatePodPorts(k8sPod)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(getPortIDs(pod.Ports), convey.ShouldResemble, []string{"id0", "id2"})
+			convey.So(getPortIDs(saved[len(saved)-1].Ports), convey.ShouldResemble, []string{"id0", "id2"})
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/antonholmquist/jason"
+	"k8s.io/api/core/v1"
+
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+// ValidatePodNetworks checks the networks annotation of the pod before it is
+// admitted, the annotation is parsed as creating ports does, then the tenant,
+// networks and ip groups are checked in knitter-manager. Failures to reach
+// knitter-manager are not treated as invalid networks.
+func ValidatePodNetworks(podNs string, k8sPod *v1.Pod) error {
+	networksStr, ok := k8sPod.GetObjectMeta().GetAnnotations()["networks"]
+	if isNetworkNotConfigExist(networksStr, ok) {
+		selectionsStr := k8sPod.GetObjectMeta().GetAnnotations()[netattachdef.NetworkSelectionAnnotation]
+		if selectionsStr == "" {
+			return nil
+		}
+		var err error
+		networksStr, err = getNetworksStrFromSelections(podNs, selectionsStr)
+		if err != nil || networksStr == "" {
+			return err
+		}
+	}
+	nwJSONObj, err := jason.NewObjectFromBytes([]byte(networksStr))
+	if err != nil {
+		return fmt.Errorf("networks annotation is not a valid json object: %v", err)
+	}
+	portArray, err := nwJSONObj.GetObjectArray("ports")
+	if err != nil || len(portArray) == 0 {
+		return fmt.Errorf("networks annotation needs a non-empty ports list")
+	}
+
+	podName := k8sPod.GetObjectMeta().GetName()
+	ports := make([]*Port, 0, len(portArray))
+	for i, portObj := range portArray {
+		port := &Port{}
+		err := port.fillPortEagerAttr(podNs, podName, portObj)
+		if err != nil {
+			return fmt.Errorf("ports[%d]: %v", i, err)
+		}
+		ports = append(ports, port)
+	}
+	// combinePortObjs deletes the combined ports in place
+	_, err = combinePortObjs(append([]*Port{}, ports...))
+	if err != nil {
+		return err
+	}
+	return validatePortsInManager(GetTenantIDOfNamespace(podNs), ports)
+}
+
+// getNetworksStrFromSelections converts the network selections without the
+// default network, an empty string is returned if the selected definitions
+// can not be got.
+func getNetworksStrFromSelections(podNs, selectionsStr string) (string, error) {
+	elements, err := netattachdef.ParseNetworkSelections(selectionsStr, podNs)
+	if err != nil {
+		return "", err
+	}
+	err = validateNetworkSelections(elements)
+	if err != nil {
+		return "", err
+	}
+	bluePrintNetworkMessage, err := newNetworkMessageFromSelections(elements)
+	if err != nil {
+		klog.Warningf("getNetworksStrFromSelections: newNetworkMessageFromSelections err: %v, skip checking", err)
+		return "", nil
+	}
+	networksByte, err := json.Marshal(bluePrintNetworkMessage)
+	if err != nil {
+		return "", err
+	}
+	return string(networksByte), nil
+}
+
+func validatePortsInManager(tenantID string, ports []*Port) error {
+	mc := infra.GetManagerClient()
+	if mc == nil {
+		klog.Warningf("validatePortsInManager: manager client is nil, skip checking in knitter-manager")
+		return nil
+	}
+	networkIDs := make(map[string]string)
+	ipGroupNames := make(map[string][]string)
+	for i, port := range ports {
+		networkName := port.EagerAttr.NetworkName
+		networkID, ok := networkIDs[networkName]
+		if !ok {
+			var err error
+			networkID, err = mc.GetNetworkID(tenantID, networkName)
+			if err == errobj.ErrTenantNotRegistered && infra.GetTenantConfig().AutoCreate {
+				klog.Infof("validatePortsInManager: tenant[%s] is not created yet, skip checking in knitter-manager", tenantID)
+				return nil
+			}
+			if err == errobj.ErrTenantNotRegistered {
+				return fmt.Errorf("tenant[%s] is not registered in knitter-manager", tenantID)
+			}
+			if err == errobj.ErrNetworkNotExist {
+				return fmt.Errorf("ports[%d]: attach_to_network[%s] does not exist in tenant[%s]", i, networkName, tenantID)
+			}
+			if err != nil {
+				klog.Warningf("validatePortsInManager: GetNetworkID(%s, %s) error: %v, skip checking in knitter-manager",
+					tenantID, networkName, err)
+				return nil
+			}
+			networkIDs[networkName] = networkID
+		}
+
+		ipGroupName := port.EagerAttr.IPGroupName
+		if ipGroupName == "" {
+			continue
+		}
+		names, ok := ipGroupNames[networkID]
+		if !ok {
+			var err error
+			names, err = mc.GetIPGroupNames(tenantID, networkID)
+			if err != nil {
+				klog.Warningf("validatePortsInManager: GetIPGroupNames(%s, %s) error: %v, skip checking in knitter-manager",
+					tenantID, networkID, err)
+				return nil
+			}
+			ipGroupNames[networkID] = names
+		}
+		if !isStringInSlice(ipGroupName, names) {
+			return fmt.Errorf("ports[%d]: ip_group_name[%s] does not exist in network[%s]", i, ipGroupName, networkName)
+		}
+	}
+	return nil
+}
+
+func isStringInSlice(str string, strs []string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
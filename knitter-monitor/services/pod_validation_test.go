@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/golang/gostub"
+	"github.com/smartystreets/goconvey/convey"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/net-attach-def"
+)
+
+func newPodWithNetworks(networks string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin",
+		Annotations: map[string]string{"networks": networks}}}
+}
+
+func TestValidatePodNetworks(t *testing.T) {
+	mc := &infra.ManagerClient{}
+	monkey.Patch(infra.GetManagerClient, func() *infra.ManagerClient {
+		return mc
+	})
+	defer monkey.UnpatchAll()
+	var networkErr, ipGroupErr error
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "GetNetworkID",
+		func(_ *infra.ManagerClient, tenantID, networkName string) (string, error) {
+			if networkErr != nil {
+				return "", networkErr
+			}
+			if networkName == "net_unknown" {
+				return "", errobj.ErrNetworkNotExist
+			}
+			return networkName + "-id", nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "GetIPGroupNames",
+		func(_ *infra.ManagerClient, tenantID, networkID string) ([]string, error) {
+			return []string{"ig1"}, ipGroupErr
+		})
+
+	convey.Convey("TestValidatePodNetworks", t, func() {
+		convey.Convey("pod without networks uses default network\n", func() {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin"}}
+			convey.So(ValidatePodNetworks("admin", pod), convey.ShouldBeNil)
+		})
+
+		convey.Convey("valid networks\n", func() {
+			pod := newPodWithNetworks(`{"ports": [{"attach_to_network": "net_api", "attributes": {"nic_name": "eth0",
+				"ip_addr": "10.0.0.10"}}, {"attach_to_network": "net_media", "attributes": {"ip_group_name": "ig1"}}]}`)
+			convey.So(ValidatePodNetworks("admin", pod), convey.ShouldBeNil)
+		})
+
+		convey.Convey("bad json\n", func() {
+			err := ValidatePodNetworks("admin", newPodWithNetworks(`{"ports": [`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "not a valid json")
+		})
+
+		convey.Convey("long nic name\n", func() {
+			err := ValidatePodNetworks("admin", newPodWithNetworks(`{"ports": [{"attach_to_network": "net_api"},
+				{"attach_to_network": "net_api", "attributes": {"nic_name": "eth_too_long_name"}}]}`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "ports[1]")
+			convey.So(err.Error(), convey.ShouldContainSubstring, "nic_name[eth_too_long_name]")
+		})
+
+		convey.Convey("illegal ip address\n", func() {
+			err := ValidatePodNetworks("admin", newPodWithNetworks(
+				`{"ports": [{"attach_to_network": "net_api", "attributes": {"ip_addr": "10.0.0.256"}}]}`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "ip_addr[10.0.0.256]")
+		})
+
+		convey.Convey("conflict combinable roles\n", func() {
+			err := ValidatePodNetworks("admin", newPodWithNetworks(`{"ports": [
+				{"attach_to_network": "net_api", "attributes": {"function": "std", "combinable": "true"}},
+				{"attach_to_network": "net_api", "attributes": {"function": "std", "combinable": "true"}}]}`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "is reused in the combined port")
+		})
+
+		convey.Convey("unknown network\n", func() {
+			err := ValidatePodNetworks("admin", newPodWithNetworks(`{"ports": [{"attach_to_network": "net_unknown"}]}`))
+			convey.So(err.Error(), convey.ShouldEqual, "ports[0]: attach_to_network[net_unknown] does not exist in tenant[admin]")
+		})
+
+		convey.Convey("unknown ip group\n", func() {
+			err := ValidatePodNetworks("admin", newPodWithNetworks(
+				`{"ports": [{"attach_to_network": "net_api", "attributes": {"ip_group_name": "ig2"}}]}`))
+			convey.So(err.Error(), convey.ShouldEqual, "ports[0]: ip_group_name[ig2] does not exist in network[net_api]")
+		})
+
+		convey.Convey("unknown tenant\n", func() {
+			networkErr = errobj.ErrTenantNotRegistered
+			defer func() { networkErr = nil }()
+			err := ValidatePodNetworks("admin", newPodWithNetworks(`{"ports": [{"attach_to_network": "net_api"}]}`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "tenant[admin] is not registered")
+		})
+
+		convey.Convey("network selections\n", func() {
+			stubs := gostub.Stub(&infra.GetNetworkAttachmentDefinition,
+				func(nadNs, nadName string) (*netattachdef.NetworkAttachmentDefinition, error) {
+					return nil, apierrors.NewNotFound(schema.GroupResource{}, nadName)
+				})
+			defer stubs.Reset()
+			newPodWithSelections := func(selections string) *v1.Pod {
+				return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "admin",
+					Annotations: map[string]string{netattachdef.NetworkSelectionAnnotation: selections}}}
+			}
+			convey.So(ValidatePodNetworks("admin", newPodWithSelections("net_api@eth1")), convey.ShouldBeNil)
+
+			err := ValidatePodNetworks("admin", newPodWithSelections("net_api, net_unknown"))
+			convey.So(err.Error(), convey.ShouldEqual, "ports[1]: attach_to_network[net_unknown] does not exist in tenant[admin]")
+
+			err = ValidatePodNetworks("admin", newPodWithSelections(`[{"name": "net_api", "ips": ["10.0.0.10/24", "10.0.0.11/24"]}]`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "only one ip is supported")
+
+			err = ValidatePodNetworks("admin", newPodWithSelections(`[{"name": "net_api", "mac": "c2:b0:57"}]`))
+			convey.So(err.Error(), convey.ShouldContainSubstring, "mac_addr[c2:b0:57]")
+		})
+
+		convey.Convey("knitter-manager unavailable\n", func() {
+			networkErr = errors.New("connection refused")
+			defer func() { networkErr = nil }()
+			err := ValidatePodNetworks("admin", newPodWithNetworks(`{"ports": [{"attach_to_network": "net_api"}]}`))
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+}
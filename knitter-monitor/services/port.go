@@ -3,6 +3,9 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
+	"reflect"
 	"regexp"
 	"runtime/debug"
 	"strings"
@@ -22,6 +25,8 @@ const ipReg = "^(1\\d{2}|2[0-4]\\d|25[0-5]|[1-9]\\d|[1-9])\\." +
 	"(1\\d{2}|2[0-4]\\d|25[0-5]|[1-9]\\d|\\d)\\." +
 	"(1\\d{2}|2[0-4]\\d|25[0-5]|[1-9]\\d|\\d)$"
 
+const uuidReg = "^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$"
+
 func GetPortService() PortServiceInterface {
 	return &portService{}
 
@@ -31,6 +36,7 @@ type PortServiceInterface interface {
 	//todo
 	NewPortsWithEagerAttrFromK8s(pod *PodForCreatPort, nwJSON *jason.Object) ([]*Port, error)
 	NewPortsWithEagerAttrAndLazyAttr(pod *PodForCreatPort, nwJSON *jason.Object) ([]*Port, error)
+	FillPortsLazyAttr(pod *PodForCreatPort, ports []*Port) error
 	DeleteBulkPorts(tenantID string, portIDs []string) error
 }
 
@@ -47,10 +53,17 @@ func newPortFromPortForDB(db *daos.PortForDB) *Port {
 		PodName:      db.PodName,
 		PodNs:        db.PodNs,
 		FixIP:        db.FixIP,
+		FixMac:       db.FixMac,
 		IPGroupName:  db.IPGroupName,
 		Metadata:     db.Metadata,
 		Combinable:   db.Combinable,
 		Roles:        db.Roles,
+
+		SecurityGroups:      db.SecurityGroups,
+		PortSecurityEnabled: db.PortSecurityEnabled,
+		AllowedAddressPairs: db.AllowedAddressPairs,
+		Bond:                newBondFromBondForDB(db.Bond),
+		Qos:                 newQosFromQosForDB(db.Qos),
 	}
 	portLazyAttr := PortLazyAttr{
 		ID:         db.ID,
@@ -75,21 +88,37 @@ func (ps *portService) NewPortsWithEagerAttrAndLazyAttr(pod *PodForCreatPort, nw
 	mports, err := ps.NewPortsWithEagerAttrFromK8s(pod, nwJSON)
 	if err != nil {
 		klog.Errorf("ps.NewPortsWithEagerAttrFromK8s(pod :[%v] , nwJSON:[%v]) err, error is [%v]", pod, nwJSON, err)
-		return nil, err
+		return nil, errobj.NewInvalidNetworksError(err)
 	}
-	createBulkPortsResp, err := ps.CreateBulkPorts(pod, mports)
+	err = ps.FillPortsLazyAttr(pod, mports)
 	if err != nil {
-		klog.Errorf("ps.NewPortsWithEagerAttrFromK8s() err, error is [%v]", err)
+		klog.Errorf("ps.FillPortsLazyAttr(pod :[%v], mports) err, error is [%v]", pod, err)
 		return nil, err
 	}
+	return mports, nil
+
+}
+
+// FillPortsLazyAttr creates the ports with eager attributes in knitter-manager
+// and fills the lazy attributes of ports from the created ones.
+func (ps *portService) FillPortsLazyAttr(pod *PodForCreatPort, ports []*Port) error {
+	err := assignStickyIPs(pod, ports)
+	if err != nil {
+		klog.Errorf("assignStickyIPs(pod :[%v]) err, error is [%v]", pod, err)
+		return err
+	}
+	createBulkPortsResp, err := ps.CreateBulkPorts(pod, ports)
+	if err != nil {
+		klog.Errorf("ps.CreateBulkPorts() err, error is [%v]", err)
+		return err
+	}
 	//todo refactor
-	err = fillPortLazyAttr(createBulkPortsResp, mports, pod.TenantID)
+	err = fillPortLazyAttr(createBulkPortsResp, ports, pod.TenantID)
 	if err != nil {
-		klog.Errorf("fillPortLazyAttr(createBulkPortsResp, mports, pod.TenantID) err, error is [%v]", err)
-		return nil, err
+		klog.Errorf("fillPortLazyAttr(createBulkPortsResp, ports, pod.TenantID) err, error is [%v]", err)
+		return err
 	}
-	return mports, nil
-
+	return nil
 }
 
 func (ps *portService) NewPortsWithEagerAttrFromK8s(pod *PodForCreatPort, nwJSON *jason.Object) ([]*Port,
@@ -171,7 +200,10 @@ func IsSameRoles(portObj1, portObj2 *Port) bool {
 		portObj1.EagerAttr.Accelerate == portObj2.EagerAttr.Accelerate &&
 		portObj1.EagerAttr.PodName == portObj2.EagerAttr.PodName &&
 		portObj1.EagerAttr.PodNs == portObj2.EagerAttr.PodNs &&
-		portObj1.EagerAttr.IPGroupName == portObj2.EagerAttr.IPGroupName
+		portObj1.EagerAttr.IPGroupName == portObj2.EagerAttr.IPGroupName &&
+		isSamePortSecurity(portObj1, portObj2) &&
+		reflect.DeepEqual(portObj1.EagerAttr.Bond, portObj2.EagerAttr.Bond) &&
+		reflect.DeepEqual(portObj1.EagerAttr.Qos, portObj2.EagerAttr.Qos)
 	//portObj1.EagerAttr.Metadata == portObj2.EagerAttr.Metadata
 }
 
@@ -308,11 +340,18 @@ func (p *Port) transferToPortForDB() *daos.PortForDB {
 		PodName:      p.EagerAttr.PodName,
 		PodNs:        p.EagerAttr.PodNs,
 		FixIP:        p.EagerAttr.FixIP,
+		FixMac:       p.EagerAttr.FixMac,
 		IPGroupName:  p.EagerAttr.IPGroupName,
 		Metadata:     p.EagerAttr.Metadata,
 		Combinable:   p.EagerAttr.Combinable,
 		Roles:        p.EagerAttr.Roles,
 
+		SecurityGroups:      p.EagerAttr.SecurityGroups,
+		PortSecurityEnabled: p.EagerAttr.PortSecurityEnabled,
+		AllowedAddressPairs: p.EagerAttr.AllowedAddressPairs,
+		Bond:                newBondForDB(p.EagerAttr.Bond),
+		Qos:                 newQosForDB(p.EagerAttr.Qos),
+
 		ID:         p.LazyAttr.ID,
 		LazyName:   p.LazyAttr.Name,
 		TenantID:   p.LazyAttr.TenantID,
@@ -372,10 +411,16 @@ func (p *Port) fillPortEagerAttr(podNs, podName string, portJSON *jason.Object)
 	p.EagerAttr.VnicType = eagerPort.VnicType
 	p.EagerAttr.Accelerate = eagerPort.Accelerate
 	p.EagerAttr.FixIP = eagerPort.FixIP
+	p.EagerAttr.FixMac = eagerPort.FixMac
 	p.EagerAttr.IPGroupName = eagerPort.IPGroupName
 	p.EagerAttr.PodNs = podNs
 	p.EagerAttr.PodName = podName
 	p.EagerAttr.Combinable = eagerPort.Combinable
+	p.EagerAttr.SecurityGroups = eagerPort.SecurityGroups
+	p.EagerAttr.PortSecurityEnabled = eagerPort.PortSecurityEnabled
+	p.EagerAttr.AllowedAddressPairs = eagerPort.AllowedAddressPairs
+	p.EagerAttr.Bond = eagerPort.Bond
+	p.EagerAttr.Qos = eagerPort.Qos
 	return nil
 }
 
@@ -391,7 +436,12 @@ func (p *Port) TransformToMangerCreatePortReq(pod *PodForCreatPort) *infra.Manag
 		PodNs:       p.EagerAttr.PodNs,
 		PodName:     p.EagerAttr.PodName,
 		FixIP:       p.EagerAttr.FixIP,
+		FixMac:      p.EagerAttr.FixMac,
 		IPGroupName: p.EagerAttr.IPGroupName,
+
+		SecurityGroups:      p.EagerAttr.SecurityGroups,
+		PortSecurityEnabled: p.EagerAttr.PortSecurityEnabled,
+		AllowedAddressPairs: p.EagerAttr.AllowedAddressPairs,
 	}
 }
 
@@ -404,10 +454,17 @@ type PortEagerAttr struct {
 	PodName      string
 	PodNs        string
 	FixIP        string
+	FixMac       string
 	IPGroupName  string
 	Combinable   string
 	Metadata     interface{}
 	Roles        []string
+
+	SecurityGroups      []string
+	PortSecurityEnabled *bool
+	AllowedAddressPairs []ports.AddressPair
+	Bond                *BondAttr
+	Qos                 *QosAttr
 }
 
 type PortLazyAttr struct {
@@ -428,16 +485,23 @@ type EagerPort struct {
 	VnicType     string
 	Accelerate   string
 	FixIP        string
+	FixMac       string
 	IPGroupName  string
 	PortFunc     string
 	Combinable   string
+
+	SecurityGroups      []string
+	PortSecurityEnabled *bool
+	AllowedAddressPairs []ports.AddressPair
+	Bond                *BondAttr
+	Qos                 *QosAttr
 }
 
 func (ep *EagerPort) Transform(portJSON *jason.Object) error {
 	networkName, err := portJSON.GetString("attach_to_network")
 	if err != nil {
 		klog.Error("Get port attach Network ERROR:", err)
-		return err
+		return fmt.Errorf("%v:attach_to_network is required", err)
 	} else if networkName == "" {
 		err = errors.New("port attach network is blank")
 		klog.Error("port attach network is blank")
@@ -453,7 +517,7 @@ func (ep *EagerPort) Transform(portJSON *jason.Object) error {
 	}
 	if len(portName) > 12 {
 		klog.Errorf("Lenth of port name is greater than 12")
-		return errors.New("lenth of port name is illegal")
+		return fmt.Errorf("lenth of port name is illegal: nic_name[%s] is longer than 12 characters", portName)
 	}
 	portFunc, err := portJSON.GetString("attributes", "function")
 	if err != nil || portFunc == "" {
@@ -485,7 +549,15 @@ func (ep *EagerPort) Transform(portJSON *jason.Object) error {
 			klog.Infof("ip_address is legitimate")
 		} else {
 			klog.Errorf("ip_address is illegal")
-			return errors.New("ip_address is illegal")
+			return fmt.Errorf("ip_address is illegal: ip_addr[%s] is not a valid IPv4 address", ipAddress)
+		}
+	}
+
+	macAddress, _ := portJSON.GetString("attributes", "mac_addr")
+	if macAddress != "" {
+		if _, err := net.ParseMAC(macAddress); err != nil {
+			klog.Errorf("mac_address is illegal: %v", err)
+			return fmt.Errorf("mac_address is illegal: mac_addr[%s] is not a valid MAC address", macAddress)
 		}
 	}
 
@@ -499,17 +571,137 @@ func (ep *EagerPort) Transform(portJSON *jason.Object) error {
 		combinable = "false"
 	}
 
+	err = ep.transformPortSecurity(portJSON)
+	if err != nil {
+		klog.Errorf("transformPortSecurity error: %v", err)
+		return err
+	}
+	if ipGroupName != "" && ep.hasPortSecurity() {
+		klog.Errorf("port security of ip group[%s] port is illegal", ipGroupName)
+		return errobj.ErrPortSecurityWithIPGroup
+	}
+	err = ep.transformBond(portJSON, portType)
+	if err != nil {
+		klog.Errorf("transformBond error: %v", err)
+		return err
+	}
+	err = ep.transformQos(portJSON, portType)
+	if err != nil {
+		klog.Errorf("transformQos error: %v", err)
+		return err
+	}
+
 	ep.NetworkName = networkName
 	ep.NetworkPlane = portFunc
 	ep.PortName = portName
 	ep.VnicType = portType
 	ep.Accelerate = isUseDpdk
 	ep.FixIP = ipAddress
+	ep.FixMac = macAddress
 	ep.IPGroupName = ipGroupName
 	ep.Combinable = combinable
 	return nil
 }
 
+func (ep *EagerPort) hasPortSecurity() bool {
+	return len(ep.SecurityGroups) != 0 || ep.PortSecurityEnabled != nil || len(ep.AllowedAddressPairs) != 0
+}
+
+func (ep *EagerPort) transformPortSecurity(portJSON *jason.Object) error {
+	if sgValue, err := portJSON.GetValue("attributes", "security_groups"); err == nil {
+		sgs, err := sgValue.Array()
+		if err != nil {
+			return errobj.ErrInvalidSecurityGroups
+		}
+		for _, sg := range sgs {
+			sgID, err := sg.String()
+			if err != nil {
+				return errobj.ErrInvalidSecurityGroups
+			}
+			if isUUID, _ := regexp.MatchString(uuidReg, sgID); !isUUID {
+				klog.Errorf("security group[%s] is not an uuid", sgID)
+				return errobj.ErrInvalidSecurityGroups
+			}
+			ep.SecurityGroups = append(ep.SecurityGroups, sgID)
+		}
+	}
+
+	if enabledValue, err := portJSON.GetValue("attributes", "port_security_enabled"); err == nil {
+		enabled, err := parseBoolValue(enabledValue)
+		if err != nil {
+			return errobj.ErrInvalidPortSecurityEnabled
+		}
+		ep.PortSecurityEnabled = &enabled
+	}
+
+	if pairsValue, err := portJSON.GetValue("attributes", "allowed_address_pairs"); err == nil {
+		pairs, err := pairsValue.ObjectArray()
+		if err != nil {
+			return errobj.ErrInvalidAllowedAddressPairs
+		}
+		if len(pairs) > constvalue.MaxAllowedAddressPairs {
+			return errobj.ErrTooManyAllowedAddressPairs
+		}
+		for _, pairObj := range pairs {
+			pair, err := parseAddressPair(pairObj)
+			if err != nil {
+				return err
+			}
+			ep.AllowedAddressPairs = append(ep.AllowedAddressPairs, pair)
+		}
+	}
+
+	if ep.PortSecurityEnabled != nil && !*ep.PortSecurityEnabled &&
+		(len(ep.SecurityGroups) != 0 || len(ep.AllowedAddressPairs) != 0) {
+		return errobj.ErrPortSecurityDisabledConflict
+	}
+	return nil
+}
+
+func parseBoolValue(value *jason.Value) (bool, error) {
+	if b, err := value.Boolean(); err == nil {
+		return b, nil
+	}
+	str, err := value.String()
+	if err != nil {
+		return false, err
+	}
+	switch str {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, errors.New("invalid bool value: " + str)
+}
+
+func parseAddressPair(pairObj *jason.Object) (ports.AddressPair, error) {
+	ip, err := pairObj.GetString("ip_address")
+	if err != nil {
+		return ports.AddressPair{}, errobj.ErrInvalidAllowedAddressPairs
+	}
+	if net.ParseIP(ip) == nil {
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			klog.Errorf("allowed address pair ip_address[%s] is illegal", ip)
+			return ports.AddressPair{}, errobj.ErrInvalidAllowedAddressPairs
+		}
+	}
+	mac, _ := pairObj.GetString("mac_address")
+	if mac != "" {
+		if _, err := net.ParseMAC(mac); err != nil {
+			klog.Errorf("allowed address pair mac_address[%s] is illegal", mac)
+			return ports.AddressPair{}, errobj.ErrInvalidAllowedAddressPairs
+		}
+	}
+	return ports.AddressPair{IPAddress: ip, MACAddress: mac}, nil
+}
+
+func isSamePortSecurity(portObj1, portObj2 *Port) bool {
+	return reflect.DeepEqual(portObj1.EagerAttr.SecurityGroups, portObj2.EagerAttr.SecurityGroups) &&
+		reflect.DeepEqual(portObj1.EagerAttr.PortSecurityEnabled, portObj2.EagerAttr.PortSecurityEnabled) &&
+		reflect.DeepEqual(portObj1.EagerAttr.AllowedAddressPairs, portObj2.EagerAttr.AllowedAddressPairs)
+}
+
 func isIPLegitimate(ipAddress string) (bool, error) {
 	return regexp.MatchString(ipReg, ipAddress)
 }
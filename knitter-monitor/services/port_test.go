@@ -3,11 +3,14 @@ package services
 import (
 	"encoding/json"
 	"errors"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
 	"github.com/ZTE/Knitter/knitter-monitor/infra"
 	"github.com/antonholmquist/jason"
 	"github.com/bouk/monkey"
+	"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
 	"github.com/smartystreets/goconvey/convey"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -195,6 +198,91 @@ func TestPortService_NewPortsWithEagerAttrFromK8s(t *testing.T) {
 
 }
 
+func TestEagerPort_TransformPortSecurity(t *testing.T) {
+	transform := func(attrs string) (*EagerPort, error) {
+		portJSON, _ := jason.NewObjectFromBytes([]byte(
+			`{"attach_to_network": "net_api", "attributes": {"nic_name": "eth1"` + attrs + `}}`))
+		ep := &EagerPort{}
+		return ep, ep.Transform(portJSON)
+	}
+
+	convey.Convey("TestEagerPort_TransformPortSecurity", t, func() {
+		convey.Convey("no port security\n", func() {
+			ep, err := transform("")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ep.SecurityGroups, convey.ShouldBeNil)
+			convey.So(ep.PortSecurityEnabled, convey.ShouldBeNil)
+			convey.So(ep.AllowedAddressPairs, convey.ShouldBeNil)
+		})
+
+		convey.Convey("security groups and allowed address pairs\n", func() {
+			ep, err := transform(`, "security_groups": ["0c6cb4cc-0f12-4e1d-9e3b-6a2c1e0d4f11"],
+				"port_security_enabled": "true",
+				"allowed_address_pairs": [{"ip_address": "10.0.0.100"},
+					{"ip_address": "10.1.0.0/24", "mac_address": "fa:16:3e:00:00:01"}]`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ep.SecurityGroups, convey.ShouldResemble, []string{"0c6cb4cc-0f12-4e1d-9e3b-6a2c1e0d4f11"})
+			convey.So(*ep.PortSecurityEnabled, convey.ShouldBeTrue)
+			convey.So(ep.AllowedAddressPairs, convey.ShouldResemble, []ports.AddressPair{
+				{IPAddress: "10.0.0.100"}, {IPAddress: "10.1.0.0/24", MACAddress: "fa:16:3e:00:00:01"}})
+		})
+
+		convey.Convey("port security disabled\n", func() {
+			ep, err := transform(`, "port_security_enabled": false`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(*ep.PortSecurityEnabled, convey.ShouldBeFalse)
+		})
+
+		convey.Convey("illegal attributes\n", func() {
+			_, err := transform(`, "security_groups": "default"`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidSecurityGroups)
+			_, err = transform(`, "security_groups": ["default"]`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidSecurityGroups)
+			_, err = transform(`, "port_security_enabled": "yes"`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidPortSecurityEnabled)
+			_, err = transform(`, "allowed_address_pairs": [{"ip_address": "10.0.0.300"}]`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidAllowedAddressPairs)
+			_, err = transform(`, "allowed_address_pairs": [{"ip_address": "10.0.0.3", "mac_address": "fa:16"}]`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidAllowedAddressPairs)
+			_, err = transform(`, "allowed_address_pairs": [` + strings.Repeat(`{"ip_address": "10.0.0.3"},`, 10) +
+				`{"ip_address": "10.0.0.4"}]`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrTooManyAllowedAddressPairs)
+			_, err = transform(`, "port_security_enabled": "false", "allowed_address_pairs": [{"ip_address": "10.0.0.3"}]`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrPortSecurityDisabledConflict)
+			_, err = transform(`, "ip_group_name": "ig0", "port_security_enabled": "false"`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrPortSecurityWithIPGroup)
+		})
+
+		convey.Convey("passed to manager request\n", func() {
+			ep, _ := transform(`, "security_groups": ["0c6cb4cc-0f12-4e1d-9e3b-6a2c1e0d4f11"]`)
+			port := &Port{}
+			portJSON, _ := jason.NewObjectFromBytes([]byte(
+				`{"attach_to_network": "net_api", "attributes": {"security_groups": ["0c6cb4cc-0f12-4e1d-9e3b-6a2c1e0d4f11"]}}`))
+			convey.So(port.fillPortEagerAttr("ns", "pod", portJSON), convey.ShouldBeNil)
+			req := port.TransformToMangerCreatePortReq(&PodForCreatPort{TenantID: "admin"})
+			convey.So(req.SecurityGroups, convey.ShouldResemble, ep.SecurityGroups)
+			convey.So(port.transferToPortForDB().SecurityGroups, convey.ShouldResemble, ep.SecurityGroups)
+		})
+	})
+}
+
+func TestEagerPort_TransformMacAddr(t *testing.T) {
+	convey.Convey("TestEagerPort_TransformMacAddr", t, func() {
+		portJSON, _ := jason.NewObjectFromBytes([]byte(
+			`{"attach_to_network": "net_api", "attributes": {"mac_addr": "c2:b0:57:49:47:f1"}}`))
+		port := &Port{}
+		convey.So(port.fillPortEagerAttr("ns", "pod", portJSON), convey.ShouldBeNil)
+		convey.So(port.TransformToMangerCreatePortReq(&PodForCreatPort{TenantID: "admin"}).FixMac,
+			convey.ShouldEqual, "c2:b0:57:49:47:f1")
+		convey.So(port.transferToPortForDB().FixMac, convey.ShouldEqual, "c2:b0:57:49:47:f1")
+
+		portJSON, _ = jason.NewObjectFromBytes([]byte(
+			`{"attach_to_network": "net_api", "attributes": {"mac_addr": "c2:b0:57"}}`))
+		err := (&EagerPort{}).Transform(portJSON)
+		convey.So(err.Error(), convey.ShouldEqual, "mac_address is illegal: mac_addr[c2:b0:57] is not a valid MAC address")
+	})
+}
+
 func TestDestoryBulkPorts(t *testing.T) {
 	var mc *infra.ManagerClient
 	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "DeleteNeutronPort",
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"github.com/antonholmquist/jason"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/daos"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// QosAttr asks knitter-agent to limit the rate of the port on br-int and to
+// mark the DSCP of the IP packets sent by the pod. Rates are in kbps and
+// bursts in kb, ingress is the traffic br-int receives from the pod and
+// egress is the traffic br-int sends to the pod, 0 means no limit.
+type QosAttr struct {
+	IngressRate  int64 `json:"ingress_rate,omitempty"`
+	IngressBurst int64 `json:"ingress_burst,omitempty"`
+	EgressRate   int64 `json:"egress_rate,omitempty"`
+	EgressBurst  int64 `json:"egress_burst,omitempty"`
+	Dscp         *int  `json:"dscp,omitempty"`
+}
+
+func getQosRate(qosObj *jason.Object, key string) (int64, error) {
+	value, err := qosObj.GetValue(key)
+	if err != nil {
+		return 0, nil
+	}
+	rate, err := value.Int64()
+	if err != nil || rate < 0 {
+		return 0, errobj.ErrInvalidQosRate
+	}
+	return rate, nil
+}
+
+// transformQos parses the qos attribute as
+// {"ingress_rate": 100000, "ingress_burst": 10000, "egress_rate": 100000, "egress_burst": 10000, "dscp": 46},
+// all fields are optional.
+func (ep *EagerPort) transformQos(portJSON *jason.Object, portType string) error {
+	qosObj, err := portJSON.GetObject("attributes", "qos")
+	if err != nil {
+		if _, errValue := portJSON.GetValue("attributes", "qos"); errValue == nil {
+			return errobj.ErrInvalidQos
+		}
+		return nil
+	}
+
+	qos := &QosAttr{}
+	if qos.IngressRate, err = getQosRate(qosObj, "ingress_rate"); err != nil {
+		return err
+	}
+	if qos.IngressBurst, err = getQosRate(qosObj, "ingress_burst"); err != nil {
+		return err
+	}
+	if qos.EgressRate, err = getQosRate(qosObj, "egress_rate"); err != nil {
+		return err
+	}
+	if qos.EgressBurst, err = getQosRate(qosObj, "egress_burst"); err != nil {
+		return err
+	}
+	if (qos.IngressBurst != 0 && qos.IngressRate == 0) || (qos.EgressBurst != 0 && qos.EgressRate == 0) {
+		return errobj.ErrQosBurstWithoutRate
+	}
+	if dscpValue, err := qosObj.GetValue("dscp"); err == nil {
+		dscp, err := dscpValue.Int64()
+		if err != nil || dscp < 0 || dscp > constvalue.MaxQosDscp {
+			return errobj.ErrInvalidQosDscp
+		}
+		qos.Dscp = new(int)
+		*qos.Dscp = int(dscp)
+	}
+
+	if portType != "normal" || ep.Bond != nil {
+		klog.Errorf("transformQos: qos of nic_type[%s] port with bond[%v] is unsupported", portType, ep.Bond)
+		return errobj.ErrQosUnsupportedNicType
+	}
+	ep.Qos = qos
+	return nil
+}
+
+func newQosForDB(qos *QosAttr) *daos.QosForDB {
+	if qos == nil {
+		return nil
+	}
+	return &daos.QosForDB{IngressRate: qos.IngressRate, IngressBurst: qos.IngressBurst,
+		EgressRate: qos.EgressRate, EgressBurst: qos.EgressBurst, Dscp: qos.Dscp}
+}
+
+func newQosFromQosForDB(db *daos.QosForDB) *QosAttr {
+	if db == nil {
+		return nil
+	}
+	return &QosAttr{IngressRate: db.IngressRate, IngressBurst: db.IngressBurst,
+		EgressRate: db.EgressRate, EgressBurst: db.EgressBurst, Dscp: db.Dscp}
+}
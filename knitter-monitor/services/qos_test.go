@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/smartystreets/goconvey/convey"
+
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+)
+
+func TestEagerPort_TransformQos(t *testing.T) {
+	transform := func(attrs string) (*EagerPort, error) {
+		portJSON, _ := jason.NewObjectFromBytes([]byte(
+			`{"attach_to_network": "net_api", "attributes": {"nic_name": "eth1"` + attrs + `}}`))
+		ep := &EagerPort{}
+		return ep, ep.Transform(portJSON)
+	}
+
+	convey.Convey("TestEagerPort_TransformQos", t, func() {
+		convey.Convey("no qos\n", func() {
+			ep, err := transform("")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ep.Qos, convey.ShouldBeNil)
+		})
+
+		convey.Convey("qos with rates and dscp\n", func() {
+			ep, err := transform(`, "qos": {"ingress_rate": 100000, "ingress_burst": 10000,
+				"egress_rate": 200000, "dscp": 0}`)
+			convey.So(err, convey.ShouldBeNil)
+			dscp := 0
+			convey.So(ep.Qos, convey.ShouldResemble,
+				&QosAttr{IngressRate: 100000, IngressBurst: 10000, EgressRate: 200000, Dscp: &dscp})
+		})
+
+		convey.Convey("illegal qos\n", func() {
+			_, err := transform(`, "qos": 1000`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidQos)
+			_, err = transform(`, "qos": {"ingress_rate": -1}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidQosRate)
+			_, err = transform(`, "qos": {"egress_rate": "10M"}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidQosRate)
+			_, err = transform(`, "qos": {"egress_burst": 1000}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrQosBurstWithoutRate)
+			_, err = transform(`, "qos": {"dscp": 64}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrInvalidQosDscp)
+			_, err = transform(`, "nic_type": "direct", "qos": {"dscp": 46}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrQosUnsupportedNicType)
+			_, err = transform(`, "bond": {}, "qos": {"dscp": 46}`)
+			convey.So(err, convey.ShouldEqual, errobj.ErrQosUnsupportedNicType)
+		})
+
+		convey.Convey("passed to db and agent\n", func() {
+			portJSON, _ := jason.NewObjectFromBytes([]byte(
+				`{"attach_to_network": "net_api", "attributes": {"qos": {"egress_rate": 1000, "dscp": 46}}}`))
+			port := &Port{}
+			convey.So(port.fillPortEagerAttr("ns", "pod", portJSON), convey.ShouldBeNil)
+			portForDB := port.transferToPortForDB()
+			convey.So(*portForDB.Qos.Dscp, convey.ShouldEqual, 46)
+			convey.So(newPortFromPortForDB(portForDB).EagerAttr.Qos, convey.ShouldResemble, port.EagerAttr.Qos)
+		})
+	})
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/daos"
+	"github.com/ZTE/Knitter/knitter-monitor/err-obj"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+// statefulSetStore caches the StatefulSets watched by
+// createPortForPodController, pods of the StatefulSets with sticky ips get
+// their ips from the ip groups of the StatefulSets.
+var statefulSetStore cache.Store
+
+// stickyIPLock serializes the updates of the ip groups of StatefulSets.
+var stickyIPLock sync.Mutex
+
+// StatefulSetOfPod is the StatefulSet owning the pod and the ordinal of pod.
+type StatefulSetOfPod struct {
+	Name    string
+	Ordinal int
+}
+
+func getStatefulSetOfPod(k8sPod *v1.Pod) *StatefulSetOfPod {
+	podName := k8sPod.GetObjectMeta().GetName()
+	for _, ref := range k8sPod.GetObjectMeta().GetOwnerReferences() {
+		if ref.Kind != "StatefulSet" || !strings.HasPrefix(podName, ref.Name+"-") {
+			continue
+		}
+		ordinal, err := strconv.Atoi(strings.TrimPrefix(podName, ref.Name+"-"))
+		if err != nil || ordinal < 0 {
+			return nil
+		}
+		return &StatefulSetOfPod{Name: ref.Name, Ordinal: ordinal}
+	}
+	return nil
+}
+
+func getK8sStatefulSet(stsNs, stsName string) *appsv1beta2.StatefulSet {
+	if statefulSetStore == nil {
+		return nil
+	}
+	obj, exists, err := statefulSetStore.GetByKey(stsNs + "/" + stsName)
+	if err != nil || !exists {
+		return nil
+	}
+	sts, ok := obj.(*appsv1beta2.StatefulSet)
+	if !ok {
+		return nil
+	}
+	return sts
+}
+
+func isStickyIPEnabled(sts *appsv1beta2.StatefulSet) bool {
+	return sts.GetObjectMeta().GetAnnotations()[constvalue.StickyIPAnnotation] == "true"
+}
+
+func getReplicasOfStatefulSet(sts *appsv1beta2.StatefulSet) int {
+	if sts.Spec.Replicas == nil {
+		return 1
+	}
+	return int(*sts.Spec.Replicas)
+}
+
+// isStickyIPPort reports whether the port gets its ip from the ip group of
+// StatefulSet, the ports with ip or ip group specified are left as they are.
+func isStickyIPPort(port *Port) bool {
+	return port.EagerAttr.IPGroupName == "" && port.EagerAttr.FixIP == "" &&
+		port.EagerAttr.VnicType == constvalue.DefaultVnicType
+}
+
+func stickyIPGroupName(stsNs, stsName, networkName string) string {
+	return fmt.Sprintf("sts_%s_%s_%s", stsNs, stsName, networkName)
+}
+
+// assignStickyIPs pins the ports of the pod of StatefulSet with sticky ips to
+// the ips of its ordinal, the ip groups of the StatefulSet are created or
+// grown on demand, since pods may come before the scale event.
+func assignStickyIPs(pod *PodForCreatPort, ports []*Port) (err error) {
+	if pod.StatefulSet == nil {
+		return nil
+	}
+	sts := getK8sStatefulSet(pod.PodNs, pod.StatefulSet.Name)
+	if sts == nil || !isStickyIPEnabled(sts) {
+		return nil
+	}
+	size := getReplicasOfStatefulSet(sts)
+	if pod.StatefulSet.Ordinal >= size {
+		size = pod.StatefulSet.Ordinal + 1
+	}
+	if size > constvalue.MaxIPsInGroup {
+		return fmt.Errorf("sticky ip is unsupported for StatefulSet[%v/%v] with more than %v replicas",
+			pod.PodNs, pod.StatefulSet.Name, constvalue.MaxIPsInGroup)
+	}
+
+	stickyIPLock.Lock()
+	defer stickyIPLock.Unlock()
+	stsForDB, err := daos.GetStatefulSetDao().Get(pod.PodNs, pod.StatefulSet.Name)
+	if errobj.IsKeyNotFoundError(err) {
+		stsForDB = &daos.StatefulSetForDB{Namespace: pod.PodNs, Name: pod.StatefulSet.Name, TenantID: pod.TenantID}
+	} else if err != nil {
+		klog.Errorf("assignStickyIPs: daos.GetStatefulSetDao().Get(%v/%v) err, error is [%v]", pod.PodNs, pod.StatefulSet.Name, err)
+		return err
+	}
+	if stsForDB.TenantID != pod.TenantID {
+		klog.Warningf("assignStickyIPs: tenant of StatefulSet[%v/%v] changed from [%v] to [%v], skip sticky ips",
+			pod.PodNs, pod.StatefulSet.Name, stsForDB.TenantID, pod.TenantID)
+		return nil
+	}
+	// groups created are recorded even if the ports fail
+	defer func() {
+		errSave := daos.GetStatefulSetDao().Save(stsForDB)
+		if errSave != nil && err == nil {
+			err = errSave
+		}
+	}()
+
+	for _, port := range ports {
+		if !isStickyIPPort(port) {
+			continue
+		}
+		group, ig, err := ensureStickyIPGroup(stsForDB, port.EagerAttr.NetworkName, size)
+		if err != nil {
+			klog.Errorf("assignStickyIPs: ensureStickyIPGroup of network[%v] err, error is [%v]", port.EagerAttr.NetworkName, err)
+			return err
+		}
+		ipAddr, err := pinIPOfOrdinal(group, ig, pod.StatefulSet.Ordinal)
+		if err != nil {
+			klog.Errorf("assignStickyIPs: pinIPOfOrdinal(%v) of group[%v] err, error is [%v]", pod.StatefulSet.Ordinal, group.Name, err)
+			return err
+		}
+		klog.Infof("assignStickyIPs: port[%v] of pod[%v/%v] pinned to ip[%v] of group[%v]",
+			port.EagerAttr.PortName, pod.PodNs, pod.PodName, ipAddr, group.Name)
+		port.EagerAttr.IPGroupName = group.Name
+		port.EagerAttr.FixIP = ipAddr
+	}
+	return nil
+}
+
+// ensureStickyIPGroup returns the ip group of the StatefulSet in the network
+// with at least size ips.
+func ensureStickyIPGroup(stsForDB *daos.StatefulSetForDB, networkName string, size int) (*daos.StickyIPGroupForDB, *infra.IPGroup, error) {
+	mc := infra.GetManagerClient()
+	for _, group := range stsForDB.IPGroups {
+		if group.NetworkName != networkName {
+			continue
+		}
+		ig, err := mc.GetIPGroup(stsForDB.TenantID, group.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(ig.IPs) < size {
+			ig, err = mc.ResizeIPGroup(stsForDB.TenantID, group.ID, size)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		return group, ig, nil
+	}
+
+	networkID, err := mc.GetNetworkID(stsForDB.TenantID, networkName)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := stickyIPGroupName(stsForDB.Namespace, stsForDB.Name, networkName)
+	// the group may be created before the record is saved
+	ig, err := mc.GetIPGroupByName(stsForDB.TenantID, networkID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ig == nil {
+		ig, err = mc.CreateIPGroup(stsForDB.TenantID, networkID, name, size)
+	} else if len(ig.IPs) < size {
+		ig, err = mc.ResizeIPGroup(stsForDB.TenantID, ig.ID, size)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	group := &daos.StickyIPGroupForDB{NetworkName: networkName, ID: ig.ID, Name: ig.Name, IPs: make(map[int]string)}
+	stsForDB.IPGroups = append(stsForDB.IPGroups, group)
+	return group, ig, nil
+}
+
+// pinIPOfOrdinal returns the ip pinned to the ordinal, a new ordinal is
+// pinned to a free ip which is not pinned to the others.
+func pinIPOfOrdinal(group *daos.StickyIPGroupForDB, ig *infra.IPGroup, ordinal int) (string, error) {
+	if group.IPs == nil {
+		group.IPs = make(map[int]string)
+	}
+	pinnedIPs := make(map[string]bool)
+	for _, ipAddr := range group.IPs {
+		pinnedIPs[ipAddr] = true
+	}
+	ipAddr, ok := group.IPs[ordinal]
+	if ok && isIPInGroup(ig, ipAddr) {
+		return ipAddr, nil
+	}
+	for _, ip := range ig.IPs {
+		if !ip.Used && !pinnedIPs[ip.IPAddr] {
+			group.IPs[ordinal] = ip.IPAddr
+			return ip.IPAddr, nil
+		}
+	}
+	return "", fmt.Errorf("no available ip in group[%v] for ordinal[%v]", group.Name, ordinal)
+}
+
+func isIPInGroup(ig *infra.IPGroup, ipAddr string) bool {
+	for _, ip := range ig.IPs {
+		if ip.IPAddr == ipAddr {
+			return true
+		}
+	}
+	return false
+}
+
+func (cpc *createPortForPodController) newStatefulSetInformer() {
+	watchlist := cache.NewListWatchFromClient(cpc.clientSet.AppsV1beta2().RESTClient(), "statefulsets",
+		v1.NamespaceAll, fields.Everything())
+
+	cpc.stsStoreIndexer, cpc.stsController = cache.NewIndexerInformer(
+		watchlist,
+		&appsv1beta2.StatefulSet{},
+		time.Second*0,
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: cpc.enqueueUpdateStatefulSet,
+			DeleteFunc: cpc.enqueueDeleteStatefulSet,
+		},
+		cache.Indexers{},
+	)
+	statefulSetStore = cpc.stsStoreIndexer
+}
+
+func (cpc *createPortForPodController) enqueueUpdateStatefulSet(oldObj, newObj interface{}) {
+	oldSts, ok := oldObj.(*appsv1beta2.StatefulSet)
+	if !ok {
+		return
+	}
+	newSts, ok := newObj.(*appsv1beta2.StatefulSet)
+	if !ok || getReplicasOfStatefulSet(oldSts) == getReplicasOfStatefulSet(newSts) {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(newSts)
+	if err != nil {
+		klog.Errorf("enqueueUpdateStatefulSet: MetaNamespaceKeyFunc err, error is [%v]", err)
+		return
+	}
+	cpc.statefulSetsQueue.Add(key)
+	klog.Infof("enqueueUpdateStatefulSet: StatefulSet[%v] scaled to [%v]", key, getReplicasOfStatefulSet(newSts))
+}
+
+func (cpc *createPortForPodController) enqueueDeleteStatefulSet(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Errorf("enqueueDeleteStatefulSet: DeletionHandlingMetaNamespaceKeyFunc err, error is [%v]", err)
+		return
+	}
+	cpc.statefulSetsQueue.Add(key)
+	klog.Infof("enqueueDeleteStatefulSet: StatefulSet[%v] deleted", key)
+}
+
+func (cpc *createPortForPodController) syncStatefulSetWorker() {
+	klog.Info("syncStatefulSetWorker start ")
+	workFunc := func() bool {
+		key, quit := cpc.statefulSetsQueue.Get()
+		if quit {
+			return true
+		}
+		defer cpc.statefulSetsQueue.Done(key)
+
+		err := cpc.syncStatefulSet(key.(string))
+		if err != nil {
+			klog.Warningf("syncStatefulSetWorker: cpc.syncStatefulSet(key:[%v]) err, error is [%v], requeue it", key, err)
+			cpc.statefulSetsQueue.AddRateLimited(key)
+			return false
+		}
+		cpc.statefulSetsQueue.Forget(key)
+		return false
+	}
+	for {
+		if quit := workFunc(); quit {
+			klog.Infof("syncStatefulSetWorker shut down")
+			return
+		}
+	}
+}
+
+// syncStatefulSet resizes the ip groups of the StatefulSet to its replicas,
+// or deletes them after the StatefulSet is deleted. The ips in use by the
+// pods being deleted fail the sync, it is retried until the pods are gone.
+func (cpc *createPortForPodController) syncStatefulSet(key string) error {
+	stsNs, stsName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		klog.Errorf("syncStatefulSet: cache.SplitMetaNamespaceKey(key:[%v]) err, error is [%v], drop it", key, err)
+		return nil
+	}
+	stickyIPLock.Lock()
+	defer stickyIPLock.Unlock()
+	stsForDB, err := daos.GetStatefulSetDao().Get(stsNs, stsName)
+	if errobj.IsKeyNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sts := getK8sStatefulSet(stsNs, stsName)
+	if sts == nil {
+		return releaseStickyIPs(stsForDB)
+	}
+	replicas := getReplicasOfStatefulSet(sts)
+	if replicas > constvalue.MaxIPsInGroup {
+		replicas = constvalue.MaxIPsInGroup
+	}
+	for _, group := range stsForDB.IPGroups {
+		err = resizeStickyIPGroup(stsForDB.TenantID, group, replicas)
+		if err != nil {
+			klog.Errorf("syncStatefulSet: resizeStickyIPGroup(%v, %v) err, error is [%v]", group.Name, replicas, err)
+			break
+		}
+	}
+	errSave := daos.GetStatefulSetDao().Save(stsForDB)
+	if err != nil {
+		return err
+	}
+	return errSave
+}
+
+// resizeStickyIPGroup keeps the ips pinned to the ordinals less than replicas
+// and deletes the ips of the ordinals scaled in.
+func resizeStickyIPGroup(tenantID string, group *daos.StickyIPGroupForDB, replicas int) error {
+	mc := infra.GetManagerClient()
+	ig, err := mc.GetIPGroup(tenantID, group.ID)
+	if err != nil {
+		return err
+	}
+	if len(ig.IPs) < replicas {
+		_, err = mc.ResizeIPGroup(tenantID, group.ID, replicas)
+		return err
+	}
+	if len(ig.IPs) == replicas {
+		return nil
+	}
+
+	keptIPs := make([]string, 0, replicas)
+	kept := make(map[string]bool)
+	for ordinal, ipAddr := range group.IPs {
+		if ordinal < replicas && isIPInGroup(ig, ipAddr) {
+			keptIPs = append(keptIPs, ipAddr)
+			kept[ipAddr] = true
+		}
+	}
+	pinnedIPs := make(map[string]bool)
+	for _, ipAddr := range group.IPs {
+		pinnedIPs[ipAddr] = true
+	}
+	for _, ip := range ig.IPs {
+		if len(keptIPs) >= replicas {
+			break
+		}
+		if !kept[ip.IPAddr] && !pinnedIPs[ip.IPAddr] {
+			keptIPs = append(keptIPs, ip.IPAddr)
+			kept[ip.IPAddr] = true
+		}
+	}
+	if len(keptIPs) == 0 {
+		_, err = mc.ResizeIPGroup(tenantID, group.ID, 0)
+	} else {
+		_, err = mc.SetIPGroupIPs(tenantID, group.ID, keptIPs)
+	}
+	if err != nil {
+		return err
+	}
+	for ordinal := range group.IPs {
+		if ordinal >= replicas {
+			delete(group.IPs, ordinal)
+		}
+	}
+	return nil
+}
+
+// releaseStickyIPs deletes the ip groups of the deleted StatefulSet.
+func releaseStickyIPs(stsForDB *daos.StatefulSetForDB) error {
+	mc := infra.GetManagerClient()
+	for _, group := range stsForDB.IPGroups {
+		err := mc.DeleteIPGroup(stsForDB.TenantID, group.ID)
+		if err != nil {
+			klog.Errorf("releaseStickyIPs: DeleteIPGroup(%v) of StatefulSet[%v/%v] err, error is [%v]",
+				group.Name, stsForDB.Namespace, stsForDB.Name, err)
+			return err
+		}
+		klog.Infof("releaseStickyIPs: ip group[%v] of StatefulSet[%v/%v] deleted", group.Name, stsForDB.Namespace, stsForDB.Name)
+	}
+	return daos.GetStatefulSetDao().Delete(stsForDB.Namespace, stsForDB.Name)
+}
+
+// resyncStatefulSets enqueues the StatefulSets with sticky ips, the scale
+// and deletion missed during monitor restart are synced.
+func (cpc *createPortForPodController) resyncStatefulSets() error {
+	stssForDB, err := daos.GetStatefulSetDao().GetAll()
+	if err != nil {
+		klog.Errorf("resyncStatefulSets: daos.GetStatefulSetDao().GetAll() err, error is [%v]", err)
+		return err
+	}
+	for _, stsForDB := range stssForDB {
+		cpc.statefulSetsQueue.Add(stsForDB.Namespace + "/" + stsForDB.Name)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/bouk/monkey"
+	"github.com/smartystreets/goconvey/convey"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/ZTE/Knitter/knitter-monitor/const-value"
+	"github.com/ZTE/Knitter/knitter-monitor/daos"
+	"github.com/ZTE/Knitter/knitter-monitor/infra"
+)
+
+type fakeStatefulSetDao struct {
+	stss map[string]*daos.StatefulSetForDB
+}
+
+func (fd *fakeStatefulSetDao) Get(stsNs, stsName string) (*daos.StatefulSetForDB, error) {
+	sts, ok := fd.stss[stsNs+"/"+stsName]
+	if !ok {
+		return nil, errors.New("100: Key not found")
+	}
+	return sts, nil
+}
+
+func (fd *fakeStatefulSetDao) Save(stsForDB *daos.StatefulSetForDB) error {
+	fd.stss[stsForDB.Namespace+"/"+stsForDB.Name] = stsForDB
+	return nil
+}
+
+func (fd *fakeStatefulSetDao) Delete(stsNs, stsName string) error {
+	delete(fd.stss, stsNs+"/"+stsName)
+	return nil
+}
+
+func (fd *fakeStatefulSetDao) GetAll() ([]*daos.StatefulSetForDB, error) {
+	stss := make([]*daos.StatefulSetForDB, 0)
+	for _, sts := range fd.stss {
+		stss = append(stss, sts)
+	}
+	return stss, nil
+}
+
+// patchIPGroupsOfManager keeps the ip groups in memory, ips are allocated
+// from 10.0.0.2 in order.
+func patchIPGroupsOfManager() map[string]*infra.IPGroup {
+	groups := make(map[string]*infra.IPGroup)
+	nextIP := 2
+	addIPs := func(ig *infra.IPGroup, size int) {
+		for len(ig.IPs) < size {
+			ig.IPs = append(ig.IPs, infra.IPGroupIP{IPAddr: fmt.Sprintf("10.0.0.%d", nextIP)})
+			nextIP++
+		}
+	}
+	var mc *infra.ManagerClient
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "GetNetworkID",
+		func(_ *infra.ManagerClient, tenantID, networkName string) (string, error) {
+			return "id_" + networkName, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "GetIPGroupByName",
+		func(_ *infra.ManagerClient, tenantID, networkID, name string) (*infra.IPGroup, error) {
+			return groups[name], nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "GetIPGroup",
+		func(_ *infra.ManagerClient, tenantID, groupID string) (*infra.IPGroup, error) {
+			return groups[groupID], nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "CreateIPGroup",
+		func(_ *infra.ManagerClient, tenantID, networkID, name string, size int) (*infra.IPGroup, error) {
+			ig := &infra.IPGroup{ID: name, Name: name, NetworkID: networkID}
+			addIPs(ig, size)
+			groups[name] = ig
+			return ig, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "ResizeIPGroup",
+		func(_ *infra.ManagerClient, tenantID, groupID string, size int) (*infra.IPGroup, error) {
+			ig := groups[groupID]
+			addIPs(ig, size)
+			ig.IPs = ig.IPs[:size]
+			return ig, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "SetIPGroupIPs",
+		func(_ *infra.ManagerClient, tenantID, groupID string, ips []string) (*infra.IPGroup, error) {
+			ig := groups[groupID]
+			keptIPs := make([]infra.IPGroupIP, 0)
+			for _, ip := range ig.IPs {
+				kept := false
+				for _, ipAddr := range ips {
+					kept = kept || ipAddr == ip.IPAddr
+				}
+				if !kept && ip.Used {
+					return nil, errors.New("409:ip group return code error, message: ip[" + ip.IPAddr + "] is in use")
+				}
+				if kept {
+					keptIPs = append(keptIPs, ip)
+				}
+			}
+			ig.IPs = keptIPs
+			return ig, nil
+		})
+	monkey.PatchInstanceMethod(reflect.TypeOf(mc), "DeleteIPGroup",
+		func(_ *infra.ManagerClient, tenantID, groupID string) error {
+			for _, ip := range groups[groupID].IPs {
+				if ip.Used {
+					return errors.New("409:delete ip group return code error")
+				}
+			}
+			delete(groups, groupID)
+			return nil
+		})
+	return groups
+}
+
+func setIPUsed(ig *infra.IPGroup, ipAddr string, used bool) {
+	for i := range ig.IPs {
+		if ig.IPs[i].IPAddr == ipAddr {
+			ig.IPs[i].Used = used
+		}
+	}
+}
+
+func newK8sStatefulSet(name string, replicas int32, sticky bool) *appsv1beta2.StatefulSet {
+	sts := &appsv1beta2.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"}}
+	sts.Spec.Replicas = &replicas
+	if sticky {
+		sts.Annotations = map[string]string{constvalue.StickyIPAnnotation: "true"}
+	}
+	return sts
+}
+
+func newStickyIPTestPorts() []*Port {
+	ports := []*Port{{}, {}}
+	ports[0].EagerAttr = PortEagerAttr{PortName: "eth0", NetworkName: "net_api", VnicType: "normal"}
+	ports[1].EagerAttr = PortEagerAttr{PortName: "eth1", NetworkName: "net_media", VnicType: "normal", IPGroupName: "ig0"}
+	return ports
+}
+
+func TestGetStatefulSetOfPod(t *testing.T) {
+	newPod := func(name, ownerKind string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1",
+			OwnerReferences: []metav1.OwnerReference{{Kind: ownerKind, Name: "web"}}}}
+	}
+	convey.Convey("TestGetStatefulSetOfPod", t, func() {
+		convey.So(getStatefulSetOfPod(newPod("web-2", "StatefulSet")), convey.ShouldResemble,
+			&StatefulSetOfPod{Name: "web", Ordinal: 2})
+		convey.So(getStatefulSetOfPod(newPod("web-2", "ReplicaSet")), convey.ShouldBeNil)
+		convey.So(getStatefulSetOfPod(newPod("web-x", "StatefulSet")), convey.ShouldBeNil)
+	})
+}
+
+func TestStickyIPsOfStatefulSet(t *testing.T) {
+	stsDao := &fakeStatefulSetDao{stss: make(map[string]*daos.StatefulSetForDB)}
+	monkey.Patch(daos.GetStatefulSetDao, func() daos.StatefulSetDaoInterface {
+		return stsDao
+	})
+	groups := patchIPGroupsOfManager()
+	defer monkey.UnpatchAll()
+	statefulSetStore = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	defer func() { statefulSetStore = nil }()
+	statefulSetStore.Add(newK8sStatefulSet("web", 2, true))
+	statefulSetStore.Add(newK8sStatefulSet("db", 2, false))
+	cpc := &createPortForPodController{}
+	newPod := func(stsName string, ordinal int) *PodForCreatPort {
+		return &PodForCreatPort{TenantID: "ns1", PodNs: "ns1", PodName: fmt.Sprintf("%s-%d", stsName, ordinal),
+			StatefulSet: &StatefulSetOfPod{Name: stsName, Ordinal: ordinal}}
+	}
+	groupName := stickyIPGroupName("ns1", "web", "net_api")
+
+	convey.Convey("TestStickyIPsOfStatefulSet", t, func() {
+		convey.Convey("pods are pinned to ips of their ordinals\n", func() {
+			ports := newStickyIPTestPorts()
+			convey.So(assignStickyIPs(newPod("web", 1), ports), convey.ShouldBeNil)
+			convey.So(ports[0].EagerAttr.IPGroupName, convey.ShouldEqual, groupName)
+			convey.So(ports[0].EagerAttr.FixIP, convey.ShouldEqual, "10.0.0.2")
+			convey.So(ports[1].EagerAttr.IPGroupName, convey.ShouldEqual, "ig0")
+			convey.So(ports[1].EagerAttr.FixIP, convey.ShouldEqual, "")
+			convey.So(len(groups[groupName].IPs), convey.ShouldEqual, 2)
+			setIPUsed(groups[groupName], "10.0.0.2", true)
+
+			ports = newStickyIPTestPorts()
+			convey.So(assignStickyIPs(newPod("web", 0), ports), convey.ShouldBeNil)
+			convey.So(ports[0].EagerAttr.FixIP, convey.ShouldEqual, "10.0.0.3")
+			setIPUsed(groups[groupName], "10.0.0.3", true)
+
+			// restarted pod gets the same ip
+			setIPUsed(groups[groupName], "10.0.0.2", false)
+			ports = newStickyIPTestPorts()
+			convey.So(assignStickyIPs(newPod("web", 1), ports), convey.ShouldBeNil)
+			convey.So(ports[0].EagerAttr.FixIP, convey.ShouldEqual, "10.0.0.2")
+			setIPUsed(groups[groupName], "10.0.0.2", true)
+
+			// pod comes before the scale event grows the group
+			ports = newStickyIPTestPorts()
+			convey.So(assignStickyIPs(newPod("web", 2), ports), convey.ShouldBeNil)
+			convey.So(ports[0].EagerAttr.FixIP, convey.ShouldEqual, "10.0.0.4")
+			setIPUsed(groups[groupName], "10.0.0.4", true)
+		})
+
+		convey.Convey("StatefulSet without sticky ips\n", func() {
+			ports := newStickyIPTestPorts()
+			convey.So(assignStickyIPs(newPod("db", 0), ports), convey.ShouldBeNil)
+			convey.So(ports[0].EagerAttr.IPGroupName, convey.ShouldEqual, "")
+		})
+
+		convey.Convey("scale in keeps the ips of remaining ordinals\n", func() {
+			statefulSetStore.Update(newK8sStatefulSet("web", 1, true))
+			convey.So(cpc.syncStatefulSet("ns1/web"), convey.ShouldNotBeNil)
+			setIPUsed(groups[groupName], "10.0.0.2", false)
+			setIPUsed(groups[groupName], "10.0.0.4", false)
+			convey.So(cpc.syncStatefulSet("ns1/web"), convey.ShouldBeNil)
+			convey.So(groups[groupName].IPs, convey.ShouldResemble, []infra.IPGroupIP{{IPAddr: "10.0.0.3", Used: true}})
+			convey.So(stsDao.stss["ns1/web"].IPGroups[0].IPs, convey.ShouldResemble, map[int]string{0: "10.0.0.3"})
+		})
+
+		convey.Convey("ips are released after StatefulSet deleted\n", func() {
+			statefulSetStore.Delete(newK8sStatefulSet("web", 1, true))
+			convey.So(cpc.syncStatefulSet("ns1/web"), convey.ShouldNotBeNil)
+			setIPUsed(groups[groupName], "10.0.0.3", false)
+			convey.So(cpc.syncStatefulSet("ns1/web"), convey.ShouldBeNil)
+			convey.So(groups, convey.ShouldBeEmpty)
+			convey.So(stsDao.stss, convey.ShouldBeEmpty)
+		})
+	})
+}
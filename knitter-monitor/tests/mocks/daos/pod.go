@@ -39,6 +39,17 @@ func (_mr *_MockPodDaoInterfaceRecorder) Delete(arg0, arg1 interface{}) *gomock.
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Delete", arg0, arg1)
 }
 
+func (_m *MockPodDaoInterface) GetAll() ([]*daos.PodForDB, error) {
+	ret := _m.ctrl.Call(_m, "GetAll")
+	ret0, _ := ret[0].([]*daos.PodForDB)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockPodDaoInterfaceRecorder) GetAll() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAll")
+}
+
 func (_m *MockPodDaoInterface) Get(_param0 string, _param1 string) (*daos.PodForDB, error) {
 	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
 	ret0, _ := ret[0].(*daos.PodForDB)
@@ -40,6 +40,38 @@ func (_mr *_MockPodServiceInterfaceRecorder) DeletePodAndPorts(arg0, arg1 interf
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeletePodAndPorts", arg0, arg1)
 }
 
+func (_m *MockPodServiceInterface) GetAll() ([]*services.Pod, error) {
+	ret := _m.ctrl.Call(_m, "GetAll")
+	ret0, _ := ret[0].([]*services.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockPodServiceInterfaceRecorder) GetAll() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAll")
+}
+
+func (_m *MockPodServiceInterface) MarkPodPendingDelete(_param0 string, _param1 string) error {
+	ret := _m.ctrl.Call(_m, "MarkPodPendingDelete", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockPodServiceInterfaceRecorder) MarkPodPendingDelete(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "MarkPodPendingDelete", arg0, arg1)
+}
+
+func (_m *MockPodServiceInterface) UpdatePodPorts(_param0 *v1.Pod) (*services.Pod, error) {
+	ret := _m.ctrl.Call(_m, "UpdatePodPorts", _param0)
+	ret0, _ := ret[0].(*services.Pod)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockPodServiceInterfaceRecorder) UpdatePodPorts(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdatePodPorts", arg0)
+}
+
 func (_m *MockPodServiceInterface) Get(_param0 string, _param1 string) (*services.Pod, error) {
 	ret := _m.ctrl.Call(_m, "Get", _param0, _param1)
 	ret0, _ := ret[0].(*services.Pod)
@@ -40,6 +40,16 @@ func (_mr *_MockPortServiceInterfaceRecorder) DeleteBulkPorts(arg0, arg1 interfa
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "DeleteBulkPorts", arg0, arg1)
 }
 
+func (_m *MockPortServiceInterface) FillPortsLazyAttr(_param0 *services.PodForCreatPort, _param1 []*services.Port) error {
+	ret := _m.ctrl.Call(_m, "FillPortsLazyAttr", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockPortServiceInterfaceRecorder) FillPortsLazyAttr(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FillPortsLazyAttr", arg0, arg1)
+}
+
 func (_m *MockPortServiceInterface) NewPortsWithEagerAttrAndLazyAttr(_param0 *services.PodForCreatPort, _param1 *jason.Object) ([]*services.Port, error) {
 	ret := _m.ctrl.Call(_m, "NewPortsWithEagerAttrAndLazyAttr", _param0, _param1)
 	ret0, _ := ret[0].([]*services.Port)
@@ -17,6 +17,7 @@ import (
 	"github.com/ZTE/Knitter/pkg/openstack/driver/http"
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
+	NETHTTP "net/http"
 )
 
 var (
@@ -26,9 +27,15 @@ var (
 	DoHttpPost = func(url string, body map[string]interface{}, headers map[string]string) (int, []byte, error) {
 		return http.GetHTTPClientObj().Post(url, body, headers)
 	}
+	DoHttpPostWithRespHeader = func(url string, body map[string]interface{}, headers map[string]string) (int, NETHTTP.Header, []byte, error) {
+		return http.GetHTTPClientObj().PostWithRespHeader(url, body, headers)
+	}
 	DoHttpGet = func(url string, headers map[string]string) (int, []byte, error) {
 		return http.GetHTTPClientObj().Get(url, headers)
 	}
+	DoHttpPut = func(url string, body map[string]interface{}, headers map[string]string) (int, []byte, error) {
+		return http.GetHTTPClientObj().Put(url, body, headers)
+	}
 	DoHttpDelete = func(url string, headers map[string]string) (int, error) {
 		return http.GetHTTPClientObj().Delete(url, headers)
 	}
@@ -16,6 +16,7 @@ package dbaccessor
 import (
 	"errors"
 	"github.com/coreos/etcd/client"
+	"strconv"
 )
 
 type DbAccessor interface {
@@ -67,18 +68,44 @@ type Agent struct {
 	Ip     string `json:"ip"`
 	Status string `json:"status"`
 	TTL    int    `json:"ttl"`
+	// Networks hosting pods on the agent, nil if the agent never reports them
+	Networks []string `json:"networks"`
+}
+
+// Tunnel is the encapsulation of the tunnels between the agents, all
+// agents of a cluster must use the same one.
+type Tunnel struct {
+	Type    string `json:"type"`
+	DstPort int    `json:"dst_port"`
 }
 
 type Sync struct {
 	Interval string   `json:"interval"`
+	Tunnel   *Tunnel  `json:"tunnel,omitempty"`
 	Client   *Agent   `json:"client"`
 	Agents   []*Agent `json:"agents"`
+	// Members are the ips of the ready agents hosting each network
+	Members map[string][]string `json:"members,omitempty"`
+	// AllNetworkMembers are the ips of the ready agents not reporting their
+	// networks, they are members of all networks
+	AllNetworkMembers []string `json:"all_network_members,omitempty"`
+	// MembersVersion is changed with the members, the agents watch it
+	MembersVersion string `json:"members_version,omitempty"`
 }
 
 type SyncRsp struct {
-	Interval string  `json:"interval"`
-	Client   Agent   `json:"client"`
-	Agents   []Agent `json:"agents"`
+	Interval          string              `json:"interval"`
+	Tunnel            *Tunnel             `json:"tunnel,omitempty"`
+	Client            Agent               `json:"client"`
+	Agents            []Agent             `json:"agents"`
+	Members           map[string][]string `json:"members,omitempty"`
+	AllNetworkMembers []string            `json:"all_network_members,omitempty"`
+	MembersVersion    string              `json:"members_version,omitempty"`
+}
+
+// MembersWatchRsp is the members version returned by the members watch.
+type MembersWatchRsp struct {
+	MembersVersion string `json:"members_version"`
 }
 
 const (
@@ -87,6 +114,19 @@ const (
 	DefaultIntervalTimeInSecond int    = 15
 )
 
+const (
+	TunnelTypeVxlan  string = "vxlan"
+	TunnelTypeGeneve string = "geneve"
+)
+
+// IsValid checks the type and the UDP destination port of the tunnel.
+func (self *Tunnel) IsValid() bool {
+	if self.Type != TunnelTypeVxlan && self.Type != TunnelTypeGeneve {
+		return false
+	}
+	return self.DstPort > 0 && self.DstPort <= 65535
+}
+
 /*************************************************************************/
 func GetCloudTNameSpaceKey(podns, podId string) string {
 	var keyCloudT string = "/paas/cloudt"
@@ -247,6 +287,10 @@ func GetKeyOfPodSelf(tenant_id, pod_ns, pod_name string) string {
 	return GetKeyOfPod(tenant_id, pod_ns, pod_name) + "/self"
 }
 
+func GetKeyOfCniArgsForPod(tenant_id, pod_ns, pod_name string) string {
+	return GetKeyOfPod(tenant_id, pod_ns, pod_name) + "/cni_args"
+}
+
 func GetKeyOfInterfaceGroupInNetwork(tenant_id, network_id string) string {
 	return GetKeyOfNetwork(tenant_id, network_id) + "/interfaces"
 }
@@ -335,6 +379,26 @@ func GetKeyOfExceptionalPort(port_id string) string {
 	return GetKeyOfExceptionalPortGroup() + "/" + port_id
 }
 
+func GetKeyOfPooledPorts() string {
+	return GetKeyOfRuntime() + "/resource/pooled/ports"
+}
+
+func GetKeyOfPooledPortsOfNetwork(networkID string) string {
+	return GetKeyOfPooledPorts() + "/" + networkID
+}
+
+func GetKeyOfPooledPort(networkID, portID string) string {
+	return GetKeyOfPooledPortsOfNetwork(networkID) + "/" + portID
+}
+
+func GetKeyOfBulkPortsReqs() string {
+	return GetKeyOfRuntime() + "/resource/bulk_ports_reqs"
+}
+
+func GetKeyOfBulkPortsReq(tenantID, reqID string) string {
+	return GetKeyOfBulkPortsReqs() + "/" + tenantID + "/" + reqID
+}
+
 func GetKeyOfTopoSyncData() string {
 	return GetKeyOfRuntime() + "/topo/sync"
 }
@@ -435,6 +499,14 @@ func GetVnfmSubNetwrokUrl(baseURL string, nfInstanceID string, subNetName string
 	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/subnets?subnetName=" + subNetName
 }
 
+func GetVnfmNetworkIDUrl(baseURL string, nfInstanceID string, networkID string) string {
+	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/networks/" + networkID
+}
+
+func GetVnfmSubNetworkIDUrl(baseURL string, nfInstanceID string, subnetID string) string {
+	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/subnets/" + subnetID
+}
+
 func GetVnfmCreatePortUrl(baseURL string, nfInstanceID string) string {
 	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/cps"
 }
@@ -451,6 +523,10 @@ func GetVnfmPortInfoUrl(baseURL string, nfInstanceID string, resourceID string)
 	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/ports?resourceId=" + resourceID
 }
 
+func GetVnfmNetworkPortsUrl(baseURL string, nfInstanceID string, networkID string) string {
+	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/ports?networkId=" + networkID
+}
+
 func GetVnfmAttachDetachPortUrl(baseURL string, nfInstanceID string, vmName string) string {
 	return GetVnfmBaseUrl(baseURL, nfInstanceID) + "/cp_attach_detach/" + vmName
 }
@@ -548,6 +624,38 @@ func GetKeyOfInterfaceSelfForRole(driver, interfacesID string) string {
 	return "/phys/role/" + driver + "/" + interfacesID
 }
 
+func GetKeyOfSriovVfs(pf string) string {
+	return "/phys/sriov/" + pf + "/vfs"
+}
+
+func GetKeyOfSriovVf(pf string, index int) string {
+	return GetKeyOfSriovVfs(pf) + "/" + strconv.Itoa(index)
+}
+
+func GetKeyOfBonds() string {
+	return "/phys/bonds"
+}
+
+func GetKeyOfBond(portID string) string {
+	return GetKeyOfBonds() + "/" + portID
+}
+
+func GetKeyOfVhostUsers() string {
+	return "/phys/vhostusers"
+}
+
+func GetKeyOfVhostUser(portID string) string {
+	return GetKeyOfVhostUsers() + "/" + portID
+}
+
+func GetKeyOfQoses() string {
+	return "/phys/qoses"
+}
+
+func GetKeyOfQos(portID string) string {
+	return GetKeyOfQoses() + "/" + portID
+}
+
 func GetKeyOfNouthInterface(containerID, driver, interfacesID string) string {
 	return "/phys/manager/nouth/" + containerID + "/" + driver + "/" + interfacesID
 }
@@ -584,3 +692,11 @@ func GetKeyOfMonitorPod(podNS, podName string) string {
 	return GetKeyOfMonitorPods() + "/" + podNS + "/" + podName
 
 }
+
+func GetKeyOfMonitorStatefulSets() string {
+	return GetKeyOfMonitor() + "/statefulsets"
+}
+
+func GetKeyOfMonitorStatefulSet(stsNs, stsName string) string {
+	return GetKeyOfMonitorStatefulSets() + "/" + stsNs + "/" + stsName
+}
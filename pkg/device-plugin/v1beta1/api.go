@@ -0,0 +1,131 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	proto "github.com/golang/protobuf/proto"
+)
+
+type DevicePluginOptions struct {
+	PreStartRequired bool `protobuf:"varint,1,opt,name=pre_start_required,json=preStartRequired,proto3" json:"pre_start_required,omitempty"`
+}
+
+func (m *DevicePluginOptions) Reset()         { *m = DevicePluginOptions{} }
+func (m *DevicePluginOptions) String() string { return proto.CompactTextString(m) }
+func (*DevicePluginOptions) ProtoMessage()    {}
+
+type RegisterRequest struct {
+	Version      string               `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Endpoint     string               `protobuf:"bytes,2,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	ResourceName string               `protobuf:"bytes,3,opt,name=resource_name,json=resourceName,proto3" json:"resource_name,omitempty"`
+	Options      *DevicePluginOptions `protobuf:"bytes,4,opt,name=options" json:"options,omitempty"`
+}
+
+func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()    {}
+
+type Empty struct {
+}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()    {}
+
+type ListAndWatchResponse struct {
+	Devices []*Device `protobuf:"bytes,1,rep,name=devices" json:"devices,omitempty"`
+}
+
+func (m *ListAndWatchResponse) Reset()         { *m = ListAndWatchResponse{} }
+func (m *ListAndWatchResponse) String() string { return proto.CompactTextString(m) }
+func (*ListAndWatchResponse) ProtoMessage()    {}
+
+type Device struct {
+	ID     string `protobuf:"bytes,1,opt,name=ID,proto3" json:"ID,omitempty"`
+	Health string `protobuf:"bytes,2,opt,name=health,proto3" json:"health,omitempty"`
+}
+
+func (m *Device) Reset()         { *m = Device{} }
+func (m *Device) String() string { return proto.CompactTextString(m) }
+func (*Device) ProtoMessage()    {}
+
+type PreStartContainerRequest struct {
+	DevicesIDs []string `protobuf:"bytes,1,rep,name=devicesIDs" json:"devicesIDs,omitempty"`
+}
+
+func (m *PreStartContainerRequest) Reset()         { *m = PreStartContainerRequest{} }
+func (m *PreStartContainerRequest) String() string { return proto.CompactTextString(m) }
+func (*PreStartContainerRequest) ProtoMessage()    {}
+
+type PreStartContainerResponse struct {
+}
+
+func (m *PreStartContainerResponse) Reset()         { *m = PreStartContainerResponse{} }
+func (m *PreStartContainerResponse) String() string { return proto.CompactTextString(m) }
+func (*PreStartContainerResponse) ProtoMessage()    {}
+
+type AllocateRequest struct {
+	ContainerRequests []*ContainerAllocateRequest `protobuf:"bytes,1,rep,name=container_requests,json=containerRequests" json:"container_requests,omitempty"`
+}
+
+func (m *AllocateRequest) Reset()         { *m = AllocateRequest{} }
+func (m *AllocateRequest) String() string { return proto.CompactTextString(m) }
+func (*AllocateRequest) ProtoMessage()    {}
+
+type ContainerAllocateRequest struct {
+	DevicesIDs []string `protobuf:"bytes,1,rep,name=devicesIDs" json:"devicesIDs,omitempty"`
+}
+
+func (m *ContainerAllocateRequest) Reset()         { *m = ContainerAllocateRequest{} }
+func (m *ContainerAllocateRequest) String() string { return proto.CompactTextString(m) }
+func (*ContainerAllocateRequest) ProtoMessage()    {}
+
+type AllocateResponse struct {
+	ContainerResponses []*ContainerAllocateResponse `protobuf:"bytes,1,rep,name=container_responses,json=containerResponses" json:"container_responses,omitempty"`
+}
+
+func (m *AllocateResponse) Reset()         { *m = AllocateResponse{} }
+func (m *AllocateResponse) String() string { return proto.CompactTextString(m) }
+func (*AllocateResponse) ProtoMessage()    {}
+
+type ContainerAllocateResponse struct {
+	Envs        map[string]string `protobuf:"bytes,1,rep,name=envs" json:"envs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Mounts      []*Mount          `protobuf:"bytes,2,rep,name=mounts" json:"mounts,omitempty"`
+	Devices     []*DeviceSpec     `protobuf:"bytes,3,rep,name=devices" json:"devices,omitempty"`
+	Annotations map[string]string `protobuf:"bytes,4,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *ContainerAllocateResponse) Reset()         { *m = ContainerAllocateResponse{} }
+func (m *ContainerAllocateResponse) String() string { return proto.CompactTextString(m) }
+func (*ContainerAllocateResponse) ProtoMessage()    {}
+
+type Mount struct {
+	ContainerPath string `protobuf:"bytes,1,opt,name=container_path,json=containerPath,proto3" json:"container_path,omitempty"`
+	HostPath      string `protobuf:"bytes,2,opt,name=host_path,json=hostPath,proto3" json:"host_path,omitempty"`
+	ReadOnly      bool   `protobuf:"varint,3,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+}
+
+func (m *Mount) Reset()         { *m = Mount{} }
+func (m *Mount) String() string { return proto.CompactTextString(m) }
+func (*Mount) ProtoMessage()    {}
+
+type DeviceSpec struct {
+	ContainerPath string `protobuf:"bytes,1,opt,name=container_path,json=containerPath,proto3" json:"container_path,omitempty"`
+	HostPath      string `protobuf:"bytes,2,opt,name=host_path,json=hostPath,proto3" json:"host_path,omitempty"`
+	Permissions   string `protobuf:"bytes,3,opt,name=permissions,proto3" json:"permissions,omitempty"`
+}
+
+func (m *DeviceSpec) Reset()         { *m = DeviceSpec{} }
+func (m *DeviceSpec) String() string { return proto.CompactTextString(m) }
+func (*DeviceSpec) ProtoMessage()    {}
@@ -0,0 +1,26 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1beta1 is the device plugin API v1beta1 of kubelet, it is written
+// after k8s.io/kubernetes/pkg/kubelet/apis/deviceplugin/v1beta1/api.proto and
+// is compatible with it on the wire.
+package v1beta1
+
+const (
+	Healthy   = "Healthy"
+	Unhealthy = "Unhealthy"
+
+	Version          = "v1beta1"
+	DevicePluginPath = "/var/lib/kubelet/device-plugins/"
+	KubeletSocket    = DevicePluginPath + "kubelet.sock"
+)
@@ -27,6 +27,7 @@ import (
 type HTTPMethods interface {
 	Get(url string) ([]byte, error)
 	Post(url string, body map[string]interface{}) ([]byte, error)
+	Put(url string, body map[string]interface{}) ([]byte, error)
 	Delete(url string) (error, int, string)
 }
 
@@ -105,6 +106,43 @@ func (self *httpClient) Post(url string, body map[string]interface{}) ([]byte, e
 	return respBody, nil
 }
 
+func (self *httpClient) Put(url string, body map[string]interface{}) ([]byte, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		klog.Error("Put: json.Marshal [", body, "]error: ", err.Error())
+		return nil, fmt.Errorf("%v:Put: json.Marshal body error", err)
+	}
+
+	client := &http.Client{}
+	request, err := http.NewRequest("PUT", url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		klog.Error("Put: http NewRequest error: ", err.Error())
+		return nil, fmt.Errorf("%v:http NewRequest error", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		klog.Error("Put: client.Do error: ", err.Error())
+		return nil, errors.New("http client.Do error")
+	}
+
+	defer response.Body.Close()
+	respBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		klog.Error("Put: ioutil.ReadAll: [", url, "] error: ", err.Error())
+		return nil, fmt.Errorf("%v:ioutil.ReadAll response error", err)
+	}
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		errResp := errors.New("Put: http client.Do[" + url + "] response error, status code: " + strconv.Itoa(response.StatusCode) + ", response body: " + string(respBody))
+		klog.Error(errResp.Error())
+		return respBody, errResp
+	}
+	return respBody, nil
+}
+
 func (self *httpClient) Delete(url string) (error, int, string) {
 	client := &http.Client{}
 	request, err := http.NewRequest("DELETE", url, nil)
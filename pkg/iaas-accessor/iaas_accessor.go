@@ -110,6 +110,7 @@ type IaaS interface {
 	GetPort(id string) (*Interface, error)
 	DeletePort(id string) error
 	ListPorts(networkID string) ([]*Interface, error)
+	UpdatePortName(id, name string) error
 
 	CreateNetwork(name string) (*Network, error)
 	CreateProviderNetwork(name, nwType, phyNet, sId string, vlanTransparent bool) (*Network, error)
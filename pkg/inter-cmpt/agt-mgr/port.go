@@ -13,6 +13,22 @@ limitations under the License.
 
 package agtmgr
 
+import (
+	"github.com/rackspace/gophercloud/openstack/networking/v2/ports"
+)
+
+// PortSecurity is the neutron port security of a port, the unset fields keep
+// the neutron defaults, e.g. the default security group of the project.
+type PortSecurity struct {
+	SecurityGroups      []string            `json:"security_groups,omitempty"`
+	PortSecurityEnabled *bool               `json:"port_security_enabled,omitempty"`
+	AllowedAddressPairs []ports.AddressPair `json:"allowed_address_pairs,omitempty"`
+}
+
+func (ps *PortSecurity) IsSet() bool {
+	return len(ps.SecurityGroups) != 0 || ps.PortSecurityEnabled != nil || len(ps.AllowedAddressPairs) != 0
+}
+
 type AgtPortReq struct {
 	TenantID    string `json:"tenant_id"`
 	NetworkName string `json:"network_name"`
@@ -24,6 +40,8 @@ type AgtPortReq struct {
 	FixIP       string `json:"ip_addr"`
 	ClusterID   string `json:"cluster_id"`
 	IPGroupName string `json:"ip_group_name"`
+	FixMac      string `json:"mac_addr,omitempty"`
+	PortSecurity
 }
 
 type AgtBulkPortsReq struct {
@@ -0,0 +1,97 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8sevent
+
+import (
+	"encoding/json"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConditionNetworkReady is true once all interfaces of the pod are attached.
+const ConditionNetworkReady v1.PodConditionType = "knitter.io/NetworkReady"
+
+// reasons of the events and conditions reported on pods
+const (
+	ReasonInvalidNetworks      = "InvalidNetworks"
+	ReasonIPExhausted          = "IPExhausted"
+	ReasonPortAllocationFailed = "PortAllocationFailed"
+	ReasonAttachFailed         = "AttachFailed"
+	ReasonInterfacesAttached   = "InterfacesAttached"
+)
+
+const (
+	ComponentMonitor = "knitter-monitor"
+	ComponentAgent   = "knitter-agent"
+)
+
+// MaxMessageLen keeps the messages in the limit of kubernetes events.
+const MaxMessageLen = 1024
+
+// NewPodEvent builds an event involving the pod, podUID could be empty if it
+// is unknown, but kubectl describe finds the events by uid of the pod.
+func NewPodEvent(podNs, podName, podUID, component, host, eventType, reason, message string) *v1.Event {
+	now := metav1.NewTime(time.Now())
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: podName + ".",
+			Namespace:    podNs,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  podNs,
+			Name:       podName,
+			UID:        types.UID(podUID),
+		},
+		Reason:         reason,
+		Message:        truncateMessage(message),
+		Source:         v1.EventSource{Component: component, Host: host},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+}
+
+// NewNetworkReadyPatch builds the strategic merge patch of pod status which
+// sets the NetworkReady condition, the other conditions are kept.
+func NewNetworkReadyPatch(ready bool, reason, message string) ([]byte, error) {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.PodCondition{{
+				Type:               ConditionNetworkReady,
+				Status:             status,
+				LastTransitionTime: metav1.NewTime(time.Now()),
+				Reason:             reason,
+				Message:            truncateMessage(message),
+			}},
+		},
+	}
+	return json.Marshal(patch)
+}
+
+func truncateMessage(message string) string {
+	if len(message) <= MaxMessageLen {
+		return message
+	}
+	return message[:MaxMessageLen-3] + "..."
+}
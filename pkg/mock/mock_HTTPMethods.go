@@ -74,3 +74,14 @@ func (_m *MockHTTPMethods) Post(_param0 string, _param1 map[string]interface{})
 func (_mr *_MockHTTPMethodsRecorder) Post(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Post", arg0, arg1)
 }
+
+func (_m *MockHTTPMethods) Put(_param0 string, _param1 map[string]interface{}) ([]byte, error) {
+	ret := _m.ctrl.Call(_m, "Put", _param0, _param1)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockHTTPMethodsRecorder) Put(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Put", arg0, arg1)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netattachdef
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotations of pod defined by the network plumbing working group, which
+// are used by multus and the tools around it
+const (
+	NetworkSelectionAnnotation = "k8s.v1.cni.cncf.io/networks"
+	NetworkStatusAnnotation    = "k8s.v1.cni.cncf.io/network-status"
+)
+
+// PortMetadataKey is the key in metadata of the port created from a network
+// selection, its value is the selected network as "namespace/name".
+const PortMetadataKey = "network_attachment"
+
+// NetworkSelectionElement is an element of the networks annotation.
+type NetworkSelectionElement struct {
+	Name             string   `json:"name"`
+	Namespace        string   `json:"namespace,omitempty"`
+	InterfaceRequest string   `json:"interface,omitempty"`
+	IPRequest        []string `json:"ips,omitempty"`
+	MacRequest       string   `json:"mac,omitempty"`
+}
+
+// ParseNetworkSelections parses the networks annotation in the json format
+// or the short format "[namespace/]name[@interface],...", the namespace of
+// pod is used if the namespace of element is not specified.
+func ParseNetworkSelections(annotation, podNs string) ([]*NetworkSelectionElement, error) {
+	annotation = strings.TrimSpace(annotation)
+	elements := make([]*NetworkSelectionElement, 0)
+	if strings.HasPrefix(annotation, "[") {
+		err := json.Unmarshal([]byte(annotation), &elements)
+		if err != nil {
+			return nil, fmt.Errorf("%v:networks annotation is not a valid json list", err)
+		}
+	} else {
+		for _, item := range strings.Split(annotation, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			elements = append(elements, parseShortSelection(item))
+		}
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("networks annotation[%s] has no network", annotation)
+	}
+	for i, element := range elements {
+		if element.Name == "" {
+			return nil, fmt.Errorf("networks annotation[%d]: name is required", i)
+		}
+		if element.Namespace == "" {
+			element.Namespace = podNs
+		}
+	}
+	return elements, nil
+}
+
+func parseShortSelection(item string) *NetworkSelectionElement {
+	element := &NetworkSelectionElement{}
+	if i := strings.LastIndex(item, "@"); i >= 0 {
+		element.InterfaceRequest = item[i+1:]
+		item = item[:i]
+	}
+	if i := strings.Index(item, "/"); i >= 0 {
+		element.Namespace = item[:i]
+		item = item[i+1:]
+	}
+	element.Name = item
+	return element
+}
+
+// NetworkAttachmentDefinition is the k8s.cni.cncf.io/v1 custom resource
+// selected by the networks annotation.
+type NetworkAttachmentDefinition struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NetworkAttachmentDefinitionSpec `json:"spec"`
+}
+
+type NetworkAttachmentDefinitionSpec struct {
+	Config string `json:"config"`
+}
+
+// GetKnitterNetworkName returns the knitter network of the definition, it
+// is "attach_to_network" in the CNI config, or the name of the config, or
+// the name of the definition at last.
+func (nad *NetworkAttachmentDefinition) GetKnitterNetworkName() string {
+	config := struct {
+		Name            string `json:"name"`
+		AttachToNetwork string `json:"attach_to_network"`
+	}{}
+	if nad.Spec.Config != "" {
+		json.Unmarshal([]byte(nad.Spec.Config), &config)
+	}
+	if config.AttachToNetwork != "" {
+		return config.AttachToNetwork
+	}
+	if config.Name != "" {
+		return config.Name
+	}
+	return nad.Name
+}
+
+// NetworkStatus is an element of the network-status annotation.
+type NetworkStatus struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface,omitempty"`
+	IPs       []string `json:"ips,omitempty"`
+	Mac       string   `json:"mac,omitempty"`
+	Default   bool     `json:"default,omitempty"`
+}
+
+// NewNetworkStatusPatch builds the strategic merge patch of the pod which
+// sets the network-status annotation.
+func NewNetworkStatusPatch(statuses []NetworkStatus) ([]byte, error) {
+	statusByte, err := json.Marshal(statuses)
+	if err != nil {
+		return nil, err
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				NetworkStatusAnnotation: string(statusByte),
+			},
+		},
+	}
+	return json.Marshal(patch)
+}
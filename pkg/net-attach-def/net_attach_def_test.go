@@ -0,0 +1,71 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netattachdef
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseNetworkSelections(t *testing.T) {
+	convey.Convey("TestParseNetworkSelections", t, func() {
+		elements, err := ParseNetworkSelections("net1, ns2/net2@eth2", "ns1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(elements, convey.ShouldResemble, []*NetworkSelectionElement{
+			{Name: "net1", Namespace: "ns1"},
+			{Name: "net2", Namespace: "ns2", InterfaceRequest: "eth2"},
+		})
+
+		elements, err = ParseNetworkSelections(`[{"name": "net1", "interface": "eth1",
+			"ips": ["10.1.1.11/24"], "mac": "c2:b0:57:49:47:f1"}]`, "ns1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(elements, convey.ShouldResemble, []*NetworkSelectionElement{
+			{Name: "net1", Namespace: "ns1", InterfaceRequest: "eth1",
+				IPRequest: []string{"10.1.1.11/24"}, MacRequest: "c2:b0:57:49:47:f1"},
+		})
+
+		_, err = ParseNetworkSelections(`[{"name": "net1"`, "ns1")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = ParseNetworkSelections(`[{"interface": "eth1"}]`, "ns1")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = ParseNetworkSelections(" , ", "ns1")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestGetKnitterNetworkName(t *testing.T) {
+	convey.Convey("TestGetKnitterNetworkName", t, func() {
+		nad := &NetworkAttachmentDefinition{}
+		nad.Name = "nad1"
+		convey.So(nad.GetKnitterNetworkName(), convey.ShouldEqual, "nad1")
+
+		nad.Spec.Config = `{"cniVersion": "0.3.1", "name": "net1", "type": "knitter"}`
+		convey.So(nad.GetKnitterNetworkName(), convey.ShouldEqual, "net1")
+
+		nad.Spec.Config = `{"name": "net1", "type": "knitter", "attach_to_network": "net_api"}`
+		convey.So(nad.GetKnitterNetworkName(), convey.ShouldEqual, "net_api")
+	})
+}
+
+func TestNewNetworkStatusPatch(t *testing.T) {
+	convey.Convey("TestNewNetworkStatusPatch", t, func() {
+		patch, err := NewNetworkStatusPatch([]NetworkStatus{
+			{Name: "ns1/net1", Interface: "eth0", IPs: []string{"10.1.1.11"}, Mac: "c2:b0:57:49:47:f1", Default: true},
+		})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(string(patch), convey.ShouldEqual, `{"metadata":{"annotations":{"k8s.v1.cni.cncf.io/network-status":`+
+			`"[{\"name\":\"ns1/net1\",\"interface\":\"eth0\",\"ips\":[\"10.1.1.11\"],\"mac\":\"c2:b0:57:49:47:f1\",\"default\":true}]"}}}`)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/ZTE/Knitter/pkg/adapter"
 	"github.com/ZTE/Knitter/pkg/http"
 	. "github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/agt-mgr"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
 	"github.com/ZTE/Knitter/pkg/klog"
 	"github.com/antonholmquist/jason"
@@ -177,6 +178,18 @@ func makeCreatePort(networkId, subnetId, networkPlane, ip, mac, vnicType string)
 	return dictPort
 }
 
+func addPortSecurity(dictPort map[string]interface{}, security *agtmgr.PortSecurity) {
+	if len(security.SecurityGroups) != 0 {
+		dictPort["security_groups"] = security.SecurityGroups
+	}
+	if security.PortSecurityEnabled != nil {
+		dictPort["port_security_enabled"] = *security.PortSecurityEnabled
+	}
+	if len(security.AllowedAddressPairs) != 0 {
+		dictPort["allowed_address_pairs"] = security.AllowedAddressPairs
+	}
+}
+
 func parsePortAttrs(obj *jason.Object) (*Interface, error) {
 	id, err := obj.GetString("id")
 	if err != nil {
@@ -280,7 +293,8 @@ func (n *NoauthOpenStack) CreateBulkPorts(req *mgriaas.MgrBulkPortsReq) ([]*Inte
 	opts := make([]map[string]interface{}, 0)
 	for _, reqPort := range req.Ports {
 		opt := makeCreatePort(reqPort.NetworkId, reqPort.SubnetId,
-			reqPort.PortName, reqPort.FixIP, "", reqPort.VnicType)
+			reqPort.PortName, reqPort.FixIP, reqPort.FixMac, reqPort.VnicType)
+		addPortSecurity(opt, &reqPort.PortSecurity)
 		opts = append(opts, opt)
 	}
 	m := make(map[string]interface{})
@@ -350,6 +364,17 @@ func (self *NoauthOpenStack) DeletePort(id string) error {
 	return nil
 }
 
+func (self *NoauthOpenStack) UpdatePortName(id, name string) error {
+	url := self.getPortIdUrl(id)
+	body := map[string]interface{}{"port": map[string]interface{}{"name": name}}
+	_, err := http.GetHTTPClientObj().Put(url, body)
+	if err != nil {
+		klog.Errorf("UpdatePortName: Put port[id: %v] name: %v error: %v", id, name, err)
+		return fmt.Errorf("%v:UpdatePortName: Put port error", err)
+	}
+	return nil
+}
+
 func parsePortArray(arrayObj *jason.Object) ([]*Interface, error) {
 	portObjs, err := arrayObj.GetObjectArray("ports")
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"github.com/antonholmquist/jason"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -78,6 +79,18 @@ type AuthConfig struct {
 
 	ComputeEndpoint string
 
+	// AuthVersion is AuthVersionV2 or AuthVersionV3, V3 holds the keystone v3
+	// credentials and scope, Region and Interface select catalog endpoints.
+	AuthVersion string
+	V3          *AuthOptionsV3
+	Region      string
+	Interface   string
+
+	// TokenIssuedAtLocal and TokenLifetime are used to refresh the cached
+	// token before it expires.
+	TokenIssuedAtLocal time.Time
+	TokenLifetime      time.Duration
+
 	mutex sync.Mutex
 }
 
@@ -92,24 +105,109 @@ func getAuthSingleton() *AuthConfig {
 	return instance
 }
 
-func (auth *AuthConfig) setConf(config OpenStackConf) {
+func (auth *AuthConfig) setConf(config OpenStackConf) error {
+	version, err := GetAuthVersion(config.Url, config.KeystoneV3Conf)
+	if err != nil {
+		klog.Errorf("setConf: GetAuthVersion error: %v", err)
+		return err
+	}
+
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
 	auth.AllowReauth = true
+	auth.AuthVersion = version
 	auth.Username = config.Username
 	auth.Password = config.Password
-	auth.IdentityEndpoint = NormalizeURL(config.Url) + "/tokens"
 	auth.TenantID = config.Tenantid
+	auth.Region = config.Region
+	auth.Interface = config.Interface
+	auth.TokenID = ""
+	auth.TokenIssuedAtLocal = time.Time{}
+	auth.TokenLifetime = 0
+	if version == AuthVersionV3 {
+		auth.V3 = NewAuthOptionsV3(config.Url, config.Username, config.Password,
+			config.Tenantid, config.TenantName, config.KeystoneV3Conf)
+		auth.IdentityEndpoint = auth.V3.GetTokensUrl()
+		return nil
+	}
+	auth.V3 = nil
+	auth.IdentityEndpoint = NormalizeURL(config.Url) + "/tokens"
+	return nil
 }
 
 func AuthCheck(config *OpenStackConf) (*AuthClient, error) {
 	auth := AuthClient{}
-	auth.setConf(*config)
-	err := auth.auth()
+	err := auth.setConf(*config)
+	if err != nil {
+		return &auth, err
+	}
+	err = auth.auth()
 	return &auth, err
 }
 
 func (auth *AuthConfig) auth() error {
 	auth.mutex.Lock()
 	defer auth.mutex.Unlock()
+	if auth.AuthVersion == AuthVersionV3 {
+		return auth.authV3()
+	}
+	return auth.authV2()
+}
+
+// refreshTokenIfNeeded re-authenticates when the cached token is about to expire.
+func (auth *AuthConfig) refreshTokenIfNeeded() error {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if !auth.AllowReauth || auth.TokenID == "" || !NeedRefresh(auth.TokenIssuedAtLocal, auth.TokenLifetime) {
+		return nil
+	}
+	klog.Infof("refreshTokenIfNeeded: token issued at %v with lifetime %v is about to expire, refresh it",
+		auth.TokenIssuedAtLocal, auth.TokenLifetime)
+	if auth.AuthVersion == AuthVersionV3 {
+		return auth.authV3()
+	}
+	return auth.authV2()
+}
+
+func (auth *AuthConfig) getTokenID() string {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	return auth.TokenID
+}
+
+func (auth *AuthConfig) authV3() error {
+	token, err := AuthenticateV3(auth.V3)
+	if err != nil {
+		klog.Errorf("authV3: AuthenticateV3 error: %v", err)
+		return fmt.Errorf("%v:authV3: AuthenticateV3 error", err)
+	}
+
+	networkURL, err := token.EndpointURL(NetworkEndpointType, auth.Interface, auth.Region)
+	if err != nil {
+		klog.Errorf("authV3: get network endpoint error: %v", err)
+		return fmt.Errorf("%v:authV3: get network endpoint error", err)
+	}
+	// compute endpoint is only used to attach ports to VMs, a catalog
+	// without it still serves the network operations
+	computeURL, err := token.EndpointURL(ComputeEndpointType, auth.Interface, auth.Region)
+	if err != nil {
+		klog.Warningf("authV3: get compute endpoint error: %v, go on without it", err)
+	}
+
+	auth.TokenID = token.ID
+	auth.TenantID = token.ProjectID
+	auth.TenantName = token.ProjectName
+	auth.TokenIssuedAtLocal = token.IssuedAtLocal
+	auth.TokenLifetime = token.Lifetime()
+	auth.NetworkEndpoint = NormalizeURL(networkURL) + "v2.0/"
+	auth.ComputeEndpoint = ""
+	if computeURL != "" {
+		auth.ComputeEndpoint = NormalizeURL(computeURL)
+	}
+	return nil
+}
+
+func (auth *AuthConfig) authV2() error {
 	url := auth.IdentityEndpoint
 	body := auth.makeTokenv2Body()
 	status, rspBytes, err := http.GetHTTPClientObj().Post(url, body, nil)
@@ -161,6 +259,16 @@ func (auth *AuthConfig) setTokenAndTenantInfo(authJSON *jason.Object) error {
 		return fmt.Errorf("%v:auth: GetString access->token->tenant->name error", err)
 	}
 	auth.TenantName = tenantName
+
+	auth.TokenIssuedAtLocal = time.Now()
+	auth.TokenLifetime = 0
+	issuedAt, _ := authJSON.GetString("access", "token", "issued_at")
+	expires, _ := authJSON.GetString("access", "token", "expires")
+	issuedTime, errIssued := time.Parse(time.RFC3339, issuedAt)
+	expiresTime, errExpires := time.Parse(time.RFC3339, expires)
+	if errIssued == nil && errExpires == nil {
+		auth.TokenLifetime = expiresTime.Sub(issuedTime)
+	}
 	return nil
 }
 
@@ -0,0 +1,379 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ZTE/Knitter/pkg/adapter"
+	"github.com/ZTE/Knitter/pkg/klog"
+)
+
+const (
+	AuthVersionV2 = "v2"
+	AuthVersionV3 = "v3"
+
+	EndpointInterfacePublic   = "public"
+	EndpointInterfaceInternal = "internal"
+	EndpointInterfaceAdmin    = "admin"
+
+	SubjectTokenHeader = "X-Subject-Token"
+
+	// TokenRefreshBeforeExpiry is how long before expires_at a cached token is
+	// proactively renewed, it is capped to half of the token lifetime.
+	TokenRefreshBeforeExpiry = 3 * time.Minute
+)
+
+var (
+	ErrNoCredentials      = errors.New("keystone v3: password or application credential is required")
+	ErrNoSubjectToken     = errors.New("keystone v3: no X-Subject-Token in response header")
+	ErrEndpointNotFound   = errors.New("keystone v3: no matched endpoint in catalog")
+	ErrInvalidAuthScope   = errors.New("keystone v3: project and domain scope are exclusive")
+	ErrUnknownAuthVersion = errors.New("keystone: unknown auth_version, only v2 and v3 are supported")
+)
+
+// KeystoneV3Conf holds the keystone v3 specific part of the openstack config,
+// it is embedded in the openstack config of both openstack drivers.
+type KeystoneV3Conf struct {
+	AuthVersion                 string `json:"auth_version,omitempty"`
+	UserID                      string `json:"user_id,omitempty"`
+	UserDomainID                string `json:"user_domain_id,omitempty"`
+	UserDomainName              string `json:"user_domain_name,omitempty"`
+	ProjectDomainID             string `json:"project_domain_id,omitempty"`
+	ProjectDomainName           string `json:"project_domain_name,omitempty"`
+	DomainID                    string `json:"domain_id,omitempty"`
+	DomainName                  string `json:"domain_name,omitempty"`
+	ApplicationCredentialID     string `json:"application_credential_id,omitempty"`
+	ApplicationCredentialName   string `json:"application_credential_name,omitempty"`
+	ApplicationCredentialSecret string `json:"application_credential_secret,omitempty"`
+	Region                      string `json:"region,omitempty"`
+	Interface                   string `json:"interface,omitempty"`
+}
+
+// GetAuthVersion returns the configured auth version, when it is not set the
+// version is inferred from the identity url: ".../v2.0" means v2, others v3.
+func GetAuthVersion(url string, conf KeystoneV3Conf) (string, error) {
+	switch strings.ToLower(conf.AuthVersion) {
+	case AuthVersionV2, "v2.0", "2", "2.0":
+		return AuthVersionV2, nil
+	case AuthVersionV3, "3":
+		return AuthVersionV3, nil
+	case "":
+		if strings.HasSuffix(strings.TrimSuffix(url, "/"), "/v2.0") {
+			return AuthVersionV2, nil
+		}
+		return AuthVersionV3, nil
+	}
+	return "", ErrUnknownAuthVersion
+}
+
+// GetIdentityV3Endpoint returns the keystone v3 base url, "/v3" is appended
+// when the configured url is the keystone root.
+func GetIdentityV3Endpoint(url string) string {
+	url = strings.TrimSuffix(url, "/")
+	if strings.HasSuffix(url, "/v3") {
+		return url
+	}
+	if strings.HasSuffix(url, "/v2.0") {
+		url = strings.TrimSuffix(url, "/v2.0")
+	}
+	return url + "/v3"
+}
+
+type AuthOptionsV3 struct {
+	IdentityEndpoint string
+
+	Username, UserID, Password   string
+	UserDomainID, UserDomainName string
+
+	ProjectID, ProjectName             string
+	ProjectDomainID, ProjectDomainName string
+
+	DomainID, DomainName string
+
+	ApplicationCredentialID     string
+	ApplicationCredentialName   string
+	ApplicationCredentialSecret string
+}
+
+func NewAuthOptionsV3(url, username, password, tenantID, tenantName string, conf KeystoneV3Conf) *AuthOptionsV3 {
+	return &AuthOptionsV3{
+		IdentityEndpoint:            GetIdentityV3Endpoint(url),
+		Username:                    username,
+		UserID:                      conf.UserID,
+		Password:                    password,
+		UserDomainID:                conf.UserDomainID,
+		UserDomainName:              conf.UserDomainName,
+		ProjectID:                   tenantID,
+		ProjectName:                 tenantName,
+		ProjectDomainID:             conf.ProjectDomainID,
+		ProjectDomainName:           conf.ProjectDomainName,
+		DomainID:                    conf.DomainID,
+		DomainName:                  conf.DomainName,
+		ApplicationCredentialID:     conf.ApplicationCredentialID,
+		ApplicationCredentialName:   conf.ApplicationCredentialName,
+		ApplicationCredentialSecret: conf.ApplicationCredentialSecret,
+	}
+}
+
+func (opts *AuthOptionsV3) GetTokensUrl() string {
+	return opts.IdentityEndpoint + "/auth/tokens"
+}
+
+func makeDomainV3(id, name string) map[string]interface{} {
+	if id != "" {
+		return map[string]interface{}{"id": id}
+	}
+	if name != "" {
+		return map[string]interface{}{"name": name}
+	}
+	return nil
+}
+
+func (opts *AuthOptionsV3) makeUserV3() map[string]interface{} {
+	user := make(map[string]interface{})
+	if opts.UserID != "" {
+		user["id"] = opts.UserID
+		return user
+	}
+	user["name"] = opts.Username
+	domain := makeDomainV3(opts.UserDomainID, opts.UserDomainName)
+	if domain == nil {
+		domain = map[string]interface{}{"id": "default"}
+	}
+	user["domain"] = domain
+	return user
+}
+
+func (opts *AuthOptionsV3) makeIdentityV3() (map[string]interface{}, error) {
+	if opts.ApplicationCredentialSecret != "" {
+		appCred := map[string]interface{}{"secret": opts.ApplicationCredentialSecret}
+		if opts.ApplicationCredentialID != "" {
+			appCred["id"] = opts.ApplicationCredentialID
+		} else if opts.ApplicationCredentialName != "" {
+			appCred["name"] = opts.ApplicationCredentialName
+			appCred["user"] = opts.makeUserV3()
+		} else {
+			return nil, ErrNoCredentials
+		}
+		return map[string]interface{}{
+			"methods":                []string{"application_credential"},
+			"application_credential": appCred,
+		}, nil
+	}
+
+	if opts.Password == "" || (opts.Username == "" && opts.UserID == "") {
+		return nil, ErrNoCredentials
+	}
+	user := opts.makeUserV3()
+	user["password"] = opts.Password
+	return map[string]interface{}{
+		"methods":  []string{"password"},
+		"password": map[string]interface{}{"user": user},
+	}, nil
+}
+
+func (opts *AuthOptionsV3) makeScopeV3() (map[string]interface{}, error) {
+	hasProject := opts.ProjectID != "" || opts.ProjectName != ""
+	domain := makeDomainV3(opts.DomainID, opts.DomainName)
+	if hasProject && domain != nil {
+		return nil, ErrInvalidAuthScope
+	}
+
+	if opts.ProjectID != "" {
+		return map[string]interface{}{"project": map[string]interface{}{"id": opts.ProjectID}}, nil
+	}
+	if opts.ProjectName != "" {
+		projDomain := makeDomainV3(opts.ProjectDomainID, opts.ProjectDomainName)
+		if projDomain == nil {
+			projDomain = makeDomainV3(opts.UserDomainID, opts.UserDomainName)
+		}
+		if projDomain == nil {
+			projDomain = map[string]interface{}{"id": "default"}
+		}
+		return map[string]interface{}{
+			"project": map[string]interface{}{"name": opts.ProjectName, "domain": projDomain},
+		}, nil
+	}
+	if domain != nil {
+		return map[string]interface{}{"domain": domain}, nil
+	}
+	return nil, nil
+}
+
+func (opts *AuthOptionsV3) makeTokenV3Body() (map[string]interface{}, error) {
+	identity, err := opts.makeIdentityV3()
+	if err != nil {
+		return nil, err
+	}
+	authMap := map[string]interface{}{"identity": identity}
+
+	// application credentials are always bound to a project, keystone
+	// rejects an explicit scope with them
+	if opts.ApplicationCredentialSecret == "" {
+		scope, err := opts.makeScopeV3()
+		if err != nil {
+			return nil, err
+		}
+		if scope != nil {
+			authMap["scope"] = scope
+		}
+	}
+	return map[string]interface{}{"auth": authMap}, nil
+}
+
+type EndpointV3 struct {
+	ID        string `json:"id"`
+	Interface string `json:"interface"`
+	Region    string `json:"region"`
+	RegionID  string `json:"region_id"`
+	URL       string `json:"url"`
+}
+
+type CatalogEntryV3 struct {
+	ID        string       `json:"id"`
+	Type      string       `json:"type"`
+	Name      string       `json:"name"`
+	Endpoints []EndpointV3 `json:"endpoints"`
+}
+
+type scopeV3 struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Domain struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"domain"`
+}
+
+type tokenV3Resp struct {
+	Token struct {
+		ExpiresAt time.Time        `json:"expires_at"`
+		IssuedAt  time.Time        `json:"issued_at"`
+		Project   *scopeV3         `json:"project"`
+		Domain    *scopeV3         `json:"domain"`
+		Catalog   []CatalogEntryV3 `json:"catalog"`
+	} `json:"token"`
+}
+
+type TokenV3 struct {
+	ID            string
+	ExpiresAt     time.Time
+	IssuedAt      time.Time
+	IssuedAtLocal time.Time
+	ProjectID     string
+	ProjectName   string
+	DomainID      string
+	DomainName    string
+	Catalog       []CatalogEntryV3
+}
+
+// AuthenticateV3 issues a new token from keystone v3.
+var AuthenticateV3 = func(opts *AuthOptionsV3) (*TokenV3, error) {
+	body, err := opts.makeTokenV3Body()
+	if err != nil {
+		klog.Errorf("AuthenticateV3: makeTokenV3Body error: %v", err)
+		return nil, fmt.Errorf("%v:AuthenticateV3: makeTokenV3Body error", err)
+	}
+
+	url := opts.GetTokensUrl()
+	status, header, rspBytes, err := adapter.DoHttpPostWithRespHeader(url, body, nil)
+	if err != nil {
+		klog.Errorf("AuthenticateV3: Post url[%s], status[%d], response body[%s] error: %v", url, status, string(rspBytes), err)
+		return nil, fmt.Errorf("%v,%v,%v:AuthenticateV3: Post request error", url, status, err)
+	}
+
+	tokenID := header.Get(SubjectTokenHeader)
+	if tokenID == "" {
+		klog.Errorf("AuthenticateV3: Post url[%s] error: %v", url, ErrNoSubjectToken)
+		return nil, ErrNoSubjectToken
+	}
+
+	resp := tokenV3Resp{}
+	err = json.Unmarshal(rspBytes, &resp)
+	if err != nil {
+		klog.Errorf("AuthenticateV3: json.Unmarshal response body[%s] error: %v", string(rspBytes), err)
+		return nil, fmt.Errorf("%v:AuthenticateV3: json.Unmarshal response body error", err)
+	}
+
+	token := &TokenV3{
+		ID:            tokenID,
+		ExpiresAt:     resp.Token.ExpiresAt,
+		IssuedAt:      resp.Token.IssuedAt,
+		IssuedAtLocal: time.Now(),
+		Catalog:       resp.Token.Catalog,
+	}
+	if resp.Token.Project != nil {
+		token.ProjectID = resp.Token.Project.ID
+		token.ProjectName = resp.Token.Project.Name
+		token.DomainID = resp.Token.Project.Domain.ID
+		token.DomainName = resp.Token.Project.Domain.Name
+	} else if resp.Token.Domain != nil {
+		token.DomainID = resp.Token.Domain.ID
+		token.DomainName = resp.Token.Domain.Name
+	}
+	klog.Infof("AuthenticateV3: token issued for project[%s], expires at %v", token.ProjectID, token.ExpiresAt)
+	return token, nil
+}
+
+// EndpointURL selects an endpoint of serviceType from the catalog, the
+// interface defaults to public and an empty region matches any region.
+func (token *TokenV3) EndpointURL(serviceType, iface, region string) (string, error) {
+	if iface == "" {
+		iface = EndpointInterfacePublic
+	}
+	iface = strings.TrimSuffix(strings.ToLower(iface), "url")
+	for _, entry := range token.Catalog {
+		if entry.Type != serviceType {
+			continue
+		}
+		for _, ep := range entry.Endpoints {
+			if ep.Interface != iface {
+				continue
+			}
+			if region != "" && ep.Region != region && ep.RegionID != region {
+				continue
+			}
+			return ep.URL, nil
+		}
+	}
+	klog.Errorf("EndpointURL: type[%s] interface[%s] region[%s] error: %v", serviceType, iface, region, ErrEndpointNotFound)
+	return "", fmt.Errorf("%v:type[%s] interface[%s] region[%s]", ErrEndpointNotFound, serviceType, iface, region)
+}
+
+// Lifetime returns the validity period of the token measured by keystone.
+func (token *TokenV3) Lifetime() time.Duration {
+	if token.ExpiresAt.IsZero() || token.IssuedAt.IsZero() {
+		return 0
+	}
+	return token.ExpiresAt.Sub(token.IssuedAt)
+}
+
+// NeedRefresh reports whether a token received at issuedAtLocal should be
+// renewed. The expiry is estimated with the local clock so that a skew between
+// keystone and this node does not matter, tokens without lifetime never expire.
+func NeedRefresh(issuedAtLocal time.Time, lifetime time.Duration) bool {
+	if issuedAtLocal.IsZero() || lifetime <= 0 {
+		return false
+	}
+	ahead := TokenRefreshBeforeExpiry
+	if half := lifetime / 2; half < ahead {
+		ahead = half
+	}
+	return time.Now().Add(ahead).After(issuedAtLocal.Add(lifetime))
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	th "github.com/ZTE/Knitter/pkg/openstack/driver/testhelper"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type fakeKeystone struct {
+	sync.Mutex
+	lifetime  time.Duration
+	issued    int
+	lastBody  map[string]interface{}
+	validToks map[string]bool
+	noCompute bool
+}
+
+func (f *fakeKeystone) issue() string {
+	f.Lock()
+	defer f.Unlock()
+	f.issued++
+	tok := fmt.Sprintf("token-%d", f.issued)
+	f.validToks[tok] = true
+	return tok
+}
+
+func (f *fakeKeystone) revokeAll() {
+	f.Lock()
+	defer f.Unlock()
+	f.validToks = map[string]bool{}
+}
+
+func (f *fakeKeystone) isValid(tok string) bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.validToks[tok]
+}
+
+func (f *fakeKeystone) issuedCount() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.issued
+}
+
+func (f *fakeKeystone) catalog() []map[string]interface{} {
+	base := th.Server.URL
+	catalog := []map[string]interface{}{
+		{"type": "network", "name": "neutron", "endpoints": []map[string]interface{}{
+			{"interface": "public", "region": "RegionOne", "region_id": "RegionOne", "url": base + "/public-one"},
+			{"interface": "internal", "region": "RegionOne", "region_id": "RegionOne", "url": base + "/neutron"},
+			{"interface": "internal", "region": "RegionTwo", "region_id": "RegionTwo", "url": base + "/internal-two"},
+		}},
+	}
+	if f.noCompute {
+		return catalog
+	}
+	return append(catalog, map[string]interface{}{"type": "compute", "name": "nova",
+		"endpoints": []map[string]interface{}{
+			{"interface": "internal", "region": "RegionOne", "region_id": "RegionOne", "url": base + "/nova/v2.1"},
+		}})
+}
+
+func setupFakeKeystone(t *testing.T, lifetime time.Duration) *fakeKeystone {
+	th.SetupHTTP()
+	f := &fakeKeystone{lifetime: lifetime, validToks: map[string]bool{}}
+	th.Mux.HandleFunc("/identity/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		th.TestMethod(t, r, "POST")
+		body, _ := ioutil.ReadAll(r.Body)
+		req := map[string]interface{}{}
+		json.Unmarshal(body, &req)
+		f.Lock()
+		f.lastBody = req
+		f.Unlock()
+
+		now := time.Now().UTC()
+		resp := map[string]interface{}{"token": map[string]interface{}{
+			"issued_at":  now.Format(time.RFC3339Nano),
+			"expires_at": now.Add(f.lifetime).Format(time.RFC3339Nano),
+			"project": map[string]interface{}{"id": "project-id", "name": "admin",
+				"domain": map[string]interface{}{"id": "default", "name": "Default"}},
+			"catalog": f.catalog(),
+		}}
+		w.Header().Set(SubjectTokenHeader, f.issue())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	})
+	th.Mux.HandleFunc("/neutron/v2.0/networks/net-id", func(w http.ResponseWriter, r *http.Request) {
+		if !f.isValid(r.Header.Get("X-Auth-Token")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"network": {"id": "net-id", "name": "net_api"}}`)
+	})
+	return f
+}
+
+func lookup(m map[string]interface{}, keys ...string) interface{} {
+	var v interface{} = m
+	for _, k := range keys {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[k]
+	}
+	return v
+}
+
+func TestGetAuthVersion(t *testing.T) {
+	convey.Convey("TestGetAuthVersion", t, func() {
+		v, err := GetAuthVersion("http://ks:5000/v2.0", KeystoneV3Conf{})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(v, convey.ShouldEqual, AuthVersionV2)
+		v, _ = GetAuthVersion("http://ks:5000/v3/", KeystoneV3Conf{})
+		convey.So(v, convey.ShouldEqual, AuthVersionV3)
+		v, _ = GetAuthVersion("http://ks:5000/v2.0", KeystoneV3Conf{AuthVersion: "3"})
+		convey.So(v, convey.ShouldEqual, AuthVersionV3)
+		_, err = GetAuthVersion("http://ks:5000", KeystoneV3Conf{AuthVersion: "v4"})
+		convey.So(err, convey.ShouldEqual, ErrUnknownAuthVersion)
+
+		convey.So(GetIdentityV3Endpoint("http://ks:5000"), convey.ShouldEqual, "http://ks:5000/v3")
+		convey.So(GetIdentityV3Endpoint("http://ks:5000/v3/"), convey.ShouldEqual, "http://ks:5000/v3")
+		convey.So(GetIdentityV3Endpoint("http://ks:5000/v2.0"), convey.ShouldEqual, "http://ks:5000/v3")
+	})
+}
+
+func TestMakeTokenV3Body(t *testing.T) {
+	convey.Convey("TestMakeTokenV3Body", t, func() {
+		convey.Convey("password with project name scope\n", func() {
+			opts := AuthOptionsV3{Username: "admin", Password: "pwd", UserDomainName: "Default",
+				ProjectName: "admin", ProjectDomainID: "default"}
+			body, err := opts.makeTokenV3Body()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(lookup(body, "auth", "identity", "password", "user", "name"), convey.ShouldEqual, "admin")
+			convey.So(lookup(body, "auth", "identity", "password", "user", "domain", "name"), convey.ShouldEqual, "Default")
+			convey.So(lookup(body, "auth", "scope", "project", "name"), convey.ShouldEqual, "admin")
+			convey.So(lookup(body, "auth", "scope", "project", "domain", "id"), convey.ShouldEqual, "default")
+		})
+
+		convey.Convey("password with domain scope\n", func() {
+			opts := AuthOptionsV3{UserID: "uid", Password: "pwd", DomainName: "Default"}
+			body, err := opts.makeTokenV3Body()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(lookup(body, "auth", "identity", "password", "user", "id"), convey.ShouldEqual, "uid")
+			convey.So(lookup(body, "auth", "scope", "domain", "name"), convey.ShouldEqual, "Default")
+		})
+
+		convey.Convey("project and domain scope are exclusive\n", func() {
+			opts := AuthOptionsV3{Username: "admin", Password: "pwd", ProjectID: "p", DomainID: "d"}
+			_, err := opts.makeTokenV3Body()
+			convey.So(err, convey.ShouldEqual, ErrInvalidAuthScope)
+		})
+
+		convey.Convey("application credential has no scope\n", func() {
+			opts := AuthOptionsV3{ApplicationCredentialID: "ac", ApplicationCredentialSecret: "s", ProjectID: "p"}
+			body, err := opts.makeTokenV3Body()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(lookup(body, "auth", "identity", "application_credential", "id"), convey.ShouldEqual, "ac")
+			convey.So(lookup(body, "auth", "identity", "application_credential", "secret"), convey.ShouldEqual, "s")
+			convey.So(lookup(body, "auth", "scope"), convey.ShouldBeNil)
+		})
+
+		convey.Convey("no credentials\n", func() {
+			opts := AuthOptionsV3{Username: "admin"}
+			_, err := opts.makeTokenV3Body()
+			convey.So(err, convey.ShouldEqual, ErrNoCredentials)
+		})
+	})
+}
+
+func TestAuthenticateV3(t *testing.T) {
+	f := setupFakeKeystone(t, time.Hour)
+	defer th.TeardownHTTP()
+
+	convey.Convey("TestAuthenticateV3", t, func() {
+		opts := NewAuthOptionsV3(th.Server.URL+"/identity", "", "", "", "",
+			KeystoneV3Conf{ApplicationCredentialName: "knitter", ApplicationCredentialSecret: "s",
+				UserDomainName: "Default"})
+		opts.Username = "admin"
+		token, err := AuthenticateV3(opts)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(token.ID, convey.ShouldEqual, "token-1")
+		convey.So(token.ProjectID, convey.ShouldEqual, "project-id")
+		convey.So(token.ProjectName, convey.ShouldEqual, "admin")
+		convey.So(token.Lifetime(), convey.ShouldEqual, time.Hour)
+		convey.So(lookup(f.lastBody, "auth", "identity", "application_credential", "user", "name"),
+			convey.ShouldEqual, "admin")
+
+		url, err := token.EndpointURL(NetworkEndpointType, "", "")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(url, convey.ShouldEqual, th.Server.URL+"/public-one")
+		url, _ = token.EndpointURL(NetworkEndpointType, "internalURL", "RegionTwo")
+		convey.So(url, convey.ShouldEqual, th.Server.URL+"/internal-two")
+		_, err = token.EndpointURL(ComputeEndpointType, EndpointInterfaceAdmin, "")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestNeedRefresh(t *testing.T) {
+	convey.Convey("TestNeedRefresh", t, func() {
+		convey.So(NeedRefresh(time.Time{}, time.Hour), convey.ShouldBeFalse)
+		convey.So(NeedRefresh(time.Now(), 0), convey.ShouldBeFalse)
+		convey.So(NeedRefresh(time.Now(), time.Hour), convey.ShouldBeFalse)
+		convey.So(NeedRefresh(time.Now().Add(-58*time.Minute), time.Hour), convey.ShouldBeTrue)
+		convey.So(NeedRefresh(time.Now().Add(-3*time.Second), 4*time.Second), convey.ShouldBeTrue)
+	})
+}
+
+func TestDriverTokenLifecycleV3(t *testing.T) {
+	f := setupFakeKeystone(t, time.Hour)
+	defer th.TeardownHTTP()
+
+	convey.Convey("TestDriverTokenLifecycleV3", t, func() {
+		cfg := fmt.Sprintf(`{"username": "admin", "password": "pwd", "url": "%s/identity",
+			"tenantname": "admin", "project_domain_name": "Default", "interface": "internal",
+			"region": "RegionOne"}`, th.Server.URL)
+		op := NewOpenstack()
+		convey.So(op.SetOpenstackConfig(cfg), convey.ShouldBeNil)
+		convey.So(op.Auth(), convey.ShouldBeNil)
+		auth := getAuthSingleton()
+		convey.So(auth.NetworkEndpoint, convey.ShouldEqual, th.Server.URL+"/neutron/v2.0/")
+		convey.So(auth.ComputeEndpoint, convey.ShouldEqual, th.Server.URL+"/nova/v2.1/")
+		tenantID, _ := op.GetTenantUUID("")
+		convey.So(tenantID, convey.ShouldEqual, "project-id")
+		convey.So(lookup(f.lastBody, "auth", "scope", "project", "domain", "name"), convey.ShouldEqual, "Default")
+
+		convey.Convey("cached token is reused\n", func() {
+			issued := f.issuedCount()
+			_, err := op.GetNetwork("net-id")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(f.issuedCount(), convey.ShouldEqual, issued)
+		})
+
+		convey.Convey("re-auth on 401\n", func() {
+			f.revokeAll()
+			issued := f.issuedCount()
+			network, err := op.GetNetwork("net-id")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(network.Name, convey.ShouldEqual, "net_api")
+			convey.So(f.issuedCount(), convey.ShouldEqual, issued+1)
+		})
+
+		convey.Convey("refresh before expiry\n", func() {
+			auth.mutex.Lock()
+			auth.TokenIssuedAtLocal = time.Now().Add(-59 * time.Minute)
+			auth.mutex.Unlock()
+			issued := f.issuedCount()
+			_, err := op.GetNetwork("net-id")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(f.issuedCount(), convey.ShouldEqual, issued+1)
+			convey.So(NeedRefresh(auth.TokenIssuedAtLocal, auth.TokenLifetime), convey.ShouldBeFalse)
+		})
+	})
+}
+
+func TestDriverAuthV3WithoutComputeEndpoint(t *testing.T) {
+	f := setupFakeKeystone(t, time.Hour)
+	defer th.TeardownHTTP()
+	f.noCompute = true
+
+	convey.Convey("TestDriverAuthV3WithoutComputeEndpoint", t, func() {
+		cfgFmt := `{"username": "admin", "password": "pwd", "url": "%s/identity",
+			"tenantname": "admin", "project_domain_name": "Default", "interface": "internal",
+			"region": "%s"}`
+
+		convey.Convey("network endpoint is enough\n", func() {
+			op := NewOpenstack()
+			convey.So(op.SetOpenstackConfig(fmt.Sprintf(cfgFmt, th.Server.URL, "RegionOne")), convey.ShouldBeNil)
+			convey.So(op.Auth(), convey.ShouldBeNil)
+			auth := getAuthSingleton()
+			convey.So(auth.NetworkEndpoint, convey.ShouldEqual, th.Server.URL+"/neutron/v2.0/")
+			convey.So(auth.ComputeEndpoint, convey.ShouldEqual, "")
+			_, err := op.AttachPortToVM("vm-id", "port-id")
+			convey.So(err, convey.ShouldEqual, ErrNoComputeEndpoint)
+		})
+
+		convey.Convey("no network endpoint fails\n", func() {
+			op := NewOpenstack()
+			convey.So(op.SetOpenstackConfig(fmt.Sprintf(cfgFmt, th.Server.URL, "RegionThree")), convey.ShouldBeNil)
+			err := op.Auth()
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, ErrEndpointNotFound.Error())
+		})
+	})
+}
@@ -26,6 +26,8 @@ import (
 type HTTPMethods interface {
 	Get(url string, headers map[string]string) (int, []byte, error)
 	Post(url string, body map[string]interface{}, headers map[string]string) (int, []byte, error)
+	PostWithRespHeader(url string, body map[string]interface{}, headers map[string]string) (int, http.Header, []byte, error)
+	Put(url string, body map[string]interface{}, headers map[string]string) (int, []byte, error)
 	Delete(url string, headers map[string]string) (int, error)
 }
 
@@ -82,10 +84,17 @@ func (self *httpClient) Get(url string, headers map[string]string) (int, []byte,
 }
 
 func (self *httpClient) Post(url string, body map[string]interface{}, headers map[string]string) (int, []byte, error) {
+	status, _, respBody, err := self.PostWithRespHeader(url, body, headers)
+	return status, respBody, err
+}
+
+// PostWithRespHeader is the same as Post but also returns the response header,
+// which is needed by callers such as keystone v3 that carry data in headers.
+func (self *httpClient) PostWithRespHeader(url string, body map[string]interface{}, headers map[string]string) (int, http.Header, []byte, error) {
 	bodyBytes, err := json.Marshal(body)
 	if err != nil {
 		klog.Error("Post: json.Marshal [", body, "]error: ", err.Error())
-		return http.StatusInternalServerError, nil, fmt.Errorf("%v:Post: json.Marshal body error", err)
+		return http.StatusInternalServerError, nil, nil, fmt.Errorf("%v:Post: json.Marshal body error", err)
 	}
 
 	bodyReader := bytes.NewReader(bodyBytes)
@@ -94,7 +103,7 @@ func (self *httpClient) Post(url string, body map[string]interface{}, headers ma
 	request, err := http.NewRequest("POST", url, bodyReader)
 	if err != nil {
 		klog.Error("Post: http NewRequest error: ", err.Error())
-		return http.StatusInternalServerError, nil, fmt.Errorf("%v:http NewRequest error", err)
+		return http.StatusInternalServerError, nil, nil, fmt.Errorf("%v:http NewRequest error", err)
 	}
 
 	if headers != nil {
@@ -117,18 +126,67 @@ func (self *httpClient) Post(url string, body map[string]interface{}, headers ma
 	}
 	if err != nil {
 		klog.Error("Post: client.Do error: ", err.Error())
-		return status, nil, errors.New("http client.Do error")
+		return status, nil, nil, errors.New("http client.Do error")
 	}
 
 	defer response.Body.Close()
 	respBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		klog.Error("Post: ioutil.ReadAll: [", url, "] error: ", err.Error())
-		return status, nil, fmt.Errorf("%v:ioutil.ReadAll response error", err)
+		return status, response.Header, nil, fmt.Errorf("%v:ioutil.ReadAll response error", err)
 	}
 
 	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
 		klog.Error("Post: http client.Do[", url, "] response error, status code: ", response.StatusCode, ", response body: ", string(respBody))
+		return status, response.Header, respBody, errors.New("http client.Do response error")
+	}
+	return status, response.Header, respBody, nil
+}
+
+func (self *httpClient) Put(url string, body map[string]interface{}, headers map[string]string) (int, []byte, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		klog.Error("Put: json.Marshal [", body, "]error: ", err.Error())
+		return http.StatusInternalServerError, nil, fmt.Errorf("%v:Put: json.Marshal body error", err)
+	}
+
+	client := &http.Client{}
+	request, err := http.NewRequest("PUT", url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		klog.Error("Put: http NewRequest error: ", err.Error())
+		return http.StatusInternalServerError, nil, fmt.Errorf("%v:http NewRequest error", err)
+	}
+
+	for k, v := range headers {
+		if v != "" {
+			request.Header.Set(k, v)
+		} else {
+			request.Header.Del(k)
+		}
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	var status int
+	if response != nil {
+		status = response.StatusCode
+	} else {
+		status = http.StatusInternalServerError
+	}
+	if err != nil {
+		klog.Error("Put: client.Do error: ", err.Error())
+		return status, nil, errors.New("http client.Do error")
+	}
+
+	defer response.Body.Close()
+	respBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		klog.Error("Put: ioutil.ReadAll: [", url, "] error: ", err.Error())
+		return status, nil, fmt.Errorf("%v:ioutil.ReadAll response error", err)
+	}
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		klog.Error("Put: http client.Do[", url, "] response error, status code: ", response.StatusCode, ", response body: ", string(respBody))
 		return status, respBody, errors.New("http client.Do response error")
 	}
 	return status, respBody, nil
@@ -291,7 +291,7 @@ func (self *NeutronClient) CreateBulkPorts(req *mgriaas.MgrBulkPortsReq) ([]*Int
 	url := self.getPortsUrl()
 	opts := make([]map[string]interface{}, 0)
 	for _, reqPort := range req.Ports {
-		opt := makeCreatePort(reqPort.NetworkId, reqPort.SubnetId, reqPort.PortName, reqPort.FixIP, "", LogicalPortDefaultVnicType)
+		opt := makeCreatePort(reqPort.NetworkId, reqPort.SubnetId, reqPort.PortName, reqPort.FixIP, reqPort.FixMac, LogicalPortDefaultVnicType)
 		opts = append(opts, opt)
 	}
 	m := make(map[string]interface{})
@@ -361,6 +361,18 @@ func (self *NeutronClient) DeletePort(id string) error {
 	return nil
 }
 
+func (self *NeutronClient) UpdatePortName(id, name string) error {
+	url := self.getPortIdUrl(id)
+	body := map[string]interface{}{"port": map[string]interface{}{"name": name}}
+	status, _, err := doHttpPutWithReAuth(url, body)
+	if status < http.StatusOK || status > http.StatusMultipleChoices || err != nil {
+		klog.Error("UpdatePortName: Put url[", url, "], status[", status, "], error: ", err)
+		return fmt.Errorf("%v:%v:UpdatePortName: Put request error", status, err)
+	}
+
+	return nil
+}
+
 func (self *NeutronClient) getPortIdUrl(id string) string {
 	return self.getPortsUrl() + "/" + id
 }
@@ -854,15 +866,23 @@ func (self *NeutronClient) DetachNetFromRouter(routerId, subNetId string) (strin
 	return portId, nil
 }
 
+func refreshTokenIfNeeded() {
+	err := getAuthSingleton().refreshTokenIfNeeded()
+	if err != nil {
+		klog.Warningf("refreshTokenIfNeeded: refresh token error: %v", err)
+	}
+}
+
 func doHttpPostWithReAuth(url string, body map[string]interface{}) (int, []byte, error) {
+	refreshTokenIfNeeded()
 	header := make(map[string]string)
-	header["X-Auth-Token"] = getAuthSingleton().TokenID
+	header["X-Auth-Token"] = getAuthSingleton().getTokenID()
 	status, rspBytes, err := adapter.DoHttpPost(url, body, header)
 	//reauth
 	if status == http.StatusUnauthorized && getAuthSingleton().AllowReauth {
 		klog.Warning("doHttpPostWithReAuth, url:[", url, "]")
 		getAuthSingleton().auth()
-		header["X-Auth-Token"] = getAuthSingleton().TokenID
+		header["X-Auth-Token"] = getAuthSingleton().getTokenID()
 		status, rspBytes, err = adapter.DoHttpPost(url, body, header)
 	}
 
@@ -870,29 +890,47 @@ func doHttpPostWithReAuth(url string, body map[string]interface{}) (int, []byte,
 }
 
 func doHttpGetWithReAuth(url string) (int, []byte, error) {
+	refreshTokenIfNeeded()
 	header := make(map[string]string)
-	header["X-Auth-Token"] = getAuthSingleton().TokenID
+	header["X-Auth-Token"] = getAuthSingleton().getTokenID()
 	status, rspBytes, err := adapter.DoHttpGet(url, header)
 	//reauth
 	if status == http.StatusUnauthorized && getAuthSingleton().AllowReauth {
 		klog.Warning("doHttpGetWithReAuth, url:[", url, "]")
 		getAuthSingleton().auth()
-		header["X-Auth-Token"] = getAuthSingleton().TokenID
+		header["X-Auth-Token"] = getAuthSingleton().getTokenID()
 		status, rspBytes, err = adapter.DoHttpGet(url, header)
 	}
 
 	return status, rspBytes, err
 }
 
+func doHttpPutWithReAuth(url string, body map[string]interface{}) (int, []byte, error) {
+	refreshTokenIfNeeded()
+	header := make(map[string]string)
+	header["X-Auth-Token"] = getAuthSingleton().getTokenID()
+	status, rspBytes, err := adapter.DoHttpPut(url, body, header)
+	//reauth
+	if status == http.StatusUnauthorized && getAuthSingleton().AllowReauth {
+		klog.Warning("doHttpPutWithReAuth, url:[", url, "]")
+		getAuthSingleton().auth()
+		header["X-Auth-Token"] = getAuthSingleton().getTokenID()
+		status, rspBytes, err = adapter.DoHttpPut(url, body, header)
+	}
+
+	return status, rspBytes, err
+}
+
 func doHttpDeleteWithReAuth(url string) (int, error) {
+	refreshTokenIfNeeded()
 	header := make(map[string]string)
-	header["X-Auth-Token"] = getAuthSingleton().TokenID
+	header["X-Auth-Token"] = getAuthSingleton().getTokenID()
 	status, err := adapter.DoHttpDelete(url, header)
 	//reauth
 	if status == http.StatusUnauthorized && getAuthSingleton().AllowReauth {
 		klog.Warning("doHttpDeleteWithReAuth, url:[", url, "]")
 		getAuthSingleton().auth()
-		header["X-Auth-Token"] = getAuthSingleton().TokenID
+		header["X-Auth-Token"] = getAuthSingleton().getTokenID()
 		status, err = adapter.DoHttpDelete(url, header)
 	}
 
@@ -14,6 +14,7 @@ limitations under the License.
 package driver
 
 import (
+	"errors"
 	"fmt"
 	. "github.com/ZTE/Knitter/pkg/iaas-accessor"
 	"github.com/ZTE/Knitter/pkg/klog"
@@ -24,6 +25,8 @@ import (
 
 const MaxReqForAttach int = 5
 
+var ErrNoComputeEndpoint = errors.New("no compute endpoint in keystone catalog")
+
 type NovaClient struct {
 	VmLock    map[string]*sync.Mutex
 	Channel   chan int
@@ -59,6 +62,10 @@ func (self *NovaClient) makeAttachPortBody(portId string) map[string]interface{}
 }
 
 func (self *NovaClient) AttachPortToVM(vmId, portId string) (*Interface, error) {
+	if getAuthSingleton().ComputeEndpoint == "" {
+		klog.Errorf("AttachPortToVM: attach port[%s] to vm[%s] error: %v", portId, vmId, ErrNoComputeEndpoint)
+		return nil, ErrNoComputeEndpoint
+	}
 	if self.Channel == nil {
 		klog.Infof("make channel size:%d", self.AttachReq)
 		self.Channel = make(chan int, self.AttachReq)
@@ -120,6 +127,10 @@ func (self *NovaClient) parseAttachInterface(obj *jason.Object) (*Interface, err
 }
 
 func (self *NovaClient) DetachPortFromVM(vmId, portId string) error {
+	if getAuthSingleton().ComputeEndpoint == "" {
+		klog.Errorf("DetachPortFromVM: detach port[%s] from vm[%s] error: %v", portId, vmId, ErrNoComputeEndpoint)
+		return ErrNoComputeEndpoint
+	}
 	if self.Channel == nil {
 		klog.Infof("make channel size:%d", self.AttachReq)
 		self.Channel = make(chan int, self.AttachReq)
@@ -42,6 +42,7 @@ type OpenStackConf struct {
 	Url        string `json:"url"`
 	Tenantid   string `json:"tenantid"`
 	TenantName string `json:"tenantname"`
+	KeystoneV3Conf
 }
 
 func (self *OpenStack) SetOpenstackConfig(cfgStr string) error {
@@ -64,7 +65,11 @@ func (self *OpenStack) SetOpenstackConfig(cfgStr string) error {
 	}
 
 	klog.Info("IaaS-SetOpenstackConfig-Unmarshal-end:", config)
-	auth.setConf(config)
+	err = auth.setConf(config)
+	if err != nil {
+		klog.Info("IaaS-SetOpenstackConfig-setConf-error:", err)
+		return err
+	}
 
 	klog.Info("IaaS-SetOpenstackConfig-end:", auth)
 	return nil
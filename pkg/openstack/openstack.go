@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"github.com/ZTE/Knitter/pkg/adapter"
 	. "github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/agt-mgr"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
 	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/ZTE/Knitter/pkg/openstack/driver"
 	"github.com/rackspace/gophercloud"
 	"github.com/rackspace/gophercloud/openstack"
 	"github.com/rackspace/gophercloud/openstack/compute/v2/servers"
@@ -44,6 +46,9 @@ type OpenStack struct {
 	novaClient    *gophercloud.ServiceClient
 	provider      *gophercloud.ProviderClient
 	config        gophercloud.AuthOptions
+	configV3      *driver.AuthOptionsV3
+	region        string
+	availability  gophercloud.Availability
 	VmLock        map[string]*sync.Mutex
 	Channel       chan int
 	AttachReq     int
@@ -55,6 +60,7 @@ type OpenStackConf struct {
 	Url        string `json:"url"`
 	Tenantid   string `json:"tenantid"`
 	TenantName string `json:"tenantname"`
+	driver.KeystoneV3Conf
 }
 
 func NewOpenstack() *OpenStack {
@@ -82,21 +88,41 @@ func (self *OpenStack) SetOpenstackConfig(cfgStr string) error {
 	err := json.Unmarshal([]byte(cfgStr), &config)
 	if err != nil {
 		self.config = gophercloud.AuthOptions{}
+		self.configV3 = nil
 		return err
 	}
-	conf := gophercloud.AuthOptions{
+	return self.SetOpenStackConf(config)
+}
+
+// SetOpenStackConf selects keystone v2 or v3 by the config, see driver.GetAuthVersion.
+func (self *OpenStack) SetOpenStackConf(config OpenStackConf) error {
+	version, err := driver.GetAuthVersion(config.Url, config.KeystoneV3Conf)
+	if err != nil {
+		klog.Errorf("SetOpenStackConf: GetAuthVersion error: %v", err)
+		return err
+	}
+	self.region = config.Region
+	self.availability = gophercloud.Availability(config.Interface)
+	if version == driver.AuthVersionV3 {
+		self.config = gophercloud.AuthOptions{}
+		self.configV3 = newAuthOptionsV3(&config)
+		return nil
+	}
+	self.config = gophercloud.AuthOptions{
 		IdentityEndpoint: config.Url + "/tokens",
 		Username:         config.Username,
 		Password:         config.Password,
 		TenantID:         config.Tenantid,
+		TenantName:       config.TenantName,
 		AllowReauth:      true,
 	}
-	self.config = conf
+	self.configV3 = nil
 	return nil
 }
 
 func (self *OpenStack) SetConfig(conf gophercloud.AuthOptions) {
 	self.config = conf
+	self.configV3 = nil
 }
 
 func (self *OpenStack) GetType() string {
@@ -136,10 +162,81 @@ func AuthenticatedClientV2(options gophercloud.AuthOptions) (*gophercloud.Provid
 	return client, nil
 }
 
+func newAuthOptionsV3(conf *OpenStackConf) *driver.AuthOptionsV3 {
+	return driver.NewAuthOptionsV3(conf.Url, conf.Username, conf.Password,
+		conf.Tenantid, conf.TenantName, conf.KeystoneV3Conf)
+}
+
+// AuthenticatedClient authenticates with keystone v2 or v3 according to conf.
+func AuthenticatedClient(conf *OpenStackConf) (*gophercloud.ProviderClient, error) {
+	version, err := driver.GetAuthVersion(conf.Url, conf.KeystoneV3Conf)
+	if err != nil {
+		return nil, err
+	}
+	if version == driver.AuthVersionV3 {
+		return AuthenticatedClientV3(newAuthOptionsV3(conf))
+	}
+	return AuthenticatedClientV2(gophercloud.AuthOptions{
+		IdentityEndpoint: conf.Url + "/tokens",
+		Username:         conf.Username,
+		Password:         conf.Password,
+		TenantID:         conf.Tenantid,
+		TenantName:       conf.TenantName,
+		AllowReauth:      true,
+	})
+}
+
+// AuthenticatedClientV3 returns a provider client holding a keystone v3 token,
+// the token is renewed by gophercloud before it expires and on 401 responses.
+func AuthenticatedClientV3(options *driver.AuthOptionsV3) (*gophercloud.ProviderClient, error) {
+	client, err := openstack.NewClient(options.IdentityEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	err = setProviderTokenV3(client, options)
+	if err != nil {
+		return nil, err
+	}
+	client.ReauthFunc = func() error {
+		return setProviderTokenV3(client, options)
+	}
+	return client, nil
+}
+
+func setProviderTokenV3(client *gophercloud.ProviderClient, options *driver.AuthOptionsV3) error {
+	token, err := driver.AuthenticateV3(options)
+	if err != nil {
+		klog.Errorf("setProviderTokenV3: AuthenticateV3 error: %v", err)
+		return err
+	}
+
+	client.TokenID = token.ID
+	client.TenantID = token.ProjectID
+	client.TenantName = token.ProjectName
+	client.TokenIssuedAt = token.IssuedAt
+	client.TokenIssuedAtLocal = token.IssuedAtLocal
+	client.TokenExpiresAt = token.ExpiresAt
+	client.EndpointLocator = func(opts gophercloud.EndpointOpts) (string, error) {
+		url, err := token.EndpointURL(opts.Type, string(opts.Availability), opts.Region)
+		if err != nil {
+			return "", err
+		}
+		return gophercloud.NormalizeURL(url), nil
+	}
+	return nil
+}
+
 func (self *OpenStack) setProvider() error {
-	provider, err := AuthenticatedClientV2(self.config)
+	var provider *gophercloud.ProviderClient
+	var err error
+	if self.configV3 != nil {
+		provider, err = AuthenticatedClientV3(self.configV3)
+	} else {
+		provider, err = AuthenticatedClientV2(self.config)
+	}
 	if err != nil {
-		klog.Error("AuthenticatedClientV2 ERROR:", err.Error())
+		klog.Error("AuthenticatedClient ERROR:", err.Error())
 		return err
 	}
 	self.provider = provider
@@ -148,7 +245,7 @@ func (self *OpenStack) setProvider() error {
 
 func (self *OpenStack) setNeutronClient() error {
 	client, err := openstack.NewNetworkV2(self.provider,
-		gophercloud.EndpointOpts{Name: "neutron"})
+		gophercloud.EndpointOpts{Name: "neutron", Region: self.region, Availability: self.availability})
 	if err != nil {
 		klog.Error("setNeutronClient failed!", err)
 		return err
@@ -159,7 +256,7 @@ func (self *OpenStack) setNeutronClient() error {
 
 func (self *OpenStack) setNovaClient() error {
 	client, err := openstack.NewComputeV2(self.provider,
-		gophercloud.EndpointOpts{Name: "nova"})
+		gophercloud.EndpointOpts{Name: "nova", Region: self.region, Availability: self.availability})
 	if err != nil {
 		klog.Error("setNovaClient failed!", err)
 		return err
@@ -206,12 +303,44 @@ func (self *OpenStack) CreatePort(networkId, subnetId, portName, ip, mac, vnicTy
 	return &port, nil
 }
 
+// bulkPortsOpts adds port_security_enabled, which ports.CreateOpts does not
+// know, to the request body of bulk ports creation.
+type bulkPortsOpts struct {
+	ports.BulkPorts
+	portSecurityEnabled []*bool
+}
+
+func (opts bulkPortsOpts) ToPortCreateMap() (map[string]interface{}, error) {
+	body, err := opts.BulkPorts.ToPortCreateMap()
+	if err != nil {
+		return nil, err
+	}
+	portMaps, _ := body["ports"].([]interface{})
+	for i, portMap := range portMaps {
+		if i < len(opts.portSecurityEnabled) && opts.portSecurityEnabled[i] != nil {
+			portMap.(map[string]interface{})["port_security_enabled"] = *opts.portSecurityEnabled[i]
+		}
+	}
+	return body, nil
+}
+
+func setPortSecurityOps(ops *ports.CreateOpts, security *agtmgr.PortSecurity) {
+	if len(security.SecurityGroups) != 0 {
+		ops.SecurityGroups = security.SecurityGroups
+	}
+	if len(security.AllowedAddressPairs) != 0 {
+		ops.AllowedAddressPairs = security.AllowedAddressPairs
+	}
+}
+
 func (o *OpenStack) CreateBulkPorts(req *mgriaas.MgrBulkPortsReq) ([]*Interface, error) {
-	opts := ports.BulkPorts{Opts: make([]*ports.CreateOpts, 0)}
+	opts := bulkPortsOpts{BulkPorts: ports.BulkPorts{Opts: make([]*ports.CreateOpts, 0)}}
 	for _, reqPort := range req.Ports {
 		opt, _ := o.makeCreatePortOps(reqPort.NetworkId, reqPort.SubnetId, reqPort.PortName,
-			reqPort.FixIP, "", reqPort.VnicType)
+			reqPort.FixIP, reqPort.FixMac, reqPort.VnicType)
+		setPortSecurityOps(opt, &reqPort.PortSecurity)
 		opts.Opts = append(opts.Opts, opt)
+		opts.portSecurityEnabled = append(opts.portSecurityEnabled, reqPort.PortSecurityEnabled)
 	}
 
 	newPorts, err := adapter.CreateBulkPorts(o.neutronClient, opts)
@@ -241,7 +370,10 @@ func (self *OpenStack) GetPort(id string) (*Interface, error) {
 	klog.Infof("GetPort: port[id: %s] info details: %v", id, pt)
 	rp := Interface{Name: pt.Name, Status: pt.Status, Id: pt.ID,
 		Ip: self.getPortIpAddr(pt.FixedIPs), MacAddress: pt.MACAddress,
-		NetworkId: pt.NetworkID, DeviceId: pt.DeviceID, SubnetId: pt.FixedIPs[0].SubnetID}
+		NetworkId: pt.NetworkID, DeviceId: pt.DeviceID}
+	if len(pt.FixedIPs) > 0 {
+		rp.SubnetId = pt.FixedIPs[0].SubnetID
+	}
 	klog.Info("GetPort OK :", rp)
 	return &rp, nil
 }
@@ -281,14 +413,31 @@ func (self *OpenStack) ListPorts(networkID string) ([]*Interface, error) {
 	for idx, port := range ports {
 		nif := Interface{}
 		nif.Id = port.ID
+		nif.Name = port.Name
+		nif.Status = port.Status
+		nif.MacAddress = port.MACAddress
 		nif.DeviceId = port.DeviceID
 		nif.NetworkId = port.NetworkID
+		if len(port.FixedIPs) > 0 {
+			nif.Ip = port.FixedIPs[0].IPAddress
+			nif.SubnetId = port.FixedIPs[0].SubnetID
+		}
 		nifs[idx] = &nif
 	}
 	klog.Tracef("ListPorts result: %v SUCC", nifs)
 	return nifs, nil
 }
 
+func (self *OpenStack) UpdatePortName(id, name string) error {
+	_, err := ports.Update(self.neutronClient, id, ports.UpdateOpts{Name: name}).Extract()
+	if err != nil {
+		klog.Errorf("UpdatePortName call ports.Update port[id: %s] name: %s error: %v", id, name, err)
+		return err
+	}
+	klog.Infof("UpdatePortName: port[id: %s] name: %s OK", id, name)
+	return nil
+}
+
 func (self *OpenStack) CreateNetwork(name string) (*Network, error) {
 	iTrue := true
 	iFalse := false
@@ -14,8 +14,15 @@ limitations under the License.
 package openstack
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
 	"github.com/ZTE/Knitter/knitter-manager/err-obj"
 	"github.com/ZTE/Knitter/pkg/adapter"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/agt-mgr"
 	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
 	. "github.com/golang/gostub"
 	"github.com/rackspace/gophercloud"
@@ -82,6 +89,44 @@ func TestCreateBulkPorts(t *testing.T) {
 	})
 }
 
+func TestCreateBulkPortsWithPortSecurity(t *testing.T) {
+	disabled := false
+	req := mgriaas.MgrBulkPortsReq{Ports: []*mgriaas.MgrPortReq{{}, {}, {}}}
+	req.Ports[0].NetworkId = "networkId0"
+	req.Ports[0].SecurityGroups = []string{"sg0", "sg1"}
+	req.Ports[0].AllowedAddressPairs = []ports.AddressPair{{IPAddress: "10.0.0.100"}}
+	req.Ports[1].NetworkId = "networkId1"
+	req.Ports[1].PortSecurity = agtmgr.PortSecurity{PortSecurityEnabled: &disabled}
+	req.Ports[2].NetworkId = "networkId2"
+
+	var body map[string]interface{}
+	stubs := Stub(&adapter.CreateBulkPorts, func(c *gophercloud.ServiceClient, opts ports.CreateOptsBuilder) ([]*ports.Port, error) {
+		body, _ = opts.ToPortCreateMap()
+		return []*ports.Port{}, nil
+	})
+	defer stubs.Reset()
+
+	convey.Convey("TestCreateBulkPortsWithPortSecurity", t, func() {
+		openStack := OpenStack{}
+		_, err := openStack.CreateBulkPorts(&req)
+		convey.So(err, convey.ShouldBeNil)
+		portMaps := body["ports"].([]interface{})
+		convey.So(len(portMaps), convey.ShouldEqual, 3)
+		port0 := portMaps[0].(map[string]interface{})
+		convey.So(port0["security_groups"], convey.ShouldResemble, []string{"sg0", "sg1"})
+		convey.So(port0["allowed_address_pairs"], convey.ShouldResemble, []ports.AddressPair{{IPAddress: "10.0.0.100"}})
+		_, ok := port0["port_security_enabled"]
+		convey.So(ok, convey.ShouldBeFalse)
+		port1 := portMaps[1].(map[string]interface{})
+		convey.So(port1["port_security_enabled"], convey.ShouldEqual, false)
+		_, ok = port1["security_groups"]
+		convey.So(ok, convey.ShouldBeFalse)
+		port2 := portMaps[2].(map[string]interface{})
+		_, ok = port2["port_security_enabled"]
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+}
+
 func Test_GetTenantID(t *testing.T) {
 	convey.Convey("Test GetTenantID", t, func() {
 		openStack := OpenStack{
@@ -93,3 +138,62 @@ func Test_GetTenantID(t *testing.T) {
 		convey.So(id, convey.ShouldEqual, "tenantid")
 	})
 }
+
+func newFakeKeystoneV3(lifetime time.Duration) (*httptest.Server, *int) {
+	issued := 0
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		now := time.Now().UTC()
+		resp := map[string]interface{}{"token": map[string]interface{}{
+			"issued_at":  now.Format(time.RFC3339),
+			"expires_at": now.Add(lifetime).Format(time.RFC3339),
+			"project":    map[string]interface{}{"id": "project-id", "name": "admin"},
+			"catalog": []map[string]interface{}{
+				{"type": "network", "name": "neutron", "endpoints": []map[string]interface{}{
+					{"interface": "public", "region": "RegionOne", "url": server.URL + "/public"},
+					{"interface": "internal", "region": "RegionOne", "url": server.URL + "/neutron"},
+				}},
+				{"type": "compute", "name": "nova", "endpoints": []map[string]interface{}{
+					{"interface": "internal", "region": "RegionOne", "url": server.URL + "/nova/v2.1"},
+				}},
+			},
+		}}
+		w.Header().Set("X-Subject-Token", fmt.Sprintf("token-%d", issued))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(resp)
+	})
+	return server, &issued
+}
+
+func TestAuthV3(t *testing.T) {
+	server, issued := newFakeKeystoneV3(time.Hour)
+	defer server.Close()
+
+	convey.Convey("TestAuthV3", t, func() {
+		cfg := fmt.Sprintf(`{"username": "admin", "password": "pwd", "url": "%s",
+			"tenantname": "admin", "interface": "internal", "region": "RegionOne"}`, server.URL)
+		openStack := NewOpenstack()
+		convey.So(openStack.SetOpenstackConfig(cfg), convey.ShouldBeNil)
+		convey.So(openStack.configV3, convey.ShouldNotBeNil)
+		convey.So(openStack.Auth(), convey.ShouldBeNil)
+		convey.So(openStack.GetTenantID(), convey.ShouldEqual, "project-id")
+		convey.So(openStack.provider.TokenID, convey.ShouldEqual, "token-1")
+		convey.So(openStack.neutronClient.ResourceBase, convey.ShouldEqual, server.URL+"/neutron/v2.0/")
+		convey.So(openStack.novaClient.Endpoint, convey.ShouldEqual, server.URL+"/nova/v2.1/")
+
+		convey.Convey("ReauthFunc issues a new token\n", func() {
+			convey.So(openStack.provider.ReauthFunc(), convey.ShouldBeNil)
+			convey.So(openStack.provider.TokenID, convey.ShouldEqual, "token-2")
+			convey.So(*issued, convey.ShouldEqual, 2)
+		})
+	})
+
+	convey.Convey("TestAuthenticatedClient v2 config is not sent to v3", t, func() {
+		conf := OpenStackConf{Url: server.URL + "/v2.0", Username: "admin", Password: "pwd"}
+		_, err := AuthenticatedClient(&conf)
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(*issued, convey.ShouldEqual, 2)
+	})
+}
@@ -13,6 +13,10 @@ limitations under the License.
 
 package transdsl
 
+import (
+	"reflect"
+)
+
 type Fragment interface {
 	Exec(transInfo *TransInfo) error
 	RollBack(transInfo *TransInfo)
@@ -25,6 +29,7 @@ func forEachFragments(fragments []Fragment, transInfo *TransInfo) (int, error) {
 			if IsErrorEqual(err, ErrTransEnd) {
 				return 0, err
 			}
+			recordErrFragment(fragments[i], transInfo, err)
 			return i, err
 		}
 	}
@@ -40,3 +45,21 @@ func backEachFragments(fragments []Fragment, transInfo *TransInfo, index int) {
 		fragments[index].RollBack(transInfo)
 	}
 }
+
+// recordErrFragment keeps the first failed fragment, which is the innermost
+// one since the containers record after their children.
+func recordErrFragment(fragment Fragment, transInfo *TransInfo, err error) {
+	if transInfo.ErrFragment != "" || IsErrorEqual(err, ErrContinue) {
+		return
+	}
+	transInfo.ErrFragment = GetFragmentName(fragment)
+}
+
+// GetFragmentName returns the type name of the fragment, e.g. GetPodAction.
+func GetFragmentName(fragment Fragment) string {
+	fragmentType := reflect.TypeOf(fragment)
+	for fragmentType.Kind() == reflect.Ptr {
+		fragmentType = fragmentType.Elem()
+	}
+	return fragmentType.Name()
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package transdsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type succAction struct {
+}
+
+func (this *succAction) Exec(transInfo *TransInfo) error {
+	return nil
+}
+
+func (this *succAction) RollBack(transInfo *TransInfo) {
+}
+
+type failAction struct {
+}
+
+func (this *failAction) Exec(transInfo *TransInfo) error {
+	return errors.New("fail")
+}
+
+func (this *failAction) RollBack(transInfo *TransInfo) {
+}
+
+type continueAction struct {
+}
+
+func (this *continueAction) Exec(transInfo *TransInfo) error {
+	return ErrContinue
+}
+
+func (this *continueAction) RollBack(transInfo *TransInfo) {
+}
+
+func TestErrFragment(t *testing.T) {
+	convey.Convey("TestErrFragment", t, func() {
+		convey.Convey("innermost failed fragment is recorded\n", func() {
+			trans := &Transaction{
+				Fragments: []Fragment{
+					new(succAction),
+					&Procedure{
+						Fragments: []Fragment{
+							new(succAction),
+							new(failAction),
+						},
+					},
+				},
+			}
+			transInfo := &TransInfo{}
+			err := trans.Exec(transInfo)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(transInfo.ErrFragment, convey.ShouldEqual, "failAction")
+		})
+
+		convey.Convey("failed fragment in repeat is recorded\n", func() {
+			trans := &Transaction{
+				Fragments: []Fragment{
+					&Repeat{
+						FuncVar: func() Fragment {
+							return new(continueAction)
+						},
+					},
+					&Repeat{
+						FuncVar: func() Fragment {
+							return new(failAction)
+						},
+					},
+				},
+			}
+			transInfo := &TransInfo{Times: 2}
+			err := trans.Exec(transInfo)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(transInfo.ErrFragment, convey.ShouldEqual, "failAction")
+		})
+
+		convey.Convey("nothing is recorded on success\n", func() {
+			trans := &Transaction{Fragments: []Fragment{new(succAction)}}
+			transInfo := &TransInfo{}
+			convey.So(trans.Exec(transInfo), convey.ShouldBeNil)
+			convey.So(transInfo.ErrFragment, convey.ShouldEqual, "")
+		})
+	})
+}
@@ -28,6 +28,7 @@ func (this *Repeat) Exec(transInfo *TransInfo) error {
 			if err.Error() == ErrContinue.Error() {
 				continue
 			}
+			recordErrFragment(this.Fragments[i], transInfo, err)
 			if i == 0 {
 				return err
 			}
@@ -17,6 +17,8 @@ type TransInfo struct {
 	// DDD framework params
 	Times     int
 	RepeatIdx int
+	// name of the innermost fragment which failed the transaction
+	ErrFragment string
 
 	// user app info
 	AppInfo interface{}
@@ -0,0 +1,704 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vnfm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ZTE/Knitter/pkg/db-accessor"
+	"github.com/ZTE/Knitter/pkg/http"
+	. "github.com/ZTE/Knitter/pkg/iaas-accessor"
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+	"github.com/ZTE/Knitter/pkg/klog"
+	"github.com/rackspace/gophercloud/openstack/networking/v2/subnets"
+)
+
+const (
+	TypeVnfm = "VNFM"
+
+	DefaultJobPollIntervalInSec = 2
+	DefaultJobTimeoutInSec      = 120
+	MaxReqForAttach             = 5
+)
+
+const (
+	JobOperationCreate = "create"
+	JobOperationAttach = "attach"
+	JobOperationDetach = "detach"
+)
+
+const (
+	JobStatusProcessing = "processing"
+	JobStatusFinished   = "finished"
+	JobStatusError      = "error"
+)
+
+// checkPortName is used to probe the VNFM, a port named like this never exists
+const checkPortName = "knitter-vnfm-check-port"
+
+var (
+	ErrJobFailed        = errors.New("vnfm job failed")
+	ErrJobTimeout       = errors.New("vnfm job time out")
+	ErrPortNotFound     = errors.New("vnfm port not found")
+	ErrInvalidConf      = errors.New("vnfm config is invalid")
+	ErrEmptyJobResult   = errors.New("vnfm job result is empty")
+	ErrCpsCountMismatch = errors.New("vnfm job result cps count mismatch")
+)
+
+// goReclaim runs the reclaim of cps created by a timed out job
+var goReclaim = func(reclaim func()) {
+	go reclaim()
+}
+
+type VnfmConf struct {
+	URL                  string `json:"url"`
+	NfInstanceID         string `json:"nf_instance_id"`
+	JobPollIntervalInSec int    `json:"job_poll_interval,omitempty"`
+	JobTimeoutInSec      int    `json:"job_timeout,omitempty"`
+}
+
+type Vnfm struct {
+	conf      VnfmConf
+	AttachReq int
+}
+
+// Cp is a connection point allocated by VNFM, it is a neutron port from knitter's view
+type Cp struct {
+	ResourceID string `json:"resourceId,omitempty"`
+	CpName     string `json:"cpName"`
+	NetworkID  string `json:"networkId"`
+	SubnetID   string `json:"subnetId,omitempty"`
+	IPAddress  string `json:"ipAddress,omitempty"`
+	MacAddress string `json:"macAddress,omitempty"`
+	VnicType   string `json:"vnicType,omitempty"`
+	Status     string `json:"status,omitempty"`
+	VMName     string `json:"vmName,omitempty"`
+}
+
+type CreateCpsReq struct {
+	Cps []*Cp `json:"cps"`
+}
+
+type AttachDetachReq struct {
+	Operation  string `json:"operation"`
+	ResourceID string `json:"resourceId"`
+}
+
+type JobResp struct {
+	JobID string `json:"jobId"`
+}
+
+type JobResult struct {
+	JobID       string `json:"jobId"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	Cps         []*Cp  `json:"cps"`
+}
+
+type PortsResp struct {
+	Ports []*Cp `json:"ports"`
+}
+
+type VnfmNetwork struct {
+	NetworkID       string   `json:"networkId"`
+	NetworkName     string   `json:"networkName"`
+	NetworkType     string   `json:"networkType"`
+	PhysicalNetwork string   `json:"physicalNetwork"`
+	SegmentationID  string   `json:"segmentationId"`
+	VlanTransparent bool     `json:"vlanTransparent"`
+	Subnets         []string `json:"subnets"`
+}
+
+type NetworksResp struct {
+	Networks []*VnfmNetwork `json:"networks"`
+}
+
+type NetworkResp struct {
+	Network *VnfmNetwork `json:"network"`
+}
+
+type VnfmSubnet struct {
+	SubnetID        string                   `json:"subnetId"`
+	SubnetName      string                   `json:"subnetName"`
+	NetworkID       string                   `json:"networkId"`
+	Cidr            string                   `json:"cidr"`
+	GatewayIP       string                   `json:"gatewayIp"`
+	TenantID        string                   `json:"tenantId"`
+	AllocationPools []subnets.AllocationPool `json:"allocationPools"`
+}
+
+type SubnetResp struct {
+	Subnet *VnfmSubnet `json:"subnet"`
+}
+
+func NewVnfm() *Vnfm {
+	return &Vnfm{AttachReq: MaxReqForAttach}
+}
+
+func (self *Vnfm) SetVnfmConfig(cfgStr string) error {
+	conf := VnfmConf{}
+	err := json.Unmarshal([]byte(cfgStr), &conf)
+	if err != nil {
+		klog.Errorf("SetVnfmConfig: json.Unmarshal(%v) FAILED, error: %v", cfgStr, err)
+		return err
+	}
+	return self.SetConfig(conf)
+}
+
+func (self *Vnfm) SetConfig(conf VnfmConf) error {
+	if conf.URL == "" || conf.NfInstanceID == "" {
+		klog.Errorf("SetConfig: url[%v] or nf_instance_id[%v] is blank", conf.URL, conf.NfInstanceID)
+		return ErrInvalidConf
+	}
+	conf.URL = strings.TrimSuffix(conf.URL, "/")
+	if conf.JobPollIntervalInSec <= 0 {
+		conf.JobPollIntervalInSec = DefaultJobPollIntervalInSec
+	}
+	if conf.JobTimeoutInSec <= 0 {
+		conf.JobTimeoutInSec = DefaultJobTimeoutInSec
+	}
+	self.conf = conf
+	return nil
+}
+
+func (self *Vnfm) GetConfig() VnfmConf {
+	return self.conf
+}
+
+func (self *Vnfm) GetTenantUUID(cfg string) (string, error) {
+	return self.conf.NfInstanceID, nil
+}
+
+func (self *Vnfm) GetType() string {
+	return TypeVnfm
+}
+
+// Auth checks VNFM is reachable, VNFM itself is trusted by network so no token is needed
+func (self *Vnfm) Auth() error {
+	return CheckVnfm(self.conf)
+}
+
+// CheckVnfm deletes a port which never exists, VNFM works well only if it responds 404
+var CheckVnfm = func(conf VnfmConf) error {
+	delURL := dbaccessor.GetVnfmDeletePortUrl(conf.URL, conf.NfInstanceID, checkPortName)
+	err, statusCode, _ := http.GetHTTPClientObj().Delete(delURL)
+	if err != nil {
+		klog.Errorf("CheckVnfm: Delete url[%v] error: %v", delURL, err)
+		return fmt.Errorf("%v:CheckVnfm: Delete error", err)
+	}
+	if statusCode != 404 {
+		klog.Errorf("CheckVnfm: Delete url[%v] status code: %v, expect 404", delURL, statusCode)
+		return fmt.Errorf("CheckVnfm: unexpected status code: %v", statusCode)
+	}
+	return nil
+}
+
+func (self *Vnfm) baseURL() string {
+	return self.conf.URL
+}
+
+func (self *Vnfm) pollInterval() time.Duration {
+	return time.Duration(self.conf.JobPollIntervalInSec) * time.Second
+}
+
+func (self *Vnfm) jobTimeout() time.Duration {
+	return time.Duration(self.conf.JobTimeoutInSec) * time.Second
+}
+
+func (self *Vnfm) postJob(url string, body interface{}) (string, error) {
+	bodyMap, err := toMap(body)
+	if err != nil {
+		return "", err
+	}
+	rspBytes, err := http.GetHTTPClientObj().Post(url, bodyMap)
+	if err != nil {
+		klog.Errorf("postJob: Post url[%v], body[%v] error: %v", url, bodyMap, err)
+		return "", fmt.Errorf("%v:postJob: Post error", err)
+	}
+	jobResp := JobResp{}
+	err = json.Unmarshal(rspBytes, &jobResp)
+	if err != nil {
+		klog.Errorf("postJob: json.Unmarshal(%v) error: %v", string(rspBytes), err)
+		return "", fmt.Errorf("%v:postJob: json.Unmarshal error", err)
+	}
+	if jobResp.JobID == "" {
+		klog.Errorf("postJob: url[%v] response has no jobId: %v", url, string(rspBytes))
+		return "", errors.New("postJob: response has no jobId")
+	}
+	klog.Infof("postJob: url[%v] job[%v] accepted", url, jobResp.JobID)
+	return jobResp.JobID, nil
+}
+
+// WaitJob polls the job result until the job finishes, fails or time out
+func (self *Vnfm) WaitJob(jobID, operation string) (*JobResult, error) {
+	url := dbaccessor.GetVnfmJobResultUrl(self.baseURL(), jobID, operation)
+	deadline := time.Now().Add(self.jobTimeout())
+	for {
+		result, err := getJobResult(url)
+		if err != nil {
+			klog.Warningf("WaitJob: get job[%v] result error: %v, just retry", jobID, err)
+		} else {
+			switch result.Status {
+			case JobStatusFinished:
+				klog.Infof("WaitJob: job[%v] operation[%v] finished", jobID, operation)
+				return result, nil
+			case JobStatusError:
+				klog.Errorf("WaitJob: job[%v] operation[%v] failed: %v", jobID, operation, result.Description)
+				return nil, fmt.Errorf("%v:%v", ErrJobFailed, result.Description)
+			default:
+				klog.Debugf("WaitJob: job[%v] operation[%v] status: %v", jobID, operation, result.Status)
+			}
+		}
+		if time.Now().After(deadline) {
+			klog.Errorf("WaitJob: job[%v] operation[%v] wait %v time out", jobID, operation, self.jobTimeout())
+			return nil, ErrJobTimeout
+		}
+		time.Sleep(self.pollInterval())
+	}
+}
+
+func getJobResult(url string) (*JobResult, error) {
+	rspBytes, err := http.GetHTTPClientObj().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	result := JobResult{}
+	err = json.Unmarshal(rspBytes, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func toMap(obj interface{}) (map[string]interface{}, error) {
+	objBytes, err := json.Marshal(obj)
+	if err != nil {
+		klog.Errorf("toMap: json.Marshal(%v) error: %v", obj, err)
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	err = json.Unmarshal(objBytes, &m)
+	if err != nil {
+		klog.Errorf("toMap: json.Unmarshal(%v) error: %v", string(objBytes), err)
+		return nil, err
+	}
+	return m, nil
+}
+
+func cpToInterface(cp *Cp) *Interface {
+	return &Interface{
+		Id:         cp.ResourceID,
+		Name:       cp.CpName,
+		Status:     cp.Status,
+		NetworkId:  cp.NetworkID,
+		SubnetId:   cp.SubnetID,
+		Ip:         cp.IPAddress,
+		MacAddress: cp.MacAddress,
+		DeviceId:   cp.VMName,
+		NicType:    cp.VnicType,
+	}
+}
+
+func (self *Vnfm) createCps(cps []*Cp) ([]*Interface, error) {
+	url := dbaccessor.GetVnfmCreatePortUrl(self.baseURL(), self.conf.NfInstanceID)
+	jobID, err := self.postJob(url, &CreateCpsReq{Cps: cps})
+	if err != nil {
+		return nil, err
+	}
+	result, err := self.WaitJob(jobID, JobOperationCreate)
+	if err == ErrJobTimeout {
+		goReclaim(func() { self.reclaimCps(jobID, cps) })
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Cps) != len(cps) {
+		klog.Errorf("createCps: job[%v] request %d cps, but got %d", jobID, len(cps), len(result.Cps))
+		self.rollbackCps(result.Cps)
+		return nil, ErrCpsCountMismatch
+	}
+	inters := make([]*Interface, 0, len(result.Cps))
+	for _, cp := range result.Cps {
+		inters = append(inters, cpToInterface(cp))
+	}
+	return inters, nil
+}
+
+// reclaimCps deletes the cps a timed out job may still create: it waits for
+// the job once more and rolls back its result, or if the job is still not
+// finished, deletes the unattached cps named as requested
+func (self *Vnfm) reclaimCps(jobID string, cps []*Cp) {
+	result, err := self.WaitJob(jobID, JobOperationCreate)
+	if err == nil {
+		klog.Infof("reclaimCps: job[%v] finished after time out, rollback %d cps", jobID, len(result.Cps))
+		self.rollbackCps(result.Cps)
+		return
+	}
+	if err != ErrJobTimeout {
+		klog.Infof("reclaimCps: job[%v] error: %v, no cps to reclaim", jobID, err)
+		return
+	}
+
+	klog.Warningf("reclaimCps: job[%v] still not finished, delete cps by name", jobID)
+	created, err := self.findCpsByName(cps)
+	if err != nil {
+		klog.Errorf("reclaimCps: findCpsByName of job[%v] error: %v", jobID, err)
+		return
+	}
+	self.rollbackCps(created)
+}
+
+// findCpsByName returns the unattached cps named as the requested ones, a
+// name matching more than one cp is ambiguous and skipped
+func (self *Vnfm) findCpsByName(cps []*Cp) ([]*Cp, error) {
+	names := make(map[string]bool)
+	networks := make([]string, 0)
+	for _, cp := range cps {
+		names[cp.CpName] = true
+		if !containsString(networks, cp.NetworkID) {
+			networks = append(networks, cp.NetworkID)
+		}
+	}
+
+	matched := make(map[string][]*Cp)
+	for _, networkID := range networks {
+		inters, err := self.ListPorts(networkID)
+		if err != nil {
+			return nil, err
+		}
+		for _, inter := range inters {
+			if names[inter.Name] && inter.DeviceId == "" {
+				matched[inter.Name] = append(matched[inter.Name], &Cp{CpName: inter.Name, ResourceID: inter.Id})
+			}
+		}
+	}
+
+	found := make([]*Cp, 0)
+	for name, named := range matched {
+		if len(named) != 1 {
+			klog.Warningf("findCpsByName: %d cps named[%v], skip them", len(named), name)
+			continue
+		}
+		found = append(found, named[0])
+	}
+	return found, nil
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Vnfm) rollbackCps(cps []*Cp) {
+	for _, cp := range cps {
+		if cp.ResourceID == "" {
+			continue
+		}
+		err := self.DeletePort(cp.ResourceID)
+		if err != nil {
+			klog.Errorf("rollbackCps: DeletePort[%v] error: %v", cp.ResourceID, err)
+		}
+	}
+}
+
+func (self *Vnfm) CreatePort(networkId, subnetId, portName, ip, mac, vnicType string) (*Interface, error) {
+	cp := &Cp{CpName: portName, NetworkID: networkId, SubnetID: subnetId,
+		IPAddress: ip, MacAddress: mac, VnicType: vnicType}
+	inters, err := self.createCps([]*Cp{cp})
+	if err != nil {
+		klog.Errorf("CreatePort: createCps[%+v] error: %v", cp, err)
+		return nil, fmt.Errorf("%v:CreatePort: createCps error", err)
+	}
+	return inters[0], nil
+}
+
+func (self *Vnfm) CreateBulkPorts(req *mgriaas.MgrBulkPortsReq) ([]*Interface, error) {
+	cps := make([]*Cp, 0, len(req.Ports))
+	for _, reqPort := range req.Ports {
+		cps = append(cps, &Cp{CpName: reqPort.PortName, NetworkID: reqPort.NetworkId,
+			SubnetID: reqPort.SubnetId, IPAddress: reqPort.FixIP, VnicType: reqPort.VnicType})
+	}
+	klog.Infof("CreateBulkPorts: TranId[%v] create %d cps", req.TranId, len(cps))
+	inters, err := self.createCps(cps)
+	if err != nil {
+		klog.Errorf("CreateBulkPorts: TranId[%v] createCps error: %v", req.TranId, err)
+		return nil, err
+	}
+	return inters, nil
+}
+
+func (self *Vnfm) getPorts(url string) ([]*Cp, error) {
+	rspBytes, err := http.GetHTTPClientObj().Get(url)
+	if err != nil {
+		klog.Errorf("getPorts: Get url[%v] error: %v", url, err)
+		return nil, fmt.Errorf("%v:getPorts: Get error", err)
+	}
+	resp := PortsResp{}
+	err = json.Unmarshal(rspBytes, &resp)
+	if err != nil {
+		klog.Errorf("getPorts: json.Unmarshal(%v) error: %v", string(rspBytes), err)
+		return nil, fmt.Errorf("%v:getPorts: json.Unmarshal error", err)
+	}
+	return resp.Ports, nil
+}
+
+func (self *Vnfm) GetPort(id string) (*Interface, error) {
+	url := dbaccessor.GetVnfmPortInfoUrl(self.baseURL(), self.conf.NfInstanceID, id)
+	cps, err := self.getPorts(url)
+	if err != nil {
+		return nil, err
+	}
+	if len(cps) == 0 {
+		klog.Errorf("GetPort: port[%v] not found", id)
+		return nil, ErrPortNotFound
+	}
+	return cpToInterface(cps[0]), nil
+}
+
+// DeletePort deletes the cp and waits until VNFM does not report it anymore
+func (self *Vnfm) DeletePort(id string) error {
+	url := dbaccessor.GetVnfmDeletePortUrl(self.baseURL(), self.conf.NfInstanceID, id)
+	err, statusCode, respBodyStr := http.GetHTTPClientObj().Delete(url)
+	if err != nil {
+		klog.Errorf("DeletePort: Delete port[id: %v] error: %v", id, err)
+		return fmt.Errorf("%v:DeletePort: Delete port error", err)
+	}
+	if statusCode == 404 {
+		return nil
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		klog.Errorf("DeletePort: Delete port[id: %v] error, Status Code: %v", id, statusCode)
+		return fmt.Errorf("Delete port[id: %v] error[%v], Status Code: %v", id, respBodyStr, statusCode)
+	}
+
+	deadline := time.Now().Add(self.jobTimeout())
+	for {
+		_, err := self.GetPort(id)
+		if err == ErrPortNotFound {
+			klog.Infof("DeletePort: port[id: %v] deleted", id)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			klog.Errorf("DeletePort: wait port[id: %v] deleted time out, last error: %v", id, err)
+			return ErrJobTimeout
+		}
+		time.Sleep(self.pollInterval())
+	}
+}
+
+func (self *Vnfm) ListPorts(networkID string) ([]*Interface, error) {
+	url := dbaccessor.GetVnfmNetworkPortsUrl(self.baseURL(), self.conf.NfInstanceID, networkID)
+	cps, err := self.getPorts(url)
+	if err != nil {
+		klog.Errorf("ListPorts: getPorts of network[%v] error: %v", networkID, err)
+		return nil, err
+	}
+	inters := make([]*Interface, 0, len(cps))
+	for _, cp := range cps {
+		inters = append(inters, cpToInterface(cp))
+	}
+	return inters, nil
+}
+
+// cps are named by VNFM when created, they can not be renamed
+func (self *Vnfm) UpdatePortName(id, name string) error {
+	return errors.New("VNFM unsupported operation: UpdatePortName")
+}
+
+// networks are instantiated by VNFM according to VNFD, knitter can only use them
+func (self *Vnfm) CreateNetwork(name string) (*Network, error) {
+	return nil, errors.New("VNFM unsupported operation: CreateNetwork")
+}
+
+func (self *Vnfm) CreateProviderNetwork(name, nwType, phyNet, sId string, vlanTransparent bool) (*Network, error) {
+	return nil, errors.New("VNFM unsupported operation: CreateProviderNetwork")
+}
+
+func (self *Vnfm) DeleteNetwork(id string) error {
+	return errors.New("VNFM unsupported operation: DeleteNetwork")
+}
+
+func (self *Vnfm) getNetwork(id string) (*VnfmNetwork, error) {
+	url := dbaccessor.GetVnfmNetworkIDUrl(self.baseURL(), self.conf.NfInstanceID, id)
+	rspBytes, err := http.GetHTTPClientObj().Get(url)
+	if err != nil {
+		klog.Warningf("getNetwork: Get url[%v] error: %v", url, err)
+		if strings.Contains(err.Error(), "404") {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%v:GetNetwork: socket-error", err)
+	}
+	resp := NetworkResp{}
+	err = json.Unmarshal(rspBytes, &resp)
+	if err != nil || resp.Network == nil {
+		klog.Errorf("getNetwork: json.Unmarshal(%v) error: %v", string(rspBytes), err)
+		return nil, fmt.Errorf("%v:getNetwork: parse response body error", err)
+	}
+	return resp.Network, nil
+}
+
+func (self *Vnfm) GetNetworkID(networkName string) (string, error) {
+	url := dbaccessor.GetVnfmNetwrokUrl(self.baseURL(), self.conf.NfInstanceID, networkName)
+	rspBytes, err := http.GetHTTPClientObj().Get(url)
+	if err != nil {
+		klog.Errorf("GetNetworkID: Get url[%v] error: %v", url, err)
+		return "", fmt.Errorf("%v:GetNetworkID: Get request error", err)
+	}
+	resp := NetworksResp{}
+	err = json.Unmarshal(rspBytes, &resp)
+	if err != nil {
+		klog.Errorf("GetNetworkID: json.Unmarshal(%v) error: %v", string(rspBytes), err)
+		return "", fmt.Errorf("%v:GetNetworkID: json.Unmarshal error", err)
+	}
+	for _, nw := range resp.Networks {
+		if nw.NetworkName == networkName {
+			return nw.NetworkID, nil
+		}
+	}
+	klog.Errorf("GetNetworkID: not found the network named: %v", networkName)
+	return "", errors.New("GetNetworkID: not found the network")
+}
+
+func (self *Vnfm) GetNetwork(id string) (*Network, error) {
+	nw, err := self.getNetwork(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Network{Id: nw.NetworkID, Name: nw.NetworkName}, nil
+}
+
+func (self *Vnfm) GetNetworkExtenAttrs(id string) (*NetworkExtenAttrs, error) {
+	nw, err := self.getNetwork(id)
+	if err != nil {
+		return nil, err
+	}
+	return &NetworkExtenAttrs{
+		Id:              nw.NetworkID,
+		Name:            nw.NetworkName,
+		NetworkType:     nw.NetworkType,
+		PhysicalNetwork: nw.PhysicalNetwork,
+		SegmentationID:  nw.SegmentationID,
+		VlanTransparent: nw.VlanTransparent,
+	}, nil
+}
+
+func (self *Vnfm) CreateSubnet(id, cidr, gw string, alloctionPools []subnets.AllocationPool) (*Subnet, error) {
+	return nil, errors.New("VNFM unsupported operation: CreateSubnet")
+}
+
+func (self *Vnfm) DeleteSubnet(id string) error {
+	return errors.New("VNFM unsupported operation: DeleteSubnet")
+}
+
+func (self *Vnfm) GetSubnetID(networkId string) (string, error) {
+	nw, err := self.getNetwork(networkId)
+	if err != nil {
+		return "", err
+	}
+	if len(nw.Subnets) < 1 {
+		klog.Errorf("GetSubnetID: there is no subnet in network[id: %v]", networkId)
+		return "", errors.New("GetSubnetID: there is no subnet in network")
+	}
+	return nw.Subnets[0], nil
+}
+
+func (self *Vnfm) GetSubnet(id string) (*Subnet, error) {
+	url := dbaccessor.GetVnfmSubNetworkIDUrl(self.baseURL(), self.conf.NfInstanceID, id)
+	rspBytes, err := http.GetHTTPClientObj().Get(url)
+	if err != nil {
+		klog.Errorf("GetSubnet: Get url[%v] error: %v", url, err)
+		return nil, fmt.Errorf("%v:GetSubnet: http Get error", err)
+	}
+	resp := SubnetResp{}
+	err = json.Unmarshal(rspBytes, &resp)
+	if err != nil || resp.Subnet == nil {
+		klog.Errorf("GetSubnet: json.Unmarshal(%v) error: %v", string(rspBytes), err)
+		return nil, fmt.Errorf("%v:GetSubnet: parse response body error", err)
+	}
+	sn := resp.Subnet
+	return &Subnet{Id: sn.SubnetID, NetworkId: sn.NetworkID, Name: sn.SubnetName, Cidr: sn.Cidr,
+		GatewayIp: sn.GatewayIP, TenantId: sn.TenantID, AllocationPools: sn.AllocationPools}, nil
+}
+
+func (self *Vnfm) CreateRouter(name, extNetId string) (string, error) {
+	return "", errors.New("VNFM unsupported operation: CreateRouter")
+}
+
+func (self *Vnfm) UpdateRouter(id, name, extNetID string) error {
+	return errors.New("VNFM unsupported operation: UpdateRouter")
+}
+
+func (self *Vnfm) GetRouter(id string) (*Router, error) {
+	return nil, errors.New("VNFM unsupported operation: GetRouter")
+}
+
+func (self *Vnfm) DeleteRouter(id string) error {
+	return errors.New("VNFM unsupported operation: DeleteRouter")
+}
+
+// AttachPortToVM attaches the cp to vm, vmId equals vm name for VNFM
+func (self *Vnfm) AttachPortToVM(vmId, portId string) (*Interface, error) {
+	url := dbaccessor.GetVnfmAttachDetachPortUrl(self.baseURL(), self.conf.NfInstanceID, vmId)
+	jobID, err := self.postJob(url, &AttachDetachReq{Operation: JobOperationAttach, ResourceID: portId})
+	if err != nil {
+		klog.Errorf("AttachPortToVM: attach port[%v] to vm[%v] error: %v", portId, vmId, err)
+		return nil, err
+	}
+	_, err = self.WaitJob(jobID, JobOperationAttach)
+	if err != nil {
+		klog.Errorf("AttachPortToVM: wait attach port[%v] to vm[%v] error: %v", portId, vmId, err)
+		return nil, err
+	}
+	return self.GetPort(portId)
+}
+
+func (self *Vnfm) DetachPortFromVM(vmId, portId string) error {
+	url := dbaccessor.GetVnfmAttachDetachPortUrl(self.baseURL(), self.conf.NfInstanceID, vmId)
+	jobID, err := self.postJob(url, &AttachDetachReq{Operation: JobOperationDetach, ResourceID: portId})
+	if err != nil {
+		klog.Errorf("DetachPortFromVM: detach port[%v] from vm[%v] error: %v", portId, vmId, err)
+		return err
+	}
+	_, err = self.WaitJob(jobID, JobOperationDetach)
+	if err != nil {
+		klog.Errorf("DetachPortFromVM: wait detach port[%v] from vm[%v] error: %v", portId, vmId, err)
+		return err
+	}
+	return nil
+}
+
+func (self *Vnfm) AttachNetToRouter(routerId, subNetId string) (string, error) {
+	return "", errors.New("VNFM unsupported operation: AttachNetToRouter")
+}
+
+func (self *Vnfm) DetachNetFromRouter(routerId, netId string) (string, error) {
+	return "", errors.New("VNFM unsupported operation: DetachNetFromRouter")
+}
+
+func (self *Vnfm) GetAttachReq() int {
+	return self.AttachReq
+}
+
+func (self *Vnfm) SetAttachReq(req int) {
+	self.AttachReq = req
+}
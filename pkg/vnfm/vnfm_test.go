@@ -0,0 +1,404 @@
+/*
+Copyright 2018 ZTE Corporation. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vnfm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ZTE/Knitter/pkg/inter-cmpt/mgr-iaas"
+	"github.com/golang/gostub"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const testNfInstanceID = "nf-1"
+
+type fakeJob struct {
+	pendingPolls int
+	failReason   string
+	cps          []*Cp
+}
+
+// fakeVnfm is a minimal in-memory VNFM which serves the cp and job APIs
+type fakeVnfm struct {
+	lock         sync.Mutex
+	server       *httptest.Server
+	cps          map[string]*Cp
+	jobs         map[string]*fakeJob
+	seq          int
+	pendingPolls int
+	failReason   string
+	neverFinish  bool
+	// createdOnPost stores the cps before their job finishes
+	createdOnPost bool
+	// lostCps drops the last cp from the job result
+	lostCps bool
+}
+
+func newFakeVnfm() *fakeVnfm {
+	f := &fakeVnfm{cps: make(map[string]*Cp), jobs: make(map[string]*fakeJob)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeVnfm) close() {
+	f.server.Close()
+}
+
+func (f *fakeVnfm) newVnfm() *Vnfm {
+	v := NewVnfm()
+	v.SetConfig(VnfmConf{URL: f.server.URL + "/", NfInstanceID: testNfInstanceID,
+		JobPollIntervalInSec: 1, JobTimeoutInSec: 1})
+	return v
+}
+
+func (f *fakeVnfm) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s-%d", prefix, f.seq)
+}
+
+func (f *fakeVnfm) addJob(cps []*Cp) string {
+	jobID := f.nextID("job")
+	pending := f.pendingPolls
+	if f.neverFinish {
+		pending = -1
+	}
+	f.jobs[jobID] = &fakeJob{pendingPolls: pending, failReason: f.failReason, cps: cps}
+	return jobID
+}
+
+func (f *fakeVnfm) finishJobs() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, job := range f.jobs {
+		job.pendingPolls = 0
+	}
+}
+
+func (f *fakeVnfm) cpsCount() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.cps)
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+func (f *fakeVnfm) serve(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	nfPrefix := "/nfs/" + testNfInstanceID
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/nfs/job_results/"):
+		f.serveJobResult(w, strings.Split(strings.TrimPrefix(path, "/nfs/job_results/"), "/")[0])
+	case path == nfPrefix+"/cps" && r.Method == http.MethodPost:
+		f.serveCreateCps(w, r)
+	case strings.HasPrefix(path, nfPrefix+"/cps/") && r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(path, nfPrefix+"/cps/")
+		if _, ok := f.cps[id]; !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+			return
+		}
+		delete(f.cps, id)
+		w.WriteHeader(http.StatusAccepted)
+	case path == nfPrefix+"/ports":
+		f.servePorts(w, r)
+	case strings.HasPrefix(path, nfPrefix+"/cp_attach_detach/"):
+		f.serveAttachDetach(w, r, strings.TrimPrefix(path, nfPrefix+"/cp_attach_detach/"))
+	case path == nfPrefix+"/networks":
+		writeJSON(w, http.StatusOK, NetworksResp{Networks: []*VnfmNetwork{
+			{NetworkID: "net-id-1", NetworkName: r.URL.Query().Get("networkName")}}})
+	case path == nfPrefix+"/networks/net-id-1":
+		writeJSON(w, http.StatusOK, NetworkResp{Network: &VnfmNetwork{NetworkID: "net-id-1",
+			NetworkName: "control", NetworkType: "vlan", PhysicalNetwork: "physnet1",
+			SegmentationID: "100", Subnets: []string{"subnet-id-1"}}})
+	case path == nfPrefix+"/subnets/subnet-id-1":
+		writeJSON(w, http.StatusOK, SubnetResp{Subnet: &VnfmSubnet{SubnetID: "subnet-id-1",
+			NetworkID: "net-id-1", Cidr: "10.0.0.0/24", GatewayIP: "10.0.0.1"}})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func (f *fakeVnfm) serveCreateCps(w http.ResponseWriter, r *http.Request) {
+	req := CreateCpsReq{}
+	json.NewDecoder(r.Body).Decode(&req)
+	created := make([]*Cp, 0)
+	for idx, cp := range req.Cps {
+		cp.ResourceID = f.nextID("cp")
+		cp.Status = "DOWN"
+		cp.MacAddress = fmt.Sprintf("fa:16:3e:00:00:%02d", idx)
+		if cp.IPAddress == "" {
+			cp.IPAddress = fmt.Sprintf("10.0.0.%d", 10+f.seq)
+		}
+		created = append(created, cp)
+		if f.createdOnPost {
+			f.cps[cp.ResourceID] = cp
+		}
+	}
+	if f.lostCps {
+		created = created[:len(created)-1]
+	}
+	writeJSON(w, http.StatusAccepted, JobResp{JobID: f.addJob(created)})
+}
+
+func (f *fakeVnfm) serveJobResult(w http.ResponseWriter, jobID string) {
+	job, ok := f.jobs[jobID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if job.pendingPolls != 0 {
+		job.pendingPolls--
+		writeJSON(w, http.StatusOK, JobResult{JobID: jobID, Status: JobStatusProcessing})
+		return
+	}
+	if job.failReason != "" {
+		writeJSON(w, http.StatusOK, JobResult{JobID: jobID, Status: JobStatusError, Description: job.failReason})
+		return
+	}
+	for _, cp := range job.cps {
+		f.cps[cp.ResourceID] = cp
+	}
+	writeJSON(w, http.StatusOK, JobResult{JobID: jobID, Status: JobStatusFinished, Cps: job.cps})
+}
+
+func (f *fakeVnfm) servePorts(w http.ResponseWriter, r *http.Request) {
+	resourceID := r.URL.Query().Get("resourceId")
+	networkID := r.URL.Query().Get("networkId")
+	ports := make([]*Cp, 0)
+	for _, cp := range f.cps {
+		if (resourceID != "" && cp.ResourceID == resourceID) ||
+			(networkID != "" && cp.NetworkID == networkID) {
+			ports = append(ports, cp)
+		}
+	}
+	writeJSON(w, http.StatusOK, PortsResp{Ports: ports})
+}
+
+func (f *fakeVnfm) serveAttachDetach(w http.ResponseWriter, r *http.Request, vmName string) {
+	req := AttachDetachReq{}
+	json.NewDecoder(r.Body).Decode(&req)
+	cp, ok := f.cps[req.ResourceID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if req.Operation == JobOperationAttach {
+		cp.VMName = vmName
+		cp.Status = "ACTIVE"
+	} else {
+		cp.VMName = ""
+		cp.Status = "DOWN"
+	}
+	writeJSON(w, http.StatusAccepted, JobResp{JobID: f.addJob(nil)})
+}
+
+func TestVnfmSetConfig(t *testing.T) {
+	convey.Convey("TestVnfmSetConfig", t, func() {
+		v := NewVnfm()
+		convey.So(v.SetVnfmConfig(`{"url": "http://vnfm/api/", "nf_instance_id": "nf"}`), convey.ShouldBeNil)
+		convey.So(v.GetConfig().URL, convey.ShouldEqual, "http://vnfm/api")
+		convey.So(v.GetConfig().JobPollIntervalInSec, convey.ShouldEqual, DefaultJobPollIntervalInSec)
+		convey.So(v.GetConfig().JobTimeoutInSec, convey.ShouldEqual, DefaultJobTimeoutInSec)
+		convey.So(v.GetType(), convey.ShouldEqual, TypeVnfm)
+		convey.So(v.SetVnfmConfig(`{"url": "http://vnfm/api/"}`), convey.ShouldEqual, ErrInvalidConf)
+		convey.So(v.SetVnfmConfig(`{bad json`), convey.ShouldNotBeNil)
+	})
+}
+
+func TestVnfmAuth(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmAuth", t, func() {
+		convey.So(f.newVnfm().Auth(), convey.ShouldBeNil)
+
+		v := NewVnfm()
+		v.SetConfig(VnfmConf{URL: "http://127.0.0.1:1", NfInstanceID: testNfInstanceID})
+		convey.So(v.Auth(), convey.ShouldNotBeNil)
+	})
+}
+
+func TestVnfmCreateGetDeletePort(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmCreateGetDeletePort", t, func() {
+		v := f.newVnfm()
+		port, err := v.CreatePort("net-id-1", "subnet-id-1", "eth1", "10.0.0.5", "", "normal")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(port.Name, convey.ShouldEqual, "eth1")
+		convey.So(port.Ip, convey.ShouldEqual, "10.0.0.5")
+		convey.So(port.NetworkId, convey.ShouldEqual, "net-id-1")
+
+		got, err := v.GetPort(port.Id)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(got.MacAddress, convey.ShouldEqual, port.MacAddress)
+
+		ports, err := v.ListPorts("net-id-1")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ports), convey.ShouldEqual, 1)
+
+		convey.So(v.DeletePort(port.Id), convey.ShouldBeNil)
+		_, err = v.GetPort(port.Id)
+		convey.So(err, convey.ShouldEqual, ErrPortNotFound)
+		convey.So(v.DeletePort(port.Id), convey.ShouldBeNil)
+	})
+}
+
+func TestVnfmCreateBulkPortsWaitJob(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmCreateBulkPortsWaitJob", t, func() {
+		f.pendingPolls = 1
+		v := f.newVnfm()
+		v.conf.JobTimeoutInSec = 5
+		req := &mgriaas.MgrBulkPortsReq{TranId: "tran-1", Ports: []*mgriaas.MgrPortReq{{}, {}}}
+		req.Ports[0].PortName = "eth1"
+		req.Ports[0].NetworkId = "net-id-1"
+		req.Ports[1].PortName = "eth2"
+		req.Ports[1].NetworkId = "net-id-1"
+		ports, err := v.CreateBulkPorts(req)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ports), convey.ShouldEqual, 2)
+		convey.So(ports[0].Name, convey.ShouldEqual, "eth1")
+		convey.So(ports[1].Name, convey.ShouldEqual, "eth2")
+	})
+}
+
+func TestVnfmJobFailedAndTimeout(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmJobFailed", t, func() {
+		f.failReason = "no ip available"
+		_, err := f.newVnfm().CreatePort("net-id-1", "subnet-id-1", "eth1", "", "", "normal")
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "no ip available")
+	})
+	convey.Convey("TestVnfmJobTimeout", t, func() {
+		f.failReason = ""
+		f.neverFinish = true
+		stubs := gostub.Stub(&goReclaim, func(reclaim func()) {})
+		defer stubs.Reset()
+		_, err := f.newVnfm().CreatePort("net-id-1", "subnet-id-1", "eth1", "", "", "normal")
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, ErrJobTimeout.Error())
+	})
+}
+
+func TestVnfmReclaimCpsOfTimedOutJob(t *testing.T) {
+	convey.Convey("TestVnfmReclaimCpsOfTimedOutJob", t, func() {
+		f := newFakeVnfm()
+		defer f.close()
+		f.neverFinish = true
+
+		convey.Convey("rollback the cps when job finishes after time out", func() {
+			stubs := gostub.Stub(&goReclaim, func(reclaim func()) {
+				f.finishJobs()
+				reclaim()
+			})
+			defer stubs.Reset()
+			_, err := f.newVnfm().CreatePort("net-id-1", "subnet-id-1", "eth1", "", "", "normal")
+			convey.So(err.Error(), convey.ShouldContainSubstring, ErrJobTimeout.Error())
+			convey.So(f.cpsCount(), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("delete the cps by name when job is still not finished", func() {
+			f.createdOnPost = true
+			stubs := gostub.Stub(&goReclaim, func(reclaim func()) { reclaim() })
+			defer stubs.Reset()
+			_, err := f.newVnfm().CreatePort("net-id-1", "subnet-id-1", "eth1", "", "", "normal")
+			convey.So(err.Error(), convey.ShouldContainSubstring, ErrJobTimeout.Error())
+			convey.So(f.cpsCount(), convey.ShouldEqual, 0)
+		})
+	})
+}
+
+func TestVnfmJobResultCpsCountMismatch(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmJobResultCpsCountMismatch", t, func() {
+		f.lostCps = true
+		req := &mgriaas.MgrBulkPortsReq{TranId: "tran-2", Ports: []*mgriaas.MgrPortReq{{}, {}}}
+		req.Ports[0].PortName = "eth1"
+		req.Ports[0].NetworkId = "net-id-1"
+		req.Ports[1].PortName = "eth2"
+		req.Ports[1].NetworkId = "net-id-1"
+		_, err := f.newVnfm().CreateBulkPorts(req)
+		convey.So(err, convey.ShouldEqual, ErrCpsCountMismatch)
+		convey.So(f.cpsCount(), convey.ShouldEqual, 0)
+	})
+}
+
+func TestVnfmAttachDetachPort(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmAttachDetachPort", t, func() {
+		v := f.newVnfm()
+		port, err := v.CreatePort("net-id-1", "subnet-id-1", "eth1", "", "", "normal")
+		convey.So(err, convey.ShouldBeNil)
+
+		attached, err := v.AttachPortToVM("vm-name-1", port.Id)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(attached.DeviceId, convey.ShouldEqual, "vm-name-1")
+		convey.So(attached.Status, convey.ShouldEqual, "ACTIVE")
+
+		convey.So(v.DetachPortFromVM("vm-name-1", port.Id), convey.ShouldBeNil)
+		detached, _ := v.GetPort(port.Id)
+		convey.So(detached.DeviceId, convey.ShouldEqual, "")
+
+		_, err = v.AttachPortToVM("vm-name-1", "not-exist-port")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestVnfmNetworkOps(t *testing.T) {
+	f := newFakeVnfm()
+	defer f.close()
+	convey.Convey("TestVnfmNetworkOps", t, func() {
+		v := f.newVnfm()
+		id, err := v.GetNetworkID("control")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(id, convey.ShouldEqual, "net-id-1")
+
+		nw, err := v.GetNetwork(id)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(nw.Name, convey.ShouldEqual, "control")
+
+		attrs, err := v.GetNetworkExtenAttrs(id)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(attrs.NetworkType, convey.ShouldEqual, "vlan")
+		convey.So(attrs.SegmentationID, convey.ShouldEqual, "100")
+
+		subnetID, err := v.GetSubnetID(id)
+		convey.So(err, convey.ShouldBeNil)
+		subnet, err := v.GetSubnet(subnetID)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(subnet.Cidr, convey.ShouldEqual, "10.0.0.0/24")
+
+		_, err = v.GetNetwork("not-exist-net")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = v.CreateNetwork("new-net")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}